@@ -0,0 +1,125 @@
+package devstatscode
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// APIMetricsFile - project custom API metrics YAML (next to project metrics.yaml), its metrics are served by SeriesQuery API
+const APIMetricsFile = "api_metrics.yaml"
+
+// APIMetric - series backed metric available via SeriesQuery API without API code changes
+// Series name can use placeholders from APIMetricPlaceholders, columns and periods limit returned columns and allowed periods (all when empty)
+type APIMetric struct {
+	Name    string   `yaml:"name"`
+	Table   string   `yaml:"table"`
+	Series  string   `yaml:"series"`
+	Columns []string `yaml:"columns"`
+	Periods []string `yaml:"periods"`
+	Desc    string   `yaml:"desc"`
+}
+
+// AllAPIMetrics - project custom API metrics (api_metrics.yaml)
+type AllAPIMetrics struct {
+	Metrics []APIMetric `yaml:"metrics"`
+}
+
+// APIMetricPlaceholders - series name placeholders, SeriesQuery API replaces them with series name values of payload arguments with the same names
+var APIMetricPlaceholders = map[string]struct{}{
+	"repository_group": {},
+	"repository":       {},
+	"country":          {},
+}
+
+var (
+	apiMetricTableRe       = regexp.MustCompile(`^s[a-z0-9_]+$`)
+	apiMetricSeriesRe      = regexp.MustCompile(`^[a-zA-Z0-9_]*(?:{{[a-z_]+}}[a-zA-Z0-9_]*)*$`)
+	apiMetricPlaceholderRe = regexp.MustCompile(`{{([a-z_]+)}}`)
+)
+
+// Validate - checks that metric has a name, a valid series table and series name, known placeholders, valid columns and periods
+func (m *APIMetric) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("metric has no name")
+	}
+	if !apiMetricTableRe.MatchString(m.Table) {
+		return fmt.Errorf("metric '%s': invalid series table '%s'", m.Name, m.Table)
+	}
+	if m.Series == "" || !apiMetricSeriesRe.MatchString(m.Series) {
+		return fmt.Errorf("metric '%s': invalid series '%s'", m.Name, m.Series)
+	}
+	for _, placeholder := range m.Placeholders() {
+		if _, ok := APIMetricPlaceholders[placeholder]; !ok {
+			return fmt.Errorf("metric '%s': unknown series placeholder '{{%s}}'", m.Name, placeholder)
+		}
+	}
+	for _, column := range m.Columns {
+		if column == "" || strings.Contains(column, `"`) {
+			return fmt.Errorf("metric '%s': invalid column '%s'", m.Name, column)
+		}
+	}
+	for _, period := range m.Periods {
+		if !periodRe.MatchString(period) {
+			return fmt.Errorf("metric '%s': invalid period '%s'", m.Name, period)
+		}
+	}
+	return nil
+}
+
+// Placeholders - returns sorted placeholders used in metric series name
+func (m *APIMetric) Placeholders() (placeholders []string) {
+	seen := make(map[string]struct{})
+	for _, match := range apiMetricPlaceholderRe.FindAllStringSubmatch(m.Series, -1) {
+		seen[match[1]] = struct{}{}
+	}
+	placeholders = StringsSetKeys(seen)
+	sort.Strings(placeholders)
+	return
+}
+
+// SeriesName - returns metric series name with placeholders replaced by given values
+func (m *APIMetric) SeriesName(values map[string]string) string {
+	return apiMetricPlaceholderRe.ReplaceAllStringFunc(m.Series, func(placeholder string) string {
+		return values[placeholder[2:len(placeholder)-2]]
+	})
+}
+
+// HasPeriod - checks if metric can be queried for a given period
+func (m *APIMetric) HasPeriod(period string) bool {
+	if len(m.Periods) == 0 {
+		return true
+	}
+	for _, p := range m.Periods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAPIMetrics - parses and validates custom API metrics YAML, returns metrics by name
+func ParseAPIMetrics(data []byte) (metrics map[string]APIMetric, err error) {
+	var all AllAPIMetrics
+	err = yaml.UnmarshalStrict(data, &all)
+	if err != nil {
+		return
+	}
+	metrics = make(map[string]APIMetric)
+	for i, metric := range all.Metrics {
+		err = metric.Validate()
+		if err != nil {
+			err = fmt.Errorf("metric #%d: %v", i+1, err)
+			return
+		}
+		if _, ok := metrics[metric.Name]; ok {
+			err = fmt.Errorf("metric #%d: duplicate name '%s'", i+1, metric.Name)
+			return
+		}
+		metrics[metric.Name] = metric
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package devstatscode
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestParseAPIMetrics(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		yaml     string
+		expected []string
+		err      string
+	}{
+		{yaml: "metrics: []", expected: []string{}},
+		{
+			yaml: `metrics:
+  - name: Stars
+    table: sstars
+    series: stars{{repository_group}}
+    periods: [d, w7, m]
+  - name: Countries
+    table: scountries
+    series: countries_{{country}}_{{repository_group}}
+    columns: [value, "All companies"]
+`,
+			expected: []string{"Countries", "Stars"},
+		},
+		{yaml: "metrics:\n  - table: sstars\n    series: stars\n", err: "metric #1: metric has no name"},
+		{yaml: "metrics:\n  - name: X\n    table: gha_events\n    series: x\n", err: "invalid series table 'gha_events'"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: \"x' or 1=1\"\n", err: "invalid series"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: x{{company}}\n", err: "unknown series placeholder '{{company}}'"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: x\n    columns: ['a\"b']\n", err: "invalid column 'a\"b'"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: x\n    periods: [day]\n", err: "invalid period 'day'"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: x\n  - name: X\n    table: sy\n    series: y\n", err: "metric #2: duplicate name 'X'"},
+		{yaml: "metrics:\n  - name: X\n    table: sx\n    series: x\n    sql: x\n", err: "field sql not found"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		metrics, err := lib.ParseAPIMetrics([]byte(test.yaml))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("test number %d, expected error containing '%s', got %v", index+1, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test number %d, unexpected error: %v", index+1, err)
+			continue
+		}
+		got := []string{}
+		for name := range metrics {
+			got = append(got, name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected metrics %+v, got %+v", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestAPIMetricSeriesName(t *testing.T) {
+	metric := lib.APIMetric{Name: "Countries", Table: "scountries", Series: "countries_{{country}}{{repository_group}}", Periods: []string{"d", "m"}}
+	placeholders := metric.Placeholders()
+	if !reflect.DeepEqual(placeholders, []string{"country", "repository_group"}) {
+		t.Errorf("unexpected placeholders %+v", placeholders)
+	}
+	got := metric.SeriesName(map[string]string{"country": "poland", "repository_group": "all"})
+	if got != "countries_polandall" {
+		t.Errorf("expected series 'countries_polandall', got '%s'", got)
+	}
+	if !metric.HasPeriod("m") || metric.HasPeriod("w") {
+		t.Errorf("unexpected periods check result")
+	}
+	metric.Periods = nil
+	if !metric.HasPeriod("w") {
+		t.Errorf("expected all periods to be allowed")
+	}
+}
@@ -0,0 +1,119 @@
+package devstatscode
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rolling event IDs filter sizing: expected number of events in a single GHA hour and false positive rate
+const (
+	BloomHourEvents = 500000
+	BloomFalseRate  = 0.0001
+)
+
+// BloomFilter - probabilistic set of strings: Test never returns false for an added string,
+// it can return true for a string that was not added (with a given false positive rate)
+type BloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// NewBloomFilter - creates bloom filter for n strings with a given false positive rate
+func NewBloomFilter(n int, rate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// positions - calls f for all k bit positions of a given string (double hashing of 64bit FNV-1a halves)
+func (b *BloomFilter) positions(s string, f func(uint64) bool) bool {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, (sum>>32)|1
+	for i := uint64(0); i < b.k; i++ {
+		if !f((h1 + i*h2) % b.m) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add - adds a string to the filter
+func (b *BloomFilter) Add(s string) {
+	b.positions(s, func(pos uint64) bool {
+		b.bits[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+}
+
+// Test - returns true if a string was (probably) added to the filter
+func (b *BloomFilter) Test(s string) bool {
+	return b.positions(s, func(pos uint64) bool {
+		return b.bits[pos/64]&(1<<(pos%64)) != 0
+	})
+}
+
+// RollingIDs - bloom filters of IDs seen in each hour, used to detect IDs repeated in the same or adjacent hours
+// It is safe to use from multiple goroutines
+type RollingIDs struct {
+	window int
+	hours  map[time.Time]*BloomFilter
+	mtx    sync.Mutex
+}
+
+// NewRollingIDs - creates rolling IDs filter, IDs of a given hour are checked against window hours before and after it
+func NewRollingIDs(window int) *RollingIDs {
+	return &RollingIDs{window: window, hours: make(map[time.Time]*BloomFilter)}
+}
+
+// Seen - returns true if ID was (probably) already seen in a given hour or in window adjacent hours, ID is added to a given hour
+func (r *RollingIDs) Seen(hour time.Time, id string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	seen := false
+	for i := -r.window; i <= r.window; i++ {
+		b, ok := r.hours[hour.Add(time.Duration(i)*time.Hour)]
+		if ok && b.Test(id) {
+			seen = true
+			break
+		}
+	}
+	b, ok := r.hours[hour]
+	if !ok {
+		b = NewBloomFilter(BloomHourEvents, BloomFalseRate)
+		r.hours[hour] = b
+	}
+	b.Add(id)
+	return seen
+}
+
+// Prune - forgets IDs of hours before a given one, they are no longer adjacent to any hour that will be processed
+func (r *RollingIDs) Prune(before time.Time) {
+	r.mtx.Lock()
+	for hour := range r.hours {
+		if hour.Before(before) {
+			delete(r.hours, hour)
+		}
+	}
+	r.mtx.Unlock()
+}
+
+// Hours - returns number of hours kept
+func (r *RollingIDs) Hours() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.hours)
+}
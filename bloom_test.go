@@ -0,0 +1,70 @@
+package devstatscode
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestBloomFilter(t *testing.T) {
+	n := 10000
+	b := lib.NewBloomFilter(n, 0.001)
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("%d", 20000000000+i))
+	}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%d", 20000000000+i)
+		if !b.Test(id) {
+			t.Fatalf("expected added id %s to be found", id)
+		}
+	}
+	fp := 0
+	for i := 0; i < n; i++ {
+		if b.Test(fmt.Sprintf("%d", 30000000000+i)) {
+			fp++
+		}
+	}
+	// Expected 10 false positives, allow some margin
+	if fp > 50 {
+		t.Errorf("expected about %d false positives, got %d", n/1000, fp)
+	}
+}
+
+func TestRollingIDs(t *testing.T) {
+	ft := func(s string) time.Time { return lib.TimeParseAny(s) }
+	r := lib.NewRollingIDs(1)
+	// Test cases, executed in order on the same filter
+	var testCases = []struct {
+		hour     string
+		id       string
+		expected bool
+	}{
+		{hour: "2021-07-01 10:00:00", id: "1", expected: false},
+		{hour: "2021-07-01 10:00:00", id: "2", expected: false},
+		{hour: "2021-07-01 10:00:00", id: "1", expected: true},
+		{hour: "2021-07-01 11:00:00", id: "2", expected: true},
+		{hour: "2021-07-01 11:00:00", id: "3", expected: false},
+		{hour: "2021-07-01 09:00:00", id: "3", expected: false},
+		{hour: "2021-07-01 12:00:00", id: "1", expected: false},
+		{hour: "2021-07-01 13:00:00", id: "1", expected: true},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := r.Seen(ft(test.hour), test.id)
+		if got != test.expected {
+			t.Errorf("test number %d, hour %s, id %s, expected %v, got %v", index+1, test.hour, test.id, test.expected, got)
+		}
+	}
+	if r.Hours() != 5 {
+		t.Errorf("expected 5 hours, got %d", r.Hours())
+	}
+	r.Prune(ft("2021-07-01 12:00:00"))
+	if r.Hours() != 2 {
+		t.Errorf("expected 2 hours after prune, got %d", r.Hours())
+	}
+	if r.Seen(ft("2021-07-01 11:00:00"), "3") {
+		t.Errorf("expected pruned id to be forgotten")
+	}
+}
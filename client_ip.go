@@ -0,0 +1,95 @@
+package devstatscode
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies - parses comma separated list of trusted proxies, each one is a CIDR ("10.0.0.0/8") or a single IP ("127.0.0.1")
+func ParseTrustedProxies(proxies string) (nets []*net.IPNet, err error) {
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy IP: '%s'", proxy)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, e := net.ParseCIDR(proxy)
+		if e != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR: '%s': %v", proxy, e)
+		}
+		nets = append(nets, ipNet)
+	}
+	return
+}
+
+// isTrustedProxy - checks if IP is one of trusted proxies, invalid IPs are never trusted
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+	pIP := net.ParseIP(ip)
+	if pIP == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(pIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP - returns client IP of a HTTP request
+// X-Forwarded-For and X-Real-IP headers are only used when request comes from a trusted proxy, otherwise they can be spoofed
+// X-Forwarded-For is checked from the right (the nearest proxy) and the first address that is not a trusted proxy is the client
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+	xff := r.Header.Values("X-Forwarded-For")
+	if len(xff) > 0 {
+		ips := []string{}
+		for _, hdr := range xff {
+			for _, ip := range strings.Split(hdr, ",") {
+				ip = strings.TrimSpace(ip)
+				if ip != "" {
+					ips = append(ips, ip)
+				}
+			}
+		}
+		for i := len(ips) - 1; i >= 0; i-- {
+			if net.ParseIP(ips[i]) == nil {
+				// Malformed entry, cannot trust anything on the left of it
+				return remote
+			}
+			if !isTrustedProxy(ips[i], trusted) {
+				return ips[i]
+			}
+		}
+		// All addresses are trusted proxies - the leftmost one is the closest to the client
+		if len(ips) > 0 {
+			return ips[0]
+		}
+		return remote
+	}
+	realIP := strings.TrimSpace(r.Header.Get("X-Real-IP"))
+	if net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
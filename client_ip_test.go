@@ -0,0 +1,75 @@
+package devstatscode
+
+import (
+	"net/http"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := lib.ParseTrustedProxies(" 10.0.0.0/8,,127.0.0.1, ::1 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := []string{}
+	for _, n := range nets {
+		got = append(got, n.String())
+	}
+	expected := []string{"10.0.0.0/8", "127.0.0.1/32", "::1/128"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	}
+	for _, bad := range []string{"10.0.0.0/33", "localhost", "1.2.3"} {
+		_, err := lib.ParseTrustedProxies(bad)
+		if err == nil {
+			t.Errorf("expected error for '%s'", bad)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := lib.ParseTrustedProxies("10.0.0.0/8,127.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Test cases
+	var testCases = []struct {
+		remote   string
+		xff      []string
+		realIP   string
+		expected string
+	}{
+		{remote: "1.2.3.4:5678", expected: "1.2.3.4"},
+		{remote: "1.2.3.4:5678", xff: []string{"5.6.7.8"}, realIP: "5.6.7.8", expected: "1.2.3.4"},
+		{remote: "10.1.1.1:80", expected: "10.1.1.1"},
+		{remote: "10.1.1.1:80", xff: []string{"5.6.7.8"}, expected: "5.6.7.8"},
+		{remote: "10.1.1.1:80", xff: []string{"9.9.9.9, 5.6.7.8, 10.2.2.2"}, expected: "5.6.7.8"},
+		{remote: "10.1.1.1:80", xff: []string{"9.9.9.9", "5.6.7.8, 127.0.0.1"}, expected: "5.6.7.8"},
+		{remote: "10.1.1.1:80", xff: []string{"10.3.3.3, 10.2.2.2"}, expected: "10.3.3.3"},
+		{remote: "10.1.1.1:80", xff: []string{"5.6.7.8, garbage"}, expected: "10.1.1.1"},
+		{remote: "10.1.1.1:80", realIP: "5.6.7.8", expected: "5.6.7.8"},
+		{remote: "10.1.1.1:80", realIP: "garbage", expected: "10.1.1.1"},
+		{remote: "[::1]:80", xff: []string{"5.6.7.8"}, expected: "::1"},
+		{remote: "127.0.0.1", xff: []string{"2001:db8::1"}, expected: "2001:db8::1"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		r := &http.Request{RemoteAddr: test.remote, Header: http.Header{}}
+		for _, xff := range test.xff {
+			r.Header.Add("X-Forwarded-For", xff)
+		}
+		if test.realIP != "" {
+			r.Header.Set("X-Real-IP", test.realIP)
+		}
+		got := lib.ClientIP(r, trusted)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %s, got %s, test case: %+v", index+1, test.expected, got, test)
+		}
+	}
+}
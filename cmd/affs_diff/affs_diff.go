@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	affsdiff "github.com/cncf/devstatscode/tools/affs_diff"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	affsdiff.Main()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	lib "github.com/cncf/devstatscode"
+	affsdiff "github.com/cncf/devstatscode/tools/affs_diff"
+	"github.com/cncf/devstatscode/tools/annotations"
+	"github.com/cncf/devstatscode/tools/api"
+	calcmetric "github.com/cncf/devstatscode/tools/calc_metric"
+	"github.com/cncf/devstatscode/tools/columns"
+	"github.com/cncf/devstatscode/tools/devstats"
+	"github.com/cncf/devstatscode/tools/doctor"
+	enrichactors "github.com/cncf/devstatscode/tools/enrich_actors"
+	getrepos "github.com/cncf/devstatscode/tools/get_repos"
+	"github.com/cncf/devstatscode/tools/gha2db"
+	gha2dbsync "github.com/cncf/devstatscode/tools/gha2db_sync"
+	ghabackfillcommitsroles "github.com/cncf/devstatscode/tools/gha_backfill_commits_roles"
+	"github.com/cncf/devstatscode/tools/ghapi2db"
+	hidedata "github.com/cncf/devstatscode/tools/hide_data"
+	importaffs "github.com/cncf/devstatscode/tools/import_affs"
+	lintmetrics "github.com/cncf/devstatscode/tools/lint_metrics"
+	mergedbs "github.com/cncf/devstatscode/tools/merge_dbs"
+	metricscoverage "github.com/cncf/devstatscode/tools/metrics_coverage"
+	pgpartitionmanager "github.com/cncf/devstatscode/tools/pg_partition_manager"
+	reconcilestars "github.com/cncf/devstatscode/tools/reconcile_stars"
+	"github.com/cncf/devstatscode/tools/replacer"
+	"github.com/cncf/devstatscode/tools/runq"
+	"github.com/cncf/devstatscode/tools/splitcrons"
+	"github.com/cncf/devstatscode/tools/sqlitedb"
+	"github.com/cncf/devstatscode/tools/structure"
+	syncissues "github.com/cncf/devstatscode/tools/sync_issues"
+	"github.com/cncf/devstatscode/tools/tags"
+	testmetrics "github.com/cncf/devstatscode/tools/test_metrics"
+	"github.com/cncf/devstatscode/tools/tracker2db"
+	tsexport "github.com/cncf/devstatscode/tools/ts_export"
+	"github.com/cncf/devstatscode/tools/tsplit"
+	unhidedata "github.com/cncf/devstatscode/tools/unhide_data"
+	"github.com/cncf/devstatscode/tools/vars"
+	"github.com/cncf/devstatscode/tools/webhook"
+	websitedata "github.com/cncf/devstatscode/tools/website_data"
+)
+
+// tools - subcommands by name, each of them is also built as a separate binary from cmd/<name>
+var tools = map[string]func(){
+	"affs_diff":                  affsdiff.Main,
+	"annotations":                annotations.Main,
+	"api":                        api.Main,
+	"calc_metric":                calcmetric.Main,
+	"columns":                    columns.Main,
+	"devstats":                   devstats.Main,
+	"doctor":                     doctor.Main,
+	"enrich_actors":              enrichactors.Main,
+	"get_repos":                  getrepos.Main,
+	"gha2db":                     gha2db.Main,
+	"gha2db_sync":                gha2dbsync.Main,
+	"gha_backfill_commits_roles": ghabackfillcommitsroles.Main,
+	"ghapi2db":                   ghapi2db.Main,
+	"hide_data":                  hidedata.Main,
+	"import_affs":                importaffs.Main,
+	"lint_metrics":               lintmetrics.Main,
+	"merge_dbs":                  mergedbs.Main,
+	"metrics_coverage":           metricscoverage.Main,
+	"pg_partition_manager":       pgpartitionmanager.Main,
+	"reconcile_stars":            reconcilestars.Main,
+	"replacer":                   replacer.Main,
+	"runq":                       runq.Main,
+	"splitcrons":                 splitcrons.Main,
+	"sqlitedb":                   sqlitedb.Main,
+	"structure":                  structure.Main,
+	"sync_issues":                syncissues.Main,
+	"tags":                       tags.Main,
+	"test_metrics":               testmetrics.Main,
+	"tracker2db":                 tracker2db.Main,
+	"ts_export":                  tsexport.Main,
+	"tsplit":                     tsplit.Main,
+	"unhide_data":                unhidedata.Main,
+	"vars":                       vars.Main,
+	"webhook":                    webhook.Main,
+	"website_data":               websitedata.Main,
+}
+
+// toolNames - returns sorted subcommand names
+func toolNames() (names []string) {
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// usage - prints how to call subcommands
+func usage() {
+	fmt.Fprintf(
+		os.Stderr,
+		"Usage: %s <tool> [args...] or call via a symlink named after the tool\nTools: %s\n",
+		filepath.Base(os.Args[0]),
+		strings.Join(toolNames(), ", "),
+	)
+}
+
+// Single binary containing all tools, tool is selected by the binary name (symlink, argv[0]) or by the first argument
+// "devstatscode tools" lists tool names one per line, so symlinks can be created: for t in `devstatscode tools`; do ln -s devstatscode $t; done
+// Tools calling other tools (like gha2db_sync) use their names, so symlinks must be in the PATH
+// Exit codes are the same as when tools are called as separate binaries, see lib.ExitCode
+func main() {
+	defer lib.ExitOnPanic()
+	if tool, ok := tools[strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")]; ok {
+		tool()
+		return
+	}
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(lib.ExitConfig)
+	}
+	switch os.Args[1] {
+	case "tools":
+		for _, name := range toolNames() {
+			fmt.Println(name)
+		}
+		return
+	case "help", "-h", "--help":
+		usage()
+		return
+	}
+	tool, ok := tools[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: unknown tool '%s'\n", filepath.Base(os.Args[0]), os.Args[1])
+		usage()
+		os.Exit(lib.ExitConfig)
+	}
+	// Tool sees its name as os.Args[0] and its own arguments after it, like when called as a separate binary
+	os.Args = os.Args[1:]
+	tool()
+}
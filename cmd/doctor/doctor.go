@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	"github.com/cncf/devstatscode/tools/doctor"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	doctor.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	enrichactors "github.com/cncf/devstatscode/tools/enrich_actors"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	enrichactors.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	ghabackfillcommitsroles "github.com/cncf/devstatscode/tools/gha_backfill_commits_roles"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	ghabackfillcommitsroles.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	lintmetrics "github.com/cncf/devstatscode/tools/lint_metrics"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	lintmetrics.Main()
+}
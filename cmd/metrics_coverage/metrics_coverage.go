@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	metricscoverage "github.com/cncf/devstatscode/tools/metrics_coverage"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	metricscoverage.Main()
+}
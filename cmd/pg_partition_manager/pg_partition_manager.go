@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	pgpartitionmanager "github.com/cncf/devstatscode/tools/pg_partition_manager"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	pgpartitionmanager.Main()
+}
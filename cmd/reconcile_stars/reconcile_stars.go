@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	reconcilestars "github.com/cncf/devstatscode/tools/reconcile_stars"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	reconcilestars.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	testmetrics "github.com/cncf/devstatscode/tools/test_metrics"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	testmetrics.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	"github.com/cncf/devstatscode/tools/tracker2db"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	tracker2db.Main()
+}
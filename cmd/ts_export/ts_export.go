@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	tsexport "github.com/cncf/devstatscode/tools/ts_export"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	tsexport.Main()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	lib "github.com/cncf/devstatscode"
+	unhidedata "github.com/cncf/devstatscode/tools/unhide_data"
+)
+
+func main() {
+	defer lib.ExitOnPanic()
+	unhidedata.Main()
+}
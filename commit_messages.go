@@ -0,0 +1,79 @@
+package devstatscode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io/ioutil"
+	"time"
+	"unicode/utf8"
+)
+
+// CompressCommitMessage - returns gzip compressed commit message, as stored in gha_commits_messages
+func CompressCommitMessage(msg string) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	_, err := zw.Write([]byte(msg))
+	FatalOnError(err)
+	FatalOnError(zw.Close())
+	return buf.Bytes()
+}
+
+// DecompressCommitMessage - returns commit message from its gha_commits_messages compressed form
+func DecompressCommitMessage(data []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	msg, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(msg), zr.Close()
+}
+
+// CommitMessageTruncated - checks if a given commit message is longer than gha_commits.message limit (GHA2DB_COMMIT_MSG_LIMIT, GHA2DB_TRUNC_LIMITS)
+func CommitMessageTruncated(ctx *Ctx, msg string) bool {
+	limit := FieldLimit(ctx, "gha_commits.message")
+	return limit > 0 && len(CleanUTF8(msg)) > limit
+}
+
+// StoreCommitMessage - saves full commit message compressed (GHA2DB_COMMIT_MSG_STORE), the same commit can be in many events and is only saved once
+func StoreCommitMessage(con *sql.Tx, ctx *Ctx, sha, msg, repoName string, createdAt time.Time) {
+	msg = CleanUTF8(msg)
+	q, args := NewQB("gha_commits_messages").
+		Set("sha", sha).
+		Set("message", CompressCommitMessage(msg)).
+		Set("length", len(msg)).
+		Set("dup_repo_name", repoName).
+		Set("dup_created_at", createdAt).
+		InsertIgnore()
+	ExecSQLTxWithErr(con, ctx, q, args...)
+}
+
+// CommitMessage - returns full message of a given commit: from gha_commits_messages when it was stored there, gha_commits.message otherwise
+// full is false when returned message can be truncated (it is not shorter than gha_commits.message limit), empty message is returned for unknown commits
+func CommitMessage(con *sql.DB, ctx *Ctx, sha string) (msg string, full bool, err error) {
+	if TableExists(con, ctx, "gha_commits_messages") {
+		var data []byte
+		err = QueryRowSQL(con, ctx, "select message from gha_commits_messages where sha = "+NValue(1), sha).Scan(&data)
+		if err == nil {
+			msg, err = DecompressCommitMessage(data)
+			return msg, err == nil, err
+		}
+		if err != sql.ErrNoRows {
+			return
+		}
+	}
+	err = QueryRowSQL(con, ctx, "select message from gha_commits where sha = "+NValue(1)+" limit 1", sha).Scan(&msg)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return
+	}
+	// Truncation keeps whole UTF-8 characters, so truncated message can be up to 3 bytes shorter than the limit
+	limit := FieldLimit(ctx, "gha_commits.message")
+	full = limit <= 0 || len(msg)+utf8.UTFMax <= limit
+	return
+}
@@ -0,0 +1,56 @@
+package devstatscode
+
+import (
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestCompressCommitMessage(t *testing.T) {
+	// Test cases
+	var testCases = []string{
+		"",
+		"Fix typo",
+		"Update vendored changelog\n\n" + strings.Repeat("* bump dependency\n", 10000),
+		"Zażółć gęślą jaźń",
+	}
+	// Execute test cases
+	for index, msg := range testCases {
+		data := lib.CompressCommitMessage(msg)
+		got, err := lib.DecompressCommitMessage(data)
+		if err != nil {
+			t.Errorf("test number %d, unexpected error: %v", index+1, err)
+		}
+		if got != msg {
+			t.Errorf("test number %d, expected %d bytes message, got %d bytes", index+1, len(msg), len(got))
+		}
+	}
+	if _, err := lib.DecompressCommitMessage([]byte("not compressed")); err == nil {
+		t.Errorf("expected error decompressing not compressed data")
+	}
+}
+
+func TestCommitMessageTruncated(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		limit    int
+		msg      string
+		expected bool
+	}{
+		{limit: 0, msg: strings.Repeat("x", 0xffff)},
+		{limit: 0, msg: strings.Repeat("x", 0x10000), expected: true},
+		{limit: 10, msg: "0123456789"},
+		{limit: 10, msg: "0123456789a", expected: true},
+		{limit: 10, msg: "01234567\x00\x00"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		var ctx lib.Ctx
+		ctx.CommitMsgLimit = test.limit
+		got := lib.CommitMessageTruncated(&ctx, test.msg)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %v, got %v", index+1, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// useActorsCache - use actorsCache or not
+	useActorsCache = true
+	// actorsCacheMtx - cache access mutex
+	actorsCacheMtx = &sync.RWMutex{}
+	// actorsCache - cache found actors (login, ID) pairs for (email, name) pairs
+	actorsCache = make(map[[2]string][2]string)
+	// actorsMissCache - (email, name) pairs not found, with time of the lookup, entries expire after ctx.ActorsMissTTL
+	actorsMissCache = make(map[[2]string]time.Time)
+	// actorsChangedChecked - when actorsChangedMetric was checked last time, actorsChangedDt - its value then
+	actorsChangedChecked time.Time
+	actorsChangedDt      time.Time
+	// Lookups statistics: cached actors, cached misses and lookups that queried DB
+	actorsCacheHits     int64
+	actorsMissCacheHits int64
+	actorsCacheMisses   int64
+)
+
+const (
+	// actorsChangedMetric - gha_computed metric updated by tools that add actors emails/names, cached misses are dropped when it changes
+	actorsChangedMetric = "actors_changed"
+	// actorsChangedCheckInterval - how often lookups check actorsChangedMetric
+	actorsChangedCheckInterval = time.Minute
+)
+
+// ActorsCacheSize - returns number of (email, name) pairs cached by actor lookups
+func ActorsCacheSize() int {
+	actorsCacheMtx.RLock()
+	defer actorsCacheMtx.RUnlock()
+	return len(actorsCache)
+}
+
+// ResetActorsCache - drops all cached actor lookups, long running tools can use it to bound memory usage
+func ResetActorsCache() {
+	actorsCacheMtx.Lock()
+	actorsCache = make(map[[2]string][2]string)
+	actorsMissCache = make(map[[2]string]time.Time)
+	actorsCacheMtx.Unlock()
+}
+
+// ActorsCacheStats - returns number of lookups answered from cache, answered from cached misses and querying DB
+func ActorsCacheStats() (hits, missHits, misses int64) {
+	return atomic.LoadInt64(&actorsCacheHits), atomic.LoadInt64(&actorsMissCacheHits), atomic.LoadInt64(&actorsCacheMisses)
+}
+
+// SetActorsChanged - marks that actors emails/names were added, so other tools drop their cached actor lookup misses
+func SetActorsChanged(con *sql.DB, ctx *Ctx) {
+	ExecSQLWithErr(con, ctx, fmt.Sprintf("delete from gha_computed where metric = %s", NValue(1)), actorsChangedMetric)
+	ExecSQLWithErr(con, ctx, fmt.Sprintf("insert into gha_computed(metric, dt) values(%s, now())", NValue(1)), actorsChangedMetric)
+}
+
+// checkActorsChanged - drops cached misses when actorsChangedMetric was updated since the last check
+// queryRow is DB or TX QueryRow function, check is done at most once per actorsChangedCheckInterval
+func checkActorsChanged(queryRow func(string, ...interface{}) *sql.Row) {
+	now := time.Now()
+	actorsCacheMtx.Lock()
+	if now.Sub(actorsChangedChecked) < actorsChangedCheckInterval {
+		actorsCacheMtx.Unlock()
+		return
+	}
+	actorsChangedChecked = now
+	actorsCacheMtx.Unlock()
+	var dt *time.Time
+	FatalOnError(queryRow(fmt.Sprintf("select max(dt) from gha_computed where metric = %s", NValue(1)), actorsChangedMetric).Scan(&dt))
+	if dt == nil {
+		return
+	}
+	actorsCacheMtx.Lock()
+	if !dt.Equal(actorsChangedDt) {
+		actorsChangedDt = *dt
+		actorsMissCache = make(map[[2]string]time.Time)
+	}
+	actorsCacheMtx.Unlock()
+}
+
+// getCachedActor - returns cached lookup result, found is set for cached actors and not expired cached misses
+func getCachedActor(ctx *Ctx, key [2]string, queryRow func(string, ...interface{}) *sql.Row) (id int, login string, found bool) {
+	if ctx.ActorsMissTTL > 0 {
+		checkActorsChanged(queryRow)
+	}
+	actorsCacheMtx.RLock()
+	data, ok := actorsCache[key]
+	missDt, miss := actorsMissCache[key]
+	actorsCacheMtx.RUnlock()
+	if ok {
+		atomic.AddInt64(&actorsCacheHits, 1)
+		id, _ = strconv.Atoi(data[0])
+		return id, data[1], true
+	}
+	if miss && time.Since(missDt) < ctx.ActorsMissTTL {
+		atomic.AddInt64(&actorsMissCacheHits, 1)
+		return 0, "", true
+	}
+	atomic.AddInt64(&actorsCacheMisses, 1)
+	return 0, "", false
+}
+
+// cacheActorMiss - remembers that actor was not found, expired entries are dropped when cache is full (all entries if they are not expired)
+func cacheActorMiss(ctx *Ctx, key [2]string) {
+	if ctx.ActorsMissTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	actorsCacheMtx.Lock()
+	defer actorsCacheMtx.Unlock()
+	if len(actorsMissCache) >= ctx.ActorsMissCacheMax {
+		for k, dt := range actorsMissCache {
+			if now.Sub(dt) >= ctx.ActorsMissTTL {
+				delete(actorsMissCache, k)
+			}
+		}
+		if len(actorsMissCache) >= ctx.ActorsMissCacheMax {
+			actorsMissCache = make(map[[2]string]time.Time)
+		}
+	}
+	actorsMissCache[key] = now
+}
+
+// LookupActorNameEmail - search for given actor using his/her name and email
+// Returns 0 and empty login if not found
+// Uses DB object, not TX
+func LookupActorNameEmail(con *sql.DB, ctx *Ctx, name, email string, maybeHide func(string) string) (int, string) {
+	if useActorsCache {
+		if id, login, ok := getCachedActor(ctx, [2]string{email, name}, con.QueryRow); ok {
+			return id, login
+		}
+	}
+	// By email
+	hemail := maybeHide(email)
+	erows := QuerySQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select a.id, a.login from gha_actors a, gha_actors_emails ae where a.id = ae.actor_id and ae.email=%s order by a.id desc limit 1", NValue(1)),
+		hemail,
+	)
+	defer func() { FatalOnError(erows.Close()) }()
+	eaid := 0
+	elogin := ""
+	for erows.Next() {
+		FatalOnError(erows.Scan(&eaid, &elogin))
+	}
+	FatalOnError(erows.Err())
+	if eaid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(eaid), elogin}
+			actorsCacheMtx.Unlock()
+		}
+		return eaid, elogin
+	}
+
+	// By name from actors names table
+	hname := maybeHide(name)
+	nrows := QuerySQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select a.id, a.login from gha_actors a, gha_actors_names an where a.id = an.actor_id and an.name=%s order by a.id desc limit 1", NValue(1)),
+		hname,
+	)
+	defer func() { FatalOnError(nrows.Close()) }()
+	naid := 0
+	nlogin := ""
+	for nrows.Next() {
+		FatalOnError(nrows.Scan(&naid, &nlogin))
+	}
+	FatalOnError(nrows.Err())
+	if naid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(naid), nlogin}
+			actorsCacheMtx.Unlock()
+		}
+		return naid, nlogin
+	}
+
+	// By name from actors table
+	n2rows := QuerySQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select id, login from gha_actors where name=%s order by id desc limit 1", NValue(1)),
+		hname,
+	)
+	defer func() { FatalOnError(n2rows.Close()) }()
+	n2aid := 0
+	n2login := ""
+	for n2rows.Next() {
+		FatalOnError(n2rows.Scan(&n2aid, &n2login))
+	}
+	FatalOnError(n2rows.Err())
+	if n2aid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(n2aid), n2login}
+			actorsCacheMtx.Unlock()
+		}
+		return n2aid, n2login
+	}
+	if useActorsCache {
+		cacheActorMiss(ctx, [2]string{email, name})
+	}
+	return 0, ""
+}
+
+// LookupActorNameEmailTx - search for given actor using his/her name and email
+// Returns 0 and empty login if not found
+// Uses TX object not DB
+func LookupActorNameEmailTx(con *sql.Tx, ctx *Ctx, name, email string, maybeHide func(string) string) (int, string) {
+	if useActorsCache {
+		if id, login, ok := getCachedActor(ctx, [2]string{email, name}, con.QueryRow); ok {
+			return id, login
+		}
+	}
+	// By email
+	hemail := maybeHide(email)
+	erows := QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select a.id, a.login from gha_actors a, gha_actors_emails ae where a.id = ae.actor_id and ae.email=%s order by a.id desc limit 1", NValue(1)),
+		hemail,
+	)
+	defer func() { FatalOnError(erows.Close()) }()
+	eaid := 0
+	elogin := ""
+	for erows.Next() {
+		FatalOnError(erows.Scan(&eaid, &elogin))
+	}
+	FatalOnError(erows.Err())
+	if eaid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(eaid), elogin}
+			actorsCacheMtx.Unlock()
+		}
+		return eaid, elogin
+	}
+
+	// By name from actors names table
+	hname := maybeHide(name)
+	nrows := QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select a.id, a.login from gha_actors a, gha_actors_names an where a.id = an.actor_id and an.name=%s order by a.id desc limit 1", NValue(1)),
+		hname,
+	)
+	defer func() { FatalOnError(nrows.Close()) }()
+	naid := 0
+	nlogin := ""
+	for nrows.Next() {
+		FatalOnError(nrows.Scan(&naid, &nlogin))
+	}
+	FatalOnError(nrows.Err())
+	if naid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(naid), nlogin}
+			actorsCacheMtx.Unlock()
+		}
+		return naid, nlogin
+	}
+
+	// By name from actors table
+	n2rows := QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select id, login from gha_actors where name=%s order by id desc limit 1", NValue(1)),
+		hname,
+	)
+	defer func() { FatalOnError(n2rows.Close()) }()
+	n2aid := 0
+	n2login := ""
+	for n2rows.Next() {
+		FatalOnError(n2rows.Scan(&n2aid, &n2login))
+	}
+	FatalOnError(n2rows.Err())
+	if n2aid != 0 {
+		if useActorsCache {
+			actorsCacheMtx.Lock()
+			actorsCache[[2]string{email, name}] = [2]string{strconv.Itoa(n2aid), n2login}
+			actorsCacheMtx.Unlock()
+		}
+		return n2aid, n2login
+	}
+	if useActorsCache {
+		cacheActorMiss(ctx, [2]string{email, name})
+	}
+	return 0, ""
+}
@@ -69,6 +69,9 @@ const All string = "all"
 // ALL - common constant string
 const ALL string = "All"
 
+// Independent - common constant string
+const Independent string = "Independent"
+
 // Kubernetes - common constant string
 const Kubernetes string = "kubernetes"
 
@@ -147,6 +150,99 @@ const Companies string = "Companies"
 // SiteStats - common constant string
 const SiteStats string = "SiteStats"
 
+// CountriesStats - common constant string
+const CountriesStats string = "CountriesStats"
+
+// DataQuality - common constant string
+const DataQuality string = "DataQuality"
+
+// Batch - common constant string
+const Batch string = "Batch"
+
+// CompanyProfile - common constant string
+const CompanyProfile string = "CompanyProfile"
+
+// CIStats - common constant string
+const CIStats string = "CIStats"
+
+// Certificate - common constant string
+const Certificate string = "Certificate"
+
+// VerifyCertificate - common constant string
+const VerifyCertificate string = "VerifyCertificate"
+
+// TSDBShadowSuffix - suffix of series tables computed in blue/green mode (swapped with current tables when all metrics are computed)
+const TSDBShadowSuffix string = "_new"
+
+// TSDBShadowComment - comment set on shadow series tables, only tables with this comment are swapped or dropped
+const TSDBShadowComment string = "devstats blue/green shadow table"
+
+// PRSizeDistribution - common constant string
+const PRSizeDistribution string = "PRSizeDistribution"
+
+// RenamedOrDeletedRepos - common constant string
+const RenamedOrDeletedRepos string = "RenamedOrDeletedRepos"
+
+// Export - common constant string
+const Export string = "Export"
+
+// IngestStats - common constant string
+const IngestStats string = "IngestStats"
+
+// Traffic - common constant string
+const Traffic string = "Traffic"
+
+// DevActDistribution - common constant string
+const DevActDistribution string = "DevActDistribution"
+
+// Velocity - common constant string
+const Velocity string = "Velocity"
+
+// LabelLifecycle - common constant string
+const LabelLifecycle string = "LabelLifecycle"
+
+// ReleaseStats - common constant string
+const ReleaseStats string = "ReleaseStats"
+
+// SyncStatus - common constant string
+const SyncStatus string = "SyncStatus"
+
+// ForkActivity - common constant string
+const ForkActivity string = "ForkActivity"
+
+// ProjectFeatures - common constant string
+const ProjectFeatures string = "ProjectFeatures"
+
+// MentionGraph - common constant string
+const MentionGraph string = "MentionGraph"
+
+// RepoStats - common constant string
+const RepoStats string = "RepoStats"
+
+// BusFactor - common constant string
+const BusFactor string = "BusFactor"
+
+// LinkedWork - common constant string
+const LinkedWork string = "LinkedWork"
+
+// TagCloud - common constant string
+const TagCloud string = "TagCloud"
+
+// MilestoneProgress - common constant string
+const MilestoneProgress string = "MilestoneProgress"
+
+// SeriesQuery - common constant string
+const SeriesQuery string = "SeriesQuery"
+
+// ContributorRetention - common constant string
+const ContributorRetention string = "ContributorRetention"
+
+// DevActDiff - common constant string
+const DevActDiff string = "DevActDiff"
+
+// BgStatus - common constant string
+const BgStatus string = "BgStatus"
+
 // Day - common constant string
 const Day string = "day"
 
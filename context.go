@@ -1,7 +1,9 @@
 package devstatscode
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
 	"strconv"
@@ -152,8 +154,79 @@ type Ctx struct {
 	MaxHistograms            int                          // From GHA2DB_MAX_HIST: maximum histogram concurrency, default: 0 - means unlimited
 	MaxRunDuration           map[string][2]int            // From GHA2DB_MAX_RUN_DURATION, how log given programs can run and exist status after timeout, for example "tags:1h:0,calc_metric:12h:1"
 	RandComputeAtThisDate    bool                         // Use rand to decide if a given date period must be calculated at this date or not.
-	RefreshCommitRoles       bool                         // From GHA2DB_REFRESH_COMMIT_ROLES - will process all commiths in DB and for every single one of them it will generate gha_commits_roles entries.
+	RefreshCommitRoles       bool                         // From GHA2DB_REFRESH_COMMIT_ROLES - will process all commiths in DB and for every single one of them it will generate gha_commits_roles entries (runs resumable gha_backfill_commits_roles tool).
 	AllowRandTagsColsCompute bool                         // If set, then tags and columns will only be computed at random 0-5 hour, otherwise always when hour<6.
+	Diff                     bool                         // From GHA2DB_DIFF, gha2db tool, dry-run mode: compare rows that would be written with rows already in DB and print diff report, nothing is written, default false
+	LocalJSONsDir            string                       // From GHA2DB_LOCAL_JSONS_DIR, gha2db tool, read GHA hours from local <dir>/YYYY-MM-DD-H.json.gz (or .json.zst, .json) files instead of fetching them from data.gharchive.org, default "" (use HTTP)
+	SkipDataQuality          bool                         // From GHA2DB_SKIP_DATA_QUALITY, gha2db_sync tool, skip computing data quality indicators (gha_data_quality table) at the end of sync, default false
+	Shards                   []string                     // From GHA2DB_SHARDS, gha2db, structure, comma separated list of shard databases, events are routed to them by org hash, default empty (no sharding)
+	GeocoderURL              string                       // From GHA2DB_GEOCODER_URL, enrich_actors, geocoding service URL with {{location}} placeholder returning {"country_code": "PL", "tz": "Europe/Warsaw"}, default empty (only built-in countries names matching)
+	APIWorkflowRuns          bool                         // From GHA2DB_GHAPIWORKFLOWRUNS, ghapi2db tool, if set then tool also syncs GitHub Actions workflow runs of recent repos (opt-in), default false
+	TrustedProxies           []*net.IPNet                 // From GHA2DB_TRUSTED_PROXIES, api tool, comma separated list of proxies CIDRs or IPs (for example "10.0.0.0/8,127.0.0.1"), X-Forwarded-For and X-Real-IP are only used for requests from them, default empty
+	TruncLimits              map[string]int               // From GHA2DB_TRUNC_LIMITS, gha2db tool, comma separated list of "table.column=limit" fields length limits overrides (see SchemaLimits), varchar limits cannot be raised above their DDL sizes, default empty
+	TruncAudit               bool                         // From GHA2DB_TRUNC_AUDIT, gha2db tool, store original values of truncated fields in gha_truncated table (personal data fields are never stored), default false
+	APICertSecret            string                       // From GHA2DB_API_CERT_SECRET, api tool, HMAC-SHA256 key used to sign Certificate API responses and verify them in VerifyCertificate API, both are disabled when not set, default empty
+	HTTPCABundle             string                       // From GHA2DB_HTTP_CA_BUNDLE, lib.HTTPClient - path to PEM file with CA certificates trusted in addition to system ones, default empty
+	HTTPClientRetries        int                          // From GHA2DB_HTTP_CLIENT_RETRIES, lib.HTTPClient - number of retries of idempotent requests failed with network errors, 429 or 5xx responses, default 2
+	HTTPClientBackoff        time.Duration                // From GHA2DB_HTTP_CLIENT_BACKOFF, lib.HTTPClient - wait before the first retry, doubled on each next one (Retry-After header takes precedence), default "1s"
+	TSDBSuffix               string                       // From GHA2DB_TSDB_SUFFIX, calc_metric tool, suffix added to series tables names, set by sync tool in blue/green mode, default empty
+	BlueGreen                bool                         // From GHA2DB_BLUE_GREEN, sync tool, when used with GHA2DB_RESETTSDB computes all series into shadow tables and swaps them with current ones at the end, default false
+	BlueGreenMinRatio        float64                      // From GHA2DB_BLUE_GREEN_MIN_RATIO, sync tool, minimum shadow/current table rows ratio required to swap tables in blue/green mode, default 0.5
+	SyncResume               bool                         // From GHA2DB_SYNC_RESUME, sync tool, resume the last unfinished project sync run skipping its completed stages, default false
+	RepoNotFoundLimit        int                          // From GHA2DB_REPO_NOT_FOUND_LIMIT, ghapi2db tool, number of consecutive GitHub API 404s after which repository is marked as not found and skipped, 0 disables, default 3
+	APIAdminToken            string                       // From GHA2DB_API_ADMIN_TOKEN, api tool, bearer token required by admin APIs (Export), admin APIs are disabled when not set, default empty
+	APITrafficOrgs           map[string]bool              // From GHA2DB_GHAPITRAFFIC, ghapi2db tool, comma separated list of orgs (token must have push access to their repos) to sync daily repository clones and views for (opt-in), default ""
+	ConfigFile               string                       // From GHA2DB_CONFIG, all tools, YAML (or JSON) file with env variables names as keys, values are used for variables not set in environment, default ""
+	FKChecks                 bool                         // From GHA2DB_FK_CHECKS, structure tool, create foreign keys and check constraints (test/QA databases only, they slow down writes), default false
+	APIReconcileActors       int                          // From GHA2DB_GHAPI_RECONCILE_ACTORS, ghapi2db tool, maximum number of logins with synthetic (hashed or zero) actor IDs to resolve via GitHub users API per run, default 0 (disabled)
+	WebsiteDataFeeds         map[string]bool              // From GHA2DB_WEBSITEDATA_FEEDS, website_data tool, comma separated list of per-project feeds to generate: stats, contributors, companies, annotations, default "stats"
+	WebsiteDataTop           int                          // From GHA2DB_WEBSITEDATA_TOP, website_data tool, number of top contributors and companies in feeds, default 10
+	WebsiteDataUpload        string                       // From GHA2DB_WEBSITEDATA_UPLOAD, website_data tool, command to run after all feeds are generated, {{dir}} is replaced with GHA2DB_JSONS_DIR, for example "aws s3 sync {{dir}} s3://bucket/jsons/", default "" (none)
+	MemBudgetMB              int                          // From GHA2DB_MEM_BUDGET_MB, gha2db tool, heap memory budget in MB, number of concurrent GHA hours processing threads is reduced when heap gets close to it and increased back when it drops, default 0 (no budget, GC forced every 24 hours processed)
+	ProgressJSON             bool                         // From GHA2DB_PROGRESS_JSON, gha2db, ghapi2db, gha_backfill_commits_roles tools, output progress reports (done/total, rate, ETA) as JSON lines instead of text, default false
+	ActorsMissTTL            time.Duration                // From GHA2DB_ACTORS_MISS_TTL, gha2db, gha_backfill_commits_roles tools, how long actor lookups by name/email that found nothing are cached, "0" disables, default "1h"
+	ActorsMissCacheMax       int                          // From GHA2DB_ACTORS_MISS_CACHE_MAX, gha2db, gha_backfill_commits_roles tools, maximum number of cached actor lookup misses, default 100000
+	Features                 map[string]bool              // From GHA2DB_FEATURES, all tools, comma separated list of project features, "name" enables and "-name" disables a feature, devstats and sync tools set it from projects.yaml 'features' map, default ""
+	APIForks                 int                          // From GHA2DB_GHAPIFORKS, ghapi2db tool, number of most recently pushed active forks of each recent repo to compare with upstream (fork activity, opt-in), default 0 (disabled)
+	Mentions                 bool                         // From GHA2DB_MENTIONS, gha2db tool, store @mentions and issue/PR cross-references from bodies and comments in gha_mentions and gha_issue_refs tables (opt-in, also "mentions" feature), default false
+	APIWarmTopK              int                          // From GHA2DB_API_WARM_TOPK, api tool, number of the most requested DevActCnt/CompaniesTable payloads whose responses are cached and recomputed after each project sync, 0 disables, default 0
+	APIWarmInterval          time.Duration                // From GHA2DB_API_WARM_INTERVAL, api tool, how often API checks for finished project syncs to refresh warmed responses, default "1m"
+	Partitioned              bool                         // From GHA2DB_PARTITIONED, structure tool, create gha_events, gha_payloads and gha_comments as monthly partitioned tables (cannot be used with GHA2DB_FK_CHECKS), default false
+	PartitionsAhead          int                          // From GHA2DB_PARTITIONS_AHEAD, structure and pg_partition_manager tools, number of future monthly partitions to create, default 3
+	PartitionsDetachMonths   int                          // From GHA2DB_PARTITIONS_DETACH_MONTHS, pg_partition_manager tool, detach partitions older than this number of months and move them to "archive" schema, 0 disables, default 0
+	SchemaCheck              bool                         // From GHA2DB_SKIP_SCHEMA_CHECK, gha2db tool, verify at startup that tables written by gha2db have exactly the columns from schema manifest and fail with a diff otherwise, default true, use GHA2DB_SKIP_SCHEMA_CHECK=1 to disable
+	APILinkedTimeline        bool                         // From GHA2DB_GHAPILINKEDTIMELINE, ghapi2db tool, also read timelines of issues closed in the recent range to find closing PRs not synced from PR bodies (for example from other repositories), one API call per closed issue (opt-in), default false
+	APIMaxBody               int                          // From GHA2DB_API_MAX_BODY, api tool, maximum API request body size in bytes (larger requests get 413), default 1048576
+	APIMaxDepth              int                          // From GHA2DB_API_MAX_DEPTH, api tool, maximum API request JSON nesting depth (top level object is 1, deeper requests get 413), default 8
+	APIMaxArray              int                          // From GHA2DB_API_MAX_ARRAY, api tool, maximum length of any array in API request JSON (longer arrays get 413), default 1000
+	EventTypesYaml           string                       // From GHA2DB_EVENT_TYPES_YAML, ghapi2db tool, YAML file with "allow" and "deny" lists of issue event types to sync (see DefaultIssueEventTypes), default "" (built-in allow list)
+	APIEventTypes            []string                     // From GHA2DB_GHAPIEVENTTYPES, ghapi2db tool, comma separated list of issue event types to sync, overrides YAML "allow" list, "*" means all types that are not denied, default empty
+	APISkipEventTypes        []string                     // From GHA2DB_GHAPISKIPEVENTTYPES, ghapi2db tool, comma separated list of issue event types to skip, overrides YAML "deny" list, default empty
+	DedupWindow              int                          // From GHA2DB_DEDUP_WINDOW, gha2db tool, number of adjacent GHA hours (before and after) checked for duplicated event IDs using rolling bloom filters, duplicates are counted in gha_parsed_stats, 0 disables, default 1
+	SkewFix                  bool                         // From GHA2DB_SKEW_FIX, gha2db tool, move created_at of events outside of their GHA hour into that hour (such events are always counted in gha_parsed_stats), default false
+	OTelEndpoint             string                       // From GHA2DB_OTEL_ENDPOINT, api, gha2db_sync, calc_metric tools, OpenTelemetry OTLP/HTTP collector URL (for example "http://otel-collector:4318"), spans of requests, SQL statements and executed tools are exported to it, default "" (tracing disabled)
+	OTelSample               float64                      // From GHA2DB_OTEL_SAMPLE, api, gha2db_sync, calc_metric tools, ratio of sampled traces (0-1, child spans follow their parent), default 1
+	ExportFormat             string                       // From GHA2DB_EXPORT_FORMAT, gha2db tool, also write events matching project filters to hourly files in external schema: "bigquery" (newline delimited JSON in GitHub BigQuery public dataset schema) or "avro" (the same schema in Avro container files), default "" (no export)
+	ExportDir                string                       // From GHA2DB_EXPORT_DIR, gha2db tool, directory where hourly export files are written, default "export/"
+	CommitsGapAttempts       int                          // From GHA2DB_COMMITS_GAP_ATTEMPTS, ghapi2db tool, number of targeted re-syncs of commits with missing author/committer enrichment (empty author_email or no author_id) in the synced window, 0 disables gaps detection, default 3
+	CommitsGapMax            int                          // From GHA2DB_COMMITS_GAP_MAX, ghapi2db tool, maximum number of commits re-synced by gaps detection in a single run (one API call each), default 1000
+	ReceiverPort             string                       // From GHA2DB_RECEIVER_PORT, gha2db tool in "receive" mode, port of GitHub webhooks receiver (listens on GHA2DB_WHHOST), default ":1983"
+	ReceiverRoot             string                       // From GHA2DB_RECEIVER_ROOT, gha2db tool in "receive" mode, path of GitHub webhooks receiver, must match org webhooks payload URL, default "/github"
+	ReceiverSecret           string                       // From GHA2DB_RECEIVER_SECRET, gha2db tool in "receive" mode, org webhooks secret used to verify X-Hub-Signature-256, required in "receive" mode
+	CommitMsgLimit           int                          // From GHA2DB_COMMIT_MSG_LIMIT, gha2db tool, maximum length (in bytes) of commit messages stored in gha_commits.message (usually set per project in projects.yaml env), overrides GHA2DB_TRUNC_LIMITS gha_commits.message, default 0 (use GHA2DB_TRUNC_LIMITS or 65535)
+	CommitMsgStore           bool                         // From GHA2DB_COMMIT_MSG_STORE, gha2db tool, store full commit messages longer than gha_commits.message limit gzip compressed in gha_commits_messages table, default false
+	APIMilestones            bool                         // From GHA2DB_GHAPIMILESTONES, ghapi2db tool, if set then tool also snapshots open/closed issues counts of active milestones of recent repos into gha_milestone_progress (opt-in), default false
+	SQLRedact                []string                     // From GHA2DB_SQL_REDACT, all tools, comma separated column name words (like "email,login") whose values are redacted in SQL logs (GHA2DB_QOUT and failed queries), "1" means "email,name,login", default "" (no redaction)
+	GitHubAppID              int64                        // From GHA2DB_GITHUB_APP_ID, all tools using GitHub API, GitHub App ID, when set the App installations are used instead of GHA2DB_GITHUB_OAUTH tokens, default 0 (use tokens)
+	GitHubAppInstallations   []int64                      // From GHA2DB_GITHUB_APP_INSTALLATION_ID, all tools using GitHub API, comma separated GitHub App installation IDs (one API client each), required when GHA2DB_GITHUB_APP_ID is set
+	GitHubAppKey             string                       // From GHA2DB_GITHUB_APP_KEY, all tools using GitHub API, path to GitHub App private key PEM file, required when GHA2DB_GITHUB_APP_ID is set
+	SummaryFile              string                       // From GHA2DB_SUMMARY_FILE, gha2db tool, path where machine readable JSON summary of each run is written (hours, events, errors, duration, memory peak), default "" (no summary)
+	GHAPIRawDir              string                       // From GHA2DB_GHAPI_RAW_DIR, ghapi2db tool, directory where fetched commits, issue events and PRs are appended to gzip compressed JSONL files partitioned by date, default "" (no snapshots)
+	APIMaxBg                 int                          // From GHA2DB_API_MAX_BG, api tool, maximum number of background (BG=1) calc_metric calculations running at the same time, default 3
+	APIMaxBgQueue            int                          // From GHA2DB_API_MAX_BG_QUEUE, api tool, maximum number of background calculations waiting for a free slot (more are rejected), 0 rejects when all slots are busy, default 100
+	ExclusionsYaml           string                       // From GHA2DB_EXCLUSIONS_YAML, gha2db tool, YAML file with "orgs" (repo owner or org/repo glob patterns) and "actors" (login regexps) whose events are skipped at ingest, default "" (no exclusions)
+	Exclusions               *Exclusions                  // Not from env, loaded from GHA2DB_EXCLUSIONS_YAML by gha2db (see LoadExclusions), nil means no exclusions
+	Trace                    context.Context              // Not from env, trace context of spans started by this context (for example API request span), nil means new trace
 }
 
 // SetCPUs - set CPUs
@@ -182,6 +255,8 @@ func (ctx *Ctx) Init() {
 		UpdateEnv(false)
 		go EnvSyncer()
 	})
+	// Optional config file, env variables take precedence
+	FatalNoLog(LoadConfigFile())
 	ctx.ExecFatal = true
 	ctx.ExecQuiet = false
 	ctx.ExecOutput = false
@@ -264,6 +339,19 @@ func (ctx *Ctx) Init() {
 	ctx.QOut = os.Getenv("GHA2DB_QOUT") != ""
 	ctx.CtxOut = os.Getenv("GHA2DB_CTXOUT") != ""
 
+	// SQL logs redaction
+	redact := os.Getenv("GHA2DB_SQL_REDACT")
+	if redact == "1" {
+		ctx.SQLRedact = SQLRedactDefault
+	} else if redact != "" {
+		for _, word := range strings.Split(redact, ",") {
+			word = strings.ToLower(strings.TrimSpace(word))
+			if word != "" {
+				ctx.SQLRedact = append(ctx.SQLRedact, word)
+			}
+		}
+	}
+
 	// Threading
 	ctx.SetCPUs()
 
@@ -340,6 +428,7 @@ func (ctx *Ctx) Init() {
 	ctx.ForceAPILicenses = os.Getenv("GHA2DB_GHAPIFORCELICENSES") != ""
 	ctx.SkipAPILangs = os.Getenv("GHA2DB_GHAPISKIPLANGS") != ""
 	ctx.ForceAPILangs = os.Getenv("GHA2DB_GHAPIFORCELANGS") != ""
+	ctx.APIWorkflowRuns = os.Getenv("GHA2DB_GHAPIWORKFLOWRUNS") != ""
 	ctx.GHAPIErrorIsFatal = os.Getenv("GHA2DB_GHAPI_ERROR_FATAL") != ""
 	ctx.AutoFetchCommits = os.Getenv("GHA2DB_NO_AUTOFETCHCOMMITS") == ""
 
@@ -448,6 +537,26 @@ func (ctx *Ctx) Init() {
 		}
 	}
 
+	// GitHub App authentication
+	if os.Getenv("GHA2DB_GITHUB_APP_ID") != "" {
+		appID, err := strconv.ParseInt(os.Getenv("GHA2DB_GITHUB_APP_ID"), 10, 64)
+		FatalNoLog(err)
+		ctx.GitHubAppID = appID
+		for _, sID := range strings.Split(os.Getenv("GHA2DB_GITHUB_APP_INSTALLATION_ID"), ",") {
+			sID = strings.TrimSpace(sID)
+			if sID == "" {
+				continue
+			}
+			installationID, err := strconv.ParseInt(sID, 10, 64)
+			FatalNoLog(err)
+			ctx.GitHubAppInstallations = append(ctx.GitHubAppInstallations, installationID)
+		}
+		ctx.GitHubAppKey = os.Getenv("GHA2DB_GITHUB_APP_KEY")
+		if ctx.GitHubAppID <= 0 || len(ctx.GitHubAppInstallations) == 0 || ctx.GitHubAppKey == "" {
+			FatalNoLog(fmt.Errorf("GHA2DB_GITHUB_APP_ID requires positive app ID, GHA2DB_GITHUB_APP_INSTALLATION_ID and GHA2DB_GITHUB_APP_KEY"))
+		}
+	}
+
 	// Max DB logs age
 	ctx.ClearDBPeriod = os.Getenv("GHA2DB_MAXLOGAGE")
 	if ctx.ClearDBPeriod == "" {
@@ -856,6 +965,376 @@ func (ctx *Ctx) Init() {
 		}
 	}
 
+	// Diff mode, it compares parsed rows with the database, so it needs database access
+	ctx.Diff = os.Getenv("GHA2DB_DIFF") != ""
+	if ctx.Diff && (!ctx.DBOut || ctx.SkipPDB) {
+		FatalfWithCode(ExitConfig, "GHA2DB_DIFF cannot be used with GHA2DB_NODB or GHA2DB_SKIPPDB")
+	}
+
+	// Local GHA JSONs directory (for example downloaded via torrents)
+	ctx.LocalJSONsDir = os.Getenv("GHA2DB_LOCAL_JSONS_DIR")
+
+	// Data quality
+	ctx.SkipDataQuality = os.Getenv("GHA2DB_SKIP_DATA_QUALITY") != ""
+
+	// Sharded databases
+	if shards := os.Getenv("GHA2DB_SHARDS"); shards != "" {
+		for _, shard := range strings.Split(shards, ",") {
+			shard = strings.TrimSpace(shard)
+			if shard != "" {
+				ctx.Shards = append(ctx.Shards, shard)
+			}
+		}
+	}
+
+	// Geocoding service
+	ctx.GeocoderURL = os.Getenv("GHA2DB_GEOCODER_URL")
+
+	// Trusted proxies
+	if proxies := os.Getenv("GHA2DB_TRUSTED_PROXIES"); proxies != "" {
+		nets, err := ParseTrustedProxies(proxies)
+		FatalOnError(err)
+		ctx.TrustedProxies = nets
+	}
+
+	// Fields truncation
+	if limits := os.Getenv("GHA2DB_TRUNC_LIMITS"); limits != "" {
+		truncLimits, err := ParseTruncLimits(limits)
+		FatalOnError(err)
+		ctx.TruncLimits = truncLimits
+	}
+	ctx.TruncAudit = os.Getenv("GHA2DB_TRUNC_AUDIT") != ""
+
+	// Commit messages size policy
+	if os.Getenv("GHA2DB_COMMIT_MSG_LIMIT") != "" {
+		msgLimit, err := strconv.Atoi(os.Getenv("GHA2DB_COMMIT_MSG_LIMIT"))
+		FatalNoLog(err)
+		if msgLimit > 0 {
+			ctx.CommitMsgLimit = msgLimit
+		}
+	}
+	ctx.CommitMsgStore = os.Getenv("GHA2DB_COMMIT_MSG_STORE") != ""
+
+	// Certificates signing key
+	ctx.APICertSecret = os.Getenv("GHA2DB_API_CERT_SECRET")
+
+	// HTTP client
+	ctx.HTTPCABundle = os.Getenv("GHA2DB_HTTP_CA_BUNDLE")
+
+	if os.Getenv("GHA2DB_HTTP_CLIENT_RETRIES") == "" {
+		ctx.HTTPClientRetries = 2
+	} else {
+		retries, err := strconv.Atoi(os.Getenv("GHA2DB_HTTP_CLIENT_RETRIES"))
+		FatalNoLog(err)
+		ctx.HTTPClientRetries = retries
+	}
+
+	if os.Getenv("GHA2DB_HTTP_CLIENT_BACKOFF") == "" {
+		ctx.HTTPClientBackoff = time.Second
+	} else {
+		d, err := time.ParseDuration(os.Getenv("GHA2DB_HTTP_CLIENT_BACKOFF"))
+		FatalNoLog(err)
+		ctx.HTTPClientBackoff = d
+	}
+
+	// Series tables suffix (blue/green mode)
+	ctx.TSDBSuffix = os.Getenv("GHA2DB_TSDB_SUFFIX")
+
+	// Blue/green TSDB recompute
+	ctx.BlueGreen = os.Getenv("GHA2DB_BLUE_GREEN") != ""
+
+	// Blue/green minimum rows ratio
+	if os.Getenv("GHA2DB_BLUE_GREEN_MIN_RATIO") == "" {
+		ctx.BlueGreenMinRatio = 0.5
+	} else {
+		ratio, err := strconv.ParseFloat(os.Getenv("GHA2DB_BLUE_GREEN_MIN_RATIO"), 64)
+		FatalNoLog(err)
+		ctx.BlueGreenMinRatio = ratio
+	}
+
+	// Resume unfinished sync run
+	ctx.SyncResume = os.Getenv("GHA2DB_SYNC_RESUME") != ""
+
+	// Repository not found limit
+	if os.Getenv("GHA2DB_REPO_NOT_FOUND_LIMIT") == "" {
+		ctx.RepoNotFoundLimit = 3
+	} else {
+		limit, err := strconv.Atoi(os.Getenv("GHA2DB_REPO_NOT_FOUND_LIMIT"))
+		FatalNoLog(err)
+		ctx.RepoNotFoundLimit = limit
+	}
+
+	// Admin APIs token
+	ctx.APIAdminToken = os.Getenv("GHA2DB_API_ADMIN_TOKEN")
+
+	// Repository traffic orgs
+	ctx.APITrafficOrgs = make(map[string]bool)
+	for _, org := range strings.Split(os.Getenv("GHA2DB_GHAPITRAFFIC"), ",") {
+		org = strings.TrimSpace(org)
+		if org != "" {
+			ctx.APITrafficOrgs[org] = true
+		}
+	}
+
+	// Config file, already loaded at the beginning of Init
+	ctx.ConfigFile = os.Getenv("GHA2DB_CONFIG")
+
+	// Integrity mode
+	ctx.FKChecks = os.Getenv("GHA2DB_FK_CHECKS") != ""
+
+	// Actors with synthetic IDs reconciliation
+	if os.Getenv("GHA2DB_GHAPI_RECONCILE_ACTORS") != "" {
+		n, err := strconv.Atoi(os.Getenv("GHA2DB_GHAPI_RECONCILE_ACTORS"))
+		FatalNoLog(err)
+		if n > 0 {
+			ctx.APIReconcileActors = n
+		}
+	}
+
+	// website_data feeds
+	ctx.WebsiteDataFeeds = make(map[string]bool)
+	feeds := os.Getenv("GHA2DB_WEBSITEDATA_FEEDS")
+	if feeds == "" {
+		feeds = "stats"
+	}
+	for _, feed := range strings.Split(feeds, ",") {
+		feed = strings.TrimSpace(feed)
+		if feed != "" {
+			ctx.WebsiteDataFeeds[feed] = true
+		}
+	}
+	ctx.WebsiteDataTop = 10
+	if os.Getenv("GHA2DB_WEBSITEDATA_TOP") != "" {
+		top, err := strconv.Atoi(os.Getenv("GHA2DB_WEBSITEDATA_TOP"))
+		FatalNoLog(err)
+		if top > 0 {
+			ctx.WebsiteDataTop = top
+		}
+	}
+	ctx.WebsiteDataUpload = os.Getenv("GHA2DB_WEBSITEDATA_UPLOAD")
+
+	if os.Getenv("GHA2DB_MEM_BUDGET_MB") != "" {
+		mb, err := strconv.Atoi(os.Getenv("GHA2DB_MEM_BUDGET_MB"))
+		FatalNoLog(err)
+		if mb > 0 {
+			ctx.MemBudgetMB = mb
+		}
+	}
+
+	ctx.ProgressJSON = os.Getenv("GHA2DB_PROGRESS_JSON") != ""
+
+	if os.Getenv("GHA2DB_ACTORS_MISS_TTL") == "" {
+		ctx.ActorsMissTTL = time.Hour
+	} else {
+		d, err := time.ParseDuration(os.Getenv("GHA2DB_ACTORS_MISS_TTL"))
+		FatalNoLog(err)
+		ctx.ActorsMissTTL = d
+	}
+	ctx.ActorsMissCacheMax = 100000
+	if os.Getenv("GHA2DB_ACTORS_MISS_CACHE_MAX") != "" {
+		max, err := strconv.Atoi(os.Getenv("GHA2DB_ACTORS_MISS_CACHE_MAX"))
+		FatalNoLog(err)
+		if max > 0 {
+			ctx.ActorsMissCacheMax = max
+		}
+	}
+
+	// Per project features can override settings from environment
+	ctx.Features = FeaturesFromString(os.Getenv("GHA2DB_FEATURES"))
+	if !ctx.FeatureEnabled(FeatureCommitsFiles, true) {
+		ctx.CommitsFilesStatsEnabled = false
+	}
+	if !ctx.FeatureEnabled(FeatureCommitsLOC, true) {
+		ctx.CommitsLOCStatsEnabled = false
+	}
+	if ctx.FeatureEnabled(FeatureWorkflowRuns, false) {
+		ctx.APIWorkflowRuns = true
+	}
+	ctx.APIMilestones = ctx.FeatureEnabled(FeatureMilestoneProgress, os.Getenv("GHA2DB_GHAPIMILESTONES") != "")
+	ctx.Mentions = ctx.FeatureEnabled(FeatureMentions, os.Getenv("GHA2DB_MENTIONS") != "")
+
+	// Monthly partitions of big event tables
+	// Partitioned tables primary keys include partition column, so gha_events.id is no longer unique and cannot be referenced
+	ctx.Partitioned = os.Getenv("GHA2DB_PARTITIONED") != ""
+	if ctx.Partitioned && ctx.FKChecks {
+		FatalfWithCode(ExitConfig, "GHA2DB_PARTITIONED cannot be used with GHA2DB_FK_CHECKS")
+	}
+	ctx.PartitionsAhead = 3
+	if os.Getenv("GHA2DB_PARTITIONS_AHEAD") != "" {
+		ahead, err := strconv.Atoi(os.Getenv("GHA2DB_PARTITIONS_AHEAD"))
+		FatalNoLog(err)
+		if ahead >= 0 {
+			ctx.PartitionsAhead = ahead
+		}
+	}
+	if os.Getenv("GHA2DB_PARTITIONS_DETACH_MONTHS") != "" {
+		months, err := strconv.Atoi(os.Getenv("GHA2DB_PARTITIONS_DETACH_MONTHS"))
+		FatalNoLog(err)
+		if months > 0 {
+			ctx.PartitionsDetachMonths = months
+		}
+	}
+
+	// Schema drift detection
+	ctx.SchemaCheck = os.Getenv("GHA2DB_SKIP_SCHEMA_CHECK") == ""
+
+	// Closing PRs from closed issues timelines
+	ctx.APILinkedTimeline = os.Getenv("GHA2DB_GHAPILINKEDTIMELINE") != ""
+
+	// Issue event types to sync (ghapi2db)
+	ctx.EventTypesYaml = os.Getenv("GHA2DB_EVENT_TYPES_YAML")
+	if os.Getenv("GHA2DB_GHAPIEVENTTYPES") != "" {
+		ctx.APIEventTypes = StringsMapToArray(strings.TrimSpace, strings.Split(os.Getenv("GHA2DB_GHAPIEVENTTYPES"), ","))
+	}
+	if os.Getenv("GHA2DB_GHAPISKIPEVENTTYPES") != "" {
+		ctx.APISkipEventTypes = StringsMapToArray(strings.TrimSpace, strings.Split(os.Getenv("GHA2DB_GHAPISKIPEVENTTYPES"), ","))
+	}
+
+	// GHA duplicated events and clock skew detection
+	ctx.DedupWindow = 1
+	if os.Getenv("GHA2DB_DEDUP_WINDOW") != "" {
+		window, err := strconv.Atoi(os.Getenv("GHA2DB_DEDUP_WINDOW"))
+		FatalNoLog(err)
+		if window >= 0 {
+			ctx.DedupWindow = window
+		}
+	}
+	ctx.SkewFix = os.Getenv("GHA2DB_SKEW_FIX") != ""
+
+	// OpenTelemetry tracing
+	ctx.OTelEndpoint = os.Getenv("GHA2DB_OTEL_ENDPOINT")
+	ctx.OTelSample = 1.0
+	if os.Getenv("GHA2DB_OTEL_SAMPLE") != "" {
+		sample, err := strconv.ParseFloat(os.Getenv("GHA2DB_OTEL_SAMPLE"), 64)
+		FatalNoLog(err)
+		if sample >= 0.0 && sample <= 1.0 {
+			ctx.OTelSample = sample
+		}
+	}
+
+	// Run summary artifact
+	ctx.SummaryFile = os.Getenv("GHA2DB_SUMMARY_FILE")
+
+	// Raw GitHub API snapshots
+	ctx.GHAPIRawDir = os.Getenv("GHA2DB_GHAPI_RAW_DIR")
+
+	// API background calculations
+	ctx.APIMaxBg = 3
+	if os.Getenv("GHA2DB_API_MAX_BG") != "" {
+		maxBg, err := strconv.Atoi(os.Getenv("GHA2DB_API_MAX_BG"))
+		FatalNoLog(err)
+		if maxBg > 0 {
+			ctx.APIMaxBg = maxBg
+		}
+	}
+	ctx.APIMaxBgQueue = 100
+	if os.Getenv("GHA2DB_API_MAX_BG_QUEUE") != "" {
+		maxQueue, err := strconv.Atoi(os.Getenv("GHA2DB_API_MAX_BG_QUEUE"))
+		FatalNoLog(err)
+		if maxQueue >= 0 {
+			ctx.APIMaxBgQueue = maxQueue
+		}
+	}
+
+	// Orgs and actors excluded at ingest
+	ctx.ExclusionsYaml = os.Getenv("GHA2DB_EXCLUSIONS_YAML")
+
+	// Export of parsed events in external schema
+	ctx.ExportFormat = os.Getenv("GHA2DB_EXPORT_FORMAT")
+	ctx.ExportDir = os.Getenv("GHA2DB_EXPORT_DIR")
+	if ctx.ExportDir == "" {
+		ctx.ExportDir = "export/"
+	}
+	if ctx.ExportDir[len(ctx.ExportDir)-1:] != "/" {
+		ctx.ExportDir += "/"
+	}
+
+	// Commits enrichment gaps detection
+	ctx.CommitsGapAttempts = 3
+	if os.Getenv("GHA2DB_COMMITS_GAP_ATTEMPTS") != "" {
+		attempts, err := strconv.Atoi(os.Getenv("GHA2DB_COMMITS_GAP_ATTEMPTS"))
+		FatalNoLog(err)
+		if attempts >= 0 {
+			ctx.CommitsGapAttempts = attempts
+		}
+	}
+	ctx.CommitsGapMax = 1000
+	if os.Getenv("GHA2DB_COMMITS_GAP_MAX") != "" {
+		gapMax, err := strconv.Atoi(os.Getenv("GHA2DB_COMMITS_GAP_MAX"))
+		FatalNoLog(err)
+		if gapMax > 0 {
+			ctx.CommitsGapMax = gapMax
+		}
+	}
+
+	// GitHub webhooks receiver (provisional events)
+	ctx.ReceiverPort = os.Getenv("GHA2DB_RECEIVER_PORT")
+	if ctx.ReceiverPort == "" {
+		ctx.ReceiverPort = ":1983"
+	} else {
+		if ctx.ReceiverPort[0:1] != ":" {
+			ctx.ReceiverPort = ":" + ctx.ReceiverPort
+		}
+	}
+	ctx.ReceiverRoot = os.Getenv("GHA2DB_RECEIVER_ROOT")
+	if ctx.ReceiverRoot == "" {
+		ctx.ReceiverRoot = "/github"
+	}
+	ctx.ReceiverSecret = os.Getenv("GHA2DB_RECEIVER_SECRET")
+
+	// API request limits
+	ctx.APIMaxBody = 1 << 20
+	if os.Getenv("GHA2DB_API_MAX_BODY") != "" {
+		maxBody, err := strconv.Atoi(os.Getenv("GHA2DB_API_MAX_BODY"))
+		FatalNoLog(err)
+		if maxBody > 0 {
+			ctx.APIMaxBody = maxBody
+		}
+	}
+	ctx.APIMaxDepth = 8
+	if os.Getenv("GHA2DB_API_MAX_DEPTH") != "" {
+		maxDepth, err := strconv.Atoi(os.Getenv("GHA2DB_API_MAX_DEPTH"))
+		FatalNoLog(err)
+		if maxDepth > 0 {
+			ctx.APIMaxDepth = maxDepth
+		}
+	}
+	ctx.APIMaxArray = 1000
+	if os.Getenv("GHA2DB_API_MAX_ARRAY") != "" {
+		maxArray, err := strconv.Atoi(os.Getenv("GHA2DB_API_MAX_ARRAY"))
+		FatalNoLog(err)
+		if maxArray > 0 {
+			ctx.APIMaxArray = maxArray
+		}
+	}
+
+	// API cache warming
+	if os.Getenv("GHA2DB_API_WARM_TOPK") != "" {
+		topK, err := strconv.Atoi(os.Getenv("GHA2DB_API_WARM_TOPK"))
+		FatalNoLog(err)
+		if topK > 0 {
+			ctx.APIWarmTopK = topK
+		}
+	}
+	if os.Getenv("GHA2DB_API_WARM_INTERVAL") == "" {
+		ctx.APIWarmInterval = time.Minute
+	} else {
+		d, err := time.ParseDuration(os.Getenv("GHA2DB_API_WARM_INTERVAL"))
+		FatalNoLog(err)
+		if d <= 0 {
+			FatalNoLog(fmt.Errorf("GHA2DB_API_WARM_INTERVAL must be positive, got %v", d))
+		}
+		ctx.APIWarmInterval = d
+	}
+
+	if os.Getenv("GHA2DB_GHAPIFORKS") != "" {
+		forks, err := strconv.Atoi(os.Getenv("GHA2DB_GHAPIFORKS"))
+		FatalNoLog(err)
+		if forks > 0 {
+			ctx.APIForks = forks
+		}
+	}
+
 	// Context out if requested
 	if ctx.CtxOut {
 		ctx.Print()
@@ -1002,5 +1481,76 @@ func (ctx *Ctx) CopyContext() *Ctx {
 		EnableMetricsDrop:        ctx.EnableMetricsDrop,
 		RecalcReciprocal:         ctx.RecalcReciprocal,
 		MaxHistograms:            ctx.MaxHistograms,
+		Diff:                     ctx.Diff,
+		LocalJSONsDir:            ctx.LocalJSONsDir,
+		SkipDataQuality:          ctx.SkipDataQuality,
+		Shards:                   ctx.Shards,
+		GeocoderURL:              ctx.GeocoderURL,
+		APIWorkflowRuns:          ctx.APIWorkflowRuns,
+		TrustedProxies:           ctx.TrustedProxies,
+		TruncLimits:              ctx.TruncLimits,
+		TruncAudit:               ctx.TruncAudit,
+		APICertSecret:            ctx.APICertSecret,
+		HTTPCABundle:             ctx.HTTPCABundle,
+		HTTPClientRetries:        ctx.HTTPClientRetries,
+		HTTPClientBackoff:        ctx.HTTPClientBackoff,
+		TSDBSuffix:               ctx.TSDBSuffix,
+		BlueGreen:                ctx.BlueGreen,
+		BlueGreenMinRatio:        ctx.BlueGreenMinRatio,
+		SyncResume:               ctx.SyncResume,
+		RepoNotFoundLimit:        ctx.RepoNotFoundLimit,
+		APIAdminToken:            ctx.APIAdminToken,
+		APITrafficOrgs:           ctx.APITrafficOrgs,
+		ConfigFile:               ctx.ConfigFile,
+		FKChecks:                 ctx.FKChecks,
+		APIReconcileActors:       ctx.APIReconcileActors,
+		WebsiteDataFeeds:         ctx.WebsiteDataFeeds,
+		WebsiteDataTop:           ctx.WebsiteDataTop,
+		WebsiteDataUpload:        ctx.WebsiteDataUpload,
+		MemBudgetMB:              ctx.MemBudgetMB,
+		ProgressJSON:             ctx.ProgressJSON,
+		ActorsMissTTL:            ctx.ActorsMissTTL,
+		ActorsMissCacheMax:       ctx.ActorsMissCacheMax,
+		Features:                 ctx.Features,
+		APIForks:                 ctx.APIForks,
+		Mentions:                 ctx.Mentions,
+		APIWarmTopK:              ctx.APIWarmTopK,
+		APIWarmInterval:          ctx.APIWarmInterval,
+		Partitioned:              ctx.Partitioned,
+		PartitionsAhead:          ctx.PartitionsAhead,
+		PartitionsDetachMonths:   ctx.PartitionsDetachMonths,
+		SchemaCheck:              ctx.SchemaCheck,
+		APILinkedTimeline:        ctx.APILinkedTimeline,
+		APIMaxBody:               ctx.APIMaxBody,
+		APIMaxDepth:              ctx.APIMaxDepth,
+		APIMaxArray:              ctx.APIMaxArray,
+		EventTypesYaml:           ctx.EventTypesYaml,
+		APIEventTypes:            ctx.APIEventTypes,
+		APISkipEventTypes:        ctx.APISkipEventTypes,
+		DedupWindow:              ctx.DedupWindow,
+		SkewFix:                  ctx.SkewFix,
+		OTelEndpoint:             ctx.OTelEndpoint,
+		OTelSample:               ctx.OTelSample,
+		ExportFormat:             ctx.ExportFormat,
+		ExportDir:                ctx.ExportDir,
+		CommitsGapAttempts:       ctx.CommitsGapAttempts,
+		CommitsGapMax:            ctx.CommitsGapMax,
+		ReceiverPort:             ctx.ReceiverPort,
+		ReceiverRoot:             ctx.ReceiverRoot,
+		ReceiverSecret:           ctx.ReceiverSecret,
+		CommitMsgLimit:           ctx.CommitMsgLimit,
+		CommitMsgStore:           ctx.CommitMsgStore,
+		APIMilestones:            ctx.APIMilestones,
+		SQLRedact:                ctx.SQLRedact,
+		GitHubAppID:              ctx.GitHubAppID,
+		GitHubAppInstallations:   ctx.GitHubAppInstallations,
+		GitHubAppKey:             ctx.GitHubAppKey,
+		SummaryFile:              ctx.SummaryFile,
+		GHAPIRawDir:              ctx.GHAPIRawDir,
+		APIMaxBg:                 ctx.APIMaxBg,
+		APIMaxBgQueue:            ctx.APIMaxBgQueue,
+		ExclusionsYaml:           ctx.ExclusionsYaml,
+		Exclusions:               ctx.Exclusions,
+		Trace:                    ctx.Trace,
 	}
 }
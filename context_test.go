@@ -2,6 +2,7 @@ package devstatscode
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"reflect"
 	"regexp"
@@ -42,6 +43,13 @@ func dynamicSetFields(t *testing.T, ctx *lib.Ctx, fields map[string]interface{})
 				return ctx
 			}
 			field.SetInt(int64(interfaceValue))
+		case int64:
+			// Check if types match
+			if fieldKind != reflect.Int64 {
+				t.Errorf("trying to set value %v, type %T for field \"%s\", type %v", interfaceValue, interfaceValue, fieldName, fieldKind)
+				return ctx
+			}
+			field.SetInt(interfaceValue)
 		case float64:
 			// Check if types match
 			if fieldKind != reflect.Float64 {
@@ -135,6 +143,22 @@ func dynamicSetFields(t *testing.T, ctx *lib.Ctx, fields map[string]interface{})
 				return ctx
 			}
 			field.Set(reflect.ValueOf(fieldValue))
+		case map[string]int:
+			// Check if types match
+			fieldType := field.Type()
+			if fieldType != reflect.TypeOf(map[string]int{}) {
+				t.Errorf("trying to set value %v, type %T for field \"%s\", type %v", interfaceValue, interfaceValue, fieldName, fieldKind)
+				return ctx
+			}
+			field.Set(reflect.ValueOf(fieldValue))
+		case []*net.IPNet:
+			// Check if types match
+			fieldType := field.Type()
+			if fieldType != reflect.TypeOf([]*net.IPNet{}) {
+				t.Errorf("trying to set value %v, type %T for field \"%s\", type %v", interfaceValue, interfaceValue, fieldName, fieldKind)
+				return ctx
+			}
+			field.Set(reflect.ValueOf(fieldValue))
 		default:
 			// Unknown type provided
 			t.Errorf("unknown type %T for field \"%s\"", interfaceValue, fieldName)
@@ -288,6 +312,76 @@ func TestInit(t *testing.T) {
 		AllowRandTagsColsCompute: false,
 		RecalcReciprocal:         24,
 		MaxHistograms:            0,
+		Diff:                     false,
+		LocalJSONsDir:            "",
+		SkipDataQuality:          false,
+		Shards:                   nil,
+		GeocoderURL:              "",
+		APIWorkflowRuns:          false,
+		TrustedProxies:           nil,
+		TruncLimits:              nil,
+		TruncAudit:               false,
+		APICertSecret:            "",
+		HTTPCABundle:             "",
+		HTTPClientRetries:        2,
+		HTTPClientBackoff:        time.Second,
+		TSDBSuffix:               "",
+		BlueGreen:                false,
+		BlueGreenMinRatio:        0.5,
+		SyncResume:               false,
+		RepoNotFoundLimit:        3,
+		APIAdminToken:            "",
+		APITrafficOrgs:           map[string]bool{},
+		ConfigFile:               "",
+		FKChecks:                 false,
+		APIReconcileActors:       0,
+		WebsiteDataFeeds:         map[string]bool{"stats": true},
+		WebsiteDataTop:           10,
+		WebsiteDataUpload:        "",
+		MemBudgetMB:              0,
+		ProgressJSON:             false,
+		ActorsMissTTL:            time.Hour,
+		ActorsMissCacheMax:       100000,
+		Features:                 map[string]bool{},
+		APIForks:                 0,
+		Mentions:                 false,
+		APIWarmTopK:              0,
+		APIWarmInterval:          time.Minute,
+		Partitioned:              false,
+		PartitionsAhead:          3,
+		PartitionsDetachMonths:   0,
+		SchemaCheck:              true,
+		APILinkedTimeline:        false,
+		APIMaxBody:               1048576,
+		APIMaxDepth:              8,
+		APIMaxArray:              1000,
+		EventTypesYaml:           "",
+		APIEventTypes:            nil,
+		APISkipEventTypes:        nil,
+		DedupWindow:              1,
+		SkewFix:                  false,
+		OTelEndpoint:             "",
+		OTelSample:               1.0,
+		ExportFormat:             "",
+		ExportDir:                "export/",
+		CommitsGapAttempts:       3,
+		CommitsGapMax:            1000,
+		ReceiverPort:             ":1983",
+		ReceiverRoot:             "/github",
+		ReceiverSecret:           "",
+		CommitMsgLimit:           0,
+		CommitMsgStore:           false,
+		APIMilestones:            false,
+		SQLRedact:                nil,
+		GitHubAppID:              0,
+		GitHubAppInstallations:   nil,
+		GitHubAppKey:             "",
+		SummaryFile:              "",
+		GHAPIRawDir:              "",
+		APIMaxBg:                 3,
+		APIMaxBgQueue:            100,
+		ExclusionsYaml:           "",
+		Trace:                    nil,
 	}
 
 	var nilRegexp *regexp.Regexp
@@ -636,6 +730,7 @@ func TestInit(t *testing.T) {
 				"GHA2DB_GHAPIFORCELICENSES":  "1",
 				"GHA2DB_GHAPISKIPLANGS":      "1",
 				"GHA2DB_GHAPIFORCELANGS":     "1",
+				"GHA2DB_GHAPIWORKFLOWRUNS":   "1",
 				"GHA2DB_GHAPI_ERROR_FATAL":   "1",
 				"GHA2DB_NO_AUTOFETCHCOMMITS": "1",
 			},
@@ -651,6 +746,7 @@ func TestInit(t *testing.T) {
 					"ForceAPILicenses":  true,
 					"SkipAPILangs":      true,
 					"ForceAPILangs":     true,
+					"APIWorkflowRuns":   true,
 					"GHAPIErrorIsFatal": true,
 					"AutoFetchCommits":  false,
 				},
@@ -972,6 +1068,484 @@ func TestInit(t *testing.T) {
 				},
 			),
 		},
+		{
+			"Setting shards",
+			map[string]string{"GHA2DB_SHARDS": "gha_shard0,gha_shard1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"Shards": []string{"gha_shard0", "gha_shard1"},
+				},
+			),
+		},
+		{
+			"Setting shards with spaces and empty entries",
+			map[string]string{"GHA2DB_SHARDS": " gha_shard0, ,gha_shard1 ,"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"Shards": []string{"gha_shard0", "gha_shard1"},
+				},
+			),
+		},
+		{
+			"Setting trusted proxies",
+			map[string]string{"GHA2DB_TRUSTED_PROXIES": "10.0.0.0/8, 127.0.0.1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"TrustedProxies": []*net.IPNet{
+						{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+						{IP: net.IP{127, 0, 0, 1}, Mask: net.CIDRMask(32, 32)},
+					},
+				},
+			),
+		},
+		{
+			"Setting certificates signing key",
+			map[string]string{"GHA2DB_API_CERT_SECRET": "key"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APICertSecret": "key"},
+			),
+		},
+		{
+			"Setting HTTP client",
+			map[string]string{
+				"GHA2DB_HTTP_CA_BUNDLE":      "/etc/ssl/ca.pem",
+				"GHA2DB_HTTP_CLIENT_RETRIES": "5",
+				"GHA2DB_HTTP_CLIENT_BACKOFF": "250ms",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"HTTPCABundle":      "/etc/ssl/ca.pem",
+					"HTTPClientRetries": 5,
+					"HTTPClientBackoff": 250 * time.Millisecond,
+				},
+			),
+		},
+		{
+			"Setting blue/green TSDB recompute",
+			map[string]string{
+				"GHA2DB_TSDB_SUFFIX":          "_new",
+				"GHA2DB_BLUE_GREEN":           "1",
+				"GHA2DB_BLUE_GREEN_MIN_RATIO": "0.8",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"TSDBSuffix":        "_new",
+					"BlueGreen":         true,
+					"BlueGreenMinRatio": 0.8,
+				},
+			),
+		},
+		{
+			"Setting sync resume",
+			map[string]string{"GHA2DB_SYNC_RESUME": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"SyncResume": true},
+			),
+		},
+		{
+			"Setting repository not found limit",
+			map[string]string{"GHA2DB_REPO_NOT_FOUND_LIMIT": "0"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"RepoNotFoundLimit": 0},
+			),
+		},
+		{
+			"Setting admin APIs token",
+			map[string]string{"GHA2DB_API_ADMIN_TOKEN": "secret"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIAdminToken": "secret"},
+			),
+		},
+		{
+			"Setting repository traffic orgs",
+			map[string]string{"GHA2DB_GHAPITRAFFIC": "cncf, kubernetes,"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APITrafficOrgs": map[string]bool{"cncf": true, "kubernetes": true}},
+			),
+		},
+		{
+			"Setting integrity mode",
+			map[string]string{"GHA2DB_FK_CHECKS": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"FKChecks": true},
+			),
+		},
+		{
+			"Setting actors reconciliation",
+			map[string]string{"GHA2DB_GHAPI_RECONCILE_ACTORS": "500"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIReconcileActors": 500},
+			),
+		},
+		{
+			"Setting website data feeds",
+			map[string]string{
+				"GHA2DB_WEBSITEDATA_FEEDS":  "stats, contributors,,annotations",
+				"GHA2DB_WEBSITEDATA_TOP":    "25",
+				"GHA2DB_WEBSITEDATA_UPLOAD": "aws s3 sync {{dir}} s3://bucket/jsons/",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"WebsiteDataFeeds":  map[string]bool{"stats": true, "contributors": true, "annotations": true},
+					"WebsiteDataTop":    25,
+					"WebsiteDataUpload": "aws s3 sync {{dir}} s3://bucket/jsons/",
+				},
+			),
+		},
+		{
+			"Setting memory budget",
+			map[string]string{"GHA2DB_MEM_BUDGET_MB": "512"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"MemBudgetMB": 512},
+			),
+		},
+		{
+			"Setting JSON progress output",
+			map[string]string{"GHA2DB_PROGRESS_JSON": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"ProgressJSON": true},
+			),
+		},
+		{
+			"Setting actors lookup misses cache",
+			map[string]string{
+				"GHA2DB_ACTORS_MISS_TTL":       "10m",
+				"GHA2DB_ACTORS_MISS_CACHE_MAX": "500",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"ActorsMissTTL": 10 * time.Minute, "ActorsMissCacheMax": 500},
+			),
+		},
+		{
+			"Setting fork activity sync",
+			map[string]string{"GHA2DB_GHAPIFORKS": "5"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIForks": 5},
+			),
+		},
+		{
+			"Setting project features",
+			map[string]string{"GHA2DB_FEATURES": "workflow_runs,-commits_files, heavy"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"Features":                 map[string]bool{"workflow_runs": true, "commits_files": false, "heavy": true},
+					"APIWorkflowRuns":          true,
+					"CommitsFilesStatsEnabled": false,
+				},
+			),
+		},
+		{
+			"Setting mentions graph",
+			map[string]string{"GHA2DB_MENTIONS": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"Mentions": true},
+			),
+		},
+		{
+			"Disabling mentions graph by project feature",
+			map[string]string{"GHA2DB_MENTIONS": "1", "GHA2DB_FEATURES": "-mentions"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"Mentions": false, "Features": map[string]bool{"mentions": false}},
+			),
+		},
+		{
+			"Setting API cache warming",
+			map[string]string{"GHA2DB_API_WARM_TOPK": "20", "GHA2DB_API_WARM_INTERVAL": "30s"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIWarmTopK": 20, "APIWarmInterval": 30 * time.Second},
+			),
+		},
+		{
+			"Setting monthly partitions",
+			map[string]string{
+				"GHA2DB_PARTITIONED":              "1",
+				"GHA2DB_PARTITIONS_AHEAD":         "0",
+				"GHA2DB_PARTITIONS_DETACH_MONTHS": "24",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"Partitioned": true, "PartitionsAhead": 0, "PartitionsDetachMonths": 24},
+			),
+		},
+		{
+			"Skipping schema check",
+			map[string]string{"GHA2DB_SKIP_SCHEMA_CHECK": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"SchemaCheck": false},
+			),
+		},
+		{
+			"Setting linked issues timeline",
+			map[string]string{"GHA2DB_GHAPILINKEDTIMELINE": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APILinkedTimeline": true},
+			),
+		},
+		{
+			"Setting API request limits",
+			map[string]string{
+				"GHA2DB_API_MAX_BODY":  "65536",
+				"GHA2DB_API_MAX_DEPTH": "4",
+				"GHA2DB_API_MAX_ARRAY": "-1",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIMaxBody": 65536, "APIMaxDepth": 4},
+			),
+		},
+		{
+			"Setting issue event types",
+			map[string]string{
+				"GHA2DB_EVENT_TYPES_YAML":    "event_types.yaml",
+				"GHA2DB_GHAPIEVENTTYPES":     "*",
+				"GHA2DB_GHAPISKIPEVENTTYPES": "subscribed, mentioned",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"EventTypesYaml":    "event_types.yaml",
+					"APIEventTypes":     []string{"*"},
+					"APISkipEventTypes": []string{"subscribed", "mentioned"},
+				},
+			),
+		},
+		{
+			"Setting duplicated events and clock skew detection",
+			map[string]string{
+				"GHA2DB_DEDUP_WINDOW": "0",
+				"GHA2DB_SKEW_FIX":     "1",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"DedupWindow": 0, "SkewFix": true},
+			),
+		},
+		{
+			"Setting OpenTelemetry tracing",
+			map[string]string{
+				"GHA2DB_OTEL_ENDPOINT": "http://otel-collector:4318",
+				"GHA2DB_OTEL_SAMPLE":   "0.25",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"OTelEndpoint": "http://otel-collector:4318", "OTelSample": 0.25},
+			),
+		},
+		{
+			"Setting events export",
+			map[string]string{
+				"GHA2DB_EXPORT_FORMAT": "bigquery",
+				"GHA2DB_EXPORT_DIR":    "/data/export",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"ExportFormat": "bigquery", "ExportDir": "/data/export/"},
+			),
+		},
+		{
+			"Setting commits gaps detection",
+			map[string]string{
+				"GHA2DB_COMMITS_GAP_ATTEMPTS": "0",
+				"GHA2DB_COMMITS_GAP_MAX":      "200",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"CommitsGapAttempts": 0, "CommitsGapMax": 200},
+			),
+		},
+		{
+			"Setting webhooks receiver",
+			map[string]string{
+				"GHA2DB_RECEIVER_PORT":   "8080",
+				"GHA2DB_RECEIVER_ROOT":   "/receive",
+				"GHA2DB_RECEIVER_SECRET": "s3cr3t",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"ReceiverPort": ":8080", "ReceiverRoot": "/receive", "ReceiverSecret": "s3cr3t"},
+			),
+		},
+		{
+			"Setting commit messages policy",
+			map[string]string{
+				"GHA2DB_COMMIT_MSG_LIMIT": "4096",
+				"GHA2DB_COMMIT_MSG_STORE": "1",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"CommitMsgLimit": 4096, "CommitMsgStore": true},
+			),
+		},
+		{
+			"Setting milestone progress sync",
+			map[string]string{"GHA2DB_GHAPIMILESTONES": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIMilestones": true},
+			),
+		},
+		{
+			"Setting milestone progress feature",
+			map[string]string{"GHA2DB_GHAPIMILESTONES": "1", "GHA2DB_FEATURES": "-milestone_progress"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"Features": map[string]bool{"milestone_progress": false}},
+			),
+		},
+		{
+			"Setting default SQL logs redaction",
+			map[string]string{"GHA2DB_SQL_REDACT": "1"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"SQLRedact": []string{"email", "name", "login"}},
+			),
+		},
+		{
+			"Setting SQL logs redaction",
+			map[string]string{"GHA2DB_SQL_REDACT": " Email, login,"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"SQLRedact": []string{"email", "login"}},
+			),
+		},
+		{
+			"Setting GitHub App authentication",
+			map[string]string{
+				"GHA2DB_GITHUB_APP_ID":              "12345",
+				"GHA2DB_GITHUB_APP_INSTALLATION_ID": "111, 222",
+				"GHA2DB_GITHUB_APP_KEY":             "/etc/github/app.pem",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"GitHubAppID":            int64(12345),
+					"GitHubAppInstallations": []int64{111, 222},
+					"GitHubAppKey":           "/etc/github/app.pem",
+				},
+			),
+		},
+		{
+			"Setting run summary file",
+			map[string]string{"GHA2DB_SUMMARY_FILE": "/tmp/gha2db.json"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"SummaryFile": "/tmp/gha2db.json"},
+			),
+		},
+		{
+			"Setting GitHub API raw snapshots directory",
+			map[string]string{"GHA2DB_GHAPI_RAW_DIR": "/data/ghapi_raw"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"GHAPIRawDir": "/data/ghapi_raw"},
+			),
+		},
+		{
+			"Setting API background calculations limits",
+			map[string]string{
+				"GHA2DB_API_MAX_BG":       "5",
+				"GHA2DB_API_MAX_BG_QUEUE": "0",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"APIMaxBg": 5, "APIMaxBgQueue": 0},
+			),
+		},
+		{
+			"Setting exclusions YAML",
+			map[string]string{"GHA2DB_EXCLUSIONS_YAML": "exclusions.yaml"},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{"ExclusionsYaml": "exclusions.yaml"},
+			),
+		},
+		{
+			"Setting invalid API background calculations limits",
+			map[string]string{
+				"GHA2DB_API_MAX_BG":       "0",
+				"GHA2DB_API_MAX_BG_QUEUE": "-1",
+			},
+			defaultContext.CopyContext(),
+		},
+		{
+			"Setting truncation limits and audit",
+			map[string]string{
+				"GHA2DB_TRUNC_LIMITS": "gha_comments.body=100000, gha_forkees.name=40",
+				"GHA2DB_TRUNC_AUDIT":  "1",
+			},
+			dynamicSetFields(
+				t,
+				defaultContext.CopyContext(),
+				map[string]interface{}{
+					"TruncLimits": map[string]int{"gha_comments.body": 100000, "gha_forkees.name": 40},
+					"TruncAudit":  true,
+				},
+			),
+		},
 		{
 			"Setting project",
 			map[string]string{"GHA2DB_PROJECT": "prometheus"},
@@ -1803,3 +2377,22 @@ func TestInit(t *testing.T) {
 		}
 	}
 }
+
+func TestInitPartitionedFKChecks(t *testing.T) {
+	t.Setenv("NO_FATAL_DELAY", "1")
+	t.Setenv("GHA2DB_PARTITIONED", "1")
+	t.Setenv("GHA2DB_FK_CHECKS", "1")
+	// Partitioned gha_events.id is not unique, so foreign keys cannot reference it
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			t.Fatalf("expected Init to fail, got %v", r)
+		}
+		if got := lib.ExitCode(err); got != lib.ExitConfig {
+			t.Errorf("expected %d, got %d for %v", lib.ExitConfig, got, err)
+		}
+	}()
+	var ctx lib.Ctx
+	ctx.Init()
+}
@@ -0,0 +1,70 @@
+package devstatscode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// NormalizeCountryCode - returns ISO 3166-1 alpha-2 code (upper case, deprecated codes like "UK" replaced by current ones)
+// Codes that are not countries are only upper cased
+func NormalizeCountryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	region, err := language.ParseRegion(code)
+	if err != nil || !region.IsCountry() {
+		return code
+	}
+	return region.Canonicalize().String()
+}
+
+// CountryNamer - localizes country names using CLDR data embedded in the binary
+type CountryNamer struct {
+	tag      language.Tag
+	namer    display.Namer
+	collator *collate.Collator
+}
+
+// NewCountryNamer - returns country namer for a given language (BCP 47 tag like "de", "pt-BR", "zh-Hans")
+func NewCountryNamer(lang string) (*CountryNamer, error) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return nil, fmt.Errorf("invalid language '%s': %v", lang, err)
+	}
+	namer := display.Regions(tag)
+	if namer == nil {
+		return nil, fmt.Errorf("no country names for language '%s'", lang)
+	}
+	return &CountryNamer{tag: tag, namer: namer, collator: collate.New(tag, collate.IgnoreCase)}, nil
+}
+
+// Name - returns localized name of a country with a given code, or def when it is not known
+func (cn *CountryNamer) Name(code, def string) string {
+	region, err := language.ParseRegion(strings.TrimSpace(code))
+	if err != nil || !region.IsCountry() {
+		return def
+	}
+	name := cn.namer.Name(region)
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+// Sort - sorts localized names (and their codes) using given language collation
+func (cn *CountryNamer) Sort(names, codes []string) {
+	idx := make([]int, len(names))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return cn.collator.CompareString(names[idx[i]], names[idx[j]]) < 0 })
+	sortedNames, sortedCodes := make([]string, len(names)), make([]string, len(codes))
+	for i, j := range idx {
+		sortedNames[i], sortedCodes[i] = names[j], codes[j]
+	}
+	copy(names, sortedNames)
+	copy(codes, sortedCodes)
+}
@@ -0,0 +1,92 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestNormalizeCountryCode(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		code     string
+		expected string
+	}{
+		{code: "", expected: ""},
+		{code: "PL", expected: "PL"},
+		{code: " us ", expected: "US"},
+		{code: "UK", expected: "GB"},
+		{code: "XK", expected: "XK"},
+		{code: "EU", expected: "EU"},
+		{code: "unknown", expected: "UNKNOWN"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.NormalizeCountryCode(test.code)
+		if got != test.expected {
+			t.Errorf("test number %d, expected '%s', got '%s'", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestCountryNamer(t *testing.T) {
+	for _, lang := range []string{"", "not a language", "xx"} {
+		_, err := lib.NewCountryNamer(lang)
+		if err == nil {
+			t.Errorf("expected error for language '%s'", lang)
+		}
+	}
+	// Test cases
+	var testCases = []struct {
+		lang     string
+		codes    []string
+		expected []string
+		sorted   []string
+	}{
+		{
+			lang:     "de",
+			codes:    []string{"US", "DE", "PL"},
+			expected: []string{"Vereinigte Staaten", "Deutschland", "Polen"},
+			sorted:   []string{"Deutschland", "Polen", "Vereinigte Staaten"},
+		},
+		{
+			lang:     "pl",
+			codes:    []string{"PL", "US", "ZZZ"},
+			expected: []string{"Polska", "Stany Zjednoczone", "default"},
+			sorted:   []string{"default", "Polska", "Stany Zjednoczone"},
+		},
+		{
+			lang:     "es",
+			codes:    []string{"NL", "AT"},
+			expected: []string{"Países Bajos", "Austria"},
+			sorted:   []string{"Austria", "Países Bajos"},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		namer, err := lib.NewCountryNamer(test.lang)
+		if err != nil {
+			t.Errorf("test number %d, unexpected error: %v", index+1, err)
+			continue
+		}
+		names := []string{}
+		for _, code := range test.codes {
+			names = append(names, namer.Name(code, "default"))
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, names)
+		}
+		codes := append([]string{}, test.codes...)
+		namer.Sort(names, codes)
+		if !reflect.DeepEqual(names, test.sorted) {
+			t.Errorf("test number %d, expected sorted '%v', got '%v'", index+1, test.sorted, names)
+		}
+		for i, name := range names {
+			if namer.Name(codes[i], "default") != name {
+				t.Errorf("test number %d, sorted codes '%v' don't match names '%v'", index+1, codes, names)
+				break
+			}
+		}
+	}
+}
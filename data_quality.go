@@ -0,0 +1,107 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Data quality indicators stored in gha_data_quality table
+const (
+	// DQEventsLastHour - number of events in the last parsed GHA hour
+	DQEventsLastHour = "events_last_hour"
+	// DQEventsTrailingAvg - average number of events per hour in the 7 days before the last parsed hour
+	DQEventsTrailingAvg = "events_trailing_avg"
+	// DQEventsVsTrailingAvg - last parsed hour events divided by their trailing average
+	DQEventsVsTrailingAvg = "events_vs_trailing_avg"
+	// DQUnknownAffiliationPct - % of actors active in the last 7 days without known affiliation
+	DQUnknownAffiliationPct = "unknown_affiliation_pct"
+	// DQCommitsWithoutRolesPct - % of commits from the last 7 days without any commit roles
+	DQCommitsWithoutRolesPct = "commits_without_roles_pct"
+	// DQHashedActorsPct - % of actors (events and commit roles) from the last 7 days with hash-fallback (negative) IDs
+	DQHashedActorsPct = "hashed_actors_pct"
+)
+
+// DataQualityIndicators - all data quality indicators in the order they are computed
+var DataQualityIndicators = []string{
+	DQEventsLastHour,
+	DQEventsTrailingAvg,
+	DQEventsVsTrailingAvg,
+	DQUnknownAffiliationPct,
+	DQCommitsWithoutRolesPct,
+	DQHashedActorsPct,
+}
+
+// dataQualityQueries - SQLs computing data quality indicators, $1 is the last parsed hour
+// Indicators not listed here are derived from other indicators
+var dataQualityQueries = map[string]string{
+	DQEventsLastHour: "select count(*) from gha_events where created_at >= $1 and created_at < $1::timestamp + '1 hour'::interval",
+	DQEventsTrailingAvg: "select count(*) / 168.0 from gha_events " +
+		"where created_at >= $1::timestamp - '7 days'::interval and created_at < $1",
+	DQUnknownAffiliationPct: "select coalesce(100.0 * sum(case when sub.known then 0 else 1 end) / nullif(count(*), 0), 0) from (" +
+		"select e.actor_id, exists(select 1 from gha_actors_affiliations af where af.actor_id = e.actor_id " +
+		"and af.company_name not in ('', 'Unknown', 'NotFound')) as known " +
+		"from gha_events e where e.created_at >= $1::timestamp - '7 days'::interval and e.created_at < $1::timestamp + '1 hour'::interval " +
+		"group by e.actor_id) sub",
+	DQCommitsWithoutRolesPct: "select coalesce(100.0 * sum(case when sub.has_roles then 0 else 1 end) / nullif(count(*), 0), 0) from (" +
+		"select c.sha, exists(select 1 from gha_commits_roles cr where cr.sha = c.sha) as has_roles " +
+		"from gha_commits c where c.dup_created_at >= $1::timestamp - '7 days'::interval and c.dup_created_at < $1::timestamp + '1 hour'::interval " +
+		"group by c.sha) sub",
+	DQHashedActorsPct: "select coalesce(100.0 * count(*) filter (where sub.actor_id < 0) / nullif(count(*), 0), 0) from (" +
+		"select actor_id from gha_events " +
+		"where created_at >= $1::timestamp - '7 days'::interval and created_at < $1::timestamp + '1 hour'::interval " +
+		"union select actor_id from gha_commits_roles where actor_id is not null and actor_id != 0 " +
+		"and dup_created_at >= $1::timestamp - '7 days'::interval and dup_created_at < $1::timestamp + '1 hour'::interval" +
+		") sub",
+}
+
+// ComputeDataQuality - computes data quality indicators for the last parsed GHA hour and stores them in gha_data_quality
+// It is called at the end of each sync, it returns error instead of failing, sync result doesn't depend on it
+// Last hour events count outside of [0.5, 2] times its trailing average is reported as an anomaly
+func ComputeDataQuality(con *sql.DB, ctx *Ctx) (values map[string]float64, err error) {
+	var dt *time.Time
+	err = QueryRowSQL(con, ctx, "select max(dt) from gha_parsed").Scan(&dt)
+	if err != nil {
+		return
+	}
+	if dt == nil {
+		err = fmt.Errorf("no parsed GHA hours, cannot compute data quality")
+		return
+	}
+	values = make(map[string]float64)
+	for _, indicator := range DataQualityIndicators {
+		query, ok := dataQualityQueries[indicator]
+		if !ok {
+			continue
+		}
+		var value float64
+		err = QueryRowSQL(con, ctx, query, *dt).Scan(&value)
+		if err != nil {
+			return
+		}
+		values[indicator] = value
+	}
+	avg := values[DQEventsTrailingAvg]
+	if avg > 0.0 {
+		values[DQEventsVsTrailingAvg] = values[DQEventsLastHour] / avg
+	} else {
+		values[DQEventsVsTrailingAvg] = 1.0
+	}
+	for _, indicator := range DataQualityIndicators {
+		q, args := NewQB("gha_data_quality").Set("time", *dt).Set("indicator", indicator).Set("value", values[indicator]).Upsert("time", "indicator")
+		_, err = ExecSQL(con, ctx, q, args...)
+		if err != nil {
+			return
+		}
+	}
+	ratio := values[DQEventsVsTrailingAvg]
+	if ratio < 0.5 || ratio > 2.0 {
+		Printf("Data quality anomaly at %s: %d events vs %.1f trailing average\n", ToYMDHDate(*dt), int(values[DQEventsLastHour]), avg)
+		fmt.Fprintf(os.Stderr, "Data quality anomaly at %s: %d events vs %.1f trailing average\n", ToYMDHDate(*dt), int(values[DQEventsLastHour]), avg)
+	}
+	if ctx.Debug > 0 {
+		Printf("Data quality at %s: %+v\n", ToYMDHDate(*dt), values)
+	}
+	return
+}
@@ -0,0 +1,135 @@
+package devstatscode
+
+import (
+	// Embed context.go source, Ctx fields comments are the settings documentation
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed context.go
+var contextSource string
+
+// CtxSetting - single Ctx setting description returned by Ctx.Describe()
+type CtxSetting struct {
+	Field       string   `json:"field"`       // Ctx field name, for example "DataDir"
+	Env         []string `json:"env"`         // environment variables that set this field, for example ["GHA2DB_DATADIR"], empty for internal fields
+	Type        string   `json:"type"`        // Go type, for example "string"
+	Default     string   `json:"default"`     // default value as documented, empty if not documented
+	Value       string   `json:"value"`       // current value, secrets are masked
+	Description string   `json:"description"` // full field comment
+}
+
+var (
+	ctxDocsOnce sync.Once
+	ctxDocs     map[string]string
+	// envVarRe - matches environment variable names in Ctx fields comments
+	envVarRe = regexp.MustCompile(`\b[A-Z][A-Z0-9]*_[A-Z0-9_]*[A-Z0-9]\b`)
+	// defaultRe - matches documented default value: "default X", value ends at the first ',', ';', '(' or sentence end
+	defaultRe = regexp.MustCompile(`(?i)\bdefaults?(?: is| to|:)?\s+("[^"]*"|[^,;(]+)`)
+	// secretRe - settings with environment variables matching this are never returned with their values
+	secretRe = regexp.MustCompile(`PASS|OAUTH|TOKEN|SECRET`)
+)
+
+// ctxFieldsDocs - returns Ctx field name -> comment map parsed from embedded context.go
+func ctxFieldsDocs() map[string]string {
+	ctxDocsOnce.Do(func() {
+		ctxDocs = make(map[string]string)
+		f, err := parser.ParseFile(token.NewFileSet(), "context.go", contextSource, parser.ParseComments)
+		if err != nil {
+			Printf("Cannot parse Ctx documentation: %v\n", err)
+			return
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			ts, ok := n.(*ast.TypeSpec)
+			if !ok || ts.Name.Name != "Ctx" {
+				return true
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+			for _, field := range st.Fields.List {
+				doc := ""
+				if field.Comment != nil {
+					doc = strings.TrimSpace(field.Comment.Text())
+				} else if field.Doc != nil {
+					doc = strings.TrimSpace(field.Doc.Text())
+				}
+				for _, name := range field.Names {
+					ctxDocs[name.Name] = doc
+				}
+			}
+			return false
+		})
+	})
+	return ctxDocs
+}
+
+// settingEnvs - returns environment variables mentioned in a setting description, in order of appearance
+func settingEnvs(doc string) (envs []string) {
+	seen := make(map[string]struct{})
+	for _, env := range envVarRe.FindAllString(doc, -1) {
+		if _, ok := seen[env]; ok {
+			continue
+		}
+		seen[env] = struct{}{}
+		envs = append(envs, env)
+	}
+	return
+}
+
+// settingDefault - returns documented default value from a setting description
+func settingDefault(doc string) string {
+	m := defaultRe.FindAllStringSubmatch(doc, -1)
+	if len(m) == 0 {
+		return ""
+	}
+	def := m[len(m)-1][1]
+	if i := strings.Index(def, ". "); i >= 0 {
+		def = def[:i]
+	}
+	return strings.Trim(strings.TrimRight(strings.TrimSpace(def), ".)"), `"`)
+}
+
+// Describe - returns all Ctx settings with their environment variables, types, documented defaults and current values
+// Settings are sorted by the first environment variable name, internal settings (without environment variables) are last
+func (ctx *Ctx) Describe() (settings []CtxSetting) {
+	docs := ctxFieldsDocs()
+	v := reflect.ValueOf(ctx).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		doc := docs[field.Name]
+		s := CtxSetting{
+			Field:       field.Name,
+			Env:         settingEnvs(doc),
+			Type:        field.Type.String(),
+			Default:     settingDefault(doc),
+			Value:       fmt.Sprintf("%v", v.Field(i).Interface()),
+			Description: doc,
+		}
+		if secretRe.MatchString(strings.Join(s.Env, " ")) && s.Value != "" {
+			s.Value = "***"
+		}
+		settings = append(settings, s)
+	}
+	sort.SliceStable(settings, func(i, j int) bool {
+		ei, ej := settings[i].Env, settings[j].Env
+		if len(ei) == 0 || len(ej) == 0 {
+			return len(ei) > len(ej)
+		}
+		return ei[0] < ej[0]
+	})
+	return
+}
@@ -0,0 +1,87 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestDescribe(t *testing.T) {
+	var ctx lib.Ctx
+	ctx.DataDir = "/data/"
+	ctx.PgPass = "secret"
+	settings := ctx.Describe()
+
+	// All exported Ctx fields must be described
+	if len(settings) != reflect.TypeOf(ctx).NumField() {
+		t.Errorf("expected %d settings, got %d", reflect.TypeOf(ctx).NumField(), len(settings))
+	}
+	byField := make(map[string]lib.CtxSetting)
+	for _, s := range settings {
+		byField[s.Field] = s
+	}
+
+	// Test cases
+	var testCases = []struct {
+		field    string
+		expected lib.CtxSetting
+	}{
+		{
+			field: "DataDir",
+			expected: lib.CtxSetting{
+				Field:   "DataDir",
+				Env:     []string{"GHA2DB_DATADIR"},
+				Type:    "string",
+				Default: "/etc/gha2db/",
+				Value:   "/data/",
+			},
+		},
+		{
+			field: "PgPass",
+			expected: lib.CtxSetting{
+				Field:   "PgPass",
+				Env:     []string{"PG_PASS"},
+				Type:    "string",
+				Default: "password",
+				Value:   "***",
+			},
+		},
+		{
+			field: "NCPUs",
+			expected: lib.CtxSetting{
+				Field:   "NCPUs",
+				Env:     []string{"GHA2DB_NCPUS", "GHA2DB_ST"},
+				Type:    "int",
+				Default: "0",
+				Value:   "0",
+			},
+		},
+		{
+			field: "ExecFatal",
+			expected: lib.CtxSetting{
+				Field:   "ExecFatal",
+				Type:    "bool",
+				Default: "true",
+				Value:   "false",
+			},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got, ok := byField[test.field]
+		if !ok {
+			t.Errorf("test number %d, field %s not described", index+1, test.field)
+			continue
+		}
+		got.Description = ""
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %+v, got %+v", index+1, test.expected, got)
+		}
+	}
+
+	// Settings without environment variables are listed last
+	if len(settings[0].Env) == 0 || len(settings[len(settings)-1].Env) != 0 {
+		t.Errorf("expected settings with environment variables first, got %+v ... %+v", settings[0], settings[len(settings)-1])
+	}
+}
@@ -0,0 +1,28 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  API_URL="http://127.0.0.1:8080/api/v1"
+fi
+project="${1}"
+range="${2}"
+repository_group="${3}"
+metric="${4}"
+if [ -z "$range" ]
+then
+  range='Last decade'
+fi
+if [ -z "$repository_group" ]
+then
+  repository_group='All'
+fi
+if [ -z "$metric" ]
+then
+  metric='Contributions'
+fi
+# PERCENT=80
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"BusFactor\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"repository_group\":\"${repository_group}\",\"metric\":\"${metric}\",\"percent\":\"${PERCENT}\",\"bg\":\"${BG}\"}}" 2>/dev/null | jq -rS .
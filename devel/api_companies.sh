@@ -9,4 +9,7 @@ then
   API_URL="http://127.0.0.1:8080/api/v1"
 fi
 project="${1}"
-curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Companies\",\"payload\":{\"project\":\"${project}\"}}" 2>/dev/null | jq
+query="${2}"
+limit="${3}"
+prefix="${4}"
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Companies\",\"payload\":{\"project\":\"${project}\",\"query\":\"${query}\",\"limit\":\"${limit}\",\"prefix\":\"${prefix}\"}}" 2>/dev/null | jq
@@ -19,4 +19,4 @@ if [ -z "$metric" ]
 then
   metric='Contributions'
 fi
-curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"CompaniesTable\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"metric\":\"${metric}\"}}" 2>/dev/null | jq
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"CompaniesTable\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"metric\":\"${metric}\",\"bucket\":\"${BUCKET}\"}}" 2>/dev/null | jq
@@ -0,0 +1,23 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if [ -z "$2" ]
+then
+  echo "$0: please specify company name as a 2nd arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  API_URL="http://127.0.0.1:8080/api/v1"
+fi
+project="${1}"
+company="${2}"
+range="${3}"
+if [ -z "$range" ]
+then
+  range='Last year'
+fi
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"CompanyProfile\",\"payload\":{\"project\":\"${project}\",\"company\":\"${company}\",\"range\":\"${range}\"}}" 2>/dev/null | jq
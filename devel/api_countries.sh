@@ -14,4 +14,9 @@ if [ ! -z "$2" ]
 then
   raw="${2}"
 fi
-curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Countries\",\"payload\":{\"project\":\"${project}\",\"raw\":\"${raw}\"}}" 2>/dev/null | jq
+query="${3}"
+limit="${4}"
+prefix="${5}"
+# LANG_TAG=de
+lang="${LANG_TAG}"
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Countries\",\"payload\":{\"project\":\"${project}\",\"raw\":\"${raw}\",\"query\":\"${query}\",\"limit\":\"${limit}\",\"prefix\":\"${prefix}\",\"lang\":\"${lang}\"}}" 2>/dev/null | jq
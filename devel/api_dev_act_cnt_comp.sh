@@ -39,4 +39,8 @@ if [ -z "$github_id" ]
 then
   github_id=''
 fi
-curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"DevActCntComp\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"metric\":\"${metric}\",\"repository_group\":\"${repository_group}\",\"country\":\"${country}\",\"companies\":${companies},\"github_id\":\"${github_id}\",\"bg\":\"${BG}\"}}" 2>/dev/null | jq -rS .
+if [ -z "$EXCLUDE" ]
+then
+  EXCLUDE='[]'
+fi
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"DevActCntComp\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"metric\":\"${metric}\",\"repository_group\":\"${repository_group}\",\"country\":\"${country}\",\"companies\":${companies},\"github_id\":\"${github_id}\",\"exclude_companies\":${EXCLUDE},\"independent_only\":\"${INDEPENDENT}\",\"bg\":\"${BG}\"}}" 2>/dev/null | jq -rS .
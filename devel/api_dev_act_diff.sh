@@ -0,0 +1,46 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  API_URL="http://127.0.0.1:8080/api/v1"
+fi
+project="${1}"
+range="${2}"
+previous_range="${3}"
+metric="${4}"
+repository_group="${5}"
+country="${6}"
+if [ -z "$range" ]
+then
+  range='Last quarter'
+fi
+if [ -z "$previous_range" ]
+then
+  previous_range='range:2020-07-01,2020-10-01'
+fi
+if [ -z "$metric" ]
+then
+  metric='Contributions'
+fi
+if [ -z "$repository_group" ]
+then
+  repository_group='All'
+fi
+if [ -z "$country" ]
+then
+  country='All'
+fi
+extra=""
+if [ ! -z "$SORT" ]
+then
+  extra="${extra},\"sort\":\"${SORT}\""
+fi
+if [ ! -z "$LIMIT" ]
+then
+  extra="${extra},\"limit\":\"${LIMIT}\""
+fi
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"DevActDiff\",\"payload\":{\"project\":\"${project}\",\"range\":\"${range}\",\"previous_range\":\"${previous_range}\",\"metric\":\"${metric}\",\"repository_group\":\"${repository_group}\",\"country\":\"${country}\",\"bg\":\"${BG}\"${extra}}}" 2>/dev/null | jq -rS .
@@ -0,0 +1,54 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if [ -z "$2" ]
+then
+  echo "$0: please specify from date as a 2nd arg"
+  exit 2
+fi
+if [ -z "$3" ]
+then
+  echo "$0: please specify to date as a 3rd arg"
+  exit 3
+fi
+if [ -z "$ADMIN_TOKEN" ]
+then
+  echo "$0: please specify admin token via ADMIN_TOKEN=..."
+  exit 4
+fi
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+project="${1}"
+from="${2}"
+to="${3}"
+format="${4:-jsonl}"
+tables=""
+for table in "${@:5}"
+do
+  if [ -z "$tables" ]
+  then
+    tables="\"${table}\""
+  else
+    tables="${tables},\"${table}\""
+  fi
+done
+if [ -z "$tables" ]
+then
+  tables='"summary"'
+fi
+if [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" -H "Authorization: Bearer ${ADMIN_TOKEN}" "${API_URL}" -d"{\"api\":\"Export\",\"payload\":{\"project\":\"${project}\",\"from\":\"${from}\",\"to\":\"${to}\",\"format\":\"${format}\",\"tables\":[${tables}]}}"
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" -H "Authorization: Bearer ..." "${API_URL}" -d"{\"api\":\"Export\",\"payload\":{\"project\":\"${project}\",\"from\":\"${from}\",\"to\":\"${to}\",\"format\":\"${format}\",\"tables\":[${tables}]}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" -H "Authorization: Bearer ${ADMIN_TOKEN}" "${API_URL}" -d"{\"api\":\"Export\",\"payload\":{\"project\":\"${project}\",\"from\":\"${from}\",\"to\":\"${to}\",\"format\":\"${format}\",\"tables\":[${tables}]}}"
+fi
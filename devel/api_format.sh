@@ -0,0 +1,18 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if [ -z "$API_URL" ]
+then
+  API_URL="http://127.0.0.1:8080/api/v1"
+fi
+project="${1}"
+if [ ! -z "$CALLBACK" ]
+then
+  # JSONP is used by <script> tags, so it is called via GET
+  curl -G "${API_URL}" --data-urlencode "api=Health" --data-urlencode "payload={\"project\":\"${project}\"}" --data-urlencode "pretty=true" --data-urlencode "callback=${CALLBACK}"
+  exit $?
+fi
+curl -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Health\",\"payload\":{\"project\":\"${project}\",\"pretty\":true}}"
@@ -0,0 +1,33 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if ( [ -z "$2" ] && [ -z "$3" ] )
+then
+  echo "$0: please specify repository name as a 2nd arg or repository group as a 3rd arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+project="${1}"
+if [ -z "$2" ]
+then
+  extra="\"repository_group\":\"${3}\""
+else
+  extra="\"repository\":\"${2}\""
+fi
+if [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"RepoStats\",\"payload\":{\"project\":\"${project}\",${extra}}}" | jq
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"RepoStats\",\"payload\":{\"project\":\"${project}\",${extra}}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"RepoStats\",\"payload\":{\"project\":\"${project}\",${extra}}}"
+fi
@@ -0,0 +1,51 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if [ -z "$2" ]
+then
+  echo "$0: please specify metric name as a 2nd arg"
+  exit 2
+fi
+if [ -z "$3" ]
+then
+  echo "$0: please specify period as a 3rd arg"
+  exit 3
+fi
+if [ -z "$4" ]
+then
+  echo "$0: please specify timestamp from as a 4th arg"
+  exit 4
+fi
+if [ -z "$5" ]
+then
+  echo "$0: please specify timestamp to as a 5th arg"
+  exit 5
+fi
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+project="${1}"
+metric="${2}"
+period="${3}"
+from="${4}"
+to="${5}"
+rg=""
+if [ ! -z "$6" ]
+then
+  rg=",\"repository_group\":\"${6}\""
+fi
+if [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SeriesQuery\",\"payload\":{\"project\":\"${project}\",\"metric\":\"${metric}\",\"period\":\"${period}\",\"from\":\"${from}\",\"to\":\"${to}\"${rg}}}" | jq
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SeriesQuery\",\"payload\":{\"project\":\"${project}\",\"metric\":\"${metric}\",\"period\":\"${period}\",\"from\":\"${from}\",\"to\":\"${to}\"${rg}}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SeriesQuery\",\"payload\":{\"project\":\"${project}\",\"metric\":\"${metric}\",\"period\":\"${period}\",\"from\":\"${from}\",\"to\":\"${to}\"${rg}}}"
+fi
@@ -0,0 +1,18 @@
+#!/bin/bash
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+projects="${1}"
+stale="${STALE_MINUTES:-180}"
+if [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SyncStatus\",\"payload\":{\"projects\":[${projects}],\"stale_minutes\":\"${stale}\"}}" | jq
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SyncStatus\",\"payload\":{\"projects\":[${projects}],\"stale_minutes\":\"${stale}\"}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"SyncStatus\",\"payload\":{\"projects\":[${projects}],\"stale_minutes\":\"${stale}\"}}"
+fi
@@ -0,0 +1,33 @@
+#!/bin/bash
+if [ -z "$1" ]
+then
+  echo "$0: please specify from date as a 1st arg"
+  exit 1
+fi
+if [ -z "$2" ]
+then
+  echo "$0: please specify to date as a 2nd arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+from="${1}"
+to="${2}"
+projects="${3}"
+format="${FORMAT:-json}"
+if [ "$format" = "csv" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Velocity\",\"payload\":{\"from\":\"${from}\",\"to\":\"${to}\",\"projects\":[${projects}],\"format\":\"csv\"}}"
+elif [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Velocity\",\"payload\":{\"from\":\"${from}\",\"to\":\"${to}\",\"projects\":[${projects}]}}" | jq
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Velocity\",\"payload\":{\"from\":\"${from}\",\"to\":\"${to}\",\"projects\":[${projects}]}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"Velocity\",\"payload\":{\"from\":\"${from}\",\"to\":\"${to}\",\"projects\":[${projects}]}}"
+fi
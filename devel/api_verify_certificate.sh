@@ -0,0 +1,28 @@
+#!/bin/bash
+# Gets a certificate via Certificate API and verifies it via VerifyCertificate API
+if [ -z "$1" ]
+then
+  echo "$0: please specify project name as a 1st arg"
+  exit 1
+fi
+if [ -z "$2" ]
+then
+  echo "$0: please specify GitHub login as a 2nd arg"
+  exit 2
+fi
+if [ -z "$API_URL" ]
+then
+  export API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ -z "$ORIGIN" ]
+then
+  export ORIGIN='https://teststats.cncf.io'
+fi
+cert=$(DEBUG='' ./devel/api_certificate.sh "$@" | jq -c '{certificate: .certificate, signature: .signature}')
+if [ -z "$DEBUG" ]
+then
+  curl -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"VerifyCertificate\",\"payload\":${cert}}" | jq
+else
+  echo curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"VerifyCertificate\",\"payload\":${cert}}"
+  curl -i -s -H "Origin: ${ORIGIN}" -H "Content-Type: application/json" "${API_URL}" -d"{\"api\":\"VerifyCertificate\",\"payload\":${cert}}"
+fi
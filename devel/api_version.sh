@@ -0,0 +1,15 @@
+#!/bin/bash
+# HEAD=1 - only print response headers
+if [ -z "$API_URL" ]
+then
+  API_URL="http://127.0.0.1:8080/api/v1"
+fi
+if [ ! -z "$HEAD" ]
+then
+  curl -I "${API_URL}/version"
+elif [ ! -z "$RAW" ]
+then
+  curl "${API_URL}/version"
+else
+  curl "${API_URL}/version" 2>/dev/null | jq
+fi
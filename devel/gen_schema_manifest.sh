@@ -0,0 +1,39 @@
+#!/bin/bash
+# Regenerates schema_manifest.go from a reference database created by the current structure tool
+# TABLES='gha_events gha_payloads' - tables to include, default are tables already listed in schema_manifest.go
+if [ -z "$1" ]
+then
+  echo "$0: please specify reference database name as a 1st arg (created by the current structure tool)"
+  exit 1
+fi
+if [ -z "$TABLES" ]
+then
+  TABLES=`grep -oE '^\s+"gha_[a-z_]+"' schema_manifest.go | tr -d ' \t"'`
+fi
+if [ -z "$TABLES" ]
+then
+  echo "$0: no tables specified, please set TABLES='gha_events gha_payloads ...'"
+  exit 2
+fi
+list=''
+for table in $TABLES
+do
+  list="${list}'${table}',"
+done
+list="${list%,}"
+cols=`./devel/db.sh psql "$1" -tA -F' ' -c "select table_name, string_agg('\"' || column_name || '\"', ', ' order by ordinal_position) from information_schema.columns where table_schema = 'public' and table_name in (${list}) group by table_name order by table_name"` || exit 3
+(
+  echo '// Code generated by devel/gen_schema_manifest.sh; DO NOT EDIT.'
+  echo ''
+  echo 'package devstatscode'
+  echo ''
+  echo '// SchemaManifest - columns of tables written by gha2db, as created by structure tool'
+  echo 'var SchemaManifest = map[string][]string{'
+  echo "$cols" | while read table columns
+  do
+    echo "\"${table}\": {${columns}},"
+  done
+  echo '}'
+) > schema_manifest.go || exit 4
+gofmt -w schema_manifest.go || exit 5
+echo "schema_manifest.go: `echo "$cols" | wc -l` tables"
@@ -3,14 +3,20 @@ package devstatscode
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
 	"time"
+
+	yaml "gopkg.in/yaml.v2"
 )
 
 var gEnvMap map[string]string = make(map[string]string)
 
+// gConfigMap - env variables set from GHA2DB_CONFIG file (name -> value set)
+var gConfigMap map[string]string = make(map[string]string)
+
 // EnvSyncer - support auto updating env variables via "env.env" file
 func EnvSyncer() {
 	for {
@@ -54,6 +60,82 @@ func UpdateEnv(useLog bool) {
 	}
 }
 
+// configValue - converts config file value to env variable value, ok is false when variable should not be set
+// Booleans are "1" or not set, lists are joined with commas (most list variables are comma separated)
+func configValue(key string, value interface{}) (string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case bool:
+		if !v {
+			return "", false, nil
+		}
+		return "1", true, nil
+	case string:
+		return v, true, nil
+	case int, int64, uint64, float64:
+		return fmt.Sprint(v), true, nil
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			s, ok, err := configValue(key, item)
+			if err != nil {
+				return "", false, err
+			}
+			if ok {
+				items = append(items, s)
+			}
+		}
+		return strings.Join(items, ","), true, nil
+	}
+	return "", false, fmt.Errorf("config key '%s': unsupported value type %T", key, value)
+}
+
+// LoadConfigFile - sets env variables from YAML (or JSON) file given in GHA2DB_CONFIG, keys are env variables names
+// Variables already set in environment (or via env.env) take precedence, values set from a previous load can be updated
+func LoadConfigFile() error {
+	path := os.Getenv("GHA2DB_CONFIG")
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config map[string]interface{}
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for key, value := range config {
+		if key == "" || key == "GHA2DB_CONFIG" {
+			continue
+		}
+		v, ok, err := configValue(key, value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		cur, set := os.LookupEnv(key)
+		if set {
+			prev, fromConfig := gConfigMap[key]
+			if !fromConfig || prev != cur {
+				continue
+			}
+		}
+		if ok {
+			err = os.Setenv(key, v)
+			gConfigMap[key] = v
+		} else {
+			err = os.Unsetenv(key)
+			delete(gConfigMap, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // EnvReplace - replace all environment variables starting with "prefix"
 // with contents of variables with "suffix" added - if defined
 // If prefix is "DB_" and suffix is "_SRC" then:
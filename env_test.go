@@ -2,6 +2,7 @@ package devstatscode
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -194,3 +195,73 @@ func TestEnv(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadConfigFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "devstats*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	_, err = file.WriteString(
+		"GHA2DB_TEST_CFG_STR: value\n" +
+			"GHA2DB_TEST_CFG_INT: 10\n" +
+			"GHA2DB_TEST_CFG_TRUE: true\n" +
+			"GHA2DB_TEST_CFG_FALSE: false\n" +
+			"GHA2DB_TEST_CFG_LIST: [a, b, 3]\n" +
+			"GHA2DB_TEST_CFG_ENV: config\n",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	keys := []string{
+		"GHA2DB_CONFIG", "GHA2DB_TEST_CFG_STR", "GHA2DB_TEST_CFG_INT", "GHA2DB_TEST_CFG_TRUE",
+		"GHA2DB_TEST_CFG_FALSE", "GHA2DB_TEST_CFG_LIST", "GHA2DB_TEST_CFG_ENV",
+	}
+	defer func() {
+		for _, key := range keys {
+			_ = os.Unsetenv(key)
+		}
+	}()
+	_ = os.Setenv("GHA2DB_CONFIG", file.Name())
+	_ = os.Setenv("GHA2DB_TEST_CFG_ENV", "env")
+	_ = os.Setenv("GHA2DB_TEST_CFG_FALSE", "")
+	if err = lib.LoadConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"GHA2DB_TEST_CFG_STR":   "value",
+		"GHA2DB_TEST_CFG_INT":   "10",
+		"GHA2DB_TEST_CFG_TRUE":  "1",
+		"GHA2DB_TEST_CFG_FALSE": "",
+		"GHA2DB_TEST_CFG_LIST":  "a,b,3",
+		"GHA2DB_TEST_CFG_ENV":   "env",
+	}
+	for key, value := range expected {
+		if got := os.Getenv(key); got != value {
+			t.Errorf("%s: expected '%s', got '%s'", key, value, got)
+		}
+	}
+
+	// Values set from config can be updated by the next load, unsupported values are errors
+	err = ioutil.WriteFile(file.Name(), []byte("GHA2DB_TEST_CFG_STR: new\nGHA2DB_TEST_CFG_ENV: config2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lib.LoadConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("GHA2DB_TEST_CFG_STR"); got != "new" {
+		t.Errorf("expected reloaded value 'new', got '%s'", got)
+	}
+	if got := os.Getenv("GHA2DB_TEST_CFG_ENV"); got != "env" {
+		t.Errorf("expected env value 'env', got '%s'", got)
+	}
+	err = ioutil.WriteFile(file.Name(), []byte("GHA2DB_TEST_CFG_STR:\n  a: b\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lib.LoadConfigFile(); err == nil {
+		t.Errorf("expected error for map value")
+	}
+}
@@ -64,7 +64,7 @@ func FatalOnError(err error) string {
 		if os.Getenv("NO_FATAL_DELAY") == "" {
 			time.Sleep(time.Duration(60) * time.Second)
 		}
-		panic(fmt.Sprintf("stacktrace: %+v", err))
+		panic(&fatalError{err: err})
 	}
 	return OK
 }
@@ -74,13 +74,22 @@ func Fatalf(f string, a ...interface{}) {
 	FatalOnError(fmt.Errorf(f, a...))
 }
 
+// FatalfWithCode - it will call FatalOnError using fmt.Errorf with args provided, tool will exit with a given exit code
+func FatalfWithCode(code int, f string, a ...interface{}) {
+	FatalOnError(ExitErrorf(code, f, a...))
+}
+
 // FatalNoLog displays error message (if error present) and exits program, should be used for very early init state
+// Unclassified errors are config errors here (invalid environment variables)
 func FatalNoLog(err error) string {
 	if err != nil {
 		tm := time.Now()
 		fmt.Fprintf(os.Stderr, "Error(time=%+v):\nError: '%s'\nStacktrace:\n", tm, err.Error())
 		time.Sleep(time.Duration(60) * time.Second)
-		panic(fmt.Sprintf("stacktrace: %+v", err))
+		if ExitCode(err) == ExitError {
+			err = WithExitCode(ExitConfig, err)
+		}
+		panic(&fatalError{err: err})
 	}
 	return OK
 }
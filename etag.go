@@ -0,0 +1,40 @@
+package devstatscode
+
+import "strings"
+
+// ETagMatch - checks if If-None-Match header value matches a given ETag (RFC 7232 section 3.2)
+// Header is "*" (matches any ETag) or a comma separated list of entity tags, they are compared using weak comparison,
+// so W/ prefixes are ignored. Invalid list elements are skipped
+func ETagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	if etag == "" {
+		return false
+	}
+	s := strings.TrimSpace(ifNoneMatch)
+	if s == "*" {
+		return true
+	}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return false
+		}
+		s = strings.TrimPrefix(s, "W/")
+		if !strings.HasPrefix(s, `"`) {
+			i := strings.IndexByte(s, ',')
+			if i < 0 {
+				return false
+			}
+			s = s[i+1:]
+			continue
+		}
+		end := strings.IndexByte(s[1:], '"')
+		if end < 0 {
+			return false
+		}
+		if s[:end+2] == etag {
+			return true
+		}
+		s = s[end+2:]
+	}
+}
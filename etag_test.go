@@ -0,0 +1,40 @@
+package devstatscode
+
+import (
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestETagMatch(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		ifNoneMatch string
+		etag        string
+		expected    bool
+	}{
+		{ifNoneMatch: `"abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"abd"`, etag: `"abc"`, expected: false},
+		{ifNoneMatch: `*`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: ` * `, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `*`, etag: ``, expected: false},
+		{ifNoneMatch: `"x", "abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"x","y"`, etag: `"abc"`, expected: false},
+		{ifNoneMatch: `W/"abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"abc"`, etag: `W/"abc"`, expected: true},
+		{ifNoneMatch: `"x" ,W/"abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"a,b", "abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `"a,b"`, etag: `"b"`, expected: false},
+		{ifNoneMatch: `abc, "abc"`, etag: `"abc"`, expected: true},
+		{ifNoneMatch: `abc`, etag: `abc`, expected: false},
+		{ifNoneMatch: `"abc`, etag: `"abc"`, expected: false},
+		{ifNoneMatch: ``, etag: `"abc"`, expected: false},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ETagMatch(test.ifNoneMatch, test.etag)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %v, got %v for '%s' and '%s'", index+1, test.expected, got, test.ifNoneMatch, test.etag)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package devstatscode
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultIssueEventTypes - issue event types synced by ghapi2db when no allow list is configured
+var DefaultIssueEventTypes = []string{
+	"closed",
+	"merged",
+	"referenced",
+	"reopened",
+	"locked",
+	"unlocked",
+	"renamed",
+	"mentioned",
+	"assigned",
+	"unassigned",
+	"labeled",
+	"unlabeled",
+	"milestoned",
+	"demilestoned",
+	"subscribed",
+	"unsubscribed",
+	"head_ref_deleted",
+	"head_ref_restored",
+	"review_requested",
+	"review_dismissed",
+	"review_request_removed",
+	"added_to_project",
+	"removed_from_project",
+	"moved_columns_in_project",
+	"marked_as_duplicate",
+	"unmarked_as_duplicate",
+	"converted_note_to_issue",
+	// Non specified in GH API but happenning
+	"base_ref_changed",
+	"comment_deleted",
+	"deployed",
+	"transferred",
+	"head_ref_force_pushed",
+	"pinned",
+	"unpinned",
+	"ready_for_review",
+	"base_ref_force_pushed",
+	"connected",
+	"disconnected",
+	"convert_to_draft",
+	"base_ref_deleted",
+	"automatic_base_change_succeeded",
+	"automatic_base_change_failed",
+	"auto_merge_enabled",
+	"auto_merge_disabled",
+	"auto_squash_enabled",
+	"auto_squash_disabled",
+	"auto_rebase_enabled",
+	"auto_rebase_disabled",
+	"user_blocked",
+	"sync",
+	"converted_to_discussion",
+}
+
+// EventTypesList - holds issue event types lists (GHA2DB_EVENT_TYPES_YAML)
+// "*" on the allow list means all event types that are not on the deny list
+type EventTypesList struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// SkippedEventType - number of events of a given type skipped by EventTypesFilter
+// Unknown is set when type was neither on the allow nor on the deny list
+type SkippedEventType struct {
+	Type    string
+	Events  int
+	Unknown bool
+}
+
+// EventTypesFilter - decides which issue event types are synced and counts skipped events per type
+// It is safe to use from multiple goroutines
+type EventTypesFilter struct {
+	all     bool
+	allow   map[string]struct{}
+	deny    map[string]struct{}
+	skipped map[string]int
+	mtx     sync.Mutex
+}
+
+// NewEventTypesFilter - creates event types filter, empty allow list means DefaultIssueEventTypes
+func NewEventTypesFilter(allow, deny []string) *EventTypesFilter {
+	if len(allow) == 0 {
+		allow = DefaultIssueEventTypes
+	}
+	f := &EventTypesFilter{
+		allow:   make(map[string]struct{}),
+		deny:    make(map[string]struct{}),
+		skipped: make(map[string]int),
+	}
+	for _, eventType := range allow {
+		if eventType == "*" {
+			f.all = true
+			continue
+		}
+		f.allow[eventType] = struct{}{}
+	}
+	for _, eventType := range deny {
+		f.deny[eventType] = struct{}{}
+	}
+	return f
+}
+
+// unknown - event type is neither allowed nor denied explicitly
+func (f *EventTypesFilter) unknown(eventType string) bool {
+	if f.all {
+		return false
+	}
+	_, allowed := f.allow[eventType]
+	_, denied := f.deny[eventType]
+	return !allowed && !denied
+}
+
+// Allowed - returns true if events of a given type should be synced, otherwise counts event as skipped
+// first is true for the first skipped event of a type that is neither on the allow nor on the deny list (caller logs it once)
+func (f *EventTypesFilter) Allowed(eventType string) (allowed, first bool) {
+	if _, denied := f.deny[eventType]; !denied {
+		if _, ok := f.allow[eventType]; ok || f.all {
+			return true, false
+		}
+	}
+	f.mtx.Lock()
+	f.skipped[eventType]++
+	n := f.skipped[eventType]
+	f.mtx.Unlock()
+	return false, n == 1 && f.unknown(eventType)
+}
+
+// Skipped - returns skipped events counts per type, most skipped first
+func (f *EventTypesFilter) Skipped() (skipped []SkippedEventType) {
+	f.mtx.Lock()
+	for eventType, events := range f.skipped {
+		skipped = append(skipped, SkippedEventType{Type: eventType, Events: events, Unknown: f.unknown(eventType)})
+	}
+	f.mtx.Unlock()
+	sort.Slice(skipped, func(i, j int) bool {
+		if skipped[i].Events == skipped[j].Events {
+			return skipped[i].Type < skipped[j].Type
+		}
+		return skipped[i].Events > skipped[j].Events
+	})
+	return
+}
@@ -0,0 +1,79 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestEventTypesFilter(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		allow           []string
+		deny            []string
+		events          []string
+		expectedAllowed []bool
+		expectedFirst   []bool
+		expectedSkipped []lib.SkippedEventType
+	}{
+		{
+			events:          []string{"closed", "labeled", "new_type", "new_type"},
+			expectedAllowed: []bool{true, true, false, false},
+			expectedFirst:   []bool{false, false, true, false},
+			expectedSkipped: []lib.SkippedEventType{{Type: "new_type", Events: 2, Unknown: true}},
+		},
+		{
+			deny:            []string{"subscribed"},
+			events:          []string{"subscribed", "closed", "subscribed", "other"},
+			expectedAllowed: []bool{false, true, false, false},
+			expectedFirst:   []bool{false, false, false, true},
+			expectedSkipped: []lib.SkippedEventType{{Type: "subscribed", Events: 2}, {Type: "other", Events: 1, Unknown: true}},
+		},
+		{
+			allow:           []string{"*"},
+			deny:            []string{"mentioned"},
+			events:          []string{"new_type", "mentioned", "closed", "mentioned"},
+			expectedAllowed: []bool{true, false, true, false},
+			expectedFirst:   []bool{false, false, false, false},
+			expectedSkipped: []lib.SkippedEventType{{Type: "mentioned", Events: 2}},
+		},
+		{
+			allow:           []string{"closed", "reopened"},
+			events:          []string{"closed", "labeled", "reopened", "unlabeled"},
+			expectedAllowed: []bool{true, false, true, false},
+			expectedFirst:   []bool{false, true, false, true},
+			expectedSkipped: []lib.SkippedEventType{{Type: "labeled", Events: 1, Unknown: true}, {Type: "unlabeled", Events: 1, Unknown: true}},
+		},
+		{
+			allow:           []string{"closed"},
+			deny:            []string{"closed"},
+			events:          []string{"closed"},
+			expectedAllowed: []bool{false},
+			expectedFirst:   []bool{false},
+			expectedSkipped: []lib.SkippedEventType{{Type: "closed", Events: 1}},
+		},
+		{
+			events:          []string{"closed"},
+			expectedAllowed: []bool{true},
+			expectedFirst:   []bool{false},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		f := lib.NewEventTypesFilter(test.allow, test.deny)
+		for i, eventType := range test.events {
+			allowed, first := f.Allowed(eventType)
+			if allowed != test.expectedAllowed[i] || first != test.expectedFirst[i] {
+				t.Errorf(
+					"test number %d, event %d (%s), expected allowed=%v first=%v, got allowed=%v first=%v",
+					index+1, i+1, eventType, test.expectedAllowed[i], test.expectedFirst[i], allowed, first,
+				)
+			}
+		}
+		got := f.Skipped()
+		if !reflect.DeepEqual(got, test.expectedSkipped) {
+			t.Errorf("test number %d, expected skipped %+v, got %+v", index+1, test.expectedSkipped, got)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EventProject - returns project that added event with a given ID, ok is false when there is no such event
+// Events added before gha_events.project was used have an empty project
+func EventProject(c *sql.DB, ctx *Ctx, eventID string) (project string, ok bool) {
+	err := QueryRowSQL(c, ctx, fmt.Sprintf("select project from gha_events where id = %s", NValue(1)), eventID).Scan(&project)
+	if err == sql.ErrNoRows {
+		return
+	}
+	FatalOnError(err)
+	ok = true
+	return
+}
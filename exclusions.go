@@ -0,0 +1,111 @@
+package devstatscode
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Reasons of events exclusion, see ExclusionReason
+const (
+	ExcludedOrg   = "org"
+	ExcludedActor = "actor"
+)
+
+// ExclusionsList - holds orgs and actors excluded at ingest (GHA2DB_EXCLUSIONS_YAML)
+// Orgs are case insensitive glob patterns matching repo owner ("*-mirror") or full repo name when they contain "/" ("spam-org/*")
+// Actors are regexps matching actor login ("^crypto-[a-z]+-bot$")
+type ExclusionsList struct {
+	Orgs   []string `yaml:"orgs"`
+	Actors []string `yaml:"actors"`
+}
+
+// Exclusions - orgs/repos and actors whose events are skipped by RepoHit and ActorHit (spammy mirror orgs, spam bots)
+// It is read only, so it is safe to use from multiple goroutines, nil Exclusions excludes nothing
+type Exclusions struct {
+	orgs   []string
+	actors []*regexp.Regexp
+}
+
+// NewExclusions - validates patterns and compiles regexps of a given exclusions list
+func NewExclusions(list ExclusionsList) (*Exclusions, error) {
+	e := &Exclusions{}
+	for _, pattern := range list.Orgs {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid excluded orgs pattern '%s': %v", pattern, err)
+		}
+		e.orgs = append(e.orgs, pattern)
+	}
+	for _, expr := range list.Actors {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded actors regexp '%s': %v", expr, err)
+		}
+		e.actors = append(e.actors, re)
+	}
+	return e, nil
+}
+
+// LoadExclusions - reads GHA2DB_EXCLUSIONS_YAML, returns nil when it is not set
+func LoadExclusions(ctx *Ctx) *Exclusions {
+	if ctx.ExclusionsYaml == "" {
+		return nil
+	}
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	data, err := ReadFile(ctx, dataPrefix+ctx.ExclusionsYaml)
+	FatalOnError(err)
+	var list ExclusionsList
+	FatalOnError(yaml.Unmarshal(data, &list))
+	exclusions, err := NewExclusions(list)
+	FatalOnError(WithExitCode(ExitConfig, err))
+	Printf("Excluding events of %d org patterns and %d actor regexps from %s\n", len(exclusions.orgs), len(exclusions.actors), ctx.ExclusionsYaml)
+	return exclusions
+}
+
+// RepoExcluded - is given repo (org/repo or pre-2015 repo name without org) excluded?
+func (e *Exclusions) RepoExcluded(fullName string) bool {
+	if e == nil || len(e.orgs) == 0 {
+		return false
+	}
+	fullName = strings.ToLower(fullName)
+	owner := ""
+	if i := strings.Index(fullName, "/"); i >= 0 {
+		owner = fullName[:i]
+	}
+	for _, pattern := range e.orgs {
+		name := owner
+		if strings.Contains(pattern, "/") {
+			name = fullName
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorExcluded - is given actor login excluded?
+func (e *Exclusions) ActorExcluded(login string) bool {
+	if e == nil {
+		return false
+	}
+	for _, re := range e.actors {
+		if re.MatchString(login) {
+			return true
+		}
+	}
+	return false
+}
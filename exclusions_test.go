@@ -0,0 +1,110 @@
+package devstatscode
+
+import (
+	"regexp"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestExclusions(t *testing.T) {
+	exclusions, err := lib.NewExclusions(
+		lib.ExclusionsList{
+			Orgs:   []string{"*-Mirror", "spam-org", "kubernetes/spam-*", " "},
+			Actors: []string{"^crypto-[a-z]+-bot$", "(?i)^airdrop"},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Test cases
+	var testCases = []struct {
+		fullName string
+		expected bool
+	}{
+		{fullName: "github-mirror/linux", expected: true},
+		{fullName: "GitHub-MIRROR/linux", expected: true},
+		{fullName: "spam-org/repo", expected: true},
+		{fullName: "spam-org2/repo", expected: false},
+		{fullName: "kubernetes/spam-repo", expected: true},
+		{fullName: "kubernetes/kubernetes", expected: false},
+		{fullName: "mirror", expected: false},
+		{fullName: "", expected: false},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := exclusions.RepoExcluded(test.fullName)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %v, got %v for %s", index+1, test.expected, got, test.fullName)
+		}
+	}
+	var actorTestCases = []struct {
+		login    string
+		expected bool
+	}{
+		{login: "crypto-coin-bot", expected: true},
+		{login: "crypto-coin-bot2", expected: false},
+		{login: "AirDropper", expected: true},
+		{login: "lukaszgryglicki", expected: false},
+	}
+	for index, test := range actorTestCases {
+		got := exclusions.ActorExcluded(test.login)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %v, got %v for %s", index+1, test.expected, got, test.login)
+		}
+	}
+
+	// Nil exclusions exclude nothing
+	var none *lib.Exclusions
+	if none.RepoExcluded("spam-org/repo") || none.ActorExcluded("crypto-coin-bot") {
+		t.Errorf("expected nil exclusions to exclude nothing")
+	}
+
+	// Invalid patterns
+	if _, err := lib.NewExclusions(lib.ExclusionsList{Orgs: []string{"[spam"}}); err == nil {
+		t.Errorf("expected error for invalid orgs pattern")
+	}
+	if _, err := lib.NewExclusions(lib.ExclusionsList{Actors: []string{"(bot"}}); err == nil {
+		t.Errorf("expected error for invalid actors regexp")
+	}
+}
+
+func TestExclusionsHits(t *testing.T) {
+	exclusions, err := lib.NewExclusions(lib.ExclusionsList{Orgs: []string{"spam-*"}, Actors: []string{"^spam-bot$"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ctx lib.Ctx
+	ctx.Exclusions = exclusions
+	forg := map[string]struct{}{"kubernetes": {}, "spam-org": {}}
+	// Test cases
+	var testCases = []struct {
+		fullName  string
+		actorName string
+		forg      map[string]struct{}
+		orgRE     *regexp.Regexp
+		hit       bool
+		reason    string
+	}{
+		{fullName: "kubernetes/kubernetes", actorName: "user", forg: forg, hit: true},
+		{fullName: "spam-org/repo", actorName: "user", forg: forg, reason: lib.ExcludedOrg},
+		{fullName: "kubernetes/kubernetes", actorName: "spam-bot", forg: forg, reason: lib.ExcludedActor},
+		{fullName: "spam-org/repo", actorName: "spam-bot", forg: forg, reason: lib.ExcludedOrg},
+		// Events not matching project filters are not counted as excluded
+		{fullName: "spam-other/repo", actorName: "user", forg: forg},
+		{fullName: "other/repo", actorName: "spam-bot", forg: forg},
+		{fullName: "spam-other/repo", actorName: "user", orgRE: regexp.MustCompile(`^spam-`), reason: lib.ExcludedOrg},
+		{fullName: "other/repo", actorName: "spam-bot", reason: lib.ExcludedActor},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		hit := lib.RepoHit(&ctx, test.fullName, test.forg, nil, test.orgRE, nil) && lib.ActorHit(&ctx, test.actorName)
+		if hit != test.hit {
+			t.Errorf("test number %d, expected hit %v, got %v", index+1, test.hit, hit)
+		}
+		reason := lib.ExclusionReason(&ctx, test.fullName, test.actorName, test.forg, nil, test.orgRE, nil)
+		if reason != test.reason {
+			t.Errorf("test number %d, expected reason '%s', got '%s'", index+1, test.reason, reason)
+		}
+	}
+}
@@ -19,7 +19,19 @@ func logCommand(ctx *Ctx, cmdAndArgs []string, env map[string]string) {
 }
 
 // ExecCommand - execute command given by array of strings with eventual environment map
+// When tracing is enabled command runs in its own span, which is passed to the command in TRACEPARENT
 func ExecCommand(ctx *Ctx, cmdAndArgs []string, env map[string]string) (string, error) {
+	if !gTracing {
+		return execCommand(ctx, cmdAndArgs, env)
+	}
+	env, span := execSpan(ctx, cmdAndArgs, env)
+	out, err := execCommand(ctx, cmdAndArgs, env)
+	EndSpan(span, err)
+	return out, err
+}
+
+// execCommand - execute command given by array of strings with eventual environment map
+func execCommand(ctx *Ctx, cmdAndArgs []string, env map[string]string) (string, error) {
 	// Execution time
 	dtStart := time.Now()
 
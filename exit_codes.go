@@ -0,0 +1,181 @@
+package devstatscode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v38/github"
+	"github.com/lib/pq"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Exit codes of all tools, sync pipeline (cron jobs, devstats, gha2db_sync) uses them to decide between retry and alert
+// 1 is used for unclassified errors, 2 is Go's unrecovered panic code and import_affs uses 2/3 for dry-run/already imported
+const (
+	// ExitOK - success
+	ExitOK = 0
+	// ExitError - unclassified error, alert
+	ExitError = 1
+	// ExitConfig - configuration error: wrong arguments, environment, YAML/SQL files or missing database, alert (retry won't help)
+	ExitConfig = 10
+	// ExitTransient - transient database or network error (connection lost, DB restarting, deadlock), retry
+	ExitTransient = 11
+	// ExitGitHubAPI - GitHub API points exhausted or API unavailable, retry after the limit resets
+	ExitGitHubAPI = 12
+	// ExitData - data error: invalid or inconsistent data, constraint violations, failed data checks, alert
+	ExitData = 13
+)
+
+// ExitCodeNames - exit code names used in logs
+var ExitCodeNames = map[int]string{
+	ExitOK:        "ok",
+	ExitError:     "error",
+	ExitConfig:    "config error",
+	ExitTransient: "transient error",
+	ExitGitHubAPI: "GitHub API exhausted",
+	ExitData:      "data error",
+}
+
+// ExitCodeError - error with an exit code attached, see WithExitCode
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+// Error - returns wrapped error message
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap - returns wrapped error
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// WithExitCode - attaches an exit code to an error (nil stays nil), it takes precedence over classification done by ExitCode
+func WithExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ExitCodeError{Code: code, Err: err}
+}
+
+// ExitErrorf - returns fmt.Errorf error with a given exit code attached
+func ExitErrorf(code int, f string, a ...interface{}) error {
+	return WithExitCode(code, fmt.Errorf(f, a...))
+}
+
+// transientErrorMessages - error messages of transient errors that were formatted into a string (so their type is lost)
+var transientErrorMessages = []string{
+	"driver: bad connection",
+	"connection refused",
+	"connection reset by peer",
+	"broken pipe",
+	"i/o timeout",
+	"cannot assign requested address",
+	"the database system is starting up",
+	"the database system is shutting down",
+}
+
+// ExitCode - returns exit code for a given error: code attached via WithExitCode, child tool's exit code or a code based on error type
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var codeErr *ExitCodeError
+	if errors.As(err, &codeErr) {
+		return codeErr.Code
+	}
+	// Tool called via ExecCommand failed, pass its classified exit code up
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code != ExitOK {
+			if _, ok := ExitCodeNames[code]; ok {
+				return code
+			}
+		}
+		return ExitError
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		// Connection exception, transaction rollback (deadlock, serialization), insufficient resources, operator intervention, system error
+		case "08", "40", "53", "57", "58":
+			return ExitTransient
+		// Data exception, integrity constraint violation
+		case "22", "23":
+			return ExitData
+		// Syntax error or access rule violation (metric SQLs), invalid authorization, invalid catalog name
+		case "42", "28", "3D":
+			return ExitConfig
+		}
+		return ExitError
+	}
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return ExitGitHubAPI
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ExitTransient
+	}
+	var numErr *strconv.NumError
+	var yamlErr *yaml.TypeError
+	if errors.As(err, &numErr) || errors.As(err, &yamlErr) {
+		return ExitConfig
+	}
+	msg := err.Error()
+	for _, transient := range transientErrorMessages {
+		if strings.Contains(msg, transient) {
+			return ExitTransient
+		}
+	}
+	return ExitError
+}
+
+// fatalError - panic value of FatalOnError and FatalNoLog, error details and stack trace are already printed
+type fatalError struct {
+	err error
+}
+
+// Error - returns error message with stacktrace prefix (this is what unrecovered panic used to print)
+func (e *fatalError) Error() string {
+	return "stacktrace: " + e.err.Error()
+}
+
+// Unwrap - returns error passed to FatalOnError
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// ExitOnPanic - must be deferred in tools main functions, exits with ExitCode of the panic error instead of Go's panic status 2
+// Panics that don't come from FatalOnError (runtime errors) get their stack trace printed
+func ExitOnPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+	var fatal *fatalError
+	if !errors.As(err, &fatal) {
+		fmt.Fprintf(os.Stderr, "panic: %v\n%s\n", err, debug.Stack())
+	}
+	code := ExitCode(err)
+	if code == ExitOK {
+		code = ExitError
+	}
+	fmt.Fprintf(os.Stderr, "%s: exiting with code %d (%s)\n", filepath.Base(os.Args[0]), code, ExitCodeNames[code])
+	os.Exit(code)
+}
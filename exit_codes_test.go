@@ -0,0 +1,70 @@
+package devstatscode
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+	"github.com/lib/pq"
+)
+
+func TestExitCode(t *testing.T) {
+	_, numErr := strconv.Atoi("x")
+	// Test cases
+	var testCases = []struct {
+		err      error
+		expected int
+	}{
+		{err: nil, expected: lib.ExitOK},
+		{err: errors.New("something failed"), expected: lib.ExitError},
+		{err: lib.ExitErrorf(lib.ExitGitHubAPI, "API limit reached"), expected: lib.ExitGitHubAPI},
+		{err: fmt.Errorf("wrapped: %w", lib.WithExitCode(lib.ExitData, errors.New("bad data"))), expected: lib.ExitData},
+		{err: lib.WithExitCode(lib.ExitConfig, &pq.Error{Code: "08006"}), expected: lib.ExitConfig},
+		{err: &pq.Error{Code: "08006"}, expected: lib.ExitTransient},
+		{err: &pq.Error{Code: "40P01"}, expected: lib.ExitTransient},
+		{err: &pq.Error{Code: "57P03"}, expected: lib.ExitTransient},
+		{err: &pq.Error{Code: "23505"}, expected: lib.ExitData},
+		{err: &pq.Error{Code: "22001"}, expected: lib.ExitData},
+		{err: &pq.Error{Code: "42703"}, expected: lib.ExitConfig},
+		{err: &pq.Error{Code: "3D000"}, expected: lib.ExitConfig},
+		{err: &pq.Error{Code: "XX000"}, expected: lib.ExitError},
+		{err: &github.RateLimitError{Message: "API rate limit exceeded"}, expected: lib.ExitGitHubAPI},
+		{err: fmt.Errorf("issues: %w", &github.AbuseRateLimitError{}), expected: lib.ExitGitHubAPI},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: lib.ExitTransient},
+		{err: fmt.Errorf("query failed: %v", "driver: bad connection"), expected: lib.ExitTransient},
+		{err: numErr, expected: lib.ExitConfig},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ExitCode(test.err)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %d, got %d for %v", index+1, test.expected, got, test.err)
+		}
+	}
+	if lib.WithExitCode(lib.ExitData, nil) != nil {
+		t.Errorf("expected nil error to stay nil")
+	}
+}
+
+func TestExitCodeOfPanics(t *testing.T) {
+	t.Setenv("NO_FATAL_DELAY", "1")
+	// FatalOnError panic keeps error, so its code survives pool task panics
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 2)
+	pool.Submit(func() { lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached") })
+	err := pool.Wait()
+	if got := lib.ExitCode(err); got != lib.ExitGitHubAPI {
+		t.Errorf("expected %d, got %d for %v", lib.ExitGitHubAPI, got, err)
+	}
+	// Non-error panic is unclassified
+	pool = lib.NewPool(&ctx, 2)
+	pool.Submit(func() { panic("boom") })
+	err = pool.Wait()
+	if got := lib.ExitCode(err); got != lib.ExitError {
+		t.Errorf("expected %d, got %d for %v", lib.ExitError, got, err)
+	}
+}
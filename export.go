@@ -0,0 +1,423 @@
+package devstatscode
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ExportActor - actor or org in GitHub BigQuery public dataset schema
+type ExportActor struct {
+	ID         int64  `json:"id"`
+	Login      string `json:"login"`
+	GravatarID string `json:"gravatar_id"`
+	AvatarURL  string `json:"avatar_url"`
+	URL        string `json:"url"`
+}
+
+// ExportRepo - repo in GitHub BigQuery public dataset schema
+type ExportRepo struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ExportEvent - GHA event in GitHub BigQuery public dataset (githubarchive) schema
+// Payload is the original event payload JSON, with hidden (GDPR) logins, names and emails anonymized
+type ExportEvent struct {
+	ID        string
+	Type      string
+	Public    bool
+	Payload   string
+	Repo      ExportRepo
+	Actor     ExportActor
+	Org       *ExportActor
+	CreatedAt time.Time
+	Other     *string
+}
+
+// ExportEncoder - writes events in a given schema to a single hour file
+type ExportEncoder interface {
+	Encode(ev *ExportEvent) error
+	Flush() error
+}
+
+// ExportFormat - GHA2DB_EXPORT_FORMAT adapter: export file extension and encoder constructor
+type ExportFormat struct {
+	Ext        string
+	NewEncoder func(w io.Writer) (ExportEncoder, error)
+}
+
+// ExportFormats - available GHA2DB_EXPORT_FORMAT adapters
+var ExportFormats = map[string]ExportFormat{
+	"bigquery": {Ext: ".json", NewEncoder: newBigQueryEncoder},
+	"avro":     {Ext: ".avro", NewEncoder: newAvroEncoder},
+}
+
+// Payload fields that can hold personal data hidden by MaybeHideFunc
+var exportHideRE = regexp.MustCompile(`"(login|name|email)"\s*:\s*"([^"\\]*)"`)
+
+// exportHidden - values hidden by maybeHide (original -> hidden), used to anonymize them everywhere they appear
+type exportHidden map[string]string
+
+// hide - returns maybeHide(value) and remembers it when the value is hidden
+func (h exportHidden) hide(value string, maybeHide func(string) string) string {
+	hidden := maybeHide(value)
+	if hidden != value && value != "" {
+		h[value] = hidden
+	}
+	return hidden
+}
+
+// replace - rewrites all occurrences of hidden values in s, that are not a part of a longer login-like word
+// So "https://api.github.com/users/login" and "login/repo" are anonymized, while "loginx" is not
+func (h exportHidden) replace(s string) string {
+	if len(h) == 0 {
+		return s
+	}
+	values := make([]string, 0, len(h))
+	for value := range h {
+		values = append(values, value)
+	}
+	// Longer values first, so e-mails are replaced before logins they contain
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	isWord := func(b byte) bool {
+		return b == '-' || b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+	}
+	for _, value := range values {
+		var out strings.Builder
+		rest := s
+		for {
+			i := strings.Index(rest, value)
+			if i < 0 {
+				out.WriteString(rest)
+				break
+			}
+			j := i + len(value)
+			before := len(s) - len(rest) + i
+			if (before > 0 && isWord(s[before-1])) || (j < len(rest) && isWord(rest[j])) {
+				out.WriteString(rest[:j])
+			} else {
+				out.WriteString(rest[:i])
+				out.WriteString(h[value])
+			}
+			rest = rest[j:]
+		}
+		s = out.String()
+	}
+	return s
+}
+
+// NewExportEvent - creates export event from the original GHA JSON, createdAt can differ from JSON's one (GHA2DB_SKEW_FIX)
+// Logins hidden by maybeHide are anonymized in actor, org, repo and payload, URLs of hidden actors are cleared
+// Every other occurrence of a hidden value (payload users' URLs, user owned repos names) is replaced with its hidden value
+func NewExportEvent(jsonStr []byte, createdAt time.Time, maybeHide func(string) string) (*ExportEvent, error) {
+	var raw struct {
+		ID      string              `json:"id"`
+		Type    string              `json:"type"`
+		Public  bool                `json:"public"`
+		Payload jsoniter.RawMessage `json:"payload"`
+		Repo    ExportRepo          `json:"repo"`
+		Actor   ExportActor         `json:"actor"`
+		Org     *ExportActor        `json:"org"`
+	}
+	err := jsoniter.Unmarshal(jsonStr, &raw)
+	if err != nil {
+		return nil, err
+	}
+	hidden := exportHidden{}
+	hideActor := func(a *ExportActor) {
+		login := hidden.hide(a.Login, maybeHide)
+		if login != a.Login {
+			a.Login = login
+			a.GravatarID = ""
+			a.AvatarURL = ""
+			a.URL = ""
+		}
+	}
+	hideActor(&raw.Actor)
+	if raw.Org != nil {
+		hideActor(raw.Org)
+	}
+	if i := strings.Index(raw.Repo.Name, "/"); i > 0 {
+		hidden.hide(raw.Repo.Name[:i], maybeHide)
+	}
+	payload := exportHideRE.ReplaceAllFunc(
+		raw.Payload,
+		func(field []byte) []byte {
+			m := exportHideRE.FindSubmatch(field)
+			value := string(m[2])
+			hiddenValue := hidden.hide(value, maybeHide)
+			if hiddenValue == value {
+				return field
+			}
+			return []byte(fmt.Sprintf(`"%s":"%s"`, m[1], hiddenValue))
+		},
+	)
+	payload = []byte(hidden.replace(string(payload)))
+	raw.Repo.Name = hidden.replace(raw.Repo.Name)
+	raw.Repo.URL = hidden.replace(raw.Repo.URL)
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	return &ExportEvent{
+		ID:        raw.ID,
+		Type:      raw.Type,
+		Public:    raw.Public,
+		Payload:   string(payload),
+		Repo:      raw.Repo,
+		Actor:     raw.Actor,
+		Org:       raw.Org,
+		CreatedAt: createdAt.UTC(),
+	}, nil
+}
+
+// EventExporter - writes events of a single GHA hour to <GHA2DB_EXPORT_DIR>/YYYY-MM-DD-H<ext> file
+// File is written under a temporary name and renamed on Close, so partially written hours are never visible
+// Events repeated within the hour are written once, it is not safe to use from multiple goroutines
+type EventExporter struct {
+	fn      string
+	file    *os.File
+	buf     *bufio.Writer
+	enc     ExportEncoder
+	ids     map[string]struct{}
+	Written int
+}
+
+// NewEventExporter - creates exporter of a given GHA hour in ctx.ExportFormat, returns nil when export is not enabled
+func NewEventExporter(ctx *Ctx, dt time.Time) (*EventExporter, error) {
+	if ctx.ExportFormat == "" {
+		return nil, nil
+	}
+	format, ok := ExportFormats[ctx.ExportFormat]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format '%s'", ctx.ExportFormat)
+	}
+	err := os.MkdirAll(ctx.ExportDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	fn := ctx.ExportDir + ToGHADate(dt) + format.Ext
+	file, err := os.Create(fn + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	enc, err := format.NewEncoder(buf)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &EventExporter{fn: fn, file: file, buf: buf, enc: enc, ids: make(map[string]struct{})}, nil
+}
+
+// Write - writes a single event, events with an ID that was already written are skipped
+func (e *EventExporter) Write(ev *ExportEvent) error {
+	if ev.ID != "" {
+		if _, ok := e.ids[ev.ID]; ok {
+			return nil
+		}
+		e.ids[ev.ID] = struct{}{}
+	}
+	e.Written++
+	return e.enc.Encode(ev)
+}
+
+// Filename - final name of export file
+func (e *EventExporter) Filename() string {
+	return e.fn
+}
+
+// Close - finishes export file and renames it to its final name
+func (e *EventExporter) Close() error {
+	err := e.enc.Flush()
+	if err == nil {
+		err = e.buf.Flush()
+	}
+	cerr := e.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(e.fn+".tmp", e.fn)
+}
+
+// bigQueryEncoder - newline delimited JSON, loadable with "bq load --source_format=NEWLINE_DELIMITED_JSON"
+type bigQueryEncoder struct {
+	w io.Writer
+}
+
+func newBigQueryEncoder(w io.Writer) (ExportEncoder, error) {
+	return &bigQueryEncoder{w: w}, nil
+}
+
+// Encode - writes event as a single JSON line, created_at is in BigQuery TIMESTAMP format
+func (b *bigQueryEncoder) Encode(ev *ExportEvent) error {
+	row := struct {
+		Type      string       `json:"type"`
+		Public    bool         `json:"public"`
+		Payload   string       `json:"payload"`
+		Repo      ExportRepo   `json:"repo"`
+		Actor     ExportActor  `json:"actor"`
+		Org       *ExportActor `json:"org"`
+		CreatedAt string       `json:"created_at"`
+		ID        string       `json:"id"`
+		Other     *string      `json:"other"`
+	}{
+		Type:      ev.Type,
+		Public:    ev.Public,
+		Payload:   ev.Payload,
+		Repo:      ev.Repo,
+		Actor:     ev.Actor,
+		Org:       ev.Org,
+		CreatedAt: ev.CreatedAt.Format("2006-01-02 15:04:05 UTC"),
+		ID:        ev.ID,
+		Other:     ev.Other,
+	}
+	data, err := jsoniter.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = b.w.Write(append(data, '\n'))
+	return err
+}
+
+// Flush - nothing is buffered
+func (b *bigQueryEncoder) Flush() error {
+	return nil
+}
+
+// AvroExportSchema - Avro schema of exported events (GitHub BigQuery public dataset schema, created_at in microseconds)
+const AvroExportSchema = `{"type":"record","name":"Event","namespace":"org.gharchive","fields":[` +
+	`{"name":"type","type":"string"},` +
+	`{"name":"public","type":"boolean"},` +
+	`{"name":"payload","type":"string"},` +
+	`{"name":"repo","type":{"type":"record","name":"Repo","fields":[{"name":"id","type":"long"},{"name":"name","type":"string"},{"name":"url","type":"string"}]}},` +
+	`{"name":"actor","type":{"type":"record","name":"Actor","fields":[{"name":"id","type":"long"},{"name":"login","type":"string"},{"name":"gravatar_id","type":"string"},{"name":"avatar_url","type":"string"},{"name":"url","type":"string"}]}},` +
+	`{"name":"org","type":["null","Actor"],"default":null},` +
+	`{"name":"created_at","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+	`{"name":"id","type":"string"},` +
+	`{"name":"other","type":["null","string"],"default":null}]}`
+
+// Number of records in a single Avro container file block
+const avroBlockRecords = 1000
+
+// avroEncoder - Avro object container file with AvroExportSchema, uncompressed (null codec)
+type avroEncoder struct {
+	w       io.Writer
+	sync    []byte
+	block   bytes.Buffer
+	records int64
+}
+
+func newAvroEncoder(w io.Writer) (ExportEncoder, error) {
+	a := &avroEncoder{w: w, sync: make([]byte, 16)}
+	_, err := rand.Read(a.sync)
+	if err != nil {
+		return nil, err
+	}
+	var header bytes.Buffer
+	header.WriteString("Obj\x01")
+	avroLong(&header, 2)
+	avroString(&header, "avro.schema")
+	avroString(&header, AvroExportSchema)
+	avroString(&header, "avro.codec")
+	avroString(&header, "null")
+	avroLong(&header, 0)
+	header.Write(a.sync)
+	_, err = w.Write(header.Bytes())
+	return a, err
+}
+
+// avroLong - zig-zag variable length encoded long
+func avroLong(b *bytes.Buffer, n int64) {
+	u := uint64((n << 1) ^ (n >> 63))
+	for u >= 0x80 {
+		b.WriteByte(byte(u) | 0x80)
+		u >>= 7
+	}
+	b.WriteByte(byte(u))
+}
+
+// avroString - length prefixed string (or bytes)
+func avroString(b *bytes.Buffer, s string) {
+	avroLong(b, int64(len(s)))
+	b.WriteString(s)
+}
+
+func avroActor(b *bytes.Buffer, a *ExportActor) {
+	avroLong(b, a.ID)
+	avroString(b, a.Login)
+	avroString(b, a.GravatarID)
+	avroString(b, a.AvatarURL)
+	avroString(b, a.URL)
+}
+
+// Encode - appends event to the current block, block is written every avroBlockRecords events
+func (a *avroEncoder) Encode(ev *ExportEvent) error {
+	b := &a.block
+	avroString(b, ev.Type)
+	if ev.Public {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	avroString(b, ev.Payload)
+	avroLong(b, ev.Repo.ID)
+	avroString(b, ev.Repo.Name)
+	avroString(b, ev.Repo.URL)
+	avroActor(b, &ev.Actor)
+	if ev.Org == nil {
+		avroLong(b, 0)
+	} else {
+		avroLong(b, 1)
+		avroActor(b, ev.Org)
+	}
+	avroLong(b, ev.CreatedAt.UnixNano()/1000)
+	avroString(b, ev.ID)
+	if ev.Other == nil {
+		avroLong(b, 0)
+	} else {
+		avroLong(b, 1)
+		avroString(b, *ev.Other)
+	}
+	a.records++
+	if a.records >= avroBlockRecords {
+		return a.Flush()
+	}
+	return nil
+}
+
+// Flush - writes current block (if not empty)
+func (a *avroEncoder) Flush() error {
+	if a.records == 0 {
+		return nil
+	}
+	var header bytes.Buffer
+	avroLong(&header, a.records)
+	avroLong(&header, int64(a.block.Len()))
+	for _, data := range [][]byte{header.Bytes(), a.block.Bytes(), a.sync} {
+		if _, err := a.w.Write(data); err != nil {
+			return err
+		}
+	}
+	a.block.Reset()
+	a.records = 0
+	return nil
+}
@@ -0,0 +1,183 @@
+package devstatscode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestNewExportEvent(t *testing.T) {
+	// Only "secret" login is hidden
+	maybeHide := func(s string) string {
+		if s == "secret" {
+			return "anon-1"
+		}
+		return s
+	}
+	ft := func(s string) time.Time { return lib.TimeParseAny(s) }
+	// Test cases
+	var testCases = []struct {
+		json            string
+		createdAt       time.Time
+		expectedLogin   string
+		expectedURL     string
+		expectedPayload string
+		expectedOrg     bool
+	}{
+		{
+			json:            `{"id":"1","type":"WatchEvent","public":true,"actor":{"id":1,"login":"lukaszgryglicki","url":"https://api.github.com/users/lukaszgryglicki"},"repo":{"id":2,"name":"cncf/devstats"},"payload":{"action":"started"},"created_at":"2021-07-01T10:00:00Z"}`,
+			createdAt:       ft("2021-07-01 10:00:00"),
+			expectedLogin:   "lukaszgryglicki",
+			expectedURL:     "https://api.github.com/users/lukaszgryglicki",
+			expectedPayload: `{"action":"started"}`,
+		},
+		{
+			json:            `{"id":"2","type":"PushEvent","public":true,"actor":{"id":3,"login":"secret","url":"https://api.github.com/users/secret"},"repo":{"id":2,"name":"cncf/devstats"},"org":{"id":4,"login":"cncf"},"payload":{"commits":[{"author":{"name":"secret","email":"x@y.z"}}]},"created_at":"2021-07-01T10:00:00Z"}`,
+			createdAt:       ft("2021-07-01 10:59:59"),
+			expectedLogin:   "anon-1",
+			expectedPayload: `{"commits":[{"author":{"name":"anon-1","email":"x@y.z"}}]}`,
+			expectedOrg:     true,
+		},
+		{
+			json:            `{"id":"3","type":"PublicEvent","public":true,"actor":{"id":1,"login":"lukaszgryglicki"},"repo":{"id":2,"name":"cncf/devstats"}}`,
+			createdAt:       ft("2021-07-01 10:00:00"),
+			expectedLogin:   "lukaszgryglicki",
+			expectedPayload: `{}`,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ev, err := lib.NewExportEvent([]byte(test.json), test.createdAt, maybeHide)
+		if err != nil {
+			t.Errorf("test number %d: unexpected error %v", index+1, err)
+			continue
+		}
+		if ev.Actor.Login != test.expectedLogin || ev.Actor.URL != test.expectedURL {
+			t.Errorf("test number %d, expected actor %s %s, got %+v", index+1, test.expectedLogin, test.expectedURL, ev.Actor)
+		}
+		if ev.Payload != test.expectedPayload {
+			t.Errorf("test number %d, expected payload %s, got %s", index+1, test.expectedPayload, ev.Payload)
+		}
+		if (ev.Org != nil) != test.expectedOrg {
+			t.Errorf("test number %d, expected org %v, got %+v", index+1, test.expectedOrg, ev.Org)
+		}
+		if !ev.CreatedAt.Equal(test.createdAt) {
+			t.Errorf("test number %d, expected created at %v, got %v", index+1, test.createdAt, ev.CreatedAt)
+		}
+	}
+}
+
+func TestNewExportEventNestedUser(t *testing.T) {
+	// Only "secret" login and its e-mail are hidden
+	maybeHide := func(s string) string {
+		switch s {
+		case "secret":
+			return "anon-1"
+		case "secret@x.com":
+			return "anon-email-1"
+		}
+		return s
+	}
+	json := `{"id":"4","type":"IssueCommentEvent","public":true,"actor":{"id":1,"login":"lukaszgryglicki"},` +
+		`"repo":{"id":5,"name":"secret/tools","url":"https://api.github.com/repos/secret/tools"},` +
+		`"payload":{"issue":{"user":{"login":"secret","email":"secret@x.com","url":"https://api.github.com/users/secret",` +
+		`"html_url":"https://github.com/secret","followers_url":"https://api.github.com/users/secret/followers",` +
+		`"avatar_url":"https://avatars.githubusercontent.com/u/7?v=4"},"body":"cc @secret, secretary <secret@x.com>"}},` +
+		`"created_at":"2021-07-01T10:00:00Z"}`
+	ev, err := lib.NewExportEvent([]byte(json), lib.TimeParseAny("2021-07-01 10:00:00"), maybeHide)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expectedPayload := `{"issue":{"user":{"login":"anon-1","email":"anon-email-1","url":"https://api.github.com/users/anon-1",` +
+		`"html_url":"https://github.com/anon-1","followers_url":"https://api.github.com/users/anon-1/followers",` +
+		`"avatar_url":"https://avatars.githubusercontent.com/u/7?v=4"},"body":"cc @anon-1, secretary <anon-email-1>"}}`
+	if ev.Payload != expectedPayload {
+		t.Errorf("expected payload %s, got %s", expectedPayload, ev.Payload)
+	}
+	if ev.Repo.Name != "anon-1/tools" || ev.Repo.URL != "https://api.github.com/repos/anon-1/tools" {
+		t.Errorf("expected anonymized repo, got %+v", ev.Repo)
+	}
+	if strings.Contains(ev.Payload, "secret/") || strings.Contains(ev.Payload, "/secret") {
+		t.Errorf("hidden login found in payload %s", ev.Payload)
+	}
+}
+
+func TestEventExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	ev, err := lib.NewExportEvent(
+		[]byte(`{"id":"1","type":"WatchEvent","public":true,"actor":{"id":1,"login":"lgryglicki"},"repo":{"id":2,"name":"cncf/devstats"},"payload":{"action":"started"}}`),
+		lib.TimeParseAny("2021-07-01 10:20:30"),
+		func(s string) string { return s },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Test cases
+	var testCases = []struct {
+		format   string
+		fn       string
+		expected func([]byte) bool
+	}{
+		{
+			format: "bigquery",
+			fn:     "2021-07-01-10.json",
+			expected: func(data []byte) bool {
+				return string(data) == `{"type":"WatchEvent","public":true,"payload":"{\"action\":\"started\"}",`+
+					`"repo":{"id":2,"name":"cncf/devstats","url":""},"actor":{"id":1,"login":"lgryglicki","gravatar_id":"","avatar_url":"","url":""},`+
+					`"org":null,"created_at":"2021-07-01 10:20:30 UTC","id":"1","other":null}`+"\n"
+			},
+		},
+		{
+			format: "avro",
+			fn:     "2021-07-01-10.avro",
+			expected: func(data []byte) bool {
+				return bytes.HasPrefix(data, []byte("Obj\x01")) &&
+					bytes.Contains(data, []byte(lib.AvroExportSchema)) &&
+					bytes.Contains(data, []byte("cncf/devstats")) &&
+					bytes.Count(data, data[len(data)-16:]) == 2
+			},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ctx := lib.Ctx{ExportFormat: test.format, ExportDir: dir + "/"}
+		exporter, err := lib.NewEventExporter(&ctx, lib.TimeParseAny("2021-07-01 10:00:00"))
+		if err != nil {
+			t.Fatalf("test number %d: %v", index+1, err)
+		}
+		for i := 0; i < 2; i++ {
+			if err = exporter.Write(ev); err != nil {
+				t.Fatalf("test number %d: %v", index+1, err)
+			}
+		}
+		if _, err = os.Stat(exporter.Filename()); err == nil {
+			t.Errorf("test number %d, export file should not exist before close", index+1)
+		}
+		if err = exporter.Close(); err != nil {
+			t.Fatalf("test number %d: %v", index+1, err)
+		}
+		if !strings.HasSuffix(exporter.Filename(), test.fn) || exporter.Written != 1 {
+			t.Errorf("test number %d, expected 1 event in %s, got %d in %s", index+1, test.fn, exporter.Written, exporter.Filename())
+		}
+		data, err := ioutil.ReadFile(exporter.Filename())
+		if err != nil {
+			t.Fatalf("test number %d: %v", index+1, err)
+		}
+		if !test.expected(data) {
+			t.Errorf("test number %d, unexpected %s export:\n%q", index+1, test.format, string(data))
+		}
+	}
+	ctx := lib.Ctx{}
+	if exporter, err := lib.NewEventExporter(&ctx, time.Now()); exporter != nil || err != nil {
+		t.Errorf("expected no exporter when export is disabled, got %v, %v", exporter, err)
+	}
+}
@@ -0,0 +1,74 @@
+package devstatscode
+
+import (
+	"sort"
+	"strings"
+)
+
+// Per project features (projects.yaml 'features' map), tools check them with ctx.FeatureEnabled
+const (
+	// FeatureCommitsFiles - get_repos commits files stats, enabled by default
+	FeatureCommitsFiles = "commits_files"
+	// FeatureCommitsLOC - get_repos commits lines of code stats, enabled by default
+	FeatureCommitsLOC = "commits_loc"
+	// FeatureWorkflowRuns - ghapi2db GitHub Actions workflow runs sync, disabled by default
+	FeatureWorkflowRuns = "workflow_runs"
+	// FeatureMentions - gha2db mentions and issue references graph, disabled by default (unless GHA2DB_MENTIONS is set)
+	FeatureMentions = "mentions"
+	// FeatureMilestoneProgress - ghapi2db milestones progress snapshots, disabled by default (unless GHA2DB_GHAPIMILESTONES is set)
+	FeatureMilestoneProgress = "milestone_progress"
+)
+
+// FeaturesFromString - parses comma separated list of features: "name" enables and "-name" disables a feature
+func FeaturesFromString(str string) map[string]bool {
+	features := make(map[string]bool)
+	for _, feature := range strings.Split(str, ",") {
+		feature = strings.TrimSpace(feature)
+		if feature == "" || feature == "-" {
+			continue
+		}
+		if strings.HasPrefix(feature, "-") {
+			features[feature[1:]] = false
+			continue
+		}
+		features[strings.TrimPrefix(feature, "+")] = true
+	}
+	return features
+}
+
+// FeaturesToString - returns features in the format parsed by FeaturesFromString, sorted by name
+func FeaturesToString(features map[string]bool) string {
+	names := []string{}
+	for name, enabled := range features {
+		if enabled {
+			names = append(names, name)
+		} else {
+			names = append(names, "-"+name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.TrimPrefix(names[i], "-") < strings.TrimPrefix(names[j], "-")
+	})
+	return strings.Join(names, ",")
+}
+
+// EnabledFeatures - returns sorted names of enabled features
+func EnabledFeatures(features map[string]bool) (names []string) {
+	names = []string{}
+	for name, enabled := range features {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// FeatureEnabled - returns if a given feature is enabled for the current project, def is used when feature is not set
+func (ctx *Ctx) FeatureEnabled(feature string, def bool) bool {
+	enabled, ok := ctx.Features[feature]
+	if !ok {
+		return def
+	}
+	return enabled
+}
@@ -0,0 +1,49 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestFeaturesFromString(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		str      string
+		expected map[string]bool
+		asString string
+	}{
+		{str: "", expected: map[string]bool{}, asString: ""},
+		{str: " , -", expected: map[string]bool{}, asString: ""},
+		{
+			str:      "workflow_runs, -commits_files,+heavy",
+			expected: map[string]bool{"workflow_runs": true, "commits_files": false, "heavy": true},
+			asString: "-commits_files,heavy,workflow_runs",
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.FeaturesFromString(test.str)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+		str := lib.FeaturesToString(got)
+		if str != test.asString {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.asString, str)
+		}
+	}
+}
+
+func TestFeatureEnabled(t *testing.T) {
+	ctx := lib.Ctx{Features: lib.FeaturesFromString("a,-b")}
+	if !ctx.FeatureEnabled("a", false) || ctx.FeatureEnabled("b", true) {
+		t.Errorf("features set in context should override defaults")
+	}
+	if !ctx.FeatureEnabled("c", true) || ctx.FeatureEnabled("c", false) {
+		t.Errorf("default should be used for features not set in context")
+	}
+	if enabled := lib.EnabledFeatures(ctx.Features); !reflect.DeepEqual(enabled, []string{"a"}) {
+		t.Errorf("expected enabled features [a], got %v", enabled)
+	}
+}
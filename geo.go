@@ -0,0 +1,174 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// LocationResolver - resolves free-form GitHub profile location to a country code and (optionally) time zone
+type LocationResolver interface {
+	Resolve(location string) (countryCode, tz string, ok bool)
+}
+
+// CountriesResolver - built-in resolver, matches country names and common aliases from gha_countries table
+// It checks location parts from the last one, so "Warsaw, Poland" and "Poland" both resolve to "PL"
+type CountriesResolver struct {
+	names map[string]string
+}
+
+// countryAliases - common location spellings not present in gha_countries names
+var countryAliases = map[string]string{
+	"usa":             "US",
+	"u.s.a.":          "US",
+	"united states":   "US",
+	"uk":              "GB",
+	"u.k.":            "GB",
+	"england":         "GB",
+	"scotland":        "GB",
+	"wales":           "GB",
+	"great britain":   "GB",
+	"russia":          "RU",
+	"south korea":     "KR",
+	"korea":           "KR",
+	"czech republic":  "CZ",
+	"the netherlands": "NL",
+	"holland":         "NL",
+	"iran":            "IR",
+	"vietnam":         "VN",
+	"taiwan":          "TW",
+}
+
+// NewCountriesResolver - creates built-in resolver using gha_countries table
+func NewCountriesResolver(con *sql.DB, ctx *Ctx) (*CountriesResolver, error) {
+	r := &CountriesResolver{names: make(map[string]string)}
+	for alias, code := range countryAliases {
+		r.names[alias] = code
+	}
+	rows, err := QuerySQLLogErr(con, ctx, "select code, name from gha_countries")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	code, name := "", ""
+	for rows.Next() {
+		err = rows.Scan(&code, &name)
+		if err != nil {
+			return nil, err
+		}
+		r.names[strings.ToLower(name)] = code
+	}
+	return r, rows.Err()
+}
+
+// Resolve - returns country code for a location, time zone is not known to this resolver
+func (r *CountriesResolver) Resolve(location string) (string, string, bool) {
+	parts := strings.FieldsFunc(location, func(c rune) bool { return c == ',' || c == '/' || c == '|' || c == ';' })
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.ToLower(strings.Trim(strings.TrimSpace(parts[i]), ".!()"))
+		if code, ok := r.names[part]; ok {
+			return code, "", true
+		}
+	}
+	return "", "", false
+}
+
+// HTTPResolver - resolves locations using an external geocoding service
+// URL is a template with {{location}} placeholder, service must return JSON: {"country_code": "PL", "tz": "Europe/Warsaw"}
+type HTTPResolver struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPResolver - creates HTTP resolver for a given URL template
+func NewHTTPResolver(urlTemplate string) *HTTPResolver {
+	return &HTTPResolver{URL: urlTemplate, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Resolve - calls geocoding service, any error means location is not resolved
+func (r *HTTPResolver) Resolve(location string) (string, string, bool) {
+	u := strings.Replace(r.URL, "{{location}}", url.QueryEscape(location), -1)
+	resp, err := r.Client.Get(u)
+	if err != nil {
+		Printf("Geocoding '%s' failed: %v\n", location, err)
+		return "", "", false
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		Printf("Geocoding '%s' failed: status %d: %v\n", location, resp.StatusCode, err)
+		return "", "", false
+	}
+	var data struct {
+		CountryCode string `json:"country_code"`
+		TZ          string `json:"tz"`
+	}
+	err = jsoniter.Unmarshal(body, &data)
+	if err != nil {
+		Printf("Geocoding '%s' failed: cannot parse '%s': %v\n", location, string(body), err)
+		return "", "", false
+	}
+	if len(data.CountryCode) != 2 {
+		return "", "", false
+	}
+	return strings.ToUpper(data.CountryCode), data.TZ, true
+}
+
+// ChainResolver - tries resolvers in order, first resolved location wins
+type ChainResolver []LocationResolver
+
+// Resolve - returns result of the first resolver that knows the location
+func (r ChainResolver) Resolve(location string) (string, string, bool) {
+	for _, resolver := range r {
+		if code, tz, ok := resolver.Resolve(location); ok {
+			return code, tz, true
+		}
+	}
+	return "", "", false
+}
+
+// CachedResolver - remembers results (also negative) of a wrapped resolver, many actors share the same location
+type CachedResolver struct {
+	Resolver LocationResolver
+	cache    map[string][3]string
+}
+
+// Resolve - returns cached result or calls wrapped resolver
+func (r *CachedResolver) Resolve(location string) (string, string, bool) {
+	key := strings.ToLower(strings.TrimSpace(location))
+	if r.cache == nil {
+		r.cache = make(map[string][3]string)
+	}
+	if data, ok := r.cache[key]; ok {
+		return data[0], data[1], data[2] != ""
+	}
+	code, tz, ok := r.Resolver.Resolve(location)
+	data := [3]string{code, tz, ""}
+	if ok {
+		data[2] = "y"
+	}
+	r.cache[key] = data
+	return code, tz, ok
+}
+
+// GetLocationResolver - returns resolver configured in context: geocoding service (if set) with built-in countries fallback
+func GetLocationResolver(con *sql.DB, ctx *Ctx) (LocationResolver, error) {
+	countries, err := NewCountriesResolver(con, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create countries resolver: %v", err)
+	}
+	chain := ChainResolver{countries}
+	if ctx.GeocoderURL != "" {
+		resolver := NewHTTPResolver(ctx.GeocoderURL)
+		resolver.Client = HTTPClient(ctx)
+		resolver.Client.Timeout = 30 * time.Second
+		chain = ChainResolver{resolver, countries}
+	}
+	return &CachedResolver{Resolver: chain}, nil
+}
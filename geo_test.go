@@ -0,0 +1,69 @@
+package devstatscode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// countingResolver - test resolver resolving fixed locations and counting calls
+type countingResolver struct {
+	data  map[string][2]string
+	calls int
+}
+
+func (r *countingResolver) Resolve(location string) (string, string, bool) {
+	r.calls++
+	data, ok := r.data[location]
+	return data[0], data[1], ok
+}
+
+func TestLocationResolvers(t *testing.T) {
+	// Geocoding service mock
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "Warsaw, Poland":
+			_, _ = w.Write([]byte(`{"country_code": "pl", "tz": "Europe/Warsaw"}`))
+		case "broken":
+			_, _ = w.Write([]byte(`not a JSON`))
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write([]byte(`{"country_code": "", "tz": ""}`))
+		}
+	}))
+	defer srv.Close()
+	fallback := &countingResolver{data: map[string][2]string{"error": {"DE", ""}}}
+	resolver := &lib.CachedResolver{
+		Resolver: lib.ChainResolver{lib.NewHTTPResolver(srv.URL + "/?q={{location}}"), fallback},
+	}
+	var testCases = []struct {
+		location string
+		code     string
+		tz       string
+		ok       bool
+	}{
+		{location: "Warsaw, Poland", code: "PL", tz: "Europe/Warsaw", ok: true},
+		{location: "error", code: "DE", tz: "", ok: true},
+		{location: "broken", code: "", tz: "", ok: false},
+		{location: "Nowhere", code: "", tz: "", ok: false},
+		{location: "nowhere ", code: "", tz: "", ok: false},
+		{location: "Error", code: "DE", tz: "", ok: true},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		code, tz, ok := resolver.Resolve(test.location)
+		if code != test.code || tz != test.tz || ok != test.ok {
+			t.Errorf(
+				"test number %d, expected (%s, %s, %v), got (%s, %s, %v), test case: %+v",
+				index+1, test.code, test.tz, test.ok, code, tz, ok, test,
+			)
+		}
+	}
+	// "error", "broken" and "Nowhere" reach fallback, cached results are not resolved again
+	if fallback.calls != 3 {
+		t.Errorf("expected 3 fallback resolver calls, got %d", fallback.calls)
+	}
+}
@@ -0,0 +1,144 @@
+package devstatscode
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v38/github"
+	jsoniter "github.com/json-iterator/go"
+	"golang.org/x/oauth2"
+)
+
+// GitHub token kinds, see GitHubTokenKind
+const (
+	GHTokenClassic      = "classic"
+	GHTokenFineGrained  = "fine-grained"
+	GHTokenOAuthApp     = "oauth app"
+	GHTokenInstallation = "installation"
+	GHTokenUnknown      = "unknown"
+)
+
+// gitHubTokenPrefixes - GitHub token prefixes and their kinds
+var gitHubTokenPrefixes = []struct{ prefix, kind string }{
+	{"github_pat_", GHTokenFineGrained},
+	{"ghp_", GHTokenClassic},
+	{"gho_", GHTokenOAuthApp},
+	{"ghs_", GHTokenInstallation},
+}
+
+// GitHubTokenKind - returns kind of a given GitHub token based on its prefix, old classic tokens are 40 hex digits
+func GitHubTokenKind(token string) string {
+	for _, p := range gitHubTokenPrefixes {
+		if strings.HasPrefix(token, p.prefix) {
+			return p.kind
+		}
+	}
+	if len(token) == 40 && strings.Trim(token, "0123456789abcdef") == "" {
+		return GHTokenClassic
+	}
+	return GHTokenUnknown
+}
+
+// GitHubTokens - splits GitHub tokens list, tokens can be separated by commas or white spaces (one token per line in a tokens file)
+func GitHubTokens(oAuth string) []string {
+	return strings.FieldsFunc(oAuth, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+}
+
+// ParseGitHubAppKey - parses GitHub App private key PEM (GitHub generates PKCS#1 keys, PKCS#8 keys are also accepted)
+func ParseGitHubAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in GitHub App private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err == nil {
+		return key, nil
+	}
+	pkcs8, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse GitHub App private key: %v", err)
+	}
+	key, ok := pkcs8.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// GitHubAppJWT - returns RS256 signed JWT authenticating as a given GitHub App
+// It is issued a minute in the past (clock drift) and expires after 9 minutes (GitHub allows at most 10)
+func GitHubAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := jsoniter.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := jsoniter.Marshal(
+		map[string]int64{
+			"iat": now.Add(-time.Minute).Unix(),
+			"exp": now.Add(9 * time.Minute).Unix(),
+			"iss": appID,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+// gitHubAppTokenSource - GitHub App installation access tokens source, each token is valid for an hour
+type gitHubAppTokenSource struct {
+	ghCtx          context.Context
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+}
+
+// Token - creates a new installation access token (oauth2.ReuseTokenSource calls it only when the previous one is about to expire)
+func (ts *gitHubAppTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := GitHubAppJWT(ts.appID, ts.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewClient(oauth2.NewClient(ts.ghCtx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt})))
+	token, _, err := client.Apps.CreateInstallationToken(ts.ghCtx, ts.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create GitHub App %d installation %d token: %v", ts.appID, ts.installationID, err)
+	}
+	// Refreshed a few minutes before it expires, so long running API calls don't use an expired token
+	expiry := token.GetExpiresAt().Add(-5 * time.Minute)
+	return &oauth2.Token{AccessToken: token.GetToken(), Expiry: expiry}, nil
+}
+
+// GitHubAppClients - returns GitHub clients authenticated as GitHub App installations (one per GHA2DB_GITHUB_APP_INSTALLATION_ID)
+// Installation tokens are created on the first API call and refreshed automatically before they expire
+func GitHubAppClients(ghCtx context.Context, ctx *Ctx) (clients []*github.Client) {
+	data, err := ReadFile(ctx, ctx.GitHubAppKey)
+	FatalOnError(err)
+	key, err := ParseGitHubAppKey(data)
+	FatalOnError(err)
+	for _, installationID := range ctx.GitHubAppInstallations {
+		ts := oauth2.ReuseTokenSource(
+			nil,
+			&gitHubAppTokenSource{ghCtx: ghCtx, appID: ctx.GitHubAppID, installationID: installationID, key: key},
+		)
+		clients = append(clients, github.NewClient(oauth2.NewClient(ghCtx, ts)))
+	}
+	return
+}
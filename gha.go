@@ -47,6 +47,11 @@ type Project struct {
 	ArchivedDate     *time.Time        `yaml:"archived_date"`
 	SyncProbability  *float64          `yaml:"sync_probabilty"`
 	ProjectScale     *float64          `yaml:"project_scale"`
+	Weight           *int              `yaml:"weight"`
+	CPUBudget        *float64          `yaml:"cpu_budget"`
+	Nice             *int              `yaml:"nice"`
+	Shards           []string          `yaml:"shards"`
+	Features         map[string]bool   `yaml:"features"`
 }
 
 // AnyArray - holds array of interface{} - just a shortcut
@@ -465,6 +470,32 @@ func GetProjectsList(ctx *Ctx, projects *AllProjects) (names []string, projs []P
 	return
 }
 
+// OrderProjectsByWeight - reorders projects returned by GetProjectsList using their weights
+// Weight is a relative sync cost from projects.yaml (default 0), lighter projects are synced first
+// so heavy projects (like Kubernetes) cannot starve smaller ones, projects.yaml order is kept for equal weights
+func OrderProjectsByWeight(names []string, projs []Project) ([]string, []Project) {
+	weight := func(proj *Project) int {
+		if proj.Weight == nil {
+			return 0
+		}
+		return *proj.Weight
+	}
+	idx := make([]int, len(names))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return weight(&projs[idx[i]]) < weight(&projs[idx[j]])
+	})
+	oNames := make([]string, len(names))
+	oProjs := make([]Project, len(projs))
+	for i, j := range idx {
+		oNames[i] = names[j]
+		oProjs[i] = projs[j]
+	}
+	return oNames, oProjs
+}
+
 // IsProjectDisabled - checks if project is disabled or not:
 // fullName comes from makeOldRepoName for pre-2015 data!
 // yamlDisabled (this is from projects.yaml - can be true or false)
@@ -490,7 +521,13 @@ func MakeOldRepoName(repo *ForkeeOld) string {
 }
 
 // ActorHit - are we intereste din this actor?
+// Actors excluded by GHA2DB_EXCLUSIONS_YAML are never hit
 func ActorHit(ctx *Ctx, actorName string) bool {
+	return actorFilterHit(ctx, actorName) && !ctx.Exclusions.ActorExcluded(actorName)
+}
+
+// actorFilterHit - does actor match GHA2DB_ACTORS_ALLOW/GHA2DB_ACTORS_FORBID filters?
+func actorFilterHit(ctx *Ctx, actorName string) bool {
 	if !ctx.ActorsFilter {
 		return true
 	}
@@ -502,7 +539,28 @@ func ActorHit(ctx *Ctx, actorName string) bool {
 }
 
 // RepoHit - are we interested in this org/repo ?
+// Orgs and repos excluded by GHA2DB_EXCLUSIONS_YAML are never hit
 func RepoHit(ctx *Ctx, fullName string, forg, frepo map[string]struct{}, orgRE, repoRE *regexp.Regexp) bool {
+	return repoFilterHit(ctx, fullName, forg, frepo, orgRE, repoRE) && !ctx.Exclusions.RepoExcluded(fullName)
+}
+
+// ExclusionReason - returns why an event matching org/repo and actor filters is excluded by GHA2DB_EXCLUSIONS_YAML (ExcludedOrg or ExcludedActor)
+// Returns empty string when event is not excluded or it doesn't match filters anyway
+func ExclusionReason(ctx *Ctx, fullName, actorName string, forg, frepo map[string]struct{}, orgRE, repoRE *regexp.Regexp) string {
+	if ctx.Exclusions == nil || !repoFilterHit(ctx, fullName, forg, frepo, orgRE, repoRE) || !actorFilterHit(ctx, actorName) {
+		return ""
+	}
+	if ctx.Exclusions.RepoExcluded(fullName) {
+		return ExcludedOrg
+	}
+	if ctx.Exclusions.ActorExcluded(actorName) {
+		return ExcludedActor
+	}
+	return ""
+}
+
+// repoFilterHit - does org/repo match GHA2DB_EXCLUDE_REPOS and org/repo filters?
+func repoFilterHit(ctx *Ctx, fullName string, forg, frepo map[string]struct{}, orgRE, repoRE *regexp.Regexp) bool {
 	// Return false if no repo name
 	if fullName == "" {
 		return false
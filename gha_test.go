@@ -174,6 +174,47 @@ func TestIsProjectDisabled(t *testing.T) {
 	}
 }
 
+func TestOrderProjectsByWeight(t *testing.T) {
+	w := func(i int) *int { return &i }
+	var testCases = []struct {
+		names    []string
+		projs    []lib.Project
+		expected []string
+	}{
+		{
+			names:    []string{},
+			projs:    []lib.Project{},
+			expected: []string{},
+		},
+		{
+			names:    []string{"a", "b", "c"},
+			projs:    []lib.Project{{}, {}, {}},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			names:    []string{"kubernetes", "b", "c"},
+			projs:    []lib.Project{{Weight: w(10)}, {}, {Weight: w(1)}},
+			expected: []string{"b", "c", "kubernetes"},
+		},
+		{
+			names:    []string{"a", "b", "c", "d"},
+			projs:    []lib.Project{{Weight: w(2)}, {Weight: w(-1)}, {Weight: w(2)}, {Weight: w(1)}},
+			expected: []string{"b", "d", "a", "c"},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		expected := test.expected
+		got, gotProjs := lib.OrderProjectsByWeight(test.names, test.projs)
+		if !reflect.DeepEqual(got, expected) || len(gotProjs) != len(test.projs) {
+			t.Errorf(
+				"test number %d, expected '%v', got '%v', test case: %+v",
+				index+1, expected, got, test,
+			)
+		}
+	}
+}
+
 func TestActorHit(t *testing.T) {
 	// Variables
 	var (
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -240,7 +244,14 @@ func GetRateLimits(gctx context.Context, ctx *Ctx, gcs []*github.Client, core bo
 }
 
 // GHClient - get GitHub client
+// GitHub App installations are used instead of tokens when GHA2DB_GITHUB_APP_ID is set
 func GHClient(ctx *Ctx) (ghCtx context.Context, clients []*github.Client) {
+	ghCtx = context.Background()
+	if ctx.GitHubAppID > 0 {
+		clients = GitHubAppClients(ghCtx, ctx)
+		return
+	}
+
 	// Get GitHub OAuth from env or from file
 	oAuth := ctx.GitHubOAuth
 	if strings.Contains(ctx.GitHubOAuth, "/") {
@@ -249,13 +260,12 @@ func GHClient(ctx *Ctx) (ghCtx context.Context, clients []*github.Client) {
 		oAuth = strings.TrimSpace(string(bytes))
 	}
 
-	// GitHub authentication or use public access
-	ghCtx = context.Background()
-	if oAuth == "-" {
+	// GitHub authentication or use public access, classic and fine-grained personal access tokens are used the same way
+	oAuths := GitHubTokens(oAuth)
+	if oAuth == "-" || len(oAuths) == 0 {
 		client := github.NewClient(nil)
 		clients = append(clients, client)
 	} else {
-		oAuths := strings.Split(oAuth, ",")
 		for _, auth := range oAuths {
 			ts := oauth2.StaticTokenSource(
 				&oauth2.Token{AccessToken: auth},
@@ -268,12 +278,142 @@ func GHClient(ctx *Ctx) (ghCtx context.Context, clients []*github.Client) {
 	return
 }
 
+var (
+	// ghRateGateMtx - protects ghRateGateUntil
+	ghRateGateMtx = &sync.Mutex{}
+	// ghRateGateUntil - no GitHub API calls should be made before that time (shared by all goroutines)
+	ghRateGateUntil time.Time
+)
+
+// RetryAfterFromHeader - returns wait duration requested via Retry-After (seconds or HTTP date)
+// or x-ratelimit-reset (unix timestamp) headers, second value is false when none of them is usable
+func RetryAfterFromHeader(hdr http.Header, now time.Time) (time.Duration, bool) {
+	if hdr == nil {
+		return 0, false
+	}
+	if ra := strings.TrimSpace(hdr.Get("Retry-After")); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if dt, err := http.ParseTime(ra); err == nil {
+			if dt.Before(now) {
+				return 0, true
+			}
+			return dt.Sub(now), true
+		}
+	}
+	if rs := strings.TrimSpace(hdr.Get("X-RateLimit-Reset")); rs != "" {
+		if ts, err := strconv.ParseInt(rs, 10, 64); err == nil && ts > 0 {
+			dt := time.Unix(ts, 0)
+			if dt.Before(now) {
+				return 0, true
+			}
+			return dt.Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// GHRetryAfter - returns wait duration requested by GitHub in an error response
+// second value is false when error doesn't carry such information
+func GHRetryAfter(err error) (time.Duration, bool) {
+	var resp *http.Response
+	switch e := err.(type) {
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter, true
+		}
+		resp = e.Response
+	case *github.RateLimitError:
+		if !e.Rate.Reset.Time.IsZero() {
+			d := time.Until(e.Rate.Reset.Time)
+			if d < 0 {
+				d = 0
+			}
+			return d, true
+		}
+		resp = e.Response
+	case *github.ErrorResponse:
+		resp = e.Response
+	}
+	if resp == nil {
+		return 0, false
+	}
+	return RetryAfterFromHeader(resp.Header, time.Now())
+}
+
+// GHAbuseWait - returns how long to wait after secondary rate limit (abuse) error
+// Uses exact duration requested by GitHub when available, exponential backoff based on trial number otherwise
+// Adds up to 10% random jitter so goroutines don't retry all at once
+func GHAbuseWait(err error, tr int) time.Duration {
+	wait, ok := GHRetryAfter(err)
+	if !ok {
+		wait = time.Duration(int(math.Pow(2.0, float64(tr+3)))) * time.Second
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/10+1))
+}
+
+// GHRateGateClose - don't allow any GitHub API calls (from all goroutines) for the next wait duration
+func GHRateGateClose(wait time.Duration) {
+	until := time.Now().Add(wait)
+	ghRateGateMtx.Lock()
+	if until.After(ghRateGateUntil) {
+		ghRateGateUntil = until
+	}
+	ghRateGateMtx.Unlock()
+}
+
+// GHRateGateWait - waits until GitHub API calls are allowed again
+func GHRateGateWait() {
+	ghRateGateMtx.Lock()
+	until := ghRateGateUntil
+	ghRateGateMtx.Unlock()
+	if d := time.Until(until); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// GHRateGateSleep - closes GitHub API rate gate for wait duration and waits for it to open
+func GHRateGateSleep(wait time.Duration) {
+	GHRateGateClose(wait)
+	GHRateGateWait()
+}
+
+// isSecondaryRateLimit - GitHub reports secondary rate limits as 403/429 with Retry-After header or specific message
+// older go-github versions only recognize the old "abuse" documentation URL
+func isSecondaryRateLimit(err error) bool {
+	e, ok := err.(*github.ErrorResponse)
+	if !ok || e.Response == nil {
+		return false
+	}
+	if e.Response.StatusCode != http.StatusForbidden && e.Response.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	if e.Response.Header.Get("Retry-After") != "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(e.Message), "secondary rate limit")
+}
+
 // HandlePossibleError - display error specific message, detect rate limit and abuse
+// When GitHub tells how long to wait after secondary rate limit (abuse), closes the shared rate gate for that period
+// Primary rate limit is per token, so it doesn't close the gate: callers switch to another token via GetRateLimits
 func HandlePossibleError(err error, cfg, info string) string {
 	if err != nil {
 		_, rate := err.(*github.RateLimitError)
 		_, abuse := err.(*github.AbuseRateLimitError)
+		if !abuse && !rate {
+			abuse = isSecondaryRateLimit(err)
+		}
 		if abuse || rate {
+			if abuse {
+				if wait, ok := GHRetryAfter(err); ok {
+					GHRateGateClose(wait)
+				}
+			}
 			if rate {
 				Printf("Rate limit (%s) for %v\n", info, cfg)
 				return "rate"
@@ -410,18 +550,14 @@ func ghMilestone(con *sql.Tx, ctx *Ctx, eid int64, ic *IssueConfig, maybeHide fu
 	)
 }
 
-// GetRecentRepos - get list of repos active last day
+// GetRecentRepos - get list of repos active since dtFrom
+// Uses gha_recent_repos summary maintained by gha2db (created by Migrate in older databases), falls back to scanning gha_events when it does not exist
 func GetRecentRepos(c *sql.DB, ctx *Ctx, dtFrom time.Time) (repos []string, rids []int64) {
-	rows := QuerySQLWithErr(
-		c,
-		ctx,
-		fmt.Sprintf(
-			"select distinct repo_id, dup_repo_name from gha_events "+
-				"where created_at > %s",
-			NValue(1),
-		),
-		dtFrom,
-	)
+	query := "select distinct repo_id, dup_repo_name from gha_events where created_at > %s"
+	if TableExists(c, ctx, "gha_recent_repos") {
+		query = "select repo_id, repo_name from gha_recent_repos where last_event_at > %s"
+	}
+	rows := QuerySQLWithErr(c, ctx, fmt.Sprintf(query, NValue(1)), dtFrom)
 	defer func() { FatalOnError(rows.Close()) }()
 	var (
 		repo string
@@ -615,9 +751,9 @@ func ArtificialPREvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig, pr *github.PullReq
 			fmt.Sprintf(
 				"into gha_events("+
 					"id, type, actor_id, repo_id, public, created_at, "+
-					"dup_actor_login, dup_repo_name, org_id, forkee_id) "+
+					"dup_actor_login, dup_repo_name, org_id, forkee_id, project) "+
 					"values(%s, %s, %s, (select coalesce(max(repo_id), -1) from gha_events where dup_repo_name = %s), true, %s, "+
-					"%s, %s, (select max(org_id) from gha_events where dup_repo_name = %s), null)",
+					"%s, %s, (select max(org_id) from gha_events where dup_repo_name = %s), null, %s)",
 				NValue(1),
 				NValue(2),
 				NValue(3),
@@ -626,6 +762,7 @@ func ArtificialPREvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig, pr *github.PullReq
 				NValue(6),
 				NValue(7),
 				NValue(8),
+				NValue(9),
 			),
 		),
 		AnyArray{
@@ -637,6 +774,7 @@ func ArtificialPREvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig, pr *github.PullReq
 			ghActorLoginOrNil(event.Actor, maybeHide),
 			cfg.Repo,
 			cfg.Repo,
+			ctx.Project,
 		}...,
 	)
 
@@ -893,9 +1031,9 @@ func ArtificialEvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig) (err error) {
 			fmt.Sprintf(
 				"into gha_events("+
 					"id, type, actor_id, repo_id, public, created_at, "+
-					"dup_actor_login, dup_repo_name, org_id, forkee_id) "+
+					"dup_actor_login, dup_repo_name, org_id, forkee_id, project) "+
 					"values(%s, %s, %s, (select coalesce(max(repo_id), -1) from gha_events where dup_repo_name = %s), true, %s, "+
-					"%s, %s, (select max(org_id) from gha_events where dup_repo_name = %s), null)",
+					"%s, %s, (select max(org_id) from gha_events where dup_repo_name = %s), null, %s)",
 				NValue(1),
 				NValue(2),
 				NValue(3),
@@ -904,6 +1042,7 @@ func ArtificialEvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig) (err error) {
 				NValue(6),
 				NValue(7),
 				NValue(8),
+				NValue(9),
 			),
 		),
 		AnyArray{
@@ -915,6 +1054,7 @@ func ArtificialEvent(c *sql.DB, ctx *Ctx, cfg *IssueConfig) (err error) {
 			ghActorLoginOrNil(event.Actor, maybeHide),
 			cfg.Repo,
 			cfg.Repo,
+			ctx.Project,
 		}...,
 	)
 
@@ -0,0 +1,140 @@
+package devstatscode
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Kinds of GitHub API objects saved in raw snapshots
+const (
+	GHAPIRawCommits      = "commits"
+	GHAPIRawIssueEvents  = "issue_events"
+	GHAPIRawPullRequests = "pull_requests"
+)
+
+// GHAPIRawRecord - single line of a raw snapshot file: GitHub API object as fetched by ghapi2db and where/when it was fetched
+type GHAPIRawRecord struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	Kind      string              `json:"kind"`
+	Repo      string              `json:"repo"`
+	Data      jsoniter.RawMessage `json:"data"`
+}
+
+// ghAPIRawFile - open snapshot file, each run appends a new gzip member to it
+type ghAPIRawFile struct {
+	f  *os.File
+	zw *gzip.Writer
+	n  int
+}
+
+// GHAPIRaw - appends fetched GitHub API objects to GHA2DB_GHAPI_RAW_DIR, so they can be re-processed later without spending API points
+// It is safe for concurrent use, nil GHAPIRaw (snapshots disabled) does nothing
+type GHAPIRaw struct {
+	mtx   sync.Mutex
+	dir   string
+	db    string
+	files map[string]*ghAPIRawFile
+}
+
+// NewGHAPIRaw - returns raw snapshots writer, nil when GHA2DB_GHAPI_RAW_DIR is not set
+func NewGHAPIRaw(ctx *Ctx) *GHAPIRaw {
+	if ctx.GHAPIRawDir == "" {
+		return nil
+	}
+	return &GHAPIRaw{dir: ctx.GHAPIRawDir, db: ctx.PgDB, files: make(map[string]*ghAPIRawFile)}
+}
+
+// GHAPIRawFileName - returns snapshot file name for a given database, object kind and fetch date: dir/YYYY-MM-DD/db_kind.jsonl.gz
+// Database is a part of the name, so syncs of different projects can run at the same time
+func GHAPIRawFileName(dir, db, kind string, dt time.Time) string {
+	return filepath.Join(dir, ToYMDDate(dt), db+"_"+kind+".jsonl.gz")
+}
+
+// Write - appends a given object fetched from a given repo to the current date's snapshot file of a given kind
+func (r *GHAPIRaw) Write(kind, repo string, obj interface{}) {
+	if r == nil {
+		return
+	}
+	data, err := jsoniter.Marshal(obj)
+	FatalOnError(err)
+	now := time.Now().UTC()
+	line, err := jsoniter.Marshal(GHAPIRawRecord{FetchedAt: now, Kind: kind, Repo: repo, Data: data})
+	FatalOnError(err)
+	fn := GHAPIRawFileName(r.dir, r.db, kind, now)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	file, ok := r.files[fn]
+	if !ok {
+		FatalOnError(os.MkdirAll(filepath.Dir(fn), 0755))
+		f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		FatalOnError(err)
+		file = &ghAPIRawFile{f: f, zw: gzip.NewWriter(f)}
+		r.files[fn] = file
+	}
+	_, err = file.zw.Write(append(line, '\n'))
+	FatalOnError(err)
+	file.n++
+}
+
+// Close - finishes gzip members and closes all snapshot files, must be called at the end of a run (data written since the last Close is lost otherwise)
+func (r *GHAPIRaw) Close() {
+	if r == nil {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	names := []string{}
+	for fn := range r.files {
+		names = append(names, fn)
+	}
+	sort.Strings(names)
+	for _, fn := range names {
+		file := r.files[fn]
+		FatalOnError(file.zw.Close())
+		FatalOnError(file.f.Close())
+		Printf("Raw GitHub API snapshot: %d objects appended to %s\n", file.n, fn)
+	}
+	r.files = make(map[string]*ghAPIRawFile)
+}
+
+// ReadGHAPIRaw - calls f for each record of a given snapshot file (all gzip members, in order they were appended)
+func ReadGHAPIRaw(fn string, f func(*GHAPIRawRecord) error) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+	reader := bufio.NewReader(zr)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var rec GHAPIRawRecord
+			if e := jsoniter.Unmarshal(line, &rec); e != nil {
+				return e
+			}
+			if e := f(&rec); e != nil {
+				return e
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
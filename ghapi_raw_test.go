@@ -0,0 +1,87 @@
+package devstatscode
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestGHAPIRaw(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghapi_raw")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	var ctx lib.Ctx
+	ctx.PgDB = "gha"
+
+	// Snapshots are disabled by default
+	if raw := lib.NewGHAPIRaw(&ctx); raw != nil {
+		t.Fatalf("expected no raw snapshots writer when GHA2DB_GHAPI_RAW_DIR is not set")
+	}
+	var raw *lib.GHAPIRaw
+	raw.Write(lib.GHAPIRawCommits, "org/repo", nil)
+	raw.Close()
+
+	// Two runs append two gzip members to the same file
+	ctx.GHAPIRawDir = dir
+	for run := 1; run <= 2; run++ {
+		raw = lib.NewGHAPIRaw(&ctx)
+		raw.Write(lib.GHAPIRawPullRequests, "org/repo", &github.PullRequest{Number: github.Int(run), Title: github.String("PR")})
+		raw.Write(lib.GHAPIRawPullRequests, "org/repo2", &github.PullRequest{Number: github.Int(10 + run)})
+		raw.Write(lib.GHAPIRawIssueEvents, "org/repo", &github.IssueEvent{ID: github.Int64(int64(run))})
+		raw.Close()
+	}
+
+	fn := lib.GHAPIRawFileName(dir, "gha", lib.GHAPIRawPullRequests, time.Now().UTC())
+	numbers := []int{}
+	repos := []string{}
+	err = lib.ReadGHAPIRaw(
+		fn,
+		func(rec *lib.GHAPIRawRecord) error {
+			if rec.Kind != lib.GHAPIRawPullRequests {
+				t.Errorf("expected kind %s, got %s", lib.GHAPIRawPullRequests, rec.Kind)
+			}
+			var pr github.PullRequest
+			if err := jsoniter.Unmarshal(rec.Data, &pr); err != nil {
+				return err
+			}
+			numbers = append(numbers, pr.GetNumber())
+			repos = append(repos, rec.Repo)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("cannot read snapshot: %v", err)
+	}
+	expected := []int{1, 11, 2, 12}
+	if len(numbers) != len(expected) {
+		t.Fatalf("expected PRs %v, got %v", expected, numbers)
+	}
+	for i := range expected {
+		if numbers[i] != expected[i] {
+			t.Errorf("expected PRs %v, got %v", expected, numbers)
+			break
+		}
+	}
+	if repos[0] != "org/repo" || repos[1] != "org/repo2" {
+		t.Errorf("unexpected repos %v", repos)
+	}
+
+	n := 0
+	err = lib.ReadGHAPIRaw(
+		lib.GHAPIRawFileName(dir, "gha", lib.GHAPIRawIssueEvents, time.Now().UTC()),
+		func(rec *lib.GHAPIRawRecord) error {
+			n++
+			return nil
+		},
+	)
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 issue events, got %d (error %v)", n, err)
+	}
+}
@@ -0,0 +1,64 @@
+package devstatscode
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+)
+
+func TestRetryAfterFromHeader(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	var testCases = []struct {
+		headers  map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{headers: map[string]string{}, expected: 0, ok: false},
+		{headers: map[string]string{"Retry-After": "60"}, expected: time.Minute, ok: true},
+		{headers: map[string]string{"Retry-After": "0"}, expected: 0, ok: true},
+		{headers: map[string]string{"Retry-After": "Tue, 01 Jun 2021 12:00:30 GMT"}, expected: 30 * time.Second, ok: true},
+		{headers: map[string]string{"Retry-After": "Tue, 01 Jun 2021 11:00:00 GMT"}, expected: 0, ok: true},
+		{headers: map[string]string{"Retry-After": "xyz"}, expected: 0, ok: false},
+		{headers: map[string]string{"X-RateLimit-Reset": "1622548920"}, expected: 2 * time.Minute, ok: true},
+		{headers: map[string]string{"X-RateLimit-Reset": "1622548000"}, expected: 0, ok: true},
+		{headers: map[string]string{"Retry-After": "5", "X-RateLimit-Reset": "1622548920"}, expected: 5 * time.Second, ok: true},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		hdr := http.Header{}
+		for k, v := range test.headers {
+			hdr.Set(k, v)
+		}
+		got, ok := lib.RetryAfterFromHeader(hdr, now)
+		if got != test.expected || ok != test.ok {
+			t.Errorf(
+				"test number %d, expected (%v, %v), got (%v, %v), test case: %+v",
+				index+1, test.expected, test.ok, got, ok, test,
+			)
+		}
+	}
+}
+
+func TestHandlePossibleErrorRateGate(t *testing.T) {
+	// Primary rate limit of one token must not block other tokens
+	reset := github.Timestamp{Time: time.Now().Add(time.Hour)}
+	res := lib.HandlePossibleError(&github.RateLimitError{Rate: github.Rate{Reset: reset}}, "org/repo", "test")
+	if res != "rate" {
+		t.Errorf("expected 'rate', got '%s'", res)
+	}
+	// Secondary rate limit closes the gate for the requested period
+	wait := 200 * time.Millisecond
+	res = lib.HandlePossibleError(&github.AbuseRateLimitError{RetryAfter: &wait}, "org/repo", "test")
+	if res != lib.Abuse {
+		t.Errorf("expected '%s', got '%s'", lib.Abuse, res)
+	}
+	dtStart := time.Now()
+	lib.GHRateGateWait()
+	took := time.Since(dtStart)
+	if took < wait/2 || took > 10*wait {
+		t.Errorf("expected rate gate to be closed for about %v, waited %v", wait, took)
+	}
+}
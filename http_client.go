@@ -0,0 +1,224 @@
+package devstatscode
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxHTTPBackoff - maximum wait time between HTTP request retries
+const maxHTTPBackoff = 5 * time.Minute
+
+// HTTPHostStats - statistics of requests made by HTTPClient to a single host
+type HTTPHostStats struct {
+	Requests int
+	Retries  int
+	Errors   int
+	Duration time.Duration
+}
+
+var (
+	httpTransportsMtx sync.Mutex
+	// httpTransports - transports are shared between clients (to reuse connections), one per CA bundle
+	httpTransports = map[string]*http.Transport{}
+	httpStatsMtx   sync.Mutex
+	httpStats      = map[string]*HTTPHostStats{}
+)
+
+// retryTransport - retries idempotent requests failed with network errors, 429 or 5xx responses and counts per host statistics
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	backoff time.Duration
+	debug   bool
+}
+
+// httpTransport - returns shared transport with proxy from environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY)
+// and CA certificates from caBundle added to system ones
+func httpTransport(caBundle string) (*http.Transport, error) {
+	httpTransportsMtx.Lock()
+	defer httpTransportsMtx.Unlock()
+	if t, ok := httpTransports[caBundle]; ok {
+		return t, nil
+	}
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if caBundle != "" {
+		pem, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle '%s'", caBundle)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	httpTransports[caBundle] = t
+	return t, nil
+}
+
+// HTTPClient - returns HTTP client honoring HTTP_PROXY/HTTPS_PROXY/NO_PROXY and custom CA bundle (GHA2DB_HTTP_CA_BUNDLE)
+// Idempotent requests are retried GHA2DB_HTTP_CLIENT_RETRIES times with exponential backoff, client timeout (GHA2DB_HTTP_TIMEOUT) includes retries
+// Every request is counted in per host statistics, see HTTPStats
+func HTTPClient(ctx *Ctx) *http.Client {
+	base, err := httpTransport(ctx.HTTPCABundle)
+	FatalOnError(err)
+	return &http.Client{
+		Timeout: time.Minute * time.Duration(ctx.HTTPTimeout),
+		Transport: &retryTransport{
+			base:    base,
+			retries: ctx.HTTPClientRetries,
+			backoff: ctx.HTTPClientBackoff,
+			debug:   ctx.Debug > 0,
+		},
+	}
+}
+
+// retryable - checks if request should be retried after a given response or error
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		return false
+	}
+	// Request body cannot be sent again
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryWait - returns wait time before retry number try (0 based), Retry-After header (in seconds) takes precedence
+func (t *retryTransport) retryWait(try int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			wait := time.Duration(secs) * time.Second
+			if wait > maxHTTPBackoff {
+				wait = maxHTTPBackoff
+			}
+			return wait
+		}
+	}
+	wait := t.backoff << uint(try)
+	if wait <= 0 || wait > maxHTTPBackoff {
+		wait = maxHTTPBackoff
+	}
+	// Up to 25% random jitter so parallel clients don't retry at the same time
+	if jitter := int64(wait / 4); jitter > 0 {
+		wait += time.Duration(rand.Int63n(jitter))
+	}
+	return wait
+}
+
+// RoundTrip - executes request with retries
+func (t *retryTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	dtStart := time.Now()
+	retries := 0
+	for try := 0; ; try++ {
+		resp, err = t.base.RoundTrip(req)
+		if try >= t.retries || !retryable(req, resp, err) {
+			break
+		}
+		wait := t.retryWait(try, resp)
+		if resp != nil {
+			if t.debug {
+				Printf("HTTP %s %s: status %d, retry %d/%d in %v\n", req.Method, req.URL.Redacted(), resp.StatusCode, try+1, t.retries, wait)
+			}
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+			resp = nil
+		} else if t.debug {
+			Printf("HTTP %s %s: %v, retry %d/%d in %v\n", req.Method, req.URL.Redacted(), err, try+1, t.retries, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if req.Context().Err() != nil {
+			err = req.Context().Err()
+			break
+		}
+		retries++
+	}
+	dur := time.Since(dtStart)
+	httpStatsMtx.Lock()
+	stats, ok := httpStats[req.URL.Host]
+	if !ok {
+		stats = &HTTPHostStats{}
+		httpStats[req.URL.Host] = stats
+	}
+	stats.Requests++
+	stats.Retries += retries
+	stats.Duration += dur
+	if err != nil || resp.StatusCode >= 400 {
+		stats.Errors++
+	}
+	httpStatsMtx.Unlock()
+	if t.debug {
+		if err != nil {
+			Printf("HTTP %s %s: %v (%v, %d retries)\n", req.Method, req.URL.Redacted(), err, dur, retries)
+		} else {
+			Printf("HTTP %s %s: status %d (%v, %d retries)\n", req.Method, req.URL.Redacted(), resp.StatusCode, dur, retries)
+		}
+	}
+	return
+}
+
+// HTTPStats - returns statistics of requests made by HTTPClient so far, per host
+func HTTPStats() map[string]HTTPHostStats {
+	httpStatsMtx.Lock()
+	defer httpStatsMtx.Unlock()
+	ret := make(map[string]HTTPHostStats)
+	for host, stats := range httpStats {
+		ret[host] = *stats
+	}
+	return ret
+}
+
+// ReportHTTPStats - prints statistics of requests made by HTTPClient (if any)
+func ReportHTTPStats() {
+	stats := HTTPStats()
+	if len(stats) == 0 {
+		return
+	}
+	hosts := []string{}
+	for host := range stats {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		s := stats[host]
+		Printf("HTTP %s: %d requests, %d retries, %d errors, total time %v\n", host, s.Requests, s.Retries, s.Errors, s.Duration)
+	}
+}
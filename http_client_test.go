@@ -0,0 +1,114 @@
+package devstatscode
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestHTTPClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail first two calls
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	var ctx lib.Ctx
+	ctx.HTTPTimeout = 1
+	ctx.HTTPClientRetries = 2
+	ctx.HTTPClientBackoff = time.Millisecond
+	client := lib.HTTPClient(&ctx)
+
+	// Recovers after 2 retries
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("expected 200 'ok', got %d '%s'", resp.StatusCode, string(body))
+	}
+	stats := lib.HTTPStats()[u.Host]
+	if stats.Requests != 1 || stats.Retries != 2 || stats.Errors != 0 {
+		t.Errorf("expected 1 request with 2 retries and no errors, got %+v", stats)
+	}
+
+	// Non idempotent requests are not retried
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected single 503 call, got %d after %d calls", resp.StatusCode, calls)
+	}
+
+	// Gives up after configured retries
+	atomic.StoreInt32(&calls, -10)
+	resp, err = client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(&calls) != -7 {
+		t.Errorf("expected 503 after 3 calls, got %d after %d calls", resp.StatusCode, calls+10)
+	}
+	stats = lib.HTTPStats()[u.Host]
+	if stats.Requests != 3 || stats.Retries != 4 || stats.Errors != 2 {
+		t.Errorf("expected 3 requests with 4 retries and 2 errors, got %+v", stats)
+	}
+}
+
+func TestHTTPClientCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var ctx lib.Ctx
+	ctx.HTTPTimeout = 1
+
+	// Test server certificate is not trusted by default
+	_, err := lib.HTTPClient(&ctx).Get(srv.URL)
+	if err == nil {
+		t.Errorf("expected certificate error")
+	}
+
+	// Trusted when its certificate is in CA bundle
+	f, err := ioutil.TempFile("", "ca*.pem")
+	if err != nil {
+		t.Fatalf("cannot create CA bundle: %v", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	err = pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("cannot write CA bundle: %v", err)
+	}
+	ctx.HTTPCABundle = f.Name()
+	resp, err := lib.HTTPClient(&ctx).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+}
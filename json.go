@@ -1,7 +1,11 @@
 package devstatscode
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"regexp"
 
 	jsoniter "github.com/json-iterator/go"
 )
@@ -22,3 +26,80 @@ func ObjectToJSON(obj interface{}, fn string) {
 	pretty := PrettyPrintJSON(jsonBytes)
 	FatalOnError(ioutil.WriteFile(fn, pretty, 0644))
 }
+
+// CheckJSONLimits - returns error when decoded JSON value is nested deeper than maxDepth or has an array longer than maxArray
+// Top level object has depth 1, limits < 1 are not checked
+func CheckJSONLimits(v interface{}, maxDepth, maxArray int) error {
+	return checkJSONLimits(v, 1, maxDepth, maxArray, "$")
+}
+
+func checkJSONLimits(v interface{}, depth, maxDepth, maxArray int, path string) error {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if maxDepth > 0 && depth > maxDepth {
+			return fmt.Errorf("%s: nesting depth exceeds %d", path, maxDepth)
+		}
+		for key, item := range value {
+			err := checkJSONLimits(item, depth+1, maxDepth, maxArray, path+"."+key)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if maxDepth > 0 && depth > maxDepth {
+			return fmt.Errorf("%s: nesting depth exceeds %d", path, maxDepth)
+		}
+		if maxArray > 0 && len(value) > maxArray {
+			return fmt.Errorf("%s: array length %d exceeds %d", path, len(value), maxArray)
+		}
+		for i, item := range value {
+			err := checkJSONLimits(item, depth+1, maxDepth, maxArray, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// JSONPCallbackRE - allowed JSONP callback names: JavaScript identifiers, optionally dotted (for example "cb" or "DevStats.onData")
+var JSONPCallbackRE = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// FormatJSONResponse - returns JSON response body indented (pretty) and/or wrapped in a JSONP callback call (when callback is not empty)
+// Keys order is preserved, body that is not a valid JSON is not indented, callback must match JSONPCallbackRE
+func FormatJSONResponse(body []byte, pretty bool, callback string) []byte {
+	body = bytes.TrimSpace(body)
+	if pretty {
+		var buf bytes.Buffer
+		if json.Indent(&buf, body, "", "  ") == nil {
+			body = buf.Bytes()
+		}
+	}
+	if callback == "" {
+		return append(body, '\n')
+	}
+	// Comment prefix prevents content sniffing attacks (the response never starts with attacker controlled bytes)
+	return []byte(fmt.Sprintf("/**/%s(%s);\n", callback, body))
+}
+
+// AddJSONField - returns JSON object body with a given field (name and JSON value) added as the last one, keys order is preserved
+// Body that is not a valid JSON object (for example array or CSV) is returned unchanged
+func AddJSONField(body []byte, name string, value []byte) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) < 2 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return body
+	}
+	key, _ := json.Marshal(name)
+	inner := bytes.TrimSpace(trimmed[1 : len(trimmed)-1])
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if len(inner) > 0 {
+		buf.Write(inner)
+		buf.WriteByte(',')
+	}
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(value)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
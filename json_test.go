@@ -0,0 +1,104 @@
+package devstatscode
+
+import (
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestCheckJSONLimits(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		json     string
+		maxDepth int
+		maxArray int
+		errStr   string
+	}{
+		{json: `{"api":"Health","payload":{"project":"kubernetes"}}`, maxDepth: 2, maxArray: 10},
+		{json: `{"api":"Health","payload":{"project":"kubernetes"}}`, maxDepth: 1, maxArray: 10, errStr: "$.payload: nesting depth exceeds 1"},
+		{json: `{"payload":{"labels":["a","b","c"]}}`, maxDepth: 3, maxArray: 3},
+		{json: `{"payload":{"labels":["a","b","c"]}}`, maxDepth: 3, maxArray: 2, errStr: "$.payload.labels: array length 3 exceeds 2"},
+		{json: `{"payload":{"labels":["a","b","c"]}}`, maxDepth: 2, maxArray: 3, errStr: "$.payload.labels: nesting depth exceeds 2"},
+		{json: `{"a":[[[[1]]]]}`, maxDepth: 4, maxArray: 0, errStr: "$.a[0][0][0]: nesting depth exceeds 4"},
+		{json: `{"a":[[[[1]]]]}`, maxDepth: 0, maxArray: 0},
+		{json: `[1,2,3]`, maxDepth: 1, maxArray: 2, errStr: "$: array length 3 exceeds 2"},
+		{json: `"scalar"`, maxDepth: 1, maxArray: 1},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		var v interface{}
+		err := jsoniter.Unmarshal([]byte(test.json), &v)
+		if err != nil {
+			t.Errorf("test number %d, unexpected error: %v", index+1, err)
+			continue
+		}
+		err = lib.CheckJSONLimits(v, test.maxDepth, test.maxArray)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.errStr {
+			t.Errorf("test number %d, expected error '%s', got '%s'", index+1, test.errStr, errStr)
+		}
+	}
+}
+
+func TestFormatJSONResponse(t *testing.T) {
+	body := []byte(`{"b":1,"a":[1,2]}` + "\n")
+	// Test cases
+	var testCases = []struct {
+		body     []byte
+		pretty   bool
+		callback string
+		expected string
+	}{
+		{body: body, expected: `{"b":1,"a":[1,2]}` + "\n"},
+		{body: body, pretty: true, expected: "{\n  \"b\": 1,\n  \"a\": [\n    1,\n    2\n  ]\n}\n"},
+		{body: body, callback: "cb", expected: `/**/cb({"b":1,"a":[1,2]});` + "\n"},
+		{body: body, pretty: true, callback: "DevStats.onData", expected: "/**/DevStats.onData({\n  \"b\": 1,\n  \"a\": [\n    1,\n    2\n  ]\n});\n"},
+		{body: []byte("not json"), pretty: true, expected: "not json\n"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := string(lib.FormatJSONResponse(test.body, test.pretty, test.callback))
+		if got != test.expected {
+			t.Errorf("test number %d, expected:\n%q\ngot:\n%q", index+1, test.expected, got)
+		}
+	}
+	// Callback names
+	for _, cb := range []string{"cb", "_cb1", "$", "jQuery123_456", "DevStats.onData"} {
+		if !lib.JSONPCallbackRE.MatchString(cb) {
+			t.Errorf("expected valid callback '%s'", cb)
+		}
+	}
+	for _, cb := range []string{"", "1cb", "cb()", "alert(1);cb", "cb.", "a b", "cb\n"} {
+		if lib.JSONPCallbackRE.MatchString(cb) {
+			t.Errorf("expected invalid callback '%s'", cb)
+		}
+	}
+}
+
+func TestAddJSONField(t *testing.T) {
+	value := []byte(`{"db":"gha"}`)
+	// Test cases
+	var testCases = []struct {
+		body     string
+		expected string
+	}{
+		{body: `{"b":1,"a":[1,2]}` + "\n", expected: `{"b":1,"a":[1,2],"meta":{"db":"gha"}}` + "\n"},
+		{body: `{ }`, expected: `{"meta":{"db":"gha"}}` + "\n"},
+		{body: `{"error":"x"}`, expected: `{"error":"x","meta":{"db":"gha"}}` + "\n"},
+		{body: `[1,2]`, expected: `[1,2]`},
+		{body: `{"a":`, expected: `{"a":`},
+		{body: "a,b\n1,2\n", expected: "a,b\n1,2\n"},
+		{body: "", expected: ""},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := string(lib.AddJSONField([]byte(test.body), "meta", value))
+		if got != test.expected {
+			t.Errorf("test number %d, expected:\n%q\ngot:\n%q", index+1, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package trailers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	var testCases = []struct {
+		msg      string
+		expected []Role
+	}{
+		{msg: "", expected: nil},
+		{msg: "Fix typo\n\nNo trailers here", expected: nil},
+		{
+			msg:      "Fix typo\n\nSigned-off-by: John Doe <john@doe.com>",
+			expected: []Role{{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Signed-off-by"}}},
+		},
+		{
+			msg: "Add feature\r\nCo-authored-by: Jane <jane@x.org>\r\nacked-and-tested-by: Bob <bob@y.org>",
+			expected: []Role{
+				{Name: "Jane", Email: "jane@x.org", Roles: []string{"Co-authored-by"}},
+				{Name: "Bob", Email: "bob@y.org", Roles: []string{"Reviewed-by", "Tested-by"}},
+			},
+		},
+		{msg: "Signed-off-by: No Email", expected: nil},
+		{msg: "Signed-off-by: <no-name@x.org>", expected: nil},
+		{msg: "Unknown-trailer: John Doe <john@doe.com>", expected: nil},
+		{msg: "Signed-off-by:John Doe <john@doe.com>", expected: nil},
+		{
+			msg:      "Signed-off-by: John Doe <john@doe.com",
+			expected: []Role{{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Signed-off-by"}}},
+		},
+		{
+			msg:      "SIGNED-OFF-BY:\tJohn Doe   <john@doe.com>  ",
+			expected: []Role{{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Signed-off-by"}}},
+		},
+		// Kubernetes commits
+		{
+			msg: "Merge pull request #97524 from gavinfish/sched-fitpredicate\n\n" +
+				"Scheduler: remove duplicated fit predicates\n\n" +
+				"Kubernetes-commit: 3bd3a2d4a5e2e0b8e7d1c4e1a0a5f3f1c9b8d7e6",
+			expected: nil,
+		},
+		{
+			msg: "Update CHANGELOG for v1.20.2\n\n" +
+				"Signed-off-by: Stephen Augustus <saugustus@vmware.com>\n" +
+				"Co-authored-by: Sascha Grunert <sgrunert@redhat.com>",
+			expected: []Role{
+				{Name: "Stephen Augustus", Email: "saugustus@vmware.com", Roles: []string{"Signed-off-by"}},
+				{Name: "Sascha Grunert", Email: "sgrunert@redhat.com", Roles: []string{"Co-authored-by"}},
+			},
+		},
+		{
+			msg: "kubelet: fix pod resize status\n\n" +
+				"Reported-by: Jordan Liggitt <liggitt@google.com>\n" +
+				"Suggested-by: Tim Hockin <thockin@google.com>\n" +
+				"Reviewed-by: Clayton Coleman <ccoleman@redhat.com>\n" +
+				"Tested-by: Jane Doe <jane@example.com>",
+			expected: []Role{
+				{Name: "Jordan Liggitt", Email: "liggitt@google.com", Roles: []string{"Reported-by"}},
+				{Name: "Tim Hockin", Email: "thockin@google.com", Roles: []string{"Reviewed-by"}},
+				{Name: "Clayton Coleman", Email: "ccoleman@redhat.com", Roles: []string{"Reviewed-by"}},
+				{Name: "Jane Doe", Email: "jane@example.com", Roles: []string{"Tested-by"}},
+			},
+		},
+		{
+			msg: "Bump golang.org/x/net\n\n" +
+				"Signed-off-by: dependabot[bot] <support@github.com>",
+			expected: []Role{{Name: "dependabot[bot]", Email: "support@github.com", Roles: []string{"Signed-off-by"}}},
+		},
+		// Multiple addresses on a single line
+		{
+			msg: "Co-authored-by: Alice <alice@x.org>, Bob <bob@y.org>",
+			expected: []Role{
+				{Name: "Alice", Email: "alice@x.org", Roles: []string{"Co-authored-by"}},
+				{Name: "Bob", Email: "bob@y.org", Roles: []string{"Co-authored-by"}},
+			},
+		},
+		{
+			msg: "Reviewed-by: Alice <alice@x.org>; Bob Smith <bob@y.org> and Carol <carol@z.org>",
+			expected: []Role{
+				{Name: "Alice", Email: "alice@x.org", Roles: []string{"Reviewed-by"}},
+				{Name: "Bob Smith", Email: "bob@y.org", Roles: []string{"Reviewed-by"}},
+				{Name: "Carol", Email: "carol@z.org", Roles: []string{"Reviewed-by"}},
+			},
+		},
+		{
+			msg: "Co-authored-by: Alice <alice@x.org>, <anonymous@y.org>, Bob <bob@y.org>",
+			expected: []Role{
+				{Name: "Alice", Email: "alice@x.org", Roles: []string{"Co-authored-by"}},
+				{Name: "Bob", Email: "bob@y.org", Roles: []string{"Co-authored-by"}},
+			},
+		},
+		// Comments
+		{
+			msg:      "Signed-off-by: John Doe <john@doe.com> (maintainer)",
+			expected: []Role{{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Signed-off-by"}}},
+		},
+		{
+			msg: "Acked-by: John Doe <john@doe.com> (for sig-node), Jane Roe <jane@roe.com> (for sig-api-machinery)",
+			expected: []Role{
+				{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Reviewed-by"}},
+				{Name: "Jane Roe", Email: "jane@roe.com", Roles: []string{"Reviewed-by"}},
+			},
+		},
+		{
+			msg:      "Signed-off-by: John (JD) Doe <john@doe.com>",
+			expected: []Role{{Name: "John Doe", Email: "john@doe.com", Roles: []string{"Signed-off-by"}}},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := ParseTrailers(test.msg)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %+v, got %+v, test case: %+v", index+1, test.expected, got, test)
+		}
+	}
+}
+
+func TestCommitAuthors(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		email    string
+		msg      string
+		expected []Author
+	}{
+		{
+			name:     "John Doe",
+			email:    "john@doe.com",
+			msg:      "Fix\n\nSigned-off-by: John Doe <john@doe.com>",
+			expected: []Author{{Name: "John Doe", Email: "john@doe.com", Source: "header"}},
+		},
+		{
+			name:  "John Doe",
+			email: "john@doe.com",
+			msg:   "Fix\n\nCo-authored-by: Jane <jane@x.org>\nReviewed-by: Bob <bob@y.org>\nCo-authored-by: John <JOHN@doe.com>\nAdditional-author: Alice <alice@x.org>, Jane <Jane@X.org>",
+			expected: []Author{
+				{Name: "John Doe", Email: "john@doe.com", Source: "header"},
+				{Name: "Jane", Email: "jane@x.org", Source: "trailer"},
+				{Name: "Alice", Email: "alice@x.org", Source: "trailer"},
+			},
+		},
+		{
+			msg:      "Co-authored-by: Jane <jane@x.org>",
+			expected: []Author{{Name: "Jane", Email: "jane@x.org", Source: "trailer"}},
+		},
+		{
+			msg: "No authors",
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := CommitAuthors(test.name, test.email, test.msg)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %+v, got %+v, test case: %+v", index+1, test.expected, got, test)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TextLimit - default limit for text columns, they have no DDL limit, but very long values are not useful for metrics
+const TextLimit = 0xffff
+
+// maxPendingTruncations - maximum number of truncated originals kept in memory before they are saved
+const maxPendingTruncations = 10000
+
+// SchemaLimits - maximum lengths (in bytes) of text fields written by gha2db, "table.column" -> limit
+// varchar limits must match structure.go DDL, TextLimit fields are text columns and their limit can be changed via GHA2DB_TRUNC_LIMITS
+var SchemaLimits = map[string]int{
+	"gha_assets.label":                    120,
+	"gha_assets.name":                     200,
+	"gha_branches.label":                  200,
+	"gha_branches.ref":                    200,
+	"gha_comments.body":                   TextLimit,
+	"gha_commits.author_name":             160,
+	"gha_commits.encrypted_email":         160,
+	"gha_commits.message":                 TextLimit,
+	"gha_commits_authors.actor_email":     160,
+	"gha_commits_authors.actor_login":     120,
+	"gha_commits_authors.actor_name":      160,
+	"gha_commits_roles.actor_email":       160,
+	"gha_commits_roles.actor_login":       120,
+	"gha_commits_roles.actor_name":        160,
+	"gha_forkees.default_branch":          200,
+	"gha_forkees.description":             TextLimit,
+	"gha_forkees.full_name":               200,
+	"gha_forkees.name":                    80,
+	"gha_issue_label_history.actor_login": 120,
+	"gha_issue_label_history.label_name":  160,
+	"gha_issue_refs.ref_repo_name":        160,
+	"gha_issue_transfers.actor_login":     120,
+	"gha_issues.body":                     TextLimit,
+	"gha_labels.name":                     160,
+	"gha_milestones.description":          TextLimit,
+	"gha_milestones.title":                200,
+	"gha_pages.title":                     300,
+	"gha_payloads.description":            TextLimit,
+	"gha_payloads.master_branch":          200,
+	"gha_payloads.ref":                    200,
+	"gha_pull_requests.body":              TextLimit,
+	"gha_releases.body":                   TextLimit,
+	"gha_releases.name":                   200,
+	"gha_releases.tag_name":               200,
+	"gha_releases.target_commitish":       200,
+	"gha_reviews.body":                    TextLimit,
+	"gha_teams.name":                      120,
+	"gha_teams.permission":                20,
+	"gha_teams.slug":                      100,
+}
+
+// personalFields - originals of these fields are never stored in gha_truncated (they can be GDPR hidden)
+var personalFields = map[string]struct{}{
+	"gha_commits.author_name":             {},
+	"gha_commits.encrypted_email":         {},
+	"gha_commits_authors.actor_email":     {},
+	"gha_commits_authors.actor_login":     {},
+	"gha_commits_authors.actor_name":      {},
+	"gha_commits_roles.actor_email":       {},
+	"gha_commits_roles.actor_login":       {},
+	"gha_commits_roles.actor_name":        {},
+	"gha_issue_label_history.actor_login": {},
+	"gha_issue_transfers.actor_login":     {},
+}
+
+// Truncation - original value of a truncated field
+type Truncation struct {
+	Field    string
+	Limit    int
+	Original string
+	Dt       time.Time
+}
+
+var (
+	truncMtx     sync.Mutex
+	truncCounts  = map[string]int{}
+	truncPending []Truncation
+	truncDropped int
+)
+
+// ParseTruncLimits - parses "table.column=limit,..." limits overrides
+// Only known fields can be overridden and varchar fields cannot get limits above their DDL sizes
+func ParseTruncLimits(limits string) (map[string]int, error) {
+	ret := make(map[string]int)
+	for _, item := range strings.Split(limits, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ary := strings.Split(item, "=")
+		if len(ary) != 2 {
+			return nil, fmt.Errorf("invalid truncation limit '%s', expected table.column=limit", item)
+		}
+		field := strings.TrimSpace(ary[0])
+		def, ok := SchemaLimits[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown truncation limit field '%s'", field)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(ary[1]))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid truncation limit '%s' for '%s'", ary[1], field)
+		}
+		if def != TextLimit && limit > def {
+			return nil, fmt.Errorf("truncation limit %d for '%s' is above its column size %d", limit, field, def)
+		}
+		ret[field] = limit
+	}
+	return ret, nil
+}
+
+// FieldLimit - returns maximum length of a given "table.column" field, fields not in SchemaLimits are not limited (0)
+func FieldLimit(ctx *Ctx, field string) int {
+	if field == "gha_commits.message" && ctx.CommitMsgLimit > 0 {
+		return ctx.CommitMsgLimit
+	}
+	if limit, ok := ctx.TruncLimits[field]; ok {
+		return limit
+	}
+	return SchemaLimits[field]
+}
+
+// TruncField - truncates value of a given "table.column" field to its limit, counts and reports truncations
+// First truncation of every field is always logged, next ones only in debug mode
+func TruncField(ctx *Ctx, field, str string) string {
+	limit := FieldLimit(ctx, field)
+	if limit <= 0 {
+		return CleanUTF8(str)
+	}
+	res := TruncToBytes(str, limit)
+	if len(res) == len(CleanUTF8(str)) {
+		return res
+	}
+	truncMtx.Lock()
+	truncCounts[field]++
+	n := truncCounts[field]
+	if ctx.TruncAudit {
+		if _, personal := personalFields[field]; !personal {
+			if len(truncPending) < maxPendingTruncations {
+				truncPending = append(truncPending, Truncation{Field: field, Limit: limit, Original: CleanUTF8(str), Dt: time.Now()})
+			} else {
+				truncDropped++
+			}
+		}
+	}
+	truncMtx.Unlock()
+	if n == 1 || ctx.Debug > 0 {
+		Printf("Truncated %s from %d to %d bytes (%d truncations of this field so far)\n", field, len(str), len(res), n)
+	}
+	return res
+}
+
+// TruncFieldOrNil - returns either nil or value of strPtr truncated to a given "table.column" field limit
+func TruncFieldOrNil(ctx *Ctx, field string, strPtr *string) interface{} {
+	if strPtr == nil {
+		return nil
+	}
+	return TruncField(ctx, field, *strPtr)
+}
+
+// TruncationCounts - returns number of truncations per field so far
+func TruncationCounts() map[string]int {
+	truncMtx.Lock()
+	defer truncMtx.Unlock()
+	ret := make(map[string]int)
+	for field, n := range truncCounts {
+		ret[field] = n
+	}
+	return ret
+}
+
+// ReportTruncations - prints number of truncations per field (if any)
+func ReportTruncations() {
+	counts := TruncationCounts()
+	if len(counts) == 0 {
+		return
+	}
+	fields := []string{}
+	for field := range counts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		Printf("Truncations: %s: %d\n", field, counts[field])
+	}
+}
+
+// FlushTruncations - saves truncated originals in gha_truncated table (only when GHA2DB_TRUNC_AUDIT is set)
+func FlushTruncations(con *sql.DB, ctx *Ctx) {
+	if !ctx.TruncAudit {
+		return
+	}
+	truncMtx.Lock()
+	pending, dropped := truncPending, truncDropped
+	truncPending, truncDropped = nil, 0
+	truncMtx.Unlock()
+	if dropped > 0 {
+		Printf("Warning: %d truncated originals were not stored, more than %d truncations between saves\n", dropped, maxPendingTruncations)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	for _, t := range pending {
+		q, args := NewQB("gha_truncated").
+			Set("field", t.Field).
+			Set("trunc_limit", t.Limit).
+			Set("original", t.Original).
+			Set("dt", t.Dt).
+			Insert()
+		ExecSQLWithErr(con, ctx, q, args...)
+	}
+	if ctx.Debug > 0 {
+		Printf("Stored %d truncated originals\n", len(pending))
+	}
+}
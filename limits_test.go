@@ -0,0 +1,98 @@
+package devstatscode
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestSchemaLimitsMatchStructure(t *testing.T) {
+	data, err := ioutil.ReadFile("structure.go")
+	if err != nil {
+		t.Fatalf("cannot read structure.go: %v", err)
+	}
+	src := string(data)
+	for field, limit := range lib.SchemaLimits {
+		ary := strings.Split(field, ".")
+		table, column := ary[0], ary[1]
+		// Tables of the initial structure are created inline, added tables are defined in addedTables
+		var ddl string
+		if i := strings.Index(src, "\""+table+"(\""); i >= 0 {
+			ddl = src[i:]
+			ddl = ddl[:strings.Index(ddl, "\")\",")]
+		} else if i := strings.Index(src, "name: \""+table+"\","); i >= 0 {
+			ddl = src[i:]
+			ddl = ddl[:strings.Index(ddl, "\n\t},")]
+		} else {
+			t.Errorf("%s: table %s not found in structure.go", field, table)
+			continue
+		}
+		m := regexp.MustCompile(`"` + column + ` (varchar\((\d+)\)|text)`).FindStringSubmatch(ddl)
+		if m == nil {
+			t.Errorf("%s: column %s not found in %s DDL", field, column, table)
+			continue
+		}
+		expected := lib.TextLimit
+		if m[2] != "" {
+			expected, _ = strconv.Atoi(m[2])
+		}
+		if limit != expected {
+			t.Errorf("%s: limit %d does not match DDL %s", field, limit, m[1])
+		}
+	}
+}
+
+func TestParseTruncLimits(t *testing.T) {
+	limits, err := lib.ParseTruncLimits(" gha_comments.body=100000,,gha_forkees.name = 40 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(limits) != 2 || limits["gha_comments.body"] != 100000 || limits["gha_forkees.name"] != 40 {
+		t.Errorf("unexpected limits: %+v", limits)
+	}
+	for _, bad := range []string{"gha_forkees.name=81", "gha_unknown.x=10", "gha_comments.body", "gha_comments.body=0", "gha_comments.body=x"} {
+		_, err := lib.ParseTruncLimits(bad)
+		if err == nil {
+			t.Errorf("expected error for '%s'", bad)
+		}
+	}
+}
+
+func TestTruncField(t *testing.T) {
+	var ctx lib.Ctx
+	ctx.TruncLimits = map[string]int{"gha_comments.body": 5, "gha_commits.message": 10}
+	ctx.CommitMsgLimit = 4
+	before := lib.TruncationCounts()["gha_comments.body"]
+
+	// Test cases
+	var testCases = []struct {
+		field    string
+		value    string
+		expected string
+	}{
+		{field: "gha_comments.body", value: "abc", expected: "abc"},
+		{field: "gha_comments.body", value: "abcdefgh", expected: "abcde"},
+		{field: "gha_comments.body", value: "ab\x00cdefgh", expected: "abcde"},
+		{field: "gha_comments.body", value: "ąęśćż", expected: "ąę"},
+		{field: "gha_teams.permission", value: strings.Repeat("x", 25), expected: strings.Repeat("x", 20)},
+		{field: "gha_commits.message", value: "abcdefgh", expected: "abcd"},
+		{field: "not_limited.field", value: strings.Repeat("x", 25), expected: strings.Repeat("x", 25)},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.TruncField(&ctx, test.field, test.value)
+		if got != test.expected {
+			t.Errorf("test number %d, expected '%s', got '%s', test case: %+v", index+1, test.expected, got, test)
+		}
+	}
+	if got := lib.TruncationCounts()["gha_comments.body"] - before; got != 3 {
+		t.Errorf("expected 3 gha_comments.body truncations, got %d", got)
+	}
+	if got := lib.TruncFieldOrNil(&ctx, "gha_comments.body", nil); got != nil {
+		t.Errorf("expected nil, got %v", got)
+	}
+}
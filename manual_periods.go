@@ -0,0 +1,119 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ManualPeriodsTable - bookkeeping of manual 'range:from,to' periods computed on demand by calc_metric (API requests)
+const ManualPeriodsTable = "gha_manual_periods"
+
+// NormalizeManualRange - snaps manual range dates to day boundaries in loc time zone, from down and to up (returned in UTC)
+// Requests differing only by hours/minutes are stored under the same period, so they are computed only once
+// To is snapped down instead when snapping it up would cross maxDt (data is only complete up to maxDt)
+func NormalizeManualRange(from, to, maxDt time.Time, loc *time.Location) (time.Time, time.Time) {
+	dayStart := func(dt time.Time) time.Time {
+		dt = dt.In(loc)
+		return time.Date(dt.Year(), dt.Month(), dt.Day(), 0, 0, 0, 0, loc)
+	}
+	nFrom, nTo := dayStart(from), dayStart(to)
+	if nTo.Before(to) {
+		next := nTo.AddDate(0, 0, 1)
+		if !next.After(maxDt) || !nTo.After(nFrom) {
+			nTo = next
+		}
+	}
+	return nFrom.UTC(), nTo.UTC()
+}
+
+// ManualBucket - single calendar bucket of a manual range and its manual period ("range:from,to")
+type ManualBucket struct {
+	From   time.Time
+	To     time.Time
+	Period string
+}
+
+// ManualBuckets - splits from-to range into calendar "quarter" or "year" buckets, the first and the last bucket are clipped to the range
+// To is limited to maxDt (data is only complete up to maxDt), dates are expected to be day aligned UTC times (see NormalizeManualRange)
+func ManualBuckets(from, to, maxDt time.Time, bucket string) (buckets []ManualBucket, err error) {
+	var next func(time.Time) time.Time
+	switch bucket {
+	case "quarter":
+		next = NextQuarterStart
+	case "year":
+		next = NextYearStart
+	default:
+		err = fmt.Errorf("invalid bucket: '%s', allowed: quarter, year", bucket)
+		return
+	}
+	if to.After(maxDt) {
+		to = maxDt
+	}
+	for bFrom := from; bFrom.Before(to); bFrom = next(bFrom) {
+		bTo := next(bFrom)
+		if bTo.After(to) {
+			bTo = to
+		}
+		buckets = append(
+			buckets,
+			ManualBucket{
+				From:   bFrom,
+				To:     bTo,
+				Period: "range:" + ToYMDHMSDate(bFrom) + "," + ToYMDHMSDate(bTo),
+			},
+		)
+	}
+	return
+}
+
+// SetManualPeriodComputed - records that metric (SQL file name without extension) data for a given manual period was saved into series
+func SetManualPeriodComputed(con *sql.DB, ctx *Ctx, series, metric, period string, from, to time.Time) {
+	q, args := NewQB(ManualPeriodsTable).
+		Set("series", series).
+		Set("metric", metric).
+		Set("period", period).
+		Set("dt_from", from).
+		Set("dt_to", to).
+		Set("computed_at", time.Now()).
+		Upsert("series", "metric", "period")
+	ExecSQLWithErr(con, ctx, q, args...)
+}
+
+// ManualPeriodComputed - returns when metric data for a given manual period was saved into series, nil when it was not computed yet
+// It doesn't fail when bookkeeping table doesn't exist, so it can be used by read-only clients (API)
+func ManualPeriodComputed(con *sql.DB, ctx *Ctx, series, metric, period string) (computedAt *time.Time, err error) {
+	var table *string
+	err = QueryRowSQL(con, ctx, "select to_regclass($1)::text", ManualPeriodsTable).Scan(&table)
+	if err != nil || table == nil {
+		return
+	}
+	rows, err := QuerySQLLogErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select computed_at from %s where series = %s and metric = %s and period = %s",
+			ManualPeriodsTable,
+			NValue(1),
+			NValue(2),
+			NValue(3),
+		),
+		series,
+		metric,
+		period,
+	)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var dt time.Time
+		err = rows.Scan(&dt)
+		if err != nil {
+			return
+		}
+		computedAt = &dt
+	}
+	err = rows.Err()
+	return
+}
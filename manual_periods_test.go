@@ -0,0 +1,101 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestNormalizeManualRange(t *testing.T) {
+	ft := func(s string) time.Time { return lib.TimeParseAny(s) }
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatalf("cannot load time zone: %v", err)
+	}
+	maxDt := ft("2022-06-01")
+	// Test cases
+	var testCases = []struct {
+		from, to     string
+		loc          *time.Location
+		expectedFrom string
+		expectedTo   string
+	}{
+		{from: "2021-08-20", to: "2022-01-01", loc: time.UTC, expectedFrom: "2021-08-20", expectedTo: "2022-01-01"},
+		{from: "2021-08-20 10:15:00", to: "2022-01-01 03:00:00", loc: time.UTC, expectedFrom: "2021-08-20", expectedTo: "2022-01-02"},
+		{from: "2021-08-20 23:59:59", to: "2021-08-20 23:59:59", loc: time.UTC, expectedFrom: "2021-08-20", expectedTo: "2021-08-21"},
+		{from: "2022-05-01", to: "2022-05-31 12:00:00", loc: time.UTC, expectedFrom: "2022-05-01", expectedTo: "2022-06-01"},
+		{from: "2022-05-31 02:00:00", to: "2022-05-31 12:00:00", loc: time.UTC, expectedFrom: "2022-05-31", expectedTo: "2022-06-01"},
+		{from: "2022-05-31 12:00:00", to: "2022-06-01 12:00:00", loc: time.UTC, expectedFrom: "2022-05-31", expectedTo: "2022-06-01"},
+		{from: "2021-08-19 22:00:00", to: "2021-08-21 21:00:00", loc: warsaw, expectedFrom: "2021-08-19 22:00:00", expectedTo: "2021-08-21 22:00:00"},
+		{from: "2021-08-20 05:00:00", to: "2021-08-21 05:00:00", loc: warsaw, expectedFrom: "2021-08-19 22:00:00", expectedTo: "2021-08-21 22:00:00"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		from, to := lib.NormalizeManualRange(ft(test.from), ft(test.to), maxDt, test.loc)
+		if !from.Equal(ft(test.expectedFrom)) || !to.Equal(ft(test.expectedTo)) || from.Location() != time.UTC || to.Location() != time.UTC {
+			t.Errorf(
+				"test number %d, expected %s - %s, got %s - %s",
+				index+1, test.expectedFrom, test.expectedTo, lib.ToYMDHMSDate(from), lib.ToYMDHMSDate(to),
+			)
+		}
+	}
+}
+
+func TestManualBuckets(t *testing.T) {
+	ft := func(s string) time.Time { return lib.TimeParseAny(s) }
+	maxDt := ft("2022-06-01")
+	// Test cases
+	var testCases = []struct {
+		from, to      string
+		bucket        string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			from:     "2021-08-20",
+			to:       "2022-01-01",
+			bucket:   "quarter",
+			expected: []string{"range:2021-08-20 00:00:00,2021-10-01 00:00:00", "range:2021-10-01 00:00:00,2022-01-01 00:00:00"},
+		},
+		{
+			from:     "2020-01-01",
+			to:       "2023-01-01",
+			bucket:   "year",
+			expected: []string{"range:2020-01-01 00:00:00,2021-01-01 00:00:00", "range:2021-01-01 00:00:00,2022-01-01 00:00:00", "range:2022-01-01 00:00:00,2022-06-01 00:00:00"},
+		},
+		{
+			from:     "2022-02-10",
+			to:       "2022-02-20",
+			bucket:   "quarter",
+			expected: []string{"range:2022-02-10 00:00:00,2022-02-20 00:00:00"},
+		},
+		{
+			from:   "2022-07-01",
+			to:     "2022-08-01",
+			bucket: "year",
+		},
+		{
+			from:          "2021-01-01",
+			to:            "2022-01-01",
+			bucket:        "month",
+			expectedError: true,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		buckets, err := lib.ManualBuckets(ft(test.from), ft(test.to), maxDt, test.bucket)
+		if (err != nil) != test.expectedError {
+			t.Errorf("test number %d, expected error %v, got %v", index+1, test.expectedError, err)
+			continue
+		}
+		var got []string
+		for _, bucket := range buckets {
+			got = append(got, bucket.Period)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %v, got %v", index+1, test.expected, got)
+		}
+	}
+}
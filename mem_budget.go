@@ -0,0 +1,121 @@
+package devstatscode
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+const (
+	// memBudgetHigh - heap usage (percent of budget) above which number of threads is halved
+	memBudgetHigh = 90
+	// memBudgetLow - heap usage (percent of budget) below which number of threads is increased by one
+	memBudgetLow = 60
+	// memGCEvery - when no budget is set, GC is forced after that many processed items
+	memGCEvery = 24
+)
+
+// MemBudget - adaptive concurrency controller keeping heap usage below GHA2DB_MEM_BUDGET_MB
+// Call Update after each processed item, use Threads as a current number of allowed concurrent workers
+// When no budget is set it always allows maximum number of threads and forces GC every 24 processed items
+type MemBudget struct {
+	ctx        *Ctx
+	budget     uint64
+	maxThreads int
+	threads    int
+	n          int
+	ReadHeap   func() uint64 // returns current heap allocation in bytes, can be replaced (tests)
+	RunGC      func()        // forces GC, can be replaced (tests)
+}
+
+// MemUsage - returns current memory statistics
+func MemUsage() string {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return fmt.Sprintf("alloc:%dM heap-alloc:%dM(%dk objs) total:%dM sys:%dM #gc:%d", m.Alloc>>20, m.HeapAlloc>>20, m.HeapObjects>>10, m.TotalAlloc>>20, m.Sys>>20, m.NumGC)
+}
+
+// heapAlloc - returns current heap allocation in bytes
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// NewMemBudget - creates memory budget controller allowing up to maxThreads workers
+// Budget is also set as Go runtime soft memory limit, so GC runs more often when heap is close to it
+func NewMemBudget(ctx *Ctx, maxThreads int) *MemBudget {
+	if maxThreads < 1 {
+		maxThreads = 1
+	}
+	mb := &MemBudget{
+		ctx:        ctx,
+		budget:     uint64(ctx.MemBudgetMB) << 20,
+		maxThreads: maxThreads,
+		threads:    maxThreads,
+		ReadHeap:   heapAlloc,
+		RunGC:      runtime.GC,
+	}
+	if mb.budget > 0 {
+		debug.SetMemoryLimit(int64(mb.budget))
+		Printf("Memory budget %dM, up to %d threads\n", ctx.MemBudgetMB, maxThreads)
+	}
+	return mb
+}
+
+// Threads - returns number of currently allowed concurrent workers
+func (mb *MemBudget) Threads() int {
+	return mb.threads
+}
+
+// SetMaxThreads - updates maximum number of workers (for example when number of CPUs available has changed)
+func (mb *MemBudget) SetMaxThreads(maxThreads int) {
+	if maxThreads < 1 {
+		maxThreads = 1
+	}
+	mb.maxThreads = maxThreads
+	if mb.budget == 0 || mb.threads > maxThreads {
+		mb.threads = maxThreads
+	}
+}
+
+// Update - checks heap usage after processing an item and adjusts number of allowed workers
+// When heap is above 90% of budget GC is forced, if that doesn't help number of workers is halved
+// When heap is below 60% of budget, number of workers is increased by one (up to maximum)
+func (mb *MemBudget) Update() {
+	mb.n++
+	if mb.budget == 0 {
+		if mb.n%memGCEvery == 0 {
+			Printf(MemUsage() + "\n")
+			mb.RunGC()
+			Printf(MemUsage() + "\n")
+		}
+		return
+	}
+	heap := mb.ReadHeap()
+	pct := heap * 100 / mb.budget
+	if mb.ctx.Debug > 0 {
+		Printf("Memory budget: heap %dM (%d%% of %dM), threads %d/%d\n", heap>>20, pct, mb.budget>>20, mb.threads, mb.maxThreads)
+	}
+	if pct >= memBudgetHigh {
+		mb.RunGC()
+		heap = mb.ReadHeap()
+		pct = heap * 100 / mb.budget
+		if pct < memBudgetHigh {
+			return
+		}
+		if mb.threads == 1 {
+			Printf("Memory budget exceeded: heap %dM (%d%% of %dM) using a single thread: %s\n", heap>>20, pct, mb.budget>>20, MemUsage())
+			return
+		}
+		mb.threads /= 2
+		Printf("Memory budget: heap %dM (%d%% of %dM), reducing threads to %d: %s\n", heap>>20, pct, mb.budget>>20, mb.threads, MemUsage())
+		return
+	}
+	if pct < memBudgetLow && mb.threads < mb.maxThreads {
+		mb.threads++
+		if mb.ctx.Debug > 0 || mb.threads == mb.maxThreads {
+			Printf("Memory budget: heap %dM (%d%% of %dM), increasing threads to %d\n", heap>>20, pct, mb.budget>>20, mb.threads)
+		}
+	}
+}
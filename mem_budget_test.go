@@ -0,0 +1,68 @@
+package devstatscode
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestMemBudget(t *testing.T) {
+	var ctx lib.Ctx
+	ctx.MemBudgetMB = 100
+	// Budget is set as a runtime soft memory limit, remove it after test
+	defer debug.SetMemoryLimit(math.MaxInt64)
+
+	// Heap usage in MB after each processed item and after forced GC
+	var testCases = []struct {
+		heap     uint64
+		afterGC  uint64
+		expected int
+	}{
+		{heap: 10, afterGC: 10, expected: 8},
+		{heap: 95, afterGC: 50, expected: 8},
+		{heap: 95, afterGC: 92, expected: 4},
+		{heap: 99, afterGC: 99, expected: 2},
+		{heap: 70, afterGC: 70, expected: 2},
+		{heap: 59, afterGC: 59, expected: 3},
+		{heap: 120, afterGC: 110, expected: 1},
+		{heap: 150, afterGC: 150, expected: 1},
+		{heap: 10, afterGC: 10, expected: 2},
+	}
+	mb := lib.NewMemBudget(&ctx, 8)
+	for index, test := range testCases {
+		heap, gcs := test.heap, 0
+		mb.ReadHeap = func() uint64 { return heap << 20 }
+		mb.RunGC = func() {
+			gcs++
+			heap = test.afterGC
+		}
+		mb.Update()
+		got := mb.Threads()
+		if got != test.expected {
+			t.Errorf("test number %d, expected %d threads, got %d, test case: %+v", index+1, test.expected, got, test)
+		}
+		if gcs > 1 {
+			t.Errorf("test number %d, expected at most one GC, got %d", index+1, gcs)
+		}
+	}
+
+	// Maximum number of threads change
+	mb.SetMaxThreads(1)
+	if mb.Threads() != 1 {
+		t.Errorf("expected 1 thread after maximum was lowered, got %d", mb.Threads())
+	}
+
+	// No budget: maximum threads, GC forced every 24 items
+	ctx.MemBudgetMB = 0
+	mb = lib.NewMemBudget(&ctx, 4)
+	gcs := 0
+	mb.RunGC = func() { gcs++ }
+	for i := 0; i < 50; i++ {
+		mb.Update()
+	}
+	if mb.Threads() != 4 || gcs != 2 {
+		t.Errorf("expected 4 threads and 2 GCs without budget, got %d threads and %d GCs", mb.Threads(), gcs)
+	}
+}
@@ -0,0 +1,161 @@
+package devstatscode
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IssueRef - issue or PR cross-reference found in a text: #123, org/repo#123 or GitHub issue/PR URL
+type IssueRef struct {
+	Repo   string
+	Number int
+}
+
+var (
+	// mentionsCodeRe - fenced code blocks, inline code and quoted lines, mentions and references there are not counted
+	mentionsCodeRe = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`|(?m)^[ \t]*>[^\n]*")
+	// mentionRe - GitHub login: alphanumeric and single hyphens, cannot start or end with hyphen, up to 39 characters
+	mentionRe = regexp.MustCompile(`@([a-zA-Z0-9](?:[a-zA-Z0-9]|-[a-zA-Z0-9]){0,38})`)
+	// issueRefRe - #123 or org/repo#123
+	issueRefRe = regexp.MustCompile(`(?:([a-zA-Z0-9][\w.-]*/[\w.-]+))?#([0-9]+)`)
+	// issueURLRe - https://github.com/org/repo/issues/123 or https://github.com/org/repo/pull/123
+	issueURLRe = regexp.MustCompile(`https?://github\.com/([a-zA-Z0-9][\w.-]*/[\w.-]+)/(?:issues|pull)/([0-9]+)`)
+	// closingRefRe - GitHub closing keyword followed by a single issue reference: closes #1, Fixes: org/repo#2, resolved https://github.com/org/repo/issues/3
+	closingRefRe = regexp.MustCompile(
+		`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?[ \t]+` +
+			`(?:https?://github\.com/([a-zA-Z0-9][\w.-]*/[\w.-]+)/issues/([0-9]+)|(?:([a-zA-Z0-9][\w.-]*/[\w.-]+))?#([0-9]+))`,
+	)
+)
+
+// isWordByte - letters, digits and underscore
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// stripMentionsCode - replaces code and quoted lines with spaces
+func stripMentionsCode(body string) string {
+	return mentionsCodeRe.ReplaceAllStringFunc(body, func(s string) string { return strings.Repeat(" ", len(s)) })
+}
+
+// ParseMentions - returns unique @mentioned logins from a given issue/PR/comment body, in order of first occurence
+// Emails, team mentions (@org/team) and mentions inside code or quoted lines are skipped, logins are compared case insensitive
+func ParseMentions(body string) (logins []string) {
+	logins = []string{}
+	if !strings.Contains(body, "@") {
+		return
+	}
+	body = stripMentionsCode(body)
+	seen := make(map[string]struct{})
+	for _, m := range mentionRe.FindAllStringSubmatchIndex(body, -1) {
+		if m[0] > 0 {
+			prev := body[m[0]-1]
+			if isWordByte(prev) || strings.IndexByte("/.`-@", prev) >= 0 {
+				continue
+			}
+		}
+		if m[1] < len(body) {
+			next := body[m[1]]
+			if isWordByte(next) || next == '/' || next == '-' || next == '@' {
+				continue
+			}
+		}
+		login := body[m[2]:m[3]]
+		lLogin := strings.ToLower(login)
+		if _, ok := seen[lLogin]; ok {
+			continue
+		}
+		seen[lLogin] = struct{}{}
+		logins = append(logins, login)
+	}
+	return
+}
+
+// ParseIssueRefs - returns unique issue/PR references from a given body, #123 refers to repo, in order of first occurence
+// References inside code or quoted lines are skipped, repository names are compared case insensitive
+func ParseIssueRefs(body, repo string) (refs []IssueRef) {
+	refs = []IssueRef{}
+	if !strings.Contains(body, "#") && !strings.Contains(body, "github.com/") {
+		return
+	}
+	body = stripMentionsCode(body)
+	seen := make(map[string]struct{})
+	add := func(refRepo, sNumber string) {
+		number, err := strconv.Atoi(sNumber)
+		if err != nil || number <= 0 || number > 0x7fffffff {
+			return
+		}
+		refRepo = strings.TrimSuffix(refRepo, ".git")
+		key := strings.ToLower(refRepo) + "#" + sNumber
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		refs = append(refs, IssueRef{Repo: refRepo, Number: number})
+	}
+	// URLs first, then they're blanked so org/repo/issues/123#issuecomment-1 is not parsed again
+	for _, m := range issueURLRe.FindAllStringSubmatchIndex(body, -1) {
+		if m[1] < len(body) && isWordByte(body[m[1]]) {
+			continue
+		}
+		add(body[m[2]:m[3]], body[m[4]:m[5]])
+	}
+	body = issueURLRe.ReplaceAllStringFunc(body, func(s string) string { return strings.Repeat(" ", len(s)) })
+	for _, m := range issueRefRe.FindAllStringSubmatchIndex(body, -1) {
+		if m[0] > 0 {
+			prev := body[m[0]-1]
+			if isWordByte(prev) || strings.IndexByte("/.&#-", prev) >= 0 {
+				continue
+			}
+		}
+		if m[1] < len(body) && isWordByte(body[m[1]]) {
+			continue
+		}
+		refRepo := repo
+		if m[2] >= 0 {
+			refRepo = body[m[2]:m[3]]
+		}
+		if refRepo == "" {
+			continue
+		}
+		add(refRepo, body[m[4]:m[5]])
+	}
+	return
+}
+
+// ParseClosingRefs - returns unique issues that a given PR body closes using GitHub closing keywords (close, fixes, resolved, ...), #123 refers to repo
+// Each keyword links a single issue (as on GitHub: "fixes #1, #2" only closes #1), references inside code or quoted lines are skipped
+func ParseClosingRefs(body, repo string) (refs []IssueRef) {
+	refs = []IssueRef{}
+	if !strings.Contains(body, "#") && !strings.Contains(body, "github.com/") {
+		return
+	}
+	body = stripMentionsCode(body)
+	seen := make(map[string]struct{})
+	for _, m := range closingRefRe.FindAllStringSubmatchIndex(body, -1) {
+		if m[1] < len(body) && isWordByte(body[m[1]]) {
+			continue
+		}
+		refRepo, sNumber := repo, ""
+		if m[2] >= 0 {
+			refRepo, sNumber = body[m[2]:m[3]], body[m[4]:m[5]]
+		} else {
+			if m[6] >= 0 {
+				refRepo = body[m[6]:m[7]]
+			}
+			sNumber = body[m[8]:m[9]]
+		}
+		number, err := strconv.Atoi(sNumber)
+		if err != nil || number <= 0 || number > 0x7fffffff || refRepo == "" {
+			continue
+		}
+		refRepo = strings.TrimSuffix(refRepo, ".git")
+		key := strings.ToLower(refRepo) + "#" + sNumber
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		refs = append(refs, IssueRef{Repo: refRepo, Number: number})
+	}
+	return
+}
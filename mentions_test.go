@@ -0,0 +1,88 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestParseMentions(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		body     string
+		expected []string
+	}{
+		{body: "", expected: []string{}},
+		{body: "no mentions here", expected: []string{}},
+		{body: "@lukaszgryglicki please review", expected: []string{"lukaszgryglicki"}},
+		{body: "cc @a-b, @c_d @e-", expected: []string{"a-b"}},
+		{body: "/assign @Foo\n/cc @foo @bar", expected: []string{"Foo", "bar"}},
+		{body: "mail me: user@example.com", expected: []string{}},
+		{body: "ping @kubernetes/sig-testing-pr-reviews", expected: []string{}},
+		{body: "(@x) @y.", expected: []string{"x", "y"}},
+		{body: "`@code` ```\n@block\n``` @real", expected: []string{"real"}},
+		{body: "> @quoted wrote\nthanks @author", expected: []string{"author"}},
+		{body: "@abcdefghijabcdefghijabcdefghijabcdefghijk", expected: []string{}},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ParseMentions(test.body)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestParseIssueRefs(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		body     string
+		expected []lib.IssueRef
+	}{
+		{body: "", expected: []lib.IssueRef{}},
+		{body: "# Title\ncolor #fff", expected: []lib.IssueRef{}},
+		{body: "Fixes #12, #12 and #13", expected: []lib.IssueRef{{Repo: "org/repo", Number: 12}, {Repo: "org/repo", Number: 13}}},
+		{body: "see kubernetes/kubernetes#1 &#123; a#2", expected: []lib.IssueRef{{Repo: "kubernetes/kubernetes", Number: 1}}},
+		{
+			body: "https://github.com/cncf/devstats/issues/5#issuecomment-1 and https://github.com/cncf/devstats/pull/6",
+			expected: []lib.IssueRef{
+				{Repo: "cncf/devstats", Number: 5},
+				{Repo: "cncf/devstats", Number: 6},
+			},
+		},
+		{body: "`#1` https://example.com/x#2 (#3)", expected: []lib.IssueRef{{Repo: "org/repo", Number: 3}}},
+		{body: "#99999999999", expected: []lib.IssueRef{}},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ParseIssueRefs(test.body, "org/repo")
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestParseClosingRefs(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		body     string
+		expected []lib.IssueRef
+	}{
+		{body: "", expected: []lib.IssueRef{}},
+		{body: "Related to #12, see #13", expected: []lib.IssueRef{}},
+		{body: "Fixes #12", expected: []lib.IssueRef{{Repo: "org/repo", Number: 12}}},
+		{body: "closes #1, #2 and RESOLVES: #3\nfixed #1", expected: []lib.IssueRef{{Repo: "org/repo", Number: 1}, {Repo: "org/repo", Number: 3}}},
+		{body: "Close kubernetes/kubernetes#7 resolved https://github.com/cncf/devstats/issues/8", expected: []lib.IssueRef{{Repo: "kubernetes/kubernetes", Number: 7}, {Repo: "cncf/devstats", Number: 8}}},
+		{body: "fixes https://github.com/cncf/devstats/pull/9 prefixes #10 fixesthis #11", expected: []lib.IssueRef{}},
+		{body: "`fixes #1`\n> closes #2\nfix #3x fix #4.", expected: []lib.IssueRef{{Repo: "org/repo", Number: 4}}},
+		{body: "fixes #99999999999", expected: []lib.IssueRef{}},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ParseClosingRefs(test.body, "org/repo")
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package devstatscode
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MetricParam - named metric parameter (metrics.yaml 'params'), SQL uses it as {{param:name}}
+// Values must match Regexp (full match) when it is set, Default is used when no value is given
+type MetricParam struct {
+	Name    string  `yaml:"name"`
+	Regexp  string  `yaml:"regexp"`
+	Default *string `yaml:"default"`
+}
+
+var (
+	metricParamNameRe        = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	metricParamPlaceholderRe = regexp.MustCompile(`{{param:([^}]*)}}`)
+)
+
+// MetricParamsValues - validates given parameter values against metric parameters definitions
+// Returns all parameters values with defaults applied, unknown, missing and not matching values are errors
+func MetricParamsValues(defs []MetricParam, values map[string]string) (params map[string]string, err error) {
+	params = make(map[string]string)
+	known := make(map[string]struct{})
+	for _, def := range defs {
+		known[def.Name] = struct{}{}
+		value, ok := values[def.Name]
+		if !ok {
+			if def.Default == nil {
+				err = fmt.Errorf("missing value for metric parameter '%s'", def.Name)
+				return
+			}
+			value = *def.Default
+		}
+		if def.Regexp != "" {
+			var re *regexp.Regexp
+			re, err = regexp.Compile("^(?:" + def.Regexp + ")$")
+			if err != nil {
+				err = fmt.Errorf("metric parameter '%s' regexp '%s': %v", def.Name, def.Regexp, err)
+				return
+			}
+			if !re.MatchString(value) {
+				err = fmt.Errorf("metric parameter '%s' value '%s' does not match '%s'", def.Name, value, def.Regexp)
+				return
+			}
+		}
+		params[def.Name] = value
+	}
+	for name := range values {
+		if _, ok := known[name]; !ok {
+			err = fmt.Errorf("unknown metric parameter '%s'", name)
+			return
+		}
+	}
+	return
+}
+
+// MetricParamsDefaults - returns parameter values when all parameters have defaults, nil otherwise
+func MetricParamsDefaults(defs []MetricParam) map[string]string {
+	params := make(map[string]string)
+	for _, def := range defs {
+		if def.Default == nil {
+			return nil
+		}
+		params[def.Name] = *def.Default
+	}
+	return params
+}
+
+// MetricParamsToString - encodes parameter values so they can be passed as calc_metric 'params:' option
+// Encoded string contains no ',' and ':' characters, empty string is returned when there are no parameters
+func MetricParamsToString(params map[string]string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	values := url.Values{}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
+// MetricParamsFromString - decodes parameter values encoded by MetricParamsToString
+func MetricParamsFromString(str string) (params map[string]string, err error) {
+	values, err := url.ParseQuery(str)
+	if err != nil {
+		return
+	}
+	params = make(map[string]string)
+	for name, vals := range values {
+		if !metricParamNameRe.MatchString(name) {
+			err = fmt.Errorf("invalid metric parameter name '%s'", name)
+			return
+		}
+		if len(vals) != 1 {
+			err = fmt.Errorf("metric parameter '%s' given %d times", name, len(vals))
+			return
+		}
+		params[name] = vals[0]
+	}
+	return
+}
+
+// MetricParamsPeriod - returns period value under which metric computed with given parameters is stored
+// Period is not changed when there are no parameters, so metrics without parameters are stored as before
+func MetricParamsPeriod(period string, params map[string]string) string {
+	if len(params) == 0 {
+		return period
+	}
+	return period + ";" + MetricParamsToString(params)
+}
+
+// ApplyMetricParams - replaces {{param:name}} placeholders with SQL string literals of parameter values
+// Values are always quoted, so they cannot change the query structure
+func ApplyMetricParams(sql string, params map[string]string) (string, error) {
+	missing := make(map[string]struct{})
+	res := metricParamPlaceholderRe.ReplaceAllStringFunc(sql, func(ph string) string {
+		name := metricParamPlaceholderRe.FindStringSubmatch(ph)[1]
+		value, ok := params[name]
+		if !ok {
+			missing[name] = struct{}{}
+			return ph
+		}
+		return "'" + strings.Replace(strings.Replace(value, "\x00", "", -1), "'", "''", -1) + "'"
+	})
+	if len(missing) > 0 {
+		names := []string{}
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return sql, fmt.Errorf("no values for metric parameters: %s", strings.Join(names, ", "))
+	}
+	return res, nil
+}
+
+// MetricParamNames - returns names of parameters used by {{param:name}} placeholders in SQL
+func MetricParamNames(sql string) (names []string) {
+	seen := make(map[string]struct{})
+	for _, m := range metricParamPlaceholderRe.FindAllStringSubmatch(sql, -1) {
+		if _, ok := seen[m[1]]; ok {
+			continue
+		}
+		seen[m[1]] = struct{}{}
+		names = append(names, m[1])
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestApplyMetricParams(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		sql      string
+		params   map[string]string
+		expected string
+		err      bool
+	}{
+		{
+			sql:      "select 1",
+			params:   nil,
+			expected: "select 1",
+		},
+		{
+			sql:      "where l.name = {{param:label}} and {{param:label}} <> ''",
+			params:   map[string]string{"label": "sig/network"},
+			expected: "where l.name = 'sig/network' and 'sig/network' <> ''",
+		},
+		{
+			sql:      "where l.name = {{param:label}}",
+			params:   map[string]string{"label": "x'; drop table gha_events; --"},
+			expected: "where l.name = 'x''; drop table gha_events; --'",
+		},
+		{
+			sql:    "where l.name = {{param:label}} and t = {{param:team}}",
+			params: map[string]string{"label": "a"},
+			err:    true,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got, err := lib.ApplyMetricParams(test.sql, test.params)
+		if (err != nil) != test.err {
+			t.Errorf("test number %d, expected error %v, got %v", index+1, test.err, err)
+			continue
+		}
+		if err == nil && got != test.expected {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestMetricParamsValues(t *testing.T) {
+	def := "sig/network"
+	defs := []lib.MetricParam{
+		{Name: "label", Regexp: `[a-z]+/[a-z-]+`, Default: &def},
+		{Name: "team"},
+	}
+	// Test cases
+	var testCases = []struct {
+		values   map[string]string
+		expected map[string]string
+		err      bool
+	}{
+		{
+			values:   map[string]string{"team": "x"},
+			expected: map[string]string{"label": "sig/network", "team": "x"},
+		},
+		{
+			values:   map[string]string{"team": "x", "label": "area/api"},
+			expected: map[string]string{"label": "area/api", "team": "x"},
+		},
+		{
+			values: map[string]string{"label": "area/api"},
+			err:    true,
+		},
+		{
+			values: map[string]string{"team": "x", "label": "area/api x"},
+			err:    true,
+		},
+		{
+			values: map[string]string{"team": "x", "other": "y"},
+			err:    true,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got, err := lib.MetricParamsValues(defs, test.values)
+		if (err != nil) != test.err {
+			t.Errorf("test number %d, expected error %v, got %v", index+1, test.err, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected '%v', got '%v'", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestMetricParamsString(t *testing.T) {
+	params := map[string]string{"label": "sig/network,a:b", "team": "x y"}
+	str := lib.MetricParamsToString(params)
+	if str != "label=sig%2Fnetwork%2Ca%3Ab&team=x+y" {
+		t.Errorf("unexpected encoding '%s'", str)
+	}
+	got, err := lib.MetricParamsFromString(str)
+	if err != nil || !reflect.DeepEqual(got, params) {
+		t.Errorf("expected '%v', got '%v', error %v", params, got, err)
+	}
+	if period := lib.MetricParamsPeriod("range:a,b", nil); period != "range:a,b" {
+		t.Errorf("unexpected period '%s'", period)
+	}
+	if period := lib.MetricParamsPeriod("y", params); period != "y;"+str {
+		t.Errorf("unexpected period '%s'", period)
+	}
+	if _, err := lib.MetricParamsFromString("Bad=1"); err == nil {
+		t.Errorf("expected error for invalid parameter name")
+	}
+}
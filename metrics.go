@@ -0,0 +1,74 @@
+package devstatscode
+
+import (
+	"math/rand"
+	"time"
+)
+
+// AllMetrics contain list of metrics to evaluate (metrics.yaml)
+type AllMetrics struct {
+	Metrics []Metric `yaml:"metrics"`
+}
+
+// Metric contain each metric data
+// some metrics can be allowed to fail
+type Metric struct {
+	Name                 string            `yaml:"name"`
+	Periods              string            `yaml:"periods"`
+	SeriesNameOrFunc     string            `yaml:"series_name_or_func"`
+	MetricSQL            string            `yaml:"sql"`
+	MetricSQLs           *[]string         `yaml:"sqls"`
+	AddPeriodToName      bool              `yaml:"add_period_to_name"`
+	Histogram            bool              `yaml:"histogram"`
+	Aggregate            string            `yaml:"aggregate"`
+	Skip                 string            `yaml:"skip"`
+	Desc                 string            `yaml:"desc"`
+	MultiValue           bool              `yaml:"multi_value"`
+	EscapeValueName      bool              `yaml:"escape_value_name"`
+	SkipEscapeSeriesName bool              `yaml:"skip_escape_series_name"`
+	AnnotationsRanges    bool              `yaml:"annotations_ranges"`
+	MergeSeries          string            `yaml:"merge_series"`
+	CustomData           bool              `yaml:"custom_data"`
+	StartFrom            *time.Time        `yaml:"start_from"`
+	LastHours            int               `yaml:"last_hours"`
+	SeriesNameMap        map[string]string `yaml:"series_name_map"`
+	EnvMap               map[string]string `yaml:"env"`
+	Disabled             bool              `yaml:"disabled"`
+	Drop                 string            `yaml:"drop"`
+	Project              string            `yaml:"project"`
+	AllowFail            bool              `yaml:"allow_fail"`
+	WaitAfterFail        int               `yaml:"wait_after_fail"`
+	HLL                  bool              `yaml:"hll"`
+	Params               []MetricParam     `yaml:"params"`
+	Feature              string            `yaml:"feature"`
+}
+
+// AllColumns contains list of columns that must be present on a certain series (columns.yaml)
+type AllColumns struct {
+	Columns []Column `yaml:"columns"`
+}
+
+// Column contain configuration of columns needed on a specific series
+type Column struct {
+	TableRegexp string `yaml:"table_regexp"`
+	Tag         string `yaml:"tag"`
+	Column      string `yaml:"column"`
+	HLL         bool   `yaml:"hll"`
+}
+
+// Randomize - shufflues array of metrics to calculate, making sure that ctx.LastSeries is still last
+func (m *AllMetrics) Randomize(ctx *Ctx) {
+	Printf("Randomizing metrics calculation order\n")
+	rand.Shuffle(len(m.Metrics), func(i, j int) { m.Metrics[i], m.Metrics[j] = m.Metrics[j], m.Metrics[i] })
+	idx := -1
+	lastI := len(m.Metrics) - 1
+	for i, m := range m.Metrics {
+		if m.SeriesNameOrFunc == ctx.LastSeries {
+			idx = i
+			break
+		}
+	}
+	if idx >= 0 && idx != lastI {
+		m.Metrics[idx], m.Metrics[lastI] = m.Metrics[lastI], m.Metrics[idx]
+	}
+}
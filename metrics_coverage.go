@@ -0,0 +1,97 @@
+package devstatscode
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	// seriesRefRe - series table ("s" prefix) used in FROM or JOIN, table name can contain Grafana variables: [[var]], ${var} or $var
+	seriesRefRe = regexp.MustCompile(`(?i)\b(?:from|join)\s+"?(s[a-z0-9_]*(?:(?:\[\[[a-z0-9_]+\]\]|\$\{[a-z0-9_:]+\}|\$[a-z0-9_]+)[a-z0-9_]*)*)"?`)
+	// grafanaVarRe - Grafana variable in a series table name
+	grafanaVarRe = regexp.MustCompile(`\[\[[a-z0-9_]+\]\]|\$\{[a-z0-9_:]+\}|\$[a-z0-9_]+`)
+	// dashboardSQLKeys - dashboard JSON keys holding SQL queries: panel targets and templating variables
+	dashboardSQLKeys = map[string]struct{}{"rawSql": {}, "query": {}, "definition": {}}
+)
+
+// DashboardSeriesRefs - returns title of Grafana dashboard JSON and sorted series tables its panels and variables query
+// Dashboard can be exported directly (sqlitedb) or wrapped in {"dashboard": {...}}, table names can contain Grafana variables
+func DashboardSeriesRefs(data []byte) (title string, refs []string, err error) {
+	var dash map[string]interface{}
+	err = jsoniter.Unmarshal(data, &dash)
+	if err != nil {
+		return
+	}
+	if inner, ok := dash["dashboard"].(map[string]interface{}); ok {
+		dash = inner
+	}
+	title, _ = dash["title"].(string)
+	seen := make(map[string]struct{})
+	var walk func(key string, v interface{})
+	walk = func(key string, v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for k, item := range value {
+				walk(k, item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(key, item)
+			}
+		case string:
+			if _, ok := dashboardSQLKeys[key]; !ok {
+				return
+			}
+			for _, m := range seriesRefRe.FindAllStringSubmatch(value, -1) {
+				seen[strings.ToLower(m[1])] = struct{}{}
+			}
+		}
+	}
+	walk("", dash)
+	refs = StringsSetKeys(seen)
+	sort.Strings(refs)
+	return
+}
+
+// seriesRefRegexp - returns regexp matching series tables a given (possibly templated) reference can query
+func seriesRefRegexp(ref string) *regexp.Regexp {
+	parts := grafanaVarRe.Split(ref, -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "[a-z0-9_]*") + "$")
+}
+
+// MetricsCoverage - cross-references series tables queried by dashboards (dashboard name -> references, see DashboardSeriesRefs) with existing series tables
+// Returns references that match no table (per dashboard) and sorted tables that no dashboard queries
+// Templated references match every table they can expand to, merged series tables are checked as a whole
+func MetricsCoverage(dashboards map[string][]string, tables []string) (missing map[string][]string, unused []string) {
+	missing = make(map[string][]string)
+	used := make(map[string]struct{})
+	for dashboard, refs := range dashboards {
+		for _, ref := range refs {
+			re := seriesRefRegexp(ref)
+			found := false
+			for _, table := range tables {
+				if re.MatchString(table) {
+					used[table] = struct{}{}
+					found = true
+				}
+			}
+			if !found {
+				missing[dashboard] = append(missing[dashboard], ref)
+			}
+		}
+	}
+	unused = []string{}
+	for _, table := range tables {
+		if _, ok := used[table]; !ok {
+			unused = append(unused, table)
+		}
+	}
+	sort.Strings(unused)
+	return
+}
@@ -0,0 +1,91 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestDashboardSeriesRefs(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		json          string
+		expectedTitle string
+		expectedRefs  []string
+		err           bool
+	}{
+		{
+			json:          `{"title":"PRs age","panels":[{"targets":[{"rawSql":"select time, p50 from \"sprs_age\" where series = 'prs_age[[repogroup]]' and period = '[[period]]'"}]}]}`,
+			expectedTitle: "PRs age",
+			expectedRefs:  []string{"sprs_age"},
+		},
+		{
+			json: `{"dashboard":{"title":"Companies","rows":[{"panels":[{"targets":[{"rawSql":"select * FROM shcom s join sannotations a on true"},{"rawSql":"select 1 from \"s[[metric]]_${period}\""}]}]}],` +
+				`"templating":{"list":[{"query":"select name from \"tquick_ranges\""},{"definition":"select value from spstat_$repo"}]}}}`,
+			expectedTitle: "Companies",
+			expectedRefs:  []string{"s[[metric]]_${period}", "sannotations", "shcom", "spstat_$repo"},
+		},
+		{
+			json:          `{"title":"Text","panels":[{"type":"text","content":"select * from snot_a_query"},{"targets":[{"rawSql":"select count(*) from gha_events"}]}]}`,
+			expectedTitle: "Text",
+			expectedRefs:  []string{},
+		},
+		{
+			json: `{"title":`,
+			err:  true,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		title, refs, err := lib.DashboardSeriesRefs([]byte(test.json))
+		if (err != nil) != test.err {
+			t.Errorf("test number %d, expected error %v, got %v", index+1, test.err, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if title != test.expectedTitle || !reflect.DeepEqual(refs, test.expectedRefs) {
+			t.Errorf("test number %d, expected '%s' %v, got '%s' %v", index+1, test.expectedTitle, test.expectedRefs, title, refs)
+		}
+	}
+}
+
+func TestMetricsCoverage(t *testing.T) {
+	tables := []string{"sannotations", "shcom", "sprs_age", "spstat_kubernetes", "spstat_helm", "sold_metric", "stime_metrics_d"}
+	// Test cases
+	var testCases = []struct {
+		dashboards      map[string][]string
+		expectedMissing map[string][]string
+		expectedUnused  []string
+	}{
+		{
+			dashboards:      map[string][]string{},
+			expectedMissing: map[string][]string{},
+			expectedUnused:  []string{"sannotations", "shcom", "sold_metric", "sprs_age", "spstat_helm", "spstat_kubernetes", "stime_metrics_d"},
+		},
+		{
+			dashboards: map[string][]string{
+				"a.json": {"sannotations", "shcom", "sprs_renamed"},
+				"b.json": {"spstat_$repo", "s[[metric]]_d"},
+			},
+			expectedMissing: map[string][]string{"a.json": {"sprs_renamed"}},
+			expectedUnused:  []string{"sold_metric", "sprs_age"},
+		},
+		{
+			dashboards: map[string][]string{
+				"c.json": {"spstat_kubernetes", "snew_[[period]]"},
+			},
+			expectedMissing: map[string][]string{"c.json": {"snew_[[period]]"}},
+			expectedUnused:  []string{"sannotations", "shcom", "sold_metric", "sprs_age", "spstat_helm", "stime_metrics_d"},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		missing, unused := lib.MetricsCoverage(test.dashboards, tables)
+		if !reflect.DeepEqual(missing, test.expectedMissing) || !reflect.DeepEqual(unused, test.expectedUnused) {
+			t.Errorf("test number %d, expected missing %v, unused %v, got %v, %v", index+1, test.expectedMissing, test.expectedUnused, missing, unused)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package devstatscode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// metricPlaceholders - placeholders replaced by calc_metric in metrics SQLs
+var metricPlaceholders = map[string]struct{}{
+	"from":          {},
+	"to":            {},
+	"n":             {},
+	"period":        {},
+	"range":         {},
+	"project_scale": {},
+	"rnd":           {},
+	"exclude_bots":  {},
+}
+
+// tagPlaceholders - placeholders replaced by tags tool in tags SQLs
+var tagPlaceholders = map[string]struct{}{
+	"lim":          {},
+	"exclude_bots": {},
+}
+
+// seriesFuncs - calc_metric series functions, series names are generated from returned data
+var seriesFuncs = map[string]struct{}{
+	"single_row_multi_column": {},
+	"multi_row_single_column": {},
+	"multi_row_multi_column":  {},
+}
+
+// annotationsTags - tag tables created by annotations tool (not defined in tags.yaml)
+var annotationsTags = map[string]struct{}{
+	"tquick_ranges": {},
+}
+
+var (
+	placeholderRe = regexp.MustCompile(`{{([a-z_]+)}}`)
+	periodRe      = regexp.MustCompile(`^[hdwmqy]([1-9][0-9]*)?$`)
+)
+
+// lintYAML - parses YAML into obj, unknown fields are reported but do not stop parsing
+func lintYAML(data []byte, obj interface{}, file string) (errs []error) {
+	if err := yaml.UnmarshalStrict(data, obj); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", file, err))
+		if err = yaml.Unmarshal(data, obj); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// lintSQL - checks that SQL file exists and uses only known placeholders
+func lintSQL(ctx *Ctx, path, item string, placeholders map[string]struct{}) (errs []error) {
+	data, err := ReadFile(ctx, path)
+	if err != nil {
+		return []error{fmt.Errorf("%s: missing SQL file %s", item, path)}
+	}
+	seen := make(map[string]struct{})
+	for _, m := range placeholderRe.FindAllStringSubmatch(string(data), -1) {
+		name := m[1]
+		if _, ok := placeholders[name]; ok {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		errs = append(errs, fmt.Errorf("%s: %s uses placeholder {{%s}} that is not provided", item, path, name))
+	}
+	return
+}
+
+// lintMetricParams - checks metric parameters definitions and that SQL uses only defined parameters
+func lintMetricParams(ctx *Ctx, paths []string, item string, defs []MetricParam) (errs []error) {
+	defined := make(map[string]struct{})
+	for _, def := range defs {
+		if !metricParamNameRe.MatchString(def.Name) {
+			errs = append(errs, fmt.Errorf("%s: invalid parameter name '%s'", item, def.Name))
+		}
+		if _, ok := defined[def.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: parameter '%s' defined more than once", item, def.Name))
+		}
+		defined[def.Name] = struct{}{}
+		if def.Regexp != "" {
+			if _, err := regexp.Compile(def.Regexp); err != nil {
+				errs = append(errs, fmt.Errorf("%s: parameter '%s' invalid regexp: %v", item, def.Name, err))
+			}
+		}
+	}
+	for _, path := range paths {
+		data, err := ReadFile(ctx, path)
+		if err != nil {
+			continue
+		}
+		for _, name := range MetricParamNames(string(data)) {
+			if _, ok := defined[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: %s uses parameter {{param:%s}} that is not defined", item, path, name))
+			}
+		}
+	}
+	return
+}
+
+// metricPeriods - returns period+aggregate values computed for a given metric
+func metricPeriods(m *Metric, item string) (periods []string, errs []error) {
+	aggregate := m.Aggregate
+	if aggregate == "" {
+		aggregate = "1"
+	}
+	suffixes := []string{}
+	for _, aggr := range strings.Split(aggregate, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(aggr))
+		if err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf("%s: invalid aggregate '%s'", item, aggr))
+			continue
+		}
+		if n == 1 {
+			suffixes = append(suffixes, "")
+		} else {
+			suffixes = append(suffixes, strconv.Itoa(n))
+		}
+	}
+	// Annotations ranges periods are quick ranges from database
+	if m.AnnotationsRanges {
+		return
+	}
+	if m.Periods == "" {
+		errs = append(errs, fmt.Errorf("%s: no periods defined", item))
+		return
+	}
+	for _, period := range strings.Split(m.Periods, ",") {
+		if !periodRe.MatchString(period) {
+			errs = append(errs, fmt.Errorf("%s: invalid period '%s'", item, period))
+			continue
+		}
+		for _, suffix := range suffixes {
+			periods = append(periods, period+suffix)
+		}
+	}
+	return
+}
+
+// LintMetrics - validates metrics YAML of the current project: unknown fields, missing SQL files,
+// duplicate series names, invalid periods and SQL placeholders that are not provided
+func LintMetrics(ctx *Ctx, dataPrefix string) (errs []error) {
+	file := dataPrefix + ctx.MetricsYaml
+	data, err := ReadFile(ctx, file)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %v", file, err)}
+	}
+	var all AllMetrics
+	errs = lintYAML(data, &all, file)
+	metricsDir := dataPrefix + "metrics"
+	if ctx.Project != "" {
+		metricsDir += "/" + ctx.Project
+	}
+	// series -> period -> metric defining it
+	series := make(map[string]map[string]string)
+	for i := range all.Metrics {
+		m := &all.Metrics[i]
+		item := fmt.Sprintf("%s: metric #%d '%s'", file, i+1, m.Name)
+		if m.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: no name", item))
+		}
+		if m.SeriesNameOrFunc == "" {
+			errs = append(errs, fmt.Errorf("%s: no series_name_or_func", item))
+		}
+		sqls := []string{}
+		if m.MetricSQLs != nil {
+			if m.MetricSQL != "" {
+				errs = append(errs, fmt.Errorf("%s: both sql and sqls are used", item))
+			}
+			sqls = *m.MetricSQLs
+		} else if m.MetricSQL != "" {
+			sqls = []string{m.MetricSQL}
+		}
+		if len(sqls) == 0 {
+			errs = append(errs, fmt.Errorf("%s: no sql defined", item))
+		}
+		paths := []string{}
+		for _, sql := range sqls {
+			path := fmt.Sprintf("%s/%s.sql", metricsDir, sql)
+			paths = append(paths, path)
+			errs = append(errs, lintSQL(ctx, path, item, metricPlaceholders)...)
+		}
+		errs = append(errs, lintMetricParams(ctx, paths, item, m.Params)...)
+		if m.Histogram && m.Drop != "" {
+			errs = append(errs, fmt.Errorf("%s: drop cannot be used on histogram metrics", item))
+		}
+		if m.StartFrom != nil && m.LastHours > 0 {
+			errs = append(errs, fmt.Errorf("%s: both start_from and last_hours are used", item))
+		}
+		periods, perrs := metricPeriods(m, item)
+		errs = append(errs, perrs...)
+		if m.Skip != "" && !m.AnnotationsRanges {
+			computed := make(map[string]struct{})
+			for _, period := range periods {
+				computed[period] = struct{}{}
+			}
+			for _, skip := range strings.Split(m.Skip, ",") {
+				if _, ok := computed[skip]; !ok {
+					errs = append(errs, fmt.Errorf("%s: skipped period '%s' is not computed", item, skip))
+				}
+			}
+		}
+		// Series functions generate names from data, merged series are written by many metrics on purpose
+		_, isFunc := seriesFuncs[m.SeriesNameOrFunc]
+		if m.Disabled || isFunc || m.MergeSeries != "" || m.Project != "" || m.MetricSQLs != nil {
+			continue
+		}
+		for _, period := range periods {
+			name := m.SeriesNameOrFunc
+			if m.AddPeriodToName {
+				name += "_" + period
+			}
+			if _, ok := series[name]; !ok {
+				series[name] = make(map[string]string)
+			}
+			if prev, ok := series[name][period]; ok {
+				errs = append(errs, fmt.Errorf("%s: series '%s' period '%s' is already computed by '%s'", item, name, period, prev))
+				continue
+			}
+			series[name][period] = m.Name
+		}
+	}
+	return
+}
+
+// LintTags - validates tags YAML of the current project, returns tag tables with their columns
+func LintTags(ctx *Ctx, dataPrefix string) (tables map[string]map[string]struct{}, errs []error) {
+	tables = make(map[string]map[string]struct{})
+	file := dataPrefix + ctx.TagsYaml
+	data, err := ReadFile(ctx, file)
+	if err != nil {
+		return tables, []error{fmt.Errorf("%s: %v", file, err)}
+	}
+	var all Tags
+	errs = lintYAML(data, &all, file)
+	dir := Metrics
+	if ctx.Project != "" {
+		dir += ctx.Project + "/"
+	}
+	for i := range all.Tags {
+		tg := &all.Tags[i]
+		item := fmt.Sprintf("%s: tag #%d '%s'", file, i+1, tg.Name)
+		if tg.SeriesName == "" {
+			errs = append(errs, fmt.Errorf("%s: no series_name", item))
+		}
+		if tg.SQLFile == "" {
+			errs = append(errs, fmt.Errorf("%s: no sql defined", item))
+		} else {
+			errs = append(errs, lintSQL(ctx, dataPrefix+dir+tg.SQLFile+".sql", item, tagPlaceholders)...)
+		}
+		if tg.Disabled {
+			continue
+		}
+		table := "t" + tg.SeriesName
+		if _, ok := tables[table]; ok {
+			errs = append(errs, fmt.Errorf("%s: series_name '%s' is already used", item, tg.SeriesName))
+			continue
+		}
+		cols := make(map[string]struct{})
+		for _, col := range []string{tg.NameTag, tg.ValueTag} {
+			if col != "" {
+				cols[col] = struct{}{}
+			}
+		}
+		for col, def := range tg.OtherTags {
+			cols[col] = struct{}{}
+			norm := strings.ToLower(def[1])
+			if norm == "1" || norm == "t" || norm == "y" {
+				cols[col+"_norm"] = struct{}{}
+			}
+		}
+		tables[table] = cols
+	}
+	return
+}
+
+// LintColumns - validates columns YAML of the current project against tag tables defined in tags YAML
+func LintColumns(ctx *Ctx, dataPrefix string, tables map[string]map[string]struct{}) (errs []error) {
+	file := dataPrefix + ctx.ColumnsYaml
+	data, err := ReadFile(ctx, file)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %v", file, err)}
+	}
+	var all AllColumns
+	errs = lintYAML(data, &all, file)
+	seen := make(map[[2]string]struct{})
+	for i := range all.Columns {
+		col := &all.Columns[i]
+		item := fmt.Sprintf("%s: column #%d '%s'", file, i+1, col.Column)
+		if _, err := regexp.Compile(col.TableRegexp); err != nil || col.TableRegexp == "" {
+			errs = append(errs, fmt.Errorf("%s: invalid table_regexp '%s'", item, col.TableRegexp))
+		}
+		key := [2]string{col.TableRegexp, col.Tag}
+		if _, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate table_regexp '%s' and tag '%s'", item, col.TableRegexp, col.Tag))
+		}
+		seen[key] = struct{}{}
+		if _, ok := annotationsTags[col.Tag]; ok {
+			continue
+		}
+		cols, ok := tables[col.Tag]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: tag table '%s' is not defined in tags", item, col.Tag))
+			continue
+		}
+		if _, ok := cols[col.Column]; !ok {
+			errs = append(errs, fmt.Errorf("%s: tag table '%s' has no '%s' column", item, col.Tag, col.Column))
+		}
+	}
+	return
+}
+
+// LintAPIMetrics - validates custom API metrics YAML of the current project (next to its metrics YAML), it is optional
+func LintAPIMetrics(ctx *Ctx, dataPrefix string) (errs []error) {
+	file := dataPrefix + filepath.Dir(ctx.MetricsYaml) + "/" + APIMetricsFile
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return
+	}
+	data, err := ReadFile(ctx, file)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %v", file, err)}
+	}
+	if _, err = ParseAPIMetrics(data); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", file, err))
+	}
+	return
+}
+
+// LintConfig - validates metrics, tags, columns and custom API metrics YAMLs of the current project, returns list of problems found
+func LintConfig(ctx *Ctx) (errs []error) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	errs = LintMetrics(ctx, dataPrefix)
+	tables, terrs := LintTags(ctx, dataPrefix)
+	errs = append(errs, terrs...)
+	errs = append(errs, LintColumns(ctx, dataPrefix, tables)...)
+	errs = append(errs, LintAPIMetrics(ctx, dataPrefix)...)
+	return
+}
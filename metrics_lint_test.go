@@ -0,0 +1,134 @@
+package devstatscode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestLintConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatalf("cannot create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := map[string]string{
+		"metrics/proj/metrics.yaml": `---
+metrics:
+  - name: Good
+    series_name_or_func: events
+    sql: events
+    periods: d,w,m
+    aggregate: 1,7
+    skip: w7
+  - name: Duplicate
+    series_name_or_func: events
+    sql: events
+    periods: m,q
+  - name: Broken
+    series_name_or_func: multi_row_single_column
+    sql: missing
+    periods: d,x2
+    aggregate: 0
+    skip: y
+    unknown_field: 1
+  - name: Placeholders
+    series_name_or_func: multi_row_single_column
+    sql: placeholders
+    periods: d
+`,
+		"metrics/proj/tags.yaml": `---
+tags:
+  - name: Repo groups
+    sql: repo_groups_tags
+    series_name: repo_groups
+    name_tag: repo_group_name
+    value_tag: repo_group_value
+  - name: Duplicate
+    sql: repo_groups_tags
+    series_name: repo_groups
+`,
+		"metrics/proj/columns.yaml": `---
+columns:
+  - table_regexp: '^sevents'
+    tag: trepo_groups
+    column: repo_group_value
+  - table_regexp: '^sprs'
+    tag: tquick_ranges
+    column: quick_ranges_suffix
+  - table_regexp: '^sissues'
+    tag: trepo_groups
+    column: other
+  - table_regexp: '(['
+    tag: tunknown
+    column: x
+`,
+		"metrics/proj/api_metrics.yaml": `---
+metrics:
+  - name: Stars
+    table: sstars
+    series: stars{{repository_group}}
+  - name: Broken
+    table: gha_stars
+    series: stars
+`,
+		"metrics/proj/events.sql":             "select count(*) from gha_events where created_at >= '{{from}}' and created_at < '{{to}}' {{exclude_bots}}",
+		"metrics/proj/placeholders.sql":       "select '{{from}}', {{lim}}, {{unknown}}, {{unknown}}, {{period:e.created_at}}",
+		"metrics/shared/repo_groups_tags.sql": "select name from gha_repo_groups limit {{lim}}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("cannot create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("cannot write %s: %v", name, err)
+		}
+	}
+	ctx := lib.Ctx{
+		DataDir:     dir + "/",
+		Project:     "proj",
+		MetricsYaml: "metrics/proj/metrics.yaml",
+		TagsYaml:    "metrics/proj/tags.yaml",
+		ColumnsYaml: "metrics/proj/columns.yaml",
+	}
+	expected := []string{
+		"field unknown_field not found",
+		"metric #2 'Duplicate': series 'events' period 'm' is already computed by 'Good'",
+		"metric #3 'Broken': missing SQL file " + dir + "/metrics/proj/missing.sql",
+		"metric #3 'Broken': invalid period 'x2'",
+		"metric #3 'Broken': invalid aggregate '0'",
+		"metric #3 'Broken': skipped period 'y' is not computed",
+		"metric #4 'Placeholders': " + dir + "/metrics/proj/placeholders.sql uses placeholder {{lim}} that is not provided",
+		"metric #4 'Placeholders': " + dir + "/metrics/proj/placeholders.sql uses placeholder {{unknown}} that is not provided",
+		"tag #2 'Duplicate': series_name 'repo_groups' is already used",
+		"column #3 'other': tag table 'trepo_groups' has no 'other' column",
+		"column #4 'x': invalid table_regexp '(['",
+		"column #4 'x': tag table 'tunknown' is not defined in tags",
+		"api_metrics.yaml: metric #2: metric 'Broken': invalid series table 'gha_stars'",
+	}
+	errs := lib.LintConfig(&ctx)
+	got := []string{}
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	for _, exp := range expected {
+		found := false
+		for _, msg := range got {
+			if strings.Contains(msg, exp) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected problem '%s' not found in:\n%s", exp, strings.Join(got, "\n"))
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("expected %d problems, got %d:\n%s", len(expected), len(got), strings.Join(got, "\n"))
+	}
+}
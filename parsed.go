@@ -0,0 +1,46 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"time"
+)
+
+// gha_parsed hour statuses
+const (
+	// ParsedOK - hour was fully parsed
+	ParsedOK = "ok"
+	// ParsedPartial - hour parsing was started but not finished (JSON fetch gave up or tool crashed), sync re-runs such hours
+	ParsedPartial = "partial"
+	// ParsedSkipped - hour is listed in skip dates config
+	ParsedSkipped = "skipped"
+)
+
+// ParsedStatus - returns gha_parsed status of a given hour, empty string when that hour was never parsed
+func ParsedStatus(con *sql.DB, ctx *Ctx, dt time.Time) (status string) {
+	rows := QuerySQLWithErr(con, ctx, "select status from gha_parsed where dt = "+NValue(1), dt)
+	defer func() { FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		FatalOnError(rows.Scan(&status))
+	}
+	FatalOnError(rows.Err())
+	return
+}
+
+// PartialHours - returns sorted hours with partial status before a given date
+func PartialHours(con *sql.DB, ctx *Ctx, before time.Time) (hours []time.Time) {
+	rows := QuerySQLWithErr(
+		con,
+		ctx,
+		"select dt from gha_parsed where status = "+NValue(1)+" and dt < "+NValue(2)+" order by dt",
+		ParsedPartial,
+		before,
+	)
+	defer func() { FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		var dt time.Time
+		FatalOnError(rows.Scan(&dt))
+		hours = append(hours, dt)
+	}
+	FatalOnError(rows.Err())
+	return
+}
@@ -0,0 +1,153 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PartitionedTable - big event table that can be partitioned by month (GHA2DB_PARTITIONED, pg_partition_manager tool)
+// Partitioned table primary key must include partition column, so Key columns are followed by Column
+// Key columns alone are no longer unique then (for example the same gha_events.id can exist in two partitions)
+type PartitionedTable struct {
+	Table  string
+	Column string
+	Key    []string
+}
+
+// PartitionedTables - tables that can be converted to monthly partitions
+var PartitionedTables = []PartitionedTable{
+	{Table: "gha_events", Column: "created_at", Key: []string{"id"}},
+	{Table: "gha_payloads", Column: "dup_created_at", Key: []string{"event_id"}},
+	{Table: "gha_comments", Column: "dup_created_at", Key: []string{"id", "event_id"}},
+}
+
+// PartitionsArchiveSchema - schema where pg_partition_manager moves detached old partitions
+const PartitionsArchiveSchema = "archive"
+
+// PartitionName - returns name of a given table partition holding a given month
+func PartitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_p%04d%02d", table, month.Year(), int(month.Month()))
+}
+
+// DefaultPartitionName - returns name of a given table default partition, it holds rows not matching any monthly partition
+func DefaultPartitionName(table string) string {
+	return table + "_default"
+}
+
+// PartitionedKey - returns comma separated primary key columns of a partitioned table
+func (pt *PartitionedTable) PartitionedKey() string {
+	return strings.Join(append(append([]string{}, pt.Key...), pt.Column), ", ")
+}
+
+// PartitionClauses - returns primary key clause and partitioning clause of a given table definition
+// Tables are only partitioned when GHA2DB_PARTITIONED is set, partitioned tables primary keys include partition column
+func PartitionClauses(ctx *Ctx, table string) (key, partitionBy string) {
+	for i := range PartitionedTables {
+		pt := &PartitionedTables[i]
+		if pt.Table != table {
+			continue
+		}
+		if !ctx.Partitioned {
+			return "primary key(" + strings.Join(pt.Key, ", ") + ")", ""
+		}
+		return "primary key(" + pt.PartitionedKey() + ")", " partition by range(" + pt.Column + ")"
+	}
+	Fatalf("table %s cannot be partitioned", table)
+	return
+}
+
+// CreateDefaultPartition - creates default partition of a given partitioned table if it does not exist yet
+func CreateDefaultPartition(con *sql.DB, ctx *Ctx, pt *PartitionedTable) {
+	ExecSQLWithErr(con, ctx, fmt.Sprintf("create table if not exists %s partition of %s default", DefaultPartitionName(pt.Table), pt.Table))
+}
+
+// IsPartitioned - checks if a given table is a partitioned table
+func IsPartitioned(con *sql.DB, ctx *Ctx, table string) bool {
+	var kind string
+	err := QueryRowSQL(
+		con,
+		ctx,
+		fmt.Sprintf("select relkind from pg_class where relname = %s and relnamespace = 'public'::regnamespace", NValue(1)),
+		table,
+	).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	FatalOnError(err)
+	return kind == "p"
+}
+
+// CreatePartition - creates partition of a given month if it does not exist yet, returns true if it was created
+// Rows of this month already present in the default partition are moved to the new partition
+func CreatePartition(con *sql.DB, ctx *Ctx, pt *PartitionedTable, month time.Time) bool {
+	month = MonthStart(month)
+	name := PartitionName(pt.Table, month)
+	if TableExists(con, ctx, name) {
+		return false
+	}
+	from, to := ToYMDDate(month), ToYMDDate(NextMonthStart(month))
+	def := DefaultPartitionName(pt.Table)
+	tx, err := con.Begin()
+	FatalOnError(err)
+	split, moved := false, 0
+	if TableExists(con, ctx, def) {
+		FatalOnError(
+			QueryRowSQLTx(
+				tx,
+				ctx,
+				fmt.Sprintf("select exists(select 1 from %s where %s >= %s and %s < %s)", def, pt.Column, NValue(1), pt.Column, NValue(2)),
+				month,
+				NextMonthStart(month),
+			).Scan(&split),
+		)
+		if split {
+			ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("create table %s (like %s including defaults)", name, pt.Table))
+			res := ExecSQLTxWithErr(
+				tx,
+				ctx,
+				fmt.Sprintf("insert into %s select * from %s where %s >= %s and %s < %s", name, def, pt.Column, NValue(1), pt.Column, NValue(2)),
+				month,
+				NextMonthStart(month),
+			)
+			n, _ := res.RowsAffected()
+			moved = int(n)
+			ExecSQLTxWithErr(
+				tx,
+				ctx,
+				fmt.Sprintf("delete from %s where %s >= %s and %s < %s", def, pt.Column, NValue(1), pt.Column, NValue(2)),
+				month,
+				NextMonthStart(month),
+			)
+			ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("alter table %s attach partition %s for values from ('%s') to ('%s')", pt.Table, name, from, to))
+		}
+	}
+	if !split {
+		ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("create table %s partition of %s for values from ('%s') to ('%s')", name, pt.Table, from, to))
+	}
+	FatalOnError(tx.Commit())
+	if split {
+		Printf("Created partition %s, moved %d rows from %s\n", name, moved, def)
+	} else if ctx.Debug > 0 {
+		Printf("Created partition %s\n", name)
+	}
+	return true
+}
+
+// EnsurePartitions - creates missing monthly partitions of all partitioned tables for months from..to, returns number of created partitions
+// Tables that are not partitioned are skipped, so it can be called on any database
+func EnsurePartitions(con *sql.DB, ctx *Ctx, from, to time.Time) (created int) {
+	for i := range PartitionedTables {
+		pt := &PartitionedTables[i]
+		if !IsPartitioned(con, ctx, pt.Table) {
+			continue
+		}
+		for month := MonthStart(from); !month.After(to); month = NextMonthStart(month) {
+			if CreatePartition(con, ctx, pt, month) {
+				created++
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package devstatscode
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestPartitionName(t *testing.T) {
+	month := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	if name := lib.PartitionName("gha_events", month); name != "gha_events_p202102" {
+		t.Errorf("expected 'gha_events_p202102', got '%s'", name)
+	}
+	if name := lib.DefaultPartitionName("gha_comments"); name != "gha_comments_default" {
+		t.Errorf("expected 'gha_comments_default', got '%s'", name)
+	}
+}
+
+func TestPartitionClauses(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		table       string
+		partitioned bool
+		key         string
+		partitionBy string
+	}{
+		{table: "gha_events", key: "primary key(id)"},
+		{table: "gha_events", partitioned: true, key: "primary key(id, created_at)", partitionBy: " partition by range(created_at)"},
+		{table: "gha_comments", key: "primary key(id, event_id)"},
+		{table: "gha_comments", partitioned: true, key: "primary key(id, event_id, dup_created_at)", partitionBy: " partition by range(dup_created_at)"},
+		{table: "gha_payloads", partitioned: true, key: "primary key(event_id, dup_created_at)", partitionBy: " partition by range(dup_created_at)"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ctx := lib.Ctx{Partitioned: test.partitioned}
+		key, partitionBy := lib.PartitionClauses(&ctx, test.table)
+		if key != test.key || partitionBy != test.partitionBy {
+			t.Errorf("test number %d, expected '%s', '%s', got '%s', '%s'", index+1, test.key, test.partitionBy, key, partitionBy)
+		}
+	}
+	// Key columns must not be modified by PartitionedKey
+	if key, _ := lib.PartitionClauses(&lib.Ctx{}, "gha_comments"); key != "primary key(id, event_id)" {
+		t.Errorf("expected unchanged key columns, got '%s'", key)
+	}
+}
@@ -36,10 +36,10 @@ func WriteTSPoints(ctx *Ctx, con *sql.DB, pts *TSPoints, mergeSeries string, hll
 	merge := false
 	mergeS := ""
 	if mergeSeries != "" {
-		if !checkPsqlName("s" + mergeSeries) {
+		mergeS = SeriesTable(ctx, mergeSeries)
+		if !checkPsqlName(mergeS) {
 			return
 		}
-		mergeS = "s" + mergeSeries
 		merge = true
 	}
 	tags := make(map[string]map[string]struct{})
@@ -67,10 +67,10 @@ func WriteTSPoints(ctx *Ctx, con *sql.DB, pts *TSPoints, mergeSeries string, hll
 		if p.fields != nil {
 			name := p.name
 			if !merge {
-				if !checkPsqlName("s" + p.name) {
+				name = SeriesTable(ctx, p.name)
+				if !checkPsqlName(name) {
 					continue
 				}
-				name = "s" + p.name
 			}
 			_, ok := fields[name]
 			if !ok {
@@ -190,6 +190,9 @@ func WriteTSPoints(ctx *Ctx, con *sql.DB, pts *TSPoints, mergeSeries string, hll
 					sq += "primary key(time, series, period))"
 					sqls = append(sqls, sq)
 					sqls = append(sqls, indices...)
+					if ctx.TSDBSuffix != "" {
+						sqls = append(sqls, "comment on table \""+mergeS+"\" is '"+TSDBShadowComment+"'")
+					}
 					sqls = append(sqls, "grant select on \""+mergeS+"\" to ro_user")
 					sqls = append(sqls, "grant select on \""+mergeS+"\" to devstats_team")
 				}
@@ -247,6 +250,9 @@ func WriteTSPoints(ctx *Ctx, con *sql.DB, pts *TSPoints, mergeSeries string, hll
 				sq += "primary key(time, period))"
 				sqls = append(sqls, sq)
 				sqls = append(sqls, indices...)
+				if ctx.TSDBSuffix != "" {
+					sqls = append(sqls, "comment on table \""+name+"\" is '"+TSDBShadowComment+"'")
+				}
 				sqls = append(sqls, "grant select on \""+name+"\" to ro_user")
 				sqls = append(sqls, "grant select on \""+name+"\" to devstats_team")
 			} else {
@@ -356,10 +362,10 @@ func WriteTSPoints(ctx *Ctx, con *sql.DB, pts *TSPoints, mergeSeries string, hll
 			ns++
 		}
 		if p.fields != nil && !merge {
-			if !checkPsqlName("s" + p.name) {
+			name := SeriesTable(ctx, p.name)
+			if !checkPsqlName(name) {
 				continue
 			}
-			name := "s" + p.name
 			namesI := []string{"time", "period"}
 			argsI := []string{"$1", "$2"}
 			vals := []interface{}{p.t, p.period}
@@ -555,6 +561,12 @@ func escapeName(name string) string {
 	return strings.Replace(name, `"`, `""`, -1)
 }
 
+// SeriesTable - returns series table name for a given series name
+// GHA2DB_TSDB_SUFFIX is added in blue/green mode, series are then written to shadow tables
+func SeriesTable(ctx *Ctx, name string) string {
+	return "s" + name + ctx.TSDBSuffix
+}
+
 // checkPsqlName - prints warning when psql name exceeds 63 bytes
 // return: true - name is OK, false: name is too long (warning is issued)
 func checkPsqlName(name string) bool {
@@ -655,19 +667,25 @@ func PgConnDB(ctx *Ctx, dbName string) *sql.DB {
 
 // CreateTable is used to replace DB specific parts of Create Table SQL statement
 func CreateTable(tdef string) string {
+	return "create table " + ddlTypes(tdef)
+}
+
+// ddlTypes - replaces {{ts}}, {{tsnow}} and {{pkauto}} type templates used in table definitions
+func ddlTypes(tdef string) string {
 	tdef = strings.Replace(tdef, "{{ts}}", "timestamp", -1)
 	tdef = strings.Replace(tdef, "{{tsnow}}", "timestamp default now()", -1)
 	tdef = strings.Replace(tdef, "{{pkauto}}", "bigserial", -1)
-	return "create table " + tdef
+	return tdef
 }
 
-// Outputs query info
-func queryOut(query string, args ...interface{}) {
+// Outputs query info, values of sensitive columns are redacted when GHA2DB_SQL_REDACT is set
+func queryOut(ctx *Ctx, query string, args ...interface{}) {
 	// Use fmt.Printf not lib.Printf here
 	// If we use lib.Printf (that logs to DB) while ouputting some query's parameters
 	// We would have infinite recurence
-	fmt.Printf("%s\n", query)
+	fmt.Printf("%s\n", RedactSQLQuery(ctx, query))
 	if len(args) > 0 {
+		args = RedactSQLArgs(ctx, query, args)
 		s := ""
 		for vi, vv := range args {
 			switch v := vv.(type) {
@@ -689,35 +707,46 @@ func queryOut(query string, args ...interface{}) {
 // QueryRowSQL executes given SQL on Postgres DB (and returns single row)
 func QueryRowSQL(con *sql.DB, ctx *Ctx, query string, args ...interface{}) *sql.Row {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return con.QueryRow(query, args...)
+	span := sqlSpan(ctx, query)
+	row := con.QueryRow(query, args...)
+	endSQLSpan(span, row.Err())
+	return row
 }
 
 // QueryRowSQLTx executes given SQL on Postgres DB (and returns single row)
 func QueryRowSQLTx(tx *sql.Tx, ctx *Ctx, query string, args ...interface{}) *sql.Row {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return tx.QueryRow(query, args...)
+	span := sqlSpan(ctx, query)
+	row := tx.QueryRow(query, args...)
+	endSQLSpan(span, row.Err())
+	return row
 }
 
 // QuerySQL executes given SQL on Postgres DB (and returns rowset that needs to be closed)
 func QuerySQL(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (*sql.Rows, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return con.Query(query, args...)
+	span := sqlSpan(ctx, query)
+	rows, err := con.Query(query, args...)
+	endSQLSpan(span, err)
+	return rows, err
 }
 
 // QuerySQLLogErr executes given SQL on Postgres DB (and returns rowset that needs to be closed)
 func QuerySQLLogErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (*sql.Rows, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
+	span := sqlSpan(ctx, query)
 	rows, err := con.Query(query, args...)
+	endSQLSpan(span, err)
 	if err != nil {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
 	return rows, err
 }
@@ -733,7 +762,7 @@ func QuerySQLWithErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) *
 	for _, try := range ctx.Trials {
 		res, err = QuerySQL(con, ctx, query, args...)
 		if err != nil {
-			queryOut(query, args...)
+			queryOut(ctx, query, args...)
 		}
 		status = FatalOnError(err)
 		if status == "ok" {
@@ -763,9 +792,12 @@ func QuerySQLWithErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) *
 // It is for running inside transaction
 func QuerySQLTx(con *sql.Tx, ctx *Ctx, query string, args ...interface{}) (*sql.Rows, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return con.Query(query, args...)
+	span := sqlSpan(ctx, query)
+	rows, err := con.Query(query, args...)
+	endSQLSpan(span, err)
+	return rows, err
 }
 
 // QuerySQLTxWithErr wrapper to QuerySQLTx that exists on error
@@ -785,7 +817,7 @@ func QuerySQLTxWithErr(con *sql.Tx, ctx *Ctx, query string, args ...interface{})
 			res, err = QuerySQL(db, ctx, query, args...)
 		}
 		if err != nil {
-			queryOut(query, args...)
+			queryOut(ctx, query, args...)
 		}
 		status = FatalOnError(err)
 		if status == "ok" {
@@ -814,11 +846,13 @@ func QuerySQLTxWithErr(con *sql.Tx, ctx *Ctx, query string, args ...interface{})
 // ExecSQLLogErr executes given SQL on Postgres DB (and return single state result, that doesn't need to be closed)
 func ExecSQLLogErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (sql.Result, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
+	span := sqlSpan(ctx, query)
 	res, err := con.Exec(query, args...)
+	endSQLSpan(span, err)
 	if err != nil {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
 	return res, err
 }
@@ -826,9 +860,12 @@ func ExecSQLLogErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (sq
 // ExecSQL executes given SQL on Postgres DB (and return single state result, that doesn't need to be closed)
 func ExecSQL(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (sql.Result, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return con.Exec(query, args...)
+	span := sqlSpan(ctx, query)
+	res, err := con.Exec(query, args...)
+	endSQLSpan(span, err)
+	return res, err
 }
 
 // ExecSQLWithErr wrapper to ExecSQL that exists on error
@@ -843,7 +880,7 @@ func ExecSQLWithErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) sq
 		res, err = ExecSQL(con, ctx, query, args...)
 		if err != nil {
 			fmt.Printf("Failed sql: ")
-			queryOut(query, args...)
+			queryOut(ctx, query, args...)
 		}
 		status = FatalOnError(err)
 		if status == "ok" {
@@ -873,9 +910,12 @@ func ExecSQLWithErr(con *sql.DB, ctx *Ctx, query string, args ...interface{}) sq
 // It is for running inside transaction
 func ExecSQLTx(con *sql.Tx, ctx *Ctx, query string, args ...interface{}) (sql.Result, error) {
 	if ctx.QOut {
-		queryOut(query, args...)
+		queryOut(ctx, query, args...)
 	}
-	return con.Exec(query, args...)
+	span := sqlSpan(ctx, query)
+	res, err := con.Exec(query, args...)
+	endSQLSpan(span, err)
+	return res, err
 }
 
 // ExecSQLTxWithErr wrapper to ExecSQLTx that exists on error
@@ -895,7 +935,7 @@ func ExecSQLTxWithErr(con *sql.Tx, ctx *Ctx, query string, args ...interface{})
 			res, err = ExecSQL(db, ctx, query, args...)
 		}
 		if err != nil {
-			queryOut(query, args...)
+			queryOut(ctx, query, args...)
 		}
 		status = FatalOnError(err)
 		if status == "ok" {
@@ -0,0 +1,205 @@
+package devstatscode
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Pool - bounded pool of worker goroutines, replaces 'nThreads++ / <-ch / nThreads--' loops
+// Submit blocks while Size() tasks are running, Wait blocks until all submitted tasks are finished
+// Task panics (including FatalOnError ones) are captured and returned by Wait, tasks submitted after a panic are skipped
+// (Submit returns false), so callers processing ordered work (like GHA hours) can stop at the first failure
+type Pool struct {
+	ctx      *Ctx
+	mtx      sync.Mutex
+	doneMtx  sync.Mutex
+	cond     *sync.Cond
+	size     int
+	maxSize  int
+	running  int
+	finished int
+	refresh  int
+	onDone   func(int)
+	errs     []error
+}
+
+// NewPool - creates pool running up to GetThreadsNum tasks at once, but not more than maxSize (maxSize < 1 means no limit)
+func NewPool(ctx *Ctx, maxSize int) *Pool {
+	p := &Pool{ctx: ctx, maxSize: maxSize}
+	p.cond = sync.NewCond(&p.mtx)
+	p.size = p.clamp(GetThreadsNum(ctx))
+	return p
+}
+
+// clamp - returns given pool size limited to 1 - maxSize range
+func (p *Pool) clamp(size int) int {
+	if p.maxSize > 0 && size > p.maxSize {
+		size = p.maxSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// RefreshEvery - pool size will be refreshed from GetThreadsNum after every n finished tasks (n < 1 disables)
+func (p *Pool) RefreshEvery(n int) *Pool {
+	p.mtx.Lock()
+	p.refresh = n
+	p.mtx.Unlock()
+	return p
+}
+
+// OnDone - f will be called with number of finished tasks after each task finishes (calls are serialized), used to report progress
+func (p *Pool) OnDone(f func(finished int)) *Pool {
+	p.mtx.Lock()
+	p.onDone = f
+	p.mtx.Unlock()
+	return p
+}
+
+// Size - returns number of tasks allowed to run at once
+func (p *Pool) Size() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.size
+}
+
+// Running - returns number of currently running tasks
+func (p *Pool) Running() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.running
+}
+
+// Finished - returns number of finished tasks
+func (p *Pool) Finished() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.finished
+}
+
+// Resize - sets number of tasks allowed to run at once (limited to 1 - maxSize), returns the new size
+// Running tasks are not interrupted when shrinking, Submit just waits until enough of them finish
+func (p *Pool) Resize(size int) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.size = p.clamp(size)
+	p.cond.Broadcast()
+	return p.size
+}
+
+// Refresh - sets pool size from GetThreadsNum (it can change when GHA2DB_NCPUS/GHA2DB_ST are changed), returns the new size
+func (p *Pool) Refresh() int {
+	return p.Resize(GetThreadsNum(p.ctx))
+}
+
+// Shrink - lowers pool size by one, returns false if pool already has a single worker
+func (p *Pool) Shrink() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.size <= 1 {
+		return false
+	}
+	p.size--
+	return true
+}
+
+// Grow - rises pool size by one, returns false if pool already has maxSize workers
+func (p *Pool) Grow() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.maxSize > 0 && p.size >= p.maxSize {
+		return false
+	}
+	p.size++
+	p.cond.Broadcast()
+	return true
+}
+
+// Submit - waits for a free worker and runs task on it
+// Returns false (without running task) when any task has already panicked
+func (p *Pool) Submit(task func()) bool {
+	p.mtx.Lock()
+	for p.running >= p.size && len(p.errs) == 0 {
+		p.cond.Wait()
+	}
+	if len(p.errs) > 0 {
+		p.mtx.Unlock()
+		return false
+	}
+	p.running++
+	p.mtx.Unlock()
+	go p.run(task)
+	return true
+}
+
+// Failed - returns true when any task has panicked, no more tasks are started then
+func (p *Pool) Failed() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.errs) > 0
+}
+
+// run - runs a single task and its OnDone callback, then releases its worker
+// Panic is recorded as soon as the task returns, so no new task is started after it
+func (p *Pool) run(task func()) {
+	err := p.call(task)
+	p.mtx.Lock()
+	if err != nil {
+		p.errs = append(p.errs, err)
+		p.cond.Broadcast()
+	}
+	p.finished++
+	finished, onDone := p.finished, p.onDone
+	if p.refresh > 0 && finished%p.refresh == 0 {
+		p.size = p.clamp(GetThreadsNum(p.ctx))
+	}
+	p.mtx.Unlock()
+	var doneErr error
+	if onDone != nil {
+		p.doneMtx.Lock()
+		doneErr = p.call(func() { onDone(finished) })
+		p.doneMtx.Unlock()
+	}
+	p.mtx.Lock()
+	if doneErr != nil {
+		p.errs = append(p.errs, doneErr)
+	}
+	p.running--
+	p.cond.Broadcast()
+	p.mtx.Unlock()
+}
+
+// call - calls f, returns its panic as an error (error panics are wrapped, so ExitCode can classify them)
+func (p *Pool) call(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = fmt.Errorf("pool task panic: %w\n%s", e, debug.Stack())
+				return
+			}
+			err = fmt.Errorf("pool task panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	f()
+	return
+}
+
+// Wait - waits until all submitted tasks are finished, returns first captured task panic (if any)
+func (p *Pool) Wait() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for p.running > 0 {
+		p.cond.Wait()
+	}
+	switch len(p.errs) {
+	case 0:
+		return nil
+	case 1:
+		return p.errs[0]
+	default:
+		return fmt.Errorf("%w\n(and %d more task panics)", p.errs[0], len(p.errs)-1)
+	}
+}
@@ -0,0 +1,200 @@
+package devstatscode
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 0)
+	for _, size := range []int{1, 3, 8} {
+		if got := pool.Resize(size); got != size {
+			t.Errorf("expected size %d, got %d", size, got)
+		}
+		var running, maxRunning, done int32
+		for i := 0; i < 40; i++ {
+			pool.Submit(func() {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&done, 1)
+			})
+		}
+		if err := pool.Wait(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if done != 40 {
+			t.Errorf("size %d: expected 40 tasks done, got %d", size, done)
+		}
+		if maxRunning > int32(size) {
+			t.Errorf("size %d: up to %d tasks were running at once", size, maxRunning)
+		}
+		if pool.Running() != 0 {
+			t.Errorf("size %d: expected no running tasks after Wait, got %d", size, pool.Running())
+		}
+	}
+	if pool.Finished() != 120 {
+		t.Errorf("expected 120 finished tasks, got %d", pool.Finished())
+	}
+}
+
+func TestPoolResize(t *testing.T) {
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 4)
+	if pool.Size() < 1 || pool.Size() > 4 {
+		t.Errorf("expected initial size in 1-4 range, got %d", pool.Size())
+	}
+	// Test cases
+	var testCases = []struct {
+		size     int
+		expected int
+	}{
+		{size: 2, expected: 2},
+		{size: 0, expected: 1},
+		{size: -3, expected: 1},
+		{size: 4, expected: 4},
+		{size: 16, expected: 4},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := pool.Resize(test.size)
+		if got != test.expected || pool.Size() != test.expected {
+			t.Errorf("test number %d, expected %d, got %d/%d", index+1, test.expected, got, pool.Size())
+		}
+	}
+	if pool.Grow() {
+		t.Errorf("pool should not grow above its max size")
+	}
+	pool.Resize(1)
+	if pool.Shrink() {
+		t.Errorf("pool should not shrink below a single worker")
+	}
+	if !pool.Grow() || pool.Size() != 2 {
+		t.Errorf("expected pool to grow to 2, got %d", pool.Size())
+	}
+	if !pool.Shrink() || pool.Size() != 1 {
+		t.Errorf("expected pool to shrink to 1, got %d", pool.Size())
+	}
+}
+
+func TestPoolRefresh(t *testing.T) {
+	st := os.Getenv("GHA2DB_ST")
+	defer func() { _ = os.Setenv("GHA2DB_ST", st) }()
+	_ = os.Setenv("GHA2DB_ST", "1")
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 0).RefreshEvery(5)
+	pool.Resize(3)
+	if pool.Refresh() != 1 {
+		t.Errorf("expected refreshed size 1 in single threaded mode, got %d", pool.Size())
+	}
+	pool.Resize(3)
+	for i := 0; i < 5; i++ {
+		pool.Submit(func() {})
+	}
+	if err := pool.Wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if pool.Size() != 1 {
+		t.Errorf("expected size refreshed to 1 after 5 tasks, got %d", pool.Size())
+	}
+}
+
+func TestPoolCapturesPanics(t *testing.T) {
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 0)
+	pool.Resize(2)
+	if err := pool.Wait(); err != nil {
+		t.Errorf("expected no error from empty pool, got %v", err)
+	}
+	var (
+		mtx  sync.Mutex
+		done []int
+	)
+	for i := 0; i < 4; i++ {
+		i := i
+		pool.Submit(func() {
+			if i == 1 {
+				panic("task failed")
+			}
+			mtx.Lock()
+			done = append(done, i)
+			mtx.Unlock()
+		})
+	}
+	err := pool.Wait()
+	if err == nil || !strings.Contains(err.Error(), "task failed") {
+		t.Errorf("expected captured panic error, got %v", err)
+	}
+	if pool.Running() != 0 {
+		t.Errorf("expected no running tasks after panic, got %d", pool.Running())
+	}
+	if !pool.Failed() {
+		t.Errorf("expected pool to be failed after a panic")
+	}
+	n := len(done)
+	if pool.Submit(func() { done = append(done, 100) }) {
+		t.Errorf("expected Submit to return false after a panic")
+	}
+	if pool.Wait() == nil || len(done) != n {
+		t.Errorf("expected tasks submitted after a panic to be skipped")
+	}
+}
+
+func TestPoolStopsAfterPanic(t *testing.T) {
+	var ctx lib.Ctx
+	pool := lib.NewPool(&ctx, 0)
+	pool.Resize(1)
+	// With a single worker the next task is submitted only after the failed one finished
+	started := 0
+	for i := 0; i < 10; i++ {
+		i := i
+		if !pool.Submit(func() {
+			started++
+			if i == 2 {
+				panic("hour failed")
+			}
+		}) {
+			break
+		}
+	}
+	if err := pool.Wait(); err == nil {
+		t.Errorf("expected captured panic error")
+	}
+	if started != 3 {
+		t.Errorf("expected no tasks to start after a panic, %d started", started)
+	}
+}
+
+func TestPoolOnDone(t *testing.T) {
+	var ctx lib.Ctx
+	calls, last := 0, 0
+	pool := lib.NewPool(&ctx, 0).OnDone(func(finished int) {
+		calls++
+		if finished > last {
+			last = finished
+		}
+	})
+	pool.Resize(4)
+	for i := 0; i < 25; i++ {
+		pool.Submit(func() { time.Sleep(time.Millisecond) })
+	}
+	if err := pool.Wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 25 || last != 25 {
+		t.Errorf("expected 25 OnDone calls up to 25 finished tasks, got %d calls up to %d", calls, last)
+	}
+}
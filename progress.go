@@ -0,0 +1,152 @@
+package devstatscode
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ProgressReport - single progress report, it is also the format of JSON progress lines (GHA2DB_PROGRESS_JSON)
+// Rate is the average number of items per second since start, CurrentRate is the rate since the previous report
+// ETA and Remaining are only set when both total and rate are known
+type ProgressReport struct {
+	Name        string     `json:"progress"`
+	Done        int        `json:"done"`
+	Total       int        `json:"total"`
+	Percent     float64    `json:"percent"`
+	Rate        float64    `json:"rate"`
+	CurrentRate float64    `json:"current_rate"`
+	Elapsed     float64    `json:"elapsed_s"`
+	Remaining   *float64   `json:"remaining_s,omitempty"`
+	ETA         *time.Time `json:"eta,omitempty"`
+	Msg         string     `json:"msg,omitempty"`
+	Dt          time.Time  `json:"dt"`
+}
+
+// Progress - progress reporter of long running operations: done/total, items/sec rate and ETA
+// Reports are printed at most once per period, as text or as JSON lines when GHA2DB_PROGRESS_JSON is set
+// Can be used from many goroutines
+type Progress struct {
+	Name   string
+	N      int
+	json   bool
+	period time.Duration
+	start  time.Time
+	first  int
+	last   time.Time
+	lastI  int
+	mtx    sync.Mutex
+}
+
+// NewProgress - returns progress reporter of n items (n = 0 means unknown), reporting at most once per period
+func NewProgress(ctx *Ctx, name string, n int, period time.Duration) *Progress {
+	now := time.Now()
+	return &Progress{Name: name, N: n, json: ctx.ProgressJSON, period: period, start: now, last: now}
+}
+
+// SetTotal - sets number of all items (when it is only known after processing started)
+func (p *Progress) SetTotal(n int) {
+	p.mtx.Lock()
+	p.N = n
+	p.mtx.Unlock()
+}
+
+// SetStart - sets number of items already done before start (resumed operations), they are not counted in rates
+func (p *Progress) SetStart(i int) {
+	p.mtx.Lock()
+	p.first = i
+	p.lastI = i
+	p.mtx.Unlock()
+}
+
+// Stats - returns progress report of i items done at a given time, without updating reporter state
+func (p *Progress) Stats(i int, msg string, now time.Time) (r ProgressReport) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.stats(i, msg, now)
+}
+
+// stats - Stats() with mutex already locked
+func (p *Progress) stats(i int, msg string, now time.Time) (r ProgressReport) {
+	r = ProgressReport{Name: p.Name, Done: i, Total: p.N, Msg: msg, Dt: now}
+	if p.N > 0 {
+		r.Percent = (float64(i) * 100.0) / float64(p.N)
+	}
+	elapsed := now.Sub(p.start).Seconds()
+	r.Elapsed = elapsed
+	if elapsed > 0 {
+		r.Rate = float64(i-p.first) / elapsed
+	}
+	if since := now.Sub(p.last).Seconds(); since > 0 {
+		r.CurrentRate = float64(i-p.lastI) / since
+	}
+	if p.N > 0 && r.Rate > 0 {
+		remaining := float64(p.N-i) / r.Rate
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta := now.Add(time.Duration(remaining * float64(time.Second)))
+		r.Remaining = &remaining
+		r.ETA = &eta
+	}
+	return
+}
+
+// Report - prints progress of i items done if at least period passed since the last report, returns true if printed
+func (p *Progress) Report(i int, msg string) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	now := time.Now()
+	if p.last.Add(p.period).After(now) {
+		return false
+	}
+	p.print(p.stats(i, msg, now))
+	p.last = now
+	p.lastI = i
+	return true
+}
+
+// Final - always prints progress of i items done, used when operation finishes
+func (p *Progress) Final(i int, msg string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	now := time.Now()
+	p.print(p.stats(i, msg, now))
+	p.last = now
+	p.lastI = i
+}
+
+// print - outputs progress report as text or as a single JSON line
+// JSON lines are written directly to stdout, without log prefix, so they can be parsed by dashboards
+func (p *Progress) print(r ProgressReport) {
+	if p.json {
+		data, err := jsoniter.Marshal(r)
+		FatalOnError(err)
+		fmt.Printf("%s\n", string(data))
+		return
+	}
+	Printf("%s\n", r.String())
+}
+
+// String - text representation of progress report
+func (r ProgressReport) String() string {
+	s := ""
+	if r.Name != "" {
+		s = r.Name + ": "
+	}
+	if r.Total > 0 {
+		s += fmt.Sprintf("%d/%d (%.3f%%)", r.Done, r.Total, r.Percent)
+	} else {
+		s += fmt.Sprintf("%d", r.Done)
+	}
+	s += fmt.Sprintf(", %.2f/s (current %.2f/s)", r.Rate, r.CurrentRate)
+	if r.ETA != nil {
+		s += fmt.Sprintf(", ETA: %v (%v left)", ToYMDHMSDate(*r.ETA), time.Duration(*r.Remaining*float64(time.Second)).Truncate(time.Second))
+	}
+	if r.Msg != "" {
+		s += ": " + r.Msg
+	}
+	return s
+}
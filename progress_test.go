@@ -0,0 +1,79 @@
+package devstatscode
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestProgress(t *testing.T) {
+	var ctx lib.Ctx
+	dtStart := time.Now()
+	p := lib.NewProgress(&ctx, "test", 100, time.Hour)
+
+	// Test cases: seconds since start, items done, items done before start
+	var testCases = []struct {
+		secs          int
+		done          int
+		first         int
+		total         int
+		expectedRate  float64
+		expectedPerc  float64
+		expectedLeft  float64
+		expectedNoETA bool
+	}{
+		{secs: 10, done: 0, total: 100, expectedRate: 0, expectedPerc: 0, expectedNoETA: true},
+		{secs: 10, done: 20, total: 100, expectedRate: 2, expectedPerc: 20, expectedLeft: 40},
+		{secs: 50, done: 100, total: 100, expectedRate: 2, expectedPerc: 100, expectedLeft: 0},
+		{secs: 10, done: 50, first: 40, total: 100, expectedRate: 1, expectedPerc: 50, expectedLeft: 50},
+		{secs: 10, done: 20, total: 0, expectedRate: 2, expectedPerc: 0, expectedNoETA: true},
+		{secs: 10, done: 120, total: 100, expectedRate: 12, expectedPerc: 120, expectedLeft: 0},
+	}
+	for index, test := range testCases {
+		p.SetTotal(test.total)
+		p.SetStart(test.first)
+		got := p.Stats(test.done, "msg", dtStart.Add(time.Duration(test.secs)*time.Second))
+		if math.Abs(got.Rate-test.expectedRate) > 0.01 {
+			t.Errorf("test number %d, expected rate %v, got %v, test case: %+v", index+1, test.expectedRate, got.Rate, test)
+		}
+		if math.Abs(got.Percent-test.expectedPerc) > 0.01 {
+			t.Errorf("test number %d, expected %v%%, got %v%%, test case: %+v", index+1, test.expectedPerc, got.Percent, test)
+		}
+		if test.expectedNoETA {
+			if got.ETA != nil || got.Remaining != nil {
+				t.Errorf("test number %d, expected no ETA, got %+v, test case: %+v", index+1, got, test)
+			}
+			continue
+		}
+		if got.ETA == nil || got.Remaining == nil {
+			t.Errorf("test number %d, expected ETA, got none, test case: %+v", index+1, test)
+			continue
+		}
+		if math.Abs(*got.Remaining-test.expectedLeft) > 0.1 {
+			t.Errorf("test number %d, expected %vs left, got %v, test case: %+v", index+1, test.expectedLeft, *got.Remaining, test)
+		}
+		if !got.ETA.Equal(got.Dt.Add(time.Duration(*got.Remaining * float64(time.Second)))) {
+			t.Errorf("test number %d, ETA %v does not match remaining %v, test case: %+v", index+1, *got.ETA, *got.Remaining, test)
+		}
+	}
+
+	// Text report
+	p.SetTotal(100)
+	p.SetStart(0)
+	str := p.Stats(20, "msg", dtStart.Add(10*time.Second)).String()
+	if !strings.HasPrefix(str, "test: 20/100 (20.000%), 2.00/s") || !strings.Contains(str, "ETA: ") || !strings.HasSuffix(str, ": msg") {
+		t.Errorf("unexpected text report: %s", str)
+	}
+
+	// Reports are printed at most once per period, final report is always printed
+	if p.Report(10, "") {
+		t.Errorf("expected no report before period passed")
+	}
+	p = lib.NewProgress(&ctx, "test", 100, 0)
+	if !p.Report(10, "") {
+		t.Errorf("expected report when period passed")
+	}
+}
@@ -0,0 +1,247 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ProvisionalEventIDBase - IDs of provisional events (received via GitHub webhooks) start here
+// GHA event IDs are far below it, ghapi2db artificial events use 2^48 + GitHub event ID
+const ProvisionalEventIDBase = 1 << 50
+
+// ProvisionalEventsTable - events received via GitHub webhooks that were not yet confirmed by GHA hour files
+const ProvisionalEventsTable = "gha_provisional_events"
+
+// WebhookEventTypes - GitHub webhook event names (X-GitHub-Event header) and GHA event types they are mapped to
+var WebhookEventTypes = map[string]string{
+	"commit_comment":              "CommitCommentEvent",
+	"create":                      "CreateEvent",
+	"delete":                      "DeleteEvent",
+	"fork":                        "ForkEvent",
+	"gollum":                      "GollumEvent",
+	"issue_comment":               "IssueCommentEvent",
+	"issues":                      "IssuesEvent",
+	"member":                      "MemberEvent",
+	"public":                      "PublicEvent",
+	"pull_request":                "PullRequestEvent",
+	"pull_request_review":         "PullRequestReviewEvent",
+	"pull_request_review_comment": "PullRequestReviewCommentEvent",
+	"push":                        "PushEvent",
+	"release":                     "ReleaseEvent",
+	"watch":                       "WatchEvent",
+}
+
+// webhookCommit - push webhook commit, GHA PushEvent commit has "sha" instead of "id"
+type webhookCommit struct {
+	ID       string `json:"id"`
+	Message  string `json:"message"`
+	Distinct bool   `json:"distinct"`
+	Author   Author `json:"author"`
+}
+
+// webhookPayload - GitHub webhook payload, GHA payloads are built from the same objects
+type webhookPayload struct {
+	Action     *string `json:"action"`
+	Sender     Actor   `json:"sender"`
+	Repository struct {
+		ID       int    `json:"id"`
+		FullName string `json:"full_name"`
+		Private  bool   `json:"private"`
+	} `json:"repository"`
+	Organization *Org            `json:"organization"`
+	Ref          *string         `json:"ref"`
+	Before       *string         `json:"before"`
+	After        *string         `json:"after"`
+	Commits      []webhookCommit `json:"commits"`
+	RefType      *string         `json:"ref_type"`
+	MasterBranch *string         `json:"master_branch"`
+	Description  *string         `json:"description"`
+	Number       *int            `json:"number"`
+	Forkee       *Forkee         `json:"forkee"`
+	Release      *Release        `json:"release"`
+	Member       *Actor          `json:"member"`
+	Issue        *Issue          `json:"issue"`
+	Comment      *Comment        `json:"comment"`
+	Review       *Review         `json:"review"`
+	Pages        *[]Page         `json:"pages"`
+	PullRequest  *PullRequest    `json:"pull_request"`
+}
+
+// NewWebhookEvent - maps GitHub webhook of a given X-GitHub-Event name and X-GitHub-Delivery ID into a provisional GHA event
+// Event is created at a given (receive) time, nil event is returned for webhooks that have no GHA event type
+func NewWebhookEvent(name, delivery string, body []byte, now time.Time) (*Event, error) {
+	typ, ok := WebhookEventTypes[name]
+	if !ok {
+		return nil, nil
+	}
+	var wh webhookPayload
+	err := jsoniter.Unmarshal(body, &wh)
+	if err != nil {
+		return nil, err
+	}
+	if wh.Repository.FullName == "" || wh.Sender.Login == "" {
+		return nil, fmt.Errorf("%s webhook %s has no repository or sender", name, delivery)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(delivery))
+	ev := &Event{
+		ID:        fmt.Sprintf("%d", ProvisionalEventIDBase+int64(h.Sum64()>>16)),
+		Type:      typ,
+		Public:    !wh.Repository.Private,
+		CreatedAt: now.UTC(),
+		Actor:     wh.Sender,
+		Repo:      Repo{ID: wh.Repository.ID, Name: wh.Repository.FullName},
+		Org:       wh.Organization,
+		Payload: Payload{
+			Ref:          wh.Ref,
+			Before:       wh.Before,
+			Action:       wh.Action,
+			RefType:      wh.RefType,
+			MasterBranch: wh.MasterBranch,
+			Description:  wh.Description,
+			Number:       wh.Number,
+			Forkee:       wh.Forkee,
+			Release:      wh.Release,
+			Member:       wh.Member,
+			Issue:        wh.Issue,
+			Comment:      wh.Comment,
+			Review:       wh.Review,
+			Pages:        wh.Pages,
+			PullRequest:  wh.PullRequest,
+		},
+	}
+	if typ == "PushEvent" {
+		commits := []Commit{}
+		for _, commit := range wh.Commits {
+			commits = append(commits, Commit{SHA: commit.ID, Author: commit.Author, Message: commit.Message, Distinct: commit.Distinct})
+		}
+		size := len(commits)
+		ev.Payload.Head = wh.After
+		ev.Payload.Size = &size
+		ev.Payload.Commits = &commits
+	}
+	return ev, nil
+}
+
+// EventKey - returns key identifying the same GitHub activity in a GHA event and in a provisional (webhook) event
+// Empty key is returned for events that cannot be matched
+func EventKey(ev *Event) string {
+	pl := &ev.Payload
+	action := ""
+	if pl.Action != nil {
+		action = *pl.Action
+	}
+	detail := ""
+	switch ev.Type {
+	case "PushEvent":
+		if pl.Head != nil {
+			detail = *pl.Head
+		}
+	case "IssuesEvent":
+		if pl.Issue != nil {
+			detail = fmt.Sprintf("%d:%s", pl.Issue.ID, action)
+		}
+	case "IssueCommentEvent", "CommitCommentEvent", "PullRequestReviewCommentEvent":
+		if pl.Comment != nil {
+			detail = fmt.Sprintf("%d", pl.Comment.ID)
+		}
+	case "PullRequestEvent":
+		if pl.PullRequest != nil {
+			detail = fmt.Sprintf("%d:%s", pl.PullRequest.ID, action)
+		}
+	case "PullRequestReviewEvent":
+		if pl.Review != nil {
+			detail = fmt.Sprintf("%d", pl.Review.ID)
+		}
+	case "ReleaseEvent":
+		if pl.Release != nil {
+			detail = fmt.Sprintf("%d:%s", pl.Release.ID, action)
+		}
+	case "ForkEvent":
+		if pl.Forkee != nil {
+			detail = fmt.Sprintf("%d", pl.Forkee.ID)
+		}
+	case "MemberEvent":
+		if pl.Member != nil {
+			detail = fmt.Sprintf("%d:%s", pl.Member.ID, action)
+		}
+	case "CreateEvent", "DeleteEvent":
+		if pl.RefType != nil {
+			detail = *pl.RefType + ":"
+			if pl.Ref != nil {
+				detail += *pl.Ref
+			}
+		}
+	case "WatchEvent", "PublicEvent", "GollumEvent":
+		detail = fmt.Sprintf("%d", ev.Actor.ID)
+	}
+	if detail == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%s", ev.Type, ev.Repo.ID, detail)
+}
+
+// AddProvisionalEvent - tags already written event as provisional
+func AddProvisionalEvent(con *sql.DB, ctx *Ctx, ev *Event, delivery string) {
+	q, args := NewQB(ProvisionalEventsTable).
+		Set("event_id", ev.ID).
+		Set("key", EventKey(ev)).
+		Set("delivery", delivery).
+		Set("type", ev.Type).
+		Set("dup_repo_name", ev.Repo.Name).
+		Set("created_at", ev.CreatedAt).
+		InsertIgnore()
+	ExecSQLWithErr(con, ctx, q, args...)
+}
+
+// deleteProvisionalEvents - deletes provisional events returned by a given query (with all their rows), returns number of deleted events
+func deleteProvisionalEvents(con *sql.DB, ctx *Ctx, query string, args ...interface{}) (n int) {
+	rows := QuerySQLWithErr(con, ctx, query, args...)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		FatalOnError(rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	FatalOnError(rows.Err())
+	FatalOnError(rows.Close())
+	for _, id := range ids {
+		// All rows written for the event (including its provisional tag) have its event_id, see SchemaManifest
+		for table, columns := range SchemaManifest {
+			for _, column := range columns {
+				if column == "event_id" {
+					ExecSQLWithErr(con, ctx, "delete from "+table+" where event_id = "+NValue(1), id)
+					break
+				}
+			}
+		}
+		ExecSQLWithErr(con, ctx, "delete from gha_events where id = "+NValue(1), id)
+		n++
+	}
+	return
+}
+
+// ConfirmProvisionalEvents - deletes provisional events matching a given GHA event (GHA event replaces them), returns number of deleted events
+func ConfirmProvisionalEvents(con *sql.DB, ctx *Ctx, ev *Event) int {
+	key := EventKey(ev)
+	if key == "" {
+		return 0
+	}
+	return deleteProvisionalEvents(con, ctx, "select event_id from "+ProvisionalEventsTable+" where key = "+NValue(1), key)
+}
+
+// ExpireProvisionalEvents - deletes provisional events created before a given date that were not confirmed by GHA (GHA is the source of truth)
+func ExpireProvisionalEvents(con *sql.DB, ctx *Ctx, before time.Time) int {
+	return deleteProvisionalEvents(con, ctx, "select event_id from "+ProvisionalEventsTable+" where created_at < "+NValue(1), before)
+}
+
+// HasProvisionalEvents - checks if there are any provisional events, GHA events are only matched against them when there are
+func HasProvisionalEvents(con *sql.DB, ctx *Ctx) bool {
+	rows := QuerySQLWithErr(con, ctx, "select 1 from "+ProvisionalEventsTable+" limit 1")
+	defer func() { FatalOnError(rows.Close()) }()
+	return rows.Next()
+}
@@ -0,0 +1,140 @@
+package devstatscode
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestNewWebhookEvent(t *testing.T) {
+	now := time.Date(2021, 7, 1, 10, 20, 30, 0, time.UTC)
+	// Test cases
+	var testCases = []struct {
+		name     string
+		body     string
+		ghaJSON  string
+		typ      string
+		repo     string
+		actor    string
+		org      bool
+		public   bool
+		nilEvent bool
+		err      bool
+	}{
+		{
+			name:    "push",
+			body:    `{"ref":"refs/heads/master","before":"b1","after":"a1","commits":[{"id":"a1","message":"Fix","distinct":true,"author":{"name":"Lukasz","email":"l@x.y","username":"lukaszgryglicki"}}],"repository":{"id":2,"full_name":"cncf/devstats","private":false},"organization":{"id":4,"login":"cncf"},"sender":{"id":1,"login":"lukaszgryglicki"}}`,
+			ghaJSON: `{"id":"10","type":"PushEvent","actor":{"id":1,"login":"lukaszgryglicki"},"repo":{"id":2,"name":"cncf/devstats"},"payload":{"push_id":5,"size":1,"ref":"refs/heads/master","head":"a1","before":"b1","commits":[{"sha":"a1"}]}}`,
+			typ:     "PushEvent",
+			repo:    "cncf/devstats",
+			actor:   "lukaszgryglicki",
+			org:     true,
+			public:  true,
+		},
+		{
+			name:    "issues",
+			body:    `{"action":"opened","issue":{"id":7,"number":3,"title":"Bug"},"repository":{"id":2,"full_name":"cncf/devstats","private":true},"sender":{"id":1,"login":"lukaszgryglicki"}}`,
+			ghaJSON: `{"id":"11","type":"IssuesEvent","actor":{"id":1,"login":"lukaszgryglicki"},"repo":{"id":2,"name":"cncf/devstats"},"payload":{"action":"opened","issue":{"id":7,"number":3}}}`,
+			typ:     "IssuesEvent",
+			repo:    "cncf/devstats",
+			actor:   "lukaszgryglicki",
+		},
+		{
+			name:    "create",
+			body:    `{"ref":"v1.0.0","ref_type":"tag","master_branch":"master","repository":{"id":2,"full_name":"cncf/devstats"},"sender":{"id":1,"login":"lukaszgryglicki"}}`,
+			ghaJSON: `{"id":"12","type":"CreateEvent","actor":{"id":1,"login":"lukaszgryglicki"},"repo":{"id":2,"name":"cncf/devstats"},"payload":{"ref":"v1.0.0","ref_type":"tag"}}`,
+			typ:     "CreateEvent",
+			repo:    "cncf/devstats",
+			actor:   "lukaszgryglicki",
+			public:  true,
+		},
+		{
+			name:     "ping",
+			body:     `{"zen":"Keep it logically awesome.","hook_id":1}`,
+			nilEvent: true,
+		},
+		{
+			name: "watch",
+			body: `{"action":"started","sender":{"id":1,"login":"lukaszgryglicki"}}`,
+			err:  true,
+		},
+		{
+			name: "issues",
+			body: `{"action":`,
+			err:  true,
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ev, err := lib.NewWebhookEvent(test.name, "72d3162e-cc78-11e3-81ab-4c9367dc0958", []byte(test.body), now)
+		if (err != nil) != test.err {
+			t.Errorf("test number %d, expected error %v, got %v", index+1, test.err, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if (ev == nil) != test.nilEvent {
+			t.Errorf("test number %d, expected nil event %v, got %+v", index+1, test.nilEvent, ev)
+			continue
+		}
+		if ev == nil {
+			continue
+		}
+		if ev.Type != test.typ || ev.Repo.Name != test.repo || ev.Actor.Login != test.actor || (ev.Org != nil) != test.org || ev.Public != test.public {
+			t.Errorf("test number %d, expected %s %s %s org %v public %v, got %+v", index+1, test.typ, test.repo, test.actor, test.org, test.public, ev)
+		}
+		if !ev.CreatedAt.Equal(now) {
+			t.Errorf("test number %d, expected created at %v, got %v", index+1, now, ev.CreatedAt)
+		}
+		var id int64
+		if err = jsoniter.UnmarshalFromString(ev.ID, &id); err != nil || id < lib.ProvisionalEventIDBase {
+			t.Errorf("test number %d, expected provisional event ID, got %s", index+1, ev.ID)
+		}
+		// The same activity has the same key in GHA and in a webhook
+		var gha lib.Event
+		if err = jsoniter.UnmarshalFromString(test.ghaJSON, &gha); err != nil {
+			t.Fatalf("test number %d: %v", index+1, err)
+		}
+		if key := lib.EventKey(ev); key == "" || key != lib.EventKey(&gha) {
+			t.Errorf("test number %d, expected GHA key %s, got %s", index+1, lib.EventKey(&gha), key)
+		}
+	}
+	// Event ID only depends on delivery ID (redelivered webhooks have the same ID)
+	body := []byte(`{"action":"started","repository":{"id":2,"full_name":"cncf/devstats"},"sender":{"id":1,"login":"lukaszgryglicki"}}`)
+	ev1, _ := lib.NewWebhookEvent("watch", "d1", body, now)
+	ev2, _ := lib.NewWebhookEvent("watch", "d1", body, now.Add(time.Hour))
+	ev3, _ := lib.NewWebhookEvent("watch", "d2", body, now)
+	if ev1.ID != ev2.ID || ev1.ID == ev3.ID {
+		t.Errorf("expected the same event ID for the same delivery only, got %s, %s, %s", ev1.ID, ev2.ID, ev3.ID)
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		json     string
+		expected string
+	}{
+		{json: `{"type":"PushEvent","repo":{"id":2},"payload":{"head":"a1"}}`, expected: "PushEvent:2:a1"},
+		{json: `{"type":"PullRequestEvent","repo":{"id":2},"payload":{"action":"closed","pull_request":{"id":9}}}`, expected: "PullRequestEvent:2:9:closed"},
+		{json: `{"type":"IssueCommentEvent","repo":{"id":2},"payload":{"action":"created","comment":{"id":8}}}`, expected: "IssueCommentEvent:2:8"},
+		{json: `{"type":"DeleteEvent","repo":{"id":2},"payload":{"ref":"feature","ref_type":"branch"}}`, expected: "DeleteEvent:2:branch:feature"},
+		{json: `{"type":"WatchEvent","actor":{"id":1},"repo":{"id":2},"payload":{"action":"started"}}`, expected: "WatchEvent:2:1"},
+		{json: `{"type":"IssuesEvent","repo":{"id":2},"payload":{"action":"opened"}}`, expected: ""},
+		{json: `{"type":"SponsorshipEvent","repo":{"id":2},"payload":{}}`, expected: ""},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		var ev lib.Event
+		if err := jsoniter.UnmarshalFromString(test.json, &ev); err != nil {
+			t.Fatalf("test number %d: %v", index+1, err)
+		}
+		got := lib.EventKey(&ev)
+		if got != test.expected {
+			t.Errorf("test number %d, expected '%s', got '%s'", index+1, test.expected, got)
+		}
+	}
+}
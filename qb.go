@@ -0,0 +1,103 @@
+package devstatscode
+
+import (
+	"strings"
+)
+
+// QB - small typed insert/update query builder
+// It tracks column names and their placeholders ($1, $2, ...) automatically,
+// so there is no need to count NValues(n) manually
+// Usage: q, args := NewQB("gha_texts").Set("event_id", eid).Set("body", body).InsertIgnore()
+type QB struct {
+	table string
+	cols  []string
+	args  []interface{}
+}
+
+// NewQB - returns new query builder for a given table
+func NewQB(table string) *QB {
+	return &QB{table: table}
+}
+
+// Set - adds column with its value, columns are used in the order they were set
+func (qb *QB) Set(col string, value interface{}) *QB {
+	qb.cols = append(qb.cols, col)
+	qb.args = append(qb.args, value)
+	return qb
+}
+
+// Cols - returns number of columns set so far
+func (qb *QB) Cols() int {
+	return len(qb.cols)
+}
+
+// Args - returns query arguments in the placeholders order used by Insert and InsertIgnore
+func (qb *QB) Args() []interface{} {
+	return qb.args
+}
+
+// into - returns "into table(col1, ..., colN) values($1, ..., $N)"
+func (qb *QB) into() string {
+	return "into " + qb.table + "(" + strings.Join(qb.cols, ", ") + ") " + NValues(len(qb.cols))
+}
+
+// Insert - returns insert statement and its arguments
+func (qb *QB) Insert() (string, []interface{}) {
+	return "insert " + qb.into(), qb.args
+}
+
+// InsertIgnore - returns insert statement that ignores conflicts and its arguments
+func (qb *QB) InsertIgnore() (string, []interface{}) {
+	return InsertIgnore(qb.into()), qb.args
+}
+
+// Upsert - returns insert statement that updates all non-key columns when row with given key columns already exists
+func (qb *QB) Upsert(keys ...string) (string, []interface{}) {
+	isKey := make(map[string]struct{})
+	for _, key := range keys {
+		isKey[key] = struct{}{}
+	}
+	sets := []string{}
+	for _, col := range qb.cols {
+		if _, ok := isKey[col]; ok {
+			continue
+		}
+		sets = append(sets, col+" = excluded."+col)
+	}
+	q := "insert " + qb.into() + " on conflict(" + strings.Join(keys, ", ") + ")"
+	if len(sets) == 0 {
+		return q + " do nothing", qb.args
+	}
+	return q + " do update set " + strings.Join(sets, ", "), qb.args
+}
+
+// Update - returns update statement setting all non-key columns for rows matching all key columns and its arguments
+// It is fatal when all columns are keys, there is nothing to set then
+func (qb *QB) Update(keys ...string) (string, []interface{}) {
+	isKey := make(map[string]struct{})
+	for _, key := range keys {
+		isKey[key] = struct{}{}
+	}
+	sets, conds := []string{}, []string{}
+	args, condArgs := []interface{}{}, []interface{}{}
+	for i, col := range qb.cols {
+		if _, ok := isKey[col]; ok {
+			conds = append(conds, col)
+			condArgs = append(condArgs, qb.args[i])
+			continue
+		}
+		sets = append(sets, col+" = "+NValue(len(sets)+1))
+		args = append(args, qb.args[i])
+	}
+	if len(sets) == 0 {
+		Fatalf("update %s: all columns (%s) are keys, there are no columns to set", qb.table, strings.Join(qb.cols, ", "))
+	}
+	for i, col := range conds {
+		conds[i] = col + " = " + NValue(len(sets)+i+1)
+	}
+	q := "update " + qb.table + " set " + strings.Join(sets, ", ")
+	if len(conds) > 0 {
+		q += " where " + strings.Join(conds, " and ")
+	}
+	return q, append(args, condArgs...)
+}
@@ -0,0 +1,93 @@
+package devstatscode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestQB(t *testing.T) {
+	// Test cases, expected queries are written the same way as before QB was introduced
+	var testCases = []struct {
+		qb       func() (string, []interface{})
+		expected string
+		args     []interface{}
+	}{
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_events").
+					Set("id", "1").Set("type", "PushEvent").Set("actor_id", 2).Set("repo_id", 3).
+					Set("public", true).Set("created_at", nil).Set("dup_actor_login", "a").
+					Set("dup_repo_name", "o/r").Set("org_id", nil).Set("forkee_id", nil).
+					Insert()
+			},
+			expected: "insert into gha_events(" +
+				"id, type, actor_id, repo_id, public, created_at, " +
+				"dup_actor_login, dup_repo_name, org_id, forkee_id) " + lib.NValues(10),
+			args: []interface{}{"1", "PushEvent", 2, 3, true, nil, "a", "o/r", nil, nil},
+		},
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_labels").Set("id", 1).Set("name", "bug").Set("color", "f00").Set("is_default", nil).InsertIgnore()
+			},
+			expected: lib.InsertIgnore("into gha_labels(id, name, color, is_default) " + lib.NValues(4)),
+			args:     []interface{}{1, "bug", "f00", nil},
+		},
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_last_computed").Set("metric", "m").Set("dt", 1).Set("took", 2).Upsert("metric")
+			},
+			expected: "insert into gha_last_computed(metric, dt, took) values($1, $2, $3) " +
+				"on conflict(metric) do update set dt = excluded.dt, took = excluded.took",
+			args: []interface{}{"m", 1, 2},
+		},
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_parsed").Set("dt", 1).Upsert("dt")
+			},
+			expected: "insert into gha_parsed(dt) values($1) on conflict(dt) do nothing",
+			args:     []interface{}{1},
+		},
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_commits_roles").
+					Set("actor_name", "n").Set("actor_id", 1).Set("actor_email", "e").Set("actor_login", "l").
+					Update("actor_name", "actor_email")
+			},
+			expected: "update gha_commits_roles set actor_id = $1, actor_login = $2 where actor_name = $3 and actor_email = $4",
+			args:     []interface{}{1, "l", "n", "e"},
+		},
+		{
+			qb: func() (string, []interface{}) {
+				return lib.NewQB("gha_actors").Set("login", "l").Update()
+			},
+			expected: "update gha_actors set login = $1",
+			args:     []interface{}{"l"},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		gotQuery, gotArgs := test.qb()
+		if gotQuery != test.expected {
+			t.Errorf("test number %d, expected query:\n%s\ngot:\n%s", index+1, test.expected, gotQuery)
+		}
+		if !reflect.DeepEqual(gotArgs, test.args) {
+			t.Errorf("test number %d, expected args %+v, got %+v", index+1, test.args, gotArgs)
+		}
+	}
+}
+
+func TestQBUpdateWithoutColumnsToSet(t *testing.T) {
+	t.Setenv("NO_FATAL_DELAY", "1")
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !strings.Contains(err.Error(), "no columns to set") {
+			t.Errorf("expected fatal error about no columns to set, got %v", r)
+		}
+	}()
+	q, _ := lib.NewQB("gha_commits_roles").Set("sha", "s").Set("role", "r").Update("sha", "role")
+	t.Errorf("expected Update to fail, got query: %s", q)
+}
@@ -0,0 +1,32 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RecentRepo - repository ID and name as stored in gha_events (the same ID can have different names and vice versa)
+type RecentRepo struct {
+	ID   int64
+	Name string
+}
+
+// UpdateRecentRepos - saves last event dates of given repos, dates older than already saved ones are ignored
+func UpdateRecentRepos(con *sql.DB, ctx *Ctx, repos map[RecentRepo]time.Time) {
+	for repo, dt := range repos {
+		ExecSQLWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"insert into gha_recent_repos(repo_id, repo_name, last_event_at) %s "+
+					"on conflict(repo_id, repo_name) do update set "+
+					"last_event_at = greatest(gha_recent_repos.last_event_at, excluded.last_event_at)",
+				NValues(3),
+			),
+			repo.ID,
+			repo.Name,
+			dt,
+		)
+	}
+}
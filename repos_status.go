@@ -0,0 +1,69 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RepoNotFoundStatus - gha_repos.status of repositories that GitHub API reported as not found GHA2DB_REPO_NOT_FOUND_LIMIT times in a row
+const RepoNotFoundStatus = "not_found"
+
+// RepoNotFound - counts consecutive GitHub API 404s of a given repository, marks it as not found when limit is reached
+func RepoNotFound(c *sql.DB, ctx *Ctx, repo string) {
+	if ctx.SkipPDB || ctx.RepoNotFoundLimit <= 0 {
+		return
+	}
+	res := ExecSQLWithErr(
+		c,
+		ctx,
+		"update gha_repos set not_found = not_found + 1, last_checked = $1, "+
+			"status = case when not_found + 1 >= $2 then $3 else status end where name = $4",
+		time.Now(),
+		ctx.RepoNotFoundLimit,
+		RepoNotFoundStatus,
+		repo,
+	)
+	rows, err := res.RowsAffected()
+	FatalOnError(err)
+	if ctx.Debug > 0 {
+		Printf("Repo %s not found, updated %d gha_repos rows\n", repo, rows)
+	}
+}
+
+// RepoFound - clears not found counter and status of a given repository (only when it was set)
+func RepoFound(c *sql.DB, ctx *Ctx, repo string) {
+	if ctx.SkipPDB || ctx.RepoNotFoundLimit <= 0 {
+		return
+	}
+	ExecSQLWithErr(
+		c,
+		ctx,
+		"update gha_repos set not_found = 0, status = null, last_checked = $1 where name = $2 and (not_found > 0 or status is not null)",
+		time.Now(),
+		repo,
+	)
+}
+
+// TombstonedRepos - returns names of repositories marked as not found
+// Repository with GHA events newer than its last check is not returned, so it will be checked again
+func TombstonedRepos(c *sql.DB, ctx *Ctx) map[string]struct{} {
+	repos := make(map[string]struct{})
+	if ctx.RepoNotFoundLimit <= 0 {
+		return repos
+	}
+	rows := QuerySQLWithErr(
+		c,
+		ctx,
+		"select distinct r.name from gha_repos r where r.status = $1 and not exists("+
+			"select 1 from gha_events e where e.dup_repo_name = r.name and e.created_at > r.last_checked)",
+		RepoNotFoundStatus,
+	)
+	defer func() { FatalOnError(rows.Close()) }()
+	repo := ""
+	for rows.Next() {
+		FatalOnError(rows.Scan(&repo))
+		repos[repo] = struct{}{}
+	}
+	FatalOnError(rows.Err())
+	return repos
+}
@@ -0,0 +1,136 @@
+package devstatscode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// runSummaryMaxErrors - maximum number of error messages saved in run summary, all errors are counted
+const runSummaryMaxErrors = 100
+
+// RunSummaryHTTP - HTTP requests statistics of a single host in run summary
+type RunSummaryHTTP struct {
+	Requests        int     `json:"requests"`
+	Retries         int     `json:"retries"`
+	Errors          int     `json:"errors"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// RunSummary - machine readable summary of a tool run written to GHA2DB_SUMMARY_FILE (for CI and CronJob monitors)
+// It is safe for concurrent use
+type RunSummary struct {
+	mtx             sync.Mutex
+	Tool            string                    `json:"tool"`
+	Args            []string                  `json:"args"`
+	StartedAt       time.Time                 `json:"started_at"`
+	FinishedAt      time.Time                 `json:"finished_at"`
+	DurationSeconds float64                   `json:"duration_seconds"`
+	Success         bool                      `json:"success"`
+	Hours           int                       `json:"hours"`
+	HoursSkipped    int                       `json:"hours_skipped"`
+	Events          int64                     `json:"events"`
+	Matched         int64                     `json:"matched"`
+	Written         int64                     `json:"written"`
+	Skewed          int64                     `json:"skewed"`
+	Duplicates      int64                     `json:"duplicates"`
+	Errors          int                       `json:"errors"`
+	ErrorMessages   []string                  `json:"error_messages"`
+	PeakHeapMB      uint64                    `json:"peak_heap_mb"`
+	SysMB           uint64                    `json:"sys_mb"`
+	HTTP            map[string]RunSummaryHTTP `json:"http"`
+	Truncations     map[string]int            `json:"truncations"`
+}
+
+// NewRunSummary - starts run summary of a given tool called with given args
+func NewRunSummary(tool string, args []string) *RunSummary {
+	return &RunSummary{Tool: tool, Args: args, StartedAt: time.Now(), ErrorMessages: []string{}}
+}
+
+// AddHour - adds counters of a processed GHA hour
+func (rs *RunSummary) AddHour(events, matched, written, skewed, duplicates int) {
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+	rs.Hours++
+	rs.Events += int64(events)
+	rs.Matched += int64(matched)
+	rs.Written += int64(written)
+	rs.Skewed += int64(skewed)
+	rs.Duplicates += int64(duplicates)
+}
+
+// SkipHour - counts GHA hour that was not processed (skip dates config or already parsed)
+func (rs *RunSummary) SkipHour() {
+	rs.mtx.Lock()
+	rs.HoursSkipped++
+	rs.mtx.Unlock()
+}
+
+// AddError - counts an error that didn't stop the run, only first 100 messages are kept
+func (rs *RunSummary) AddError(msg string) {
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+	rs.Errors++
+	if len(rs.ErrorMessages) < runSummaryMaxErrors {
+		rs.ErrorMessages = append(rs.ErrorMessages, msg)
+	}
+}
+
+// SampleMemory - updates peak heap usage, call it after each processed item
+func (rs *RunSummary) SampleMemory() {
+	heap := heapAlloc() >> 20
+	rs.mtx.Lock()
+	if heap > rs.PeakHeapMB {
+		rs.PeakHeapMB = heap
+	}
+	rs.mtx.Unlock()
+}
+
+// Finish - sets run result, duration, memory, HTTP and truncation statistics
+func (rs *RunSummary) Finish(success bool) {
+	rs.SampleMemory()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	rs.mtx.Lock()
+	defer rs.mtx.Unlock()
+	rs.Success = success
+	rs.FinishedAt = time.Now()
+	rs.DurationSeconds = rs.FinishedAt.Sub(rs.StartedAt).Seconds()
+	rs.SysMB = m.Sys >> 20
+	rs.HTTP = make(map[string]RunSummaryHTTP)
+	for host, stats := range HTTPStats() {
+		rs.HTTP[host] = RunSummaryHTTP{Requests: stats.Requests, Retries: stats.Retries, Errors: stats.Errors, DurationSeconds: stats.Duration.Seconds()}
+	}
+	rs.Truncations = TruncationCounts()
+}
+
+// Write - finishes run summary and writes it to GHA2DB_SUMMARY_FILE (if set)
+// File is replaced atomically, so monitors never read a partially written summary
+func (rs *RunSummary) Write(ctx *Ctx, success bool) {
+	if ctx.SummaryFile == "" {
+		return
+	}
+	rs.Finish(success)
+	rs.mtx.Lock()
+	data, err := jsoniter.MarshalIndent(rs, "", "  ")
+	rs.mtx.Unlock()
+	if err != nil {
+		Printf("Cannot marshal run summary: %v\n", err)
+		return
+	}
+	tmp := filepath.Join(filepath.Dir(ctx.SummaryFile), "."+filepath.Base(ctx.SummaryFile)+".tmp")
+	err = ioutil.WriteFile(tmp, append(data, '\n'), 0644)
+	if err == nil {
+		err = os.Rename(tmp, ctx.SummaryFile)
+	}
+	if err != nil {
+		Printf("Cannot write run summary to %s: %v\n", ctx.SummaryFile, err)
+		return
+	}
+	Printf("Run summary written to %s\n", ctx.SummaryFile)
+}
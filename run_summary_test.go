@@ -0,0 +1,89 @@
+package devstatscode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestRunSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "run_summary")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	var ctx lib.Ctx
+	ctx.SummaryFile = filepath.Join(dir, "summary.json")
+
+	rs := lib.NewRunSummary("gha2db", []string{"2021-01-01", "0", "2021-01-01", "23"})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs.AddHour(100, 10, 9, 1, 2)
+			rs.SampleMemory()
+		}()
+	}
+	wg.Wait()
+	rs.SkipHour()
+	for i := 0; i < 150; i++ {
+		rs.AddError("2021-01-01 23: no data yet")
+	}
+	rs.Write(&ctx, true)
+
+	data, err := ioutil.ReadFile(ctx.SummaryFile)
+	if err != nil {
+		t.Fatalf("cannot read summary: %v", err)
+	}
+	var got map[string]interface{}
+	err = jsoniter.Unmarshal(data, &got)
+	if err != nil {
+		t.Fatalf("cannot parse summary: %v", err)
+	}
+	expected := map[string]interface{}{
+		"tool":          "gha2db",
+		"success":       true,
+		"hours":         float64(20),
+		"hours_skipped": float64(1),
+		"events":        float64(2000),
+		"matched":       float64(200),
+		"written":       float64(180),
+		"skewed":        float64(20),
+		"duplicates":    float64(40),
+		"errors":        float64(150),
+	}
+	for key, value := range expected {
+		if got[key] != value {
+			t.Errorf("expected %s %v, got %v", key, value, got[key])
+		}
+	}
+	if msgs, _ := got["error_messages"].([]interface{}); len(msgs) != 100 {
+		t.Errorf("expected 100 error messages, got %d", len(msgs))
+	}
+	if _, ok := got["finished_at"]; !ok {
+		t.Errorf("expected finished_at in summary")
+	}
+	// Only the summary file is left, temporary file is renamed
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot list directory: %v", err)
+	}
+	if len(files) != 1 || strings.HasPrefix(files[0].Name(), ".") {
+		t.Errorf("expected only summary file, got %d files", len(files))
+	}
+
+	// No file when GHA2DB_SUMMARY_FILE is not set
+	ctx.SummaryFile = ""
+	lib.NewRunSummary("gha2db", nil).Write(&ctx, false)
+	files, _ = ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected no new files, got %d files", len(files))
+	}
+}
@@ -0,0 +1,104 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaOptionalTables - tables from SchemaManifest that are only created when their feature is enabled (GHA2DB_MENTIONS, GHA2DB_TRUNC_AUDIT, GHA2DB_COMMIT_MSG_STORE)
+var SchemaOptionalTables = map[string]struct{}{
+	"gha_mentions":         {},
+	"gha_issue_refs":       {},
+	"gha_truncated":        {},
+	"gha_commits_messages": {},
+}
+
+// SchemaColumnsDiff - returns expected columns missing from actual columns and actual columns that are not expected (both sorted)
+func SchemaColumnsDiff(expected, actual []string) (missing, extra []string) {
+	missing, extra = []string{}, []string{}
+	exp := make(map[string]struct{})
+	for _, col := range expected {
+		exp[col] = struct{}{}
+	}
+	act := make(map[string]struct{})
+	for _, col := range actual {
+		act[col] = struct{}{}
+		if _, ok := exp[col]; !ok {
+			extra = append(extra, col)
+		}
+	}
+	for _, col := range expected {
+		if _, ok := act[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return
+}
+
+// SchemaDrift - compares columns of tables from SchemaManifest with a given database, returns differences (empty when schema matches)
+// Missing columns mean database is older than the binary, extra columns mean it is newer
+func SchemaDrift(con *sql.DB, ctx *Ctx) (diffs []string) {
+	diffs = []string{}
+	rows := QuerySQLWithErr(
+		con,
+		ctx,
+		"select table_name, column_name from information_schema.columns "+
+			"where table_schema = 'public' and table_name like 'gha\\_%' order by table_name, ordinal_position",
+	)
+	defer func() { FatalOnError(rows.Close()) }()
+	actual := make(map[string][]string)
+	var table, column string
+	for rows.Next() {
+		FatalOnError(rows.Scan(&table, &column))
+		if _, ok := SchemaManifest[table]; ok {
+			actual[table] = append(actual[table], column)
+		}
+	}
+	FatalOnError(rows.Err())
+	tables := []string{}
+	for table := range SchemaManifest {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		columns, ok := actual[table]
+		if !ok {
+			if _, optional := SchemaOptionalTables[table]; !optional {
+				diffs = append(diffs, fmt.Sprintf("%s: table is missing", table))
+			}
+			continue
+		}
+		missing, extra := SchemaColumnsDiff(SchemaManifest[table], columns)
+		if len(missing) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%s: missing columns: %s", table, strings.Join(missing, ", ")))
+		}
+		if len(extra) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected columns: %s", table, strings.Join(extra, ", ")))
+		}
+	}
+	return
+}
+
+// CheckSchema - fails with a diff when a given database (db is its name) schema differs from SchemaManifest (GHA2DB_SKIP_SCHEMA_CHECK disables)
+// It must be called after tools add their optional columns and tables, so only real drift is reported
+func CheckSchema(con *sql.DB, ctx *Ctx, db string) {
+	if !ctx.SchemaCheck {
+		return
+	}
+	diffs := SchemaDrift(con, ctx)
+	if len(diffs) == 0 {
+		if ctx.Debug > 0 {
+			Printf("Database %s schema matches manifest (%d tables)\n", db, len(SchemaManifest))
+		}
+		return
+	}
+	Fatalf(
+		"database %s schema differs from this binary schema manifest, upgrade the database or the binary (GHA2DB_SKIP_SCHEMA_CHECK=1 skips this check):\n%s",
+		db,
+		strings.Join(diffs, "\n"),
+	)
+}
@@ -0,0 +1,50 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestSchemaColumnsDiff(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		expected []string
+		actual   []string
+		missing  []string
+		extra    []string
+	}{
+		{expected: []string{}, actual: []string{}, missing: []string{}, extra: []string{}},
+		{expected: []string{"id", "name"}, actual: []string{"name", "id"}, missing: []string{}, extra: []string{}},
+		{expected: []string{"id", "name", "project"}, actual: []string{"id", "name"}, missing: []string{"project"}, extra: []string{}},
+		{expected: []string{"id"}, actual: []string{"id", "status", "last_checked"}, missing: []string{}, extra: []string{"last_checked", "status"}},
+		{expected: []string{"id", "b", "a"}, actual: []string{"id", "c"}, missing: []string{"a", "b"}, extra: []string{"c"}},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		missing, extra := lib.SchemaColumnsDiff(test.expected, test.actual)
+		if !reflect.DeepEqual(missing, test.missing) || !reflect.DeepEqual(extra, test.extra) {
+			t.Errorf(
+				"test number %d, expected missing '%v', extra '%v', got '%v', '%v'",
+				index+1, test.missing, test.extra, missing, extra,
+			)
+		}
+	}
+}
+
+func TestSchemaManifest(t *testing.T) {
+	for table := range lib.SchemaOptionalTables {
+		if _, ok := lib.SchemaManifest[table]; !ok {
+			t.Errorf("optional table %s is not in schema manifest", table)
+		}
+	}
+	for table, columns := range lib.SchemaManifest {
+		if len(columns) == 0 {
+			t.Errorf("table %s has no columns in schema manifest", table)
+		}
+		if len(lib.StringsMapToSet(func(s string) string { return s }, columns)) != len(columns) {
+			t.Errorf("table %s has duplicate columns in schema manifest", table)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SeriesVersionsTable - data versions of series tables periods, API uses them to invalidate cached responses exactly when data changes
+const SeriesVersionsTable = "gha_series_versions"
+
+// BumpSeriesVersion - increments data version of a given series table (like 'shdev') period, empty period means the whole table was replaced
+func BumpSeriesVersion(con *sql.DB, ctx *Ctx, table, period string) {
+	ExecSQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"insert into %[1]s(series_table, period, version, updated_at) values(%[2]s, %[3]s, 1, now()) "+
+				"on conflict(series_table, period) do update set version = %[1]s.version + 1, updated_at = excluded.updated_at",
+			SeriesVersionsTable,
+			NValue(1),
+			NValue(2),
+		),
+		table,
+		period,
+	)
+}
+
+// SeriesVersion - returns data version of a given series table period ("table version.period version"), it changes whenever period data is written
+// Returns empty string when versions are not tracked (table doesn't exist), so it can be used by read-only clients (API)
+func SeriesVersion(con *sql.DB, ctx *Ctx, table, period string) (version string, err error) {
+	var exists *string
+	err = QueryRowSQL(con, ctx, "select to_regclass($1)::text", SeriesVersionsTable).Scan(&exists)
+	if err != nil || exists == nil {
+		return
+	}
+	var tableVersion, periodVersion int64
+	err = QueryRowSQL(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select coalesce(sum(version) filter (where period = ''), 0), coalesce(sum(version) filter (where period = %[2]s), 0) "+
+				"from %[3]s where series_table = %[1]s and period in ('', %[2]s)",
+			NValue(1),
+			NValue(2),
+			SeriesVersionsTable,
+		),
+		table,
+		period,
+	).Scan(&tableVersion, &periodVersion)
+	if err != nil {
+		return
+	}
+	version = fmt.Sprintf("%d.%d", tableVersion, periodVersion)
+	return
+}
@@ -0,0 +1,195 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ShardsSchema - schema in the main database holding union views over the main database and all shards
+// It is never put on the database search_path, unqualified table names always mean main database tables
+const ShardsSchema = "shards"
+
+// ShardedTables - tables written by gha2db that are split between shard databases
+// All of them are keyed by event, so every row lives in exactly one shard
+var ShardedTables = []string{
+	"gha_events", "gha_payloads", "gha_commits", "gha_commits_roles", "gha_pages", "gha_comments",
+	"gha_issues", "gha_issues_assignees", "gha_issues_labels", "gha_milestones", "gha_forkees", "gha_branches",
+	"gha_pull_requests", "gha_pull_requests_assignees", "gha_pull_requests_requested_reviewers",
+	"gha_reviews", "gha_releases", "gha_releases_assets", "gha_assets", "gha_teams", "gha_teams_repositories",
+}
+
+// ShardedDimensionTables - tables written by gha2db that can contain the same rows in many shards
+// (the same label can be used in orgs from different shards), union views remove duplicates
+// Actors and affiliations tables are not sharded: gha2db replicates actors to the main database
+// and import_affs writes affiliations there, so they are always complete in the main database
+var ShardedDimensionTables = []string{
+	"gha_orgs", "gha_repos", "gha_labels",
+}
+
+// shardsTablesRe - matches unqualified references to sharded tables (not preceded by schema, quote or identifier character)
+var shardsTablesRe = regexp.MustCompile(
+	`(^|[^\w."'])(` + strings.Join(append(append([]string{}, ShardedTables...), ShardedDimensionTables...), "|") + `)\b`,
+)
+
+// ShardIndex - returns shard number (0..n-1) for a given org, org name is case insensitive
+func ShardIndex(org string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.ToLower(org)))
+	return int(h.Sum32() % uint32(n))
+}
+
+// ShardForRepo - returns shard database name for a given "org/repo" name
+// Returns main database when sharding is not configured
+func ShardForRepo(ctx *Ctx, repoName string) string {
+	if len(ctx.Shards) == 0 {
+		return ctx.PgDB
+	}
+	org := repoName
+	if i := strings.Index(repoName, "/"); i >= 0 {
+		org = repoName[:i]
+	}
+	return ctx.Shards[ShardIndex(org, len(ctx.Shards))]
+}
+
+// ShardsConns - connects to all shard databases, returns shard name -> connection map
+func ShardsConns(ctx *Ctx) map[string]*sql.DB {
+	conns := make(map[string]*sql.DB)
+	for _, shard := range ctx.Shards {
+		conns[shard] = PgConnDB(ctx, shard)
+	}
+	return conns
+}
+
+// CloseShardsConns - closes connections returned by ShardsConns
+func CloseShardsConns(conns map[string]*sql.DB) {
+	for _, con := range conns {
+		FatalOnError(con.Close())
+	}
+}
+
+// shardSchema - schema name with foreign tables imported from a given shard
+func shardSchema(shard string) string {
+	return "shard_" + makePsqlName(shard, true)
+}
+
+// ShardsSQL - makes a read-only query read sharded tables from union views in "shards" schema
+// Returns query unchanged when sharding is not configured, must not be used for queries that write
+func ShardsSQL(ctx *Ctx, query string) string {
+	if len(ctx.Shards) == 0 {
+		return query
+	}
+	return shardsTablesRe.ReplaceAllString(query, "${1}"+ShardsSchema+".${2}")
+}
+
+// PgConnShardsReadErr - connects to the main database of a sharded project for reading only
+// Sessions have "shards, public" search_path, so unqualified sharded tables resolve to union views
+// Must only be used with read-only users (like API's PG_USER_RO), writes to union views fail
+func PgConnShardsReadErr(ctx *Ctx) (*sql.DB, error) {
+	connectionString := "client_encoding=UTF8 sslmode='" + ctx.PgSSL + "' host='" + ctx.PgHost + "' port=" + ctx.PgPort + " dbname='" + ctx.PgDB + "' user='" + ctx.PgUser + "' password='" + ctx.PgPass + "' search_path='" + ShardsSchema + ",public'"
+	if ctx.QOut {
+		// Use fmt.Printf (not lib.Printf that logs to DB) here
+		// Avoid trying to log something to DB while connecting
+		fmt.Printf("PgConnectString: %s\n", connectionString)
+	}
+	return sql.Open("postgres", connectionString)
+}
+
+// resetShardsSearchPath - resets database search_path only when it is "shards, public" (set by the first sharding version)
+// Union views on the search_path broke unqualified writes, search_path set by admins for other reasons is kept
+func resetShardsSearchPath(con *sql.DB, ctx *Ctx) {
+	n := 0
+	FatalOnError(
+		QueryRowSQL(
+			con,
+			ctx,
+			"select count(*) from pg_db_role_setting s join pg_database d on d.oid = s.setdatabase "+
+				"where d.datname = $1 and s.setrole = 0 and $2 = any(s.setconfig)",
+			ctx.PgDB,
+			"search_path="+ShardsSchema+", public",
+		).Scan(&n),
+	)
+	if n > 0 {
+		ExecSQLWithErr(con, ctx, fmt.Sprintf("alter database \"%s\" reset search_path", escapeName(ctx.PgDB)))
+	}
+}
+
+// ShardsUnion - creates read layer over the main database and all shards in the main database (ctx.PgDB)
+// Every shard's tables are imported via postgres_fdw into "shard_{{db}}" schema
+// and "shards" schema gets union views named like original tables, they also include main database's own rows
+// (written by tools that are not shard aware, like ghapi2db artificial events)
+// Database search_path is not changed: writers keep using main database tables, readers use ShardsSQL or PgConnShardsReadErr
+// User mapping stores PG_USER and PG_PASS in the main database catalog (pg_user_mappings, readable by superusers
+// and the mapping owner), union views only need select privileges, so prefer a least-privilege PG_USER when running structure
+func ShardsUnion(ctx *Ctx) {
+	if len(ctx.Shards) == 0 {
+		return
+	}
+	con := PgConn(ctx)
+	defer func() { FatalOnError(con.Close()) }()
+	ExecSQLWithErr(con, ctx, "create extension if not exists postgres_fdw")
+	ExecSQLWithErr(con, ctx, "create schema if not exists "+ShardsSchema)
+	tables := append([]string{}, ShardedTables...)
+	tables = append(tables, ShardedDimensionTables...)
+	for _, shard := range ctx.Shards {
+		schema := shardSchema(shard)
+		ExecSQLWithErr(con, ctx, "drop server if exists "+schema+" cascade")
+		ExecSQLWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"create server %s foreign data wrapper postgres_fdw options (host %s, port %s, dbname %s)",
+				schema, pq.QuoteLiteral(ctx.PgHost), pq.QuoteLiteral(ctx.PgPort), pq.QuoteLiteral(shard),
+			),
+		)
+		ExecSQLWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"create user mapping for current_user server %s options (user %s, password %s)",
+				schema, pq.QuoteLiteral(ctx.PgUser), pq.QuoteLiteral(ctx.PgPass),
+			),
+		)
+		ExecSQLWithErr(con, ctx, "drop schema if exists "+schema+" cascade")
+		ExecSQLWithErr(con, ctx, "create schema "+schema)
+		ExecSQLWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"import foreign schema public limit to (%s) from server %s into %s",
+				strings.Join(tables, ", "), schema, schema,
+			),
+		)
+	}
+	createView := func(table, union string) {
+		selects := []string{"select * from public." + table}
+		for _, shard := range ctx.Shards {
+			selects = append(selects, "select * from "+shardSchema(shard)+"."+table)
+		}
+		ExecSQLWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"create or replace view %s.%s as %s",
+				ShardsSchema, table, strings.Join(selects, " "+union+" "),
+			),
+		)
+	}
+	for _, table := range ShardedTables {
+		createView(table, "union all")
+	}
+	for _, table := range ShardedDimensionTables {
+		createView(table, "union")
+	}
+	resetShardsSearchPath(con, ctx)
+	if ctx.Debug > 0 {
+		Printf("Created %d shards union views in %s\n", len(tables), ctx.PgDB)
+	}
+}
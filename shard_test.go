@@ -0,0 +1,75 @@
+package devstatscode
+
+import (
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestShardForRepo(t *testing.T) {
+	var ctx lib.Ctx
+	ctx.PgDB = "gha"
+
+	// No sharding - always main database
+	if got := lib.ShardForRepo(&ctx, "kubernetes/kubernetes"); got != "gha" {
+		t.Errorf("expected main database 'gha', got '%s'", got)
+	}
+
+	// All repos from the same org must go to the same shard, org is case insensitive
+	ctx.Shards = []string{"gha_shard0", "gha_shard1", "gha_shard2"}
+	shard := lib.ShardForRepo(&ctx, "kubernetes/kubernetes")
+	for _, repo := range []string{"kubernetes/test-infra", "Kubernetes/website", "kubernetes"} {
+		got := lib.ShardForRepo(&ctx, repo)
+		if got != shard {
+			t.Errorf("expected repo '%s' in shard '%s', got '%s'", repo, shard, got)
+		}
+	}
+
+	// Orgs should be spread between shards
+	used := make(map[string]struct{})
+	for _, org := range []string{"kubernetes", "prometheus", "envoyproxy", "cncf", "helm", "etcd-io", "containerd", "istio"} {
+		used[lib.ShardForRepo(&ctx, org+"/repo")] = struct{}{}
+	}
+	if len(used) < 2 {
+		t.Errorf("expected orgs to be spread between shards, got %+v", used)
+	}
+
+	// Shard index is always in range
+	for n := 1; n < 10; n++ {
+		idx := lib.ShardIndex("kubernetes", n)
+		if idx < 0 || idx >= n {
+			t.Errorf("shard index %d out of range for %d shards", idx, n)
+		}
+	}
+}
+
+func TestShardsSQL(t *testing.T) {
+	var ctx lib.Ctx
+	query := "select e.id from gha_events e join gha_actors a on a.id = e.actor_id where e.repo_id in (select id from gha_repos)"
+	if got := lib.ShardsSQL(&ctx, query); got != query {
+		t.Errorf("expected query unchanged without sharding, got %s", got)
+	}
+	ctx.Shards = []string{"gha_shard0", "gha_shard1"}
+	// Test cases
+	var testCases = []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    query,
+			expected: "select e.id from shards.gha_events e join gha_actors a on a.id = e.actor_id where e.repo_id in (select id from shards.gha_repos)",
+		},
+		{query: "gha_events_commits_files", expected: "gha_events_commits_files"},
+		{query: "select count(*) from public.gha_events", expected: "select count(*) from public.gha_events"},
+		{query: "select 'gha_events', \"gha_issues\"", expected: "select 'gha_events', \"gha_issues\""},
+		{query: "select * from gha_issues_labels il,gha_labels l", expected: "select * from shards.gha_issues_labels il,shards.gha_labels l"},
+		{query: "select 1 from gha_actors_affiliations", expected: "select 1 from gha_actors_affiliations"},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.ShardsSQL(&ctx, test.query)
+		if got != test.expected {
+			t.Errorf("test number %d, expected '%s', got '%s'", index+1, test.expected, got)
+		}
+	}
+}
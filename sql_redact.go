@@ -0,0 +1,156 @@
+package devstatscode
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQLRedactDefault - column name words redacted in SQL logs when GHA2DB_SQL_REDACT=1
+var SQLRedactDefault = []string{"email", "name", "login"}
+
+// SQLRedacted - logged instead of a redacted SQL argument
+const SQLRedacted = "<redacted>"
+
+var (
+	// sqlInsertRe - "insert into table(col1, ..., colN) values(expr1, ..., exprN)[, (expr1, ..., exprN), ...]"
+	sqlInsertRe = regexp.MustCompile(`(?is)\binsert\s+into\s+[\w."]+\s*\(([^)]*)\)\s*values\s*(\([^)]*\)(?:\s*,\s*\([^)]*\))*)`)
+	// sqlTupleRe - single "(expr1, ..., exprN)" tuple of insert values
+	sqlTupleRe = regexp.MustCompile(`\(([^)]*)\)`)
+	// sqlCompareRe - "column <op> $N", column and placeholder can be wrapped in lower()
+	sqlCompareRe = regexp.MustCompile(`(?i)(?:lower\(\s*)?([\w."]+)\s*\)?\s*(?:=|<>|!=|>=|<=|>|<|\bi?like\b|\bin\b|=\s*any)\s*\(?\s*(?:lower\(\s*)?\$(\d+)\b`)
+	// sqlLiteralCompareRe - "column <op> 'literal'", column and literal can be wrapped in lower()
+	sqlLiteralCompareRe = regexp.MustCompile(`(?i)(?:lower\(\s*)?([\w."]+)\s*\)?\s*(?:=|<>|!=|\bi?like\b)\s*(?:lower\(\s*)?'(?:[^']|'')*'`)
+	// sqlPlaceholderRe - value expression that is just a placeholder
+	sqlPlaceholderRe = regexp.MustCompile(`^\s*\$(\d+)\s*$`)
+	// sqlLiteralRe - value expression that is just a string literal
+	sqlLiteralRe = regexp.MustCompile(`^\s*'(?:[^']|'')*'\s*$`)
+	// sqlEmailRe - values looking like emails are always redacted
+	sqlEmailRe = regexp.MustCompile(`[^@\s<>]+@[^@\s<>]+\.[a-zA-Z]{2,}`)
+)
+
+// sqlColumnName - returns lower case column name without table/schema prefix and quotes
+func sqlColumnName(column string) string {
+	column = strings.ToLower(strings.Trim(strings.TrimSpace(column), `"`))
+	if dot := strings.LastIndex(column, "."); dot >= 0 {
+		column = strings.Trim(column[dot+1:], `"`)
+	}
+	return column
+}
+
+// SQLArgsColumns - returns column names that SQL placeholders ($N, by N) are bound to
+// Placeholders are mapped from insert columns lists (all values tuples) and from "column <op> $N" conditions, other placeholders are not mapped
+func SQLArgsColumns(query string) map[int]string {
+	columns := make(map[int]string)
+	add := func(n, column string) {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return
+		}
+		columns[i] = sqlColumnName(column)
+	}
+	for _, m := range sqlInsertRe.FindAllStringSubmatch(query, -1) {
+		cols := strings.Split(m[1], ",")
+		for _, tuple := range sqlTupleRe.FindAllStringSubmatch(m[2], -1) {
+			values := strings.Split(tuple[1], ",")
+			if len(cols) != len(values) {
+				continue
+			}
+			for i, value := range values {
+				if p := sqlPlaceholderRe.FindStringSubmatch(value); p != nil {
+					add(p[1], cols[i])
+				}
+			}
+		}
+	}
+	for _, m := range sqlCompareRe.FindAllStringSubmatch(query, -1) {
+		add(m[2], m[1])
+	}
+	return columns
+}
+
+// SQLColumnSensitive - checks if a given column name contains a word (parts separated by "_") from a given list
+func SQLColumnSensitive(column string, words []string) bool {
+	for _, part := range strings.Split(column, "_") {
+		for _, word := range words {
+			if part == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactSQLArgs - returns SQL arguments with values of sensitive columns (GHA2DB_SQL_REDACT) and email-like values replaced by SQLRedacted
+// Arguments are returned unchanged when redaction is disabled, it is only used for logging
+func RedactSQLArgs(ctx *Ctx, query string, args []interface{}) []interface{} {
+	if len(ctx.SQLRedact) == 0 || len(args) == 0 {
+		return args
+	}
+	columns := SQLArgsColumns(query)
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		redacted[i] = arg
+		if arg == nil {
+			continue
+		}
+		if column, ok := columns[i+1]; ok && SQLColumnSensitive(column, ctx.SQLRedact) {
+			redacted[i] = SQLRedacted
+			continue
+		}
+		switch v := arg.(type) {
+		case string:
+			if sqlEmailRe.MatchString(v) {
+				redacted[i] = SQLRedacted
+			}
+		case *string:
+			if v != nil && sqlEmailRe.MatchString(*v) {
+				redacted[i] = SQLRedacted
+			}
+		}
+	}
+	return redacted
+}
+
+// RedactSQLQuery - returns SQL query text with string literals of sensitive columns (GHA2DB_SQL_REDACT) and email-like text replaced by SQLRedacted
+// Many queries have values interpolated instead of bound, so query text is redacted like arguments, it is only used for logging
+func RedactSQLQuery(ctx *Ctx, query string) string {
+	if len(ctx.SQLRedact) == 0 {
+		return query
+	}
+	literal := "'" + SQLRedacted + "'"
+	query = sqlLiteralCompareRe.ReplaceAllStringFunc(
+		query,
+		func(m string) string {
+			if !SQLColumnSensitive(sqlColumnName(sqlLiteralCompareRe.FindStringSubmatch(m)[1]), ctx.SQLRedact) {
+				return m
+			}
+			// Column name cannot contain quote, so the first one starts the literal
+			return m[:strings.Index(m, "'")] + literal
+		},
+	)
+	query = sqlInsertRe.ReplaceAllStringFunc(
+		query,
+		func(m string) string {
+			idx := sqlInsertRe.FindStringSubmatchIndex(m)
+			cols := strings.Split(m[idx[2]:idx[3]], ",")
+			values := sqlTupleRe.ReplaceAllStringFunc(
+				m[idx[4]:idx[5]],
+				func(tuple string) string {
+					exprs := strings.Split(tuple[1:len(tuple)-1], ",")
+					if len(exprs) != len(cols) {
+						return tuple
+					}
+					for i, expr := range exprs {
+						if sqlLiteralRe.MatchString(expr) && SQLColumnSensitive(sqlColumnName(cols[i]), ctx.SQLRedact) {
+							exprs[i] = strings.Replace(expr, strings.TrimSpace(expr), literal, 1)
+						}
+					}
+					return "(" + strings.Join(exprs, ",") + ")"
+				},
+			)
+			return m[:idx[4]] + values + m[idx[5]:]
+		},
+	)
+	return sqlEmailRe.ReplaceAllString(query, SQLRedacted)
+}
@@ -0,0 +1,126 @@
+package devstatscode
+
+import (
+	"reflect"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestSQLArgsColumns(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		query    string
+		expected map[int]string
+	}{
+		{
+			query:    "insert into gha_actors_emails(actor_id, email, origin) values($1, $2, 0) on conflict do nothing",
+			expected: map[int]string{1: "actor_id", 2: "email"},
+		},
+		{
+			query:    "insert into gha_texts(event_id, body, actor_login) values($1, $2, $3) on conflict(event_id) do update set body = excluded.body",
+			expected: map[int]string{1: "event_id", 2: "body", 3: "actor_login"},
+		},
+		{
+			query:    "select id from gha_actors where lower(login) = lower($1) and a.name <> $2 and dt >= $3",
+			expected: map[int]string{1: "login", 2: "name", 3: "dt"},
+		},
+		{
+			query:    `update gha_commits set "author_email" = $1 where sha = $2 and dup_repo_name in ($3)`,
+			expected: map[int]string{1: "author_email", 2: "sha", 3: "dup_repo_name"},
+		},
+		{
+			query:    "select count(*) from gha_events where id > 0 limit $1",
+			expected: map[int]string{},
+		},
+		{
+			query:    "insert into gha_actors_emails(actor_id, email) values($1, $2), ($3, $4),($5, $6) on conflict do nothing",
+			expected: map[int]string{1: "actor_id", 2: "email", 3: "actor_id", 4: "email", 5: "actor_id", 6: "email"},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		got := lib.SQLArgsColumns(test.query)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %+v, got %+v", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestRedactSQLArgs(t *testing.T) {
+	email := "john@example.com"
+	query := "insert into gha_commits(sha, author_name, author_email, message, dup_repo_name) values($1, $2, $3, $4, $5)"
+	args := []interface{}{"abc", "John Doe", "john@example.com", "Fix bug", "org/repo"}
+	// Test cases
+	var testCases = []struct {
+		redact   []string
+		query    string
+		args     []interface{}
+		expected []interface{}
+	}{
+		{query: query, args: args, expected: args},
+		{
+			redact:   []string{"email", "name", "login"},
+			query:    query,
+			args:     args,
+			expected: []interface{}{"abc", lib.SQLRedacted, lib.SQLRedacted, "Fix bug", lib.SQLRedacted},
+		},
+		{
+			redact:   []string{"email"},
+			query:    query,
+			args:     args,
+			expected: []interface{}{"abc", "John Doe", lib.SQLRedacted, "Fix bug", "org/repo"},
+		},
+		{
+			redact:   []string{"login"},
+			query:    "select 1 from t where body like $1 or x = $2 or y = $3",
+			args:     []interface{}{"%Signed-off-by: John <john@example.com>%", &email, nil},
+			expected: []interface{}{lib.SQLRedacted, lib.SQLRedacted, nil},
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ctx := lib.Ctx{SQLRedact: test.redact}
+		got := lib.RedactSQLArgs(&ctx, test.query, test.args)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("test number %d, expected %+v, got %+v", index+1, test.expected, got)
+		}
+	}
+}
+
+func TestRedactSQLQuery(t *testing.T) {
+	// Test cases
+	var testCases = []struct {
+		redact   []string
+		query    string
+		expected string
+	}{
+		{
+			query:    "select id from gha_actors where login = 'john' and email = 'john@example.com'",
+			expected: "select id from gha_actors where login = 'john' and email = 'john@example.com'",
+		},
+		{
+			redact:   []string{"email", "name", "login"},
+			query:    "select id from gha_actors where lower(login) = lower('John') and a.name <> 'John Doe' and dt >= '2021-01-01'",
+			expected: "select id from gha_actors where lower(login) = lower('<redacted>') and a.name <> '<redacted>' and dt >= '2021-01-01'",
+		},
+		{
+			redact:   []string{"login"},
+			query:    "insert into gha_actors(id, login, name) values(1, 'john', 'John'), (2, 'jane', 'Jane'),(3, $1, 'X')",
+			expected: "insert into gha_actors(id, login, name) values(1, '<redacted>', 'John'), (2, '<redacted>', 'Jane'),(3, $1, 'X')",
+		},
+		{
+			redact:   []string{"login"},
+			query:    "update gha_texts set body = 'Signed-off-by: John <john@example.com>' where event_id = 1",
+			expected: "update gha_texts set body = 'Signed-off-by: John <<redacted>>' where event_id = 1",
+		},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		ctx := lib.Ctx{SQLRedact: test.redact}
+		got := lib.RedactSQLQuery(&ctx, test.query)
+		if got != test.expected {
+			t.Errorf("test number %d, expected %s, got %s", index+1, test.expected, got)
+		}
+	}
+}
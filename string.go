@@ -83,6 +83,62 @@ func Slugify(arg string) string {
 	return strings.ToLower(arg)
 }
 
+// HiddenColumn - table column that can contain GDPR hidden values ("anon-" + SHA1 of the original value)
+type HiddenColumn struct {
+	Table  string
+	Column string
+}
+
+// HiddenColumns - all columns updated when hiding (or un-hiding) data
+var HiddenColumns = []HiddenColumn{
+	{Table: "gha_actors", Column: "login"},
+	{Table: "gha_actors", Column: "name"},
+	{Table: "gha_actors_emails", Column: "email"},
+	{Table: "gha_actors_names", Column: "name"},
+	{Table: "gha_actors_affiliations", Column: "company_name"},
+	{Table: "gha_actors_affiliations", Column: "original_company_name"},
+	{Table: "gha_companies", Column: "name"},
+	{Table: "gha_events", Column: "dup_actor_login"},
+	{Table: "gha_payloads", Column: "dup_actor_login"},
+	{Table: "gha_commits", Column: "dup_actor_login"},
+	{Table: "gha_commits", Column: "dup_author_login"},
+	{Table: "gha_commits", Column: "dup_committer_login"},
+	{Table: "gha_commits", Column: "author_name"},
+	{Table: "gha_commits", Column: "author_email"},
+	{Table: "gha_commits", Column: "committer_name"},
+	{Table: "gha_commits", Column: "committer_email"},
+	{Table: "gha_commits_roles", Column: "actor_login"},
+	{Table: "gha_commits_roles", Column: "actor_name"},
+	{Table: "gha_commits_roles", Column: "actor_email"},
+	{Table: "gha_commits_authors", Column: "actor_login"},
+	{Table: "gha_commits_authors", Column: "actor_name"},
+	{Table: "gha_commits_authors", Column: "actor_email"},
+	{Table: "gha_pages", Column: "dup_actor_login"},
+	{Table: "gha_comments", Column: "dup_actor_login"},
+	{Table: "gha_comments", Column: "dup_user_login"},
+	{Table: "gha_reviews", Column: "dup_actor_login"},
+	{Table: "gha_reviews", Column: "dup_user_login"},
+	{Table: "gha_issues", Column: "dup_actor_login"},
+	{Table: "gha_issues", Column: "dup_user_login"},
+	{Table: "gha_milestones", Column: "dup_actor_login"},
+	{Table: "gha_milestones", Column: "dupn_creator_login"},
+	{Table: "gha_issues_labels", Column: "dup_actor_login"},
+	{Table: "gha_forkees", Column: "dup_actor_login"},
+	{Table: "gha_forkees", Column: "dup_owner_login"},
+	{Table: "gha_releases", Column: "dup_actor_login"},
+	{Table: "gha_releases", Column: "dup_author_login"},
+	{Table: "gha_assets", Column: "dup_actor_login"},
+	{Table: "gha_assets", Column: "dup_uploader_login"},
+	{Table: "gha_pull_requests", Column: "dup_actor_login"},
+	{Table: "gha_pull_requests", Column: "dup_user_login"},
+	{Table: "gha_branches", Column: "dupn_forkee_name"},
+	{Table: "gha_branches", Column: "dupn_user_login"},
+	{Table: "gha_teams", Column: "dup_actor_login"},
+	{Table: "gha_texts", Column: "actor_login"},
+	{Table: "gha_issues_events_labels", Column: "actor_login"},
+	{Table: "gha_issue_label_history", Column: "actor_login"},
+}
+
 // GetHidden - return list of shas to replace
 func GetHidden(ctx *Ctx, configFile string) (shaMap map[string]string) {
 	shaMap = make(map[string]string)
@@ -110,6 +166,28 @@ func GetHidden(ctx *Ctx, configFile string) (shaMap map[string]string) {
 	return
 }
 
+// SaveHidden - saves list of shas to replace (keys of shaMap) in configFile
+func SaveHidden(configFile string, shaMap map[string]string) (err error) {
+	oFile, err := os.Create(configFile)
+	if err != nil {
+		return
+	}
+	defer func() { _ = oFile.Close() }()
+	writer := csv.NewWriter(oFile)
+	err = writer.Write([]string{"sha1"})
+	if err != nil {
+		return
+	}
+	for sha := range shaMap {
+		err = writer.Write([]string{sha})
+		if err != nil {
+			return
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
 // MaybeHideFunc - use closure as a data storage
 func MaybeHideFunc(shas map[string]string) (f func(string) string) {
 	cache := make(map[string]string)
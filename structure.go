@@ -1,15 +1,689 @@
 package devstatscode
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// integrityConstraints - foreign keys and check constraints created only in integrity mode (GHA2DB_FK_CHECKS): table, name, definition
+// Foreign keys are deferred (checked on commit), so they catch events data written in separate transactions in a wrong order
+// gha_actors and gha_repos have no unique id (primary keys are id+login and id+name), so they cannot be referenced
+var integrityConstraints = [][3]string{
+	{"gha_events", "gha_events_org_fk", "foreign key(org_id) references gha_orgs(id) deferrable initially deferred"},
+	{"gha_events", "gha_events_type_check", "check(type <> '')"},
+	{"gha_payloads", "gha_payloads_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_commits", "gha_commits_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_commits_authors", "gha_commits_authors_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_commits_roles", "gha_commits_roles_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_pages", "gha_pages_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_comments", "gha_comments_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_issues", "gha_issues_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_issues", "gha_issues_number_check", "check(number > 0)"},
+	{"gha_issues_assignees", "gha_issues_assignees_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_issues_labels", "gha_issues_labels_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_issues_labels", "gha_issues_labels_label_fk", "foreign key(label_id) references gha_labels(id) deferrable initially deferred"},
+	{"gha_milestones", "gha_milestones_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_forkees", "gha_forkees_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_releases", "gha_releases_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_releases_assets", "gha_releases_assets_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_assets", "gha_assets_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_pull_requests", "gha_pull_requests_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_pull_requests_assignees", "gha_pull_requests_assignees_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_pull_requests_requested_reviewers", "gha_pull_requests_requested_reviewers_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_branches", "gha_branches_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_teams", "gha_teams_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_teams_repositories", "gha_teams_repositories_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+	{"gha_reviews", "gha_reviews_event_fk", "foreign key(event_id) references gha_events(id) deferrable initially deferred"},
+}
+
+// dropIntegrityConstraints - drops integrity mode constraints (if any), tables referenced by them can be dropped then
+func dropIntegrityConstraints(c *sql.DB, ctx *Ctx) {
+	for _, constraint := range integrityConstraints {
+		ExecSQLWithErr(c, ctx, fmt.Sprintf("alter table if exists %s drop constraint if exists %s", constraint[0], constraint[1]))
+	}
+}
+
+// addedTable - table added to structure after databases using it were created, Migrate creates it in such databases
+// Columns are "name type" definitions, columns added to an existing table are appended, so Migrate can add them too
+// Indices are "name on table(columns)" definitions, fill (if any) populates a table created by Migrate from existing data
+type addedTable struct {
+	name    string
+	columns []string
+	key     string
+	indices []string
+	fill    string
+}
+
+// addedColumns - columns added to tables of the initial structure: table, "name type" definition
+var addedColumns = [][2]string{
+	{"gha_events", "project varchar(100) not null default ''"},
+	{"gha_repos", "status varchar(20)"},
+	{"gha_repos", "not_found int not null default 0"},
+	{"gha_repos", "last_checked {{ts}}"},
+	{"gha_parsed", "status varchar(20) not null default 'ok'"},
+	{"gha_parsed", "events_found int not null default 0"},
+	{"gha_parsed", "events_written int not null default 0"},
+	{"gha_parsed", "last_run_at {{ts}}"},
+}
+
+// addedIndices - indices added to tables of the initial structure: table, "name on table(columns)" definition
+var addedIndices = [][2]string{
+	{"gha_parsed", "parsed_status_idx on gha_parsed(status)"},
+}
+
+// addedTables - tables added to structure, see addedTable
+var addedTables = []addedTable{
+	{
+		name: "gha_commits_authors",
+		columns: []string{
+			"sha varchar(40) not null",
+			"event_id bigint not null",
+			"ord int not null",
+			"source varchar(10) not null",
+			"actor_id bigint",
+			"actor_login varchar(120) not null default ''",
+			"actor_name varchar(160) not null default ''",
+			"actor_email varchar(160) not null default ''",
+			"dup_repo_id bigint not null",
+			"dup_repo_name varchar(160) not null",
+			"dup_created_at {{ts}} not null",
+		},
+		key: "sha, event_id, ord",
+		indices: []string{
+			"commits_authors_event_id_idx on gha_commits_authors(event_id)",
+			"commits_authors_actor_id_idx on gha_commits_authors(actor_id)",
+			"commits_authors_actor_login_idx on gha_commits_authors(actor_login)",
+			"commits_authors_actor_email_idx on gha_commits_authors(actor_email)",
+			"commits_authors_dup_repo_name_idx on gha_commits_authors(dup_repo_name)",
+			"commits_authors_dup_created_at_idx on gha_commits_authors(dup_created_at)",
+		},
+	},
+	{
+		name: "gha_issue_label_history",
+		columns: []string{
+			"event_id bigint not null",
+			"issue_id bigint not null",
+			"label_name varchar(160) not null",
+			"action varchar(10) not null",
+			"dt {{ts}} not null",
+			"actor_id bigint",
+			"actor_login varchar(120) not null default ''",
+			"repo_name varchar(160) not null",
+			"issue_number int not null",
+			"is_pull_request boolean not null",
+		},
+		key: "event_id",
+		indices: []string{
+			"issue_label_history_issue_id_idx on gha_issue_label_history(issue_id)",
+			"issue_label_history_label_name_idx on gha_issue_label_history(label_name)",
+			"issue_label_history_dt_idx on gha_issue_label_history(dt)",
+			"issue_label_history_actor_login_idx on gha_issue_label_history(actor_login)",
+			"issue_label_history_repo_name_idx on gha_issue_label_history(repo_name)",
+		},
+	},
+	{
+		name: "gha_issue_transfers",
+		columns: []string{
+			"event_id bigint not null",
+			"issue_id bigint not null",
+			"from_repo_id bigint",
+			"from_repo_name varchar(160)",
+			"from_number int",
+			"to_repo_id bigint not null",
+			"to_repo_name varchar(160) not null",
+			"to_number int not null",
+			"is_pull_request boolean not null",
+			"dt {{ts}} not null",
+			"actor_id bigint",
+			"actor_login varchar(120) not null default ''",
+		},
+		key: "event_id",
+		indices: []string{
+			"issue_transfers_issue_id_idx on gha_issue_transfers(issue_id)",
+			"issue_transfers_from_repo_name_idx on gha_issue_transfers(from_repo_name)",
+			"issue_transfers_to_repo_name_idx on gha_issue_transfers(to_repo_name)",
+			"issue_transfers_dt_idx on gha_issue_transfers(dt)",
+		},
+	},
+	{
+		name: "gha_issues_canonical",
+		columns: []string{
+			"issue_id bigint not null",
+			"repo_id bigint not null",
+			"repo_name varchar(160) not null",
+			"number int not null",
+			"transferred_at {{ts}} not null",
+		},
+		key: "issue_id",
+		indices: []string{
+			"issues_canonical_repo_name_idx on gha_issues_canonical(repo_name)",
+		},
+	},
+	{
+		name: "gha_pr_issues",
+		columns: []string{
+			"pr_issue_id bigint not null",
+			"pr_repo_name varchar(160) not null",
+			"pr_number int not null",
+			"issue_repo_name varchar(160) not null",
+			"issue_number int not null",
+			"issue_id bigint",
+			"source varchar(20) not null",
+			"dt {{ts}} not null",
+		},
+		key: "pr_issue_id, issue_repo_name, issue_number",
+		indices: []string{
+			"pr_issues_pr_repo_name_idx on gha_pr_issues(pr_repo_name)",
+			"pr_issues_issue_idx on gha_pr_issues(issue_repo_name, issue_number)",
+			"pr_issues_issue_id_idx on gha_pr_issues(issue_id)",
+			"pr_issues_dt_idx on gha_pr_issues(dt)",
+		},
+	},
+	{
+		name: "gha_skipped_events",
+		columns: []string{
+			"dt {{ts}} not null",
+			"type varchar(40) not null",
+			"events int not null",
+			"unknown boolean not null",
+		},
+		key: "dt, type",
+		indices: []string{
+			"skipped_events_type_idx on gha_skipped_events(type)",
+		},
+	},
+	{
+		name: "gha_commits_gaps",
+		columns: []string{
+			"sha varchar(40) not null",
+			"dup_created_at {{ts}} not null",
+			"dup_repo_name varchar(160) not null",
+			"attempts int not null default 0",
+			"last_attempt_at {{ts}} not null",
+		},
+		key: "sha, dup_created_at",
+	},
+	{
+		name: "gha_manual_periods",
+		columns: []string{
+			"series text not null",
+			"metric text not null",
+			"period text not null",
+			"dt_from {{ts}} not null",
+			"dt_to {{ts}} not null",
+			"computed_at {{ts}} not null",
+		},
+		key: "series, metric, period",
+	},
+	{
+		name: "gha_series_versions",
+		columns: []string{
+			"series_table text not null",
+			"period text not null",
+			"version bigint not null",
+			"updated_at {{ts}} not null",
+		},
+		key: "series_table, period",
+	},
+	{
+		name: "gha_data_quality",
+		columns: []string{
+			"time {{ts}} not null",
+			"indicator varchar(60) not null",
+			"value double precision not null",
+		},
+		key: "time, indicator",
+		indices: []string{
+			"data_quality_indicator_idx on gha_data_quality(indicator)",
+		},
+	},
+	{
+		name: "gha_actors_geo",
+		columns: []string{
+			"login varchar(120) not null",
+			"location text",
+			"country_id varchar(2)",
+			"tz varchar(40)",
+			"checked_at {{ts}} not null",
+		},
+		key: "login",
+		indices: []string{
+			"actors_geo_checked_at_idx on gha_actors_geo(checked_at)",
+		},
+	},
+	{
+		name: "gha_star_corrections",
+		columns: []string{
+			"repo_id bigint not null",
+			"repo_name varchar(160) not null",
+			"stored_count int not null",
+			"api_count int not null",
+			"correction int not null",
+			"checked_at {{ts}} not null",
+		},
+		key: "repo_id",
+		indices: []string{
+			"star_corrections_repo_name_idx on gha_star_corrections(repo_name)",
+		},
+	},
+	{
+		name: "gha_workflow_runs",
+		columns: []string{
+			"id bigint not null",
+			"repo_name varchar(160) not null",
+			"workflow_id bigint not null",
+			"name varchar(200) not null",
+			"event varchar(40) not null",
+			"head_branch varchar(200)",
+			"head_sha varchar(40)",
+			"status varchar(20) not null",
+			"conclusion varchar(20)",
+			"actor_id bigint",
+			"actor_login varchar(120)",
+			"created_at {{ts}} not null",
+			"updated_at {{ts}} not null",
+			"duration_seconds int",
+		},
+		key: "id",
+		indices: []string{
+			"workflow_runs_repo_name_idx on gha_workflow_runs(repo_name)",
+			"workflow_runs_created_at_idx on gha_workflow_runs(created_at)",
+			"workflow_runs_conclusion_idx on gha_workflow_runs(conclusion)",
+		},
+	},
+	{
+		name: "gha_repo_traffic",
+		columns: []string{
+			"repo_name varchar(160) not null",
+			"dt {{ts}} not null",
+			"clones int not null default 0",
+			"clones_uniques int not null default 0",
+			"views int not null default 0",
+			"views_uniques int not null default 0",
+		},
+		key: "repo_name, dt",
+		indices: []string{
+			"repo_traffic_dt_idx on gha_repo_traffic(dt)",
+		},
+	},
+	{
+		name: "gha_fork_activity",
+		columns: []string{
+			"repo_name varchar(160) not null",
+			"fork_name varchar(160) not null",
+			"branch varchar(200) not null",
+			"pushed_at {{ts}} not null",
+			"stargazers int not null default 0",
+			"ahead_by int not null default 0",
+			"behind_by int not null default 0",
+			"dt {{ts}} not null",
+		},
+		key: "repo_name, fork_name",
+		indices: []string{
+			"fork_activity_pushed_at_idx on gha_fork_activity(pushed_at)",
+		},
+	},
+	{
+		name: "gha_milestone_progress",
+		columns: []string{
+			"milestone_id bigint not null",
+			"dt {{ts}} not null",
+			"repo_name varchar(160) not null",
+			"number int not null",
+			"title varchar(200) not null",
+			"state varchar(20) not null",
+			"due_on {{ts}}",
+			"open_issues int not null default 0",
+			"closed_issues int not null default 0",
+		},
+		key: "milestone_id, dt",
+		indices: []string{
+			"milestone_progress_dt_idx on gha_milestone_progress(dt)",
+			"milestone_progress_repo_name_idx on gha_milestone_progress(repo_name)",
+			"milestone_progress_title_idx on gha_milestone_progress(title)",
+		},
+	},
+	{
+		name: "gha_mentions",
+		columns: []string{
+			"source_type varchar(20) not null",
+			"source_id bigint not null",
+			"mentioned_login varchar(120) not null",
+			"event_id bigint not null",
+			"actor_id bigint not null",
+			"actor_login varchar(120) not null",
+			"repo_id bigint not null",
+			"repo_name varchar(160) not null",
+			"created_at {{ts}} not null",
+		},
+		key: "repo_id, source_type, source_id, mentioned_login",
+		indices: []string{
+			"mentions_mentioned_login_idx on gha_mentions(lower(mentioned_login))",
+			"mentions_actor_login_idx on gha_mentions(lower(actor_login))",
+			"mentions_repo_name_idx on gha_mentions(repo_name)",
+			"mentions_created_at_idx on gha_mentions(created_at)",
+		},
+	},
+	{
+		name: "gha_issue_refs",
+		columns: []string{
+			"source_type varchar(20) not null",
+			"source_id bigint not null",
+			"ref_repo_name varchar(160) not null",
+			"ref_number int not null",
+			"event_id bigint not null",
+			"actor_id bigint not null",
+			"actor_login varchar(120) not null",
+			"repo_id bigint not null",
+			"repo_name varchar(160) not null",
+			"created_at {{ts}} not null",
+		},
+		key: "repo_id, source_type, source_id, ref_repo_name, ref_number",
+		indices: []string{
+			"issue_refs_ref_idx on gha_issue_refs(ref_repo_name, ref_number)",
+			"issue_refs_repo_name_idx on gha_issue_refs(repo_name)",
+			"issue_refs_created_at_idx on gha_issue_refs(created_at)",
+		},
+	},
+	{
+		name: "gha_actors_unreconciled",
+		columns: []string{
+			"login varchar(120) not null",
+			"reason varchar(20) not null",
+			"dt {{ts}} not null",
+		},
+		key: "login",
+	},
+	{
+		name: "gha_truncated",
+		columns: []string{
+			"field varchar(100) not null",
+			"trunc_limit int not null",
+			"original text not null",
+			"dt {{ts}} not null",
+		},
+		indices: []string{
+			"truncated_field_idx on gha_truncated(field)",
+			"truncated_dt_idx on gha_truncated(dt)",
+		},
+	},
+	{
+		name: "gha_commits_messages",
+		columns: []string{
+			"sha varchar(40) not null",
+			"message bytea not null",
+			"length int not null",
+			"dup_repo_name varchar(160) not null",
+			"dup_created_at {{ts}} not null",
+		},
+		key: "sha",
+	},
+	{
+		name: "gha_sync_runs",
+		columns: []string{
+			"run_id bigint not null",
+			"project varchar(100) not null",
+			"stage text not null",
+			"status varchar(20) not null",
+			"dt_start {{ts}} not null",
+			"dt_end {{ts}}",
+		},
+		key: "run_id, stage",
+		indices: []string{
+			"sync_runs_project_idx on gha_sync_runs(project)",
+		},
+	},
+	{
+		name: "gha_ts_export",
+		columns: []string{
+			"backend varchar(40) not null",
+			"series_table text not null",
+			"last_time {{ts}} not null",
+			"dt {{ts}} not null",
+		},
+		key: "backend, series_table",
+	},
+	{
+		name: "gha_tracker_issues",
+		columns: []string{
+			"source varchar(40) not null",
+			"external_key varchar(100) not null",
+			"external_state varchar(100) not null",
+			"id bigint not null",
+			"event_id bigint not null",
+			"assignee_id bigint",
+			"body text",
+			"closed_at {{ts}}",
+			"comments int not null",
+			"created_at {{ts}} not null",
+			"locked boolean not null",
+			"milestone_id bigint",
+			"number int not null",
+			"state varchar(20) not null",
+			"title text not null",
+			"updated_at {{ts}} not null",
+			"user_id bigint not null",
+			"is_pull_request boolean not null",
+			"dup_actor_id bigint not null",
+			"dup_actor_login varchar(120) not null",
+			"dup_repo_id bigint not null",
+			"dup_repo_name varchar(160) not null",
+			"dup_type varchar(40) not null",
+			"dup_created_at {{ts}} not null",
+			"dupn_assignee_login varchar(120)",
+			"dup_user_login varchar(120) not null",
+		},
+		key: "source, id, event_id",
+		indices: []string{
+			"tracker_issues_external_key_idx on gha_tracker_issues(external_key)",
+			"tracker_issues_created_at_idx on gha_tracker_issues(created_at)",
+			"tracker_issues_updated_at_idx on gha_tracker_issues(updated_at)",
+			"tracker_issues_state_idx on gha_tracker_issues(state)",
+			"tracker_issues_dup_actor_login_idx on gha_tracker_issues(dup_actor_login)",
+			"tracker_issues_dup_repo_name_idx on gha_tracker_issues(dup_repo_name)",
+		},
+	},
+	{
+		name: "gha_parsed_stats",
+		columns: []string{
+			"dt {{ts}} not null",
+			"type varchar(40) not null",
+			"events int not null",
+			"matched int not null",
+			"written int not null",
+			"skewed int not null default 0",
+			"duplicates int not null default 0",
+			"excluded int not null default 0",
+		},
+		key: "dt, type",
+		indices: []string{
+			"parsed_stats_type_idx on gha_parsed_stats(type)",
+		},
+	},
+	{
+		name: "gha_recent_repos",
+		columns: []string{
+			"repo_id bigint not null",
+			"repo_name varchar(160) not null",
+			"last_event_at {{ts}} not null",
+		},
+		key: "repo_id, repo_name",
+		indices: []string{
+			"recent_repos_last_event_at_idx on gha_recent_repos(last_event_at)",
+		},
+		fill: "insert into gha_recent_repos(repo_id, repo_name, last_event_at) " +
+			"select repo_id, dup_repo_name, max(created_at) from gha_events " +
+			"group by repo_id, dup_repo_name " +
+			"on conflict do nothing",
+	},
+	{
+		name: "gha_provisional_events",
+		columns: []string{
+			"event_id bigint not null",
+			"key text not null",
+			"delivery varchar(40) not null",
+			"type varchar(40) not null",
+			"dup_repo_name varchar(160) not null",
+			"created_at {{ts}} not null",
+		},
+		key: "event_id",
+		indices: []string{
+			"provisional_events_key_idx on gha_provisional_events(key)",
+			"provisional_events_created_at_idx on gha_provisional_events(created_at)",
+		},
+	},
+	{
+		name: "gha_backfill_progress",
+		columns: []string{
+			"name varchar(100) not null",
+			"sha varchar(40) not null",
+			"event_id bigint not null",
+			"processed bigint not null",
+			"roles bigint not null",
+			"updated_at {{tsnow}} not null",
+		},
+		key: "name",
+	},
+}
+
+// addedColumnsDDL - returns columns added to a given initial structure table, as a part of its create table statement
+func addedColumnsDDL(table string) (ddl string) {
+	for _, column := range addedColumns {
+		if column[0] == table {
+			ddl += column[1] + ", "
+		}
+	}
+	return
+}
+
+// createAddedIndices - creates indices added to a given initial structure table
+func createAddedIndices(c *sql.DB, ctx *Ctx, table, ifNotExists string) {
+	for _, index := range addedIndices {
+		if index[0] == table {
+			ExecSQLWithErr(c, ctx, "create index "+ifNotExists+index[1])
+		}
+	}
+}
+
+// findAddedTable - returns added table definition by name
+func findAddedTable(name string) *addedTable {
+	for i := range addedTables {
+		if addedTables[i].name == name {
+			return &addedTables[i]
+		}
+	}
+	Fatalf("unknown added table: %s", name)
+	return nil
+}
+
+// create - creates table and its indices, ifNotExists is "if not exists " for Migrate (tools can race on it) and empty for Structure
+func (t *addedTable) create(c *sql.DB, ctx *Ctx, ifNotExists string, table, index bool) {
+	if table {
+		columns := strings.Join(t.columns, ", ")
+		if t.key != "" {
+			columns += ", primary key(" + t.key + ")"
+		}
+		ExecSQLWithErr(c, ctx, CreateTable(ifNotExists+t.name+"("+columns+")"))
+	}
+	if index {
+		for _, idx := range t.indices {
+			ExecSQLWithErr(c, ctx, "create index "+ifNotExists+idx)
+		}
+	}
+}
+
+// createAddedTable - drops and creates a given added table, like all other tables created by Structure
+func createAddedTable(c *sql.DB, ctx *Ctx, name string) {
+	t := findAddedTable(name)
+	if ctx.Table {
+		ExecSQLWithErr(c, ctx, "drop table if exists "+t.name)
+	}
+	t.create(c, ctx, "", ctx.Table, ctx.Index)
+}
+
+// Migrate - creates tables and columns added to structure that are missing in a database created by an older version
+// Catalog is checked first and only missing objects are created, so up to date databases are not locked by DDL
+// Tools writing to these tables call it once after connecting, readers treat missing tables as no data
+func Migrate(c *sql.DB, ctx *Ctx) {
+	rows := QuerySQLWithErr(
+		c,
+		ctx,
+		"select table_name, column_name from information_schema.columns where table_schema = 'public' and table_name like 'gha\\_%'",
+	)
+	columns := make(map[string]map[string]struct{})
+	var table, column string
+	for rows.Next() {
+		FatalOnError(rows.Scan(&table, &column))
+		if _, ok := columns[table]; !ok {
+			columns[table] = make(map[string]struct{})
+		}
+		columns[table][column] = struct{}{}
+	}
+	FatalOnError(rows.Err())
+	FatalOnError(rows.Close())
+	// Database without any gha_* tables is not created yet, structure creates all of them
+	if len(columns) == 0 {
+		return
+	}
+	indices := make(map[string]struct{})
+	rows = QuerySQLWithErr(c, ctx, "select indexname from pg_indexes where schemaname = 'public'")
+	var index string
+	for rows.Next() {
+		FatalOnError(rows.Scan(&index))
+		indices[index] = struct{}{}
+	}
+	FatalOnError(rows.Err())
+	FatalOnError(rows.Close())
+	addColumn := func(table, definition string) {
+		if _, ok := columns[table][strings.Fields(definition)[0]]; ok {
+			return
+		}
+		Printf("Adding missing column %s.%s\n", table, definition)
+		ExecSQLWithErr(c, ctx, "alter table "+table+" add column if not exists "+ddlTypes(definition))
+	}
+	for _, column := range addedColumns {
+		if _, ok := columns[column[0]]; ok {
+			addColumn(column[0], column[1])
+		}
+	}
+	for _, index := range addedIndices {
+		if _, ok := columns[index[0]]; !ok {
+			continue
+		}
+		if _, ok := indices[strings.Fields(index[1])[0]]; !ok {
+			Printf("Creating missing index %s\n", index[1])
+			ExecSQLWithErr(c, ctx, "create index if not exists "+index[1])
+		}
+	}
+	for i := range addedTables {
+		t := &addedTables[i]
+		if _, ok := columns[t.name]; ok {
+			for _, column := range t.columns {
+				addColumn(t.name, column)
+			}
+			continue
+		}
+		Printf("Creating missing table %s\n", t.name)
+		t.create(c, ctx, "if not exists ", true, true)
+		if t.fill != "" {
+			ExecSQLWithErr(c, ctx, t.fill)
+		}
+	}
+}
+
 // Structure creates full database structure, indexes, views/summary tables etc
 func Structure(ctx *Ctx) {
 	// Connect to Postgres DB
 	c := PgConn(ctx)
 	defer func() { FatalOnError(c.Close()) }()
 
+	// Integrity mode constraints would not allow dropping tables
+	if ctx.Table || ctx.FKChecks {
+		dropIntegrityConstraints(c, ctx)
+	}
+
 	// gha_events
 	// {"id:String"=>48592, "type:String"=>48592, "actor:Hash"=>48592, "repo:Hash"=>48592,
 	// "payload:Hash"=>48592, "public:TrueClass"=>48592, "created_at:String"=>48592, "org:Hash"=>19451}
@@ -17,14 +691,16 @@ func Structure(ctx *Ctx) {
 	// "created_at"=>20, "org"=>230}
 	// const
 	// dup columns: dup_actor_login, dup_repo_name
+	// project: GHA2DB_PROJECT that added the event (projects sharing a database)
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_events")
+		key, partitionBy := PartitionClauses(ctx, "gha_events")
 		ExecSQLWithErr(
 			c,
 			ctx,
 			CreateTable(
 				"gha_events("+
-					"id bigint not null primary key, "+
+					"id bigint not null, "+
 					"type varchar(40) not null, "+
 					"actor_id bigint not null, "+
 					"repo_id bigint not null, "+
@@ -33,8 +709,10 @@ func Structure(ctx *Ctx) {
 					"org_id bigint, "+
 					"forkee_id bigint, "+
 					"dup_actor_login varchar(120) not null, "+
-					"dup_repo_name varchar(160) not null"+
-					")",
+					"dup_repo_name varchar(160) not null, "+
+					addedColumnsDDL("gha_events")+
+					key+
+					")"+partitionBy,
 			),
 		)
 	}
@@ -200,6 +878,7 @@ func Structure(ctx *Ctx) {
 					"license_prob double precision, "+
 					"created_at {{tsnow}}, "+
 					"updated_at {{tsnow}}, "+
+					addedColumnsDDL("gha_repos")+
 					"primary key(id, name))",
 			),
 		)
@@ -216,6 +895,7 @@ func Structure(ctx *Ctx) {
 		ExecSQLWithErr(c, ctx, "create index repos_license_prob_idx on gha_repos(license_prob)")
 		ExecSQLWithErr(c, ctx, "create index repos_created_at_idx on gha_repos(created_at)")
 		ExecSQLWithErr(c, ctx, "create index repos_updated_at_idx on gha_repos(updated_at)")
+		ExecSQLWithErr(c, ctx, "create index repos_status_idx on gha_repos(status)")
 	}
 
 	// gha_repo_groups
@@ -310,12 +990,13 @@ func Structure(ctx *Ctx) {
 	// const
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_payloads")
+		key, partitionBy := PartitionClauses(ctx, "gha_payloads")
 		ExecSQLWithErr(
 			c,
 			ctx,
 			CreateTable(
 				"gha_payloads("+
-					"event_id bigint not null primary key, "+
+					"event_id bigint not null, "+
 					"push_id bigint, "+
 					"size int, "+
 					"ref varchar(200), "+
@@ -338,8 +1019,9 @@ func Structure(ctx *Ctx) {
 					"dup_repo_id bigint not null, "+
 					"dup_repo_name varchar(160) not null, "+
 					"dup_type varchar(40) not null, "+
-					"dup_created_at {{ts}} not null"+
-					")",
+					"dup_created_at {{ts}} not null, "+
+					key+
+					")"+partitionBy,
 			),
 		)
 	}
@@ -466,6 +1148,12 @@ func Structure(ctx *Ctx) {
 		ExecSQLWithErr(c, ctx, "create index commits_roles_dup_created_at_idx on gha_commits_roles(dup_created_at)")
 	}
 
+	// gha_commits_authors - artificial table, commit author (source header) and co-authors from commit trailers (source trailer)
+	// Written by gha2db and backfilled by gha_backfill_commits_roles authors, ord 0 is the commit author
+	createAddedTable(c, ctx, "gha_commits_authors")
+	// gha_backfill_progress - last commit processed by gha_backfill_commits_roles (roles and authors backfills), so it can be resumed
+	createAddedTable(c, ctx, "gha_backfill_progress")
+
 	// gha_pages
 	// {"page_name:String"=>370, "title:String"=>370, "summary:NilClass"=>370,
 	// "action:String"=>370, "sha:String"=>370, "html_url:String"=>370}
@@ -511,6 +1199,7 @@ func Structure(ctx *Ctx) {
 	// variable
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_comments")
+		key, partitionBy := PartitionClauses(ctx, "gha_comments")
 		ExecSQLWithErr(
 			c,
 			ctx,
@@ -537,8 +1226,8 @@ func Structure(ctx *Ctx) {
 					"dup_type varchar(40) not null, "+
 					"dup_created_at {{ts}} not null, "+
 					"dup_user_login varchar(120) not null, "+
-					"primary key(id, event_id)"+
-					")",
+					key+
+					")"+partitionBy,
 			),
 		)
 	}
@@ -1405,6 +2094,29 @@ func Structure(ctx *Ctx) {
 		ExecSQLWithErr(c, ctx, "create index issues_events_labels_lower_actor_login_idx on gha_issues_events_labels(lower(actor_login))")
 	}
 
+	// gha_issue_label_history - artificial table, label added/removed transitions from GitHub API issue events (labeled/unlabeled)
+	// Written by ghapi2db, event_id is GitHub API issue event ID
+	createAddedTable(c, ctx, "gha_issue_label_history")
+
+	// gha_issue_transfers - artificial table, issues/PRs transferred between repositories (GitHub API 'transferred' issue events)
+	// Written by ghapi2db, event_id is GitHub API issue event ID, source repository is taken from previous issue state (if known)
+	// gha_issues_canonical - current repository of each transferred issue, metrics should use it instead of dup_repo_name
+	// so transferred issues are counted once with continuous history
+	createAddedTable(c, ctx, "gha_issue_transfers")
+	createAddedTable(c, ctx, "gha_issues_canonical")
+
+	// gha_pr_issues - artificial table, issues that PRs close ("closes #N" linkage), written by ghapi2db
+	// source is 'body' (closing keywords in PR body) or 'timeline' (cross-referenced closing PR found on closed issue timeline)
+	// issue_id is resolved from gha_issues when issue is known
+	createAddedTable(c, ctx, "gha_pr_issues")
+
+	// gha_skipped_events - artificial table, numbers of issue events skipped by ghapi2db per event type and sync run
+	// unknown is set for types that are neither on the allow nor on the deny list (GHA2DB_EVENT_TYPES_YAML)
+	createAddedTable(c, ctx, "gha_skipped_events")
+
+	// gha_commits_gaps - artificial table, targeted re-syncs of commits with missing enrichment done by ghapi2db
+	createAddedTable(c, ctx, "gha_commits_gaps")
+
 	// This table is a kind of `materialized view` of issues - PRs connections
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_issues_pull_requests")
@@ -1491,7 +2203,53 @@ func Structure(ctx *Ctx) {
 			),
 		)
 	}
+	// This is to determine if a manual 'range:from,to' period was already computed on demand (API)
+	createAddedTable(c, ctx, "gha_manual_periods")
+	// This is to invalidate API cached responses when series data of a period changes (calc_metric, blue/green swap)
+	createAddedTable(c, ctx, "gha_series_versions")
+	// This table stores data quality indicators computed at the end of each sync
+	createAddedTable(c, ctx, "gha_data_quality")
+	// This table stores GitHub profile locations of actors and countries/time zones resolved from them
+	// it is used to skip recently checked actors and to never overwrite manually curated countries
+	createAddedTable(c, ctx, "gha_actors_geo")
+	// This table stores differences between stars counted from WatchEvents and stargazers_count from GitHub API
+	// WatchEvents are never removed when a star is removed, so metrics add corrections to stored counts
+	createAddedTable(c, ctx, "gha_star_corrections")
+	// This table stores GitHub Actions workflow runs synced from GitHub API by ghapi2db (when GHA2DB_GHAPIWORKFLOWRUNS is set)
+	// GHA data has no events for CI runs
+	createAddedTable(c, ctx, "gha_workflow_runs")
+	// This table stores daily repository clones and views (ghapi2db with GHA2DB_GHAPITRAFFIC)
+	// GitHub keeps only the last 14 days of traffic, so data is collected continuously
+	createAddedTable(c, ctx, "gha_repo_traffic")
+	// This table stores active forks of repos compared with upstream (ghapi2db with GHA2DB_GHAPIFORKS)
+	// Each fork has a single row with the state from the last sync
+	createAddedTable(c, ctx, "gha_fork_activity")
+	// This table stores hourly snapshots of active milestones issues counts (ghapi2db with GHA2DB_GHAPIMILESTONES)
+	// GHA events only have milestone state at the time of each event, snapshots allow burn-down metrics
+	createAddedTable(c, ctx, "gha_milestone_progress")
+	// This table stores @mentions found in issues, PRs, reviews and comments bodies (gha2db with GHA2DB_MENTIONS)
+	// Source is: 'body' (issue/PR description, source_id is its number), 'comment' or 'review' (source_id is comment/review ID)
+	// Each mentioned login is stored once per source (first event with a given body), self mentions are skipped
+	createAddedTable(c, ctx, "gha_mentions")
+	// This table stores issue/PR cross-references (#123, org/repo#123, issue/PR URLs) found in the same bodies as gha_mentions
+	createAddedTable(c, ctx, "gha_issue_refs")
+	// This table stores logins with synthetic actor IDs that ghapi2db (GHA2DB_GHAPI_RECONCILE_ACTORS) could not resolve to real GitHub IDs
+	createAddedTable(c, ctx, "gha_actors_unreconciled")
+	// This table stores original values of fields truncated by gha2db (when GHA2DB_TRUNC_AUDIT is set)
+	createAddedTable(c, ctx, "gha_truncated")
+	// This table stores gzip compressed full commit messages longer than gha_commits.message limit (when GHA2DB_COMMIT_MSG_STORE is set)
+	createAddedTable(c, ctx, "gha_commits_messages")
+	// This table stores per-project sync runs and their stages statuses (gha2db_sync)
+	// Stage "all" holds the whole run status, GHA2DB_SYNC_RESUME skips stages already done in the last unfinished run
+	createAddedTable(c, ctx, "gha_sync_runs")
+	// This table stores last series time replicated to each time series backend by ts_export
+	createAddedTable(c, ctx, "gha_ts_export")
+	// This table stores issues imported from external issue trackers (Jira etc.) by tracker2db
+	// Columns are the same as in gha_issues (so both can be used in union queries), source is the tracker name
+	// Each issue has one row per state transition (event_id = 0 is the issue creation)
+	createAddedTable(c, ctx, "gha_tracker_issues")
 	// This table is to determine if given GHA hour was already parsed or not
+	// status - ok, partial (started but not finished, re-run by sync) or skipped (skip dates config)
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_parsed")
 		ExecSQLWithErr(
@@ -1500,6 +2258,7 @@ func Structure(ctx *Ctx) {
 			CreateTable(
 				"gha_parsed("+
 					"dt {{ts}} not null, "+
+					addedColumnsDDL("gha_parsed")+
 					"primary key(dt)"+
 					")",
 			),
@@ -1507,7 +2266,15 @@ func Structure(ctx *Ctx) {
 	}
 	if ctx.Index {
 		ExecSQLWithErr(c, ctx, "create index parsed_dt_idx on gha_parsed(dt)")
-	}
+		createAddedIndices(c, ctx, "gha_parsed", "")
+	}
+	// Per hour event type counters saved together with gha_parsed
+	// skewed - events created outside of their GHA hour, duplicates - events already seen in the same or adjacent GHA hours
+	createAddedTable(c, ctx, "gha_parsed_stats")
+	// Last event date of each repo, maintained by gha2db, used by ghapi2db to find recently active repos
+	createAddedTable(c, ctx, "gha_recent_repos")
+	// Events received via GitHub webhooks (gha2db receive), removed when GHA hour containing them is parsed
+	createAddedTable(c, ctx, "gha_provisional_events")
 	// This is to determine if a given JSON was imported or not
 	if ctx.Table {
 		ExecSQLWithErr(c, ctx, "drop table if exists gha_imported_shas")
@@ -1539,7 +2306,22 @@ func Structure(ctx *Ctx) {
 	if ctx.Index {
 		ExecSQLWithErr(c, ctx, "create index gha_bot_logins_pattern_idx on gha_bot_logins(pattern)")
 	}
+	// Partitioned tables get default partition (rows not matching monthly partitions) and partitions of current and next months
+	// gha2db creates partitions of imported months, pg_partition_manager creates future ones
+	if ctx.Table && ctx.Partitioned {
+		for i := range PartitionedTables {
+			CreateDefaultPartition(c, ctx, &PartitionedTables[i])
+		}
+		now := time.Now()
+		EnsurePartitions(c, ctx, now, AddNIntervals(now, ctx.PartitionsAhead, NextMonthStart, PrevMonthStart))
+	}
 	// Foreign keys are not needed - they slow down processing a lot
+	// They are only created in integrity mode (GHA2DB_FK_CHECKS) for test/QA databases, to catch parent/child ordering bugs
+	if ctx.FKChecks {
+		for _, constraint := range integrityConstraints {
+			ExecSQLWithErr(c, ctx, fmt.Sprintf("alter table %s add constraint %s %s", constraint[0], constraint[1], constraint[2]))
+		}
+	}
 
 	// Tools (like views and functions needed for generating metrics)
 	if ctx.Tools {
@@ -0,0 +1,125 @@
+package devstatscode
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// SyncRunAll - stage name of a row holding the whole sync run status
+const SyncRunAll = "all"
+
+// SyncRun - single per-project sync run, every stage completion is saved in gha_sync_runs
+// When GHA2DB_SYNC_RESUME is set and the last project run was not finished, its completed stages are skipped
+type SyncRun struct {
+	ID      int64
+	Project string
+	Resumed bool
+	con     *sql.DB
+	ctx     *Ctx
+	done    map[string]struct{}
+	mtx     sync.Mutex
+}
+
+// NewSyncRun - starts a new sync run of a given project or resumes the last unfinished one (GHA2DB_SYNC_RESUME)
+func NewSyncRun(con *sql.DB, ctx *Ctx, project string) *SyncRun {
+	run := &SyncRun{Project: project, con: con, ctx: ctx, done: make(map[string]struct{})}
+	if ctx.SyncResume {
+		var (
+			id     int64
+			status string
+		)
+		err := QueryRowSQL(
+			con,
+			ctx,
+			"select run_id, status from gha_sync_runs where project = $1 and stage = $2 order by run_id desc limit 1",
+			project,
+			SyncRunAll,
+		).Scan(&id, &status)
+		if err != nil && err != sql.ErrNoRows {
+			FatalOnError(err)
+		}
+		if err == nil && status == "started" {
+			run.ID = id
+			run.Resumed = true
+			rows := QuerySQLWithErr(con, ctx, "select stage from gha_sync_runs where run_id = $1 and status = 'done'", id)
+			defer func() { FatalOnError(rows.Close()) }()
+			stage := ""
+			for rows.Next() {
+				FatalOnError(rows.Scan(&stage))
+				run.done[stage] = struct{}{}
+			}
+			FatalOnError(rows.Err())
+			Printf("Resuming sync run %d of '%s', %d stages already done\n", id, project, len(run.done))
+			return run
+		}
+	}
+	// Only keep recent runs history
+	ExecSQLWithErr(con, ctx, "delete from gha_sync_runs where dt_start < now() - '1 week'::interval")
+	FatalOnError(QueryRowSQL(con, ctx, "select coalesce(max(run_id), 0) + 1 from gha_sync_runs").Scan(&run.ID))
+	run.save(SyncRunAll, "started")
+	if ctx.Debug > 0 {
+		Printf("Started sync run %d of '%s'\n", run.ID, project)
+	}
+	return run
+}
+
+// save - saves given stage status, started stage gets a new start date, other statuses set its end date
+func (r *SyncRun) save(stage, status string) {
+	if status != "started" {
+		ExecSQLWithErr(
+			r.con,
+			r.ctx,
+			"update gha_sync_runs set status = $1, dt_end = $2 where run_id = $3 and stage = $4",
+			status,
+			time.Now(),
+			r.ID,
+			stage,
+		)
+		return
+	}
+	q, args := NewQB("gha_sync_runs").
+		Set("run_id", r.ID).
+		Set("project", r.Project).
+		Set("stage", stage).
+		Set("status", status).
+		Set("dt_start", time.Now()).
+		Set("dt_end", nil).
+		Upsert("run_id", "stage")
+	ExecSQLWithErr(r.con, r.ctx, q, args...)
+}
+
+// Start - returns false if a given stage was already done in the resumed run, otherwise marks it as started and returns true
+func (r *SyncRun) Start(stage string) bool {
+	r.mtx.Lock()
+	_, done := r.done[stage]
+	r.mtx.Unlock()
+	if done {
+		Printf("Sync run %d: skipping stage '%s', already done\n", r.ID, stage)
+		return false
+	}
+	r.save(stage, "started")
+	return true
+}
+
+// IsDone - checks if a given stage was already done
+func (r *SyncRun) IsDone(stage string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	_, done := r.done[stage]
+	return done
+}
+
+// Done - marks given stage as done, resumed run will skip it
+func (r *SyncRun) Done(stage string) {
+	r.save(stage, "done")
+	r.mtx.Lock()
+	r.done[stage] = struct{}{}
+	r.mtx.Unlock()
+}
+
+// Finish - marks the whole run as finished, next resume will start a new run
+func (r *SyncRun) Finish() {
+	r.save(SyncRunAll, "finished")
+	Printf("Sync run %d finished\n", r.ID)
+}
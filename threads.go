@@ -1,6 +1,7 @@
 package devstatscode
 
 import (
+	"math"
 	"runtime"
 )
 
@@ -29,3 +30,18 @@ func GetThreadsNum(ctx *Ctx) int {
 	//http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = 2 * thrN
 	return thrN
 }
+
+// BudgetThreadsNum returns the number of CPUs that given CPU budget allows
+// Budget is a fraction of all available CPUs, for example 0.25 means 25% of CPUs
+// It always returns at least 1 and at most number of available CPUs
+func BudgetThreadsNum(budget float64) int {
+	n := runtime.NumCPU()
+	if budget <= 0.0 || budget >= 1.0 {
+		return n
+	}
+	thrN := int(math.Ceil(budget * float64(n)))
+	if thrN < 1 {
+		thrN = 1
+	}
+	return thrN
+}
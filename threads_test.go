@@ -1,6 +1,7 @@
 package devstatscode
 
 import (
+	"runtime"
 	"testing"
 
 	lib "github.com/cncf/devstatscode"
@@ -45,3 +46,32 @@ func TestGetThreadsNum(t *testing.T) {
 		}
 	}
 }
+
+func TestBudgetThreadsNum(t *testing.T) {
+	// Get actual number of CPUs available
+	nCPUs := runtime.NumCPU()
+
+	// Test cases
+	var testCases = []struct {
+		budget   float64
+		expected int
+	}{
+		{budget: 0.0, expected: nCPUs},
+		{budget: -0.5, expected: nCPUs},
+		{budget: 1.0, expected: nCPUs},
+		{budget: 2.0, expected: nCPUs},
+		{budget: 0.000001, expected: 1},
+		{budget: 0.5, expected: (nCPUs + 1) / 2},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		expected := test.expected
+		got := lib.BudgetThreadsNum(test.budget)
+		if got != expected {
+			t.Errorf(
+				"test number %d, expected to return %d threads for %f budget, got %d (%d CPUs on this machine)",
+				index+1, expected, test.budget, got, nCPUs,
+			)
+		}
+	}
+}
@@ -91,6 +91,7 @@ func GetDateAgo(con *sql.DB, ctx *Ctx, from time.Time, ago string) (tm time.Time
 
 // ProgressInfo display info about progress: i/n if current time >= last + period
 // If displayed info, update last
+// Use Progress reporter for operations that should also report rates and support JSON output (GHA2DB_PROGRESS_JSON)
 func ProgressInfo(i, n int, start time.Time, last *time.Time, period time.Duration, msg string) {
 	now := time.Now()
 	if last.Add(period).Before(now) {
@@ -403,7 +404,7 @@ func TimeParseAny(dtStr string) time.Time {
 	}
 	Printf("Error:\nCannot parse date: '%v'\n", dtStr)
 	fmt.Fprintf(os.Stdout, "Error:\nCannot parse date: '%v'\n", dtStr)
-	os.Exit(1)
+	os.Exit(ExitConfig)
 	return time.Now()
 }
 
@@ -541,7 +542,7 @@ func GetIntervalFunctions(intervalAbbr string, allowUnknown bool) (interval stri
 		if !allowUnknown {
 			Printf("Error:\nUnknown interval '%v'\n", intervalAbbr)
 			fmt.Fprintf(os.Stdout, "Error:\nUnknown interval '%v'\n", intervalAbbr)
-			os.Exit(1)
+			os.Exit(ExitConfig)
 		} else {
 			return
 		}
@@ -0,0 +1,343 @@
+package affsdiff
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// gitHubUser - single GitHub user entry from cncf/gitdm `github_users.json` JSON (only fields used by affiliations)
+type gitHubUser struct {
+	Login       string `json:"login"`
+	Affiliation string `json:"affiliation"`
+	Source      string `json:"source"`
+}
+
+// allAcquisitions contain all company acquisitions data
+type allAcquisitions struct {
+	Acquisitions [][2]string `yaml:"acquisitions"`
+}
+
+// affiliation - single login's company affiliation, as stored in gha_actors_affiliations
+type affiliation struct {
+	Company string
+	From    time.Time
+	To      time.Time
+}
+
+// companyDiff - number of added, removed and changed (same company, different dates) affiliations of a company
+type companyDiff struct {
+	Added   int
+	Removed int
+	Changed int
+}
+
+// affectedRange - login with a date range in which its affiliation differs
+type affectedRange struct {
+	Login string
+	From  time.Time
+	To    time.Time
+}
+
+// affectedBatch - number of logins checked for activity in a single query
+const affectedBatch = 1000
+
+var (
+	defaultStartDate = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultEndDate   = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	sourceToPrio     = map[string]int{"notfound": -20, "domain": -10, "": 0, "config": 10, "manual": 20, "user_manual": 30, "user": 40}
+)
+
+// readAcquisitions - reads company acquisitions mapping (acquired company name regexp -> new company name)
+func readAcquisitions(ctx *lib.Ctx, dataPrefix string) map[*regexp.Regexp]string {
+	acqMap := make(map[*regexp.Regexp]string)
+	if ctx.SkipCompanyAcq {
+		return acqMap
+	}
+	data, err := lib.ReadFile(ctx, dataPrefix+ctx.CompanyAcqYaml)
+	if err != nil {
+		lib.Printf("Cannot read company acquisitions mapping '%v', continuying without\n", err)
+		return acqMap
+	}
+	var acqs allAcquisitions
+	lib.FatalOnError(yaml.Unmarshal(data, &acqs))
+	for _, acq := range acqs.Acquisitions {
+		acqMap[regexp.MustCompile(acq[0])] = acq[1]
+	}
+	return acqMap
+}
+
+// mapCompanyName - maps company name to a new company name when it was acquired
+func mapCompanyName(acqMap map[*regexp.Regexp]string, cache map[string]string, company string) string {
+	if res, ok := cache[company]; ok {
+		return res
+	}
+	res := company
+	for re, acquirer := range acqMap {
+		if re.MatchString(company) {
+			res = acquirer
+			break
+		}
+	}
+	cache[company] = res
+	return res
+}
+
+// incomingAffiliations - parses github_users.json into login -> affiliations, the same way import_affs does:
+// highest source priority wins, then the definition listing most companies
+func incomingAffiliations(ctx *lib.Ctx, jsonFN string, acqMap map[*regexp.Regexp]string, maybeHide func(string) string) map[string]map[affiliation]struct{} {
+	data, err := lib.ReadFile(ctx, jsonFN)
+	lib.FatalOnError(err)
+	var users []gitHubUser
+	lib.FatalOnError(jsoniter.Unmarshal(data, &users))
+	lib.Printf("Processing %d JSON entries\n", len(users))
+	prios := make(map[string]int)
+	defs := make(map[string]map[string]struct{})
+	for _, user := range users {
+		aff := user.Affiliation
+		if aff == "NotFound" || aff == "(Unknown)" || aff == "?" || aff == "-" || aff == "" {
+			continue
+		}
+		aff = strings.Replace(aff, `"`, "", -1)
+		login := strings.ToLower(user.Login)
+		prio := sourceToPrio[strings.ToLower(user.Source)]
+		curr, ok := prios[login]
+		if ok && prio < curr {
+			continue
+		}
+		if !ok || prio > curr {
+			prios[login] = prio
+			defs[login] = make(map[string]struct{})
+		}
+		defs[login][aff] = struct{}{}
+	}
+	cache := make(map[string]string)
+	affs := make(map[string]map[affiliation]struct{})
+	for login, laffs := range defs {
+		var affsAry []string
+		for aff := range laffs {
+			ary := strings.Split(aff, ",")
+			if len(ary) > len(affsAry) {
+				affsAry = ary
+			}
+		}
+		hlogin := maybeHide(login)
+		affs[hlogin] = make(map[affiliation]struct{})
+		// Affiliation has a form "com1 < dt1, com2 < dt2, ..., com(N-1) < dt(N-1), comN"
+		prevDate := defaultStartDate
+		for _, aff := range affsAry {
+			ary := strings.Split(strings.TrimSpace(aff), "<")
+			company := strings.TrimSpace(ary[0])
+			dtTo := defaultEndDate
+			if len(ary) > 1 {
+				dtTo = lib.TimeParseAny(strings.TrimSpace(ary[1]))
+			}
+			if company != "" {
+				company = maybeHide(lib.TruncToBytes(mapCompanyName(acqMap, cache, company), 160))
+				affs[hlogin][affiliation{Company: company, From: prevDate, To: dtTo}] = struct{}{}
+			}
+			prevDate = dtTo
+		}
+	}
+	return affs
+}
+
+// currentAffiliations - returns login -> affiliations currently stored in gha_actors_affiliations
+func currentAffiliations(con *sql.DB, ctx *lib.Ctx) map[string]map[affiliation]struct{} {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select distinct lower(a.login), af.company_name, af.dt_from, af.dt_to "+
+			"from gha_actors_affiliations af, gha_actors a where af.actor_id = a.id",
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	affs := make(map[string]map[affiliation]struct{})
+	var (
+		login string
+		aff   affiliation
+	)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&login, &aff.Company, &aff.From, &aff.To))
+		aff.From = aff.From.UTC()
+		aff.To = aff.To.UTC()
+		if _, ok := affs[login]; !ok {
+			affs[login] = make(map[affiliation]struct{})
+		}
+		affs[login][aff] = struct{}{}
+	}
+	lib.FatalOnError(rows.Err())
+	return affs
+}
+
+// diffAffiliations - compares incoming and current affiliations of logins defined in the incoming file
+// Returns per company differences and logins with date ranges whose affiliation differs
+func diffAffiliations(incoming, current map[string]map[affiliation]struct{}) (companies map[string]*companyDiff, ranges []affectedRange) {
+	companies = make(map[string]*companyDiff)
+	get := func(company string) *companyDiff {
+		diff, ok := companies[company]
+		if !ok {
+			diff = &companyDiff{}
+			companies[company] = diff
+		}
+		return diff
+	}
+	for login, affs := range incoming {
+		curr := current[login]
+		added := make(map[string]affiliation)
+		removed := make(map[string]affiliation)
+		for aff := range affs {
+			if _, ok := curr[aff]; !ok {
+				added[aff.Company] = aff
+				ranges = append(ranges, affectedRange{Login: login, From: aff.From, To: aff.To})
+			}
+		}
+		for aff := range curr {
+			if _, ok := affs[aff]; !ok {
+				removed[aff.Company] = aff
+				ranges = append(ranges, affectedRange{Login: login, From: aff.From, To: aff.To})
+			}
+		}
+		for company := range added {
+			if _, ok := removed[company]; ok {
+				get(company).Changed++
+				delete(removed, company)
+				continue
+			}
+			get(company).Added++
+		}
+		for company := range removed {
+			get(company).Removed++
+		}
+	}
+	return
+}
+
+// affectedActivity - returns the earliest event date and number of events of logins in ranges in which their affiliations differ
+func affectedActivity(con *sql.DB, ctx *lib.Ctx, ranges []affectedRange) (first *time.Time, events int64) {
+	for from := 0; from < len(ranges); from += affectedBatch {
+		to := from + affectedBatch
+		if to > len(ranges) {
+			to = len(ranges)
+		}
+		args := []interface{}{}
+		values := []string{}
+		for i, r := range ranges[from:to] {
+			values = append(values, fmt.Sprintf("(%s, %s::timestamp, %s::timestamp)", lib.NValue(3*i+1), lib.NValue(3*i+2), lib.NValue(3*i+3)))
+			args = append(args, r.Login, r.From, r.To)
+		}
+		var (
+			dt *time.Time
+			n  int64
+		)
+		lib.FatalOnError(
+			lib.QueryRowSQL(
+				con,
+				ctx,
+				"select min(e.created_at), count(e.id) from gha_events e, (values "+strings.Join(values, ", ")+
+					") as r(login, dt_from, dt_to) where lower(e.dup_actor_login) = r.login "+
+					"and e.created_at >= r.dt_from and e.created_at < r.dt_to",
+				args...,
+			).Scan(&dt, &n),
+		)
+		events += n
+		if dt != nil && (first == nil || dt.Before(*first)) {
+			first = dt
+		}
+	}
+	return
+}
+
+// affsDiff - compares given github_users.json with current affiliations and reports import impact
+func affsDiff(jsonFN string) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Files path
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	if jsonFN == "" {
+		jsonFN = dataPrefix + ctx.AffiliationsJSON
+	}
+	lib.Printf("Comparing %s with current affiliations\n", jsonFN)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+
+	// Hidden logins and companies are compared using their anonymized values
+	maybeHide := lib.MaybeHideFunc(lib.GetHidden(&ctx, lib.HideCfgFile))
+	incoming := incomingAffiliations(&ctx, jsonFN, readAcquisitions(&ctx, dataPrefix), maybeHide)
+	current := currentAffiliations(con, &ctx)
+	companies, ranges := diffAffiliations(incoming, current)
+
+	// Per company report, most affected companies first
+	names := []string{}
+	total := companyDiff{}
+	for name, diff := range companies {
+		names = append(names, name)
+		total.Added += diff.Added
+		total.Removed += diff.Removed
+		total.Changed += diff.Changed
+	}
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := companies[names[i]], companies[names[j]]
+		ni, nj := di.Added+di.Removed+di.Changed, dj.Added+dj.Removed+dj.Changed
+		if ni == nj {
+			return names[i] < names[j]
+		}
+		return ni > nj
+	})
+	fmt.Printf("%-50s %8s %8s %8s\n", "Company", "Added", "Removed", "Changed")
+	for _, name := range names {
+		diff := companies[name]
+		fmt.Printf("%-50s %8d %8d %8d\n", name, diff.Added, diff.Removed, diff.Changed)
+	}
+	fmt.Printf("%-50s %8d %8d %8d\n", "Total", total.Added, total.Removed, total.Changed)
+	if len(ranges) == 0 {
+		fmt.Printf("No affiliation changes, no recompute needed\n")
+		return
+	}
+
+	// Estimate computed series periods that require recompute
+	first, events := affectedActivity(con, &ctx, ranges)
+	if first == nil {
+		fmt.Printf("No activity of affected actors in changed ranges, no recompute needed\n")
+		return
+	}
+	now := time.Now().UTC()
+	fmt.Printf("%d events of affected actors, first at %s\n", events, lib.ToYMDHMSDate(*first))
+	for _, period := range []string{"d", "w", "m", "q", "y"} {
+		interval, _, intervalStart, nextIntervalStart, _ := lib.GetIntervalFunctions(period, false)
+		n := 0
+		dt := intervalStart(*first)
+		for start := dt; start.Before(now); start = nextIntervalStart(start) {
+			n++
+		}
+		fmt.Printf("%-8s series: %d periods from %s\n", interval, n, lib.ToYMDDate(dt))
+	}
+	fmt.Printf("Histograms with ranges ending after %s\n", lib.ToYMDDate(*first))
+}
+
+// Main - runs affs_diff tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	if len(os.Args) < 2 {
+		affsDiff("")
+	} else {
+		affsDiff(os.Args[1])
+	}
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,9209 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/cors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// allAPIs - List all currently defined APIs
+var allAPIs = []string{
+	lib.Health,
+	lib.ListAPIs,
+	lib.ListProjects,
+	lib.RepoGroups,
+	lib.Ranges,
+	lib.Countries,
+	lib.Companies,
+	lib.Events,
+	lib.Repos,
+	lib.CompaniesTable,
+	lib.ComContribRepoGrp,
+	lib.DevActCnt,
+	lib.DevActCntComp,
+	lib.ComStatsRepoGrp,
+	lib.SiteStats,
+	lib.CountriesStats,
+	lib.DataQuality,
+	lib.Batch,
+	lib.CompanyProfile,
+	lib.CIStats,
+	lib.Certificate,
+	lib.VerifyCertificate,
+	lib.PRSizeDistribution,
+	lib.RenamedOrDeletedRepos,
+	lib.Export,
+	lib.IngestStats,
+	lib.Traffic,
+	lib.DevActDistribution,
+	lib.Velocity,
+	lib.LabelLifecycle,
+	lib.ReleaseStats,
+	lib.SyncStatus,
+	lib.ForkActivity,
+	lib.ProjectFeatures,
+	lib.MentionGraph,
+	lib.RepoStats,
+	lib.BusFactor,
+	lib.LinkedWork,
+	lib.TagCloud,
+	lib.MilestoneProgress,
+	lib.SeriesQuery,
+	lib.ContributorRetention,
+	lib.DevActDiff,
+	lib.BgStatus,
+}
+
+var (
+	gNameToDB map[string]string
+	gProjects []string
+	// gMainRepos - project main repository (velocity report "repo" column), by project database
+	gMainRepos map[string]string
+	// gFeatures - project enabled features (projects.yaml 'features'), by project database
+	gFeatures map[string][]string
+	// gSharded - project databases using sharding (projects.yaml 'shards'), their GHA tables are read from union views
+	gSharded map[string]bool
+	gMtx     *sync.RWMutex
+	gBgMtx   *sync.RWMutex
+	// gNumBg - number of running background calculations
+	gNumBg = 0
+	// gMaxBg - maximum number of background calculations running at the same time (GHA2DB_API_MAX_BG)
+	gMaxBg = 3
+	// gMaxBgQueue - maximum number of background calculations waiting for a free slot (GHA2DB_API_MAX_BG_QUEUE)
+	gMaxBgQueue = 100
+	// gBgJobs - running and queued background calculations by configuration key
+	gBgJobs = map[string]*bgJob{}
+	// gBgQueue - keys of queued background calculations in order they were requested
+	gBgQueue = []string{}
+	// gBatchWorkers - maximum number of API calls from a single Batch API request executed at the same time
+	gBatchWorkers = 4
+	// gMaxBatchRequests - maximum number of API calls in a single Batch API request
+	gMaxBatchRequests = 20
+	// gMaxGitHubIDs - maximum number of logins in a single DevActCnt/DevActCntComp github_ids request
+	gMaxGitHubIDs = 100
+	// gTrustedProxies - client IP is taken from X-Forwarded-For/X-Real-IP only for requests from these proxies (GHA2DB_TRUSTED_PROXIES)
+	gTrustedProxies []*net.IPNet
+	// gCertSecret - Certificate API signing key (GHA2DB_API_CERT_SECRET)
+	gCertSecret []byte
+	// gAdminToken - bearer token required by admin APIs (GHA2DB_API_ADMIN_TOKEN)
+	gAdminToken []byte
+	// gMaxBody - maximum API request body size in bytes (GHA2DB_API_MAX_BODY)
+	gMaxBody = 1 << 20
+	// gMaxDepth - maximum API request JSON nesting depth (GHA2DB_API_MAX_DEPTH)
+	gMaxDepth = 8
+	// gMaxArray - maximum length of any array in API request JSON (GHA2DB_API_MAX_ARRAY)
+	gMaxArray = 1000
+	// gMaxExportTables - maximum number of tables in a single Export API request
+	gMaxExportTables = 50
+	// gStartTime - API server start time (version endpoint uptime)
+	gStartTime time.Time
+)
+
+// Methods allowed on API endpoints, advertised in OPTIONS responses and Allow header
+const (
+	apiMethods     = "POST, GET, HEAD, OPTIONS"
+	versionMethods = "GET, HEAD, OPTIONS"
+)
+
+type apiPayload struct {
+	API     string                 `json:"api"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+type errorPayload struct {
+	Error string `json:"error"`
+}
+
+type batchResult struct {
+	API      string              `json:"api"`
+	Status   int                 `json:"status"`
+	Response jsoniter.RawMessage `json:"response"`
+}
+
+type batchPayload struct {
+	Results []batchResult `json:"results"`
+}
+
+type healthPayload struct {
+	Project string `json:"project"`
+	DB      string `json:"db_name"`
+	Events  int    `json:"events"`
+}
+
+// Background calculation states
+const (
+	bgRunning = "running"
+	bgQueued  = "queued"
+)
+
+// bgJob - background calc_metric calculation requested with BG=1
+type bgJob struct {
+	Project   string     `json:"project"`
+	DB        string     `json:"db"`
+	API       string     `json:"api"`
+	Metric    string     `json:"metric"`
+	Period    string     `json:"period"`
+	Repos     bool       `json:"repos"`
+	State     string     `json:"state"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at"`
+	run       func()
+}
+
+type bgStatusPayload struct {
+	MaxRunning int     `json:"max_running"`
+	MaxQueued  int     `json:"max_queued"`
+	Running    []bgJob `json:"running"`
+	Queued     []bgJob `json:"queued"`
+}
+
+type listAPIsPayload struct {
+	APIs []string `json:"apis"`
+}
+
+type optionsPayload struct {
+	Methods []string `json:"methods"`
+	APIs    []string `json:"apis,omitempty"`
+}
+
+type versionPayload struct {
+	BuildTime     string    `json:"build_time"`
+	GitSHA        string    `json:"git_sha"`
+	GoVersion     string    `json:"go_version"`
+	BuildHost     string    `json:"build_host"`
+	Projects      int       `json:"projects"`
+	StartedAt     time.Time `json:"started_at"`
+	Uptime        string    `json:"uptime"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+type listProjectsPayload struct {
+	Projects []string `json:"projects"`
+}
+
+type eventsPayload struct {
+	Project    string      `json:"project"`
+	DB         string      `json:"db_name"`
+	TimeStamps []time.Time `json:"timestamps"`
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Values     []int64     `json:"values"`
+}
+
+type siteStatsPayload struct {
+	Project       string `json:"project"`
+	DB            string `json:"db_name"`
+	Contributors  int64  `json:"contributors"`
+	Contributions int64  `json:"contributions"`
+	BOC           int64  `json:"boc"`
+	Committers    int64  `json:"committers"`
+	Commits       int64  `json:"commits"`
+	Events        int64  `json:"events"`
+	Forkers       int64  `json:"forkers"`
+	Repositories  int64  `json:"repositories"`
+	Stargazers    int64  `json:"stargazers"`
+	Countries     int64  `json:"countries"`
+	Companies     int64  `json:"companies"`
+}
+
+type siteStatsCacheEntry struct {
+	dt        time.Time
+	siteStats siteStatsPayload
+}
+
+var (
+	siteStatsCache    = map[[2]string]siteStatsCacheEntry{}
+	siteStatsCacheMtx = &sync.Mutex{}
+)
+
+type repoStatsPayload struct {
+	Project         string `json:"project"`
+	DB              string `json:"db_name"`
+	Repository      string `json:"repository"`
+	RepositoryGroup string `json:"repository_group"`
+	Contributors    int64  `json:"contributors"`
+	Contributions   int64  `json:"contributions"`
+	BOC             int64  `json:"boc"`
+	Committers      int64  `json:"committers"`
+	Commits         int64  `json:"commits"`
+	Events          int64  `json:"events"`
+	Forkers         int64  `json:"forkers"`
+	Repositories    int64  `json:"repositories"`
+	Stargazers      int64  `json:"stargazers"`
+	Countries       int64  `json:"countries"`
+	Companies       int64  `json:"companies"`
+}
+
+type repoStatsCacheEntry struct {
+	dt        time.Time
+	repoStats repoStatsPayload
+}
+
+// repoStatsQuery - single row query returning some of RepoStats counters
+type repoStatsQuery struct {
+	query  string
+	values []*int64
+}
+
+var (
+	repoStatsCache    = map[[4]string]repoStatsCacheEntry{}
+	repoStatsCacheMtx = &sync.Mutex{}
+)
+
+type countriesStatsPayload struct {
+	Project         string   `json:"project"`
+	DB              string   `json:"db_name"`
+	From            string   `json:"from"`
+	To              string   `json:"to"`
+	RepositoryGroup string   `json:"repository_group"`
+	Countries       []string `json:"countries"`
+	CountryCodes    []string `json:"country_codes"`
+	Contributors    []int64  `json:"contributors"`
+	Contributions   []int64  `json:"contributions"`
+}
+
+type countriesStatsCacheEntry struct {
+	dt             time.Time
+	countriesStats countriesStatsPayload
+}
+
+var (
+	countriesStatsCache    = map[[5]string]countriesStatsCacheEntry{}
+	countriesStatsCacheMtx = &sync.Mutex{}
+)
+
+type dataQualityPayload struct {
+	Project    string      `json:"project"`
+	DB         string      `json:"db_name"`
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Indicators []string    `json:"indicators"`
+	Indicator  []string    `json:"indicator"`
+	TimeStamps []time.Time `json:"timestamps"`
+	Values     []float64   `json:"values"`
+}
+
+type companiesTablePayload struct {
+	Project string                  `json:"project"`
+	DB      string                  `json:"db_name"`
+	Range   string                  `json:"range"`
+	Metric  string                  `json:"metric"`
+	Rank    []int                   `json:"rank"`
+	Company []string                `json:"company"`
+	Number  []float64               `json:"number"`
+	Bucket  string                  `json:"bucket,omitempty"`
+	Buckets []companiesTableBuckets `json:"buckets,omitempty"`
+}
+
+// companiesTableBuckets - ranked companies of a single CompaniesTable bucket (quarter or year)
+type companiesTableBuckets struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Rank    []int     `json:"rank"`
+	Company []string  `json:"company"`
+	Number  []float64 `json:"number"`
+}
+
+type companyProfilePayload struct {
+	Project                  string      `json:"project"`
+	DB                       string      `json:"db_name"`
+	Range                    string      `json:"range"`
+	Company                  string      `json:"company"`
+	Metric                   []string    `json:"metric"`
+	Rank                     []int       `json:"rank"`
+	Number                   []float64   `json:"number"`
+	ActiveDevelopers         int         `json:"active_developers"`
+	TopContributors          []string    `json:"top_contributors"`
+	TopContributions         []int       `json:"top_contributions"`
+	RepositoryGroups         []string    `json:"repository_groups"`
+	RepositoryGroupsContribs []float64   `json:"repository_groups_contributions"`
+	Quarters                 []time.Time `json:"quarters"`
+	QuartersContribs         []float64   `json:"quarters_contributions"`
+}
+
+type ciStatsPayload struct {
+	Project            string    `json:"project"`
+	DB                 string    `json:"db_name"`
+	From               string    `json:"from"`
+	To                 string    `json:"to"`
+	Repository         string    `json:"repository,omitempty"`
+	Runs               int       `json:"runs"`
+	SuccessRate        float64   `json:"success_rate"`
+	AvgDuration        float64   `json:"avg_duration_seconds"`
+	Repositories       []string  `json:"repositories"`
+	RepositoriesRuns   []int     `json:"repositories_runs"`
+	RepositoriesRate   []float64 `json:"repositories_success_rate"`
+	RepositoriesAvgDur []float64 `json:"repositories_avg_duration_seconds"`
+}
+
+type trafficPayload struct {
+	Project       string      `json:"project"`
+	DB            string      `json:"db_name"`
+	From          string      `json:"from"`
+	To            string      `json:"to"`
+	Repository    string      `json:"repository,omitempty"`
+	Clones        int         `json:"clones"`
+	Views         int         `json:"views"`
+	Days          []time.Time `json:"days"`
+	DaysClones    []int       `json:"days_clones"`
+	DaysClonesUnq []int       `json:"days_clones_uniques"`
+	DaysViews     []int       `json:"days_views"`
+	DaysViewsUnq  []int       `json:"days_views_uniques"`
+}
+
+type milestoneProgress struct {
+	Title        string      `json:"title"`
+	State        string      `json:"state"`
+	DueOn        *time.Time  `json:"due_on"`
+	Repositories int         `json:"repositories"`
+	Dts          []time.Time `json:"dts"`
+	OpenIssues   []int       `json:"open_issues"`
+	ClosedIssues []int       `json:"closed_issues"`
+}
+
+type milestoneProgressPayload struct {
+	Project    string              `json:"project"`
+	DB         string              `json:"db_name"`
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	Repository string              `json:"repository,omitempty"`
+	Milestone  string              `json:"milestone,omitempty"`
+	Milestones []milestoneProgress `json:"milestones"`
+}
+
+type labelLifecyclePayload struct {
+	Project           string    `json:"project"`
+	DB                string    `json:"db_name"`
+	From              string    `json:"from"`
+	To                string    `json:"to"`
+	Repository        string    `json:"repository,omitempty"`
+	Labels            []string  `json:"labels"`
+	Periods           []int64   `json:"periods"`
+	Issues            []int64   `json:"issues"`
+	StillApplied      []int64   `json:"still_applied"`
+	AvgHours          []float64 `json:"avg_hours"`
+	MedianHours       []float64 `json:"median_hours"`
+	Percentile85Hours []float64 `json:"percentile_85_hours"`
+}
+
+type releaseStatsPayload struct {
+	Project                   string     `json:"project"`
+	DB                        string     `json:"db_name"`
+	From                      string     `json:"from"`
+	To                        string     `json:"to"`
+	RepositoryGroup           string     `json:"repository_group"`
+	Releases                  int64      `json:"releases"`
+	Prereleases               int64      `json:"prereleases"`
+	PrereleaseRatio           float64    `json:"prerelease_ratio"`
+	AvgDaysBetween            *float64   `json:"avg_days_between"`
+	MedianLeadTimeDays        *float64   `json:"median_lead_time_days"`
+	Quarters                  []string   `json:"quarters"`
+	QuarterReleases           []int64    `json:"quarter_releases"`
+	QuarterPrereleases        []int64    `json:"quarter_prereleases"`
+	QuarterAvgDaysBetween     []*float64 `json:"quarter_avg_days_between"`
+	QuarterMedianLeadTimeDays []*float64 `json:"quarter_median_lead_time_days"`
+}
+
+type contributorRetentionPayload struct {
+	Project         string       `json:"project"`
+	DB              string       `json:"db_name"`
+	From            string       `json:"from"`
+	To              string       `json:"to"`
+	RepositoryGroup string       `json:"repository_group"`
+	Quarters        int          `json:"quarters"`
+	Cohorts         []string     `json:"cohorts"`
+	Contributors    []int64      `json:"contributors"`
+	Retained        [][]*int64   `json:"retained"`
+	Retention       [][]*float64 `json:"retention"`
+}
+
+type forkActivityPayload struct {
+	Project      string      `json:"project"`
+	DB           string      `json:"db_name"`
+	Repository   string      `json:"repository,omitempty"`
+	Forks        int         `json:"forks"`
+	ForksAhead   int         `json:"forks_ahead"`
+	CommitsAhead int         `json:"commits_ahead"`
+	Repos        []string    `json:"repos"`
+	ForkNames    []string    `json:"fork_names"`
+	Branches     []string    `json:"branches"`
+	PushedAt     []time.Time `json:"pushed_at"`
+	Stargazers   []int       `json:"stargazers"`
+	AheadBy      []int       `json:"ahead_by"`
+	BehindBy     []int       `json:"behind_by"`
+}
+
+type mentionGraphPayload struct {
+	Project       string   `json:"project"`
+	DB            string   `json:"db_name"`
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Repository    string   `json:"repository,omitempty"`
+	Mentions      int64    `json:"mentions"`
+	Actors        int64    `json:"actors"`
+	Mentioned     int64    `json:"mentioned"`
+	IssueRefs     int64    `json:"issue_refs"`
+	CrossRepoRefs int64    `json:"cross_repo_refs"`
+	FromLogins    []string `json:"from_logins"`
+	ToLogins      []string `json:"to_logins"`
+	Weights       []int64  `json:"weights"`
+}
+
+type projectFeaturesPayload struct {
+	Projects []string   `json:"projects"`
+	DB       []string   `json:"db_name"`
+	Features [][]string `json:"features"`
+}
+
+type syncStatusPayload struct {
+	Projects       []string     `json:"projects"`
+	DB             []string     `json:"db_name"`
+	LastGHAHour    []*time.Time `json:"last_gha_hour"`
+	LastCalcMetric []*time.Time `json:"last_calc_metric"`
+	LagMinutes     []*int64     `json:"lag_minutes"`
+	Stale          []bool       `json:"stale"`
+	StaleMinutes   int64        `json:"stale_minutes"`
+}
+
+// forkActivityDays - ForkActivity only returns forks pushed within this number of days (ghapi2db syncs forks active in the last 90 days)
+const forkActivityDays = 90
+
+// defaultMentionGraphLimit - MentionGraph returns at most this number of edges, unless 'limit' is given
+const defaultMentionGraphLimit = 100
+
+// defaultTagCloudLimit - TagCloud returns at most this number of labels, unless 'limit' is given
+const defaultTagCloudLimit = 50
+
+// tagCloudKinds - TagCloud 'kind' values and is_pull_request value of issues they select (empty means all)
+var tagCloudKinds = map[string]string{
+	"all":    "",
+	"issues": "false",
+	"prs":    "true",
+}
+
+// maxCompaniesTableBuckets - maximum number of CompaniesTable buckets, each bucket is computed as a separate manual range
+const maxCompaniesTableBuckets = 40
+
+// defaultStaleMinutes - SyncStatus project is stale when its last GHA hour is older than this, unless 'stale_minutes' is given
+const defaultStaleMinutes = 180
+
+type prSizeDistributionPayload struct {
+	Project          string      `json:"project"`
+	DB               string      `json:"db_name"`
+	From             string      `json:"from"`
+	To               string      `json:"to"`
+	Period           string      `json:"period"`
+	RepositoryGroup  string      `json:"repository_group"`
+	Buckets          []string    `json:"buckets"`
+	Periods          []time.Time `json:"periods"`
+	PRs              []int64     `json:"prs"`
+	BucketsPRs       [][]int64   `json:"buckets_prs"`
+	Merged           []int64     `json:"merged"`
+	MergeRate        []float64   `json:"merge_rate"`
+	MedianMergeHours []float64   `json:"median_time_to_merge_hours"`
+}
+
+type renamedOrDeletedReposPayload struct {
+	Project     string      `json:"project"`
+	DB          string      `json:"db_name"`
+	Repos       []string    `json:"repos"`
+	IDs         []int64     `json:"ids"`
+	NotFound    []int       `json:"not_found"`
+	LastChecked []time.Time `json:"last_checked"`
+	OtherNames  [][]string  `json:"other_names"`
+}
+
+type ingestStatsPayload struct {
+	Project    string      `json:"project"`
+	DB         string      `json:"db_name"`
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	EventType  string      `json:"event_type"`
+	Hours      []time.Time `json:"hours"`
+	Events     []int64     `json:"events"`
+	Matched    []int64     `json:"matched"`
+	Written    []int64     `json:"written"`
+	Skewed     []int64     `json:"skewed"`
+	Duplicates []int64     `json:"duplicates"`
+	Excluded   []int64     `json:"excluded"`
+}
+
+// certificate - contribution totals and rank of a GitHub user in a project in a given date range
+// Signature is computed from this struct JSON encoding, so fields order must not change
+type certificate struct {
+	Project  string `json:"project"`
+	GitHubID string `json:"github_id"`
+	Metric   string `json:"metric"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Number   int    `json:"number"`
+	Rank     *int   `json:"rank"`
+	Of       int    `json:"of"`
+	IssuedAt string `json:"issued_at"`
+}
+
+type certificatePayload struct {
+	Certificate certificate `json:"certificate"`
+	Algorithm   string      `json:"algorithm"`
+	Signature   string      `json:"signature"`
+}
+
+type verifyCertificatePayload struct {
+	Valid bool `json:"valid"`
+}
+
+type comContribRepoGrpPayload struct {
+	Project              string      `json:"project"`
+	DB                   string      `json:"db_name"`
+	Period               string      `json:"period"`
+	RepositoryGroup      string      `json:"repository_group"`
+	Companies            []float64   `json:"companies"`
+	Developers           []float64   `json:"developers"`
+	CompaniesTimestamps  []time.Time `json:"companies_timestamps"`
+	DevelopersTimestamps []time.Time `json:"developers_timestamps"`
+}
+
+// devActCntUser - rank and number of a single login returned in DevActCnt/DevActCntComp github_ids mode
+// Rank is null for logins without activity in the range
+type devActCntUser struct {
+	Rank    *int   `json:"rank"`
+	Number  int    `json:"number"`
+	Company string `json:"company,omitempty"`
+}
+
+type devActCntPayload struct {
+	Project           string                    `json:"project"`
+	DB                string                    `json:"db_name"`
+	Range             string                    `json:"range"`
+	Metric            string                    `json:"metric"`
+	RepositoryGroup   string                    `json:"repository_group"`
+	Country           string                    `json:"country"`
+	GitHubID          string                    `json:"github_id"`
+	Filter            string                    `json:"filter"`
+	Rank              []*int                    `json:"rank"`
+	Login             []string                  `json:"login"`
+	Number            []int                     `json:"number"`
+	GitHubIDs         map[string]*devActCntUser `json:"github_ids,omitempty"`
+	NoActivityInRange bool                      `json:"no_activity_in_range,omitempty"`
+	Score             []float64                 `json:"score,omitempty"`
+	Breakdown         []map[string]float64      `json:"breakdown,omitempty"`
+	Weights           map[string]float64        `json:"weights,omitempty"`
+}
+
+// scoreConfig - DevActCnt "score" metric weights (metric value or name -> weight), from metrics/{{project}}/score.yaml
+type scoreConfig struct {
+	Weights map[string]float64 `yaml:"weights"`
+}
+
+// scoreMetric - DevActCnt metric computed as a weighted sum of other metrics
+const scoreMetric = "score"
+
+// defaultScoreWeights - used when neither project nor shared score.yaml exists, metrics not available in a project are skipped
+var defaultScoreWeights = map[string]float64{
+	"commits":         1,
+	"prs":             2,
+	"reviews":         2,
+	"issues":          1,
+	"review_comments": 0.5,
+	"issue_comments":  0.5,
+}
+
+type devActDistributionPayload struct {
+	Project            string   `json:"project"`
+	DB                 string   `json:"db_name"`
+	Range              string   `json:"range"`
+	Metric             string   `json:"metric"`
+	RepositoryGroup    string   `json:"repository_group"`
+	Country            string   `json:"country"`
+	Filter             string   `json:"filter"`
+	Buckets            []string `json:"buckets"`
+	Contributors       []int64  `json:"contributors"`
+	Contributions      []int64  `json:"contributions"`
+	TotalContributors  int64    `json:"total_contributors"`
+	TotalContributions int64    `json:"total_contributions"`
+	HalfContributions  int64    `json:"top_contributors_50"`
+}
+
+type devActDiffPayload struct {
+	Project         string   `json:"project"`
+	DB              string   `json:"db_name"`
+	Range           string   `json:"range"`
+	PreviousRange   string   `json:"previous_range"`
+	Metric          string   `json:"metric"`
+	RepositoryGroup string   `json:"repository_group"`
+	Country         string   `json:"country"`
+	Sort            string   `json:"sort"`
+	Filter          string   `json:"filter"`
+	Login           []string `json:"login"`
+	Number          []int    `json:"number"`
+	PreviousNumber  []int    `json:"previous_number"`
+	Delta           []int    `json:"delta"`
+	Rank            []*int   `json:"rank"`
+	PreviousRank    []*int   `json:"previous_rank"`
+	RankChange      []*int   `json:"rank_change"`
+}
+
+type busFactorPayload struct {
+	Project              string   `json:"project"`
+	DB                   string   `json:"db_name"`
+	Range                string   `json:"range"`
+	Metric               string   `json:"metric"`
+	RepositoryGroup      string   `json:"repository_group"`
+	Percent              int      `json:"percent"`
+	Filter               string   `json:"filter"`
+	BusFactor            int      `json:"bus_factor"`
+	TotalContributors    int64    `json:"total_contributors"`
+	TotalContributions   int64    `json:"total_contributions"`
+	CoveredContributions int64    `json:"covered_contributions"`
+	Logins               []string `json:"logins"`
+	Companies            []string `json:"companies"`
+	Contributions        []int64  `json:"contributions"`
+}
+
+type linkedWorkPayload struct {
+	Project           string   `json:"project"`
+	DB                string   `json:"db_name"`
+	From              string   `json:"from"`
+	To                string   `json:"to"`
+	Repository        string   `json:"repository,omitempty"`
+	MergedPRs         int64    `json:"merged_prs"`
+	LinkedPRs         int64    `json:"linked_prs"`
+	LinkedRatio       float64  `json:"linked_ratio"`
+	LinkedIssues      int64    `json:"linked_issues"`
+	BodyLinks         int64    `json:"body_links"`
+	TimelineLinks     int64    `json:"timeline_links"`
+	AvgHours          *float64 `json:"avg_hours"`
+	MedianHours       *float64 `json:"median_hours"`
+	Percentile85Hours *float64 `json:"percentile_85_hours"`
+}
+
+type tagCloudPayload struct {
+	Project         string     `json:"project"`
+	DB              string     `json:"db_name"`
+	From            string     `json:"from"`
+	To              string     `json:"to"`
+	RepositoryGroup string     `json:"repository_group"`
+	Kind            string     `json:"kind"`
+	QuarterFrom     string     `json:"quarter_from"`
+	PrevQuarterFrom string     `json:"previous_quarter_from"`
+	Labels          []string   `json:"labels"`
+	Issues          []int64    `json:"issues"`
+	Quarter         []int64    `json:"quarter"`
+	PrevQuarter     []int64    `json:"previous_quarter"`
+	Trend           []*float64 `json:"trend"`
+}
+
+type velocityPayload struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Rank     []int    `json:"rank"`
+	Project  []string `json:"project"`
+	DB       []string `json:"db_name"`
+	Repo     []string `json:"repo"`
+	Activity []int64  `json:"activity"`
+	Comments []int64  `json:"comments"`
+	PRs      []int64  `json:"prs"`
+	Commits  []int64  `json:"commits"`
+	Issues   []int64  `json:"issues"`
+	Authors  []int64  `json:"authors"`
+	Pushes   []int64  `json:"pushes"`
+}
+
+type devActCntReposPayload struct {
+	Project           string                    `json:"project"`
+	DB                string                    `json:"db_name"`
+	Range             string                    `json:"range"`
+	Metric            string                    `json:"metric"`
+	Repository        string                    `json:"repository"`
+	Country           string                    `json:"country"`
+	GitHubID          string                    `json:"github_id"`
+	Filter            string                    `json:"filter"`
+	Rank              []*int                    `json:"rank"`
+	Login             []string                  `json:"login"`
+	Number            []int                     `json:"number"`
+	GitHubIDs         map[string]*devActCntUser `json:"github_ids,omitempty"`
+	NoActivityInRange bool                      `json:"no_activity_in_range,omitempty"`
+}
+
+type devActCntCompPayload struct {
+	Project          string                    `json:"project"`
+	DB               string                    `json:"db_name"`
+	Range            string                    `json:"range"`
+	Metric           string                    `json:"metric"`
+	RepositoryGroup  string                    `json:"repository_group"`
+	Country          string                    `json:"country"`
+	Companies        []string                  `json:"companies"`
+	ExcludeCompanies []string                  `json:"exclude_companies"`
+	IndependentOnly  bool                      `json:"independent_only"`
+	GitHubID         string                    `json:"github_id"`
+	Rank             []int                     `json:"rank"`
+	Login            []string                  `json:"login"`
+	Company          []string                  `json:"company"`
+	Number           []int                     `json:"number"`
+	GitHubIDs        map[string]*devActCntUser `json:"github_ids,omitempty"`
+}
+
+type devActCntCompReposPayload struct {
+	Project          string                    `json:"project"`
+	DB               string                    `json:"db_name"`
+	Range            string                    `json:"range"`
+	Metric           string                    `json:"metric"`
+	Repository       string                    `json:"repository"`
+	Country          string                    `json:"country"`
+	Companies        []string                  `json:"companies"`
+	ExcludeCompanies []string                  `json:"exclude_companies"`
+	IndependentOnly  bool                      `json:"independent_only"`
+	GitHubID         string                    `json:"github_id"`
+	Rank             []int                     `json:"rank"`
+	Login            []string                  `json:"login"`
+	Company          []string                  `json:"company"`
+	Number           []int                     `json:"number"`
+	GitHubIDs        map[string]*devActCntUser `json:"github_ids,omitempty"`
+}
+
+type comStatsRepoGrpPayload struct {
+	Project         string               `json:"project"`
+	DB              string               `json:"db_name"`
+	Period          string               `json:"period"`
+	Metric          string               `json:"metric"`
+	RepositoryGroup string               `json:"repository_group"`
+	Companies       []string             `json:"companies"`
+	From            string               `json:"from"`
+	To              string               `json:"to"`
+	Values          []map[string]float64 `json:"values"`
+	Timestamps      []time.Time          `json:"timestamps"`
+}
+
+type seriesQueryPayload struct {
+	Project    string               `json:"project"`
+	DB         string               `json:"db_name"`
+	Metric     string               `json:"metric"`
+	Period     string               `json:"period"`
+	From       string               `json:"from"`
+	To         string               `json:"to"`
+	Args       map[string]string    `json:"args,omitempty"`
+	Series     string               `json:"series"`
+	Values     []map[string]float64 `json:"values"`
+	Timestamps []time.Time          `json:"timestamps"`
+}
+
+type repoGroupsPayload struct {
+	Project    string   `json:"project"`
+	DB         string   `json:"db_name"`
+	RepoGroups []string `json:"repo_groups"`
+}
+
+type companiesPayload struct {
+	Project   string   `json:"project"`
+	DB        string   `json:"db_name"`
+	Companies []string `json:"companies"`
+}
+
+type rangesPayload struct {
+	Project string   `json:"project"`
+	DB      string   `json:"db_name"`
+	Ranges  []string `json:"ranges"`
+}
+
+type countriesPayload struct {
+	Project   string   `json:"project"`
+	DB        string   `json:"db_name"`
+	Lang      string   `json:"lang,omitempty"`
+	Countries []string `json:"countries"`
+	Codes     []string `json:"codes"`
+}
+
+type reposPayload struct {
+	Project    string   `json:"project"`
+	DB         string   `json:"db_name"`
+	RepoGroups []string `json:"repo_groups"`
+	Repos      []string `json:"repos"`
+}
+
+func returnError(apiName string, w http.ResponseWriter, err error) {
+	returnErrorStatus(apiName, w, http.StatusBadRequest, err)
+}
+
+// returnErrorStatus - returns JSON error payload with a given HTTP status
+func returnErrorStatus(apiName string, w http.ResponseWriter, status int, err error) {
+	errStr := err.Error()
+	if !strings.HasPrefix(errStr, "API '") {
+		errStr = "API '" + apiName + "': " + errStr
+	}
+	lib.Printf(errStr + "\n")
+	epl := errorPayload{Error: errStr}
+	w.Header().Del("ETag")
+	w.WriteHeader(status)
+	jsoniter.NewEncoder(w).Encode(epl)
+}
+
+func timeParseAny(dtStr string) (time.Time, error) {
+	return timeParseAnyIn(dtStr, time.UTC)
+}
+
+// timeParseAnyIn - parses datetime in a given time zone and returns it in UTC
+// Datetimes with explicit "Z" suffix are always UTC
+func timeParseAnyIn(dtStr string, loc *time.Location) (time.Time, error) {
+	formats := []string{
+		"2006-01-02T15:04:05Z",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02 15",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	}
+	for _, format := range formats {
+		floc := loc
+		if strings.HasSuffix(format, "Z") {
+			floc = time.UTC
+		}
+		t, e := time.ParseInLocation(format, dtStr, floc)
+		if e == nil {
+			return t.UTC(), nil
+		}
+	}
+	err := fmt.Errorf("cannot parse datetime: '%s'", dtStr)
+	return time.Now(), err
+}
+
+// getTZParam - returns time zone from optional 'tz' payload param (IANA name, for example 'Europe/Warsaw'), UTC when not specified
+func getTZParam(w http.ResponseWriter, payload map[string]interface{}) (loc *time.Location, tz string, err error) {
+	tz, err = getPayloadStringParam("tz", w, payload, true)
+	if err != nil {
+		return
+	}
+	if tz == "" {
+		loc = time.UTC
+		return
+	}
+	loc, err = time.LoadLocation(tz)
+	if err != nil {
+		err = fmt.Errorf("invalid tz value: '%s': %v", tz, err)
+	}
+	return
+}
+
+// getTimeRangeParams - parses 'from' and 'to' params in time zone given by optional 'tz' param
+// Returns UTC datetimes to be used in queries
+func getTimeRangeParams(w http.ResponseWriter, payload map[string]interface{}, params map[string]string) (from, to string, err error) {
+	loc, _, err := getTZParam(w, payload)
+	if err != nil {
+		return
+	}
+	dtFrom, err := timeParseAnyIn(params["from"], loc)
+	if err != nil {
+		return
+	}
+	dtTo, err := timeParseAnyIn(params["to"], loc)
+	if err != nil {
+		return
+	}
+	from, to = lib.ToYMDHMSDate(dtFrom), lib.ToYMDHMSDate(dtTo)
+	return
+}
+
+func nameToDB(name string) (db string, err error) {
+	gMtx.RLock()
+	db, ok := gNameToDB[name]
+	gMtx.RUnlock()
+	if !ok {
+		err = fmt.Errorf("database not found for project '%s'", name)
+	}
+	return
+}
+
+func getContextAndDB(w http.ResponseWriter, db string) (ctx *lib.Ctx, c *sql.DB, err error) {
+	var lctx lib.Ctx
+	lctx.Init()
+	lctx.PgHost = os.Getenv("PG_HOST_RO")
+	lctx.PgUser = os.Getenv("PG_USER_RO")
+	lctx.PgPass = os.Getenv("PG_PASS_RO")
+	lctx.PgDB = db
+	lctx.ExecFatal = false
+	lctx.ExecOutput = true
+	lctx.Trace = requestTrace(w)
+	gMtx.RLock()
+	sharded := gSharded[db]
+	gMtx.RUnlock()
+	if sharded {
+		c, err = lib.PgConnShardsReadErr(&lctx)
+	} else {
+		c, err = lib.PgConnErr(&lctx)
+	}
+	if err != nil {
+		return
+	}
+	ctx = &lctx
+	return
+}
+
+func handleSharedPayload(w http.ResponseWriter, payload map[string]interface{}) (project, db string, err error) {
+	if len(payload) == 0 {
+		err = fmt.Errorf("'payload' section empty or missing")
+		return
+	}
+	iproject, ok := payload["project"]
+	if !ok {
+		err = fmt.Errorf("missing 'project' field in 'payload' section")
+		return
+	}
+	project, ok = iproject.(string)
+	if !ok {
+		err = fmt.Errorf("'payload' 'project' field '%+v' is not a string", iproject)
+		return
+	}
+	db, err = nameToDB(project)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func getPayloadStringParam(paramName string, w http.ResponseWriter, payload map[string]interface{}, optional bool) (param string, err error) {
+	iparam, ok := payload[paramName]
+	if !ok {
+		if optional {
+			return
+		}
+		err = fmt.Errorf("missing '%s' field in 'payload' section (optional %v)", paramName, optional)
+		return
+	}
+	param, ok = iparam.(string)
+	if !ok {
+		err = fmt.Errorf("'payload' '%s' field '%+v'/%T is not a string (optional %v)", paramName, iparam, iparam, optional)
+		return
+	}
+	return
+}
+
+func getPayloadStringArrayParam(paramName string, w http.ResponseWriter, payload map[string]interface{}, optional, allowEmpty bool) (param []string, err error) {
+	iparam, ok := payload[paramName]
+	if !ok {
+		if optional {
+			return
+		}
+		err = fmt.Errorf("missing '%s' field in 'payload' section (optional %v, allow empty %v)", paramName, optional, allowEmpty)
+		return
+	}
+	iary, ok := iparam.([]interface{})
+	if !ok {
+		err = fmt.Errorf("'payload' '%s' field '%+v'/%T is not an array (optional %v, allow empty %v)", paramName, iparam, iparam, optional, allowEmpty)
+		return
+	}
+	for idx, item := range iary {
+		s, ok := item.(string)
+		if !ok {
+			err = fmt.Errorf("'payload' '%s' field '%+v' #%d item '%+v'/%T is not a string (optional %v, allow empty %v)", paramName, iary, idx+1, item, item, optional, allowEmpty)
+		}
+		param = append(param, s)
+	}
+	if !allowEmpty && len(param) == 0 {
+		err = fmt.Errorf("'payload' '%s' field '%+v' cannot be empty (optional %v, allow empty %v)", paramName, param, optional, allowEmpty)
+		return
+	}
+	return
+}
+
+// getPayloadBoolParam - returns optional boolean param, for example "pretty": true
+func getPayloadBoolParam(paramName string, w http.ResponseWriter, payload map[string]interface{}) (param bool, err error) {
+	iparam, ok := payload[paramName]
+	if !ok {
+		return
+	}
+	param, ok = iparam.(bool)
+	if !ok {
+		err = fmt.Errorf("'payload' '%s' field '%+v'/%T is not a boolean", paramName, iparam, iparam)
+		return
+	}
+	return
+}
+
+// getPayloadStringMapParam - returns optional object param with string values, for example metric parameters
+func getPayloadStringMapParam(paramName string, w http.ResponseWriter, payload map[string]interface{}) (param map[string]string, err error) {
+	iparam, ok := payload[paramName]
+	if !ok {
+		return
+	}
+	imap, ok := iparam.(map[string]interface{})
+	if !ok {
+		err = fmt.Errorf("'payload' '%s' field '%+v'/%T is not an object", paramName, iparam, iparam)
+		return
+	}
+	param = make(map[string]string)
+	for key, item := range imap {
+		s, ok := item.(string)
+		if !ok {
+			err = fmt.Errorf("'payload' '%s' field '%+v' key '%s' value '%+v'/%T is not a string", paramName, imap, key, item, item)
+			return
+		}
+		param[key] = s
+	}
+	return
+}
+
+// periodNameToValue - returns period value for a given period name, manual "range:from,to" dates are parsed in loc time zone and stored in UTC
+// Manual range dates are snapped to loc day boundaries (from down, to up)
+func periodNameToValue(c *sql.DB, ctx *lib.Ctx, periodName string, allowManual bool, loc *time.Location) (periodValue string, manual bool, err error) {
+	if allowManual && strings.HasPrefix(periodName, "range:") {
+		ary := strings.Split(periodName[6:], ",")
+		if len(ary) != 2 {
+			err = fmt.Errorf("range should be specified as 'range:YYYY[-MM[-DD [HH[-MM[-SS]]]]],YYYY[-MM[-DD [HH[-MM[-SS]]]]]'")
+			return
+		}
+		from, e := timeParseAnyIn(ary[0], loc)
+		if e != nil {
+			err = e
+			return
+		}
+		to, e := timeParseAnyIn(ary[1], loc)
+		if e != nil {
+			err = e
+			return
+		}
+		// Snap to day boundaries, so close requests share the same (already computed) period
+		maxDt := lib.DayStart(time.Now().AddDate(0, 0, -1))
+		from, to = lib.NormalizeManualRange(from, to, maxDt, loc)
+		sFrom, sTo := lib.ToYMDHMSDate(from), lib.ToYMDHMSDate(to)
+		if from.After(maxDt) || to.After(maxDt) || !from.Before(to) {
+			err = fmt.Errorf("from (%s) and to (%s) dates must not be after %v, from date must be before to date", sFrom, sTo, maxDt)
+			return
+		}
+		periodValue = "range:" + sFrom + "," + sTo
+		manual = true
+		return
+	}
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select quick_ranges_suffix from tquick_ranges where quick_ranges_name = $1", periodName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&periodValue)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	if periodValue == "" {
+		err = fmt.Errorf("invalid period name: '%s'", periodName)
+	}
+	return
+}
+
+// periodBounds - returns dates range of a manual period value ("range:from,to") or of a quick range name
+// Quick ranges are either fixed (annotations, CNCF dates) or relative to the current day ("Last year")
+func periodBounds(c *sql.DB, ctx *lib.Ctx, periodName, periodValue string) (from, to time.Time, err error) {
+	if strings.HasPrefix(periodValue, "range:") {
+		ary := strings.Split(periodValue[6:], ",")
+		from, to = lib.TimeParseAny(ary[0]), lib.TimeParseAny(ary[1])
+		return
+	}
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select quick_ranges_data from tquick_ranges where quick_ranges_name = $1", periodName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	data := ""
+	for rows.Next() {
+		err = rows.Scan(&data)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	// data is suffix;interval;from;to
+	ary := strings.Split(data, ";")
+	if len(ary) != 4 {
+		err = fmt.Errorf("invalid quick range '%s' data: '%s'", periodName, data)
+		return
+	}
+	if ary[2] != "" && ary[3] != "" {
+		from, to = lib.TimeParseAny(ary[2]), lib.TimeParseAny(ary[3])
+		return
+	}
+	to = lib.NextDayStart(time.Now())
+	err = lib.QueryRowSQL(c, ctx, "select $1::timestamp - $2::interval", to, ary[1]).Scan(&from)
+	return
+}
+
+// metricParamsDefs - returns parameters defined in project metrics.yaml for a metric using a given SQL file
+func metricParamsDefs(ctx *lib.Ctx, project, sqlName string) (defs []lib.MetricParam, err error) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	path := dataPrefix + "metrics/" + project + "/metrics.yaml"
+	if _, e := os.Stat(path); os.IsNotExist(e) {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var allMetrics lib.AllMetrics
+	err = yaml.Unmarshal(data, &allMetrics)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+		return
+	}
+	for _, metric := range allMetrics.Metrics {
+		if metric.MetricSQL == sqlName && len(metric.Params) > 0 {
+			defs = metric.Params
+			return
+		}
+	}
+	return
+}
+
+// ensureManualData - computes manual range data if it is not computed yet, returns period under which data is stored
+// Metrics with parameters are computed with given values (defaults when none given), non-default values are stored under their own period
+func ensureManualData(c *sql.DB, ctx *lib.Ctx, project, db, apiName, metric, period string, params map[string]string, reposMode, bg bool) (dataPeriod string, err error) {
+	dataPeriod = period
+	file, mode, extra := "", "", ""
+	switch apiName {
+	case lib.CompaniesTable:
+		file, mode = "project_company_stats", "multi_row_single_column"
+	case lib.DevActCnt, lib.DevActCntComp:
+		file, mode = "project_developer_stats", "multi_row_single_column"
+		if metric == "approves" {
+			if db != lib.GHA {
+				err = fmt.Errorf("ensureManualData: approves mode only allowed for kubernetes projectreturn (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+			}
+			file = "hist_approvers"
+		}
+		if metric == "reviews" {
+			if db != lib.GHA {
+				err = fmt.Errorf("ensureManualData: reviews mode only allowed for kubernetes projectreturn (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+			}
+			file = "hist_reviewers"
+		}
+	default:
+		err = fmt.Errorf("ensureManualData: unknown API configuration (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+		return
+	}
+	if file == "" {
+		err = fmt.Errorf("ensureManualData: cannot find manual SQL file for configuration (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+		return
+	}
+	if reposMode {
+		file += "_repos"
+	}
+	// lib.Printf("file detected: %s\n", file)
+	query, series := "", ""
+	var args []interface{}
+	switch file {
+	case "hist_reviewers", "hist_approvers", "project_developer_stats":
+		series = "hdev"
+		extra = "hist,merge_series:" + series
+		query = "select 1 from shdev where period = $1 and series like $2 limit 1"
+		args = []interface{}{period, "hdev_" + metric + "%"}
+	case "project_company_stats":
+		series = "hcom"
+		extra = "hist,merge_series:" + series
+		query = "select 1 from shcom where period = $1 and series = $2 limit 1"
+		args = []interface{}{period, "hcom" + metric}
+	case "hist_reviewers_repos", "hist_approvers_repos", "project_developer_stats_repos":
+		series = "hdev_repos"
+		extra = "hist,merge_series:" + series
+		query = "select 1 from shdev_repos where period = $1 and series like $2 limit 1"
+		args = []interface{}{period, "hdev_" + metric + "%"}
+	default:
+		err = fmt.Errorf("ensureManualData: don't know how to check for existing data for configuration (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+		return
+	}
+	defs, err := metricParamsDefs(ctx, project, file)
+	if err != nil {
+		return
+	}
+	if len(defs) == 0 && len(params) > 0 {
+		err = fmt.Errorf("ensureManualData: metric has no parameters (%s,%s,%s,%s,%s,%v)", project, db, apiName, metric, period, reposMode)
+		return
+	}
+	if len(defs) > 0 {
+		var values map[string]string
+		values, err = lib.MetricParamsValues(defs, params)
+		if err != nil {
+			return
+		}
+		extra += ",params:" + lib.MetricParamsToString(values)
+		if len(params) > 0 {
+			extra += ",params_in_period"
+			dataPeriod = lib.MetricParamsPeriod(period, values)
+			args[0] = dataPeriod
+		}
+	}
+	// Periods computed earlier are recorded by calc_metric, data saved before that is detected by querying series
+	computedAt, err := lib.ManualPeriodComputed(c, ctx, series, file, dataPeriod)
+	if err != nil {
+		return
+	}
+	if computedAt != nil {
+		if ctx.Debug > 0 {
+			lib.Printf("Reusing %s %s %s computed at %v\n", series, file, dataPeriod, *computedAt)
+		}
+		return
+	}
+	file += ".sql"
+	// Projects without their own metric SQL use the shared one
+	path := "/etc/gha2db/metrics/" + project + "/" + file
+	if _, e := os.Stat(path); os.IsNotExist(e) {
+		path = "/etc/gha2db/metrics/shared/" + file
+	}
+	// lib.Printf("query,args: %s,%+v\n", query, args)
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	dummy := 0
+	for rows.Next() {
+		err = rows.Scan(&dummy)
+		if err != nil {
+			return
+		}
+		break
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	// lib.Printf("dummy=%d\n", dummy)
+	if dummy != 0 {
+		return
+	}
+	dtNow := lib.ToYMDHDate(time.Now())
+	// GHA2DB_PROJECT=project calc_metric multi_row_single_column /etc/gha2db/metrics/project/project_developer_stats.sql '2021-08-25 0' '2021-08-25 0' 'range:2021-08-20,2022' 'hist,merge_series:hdev'
+	// range:2021-08-20 00:00:00,2022-01-01 00:00:00
+	calc := func() error {
+		data, err := lib.ExecCommand(
+			ctx,
+			[]string{
+				"calc_metric",
+				mode,
+				path,
+				dtNow,
+				dtNow,
+				period,
+				extra,
+			},
+			map[string]string{
+				"PG_DB":          db,
+				"GHA2DB_PROJECT": project,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		lib.Printf("Calculated manually:\n")
+		lib.Printf("%s", data)
+		return nil
+	}
+	if bg {
+		key := project + file + mode + period + extra
+		job := &bgJob{Project: project, DB: db, API: apiName, Metric: metric, Period: period, Repos: reposMode}
+		job.run = func() {
+			if e := calc(); e != nil {
+				lib.Printf("Background calculation (%s,%s,%s,%s,%s,%v) failed: %v\n", project, db, apiName, metric, period, reposMode, e)
+			}
+		}
+		err = submitBgJob(key, job)
+		return
+	}
+	err = calc()
+	return
+}
+
+// submitBgJob - starts background calculation, or queues it when gMaxBg calculations are already running
+// Only gMaxBgQueue calculations can wait in the queue, the same configuration cannot be running or queued twice
+func submitBgJob(key string, job *bgJob) error {
+	gBgMtx.Lock()
+	defer gBgMtx.Unlock()
+	if j, ok := gBgJobs[key]; ok {
+		return fmt.Errorf("configuration already %s in background (%s,%s,%s,%s,%s,%v)", j.State, j.Project, j.DB, j.API, j.Metric, j.Period, j.Repos)
+	}
+	if gNumBg >= gMaxBg && len(gBgQueue) >= gMaxBgQueue {
+		return fmt.Errorf("too many background calculations: %d running, %d queued", gNumBg, len(gBgQueue))
+	}
+	job.QueuedAt = time.Now()
+	gBgJobs[key] = job
+	if gNumBg < gMaxBg {
+		startBgJob(key, job)
+		return nil
+	}
+	job.State = bgQueued
+	gBgQueue = append(gBgQueue, key)
+	lib.Printf("Background calculation (%s,%s,%s,%s,%s,%v) queued, %d running, %d queued\n", job.Project, job.DB, job.API, job.Metric, job.Period, job.Repos, gNumBg, len(gBgQueue))
+	return nil
+}
+
+// startBgJob - runs background calculation in a goroutine, gBgMtx must be locked
+func startBgJob(key string, job *bgJob) {
+	now := time.Now()
+	job.State = bgRunning
+	job.StartedAt = &now
+	gNumBg++
+	go func() {
+		defer finishBgJob(key)
+		job.run()
+	}()
+}
+
+// finishBgJob - removes finished background calculation and starts queued ones in order they were requested
+func finishBgJob(key string) {
+	gBgMtx.Lock()
+	defer gBgMtx.Unlock()
+	delete(gBgJobs, key)
+	gNumBg--
+	for len(gBgQueue) > 0 && gNumBg < gMaxBg {
+		next := gBgQueue[0]
+		gBgQueue = gBgQueue[1:]
+		startBgJob(next, gBgJobs[next])
+	}
+}
+
+func allRepoGroupNameToValue(c *sql.DB, ctx *lib.Ctx, repoGroupName string) (repoGroupValue string, err error) {
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select all_repo_group_value from tall_repo_groups where all_repo_group_name = $1", repoGroupName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&repoGroupValue)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	if repoGroupValue == "" {
+		err = fmt.Errorf("invalid repository_group name: '%s'", repoGroupName)
+	}
+	return
+}
+
+func repoNameToValue(c *sql.DB, ctx *lib.Ctx, repoName string) (repoValue string, err error) {
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select repo_value from trepos where repo_name = $1", repoName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&repoValue)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	if repoValue == "" {
+		err = fmt.Errorf("invalid repository name: '%s'", repoName)
+	}
+	return
+}
+
+// reposModeCheck - checks if repository mode table exists in the project database
+// trepos comes from tags, shdev_repos from project_developer_stats_repos metric (manual ranges are calculated into it on demand)
+func reposModeCheck(c *sql.DB, ctx *lib.Ctx, project, table string) (err error) {
+	exists, err := tableExists(c, ctx, table)
+	if err != nil {
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("repository mode is not available for project '%s', missing '%s' table", project, table)
+	}
+	return
+}
+
+func allCountryNameToValue(c *sql.DB, ctx *lib.Ctx, countryName string) (countryValue string, err error) {
+	rows, err := lib.QuerySQLLogErr(
+		c,
+		ctx,
+		"select sub.value from (select country_value as value, 0 as ord from tcountries "+
+			"where country_name = $1 union select 'all', 1 as ord) sub order by sub.ord limit 1",
+		countryName,
+	)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&countryValue)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	if countryValue == "" || (countryValue == "all" && countryName != lib.ALL) {
+		err = fmt.Errorf("invalid country name: '%s'", countryName)
+	}
+	return
+}
+
+func getStringTags(c *sql.DB, ctx *lib.Ctx, tag, col string) (values []string, err error) {
+	return searchStringTags(c, ctx, tag, col, "", false, 0)
+}
+
+// getSearchParams - returns optional "query", "prefix" and "limit" params used by searchStringTags
+func getSearchParams(w http.ResponseWriter, payload map[string]interface{}) (query string, prefix bool, limit int, err error) {
+	query, _ = getPayloadStringParam("query", w, payload, true)
+	sPrefix, _ := getPayloadStringParam("prefix", w, payload, true)
+	prefix = sPrefix != ""
+	sLimit, _ := getPayloadStringParam("limit", w, payload, true)
+	if sLimit != "" {
+		limit, err = strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			err = fmt.Errorf("invalid limit value: '%s'", sLimit)
+			return
+		}
+	}
+	return
+}
+
+// searchTagsQuery - returns query selecting cols from tag table, rows where col contains query (or starts with it when prefix is set)
+// are returned, rows starting with query first, at most limit rows when limit is positive
+func searchTagsQuery(cols, tag, col, query string, prefix bool, limit int) (q string, args []interface{}) {
+	q = fmt.Sprintf("select %s from %s", cols, tag)
+	if query != "" {
+		// Escape LIKE special characters, query is matched literally
+		pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+		args = append(args, pattern)
+		if prefix {
+			q += fmt.Sprintf(" where %s ilike $1 order by %s", col, col)
+		} else {
+			q += fmt.Sprintf(" where %s ilike '%%' || $1 order by %s ilike $1 desc, %s", col, col, col)
+		}
+	}
+	if limit > 0 {
+		args = append(args, limit)
+		q += fmt.Sprintf(" limit $%d", len(args))
+	}
+	return
+}
+
+// searchStringTags - returns tag values, optionally only those containing query (or starting with it when prefix is set), case insensitive
+// When query is given, values starting with it are returned first, limit 0 means no limit
+func searchStringTags(c *sql.DB, ctx *lib.Ctx, tag, col, query string, prefix bool, limit int) (values []string, err error) {
+	if col == "" || tag == "" {
+		err = fmt.Errorf("tag and col must both be non-empty, got (%s, %s)", tag, col)
+		return
+	}
+	values = []string{}
+	q, args := searchTagsQuery(col, tag, col, query, prefix, limit)
+	rows, err := lib.QuerySQLLogErr(c, ctx, q, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	value := ""
+	for rows.Next() {
+		err = rows.Scan(&value)
+		if err != nil {
+			return
+		}
+		values = append(values, value)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	return
+}
+
+func metricNameToValueMap(db, apiName string) (nameToValue map[string]string, err error) {
+	switch apiName {
+	case lib.CompaniesTable:
+		nameToValue = map[string]string{
+			"Commenters":                   "commenters",
+			"Comments":                     "comments",
+			"Commit commenters":            "commitcommenters",
+			"Commits":                      "commits",
+			"Committers":                   "committers",
+			"Documentation commits":        "documentationcommits",
+			"Documentation committers":     "documentationcommitters",
+			"Pushers":                      "pushers",
+			"GitHub Events":                "events",
+			"Forkers":                      "forkers",
+			"Issue commenters":             "issuecommenters",
+			"Issuers":                      "issues",
+			"PR authors":                   "prcreators",
+			"PR reviews":                   "prreviewers",
+			"Pull requests":                "prs",
+			"Contributing in repositories": "repositories",
+			"Contributors":                 "contributors",
+			"Contributions":                "contributions",
+			"Watchers":                     "watchers",
+		}
+	case lib.ComStatsRepoGrp:
+		nameToValue = map[string]string{
+			"All activity":          "activity",
+			"Active authors":        "authors",
+			"Issues created":        "issues",
+			"Pull requests created": "prs",
+			"Commits":               "commits",
+			"Committers":            "committers",
+			"Pushers":               "pushers",
+			"Pushes":                "pushes",
+			"Contributions":         "contributions",
+			"Contributors":          "contributors",
+			"Comments":              "comments",
+		}
+	case lib.DevActCnt, lib.DevActCntComp:
+		nameToValue = map[string]string{
+			"Comments":            "comments",
+			"Commit comments":     "commit_comments",
+			"Commits":             "commits",
+			"GitHub Events":       "events",
+			"GitHub pushes":       "pushes",
+			"Issue comments":      "issue_comments",
+			"Issues":              "issues",
+			"PRs":                 "prs",
+			"Merged PRs":          "merged_prs",
+			"Review comments":     "review_comments",
+			"Contributions":       "contributions",
+			"Active repositories": "active_repos",
+		}
+		if db == lib.GHA {
+			nameToValue["Approves"] = "approves"
+			nameToValue["Reviews"] = "reviews"
+		}
+	default:
+		return nil, fmt.Errorf("metricNameToValueMap: unknown db/api pair: '%s'/'%s'", db, apiName)
+	}
+	return nameToValue, nil
+}
+
+func periodNameToValueMap(db, apiName string) (map[string]string, error) {
+	switch apiName {
+	case lib.ComContribRepoGrp:
+		return map[string]string{
+			"7 Days MA":  "d7",
+			"28 Days MA": "d28",
+			"Week":       "w",
+			"Month":      "m",
+			"Quarter":    "q",
+		}, nil
+	case lib.ComStatsRepoGrp:
+		return map[string]string{
+			"Day":       "d",
+			"7 Days MA": "d7",
+			"Week":      "w",
+			"Month":     "m",
+			"Quarter":   "q",
+			"Year":      "y",
+		}, nil
+	default:
+		return nil, fmt.Errorf("periodNameToValueMap: unknown db/api pair: '%s'/'%s'", db, apiName)
+	}
+}
+
+func apiComContribRepoGrp(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ComContribRepoGrp
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": "", "period": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	periodMap, err := periodNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range periodMap {
+		periodMap[v] = v
+	}
+	period, ok := periodMap[params["period"]]
+	if !ok {
+		err = fmt.Errorf("invalid period value: '%s'", params["period"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	query := `
+  select
+    time,
+    value
+  from
+    snum_stats
+  where
+    time >= $1
+    and time < $2
+    and period = $3
+    and series = $4
+  order by
+    time
+	`
+	seriesComps := "nstats" + repogroup + "comps"
+	seriesDevs := "nstats" + repogroup + "devs"
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, from, to, period, seriesComps)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		t                    time.Time
+		v                    float64
+		companies            []float64
+		developers           []float64
+		companiesTimestamps  []time.Time
+		developersTimestamps []time.Time
+	)
+	for rows.Next() {
+		err = rows.Scan(&t, &v)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		companiesTimestamps = append(companiesTimestamps, t)
+		companies = append(companies, v)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, from, to, period, seriesDevs)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&t, &v)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		developersTimestamps = append(developersTimestamps, t)
+		developers = append(developers, v)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := comContribRepoGrpPayload{
+		Project:              project,
+		DB:                   db,
+		Period:               params["period"],
+		RepositoryGroup:      params["repository_group"],
+		Companies:            companies,
+		CompaniesTimestamps:  companiesTimestamps,
+		Developers:           developers,
+		DevelopersTimestamps: developersTimestamps,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiCompaniesTable(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.CompaniesTable
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"range": "", "metric": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	bucket, _ := getPayloadStringParam("bucket", w, payload, true)
+	if bucket != "" && bucket != "quarter" && bucket != "year" {
+		err = fmt.Errorf("invalid bucket value: '%s', allowed: quarter, year", bucket)
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Manual ranges are only allowed in bucket mode, buckets are always computed as manual ranges
+	period, _, err := periodNameToValue(c, ctx, params["range"], bucket != "", time.UTC)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := companiesTablePayload{
+		Project: project,
+		DB:      db,
+		Range:   params["range"],
+		Metric:  params["metric"],
+		Bucket:  bucket,
+	}
+	if bucket != "" {
+		var from, to time.Time
+		from, to, err = periodBounds(c, ctx, params["range"], period)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		maxDt := lib.DayStart(time.Now().AddDate(0, 0, -1))
+		from, to = lib.NormalizeManualRange(from, to, maxDt, time.UTC)
+		var buckets []lib.ManualBucket
+		buckets, err = lib.ManualBuckets(from, to, maxDt, bucket)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if len(buckets) > maxCompaniesTableBuckets {
+			err = fmt.Errorf("too many buckets: %d, maximum is %d, please use a shorter range", len(buckets), maxCompaniesTableBuckets)
+			returnError(apiName, w, err)
+			return
+		}
+		for _, b := range buckets {
+			_, err = ensureManualData(c, ctx, project, db, apiName, metric, b.Period, nil, false, false)
+			if err != nil {
+				returnError(apiName, w, err)
+				return
+			}
+			rb := companiesTableBuckets{From: b.From, To: b.To}
+			rb.Rank, rb.Company, rb.Number, err = companiesTableRanks(c, ctx, metric, b.Period)
+			if err != nil {
+				returnError(apiName, w, err)
+				return
+			}
+			pl.Buckets = append(pl.Buckets, rb)
+		}
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(pl)
+		return
+	}
+	key, version, versioned, cached := seriesVersion(w, c, ctx, apiName, payload, "shcom", period)
+	if cached {
+		return
+	}
+	pl.Rank, pl.Company, pl.Number, err = companiesTableRanks(c, ctx, metric, period)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	writeSeriesResponse(w, key, version, versioned, pl)
+}
+
+// companiesTableRanks - returns companies ranked by a given metric in a given shcom period
+func companiesTableRanks(c *sql.DB, ctx *lib.Ctx, metric, period string) (ranks []int, companies []string, numbers []float64, err error) {
+	series := fmt.Sprintf("hcom%s", metric)
+	query := `
+    select (row_number() over (order by value desc) -1), name, value from shcom where series = $1 and period = $2
+	`
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, series, period)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank    int
+		company string
+		number  float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&rank, &company, &number)
+		if err != nil {
+			return
+		}
+		ranks = append(ranks, rank)
+		companies = append(companies, company)
+		numbers = append(numbers, number)
+	}
+	err = rows.Err()
+	return
+}
+
+// knownLogin - checks if GitHub login is known to the project (present in gha_actors)
+func knownLogin(c *sql.DB, ctx *lib.Ctx, login string) (bool, error) {
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select 1 from gha_actors where lower(login) = lower($1) limit 1", login)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = rows.Close() }()
+	known := false
+	for rows.Next() {
+		known = true
+	}
+	return known, rows.Err()
+}
+
+// getGitHubIDParams - returns github_id (single login filter) or github_ids (multiple logins) param, only one of them can be used
+func getGitHubIDParams(w http.ResponseWriter, payload map[string]interface{}) (ghID string, ghIDs []string, err error) {
+	ghIDs, err = getPayloadStringArrayParam("github_ids", w, payload, true, false)
+	if err != nil {
+		return
+	}
+	if len(ghIDs) > gMaxGitHubIDs {
+		err = fmt.Errorf("too many github_ids: %d, maximum is %d", len(ghIDs), gMaxGitHubIDs)
+		return
+	}
+	// github_id is required (it can be empty) unless github_ids is used
+	ghID, err = getPayloadStringParam("github_id", w, payload, len(ghIDs) > 0)
+	if err == nil && ghID != "" && len(ghIDs) > 0 {
+		err = fmt.Errorf("github_id and github_ids cannot be used together")
+	}
+	return
+}
+
+// loginsCondition - returns condition limiting login SQL expression to given logins, placeholders are numbered after offset
+func loginsCondition(expr string, logins []string, offset int) (cond string, args []interface{}) {
+	if len(logins) == 0 {
+		return
+	}
+	cond = " where " + expr + " in " + lib.NArray(len(logins), offset)
+	args = toInterfaceArray([]string{}, logins, []string{})
+	return
+}
+
+// newDevActCntUsers - returns github_ids mode result with all logins having no activity, nil when github_ids are not used
+func newDevActCntUsers(logins []string) map[string]*devActCntUser {
+	if len(logins) == 0 {
+		return nil
+	}
+	users := make(map[string]*devActCntUser)
+	for _, login := range logins {
+		users[login] = &devActCntUser{}
+	}
+	return users
+}
+
+// setDevActCntUser - sets login rank and number in github_ids mode result, only the best ranked row of a login is used
+func setDevActCntUser(users map[string]*devActCntUser, login string, rank, number int, company string) {
+	user, ok := users[login]
+	if !ok || user.Rank != nil {
+		return
+	}
+	r := rank
+	user.Rank = &r
+	user.Number = number
+	user.Company = company
+}
+
+func apiDevActCntRepos(apiName, project, db, info string, w http.ResponseWriter, payload map[string]interface{}) {
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	params := map[string]string{"range": "", "metric": "", "repository": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ghID, ghIDs, err := getGitHubIDParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, tz, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	err = reposModeCheck(c, ctx, project, "trepos")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repo, err := repoNameToValue(c, ctx, params["repository"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !manual {
+		err = reposModeCheck(c, ctx, project, "shdev_repos")
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	if manual {
+		_, err = ensureManualData(c, ctx, project, db, apiName, metric, period, nil, true, bg)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repo, country)
+	query := `
+   select
+     sub."Rank",
+     sub.name,
+     sub.value
+   from (
+     select row_number() over (order by sum(value) desc) as "Rank",
+       split_part(name, '$$$', 1) as name,
+       sum(value) as value
+     from
+       shdev_repos
+     where
+       series = $1
+       and period = $2
+     group by
+       split_part(name, '$$$', 1)
+   ) sub
+	`
+	// Ranks are computed over all logins, github_ids only limit returned rows
+	cond, args := loginsCondition("sub.name", ghIDs, 2)
+	query += cond
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, append([]interface{}{series, period}, args...)...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank    int
+		login   string
+		number  int
+		ranks   []*int
+		logins  []string
+		numbers []int
+	)
+	users := newDevActCntUsers(ghIDs)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if ghID != "" && login != ghID {
+			continue
+		}
+		r := rank
+		ranks = append(ranks, &r)
+		setDevActCntUser(users, login, rank, number, "")
+		logins = append(logins, login)
+		numbers = append(numbers, number)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Login known to the project but without activity in the range is not an error
+	noActivity := false
+	if len(ranks) == 0 && ghID != "" {
+		var known bool
+		known, err = knownLogin(c, ctx, ghID)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if !known {
+			err = fmt.Errorf("github_id '%s' not found in project", ghID)
+			returnError(apiName, w, err)
+			return
+		}
+		noActivity = true
+		ranks = []*int{nil}
+		logins = []string{ghID}
+		numbers = []int{0}
+	}
+	filter := fmt.Sprintf("series:%s period:%s", series, period)
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	if ghID != "" {
+		filter += " github_id:" + ghID
+	}
+	if len(ghIDs) > 0 {
+		filter += " github_ids:" + strings.Join(ghIDs, ",")
+	}
+	pl := devActCntReposPayload{
+		Project:           project,
+		DB:                db,
+		Range:             params["range"],
+		Metric:            params["metric"],
+		Repository:        params["repository"],
+		Country:           params["country"],
+		GitHubID:          ghID,
+		Filter:            filter,
+		Rank:              ranks,
+		Login:             logins,
+		Number:            numbers,
+		GitHubIDs:         users,
+		NoActivityInRange: noActivity,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// getScoreWeights - returns DevActCnt score weights by metric value, from project score.yaml, shared one or defaults
+func getScoreWeights(ctx *lib.Ctx, project string, metricMap map[string]string) (weights map[string]float64, err error) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	cfg := scoreConfig{}
+	for _, dir := range []string{project, "shared"} {
+		path := dataPrefix + "metrics/" + dir + "/score.yaml"
+		if _, e := os.Stat(path); os.IsNotExist(e) {
+			continue
+		}
+		var data []byte
+		data, err = ioutil.ReadFile(path)
+		if err != nil {
+			return
+		}
+		err = yaml.Unmarshal(data, &cfg)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", path, err)
+			return
+		}
+		break
+	}
+	weights = make(map[string]float64)
+	if cfg.Weights == nil {
+		for metric, weight := range defaultScoreWeights {
+			if _, ok := metricMap[metric]; ok {
+				weights[metric] = weight
+			}
+		}
+		return
+	}
+	for name, weight := range cfg.Weights {
+		metric, ok := metricMap[name]
+		if !ok || metric == scoreMetric {
+			err = fmt.Errorf("invalid score metric: '%s'", name)
+			return
+		}
+		if weight != 0 {
+			weights[metric] += weight
+		}
+	}
+	if len(weights) == 0 {
+		err = fmt.Errorf("no score weights defined for project '%s'", project)
+	}
+	return
+}
+
+// devActCntScores - returns developers ranked by a weighted sum of series values, with per metric values breakdown
+func devActCntScores(c *sql.DB, ctx *lib.Ctx, suffix, period string, weights map[string]float64, ghIDs []string) (ranks []int, logins []string, scores []float64, breakdowns []map[string]float64, err error) {
+	values := []string{}
+	args := []interface{}{period}
+	for metric, weight := range weights {
+		values = append(values, fmt.Sprintf("(%s, %s::text, %s::float)", lib.NValue(len(args)+1), lib.NValue(len(args)+2), lib.NValue(len(args)+3)))
+		args = append(args, "hdev_"+metric+suffix, metric, weight)
+	}
+	query := `
+   select
+     sub."Rank",
+     sub.name,
+     sub.value,
+     sub.breakdown
+   from (
+     select row_number() over (order by sum(m.value * m.weight) desc) as "Rank",
+       m.name,
+       sum(m.value * m.weight) as value,
+       json_object_agg(m.metric, m.value)::text as breakdown
+     from (
+       select split_part(s.name, '$$$', 1) as name,
+         w.metric,
+         w.weight,
+         sum(s.value) as value
+       from
+         shdev s,
+         (values ` + strings.Join(values, ", ") + `) as w(series, metric, weight)
+       where
+         s.series = w.series
+         and s.period = $1
+       group by
+         split_part(s.name, '$$$', 1),
+         w.metric,
+         w.weight
+     ) m
+     group by
+       m.name
+   ) sub
+	`
+	cond, cargs := loginsCondition("sub.name", ghIDs, len(args))
+	query += cond
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, append(args, cargs...)...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank      int
+		login     string
+		score     float64
+		breakdown string
+	)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &score, &breakdown)
+		if err != nil {
+			return
+		}
+		metrics := make(map[string]float64)
+		err = jsoniter.Unmarshal([]byte(breakdown), &metrics)
+		if err != nil {
+			return
+		}
+		ranks = append(ranks, rank)
+		logins = append(logins, login)
+		scores = append(scores, score)
+		breakdowns = append(breakdowns, metrics)
+	}
+	err = rows.Err()
+	return
+}
+
+// apiDevActCntScore - DevActCnt "score" metric, developers ranked by a weighted sum of their metrics
+func apiDevActCntScore(apiName, project, db string, w http.ResponseWriter, c *sql.DB, ctx *lib.Ctx, params map[string]string, series, period, tz string, weights map[string]float64, ghID string, ghIDs []string) {
+	suffix := strings.TrimPrefix(series, "hdev_"+scoreMetric)
+	allRanks, allLogins, allScores, allBreakdowns, err := devActCntScores(c, ctx, suffix, period, weights, ghIDs)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var (
+		ranks      []*int
+		logins     []string
+		numbers    []int
+		scores     []float64
+		breakdowns []map[string]float64
+	)
+	users := newDevActCntUsers(ghIDs)
+	for i, login := range allLogins {
+		if ghID != "" && login != ghID {
+			continue
+		}
+		r := allRanks[i]
+		number := int(math.Round(allScores[i]))
+		ranks = append(ranks, &r)
+		setDevActCntUser(users, login, r, number, "")
+		logins = append(logins, login)
+		numbers = append(numbers, number)
+		scores = append(scores, allScores[i])
+		breakdowns = append(breakdowns, allBreakdowns[i])
+	}
+	// Login known to the project but without activity in the range is not an error
+	noActivity := false
+	if len(ranks) == 0 && ghID != "" {
+		var known bool
+		known, err = knownLogin(c, ctx, ghID)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if !known {
+			err = fmt.Errorf("github_id '%s' not found in project", ghID)
+			returnError(apiName, w, err)
+			return
+		}
+		noActivity = true
+		ranks = []*int{nil}
+		logins = []string{ghID}
+		numbers = []int{0}
+		scores = []float64{0}
+		breakdowns = []map[string]float64{{}}
+	}
+	filter := fmt.Sprintf("series:%s period:%s", series, period)
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	if ghID != "" {
+		filter += " github_id:" + ghID
+	}
+	if len(ghIDs) > 0 {
+		filter += " github_ids:" + strings.Join(ghIDs, ",")
+	}
+	pl := devActCntPayload{
+		Project:           project,
+		DB:                db,
+		Range:             params["range"],
+		Metric:            params["metric"],
+		RepositoryGroup:   params["repository_group"],
+		Country:           params["country"],
+		GitHubID:          ghID,
+		Filter:            filter,
+		Rank:              ranks,
+		Login:             logins,
+		Number:            numbers,
+		GitHubIDs:         users,
+		NoActivityInRange: noActivity,
+		Score:             scores,
+		Breakdown:         breakdowns,
+		Weights:           weights,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiDevActCnt(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.DevActCnt
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Repository mode, available for all projects with trepos tags
+	paramValue, _ := getPayloadStringParam("repository", w, payload, true)
+	if paramValue != "" {
+		apiDevActCntRepos(apiName, project, db, info, w, payload)
+		return
+	}
+	params := map[string]string{"range": "", "metric": "", "repository_group": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ghID, ghIDs, err := getGitHubIDParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, tz, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricParams, err := getPayloadStringMapParam("params", w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	// Score is a weighted sum of other metrics, weights are defined per project
+	score := strings.ToLower(params["metric"]) == scoreMetric
+	if score && len(metricParams) > 0 {
+		err = fmt.Errorf("metric parameters cannot be used with '%s' metric", scoreMetric)
+		returnError(apiName, w, err)
+		return
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok && !score {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	var weights map[string]float64
+	if score {
+		metric = scoreMetric
+		weights, err = getScoreWeights(ctx, project, metricMap)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !manual && len(metricParams) > 0 {
+		err = fmt.Errorf("metric parameters can only be used with manual ranges ('range:from,to')")
+		returnError(apiName, w, err)
+		return
+	}
+	if manual {
+		manualMetrics := []string{metric}
+		if score {
+			manualMetrics = []string{}
+			for weighted := range weights {
+				manualMetrics = append(manualMetrics, weighted)
+			}
+		}
+		for _, manualMetric := range manualMetrics {
+			period, err = ensureManualData(c, ctx, project, db, apiName, manualMetric, period, metricParams, false, bg)
+			if err != nil {
+				returnError(apiName, w, err)
+				return
+			}
+		}
+	}
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repogroup, country)
+	if score {
+		apiDevActCntScore(apiName, project, db, w, c, ctx, params, series, period, tz, weights, ghID, ghIDs)
+		return
+	}
+	key, version, versioned, cached := seriesVersion(w, c, ctx, apiName, payload, "shdev", period)
+	if cached {
+		return
+	}
+	query := `
+   select
+     sub."Rank",
+     sub.name,
+     sub.value
+   from (
+     select row_number() over (order by sum(value) desc) as "Rank",
+       split_part(name, '$$$', 1) as name,
+       sum(value) as value
+     from
+       shdev
+     where
+       series = $1
+       and period = $2
+     group by
+       split_part(name, '$$$', 1)
+   ) sub
+	`
+	// Ranks are computed over all logins, github_ids only limit returned rows
+	cond, args := loginsCondition("sub.name", ghIDs, 2)
+	query += cond
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, append([]interface{}{series, period}, args...)...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank    int
+		login   string
+		number  int
+		ranks   []*int
+		logins  []string
+		numbers []int
+	)
+	users := newDevActCntUsers(ghIDs)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if ghID != "" && login != ghID {
+			continue
+		}
+		r := rank
+		ranks = append(ranks, &r)
+		setDevActCntUser(users, login, rank, number, "")
+		logins = append(logins, login)
+		numbers = append(numbers, number)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Login known to the project but without activity in the range is not an error
+	noActivity := false
+	if len(ranks) == 0 && ghID != "" {
+		var known bool
+		known, err = knownLogin(c, ctx, ghID)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if !known {
+			err = fmt.Errorf("github_id '%s' not found in project", ghID)
+			returnError(apiName, w, err)
+			return
+		}
+		noActivity = true
+		ranks = []*int{nil}
+		logins = []string{ghID}
+		numbers = []int{0}
+	}
+	filter := fmt.Sprintf("series:%s period:%s", series, period)
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	if ghID != "" {
+		filter += " github_id:" + ghID
+	}
+	if len(ghIDs) > 0 {
+		filter += " github_ids:" + strings.Join(ghIDs, ",")
+	}
+	pl := devActCntPayload{
+		Project:           project,
+		DB:                db,
+		Range:             params["range"],
+		Metric:            params["metric"],
+		RepositoryGroup:   params["repository_group"],
+		Country:           params["country"],
+		GitHubID:          ghID,
+		Filter:            filter,
+		Rank:              ranks,
+		Login:             logins,
+		Number:            numbers,
+		GitHubIDs:         users,
+		NoActivityInRange: noActivity,
+	}
+	writeSeriesResponse(w, key, version, versioned, pl)
+}
+
+func apiDevActCntCompRepos(apiName, project, db, info string, w http.ResponseWriter, payload map[string]interface{}) {
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	params := map[string]string{"range": "", "metric": "", "repository": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ghID, ghIDs, err := getGitHubIDParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	paramsAry := map[string][]string{"companies": {}}
+	for paramName := range paramsAry {
+		paramValue, err := getPayloadStringArrayParam(paramName, w, payload, false, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		paramsAry[paramName] = paramValue
+	}
+	excludeCompanies, err := getPayloadStringArrayParam("exclude_companies", w, payload, true, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	independentOnly := false
+	sIndependentOnly, _ := getPayloadStringParam("independent_only", w, payload, true)
+	if sIndependentOnly != "" {
+		independentOnly = true
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, _, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	err = reposModeCheck(c, ctx, project, "trepos")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repo, err := repoNameToValue(c, ctx, params["repository"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !manual {
+		err = reposModeCheck(c, ctx, project, "shdev_repos")
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	companiesParam := paramsAry["companies"]
+	if len(companiesParam) == 0 {
+		err = fmt.Errorf("you need to specify at least one company, for example 'All'")
+		returnError(apiName, w, err)
+		return
+	}
+	if manual {
+		_, err = ensureManualData(c, ctx, project, db, apiName, metric, period, nil, true, bg)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	var rows *sql.Rows
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repo, country)
+	query := `
+  select
+    sub."Rank",
+    split_part(sub.name, '$$$', 1),
+    split_part(sub.name, '$$$', 2),
+    sub.value
+  from (
+    select row_number() over (order by value desc) as "Rank",
+      name,
+      value
+    from
+      shdev_repos
+    where
+      series = $1
+      and period = $2
+  `
+	cond, args := companiesCondition(companiesParam, excludeCompanies, independentOnly, 2)
+	query += cond + ") sub"
+	lcond, largs := loginsCondition("split_part(sub.name, '$$$', 1)", ghIDs, 2+len(args))
+	query += lcond
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, append(append([]interface{}{series, period}, args...), largs...)...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank      int
+		login     string
+		company   string
+		number    int
+		ranks     []int
+		logins    []string
+		companies []string
+		numbers   []int
+	)
+	users := newDevActCntUsers(ghIDs)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &company, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if ghID != "" && login != ghID {
+			continue
+		}
+		ranks = append(ranks, rank)
+		logins = append(logins, login)
+		companies = append(companies, company)
+		setDevActCntUser(users, login, rank, number, company)
+		numbers = append(numbers, number)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(ranks) == 0 && ghID != "" {
+		returnError(apiName, w, fmt.Errorf("github_id '%s' not found in results", ghID))
+		return
+	}
+	cpl := devActCntCompReposPayload{
+		Project:          project,
+		DB:               db,
+		Range:            params["range"],
+		Metric:           params["metric"],
+		Repository:       params["repository"],
+		Country:          params["country"],
+		Companies:        companiesParam,
+		ExcludeCompanies: excludeCompanies,
+		IndependentOnly:  independentOnly,
+		GitHubID:         ghID,
+		Rank:             ranks,
+		Login:            logins,
+		Company:          companies,
+		Number:           numbers,
+		GitHubIDs:        users,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(cpl)
+}
+
+func apiDevActCntComp(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.DevActCntComp
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Repository mode, available for all projects with trepos tags
+	paramValue, _ := getPayloadStringParam("repository", w, payload, true)
+	if paramValue != "" {
+		apiDevActCntCompRepos(apiName, project, db, info, w, payload)
+		return
+	}
+	params := map[string]string{"range": "", "metric": "", "repository_group": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ghID, ghIDs, err := getGitHubIDParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, _, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	paramsAry := map[string][]string{"companies": {}}
+	for paramName := range paramsAry {
+		paramValue, err := getPayloadStringArrayParam(paramName, w, payload, false, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		paramsAry[paramName] = paramValue
+	}
+	excludeCompanies, err := getPayloadStringArrayParam("exclude_companies", w, payload, true, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	independentOnly := false
+	sIndependentOnly, _ := getPayloadStringParam("independent_only", w, payload, true)
+	if sIndependentOnly != "" {
+		independentOnly = true
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	companiesParam := paramsAry["companies"]
+	if len(companiesParam) == 0 {
+		err = fmt.Errorf("you need to specify at least one company, for example 'All'")
+		returnError(apiName, w, err)
+		return
+	}
+	if manual {
+		_, err = ensureManualData(c, ctx, project, db, apiName, metric, period, nil, false, bg)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	key, version, versioned, cached := seriesVersion(w, c, ctx, apiName, payload, "shdev", period)
+	if cached {
+		return
+	}
+	var rows *sql.Rows
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repogroup, country)
+	query := `
+  select
+    sub."Rank",
+    split_part(sub.name, '$$$', 1),
+    split_part(sub.name, '$$$', 2),
+    sub.value
+  from (
+    select row_number() over (order by value desc) as "Rank",
+      name,
+      value
+    from
+      shdev
+    where
+      series = $1
+      and period = $2
+  `
+	cond, args := companiesCondition(companiesParam, excludeCompanies, independentOnly, 2)
+	query += cond + ") sub"
+	lcond, largs := loginsCondition("split_part(sub.name, '$$$', 1)", ghIDs, 2+len(args))
+	query += lcond
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, append(append([]interface{}{series, period}, args...), largs...)...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		rank      int
+		login     string
+		company   string
+		number    int
+		ranks     []int
+		logins    []string
+		companies []string
+		numbers   []int
+	)
+	users := newDevActCntUsers(ghIDs)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &company, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if ghID != "" && login != ghID {
+			continue
+		}
+		ranks = append(ranks, rank)
+		logins = append(logins, login)
+		companies = append(companies, company)
+		setDevActCntUser(users, login, rank, number, company)
+		numbers = append(numbers, number)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(ranks) == 0 && ghID != "" {
+		returnError(apiName, w, fmt.Errorf("github_id '%s' not found in results", ghID))
+		return
+	}
+	cpl := devActCntCompPayload{
+		Project:          project,
+		DB:               db,
+		Range:            params["range"],
+		Metric:           params["metric"],
+		RepositoryGroup:  params["repository_group"],
+		Country:          params["country"],
+		Companies:        companiesParam,
+		ExcludeCompanies: excludeCompanies,
+		IndependentOnly:  independentOnly,
+		GitHubID:         ghID,
+		Rank:             ranks,
+		Login:            logins,
+		Company:          companies,
+		Number:           numbers,
+		GitHubIDs:        users,
+	}
+	writeSeriesResponse(w, key, version, versioned, cpl)
+}
+
+// devActBuckets - contributions count buckets, each bucket contains counts below its limit, last bucket has no limit
+var devActBuckets = []struct {
+	name  string
+	limit int64
+}{
+	{"1", 2},
+	{"2-4", 5},
+	{"5-9", 10},
+	{"10-19", 20},
+	{"20-49", 50},
+	{"50-99", 100},
+	{"100-249", 250},
+	{"250-999", 1000},
+	{"1000+", 0},
+}
+
+// devActBucket - returns devActBuckets index of a given contributions count
+func devActBucket(number int64) int {
+	for i, bucket := range devActBuckets {
+		if bucket.limit == 0 || number < bucket.limit {
+			return i
+		}
+	}
+	return len(devActBuckets) - 1
+}
+
+// apiDevActDistribution - returns histogram of contributors by their contributions count (from the same data as DevActCnt)
+func apiDevActDistribution(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.DevActDistribution
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"range": "", "metric": "", "repository_group": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, tz, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, lib.DevActCnt)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if manual {
+		_, err = ensureManualData(c, ctx, project, db, lib.DevActCnt, metric, period, nil, false, bg)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repogroup, country)
+	// Number of contributors having each contributions count, the biggest counts first
+	query := `
+   select
+     sub.value,
+     count(*)
+   from (
+     select sum(value)::bigint as value
+     from
+       shdev
+     where
+       series = $1
+       and period = $2
+     group by
+       split_part(name, '$$$', 1)
+   ) sub
+   where
+     sub.value > 0
+   group by
+     sub.value
+   order by
+     sub.value desc
+	`
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, series, period)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	filter := fmt.Sprintf("series:%s period:%s", series, period)
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	pl := devActDistributionPayload{
+		Project:         project,
+		DB:              db,
+		Range:           params["range"],
+		Metric:          params["metric"],
+		RepositoryGroup: params["repository_group"],
+		Country:         params["country"],
+		Filter:          filter,
+		Buckets:         []string{},
+		Contributors:    make([]int64, len(devActBuckets)),
+		Contributions:   make([]int64, len(devActBuckets)),
+	}
+	for _, bucket := range devActBuckets {
+		pl.Buckets = append(pl.Buckets, bucket.name)
+	}
+	var (
+		number, contributors int64
+		counts               [][2]int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&number, &contributors)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		i := devActBucket(number)
+		pl.Contributors[i] += contributors
+		pl.Contributions[i] += number * contributors
+		pl.TotalContributors += contributors
+		pl.TotalContributions += number * contributors
+		counts = append(counts, [2]int64{number, contributors})
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// The smallest number of top contributors doing at least half of all contributions
+	sum := int64(0)
+	for _, count := range counts {
+		if 2*sum >= pl.TotalContributions {
+			break
+		}
+		need := (pl.TotalContributions - 2*sum + 2*count[0] - 1) / (2 * count[0])
+		if need > count[1] {
+			need = count[1]
+		}
+		pl.HalfContributions += need
+		sum += need * count[0]
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// defaultDevActDiffLimit - DevActDiff returns this many logins, unless 'limit' is given
+const defaultDevActDiffLimit = 100
+
+// apiDevActDiff - per login contributions deltas and rank changes between two ranges (from the same data as DevActCnt)
+// Logins with the biggest growth are returned first ("rising contributors"), or the biggest drop when sort is "falling"
+func apiDevActDiff(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.DevActDiff
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"range": "", "previous_range": "", "metric": "", "repository_group": "", "country": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	limit := defaultDevActDiffLimit
+	sLimit, _ := getPayloadStringParam("limit", w, payload, true)
+	if sLimit != "" {
+		limit, err = strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			err = fmt.Errorf("invalid limit value: '%s'", sLimit)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	sortOrder, _ := getPayloadStringParam("sort", w, payload, true)
+	if sortOrder == "" {
+		sortOrder = "rising"
+	}
+	if sortOrder != "rising" && sortOrder != "falling" {
+		err = fmt.Errorf("invalid sort value: '%s', must be 'rising' or 'falling'", sortOrder)
+		returnError(apiName, w, err)
+		return
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, tz, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, lib.DevActCnt)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	country, err := allCountryNameToValue(c, ctx, params["country"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	periods := []string{}
+	for _, rangeName := range []string{params["range"], params["previous_range"]} {
+		period, manual, err := periodNameToValue(c, ctx, rangeName, true, loc)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if manual {
+			_, err = ensureManualData(c, ctx, project, db, lib.DevActCnt, metric, period, nil, false, bg)
+			if err != nil {
+				returnError(apiName, w, err)
+				return
+			}
+		}
+		periods = append(periods, period)
+	}
+	series := fmt.Sprintf("hdev_%s%s%s", metric, repogroup, country)
+	// Ranks are computed in each range separately (like in DevActCnt), logins without activity in a range have no rank there
+	order := "delta desc"
+	if sortOrder == "falling" {
+		order = "delta asc"
+	}
+	query := `
+   with cur as (
+     select
+       row_number() over (order by sum(value) desc) as rank,
+       split_part(name, '$$$', 1) as name,
+       sum(value) as value
+     from
+       shdev
+     where
+       series = $1
+       and period = $2
+     group by
+       split_part(name, '$$$', 1)
+   ), prev as (
+     select
+       row_number() over (order by sum(value) desc) as rank,
+       split_part(name, '$$$', 1) as name,
+       sum(value) as value
+     from
+       shdev
+     where
+       series = $1
+       and period = $3
+     group by
+       split_part(name, '$$$', 1)
+   )
+   select
+     coalesce(c.name, p.name) as name,
+     coalesce(c.value, 0) as value,
+     coalesce(p.value, 0) as prev_value,
+     coalesce(c.value, 0) - coalesce(p.value, 0) as delta,
+     c.rank,
+     p.rank
+   from
+     cur c
+   full outer join
+     prev p
+   on
+     c.name = p.name
+   where
+     coalesce(c.value, 0) <> coalesce(p.value, 0)
+   order by
+     ` + order + `,
+     name asc
+   limit ` + strconv.Itoa(limit) + `
+	`
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, series, periods[0], periods[1])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	filter := fmt.Sprintf("series:%s period:%s previous_period:%s", series, periods[0], periods[1])
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	pl := devActDiffPayload{
+		Project:         project,
+		DB:              db,
+		Range:           params["range"],
+		PreviousRange:   params["previous_range"],
+		Metric:          params["metric"],
+		RepositoryGroup: params["repository_group"],
+		Country:         params["country"],
+		Sort:            sortOrder,
+		Filter:          filter,
+		Login:           []string{},
+		Number:          []int{},
+		PreviousNumber:  []int{},
+		Delta:           []int{},
+		Rank:            []*int{},
+		PreviousRank:    []*int{},
+		RankChange:      []*int{},
+	}
+	var (
+		login                   string
+		number, previous, delta int
+		rank, previousRank      *int
+	)
+	for rows.Next() {
+		err = rows.Scan(&login, &number, &previous, &delta, &rank, &previousRank)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		// Positive rank change means login moved up
+		var change *int
+		if rank != nil && previousRank != nil {
+			ch := *previousRank - *rank
+			change = &ch
+		}
+		pl.Login = append(pl.Login, login)
+		pl.Number = append(pl.Number, number)
+		pl.PreviousNumber = append(pl.PreviousNumber, previous)
+		pl.Delta = append(pl.Delta, delta)
+		pl.Rank = append(pl.Rank, rank)
+		pl.PreviousRank = append(pl.PreviousRank, previousRank)
+		pl.RankChange = append(pl.RankChange, change)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// defaultBusFactorPercent - BusFactor counts top contributors doing at least this percent of contributions, unless 'percent' is given
+const defaultBusFactorPercent = 50
+
+// apiBusFactor - the smallest number of top contributors doing at least percent of all contributions in a repository group (from DevActCnt data)
+func apiBusFactor(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.BusFactor
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"range": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	metricName, _ := getPayloadStringParam("metric", w, payload, true)
+	if metricName == "" {
+		metricName = "Contributions"
+	}
+	percent := defaultBusFactorPercent
+	sPercent, _ := getPayloadStringParam("percent", w, payload, true)
+	if sPercent != "" {
+		percent, err = strconv.Atoi(sPercent)
+		if err != nil || percent < 1 || percent > 100 {
+			err = fmt.Errorf("invalid percent value: '%s', must be 1-100", sPercent)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	bg := false
+	sbg, _ := getPayloadStringParam("bg", w, payload, true)
+	if sbg != "" {
+		bg = true
+	}
+	loc, tz, err := getTZParam(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, lib.DevActCnt)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[metricName]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", metricName)
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, manual, err := periodNameToValue(c, ctx, params["range"], true, loc)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if manual {
+		_, err = ensureManualData(c, ctx, project, db, lib.DevActCnt, metric, period, nil, false, bg)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	// All countries
+	series := fmt.Sprintf("hdev_%s%sall", metric, repogroup)
+	// Contributors by their contributions, the biggest first, company is the one with most contributions of a given login
+	query := `
+   select
+     split_part(name, '$$$', 1) as login,
+     (array_agg(split_part(name, '$$$', 2) order by value desc))[1] as company,
+     sum(value)::bigint as value
+   from
+     shdev
+   where
+     series = $1
+     and period = $2
+   group by
+     split_part(name, '$$$', 1)
+   having
+     sum(value) > 0
+   order by
+     value desc,
+     login asc
+	`
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, series, period)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		login, company string
+		number         int64
+		logins         []string
+		companies      []string
+		numbers        []int64
+	)
+	filter := fmt.Sprintf("series:%s period:%s", series, period)
+	if tz != "" {
+		filter += " tz:" + tz
+	}
+	pl := busFactorPayload{
+		Project:         project,
+		DB:              db,
+		Range:           params["range"],
+		Metric:          metricName,
+		RepositoryGroup: params["repository_group"],
+		Percent:         percent,
+		Filter:          filter,
+		Logins:          []string{},
+		Companies:       []string{},
+		Contributions:   []int64{},
+	}
+	for rows.Next() {
+		err = rows.Scan(&login, &company, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		logins = append(logins, login)
+		companies = append(companies, company)
+		numbers = append(numbers, number)
+		pl.TotalContributors++
+		pl.TotalContributions += number
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for i := range numbers {
+		if 100*pl.CoveredContributions >= int64(percent)*pl.TotalContributions {
+			break
+		}
+		pl.BusFactor++
+		pl.CoveredContributions += numbers[i]
+		pl.Logins = append(pl.Logins, logins[i])
+		pl.Companies = append(pl.Companies, companies[i])
+		pl.Contributions = append(pl.Contributions, numbers[i])
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// velocityRow - single project row of the velocity report
+type velocityRow struct {
+	project, db, repo                                         string
+	activity, comments, prs, commits, issues, authors, pushes int64
+}
+
+// velocityQuery - computes velocity report row data in a single project database
+const velocityQuery = `
+  select
+    (select count(distinct id) from gha_comments where created_at >= $1 and created_at < $2),
+    (select count(distinct id) from gha_pull_requests where created_at >= $1 and created_at < $2),
+    (select count(distinct sha) from gha_commits where dup_created_at >= $1 and dup_created_at < $2),
+    (select count(distinct id) from gha_issues where is_pull_request = false and created_at >= $1 and created_at < $2),
+    (select count(distinct dup_actor_login) from gha_events where created_at >= $1 and created_at < $2 and type in (
+      'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'IssueCommentEvent', 'CommitCommentEvent', 'PullRequestReviewCommentEvent', 'PullRequestReviewEvent'
+    )),
+    (select count(*) from gha_events where type = 'PushEvent' and created_at >= $1 and created_at < $2)
+  `
+
+// velocityProjectRow - returns velocity report row of a given project in from - to range
+func velocityProjectRow(project, db, from, to string) (row velocityRow, err error) {
+	ctx, c, err := getContextAndDB(nil, db)
+	if err != nil {
+		return
+	}
+	defer func() { _ = c.Close() }()
+	rows, err := lib.QuerySQLLogErr(c, ctx, velocityQuery, from, to)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	row = velocityRow{project: project, db: db, repo: gMainRepos[db]}
+	for rows.Next() {
+		err = rows.Scan(&row.comments, &row.prs, &row.commits, &row.issues, &row.authors, &row.pushes)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	row.activity = row.comments + row.prs + row.commits + row.issues
+	return
+}
+
+// apiVelocity - returns CNCF velocity report (github.com/cncf/velocity) rows of many projects, ranked by activity
+func apiVelocity(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Velocity
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): payload: %+v err:%v\n", apiName, payload, err)
+	}()
+	if len(payload) == 0 {
+		err = fmt.Errorf("'payload' section empty or missing")
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	format, _ := getPayloadStringParam("format", w, payload, true)
+	if format != "" && format != "json" && format != "csv" {
+		err = fmt.Errorf("invalid format value: '%s', allowed: json, csv", format)
+		returnError(apiName, w, err)
+		return
+	}
+	names, err := getPayloadStringArrayParam("projects", w, payload, true, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(names) == 0 {
+		gMtx.RLock()
+		names = append(names, gProjects...)
+		gMtx.RUnlock()
+	}
+	// Each project database is queried once, even when it is given by many names
+	projects := [][2]string{}
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		var db string
+		db, err = nameToDB(name)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if _, ok := seen[db]; ok {
+			continue
+		}
+		seen[db] = struct{}{}
+		projects = append(projects, [2]string{name, db})
+	}
+	// Projects are queried concurrently using bounded number of workers
+	rows := make([]velocityRow, len(projects))
+	errs := make([]error, len(projects))
+	ch := make(chan struct{})
+	nThreads := 0
+	for i := range projects {
+		go func(i int) {
+			defer func() { ch <- struct{}{} }()
+			rows[i], errs[i] = velocityProjectRow(projects[i][0], projects[i][1], from, to)
+		}(i)
+		nThreads++
+		if nThreads >= gBatchWorkers {
+			<-ch
+			nThreads--
+		}
+	}
+	for nThreads > 0 {
+		<-ch
+		nThreads--
+	}
+	for i, e := range errs {
+		if e != nil {
+			err = fmt.Errorf("project '%s': %v", projects[i][0], e)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].activity != rows[j].activity {
+			return rows[i].activity > rows[j].activity
+		}
+		return rows[i].project < rows[j].project
+	})
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"org", "repo", "activity", "comments", "prs", "commits", "issues", "authors", "pushes"})
+		for _, row := range rows {
+			_ = writer.Write(
+				[]string{
+					row.project,
+					row.repo,
+					strconv.FormatInt(row.activity, 10),
+					strconv.FormatInt(row.comments, 10),
+					strconv.FormatInt(row.prs, 10),
+					strconv.FormatInt(row.commits, 10),
+					strconv.FormatInt(row.issues, 10),
+					strconv.FormatInt(row.authors, 10),
+					strconv.FormatInt(row.pushes, 10),
+				},
+			)
+		}
+		writer.Flush()
+		return
+	}
+	pl := velocityPayload{
+		From:     params["from"],
+		To:       params["to"],
+		Rank:     []int{},
+		Project:  []string{},
+		DB:       []string{},
+		Repo:     []string{},
+		Activity: []int64{},
+		Comments: []int64{},
+		PRs:      []int64{},
+		Commits:  []int64{},
+		Issues:   []int64{},
+		Authors:  []int64{},
+		Pushes:   []int64{},
+	}
+	for i, row := range rows {
+		pl.Rank = append(pl.Rank, i+1)
+		pl.Project = append(pl.Project, row.project)
+		pl.DB = append(pl.DB, row.db)
+		pl.Repo = append(pl.Repo, row.repo)
+		pl.Activity = append(pl.Activity, row.activity)
+		pl.Comments = append(pl.Comments, row.comments)
+		pl.PRs = append(pl.PRs, row.prs)
+		pl.Commits = append(pl.Commits, row.commits)
+		pl.Issues = append(pl.Issues, row.issues)
+		pl.Authors = append(pl.Authors, row.authors)
+		pl.Pushes = append(pl.Pushes, row.pushes)
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// syncStatusRow - last synced GHA hour and last calc_metric run of a single project database, nil when there is no data
+type syncStatusRow struct {
+	lastGHAHour    *time.Time
+	lastCalcMetric *time.Time
+}
+
+func syncStatusProjectRow(db string) (row syncStatusRow, err error) {
+	ctx, c, err := getContextAndDB(nil, db)
+	if err != nil {
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Partially parsed hours are parsed again by the next sync, so they are not synced yet
+	// devstats tool saves its sync durations in gha_last_computed too, they are not calc_metric runs
+	rows, err := lib.QuerySQLLogErr(
+		c,
+		ctx,
+		"select (select max(dt) from gha_parsed where status <> "+lib.NValue(1)+"), "+
+			"(select max(dt) from gha_last_computed where metric not in ('devstats_sync', 'devstats_sync_failed'))",
+		lib.ParsedPartial,
+	)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		err = rows.Scan(&row.lastGHAHour, &row.lastCalcMetric)
+		if err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// apiSyncStatus - returns last synced GHA hour, last calc_metric run and data freshness lag of many projects
+func apiSyncStatus(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.SyncStatus
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): payload: %+v err:%v\n", apiName, payload, err)
+	}()
+	names, err := getPayloadStringArrayParam("projects", w, payload, true, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	staleMinutes := int64(defaultStaleMinutes)
+	sStale, err := getPayloadStringParam("stale_minutes", w, payload, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if sStale != "" {
+		staleMinutes, err = strconv.ParseInt(sStale, 10, 64)
+		if err != nil || staleMinutes <= 0 {
+			err = fmt.Errorf("invalid stale_minutes value: '%s'", sStale)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	if len(names) == 0 {
+		gMtx.RLock()
+		names = append(names, gProjects...)
+		gMtx.RUnlock()
+	}
+	// Each project database is queried once, even when it is given by many names
+	projects := [][2]string{}
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		var db string
+		db, err = nameToDB(name)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if _, ok := seen[db]; ok {
+			continue
+		}
+		seen[db] = struct{}{}
+		projects = append(projects, [2]string{name, db})
+	}
+	// Projects are queried concurrently using bounded number of workers
+	rows := make([]syncStatusRow, len(projects))
+	errs := make([]error, len(projects))
+	ch := make(chan struct{})
+	nThreads := 0
+	for i := range projects {
+		go func(i int) {
+			defer func() { ch <- struct{}{} }()
+			rows[i], errs[i] = syncStatusProjectRow(projects[i][1])
+		}(i)
+		nThreads++
+		if nThreads >= gBatchWorkers {
+			<-ch
+			nThreads--
+		}
+	}
+	for nThreads > 0 {
+		<-ch
+		nThreads--
+	}
+	for i, e := range errs {
+		if e != nil {
+			err = fmt.Errorf("project '%s': %v", projects[i][0], e)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	pl := syncStatusPayload{
+		Projects:       []string{},
+		DB:             []string{},
+		LastGHAHour:    []*time.Time{},
+		LastCalcMetric: []*time.Time{},
+		LagMinutes:     []*int64{},
+		Stale:          []bool{},
+		StaleMinutes:   staleMinutes,
+	}
+	now := time.Now()
+	for i, row := range rows {
+		// Hour is fully synced at its end, projects without any synced hour are stale
+		var lag *int64
+		stale := true
+		if row.lastGHAHour != nil {
+			minutes := int64(now.Sub(row.lastGHAHour.Add(time.Hour)).Minutes())
+			if minutes < 0 {
+				minutes = 0
+			}
+			lag = &minutes
+			stale = minutes > staleMinutes
+		}
+		pl.Projects = append(pl.Projects, projects[i][0])
+		pl.DB = append(pl.DB, projects[i][1])
+		pl.LastGHAHour = append(pl.LastGHAHour, row.lastGHAHour)
+		pl.LastCalcMetric = append(pl.LastCalcMetric, row.lastCalcMetric)
+		pl.LagMinutes = append(pl.LagMinutes, lag)
+		pl.Stale = append(pl.Stale, stale)
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiProjectFeatures - returns features enabled in projects.yaml for many projects
+func apiProjectFeatures(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ProjectFeatures
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): payload: %+v err:%v\n", apiName, payload, err)
+	}()
+	names, err := getPayloadStringArrayParam("projects", w, payload, true, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(names) == 0 {
+		gMtx.RLock()
+		names = append(names, gProjects...)
+		gMtx.RUnlock()
+	}
+	pl := projectFeaturesPayload{Projects: []string{}, DB: []string{}, Features: [][]string{}}
+	seen := make(map[string]struct{})
+	for _, name := range names {
+		var db string
+		db, err = nameToDB(name)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if _, ok := seen[db]; ok {
+			continue
+		}
+		seen[db] = struct{}{}
+		gMtx.RLock()
+		features := gFeatures[db]
+		gMtx.RUnlock()
+		pl.Projects = append(pl.Projects, name)
+		pl.DB = append(pl.DB, db)
+		pl.Features = append(pl.Features, features)
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiListAPIs(info string, w http.ResponseWriter) {
+	apiName := lib.ListAPIs
+	lapl := listAPIsPayload{APIs: allAPIs}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(lapl)
+	lib.Printf("%s(exit)\n", apiName)
+}
+
+// apiBgStatus - returns running (oldest first) and queued (in order they will be started) background calculations
+func apiBgStatus(info string, w http.ResponseWriter) {
+	apiName := lib.BgStatus
+	bspl := bgStatusPayload{Running: []bgJob{}, Queued: []bgJob{}}
+	gBgMtx.RLock()
+	bspl.MaxRunning = gMaxBg
+	bspl.MaxQueued = gMaxBgQueue
+	for _, job := range gBgJobs {
+		if job.State == bgRunning {
+			bspl.Running = append(bspl.Running, *job)
+		}
+	}
+	for _, key := range gBgQueue {
+		bspl.Queued = append(bspl.Queued, *gBgJobs[key])
+	}
+	gBgMtx.RUnlock()
+	sort.Slice(bspl.Running, func(i, j int) bool { return bspl.Running[i].StartedAt.Before(*bspl.Running[j].StartedAt) })
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(bspl)
+	lib.Printf("%s(exit): %d running, %d queued\n", apiName, len(bspl.Running), len(bspl.Queued))
+}
+
+func apiListProjects(info string, w http.ResponseWriter) {
+	apiName := lib.ListProjects
+	names := []string{}
+	gMtx.RLock()
+	for _, name := range gProjects {
+		names = append(names, name)
+	}
+	gMtx.RUnlock()
+	lppl := listProjectsPayload{Projects: names}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(lppl)
+	lib.Printf("%s(exit)\n", apiName)
+}
+
+func apiHealth(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Health
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select count(*) from gha_events")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	events := 0
+	for rows.Next() {
+		err = rows.Scan(&events)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	hpl := healthPayload{Project: project, DB: db, Events: events}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(hpl)
+}
+
+func apiRepoGroups(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.RepoGroups
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"raw": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, true)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repoGroups := []string{}
+	if params["raw"] == "" {
+		repoGroups, err = getStringTags(c, ctx, "tall_repo_groups", "all_repo_group_name")
+	} else {
+		repoGroups, err = getStringTags(c, ctx, "tall_repo_groups", "all_repo_group_value")
+	}
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	rgpl := repoGroupsPayload{Project: project, DB: db, RepoGroups: repoGroups}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(rgpl)
+}
+
+func apiCompanies(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Companies
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	query, prefix, limit, err := getSearchParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	companies := []string{}
+	companies, err = searchStringTags(c, ctx, "tcompanies", "companies_name", query, prefix, limit)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	cpl := companiesPayload{Project: project, DB: db, Companies: companies}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(cpl)
+}
+
+func apiRanges(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Ranges
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"raw": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, true)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	ranges := []string{}
+	if params["raw"] == "" {
+		ranges, err = getStringTags(c, ctx, "tquick_ranges", "quick_ranges_name")
+	} else {
+		ranges, err = getStringTags(c, ctx, "tquick_ranges", "quick_ranges_suffix")
+	}
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	rpl := rangesPayload{Project: project, DB: db, Ranges: ranges}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(rpl)
+}
+
+func apiCountries(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Countries
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"raw": "", "lang": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, true)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	query, prefix, limit, err := getSearchParams(w, payload)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Localized names are only used for countries names, raw mode returns codes
+	var namer *lib.CountryNamer
+	if params["lang"] != "" && params["raw"] == "" {
+		namer, err = lib.NewCountryNamer(params["lang"])
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	var countries, codes []string
+	if namer != nil {
+		// Localized names are searched and sorted here, gha_countries only has English names
+		countries, codes, err = searchCountries(c, ctx, "name", "", false, 0)
+		if err == nil {
+			countries, codes = localizeCountries(namer, countries, codes, query, prefix, limit)
+		}
+	} else if params["raw"] == "" {
+		countries, codes, err = searchCountries(c, ctx, "name", query, prefix, limit)
+	} else {
+		countries, codes, err = searchCountries(c, ctx, "code", query, prefix, limit)
+	}
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for i, code := range codes {
+		codes[i] = lib.NormalizeCountryCode(code)
+	}
+	cpl := countriesPayload{Project: project, DB: db, Lang: params["lang"], Countries: countries, Codes: codes}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(cpl)
+}
+
+// searchCountries - searches gha_countries like searchStringTags does, returns values of col and country codes
+func searchCountries(c *sql.DB, ctx *lib.Ctx, col, query string, prefix bool, limit int) (values, codes []string, err error) {
+	values, codes = []string{}, []string{}
+	q, args := searchTagsQuery(col+", code", "gha_countries", col, query, prefix, limit)
+	rows, err := lib.QuerySQLLogErr(c, ctx, q, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	value, code := "", ""
+	for rows.Next() {
+		err = rows.Scan(&value, &code)
+		if err != nil {
+			return
+		}
+		values = append(values, value)
+		codes = append(codes, code)
+	}
+	err = rows.Err()
+	return
+}
+
+// localizeCountries - translates country names, then filters them by query (case insensitive) and sorts them like searchCountries
+func localizeCountries(namer *lib.CountryNamer, names, codes []string, query string, prefix bool, limit int) ([]string, []string) {
+	for i := range names {
+		names[i] = namer.Name(codes[i], names[i])
+	}
+	namer.Sort(names, codes)
+	if query != "" {
+		lQuery := strings.ToLower(query)
+		starting, startingCodes, containing, containingCodes := []string{}, []string{}, []string{}, []string{}
+		for i, name := range names {
+			lName := strings.ToLower(name)
+			if strings.HasPrefix(lName, lQuery) {
+				starting = append(starting, name)
+				startingCodes = append(startingCodes, codes[i])
+			} else if !prefix && strings.Contains(lName, lQuery) {
+				containing = append(containing, name)
+				containingCodes = append(containingCodes, codes[i])
+			}
+		}
+		names, codes = append(starting, containing...), append(startingCodes, containingCodes...)
+	}
+	if limit > 0 && len(names) > limit {
+		names, codes = names[:limit], codes[:limit]
+	}
+	return names, codes
+}
+
+// companiesCondition - returns SQL condition filtering shdev/shdev_repos rows by the company part of their name
+// placeholders are numbered starting from offset+1, companies ["All"] means no companies filter
+func companiesCondition(companies, excludeCompanies []string, independentOnly bool, offset int) (cond string, args []interface{}) {
+	if !(len(companies) == 1 && companies[0] == lib.ALL) {
+		cond += " and split_part(name, '$$$', 2) in " + lib.NArray(len(companies), offset+len(args))
+		args = append(args, toInterfaceArray([]string{}, companies, []string{})...)
+	}
+	if len(excludeCompanies) > 0 {
+		cond += " and split_part(name, '$$$', 2) not in " + lib.NArray(len(excludeCompanies), offset+len(args))
+		args = append(args, toInterfaceArray([]string{}, excludeCompanies, []string{})...)
+	}
+	if independentOnly {
+		cond += " and split_part(name, '$$$', 2) = " + lib.NValue(offset+len(args)+1)
+		args = append(args, lib.Independent)
+	}
+	return
+}
+
+func toInterfaceArray(beforeArray, stringArray, afterArray []string) (interfaceArray []interface{}) {
+	for _, str := range beforeArray {
+		interfaceArray = append(interfaceArray, str)
+	}
+	for _, str := range stringArray {
+		interfaceArray = append(interfaceArray, str)
+	}
+	for _, str := range afterArray {
+		interfaceArray = append(interfaceArray, str)
+	}
+	return
+}
+
+func apiRepos(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Repos
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string][]string{"repository_group": {}}
+	for paramName := range params {
+		paramValue, err := getPayloadStringArrayParam(paramName, w, payload, false, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repositoryGroupParam := params["repository_group"]
+	var rows *sql.Rows
+	// TODO: consider swiitching to gha_repo_groups
+	query := `
+    select
+      distinct coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') as "Repository group",
+      name as "Repository"
+    from
+      gha_repos
+    where
+      name like '%_/_%'
+      and name not like '%/%/%'
+  `
+	if len(repositoryGroupParam) == 1 && repositoryGroupParam[0] == lib.ALL {
+		rows, err = lib.QuerySQLLogErr(c, ctx, query)
+	} else {
+		query += ` and coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') in ` + lib.NArray(len(repositoryGroupParam), 0)
+		rows, err = lib.QuerySQLLogErr(c, ctx, query, toInterfaceArray([]string{}, repositoryGroupParam, []string{})...)
+	}
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	repoGroups := []string{}
+	repoGroup := ""
+	repos := []string{}
+	repo := ""
+	for rows.Next() {
+		err = rows.Scan(&repoGroup, &repo)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		repoGroups = append(repoGroups, repoGroup)
+		repos = append(repos, repo)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	rpl := reposPayload{Project: project, DB: db, RepoGroups: repoGroups, Repos: repos}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(rpl)
+}
+
+func apiComStatsRepoGrp(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ComStatsRepoGrp
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": "", "period": "", "metric": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	periodMap, err := periodNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range periodMap {
+		periodMap[v] = v
+	}
+	period, ok := periodMap[params["period"]]
+	if !ok {
+		err = fmt.Errorf("invalid period value: '%s'", params["period"])
+		returnError(apiName, w, err)
+		return
+	}
+	metricMap, err := metricNameToValueMap(db, apiName)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	for _, v := range metricMap {
+		metricMap[v] = v
+	}
+	metric, ok := metricMap[params["metric"]]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", params["metric"])
+		returnError(apiName, w, err)
+		return
+	}
+	paramsAry := map[string][]string{"companies": {}}
+	for paramName := range paramsAry {
+		paramValue, err := getPayloadStringArrayParam(paramName, w, payload, false, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		paramsAry[paramName] = paramValue
+	}
+	companiesParam := paramsAry["companies"]
+	if len(companiesParam) == 0 {
+		err = fmt.Errorf("you need to specify at least one company, for example 'All'")
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	repogroup, err := allRepoGroupNameToValue(c, ctx, params["repository_group"])
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	query := "select "
+	if len(companiesParam) == 1 && companiesParam[0] == lib.ALL {
+		query += "*"
+	} else {
+		query += "time, "
+		for _, company := range companiesParam {
+			query += "\"" + company + "\", "
+		}
+		query = query[0 : len(query)-2]
+	}
+	query += " from scompany_activity where time >= $1 and time < $2 and period = $3 and series = $4 order by time"
+	series := "company" + repogroup + metric
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, from, to, period, series)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	vals := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case lib.TimeCol:
+			vals[i] = new(time.Time)
+		case lib.SeriesCol, lib.PeriodCol:
+			vals[i] = new(string)
+		default:
+			vals[i] = new(float64)
+		}
+	}
+	times := []time.Time{}
+	values := []map[string]float64{}
+	now := time.Now()
+	for rows.Next() {
+		err = rows.Scan(vals...)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		vMap := make(map[string]float64)
+		for index, val := range vals {
+			column := columns[index]
+			switch column {
+			case lib.TimeCol:
+				if val != nil {
+					times = append(times, *(val.(*time.Time)))
+				} else {
+					times = append(times, now)
+				}
+				continue
+			case lib.SeriesCol, lib.PeriodCol:
+				continue
+			default:
+				if val != nil {
+					vMap[column] = *val.(*float64)
+				} else {
+					vMap[column] = 0.0
+				}
+			}
+		}
+		values = append(values, vMap)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := comStatsRepoGrpPayload{
+		Project:         project,
+		DB:              db,
+		From:            params["from"],
+		To:              params["to"],
+		Period:          params["period"],
+		Metric:          params["metric"],
+		RepositoryGroup: params["repository_group"],
+		Companies:       companiesParam,
+		Timestamps:      times,
+		Values:          values,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// projectAPIMetrics - returns project custom API metrics (metrics/<project>/api_metrics.yaml), nil when project has none
+func projectAPIMetrics(ctx *lib.Ctx, project string) (metrics map[string]lib.APIMetric, err error) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	path := dataPrefix + "metrics/" + project + "/" + lib.APIMetricsFile
+	if _, e := os.Stat(path); os.IsNotExist(e) {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	metrics, err = lib.ParseAPIMetrics(data)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// apiSeriesQuery - returns series data of project custom API metric (api_metrics.yaml), new series backed metrics need no API code changes
+// Payload arguments named like metric series placeholders (for example repository_group) select the series
+func apiSeriesQuery(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.SeriesQuery
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": "", "period": "", "metric": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	metrics, err := projectAPIMetrics(ctx, project)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metric, ok := metrics[params["metric"]]
+	if !ok {
+		names := []string{}
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		err = fmt.Errorf("invalid metric value: '%s', project '%s' metrics: %s", params["metric"], project, strings.Join(names, ", "))
+		returnError(apiName, w, err)
+		return
+	}
+	period := params["period"]
+	if !metric.HasPeriod(period) {
+		err = fmt.Errorf("invalid period value: '%s', metric '%s' periods: %s", period, metric.Name, strings.Join(metric.Periods, ", "))
+		returnError(apiName, w, err)
+		return
+	}
+	// Series placeholders values from payload arguments with the same names
+	args := make(map[string]string)
+	values := make(map[string]string)
+	for _, placeholder := range metric.Placeholders() {
+		arg, err := getPayloadStringParam(placeholder, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		args[placeholder] = arg
+		switch placeholder {
+		case "repository_group":
+			values[placeholder], err = allRepoGroupNameToValue(c, ctx, arg)
+		case "repository":
+			values[placeholder], err = repoNameToValue(c, ctx, arg)
+		case "country":
+			values[placeholder], err = allCountryNameToValue(c, ctx, arg)
+		}
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	exists, err := tableExists(c, ctx, metric.Table)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("metric '%s' series table '%s' does not exist in project '%s'", metric.Name, metric.Table, project)
+		returnError(apiName, w, err)
+		return
+	}
+	query := "select "
+	if len(metric.Columns) == 0 {
+		query += "*"
+	} else {
+		query += "time"
+		for _, column := range metric.Columns {
+			query += ", \"" + column + "\""
+		}
+	}
+	query += " from " + metric.Table + " where time >= $1 and time < $2 and period = $3 and series = $4 order by time"
+	series := metric.SeriesName(values)
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, from, to, period, series)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	vals := make([]interface{}, len(columns))
+	for i, column := range columns {
+		switch column {
+		case lib.TimeCol:
+			vals[i] = new(time.Time)
+		case lib.SeriesCol, lib.PeriodCol:
+			vals[i] = new(string)
+		default:
+			vals[i] = new(*float64)
+		}
+	}
+	pl := seriesQueryPayload{
+		Project:    project,
+		DB:         db,
+		Metric:     params["metric"],
+		Period:     period,
+		From:       params["from"],
+		To:         params["to"],
+		Series:     series,
+		Values:     []map[string]float64{},
+		Timestamps: []time.Time{},
+	}
+	if len(args) > 0 {
+		pl.Args = args
+	}
+	for rows.Next() {
+		err = rows.Scan(vals...)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		vMap := make(map[string]float64)
+		for index, val := range vals {
+			column := columns[index]
+			switch column {
+			case lib.TimeCol:
+				pl.Timestamps = append(pl.Timestamps, *(val.(*time.Time)))
+			case lib.SeriesCol, lib.PeriodCol:
+				continue
+			default:
+				if v := *val.(**float64); v != nil {
+					vMap[column] = *v
+				} else {
+					vMap[column] = 0.0
+				}
+			}
+		}
+		pl.Values = append(pl.Values, vMap)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiEvents(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Events
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	query := `
+  select
+    time,
+    value
+  from
+    sevents_h
+  where
+    time >= $1
+    and time < $2
+  order by
+    time
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, from, to)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	times := []time.Time{}
+	values := []int64{}
+	var (
+		t time.Time
+		v int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&t, &v)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		times = append(times, t)
+		values = append(values, v)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	epl := eventsPayload{Project: project, DB: db, TimeStamps: times, Values: values, From: params["from"], To: params["to"]}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(epl)
+}
+
+// tableExists - checks if a given table exists, tables created by optional tools can be missing
+func tableExists(c *sql.DB, ctx *lib.Ctx, tableName string) (bool, error) {
+	var table *string
+	err := lib.QueryRowSQL(c, ctx, "select to_regclass($1)::text", tableName).Scan(&table)
+	return table != nil, err
+}
+
+// starsCorrection - returns sum of stars corrections recorded by reconcile_stars tool
+// returns 0 if the tool was never run on a given database
+func starsCorrection(c *sql.DB, ctx *lib.Ctx) (correction int64, err error) {
+	exists, err := tableExists(c, ctx, "gha_star_corrections")
+	if err != nil || !exists {
+		return
+	}
+	err = lib.QueryRowSQL(c, ctx, "select coalesce(sum(correction), 0) from gha_star_corrections").Scan(&correction)
+	return
+}
+
+func apiSiteStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.SiteStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	key := [2]string{project, db}
+	siteStatsCacheMtx.Lock()
+	data, ok := siteStatsCache[key]
+	siteStatsCacheMtx.Unlock()
+	if ok {
+		age := time.Now().Sub(data.dt).Seconds()
+		if age < 43200 {
+			lib.Printf("Using cached value %+v (age is %.0f < 43200)\n", data, age)
+			setResponseCached(w)
+			w.WriteHeader(http.StatusOK)
+			jsoniter.NewEncoder(w).Encode(data.siteStats)
+			return
+		}
+		siteStatsCacheMtx.Lock()
+		delete(siteStatsCache, key)
+		siteStatsCacheMtx.Unlock()
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	ch := make(chan error)
+	mtx := &sync.Mutex{}
+	sspl := siteStatsPayload{Project: project, DB: db}
+	go func(ch chan error) {
+		var err error
+		//lib.Printf("pstatall start\n")
+		defer func() {
+			ch <- err
+			//lib.Printf("pstatall end\n")
+		}()
+		query := `
+  select
+    name,
+    value
+  from
+    spstat
+  where
+    series = 'pstatall'
+    and period = 'y100'
+    and name in (
+      'Contributors', 'Contributions', 'Code committers',
+      'Commits', 'Events', 'Forkers',
+      'Repositories', 'Stargazers'
+    )
+  `
+		var rows *sql.Rows
+		rows, err = lib.QuerySQLLogErr(c, ctx, query)
+		if err != nil {
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		var (
+			value float64
+			name  string
+		)
+		for rows.Next() {
+			err = rows.Scan(&name, &value)
+			if err != nil {
+				return
+			}
+			//lib.Printf("pstatall > %v %v\n", name, value)
+			mtx.Lock()
+			switch name {
+			case "Contributors":
+				sspl.Contributors = int64(value)
+			case "Contributions":
+				sspl.Contributions = int64(value)
+			case "Code committers":
+				sspl.Committers = int64(value)
+			case "Commits":
+				sspl.Commits = int64(value)
+			case "Events":
+				sspl.Events = int64(value)
+			case "Forkers":
+				sspl.Forkers = int64(value)
+			case "Repositories":
+				sspl.Repositories = int64(value)
+			case "Stargazers":
+				sspl.Stargazers = int64(value)
+			default:
+				//lib.Printf("site stats: unknown metric: '%s'\n", name)
+			}
+			mtx.Unlock()
+			//lib.Printf("pstatall < %v %v\n", name, value)
+		}
+		err = rows.Err()
+	}(ch)
+	go func(ch chan error) {
+		//lib.Printf("BOC start\n")
+		var err error
+		defer func() {
+			ch <- err
+			//lib.Printf("BOC end\n")
+		}()
+		query := `
+  select
+    sum(rl.lang_loc)
+  from
+    gha_repos r,
+    gha_repos_langs rl
+  where
+    r.name = rl.repo_name
+    and (r.name, r.id) = (
+      select i.name,
+        i.id
+      from
+        gha_repos i
+      where
+        i.alias = r.alias
+        and i.name like '%_/_%'
+        and i.name not like '%/%/%'
+      limit 1
+    )
+  `
+		var rows *sql.Rows
+		rows, err = lib.QuerySQLLogErr(c, ctx, query)
+		if err != nil {
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		var value float64
+		for rows.Next() {
+			err = rows.Scan(&value)
+			if err != nil {
+				return
+			}
+			//lib.Printf("BOC > %v\n", value)
+			mtx.Lock()
+			sspl.BOC = int64(value)
+			mtx.Unlock()
+			//lib.Printf("BOC < %v\n", value)
+		}
+		err = rows.Err()
+	}(ch)
+	go func(ch chan error) {
+		//lib.Printf("countries start\n")
+		var err error
+		defer func() {
+			ch <- err
+			//lib.Printf("countries end\n")
+		}()
+		query := `
+  select
+    count(distinct sub.country_id) as num_countries
+  from (
+    select
+      a.country_id
+    from
+      gha_events e,
+      gha_actors a
+    where
+      e.actor_id = a.id
+      and e.type in (
+        'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent',
+        'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'
+      )
+    union select
+      a.country_id
+    from
+      gha_actors a,
+      gha_commits c
+    where
+      (
+        c.author_id = a.id
+        or c.committer_id = a.id
+      )
+    union select
+      a.country_id
+    from
+      gha_actors a,
+      gha_commits_roles cr
+    where
+      cr.actor_id = a.id
+      and cr.role = 'Co-authored-by'
+  ) sub
+  `
+		var rows *sql.Rows
+		rows, err = lib.QuerySQLLogErr(c, ctx, query)
+		if err != nil {
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		var value float64
+		for rows.Next() {
+			err = rows.Scan(&value)
+			if err != nil {
+				return
+			}
+			//lib.Printf("contries > %v\n", value)
+			mtx.Lock()
+			sspl.Countries = int64(value)
+			mtx.Unlock()
+			//lib.Printf("contries < %v\n", value)
+		}
+		err = rows.Err()
+	}(ch)
+	go func(ch chan error) {
+		//lib.Printf("companies start\n")
+		var err error
+		defer func() {
+			ch <- err
+			//lib.Printf("companies end\n")
+		}()
+		query := `
+  select
+    count(distinct sub.company_name) as num_companis
+  from (
+    select
+      af.company_name
+    from
+      gha_events e,
+      gha_actors_affiliations af
+    where
+      e.actor_id = af.actor_id
+      and af.dt_from <= e.created_at
+      and af.dt_to > e.created_at
+      and af.company_name not in ('Independent', 'Unknown', 'NotFound', '')
+      and e.type in (
+        'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent',
+        'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'
+      )
+    union select
+      af.company_name
+    from
+      gha_actors_affiliations af,
+      gha_commits c
+    where
+      (
+        c.author_id = af.actor_id
+        or c.committer_id = af.actor_id
+      )
+      and af.dt_from <= c.dup_created_at
+      and af.dt_to > c.dup_created_at
+      and af.company_name not in ('Independent', 'Unknown', 'NotFound', '')
+    union select
+      af.company_name
+    from
+      gha_actors_affiliations af,
+      gha_commits_roles cr
+    where
+      cr.actor_id = af.actor_id
+      and cr.role = 'Co-authored-by'
+      and af.dt_from <= cr.dup_created_at
+      and af.dt_to > cr.dup_created_at
+      and af.company_name not in ('Independent', 'Unknown', 'NotFound', '')
+  ) sub
+  `
+		var rows *sql.Rows
+		rows, err = lib.QuerySQLLogErr(c, ctx, query)
+		if err != nil {
+			return
+		}
+		defer func() { _ = rows.Close() }()
+		var value float64
+		for rows.Next() {
+			err = rows.Scan(&value)
+			if err != nil {
+				return
+			}
+			//lib.Printf("orgs > %v\n", value)
+			mtx.Lock()
+			sspl.Companies = int64(value)
+			mtx.Unlock()
+			//lib.Printf("orgs < %v\n", value)
+		}
+		err = rows.Err()
+	}(ch)
+	for i := 0; i < 4; i++ {
+		//lib.Printf("before %d\n", i)
+		err := <-ch
+		//lib.Printf("after %d\n", i)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	// Stargazers come from WatchEvents which are never removed, apply corrections from the stars reconciliation
+	correction, err := starsCorrection(c, ctx)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	sspl.Stargazers += correction
+	//lib.Printf("out\n")
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(sspl)
+	siteStatsCacheMtx.Lock()
+	siteStatsCache[key] = siteStatsCacheEntry{dt: time.Now(), siteStats: sspl}
+	siteStatsCacheMtx.Unlock()
+}
+
+// apiRepoStats - SiteStats counters limited to a single repository or to all repositories of a repository group
+// Either 'repository' or 'repository_group' must be given, results are cached like SiteStats
+func apiRepoStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.RepoStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"repository": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, true)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	repo, repoGroup := params["repository"], params["repository_group"]
+	if (repo == "") == (repoGroup == "") {
+		err = fmt.Errorf("exactly one of 'repository' or 'repository_group' must be specified")
+		returnError(apiName, w, err)
+		return
+	}
+	key := [4]string{project, db, repo, repoGroup}
+	repoStatsCacheMtx.Lock()
+	data, ok := repoStatsCache[key]
+	repoStatsCacheMtx.Unlock()
+	if ok {
+		age := time.Now().Sub(data.dt).Seconds()
+		if age < 43200 {
+			lib.Printf("Using cached value %+v (age is %.0f < 43200)\n", data, age)
+			setResponseCached(w)
+			w.WriteHeader(http.StatusOK)
+			jsoniter.NewEncoder(w).Encode(data.repoStats)
+			return
+		}
+		repoStatsCacheMtx.Lock()
+		delete(repoStatsCache, key)
+		repoStatsCacheMtx.Unlock()
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Repositories are selected by name and then matched by id, so events from before repository rename are included
+	cond, arg := "name = $1", repo
+	if repoGroup != "" {
+		cond, arg = "coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $1", repoGroup
+	}
+	ids := "select id from gha_repos where " + cond
+	rspl := repoStatsPayload{Project: project, DB: db, Repository: repo, RepositoryGroup: repoGroup}
+	err = lib.QueryRowSQL(c, ctx, "select count(distinct id) from gha_repos where "+cond, arg).Scan(&rspl.Repositories)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if rspl.Repositories == 0 {
+		err = fmt.Errorf("no repositories found for repository '%s', repository group '%s'", repo, repoGroup)
+		returnError(apiName, w, err)
+		return
+	}
+	contributions := `
+      'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent',
+      'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'
+  `
+	queries := []repoStatsQuery{
+		{
+			query: `
+  select
+    count(distinct actor_id),
+    count(id)
+  from
+    gha_events
+  where
+    repo_id in (` + ids + `)
+    and type in (` + contributions + `)
+  `,
+			values: []*int64{&rspl.Contributors, &rspl.Contributions},
+		},
+		{
+			query: `
+  select
+    count(id),
+    count(distinct actor_id) filter (where type = 'ForkEvent'),
+    count(distinct actor_id) filter (where type = 'WatchEvent')
+  from
+    gha_events
+  where
+    repo_id in (` + ids + `)
+  `,
+			values: []*int64{&rspl.Events, &rspl.Forkers, &rspl.Stargazers},
+		},
+		{
+			query: `
+  select
+    count(distinct sha),
+    (
+      select
+        count(distinct sub.actor_id)
+      from (
+        select author_id as actor_id from gha_commits where dup_repo_id in (` + ids + `) and author_id is not null
+        union select committer_id from gha_commits where dup_repo_id in (` + ids + `) and committer_id is not null
+      ) sub
+    )
+  from
+    gha_commits
+  where
+    dup_repo_id in (` + ids + `)
+  `,
+			values: []*int64{&rspl.Commits, &rspl.Committers},
+		},
+		{
+			query: `
+  select
+    coalesce(sum(lang_loc), 0)::bigint
+  from
+    gha_repos_langs
+  where
+    repo_name in (select name from gha_repos where ` + cond + `)
+  `,
+			values: []*int64{&rspl.BOC},
+		},
+		{
+			query: `
+  select
+    count(distinct a.country_id)
+  from
+    gha_events e,
+    gha_actors a
+  where
+    e.actor_id = a.id
+    and e.repo_id in (` + ids + `)
+    and e.type in (` + contributions + `)
+  `,
+			values: []*int64{&rspl.Countries},
+		},
+		{
+			query: `
+  select
+    count(distinct af.company_name)
+  from
+    gha_events e,
+    gha_actors_affiliations af
+  where
+    e.actor_id = af.actor_id
+    and af.dt_from <= e.created_at
+    and af.dt_to > e.created_at
+    and af.company_name not in ('Independent', 'Unknown', 'NotFound', '')
+    and e.repo_id in (` + ids + `)
+    and e.type in (` + contributions + `)
+  `,
+			values: []*int64{&rspl.Companies},
+		},
+	}
+	// Stargazers come from WatchEvents which are never removed, apply corrections from the stars reconciliation
+	var correction int64
+	exists, err := tableExists(c, ctx, "gha_star_corrections")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if exists {
+		queries = append(
+			queries,
+			repoStatsQuery{
+				query:  "select coalesce(sum(correction), 0) from gha_star_corrections where repo_id in (" + ids + ")",
+				values: []*int64{&correction},
+			},
+		)
+	}
+	// Each query sets its own fields, so they can run in parallel
+	ch := make(chan error)
+	for _, q := range queries {
+		go func(ch chan error, query string, values []*int64) {
+			dest := []interface{}{}
+			for _, value := range values {
+				dest = append(dest, value)
+			}
+			ch <- lib.QueryRowSQL(c, ctx, query, arg).Scan(dest...)
+		}(ch, q.query, q.values)
+	}
+	for range queries {
+		e := <-ch
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	rspl.Stargazers += correction
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(rspl)
+	repoStatsCacheMtx.Lock()
+	repoStatsCache[key] = repoStatsCacheEntry{dt: time.Now(), repoStats: rspl}
+	repoStatsCacheMtx.Unlock()
+}
+
+func apiCountriesStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.CountriesStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	key := [5]string{project, db, from, to, params["repository_group"]}
+	countriesStatsCacheMtx.Lock()
+	data, ok := countriesStatsCache[key]
+	countriesStatsCacheMtx.Unlock()
+	if ok {
+		age := time.Now().Sub(data.dt).Seconds()
+		if age < 43200 {
+			lib.Printf("Using cached value for %+v (age is %.0f < 43200)\n", key, age)
+			setResponseCached(w)
+			// Cache key uses UTC range, return from and to as given in this request
+			cspl := data.countriesStats
+			cspl.From, cspl.To = params["from"], params["to"]
+			w.WriteHeader(http.StatusOK)
+			jsoniter.NewEncoder(w).Encode(cspl)
+			return
+		}
+		countriesStatsCacheMtx.Lock()
+		delete(countriesStatsCache, key)
+		countriesStatsCacheMtx.Unlock()
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	query := `
+  select
+    a.country_id,
+    max(a.country_name) as country_name,
+    count(distinct e.actor_id) as contributors,
+    count(distinct e.id) as contributions
+  from
+    gha_events e,
+    gha_actors a
+  where
+    e.actor_id = a.id
+    and a.country_id is not null
+    and a.country_id != ''
+    and e.created_at >= $1
+    and e.created_at < $2
+    and e.type in (
+      'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent',
+      'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'
+    )
+  `
+	args := []interface{}{from, to}
+	if params["repository_group"] != lib.ALL {
+		query += `
+    and (e.repo_id, e.dup_repo_name) in (
+      select
+        id,
+        name
+      from
+        gha_repos
+      where
+        coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $3
+    )
+  `
+		args = append(args, params["repository_group"])
+	}
+	query += `
+  group by
+    a.country_id
+  order by
+    contributions desc,
+    country_name asc
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	cspl := countriesStatsPayload{
+		Project:         project,
+		DB:              db,
+		From:            params["from"],
+		To:              params["to"],
+		RepositoryGroup: params["repository_group"],
+		Countries:       []string{},
+		CountryCodes:    []string{},
+		Contributors:    []int64{},
+		Contributions:   []int64{},
+	}
+	var (
+		code          string
+		name          *string
+		contributors  int64
+		contributions int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&code, &name, &contributors, &contributions)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		country := code
+		if name != nil && *name != "" {
+			country = *name
+		}
+		cspl.Countries = append(cspl.Countries, country)
+		cspl.CountryCodes = append(cspl.CountryCodes, code)
+		cspl.Contributors = append(cspl.Contributors, contributors)
+		cspl.Contributions = append(cspl.Contributions, contributions)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(cspl)
+	countriesStatsCacheMtx.Lock()
+	countriesStatsCache[key] = countriesStatsCacheEntry{dt: time.Now(), countriesStats: cspl}
+	countriesStatsCacheMtx.Unlock()
+}
+
+func apiDataQuality(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.DataQuality
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	indicators, err := getPayloadStringArrayParam("indicators", w, payload, true, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(indicators) == 0 {
+		indicators = lib.DataQualityIndicators
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	dqpl := dataQualityPayload{
+		Project:    project,
+		DB:         db,
+		From:       params["from"],
+		To:         params["to"],
+		Indicators: indicators,
+		Indicator:  []string{},
+		TimeStamps: []time.Time{},
+		Values:     []float64{},
+	}
+	// Table is created by the first sync computing data quality, there is no data before that
+	exists, err := tableExists(c, ctx, "gha_data_quality")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(dqpl)
+		return
+	}
+	query := `
+  select
+    time,
+    indicator,
+    value
+  from
+    gha_data_quality
+  where
+    time >= $1
+    and time < $2
+    and indicator in ` + lib.NArray(len(indicators), 2) + `
+  order by
+    time,
+    indicator
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, toInterfaceArray([]string{from, to}, indicators, []string{})...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		t         time.Time
+		indicator string
+		value     float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&t, &indicator, &value)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		dqpl.TimeStamps = append(dqpl.TimeStamps, t)
+		dqpl.Indicator = append(dqpl.Indicator, indicator)
+		dqpl.Values = append(dqpl.Values, value)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(dqpl)
+}
+
+func apiCompanyProfile(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.CompanyProfile
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"range": "", "company": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	company := params["company"]
+	limit := 10
+	sLimit, _ := getPayloadStringParam("limit", w, payload, true)
+	if sLimit != "" {
+		limit, err = strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			err = fmt.Errorf("invalid limit value: '%s'", sLimit)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	// Company rank per metric uses the same series as CompaniesTable API
+	metricMap, err := metricNameToValueMap(db, lib.CompaniesTable)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	seriesToMetric := make(map[string]string)
+	for name, value := range metricMap {
+		seriesToMetric["hcom"+value] = name
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	period, _, err := periodNameToValue(c, ctx, params["range"], false, time.UTC)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := companyProfilePayload{Project: project, DB: db, Range: params["range"], Company: company}
+	query := `
+  select
+    sub.series,
+    sub.rank,
+    sub.value
+  from (
+    select series,
+      name,
+      value,
+      row_number() over (partition by series order by value desc) - 1 as rank
+    from
+      shcom
+    where
+      period = $1
+      and series like 'hcom%'
+  ) sub
+  where
+    sub.name = $2
+  order by
+    sub.series
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, period, company)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var (
+		series string
+		rank   int
+		number float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&series, &rank, &number)
+		if err != nil {
+			_ = rows.Close()
+			returnError(apiName, w, err)
+			return
+		}
+		metric, ok := seriesToMetric[series]
+		if !ok {
+			continue
+		}
+		pl.Metric = append(pl.Metric, metric)
+		pl.Rank = append(pl.Rank, rank)
+		pl.Number = append(pl.Number, number)
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(pl.Metric) == 0 {
+		err = fmt.Errorf("company '%s' not found in project for range '%s'", company, params["range"])
+		returnError(apiName, w, err)
+		return
+	}
+	// Active developers and top contributors - the same data as DevActCntComp API for all repository groups and countries
+	query = `
+  select
+    split_part(name, '$$$', 1),
+    value
+  from
+    shdev
+  where
+    series = 'hdev_contributionsallall'
+    and period = $1
+    and split_part(name, '$$$', 2) = $2
+  order by
+    value desc,
+    name
+  `
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, period, company)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var (
+		login    string
+		contribs int
+	)
+	for rows.Next() {
+		err = rows.Scan(&login, &contribs)
+		if err != nil {
+			_ = rows.Close()
+			returnError(apiName, w, err)
+			return
+		}
+		pl.ActiveDevelopers++
+		if len(pl.TopContributors) < limit {
+			pl.TopContributors = append(pl.TopContributors, login)
+			pl.TopContributions = append(pl.TopContributions, contribs)
+		}
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Repository groups where the company is active
+	query = `
+  select
+    t.all_repo_group_name,
+    sum(s.value)
+  from
+    shdev s,
+    tall_repo_groups t
+  where
+    s.series = 'hdev_contributions' || t.all_repo_group_value || 'all'
+    and s.period = $1
+    and split_part(s.name, '$$$', 2) = $2
+    and t.all_repo_group_value <> 'all'
+  group by
+    t.all_repo_group_name
+  order by
+    2 desc,
+    1
+  `
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, period, company)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var repoGroup string
+	for rows.Next() {
+		err = rows.Scan(&repoGroup, &number)
+		if err != nil {
+			_ = rows.Close()
+			returnError(apiName, w, err)
+			return
+		}
+		pl.RepositoryGroups = append(pl.RepositoryGroups, repoGroup)
+		pl.RepositoryGroupsContribs = append(pl.RepositoryGroupsContribs, number)
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Contributions trend over the last 4 quarters - the same data as ComStatsRepoGrp API
+	// companies are columns in scompany_activity, to_jsonb is used so a missing column means 0 instead of an error
+	query = `
+  select
+    sub.time,
+    sub.value
+  from (
+    select time,
+      coalesce((to_jsonb(s) ->> $1)::float, 0) as value
+    from
+      scompany_activity s
+    where
+      series = 'companyallcontributions'
+      and period = 'q'
+    order by
+      time desc
+    limit 4
+  ) sub
+  order by
+    sub.time
+  `
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, company)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var quarter time.Time
+	for rows.Next() {
+		err = rows.Scan(&quarter, &number)
+		if err != nil {
+			_ = rows.Close()
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Quarters = append(pl.Quarters, quarter)
+		pl.QuartersContribs = append(pl.QuartersContribs, number)
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// ciRate - returns % of successful runs among runs that succeeded or failed (cancelled and skipped runs are not counted)
+func ciRate(success, failure int) float64 {
+	if success+failure == 0 {
+		return 0
+	}
+	return float64(success) * 100.0 / float64(success+failure)
+}
+
+// ciAvg - returns average duration in seconds
+func ciAvg(sum float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func apiCIStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.CIStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_workflow_runs")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("workflow runs are not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	query := `
+  select
+    repo_name,
+    count(*),
+    count(*) filter (where conclusion = 'success'),
+    count(*) filter (where conclusion in ('failure', 'timed_out', 'startup_failure')),
+    coalesce(sum(duration_seconds), 0),
+    count(duration_seconds)
+  from
+    gha_workflow_runs
+  where
+    created_at >= $1
+    and created_at < $2
+  `
+	args := []interface{}{from, to}
+	if repository != "" {
+		query += "    and repo_name = $3\n"
+		args = append(args, repository)
+	}
+	query += `  group by
+    repo_name
+  order by
+    2 desc,
+    1
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := ciStatsPayload{
+		Project:            project,
+		DB:                 db,
+		From:               params["from"],
+		To:                 params["to"],
+		Repository:         repository,
+		Repositories:       []string{},
+		RepositoriesRuns:   []int{},
+		RepositoriesRate:   []float64{},
+		RepositoriesAvgDur: []float64{},
+	}
+	var (
+		repo                                  string
+		runs, success, failure, nDurations    int
+		durations                             float64
+		allSuccess, allFailure, allNDurations int
+		allDurations                          float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&repo, &runs, &success, &failure, &durations, &nDurations)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Repositories = append(pl.Repositories, repo)
+		pl.RepositoriesRuns = append(pl.RepositoriesRuns, runs)
+		pl.RepositoriesRate = append(pl.RepositoriesRate, ciRate(success, failure))
+		pl.RepositoriesAvgDur = append(pl.RepositoriesAvgDur, ciAvg(durations, nDurations))
+		pl.Runs += runs
+		allSuccess += success
+		allFailure += failure
+		allDurations += durations
+		allNDurations += nDurations
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl.SuccessRate = ciRate(allSuccess, allFailure)
+	pl.AvgDuration = ciAvg(allDurations, allNDurations)
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiTraffic - returns daily repository clones and views synced by ghapi2db (GHA2DB_GHAPITRAFFIC)
+func apiTraffic(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Traffic
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_repo_traffic")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("repository traffic is not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	query := `
+  select
+    dt,
+    sum(clones),
+    sum(clones_uniques),
+    sum(views),
+    sum(views_uniques)
+  from
+    gha_repo_traffic
+  where
+    dt >= $1
+    and dt < $2
+  `
+	args := []interface{}{from, to}
+	if repository != "" {
+		query += "    and repo_name = $3\n"
+		args = append(args, repository)
+	}
+	query += `  group by
+    dt
+  order by
+    dt
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := trafficPayload{
+		Project:       project,
+		DB:            db,
+		From:          params["from"],
+		To:            params["to"],
+		Repository:    repository,
+		Days:          []time.Time{},
+		DaysClones:    []int{},
+		DaysClonesUnq: []int{},
+		DaysViews:     []int{},
+		DaysViewsUnq:  []int{},
+	}
+	var (
+		day                                        time.Time
+		clones, clonesUniques, views, viewsUniques int
+	)
+	for rows.Next() {
+		err = rows.Scan(&day, &clones, &clonesUniques, &views, &viewsUniques)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Days = append(pl.Days, day)
+		pl.DaysClones = append(pl.DaysClones, clones)
+		pl.DaysClonesUnq = append(pl.DaysClonesUnq, clonesUniques)
+		pl.DaysViews = append(pl.DaysViews, views)
+		pl.DaysViewsUnq = append(pl.DaysViewsUnq, viewsUniques)
+		pl.Clones += clones
+		pl.Views += views
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiMilestoneProgress - returns milestones burn-down: open and closed issues snapshots synced by ghapi2db (GHA2DB_GHAPIMILESTONES)
+// Milestones with the same title in different repositories (release milestones) are summed
+func apiMilestoneProgress(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.MilestoneProgress
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	milestone, _ := getPayloadStringParam("milestone", w, payload, true)
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_milestone_progress")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("milestones progress is not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	query := `
+  select
+    title,
+    dt,
+    case bool_or(state = 'open') when true then 'open' else 'closed' end,
+    min(due_on),
+    count(distinct repo_name),
+    sum(open_issues),
+    sum(closed_issues)
+  from
+    gha_milestone_progress
+  where
+    dt >= $1
+    and dt < $2
+  `
+	args := []interface{}{from, to}
+	if repository != "" {
+		args = append(args, repository)
+		query += fmt.Sprintf("    and repo_name = $%d\n", len(args))
+	}
+	if milestone != "" {
+		args = append(args, milestone)
+		query += fmt.Sprintf("    and title = $%d\n", len(args))
+	}
+	query += `  group by
+    title,
+    dt
+  order by
+    title,
+    dt
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := milestoneProgressPayload{
+		Project:    project,
+		DB:         db,
+		From:       params["from"],
+		To:         params["to"],
+		Repository: repository,
+		Milestone:  milestone,
+		Milestones: []milestoneProgress{},
+	}
+	var (
+		title, state              string
+		dt                        time.Time
+		dueOn                     *time.Time
+		repos, open, closedIssues int
+	)
+	for rows.Next() {
+		err = rows.Scan(&title, &dt, &state, &dueOn, &repos, &open, &closedIssues)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		n := len(pl.Milestones)
+		if n == 0 || pl.Milestones[n-1].Title != title {
+			pl.Milestones = append(pl.Milestones, milestoneProgress{Title: title, Dts: []time.Time{}, OpenIssues: []int{}, ClosedIssues: []int{}})
+			n++
+		}
+		// State, due date and number of repositories are from the latest snapshot
+		m := &pl.Milestones[n-1]
+		m.State, m.DueOn, m.Repositories = state, dueOn, repos
+		m.Dts = append(m.Dts, dt)
+		m.OpenIssues = append(m.OpenIssues, open)
+		m.ClosedIssues = append(m.ClosedIssues, closedIssues)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiForkActivity - returns active forks of project repositories with numbers of commits they are ahead/behind upstream
+func apiForkActivity(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ForkActivity
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_fork_activity")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("fork activity is not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	query := `
+  select
+    repo_name,
+    fork_name,
+    branch,
+    pushed_at,
+    stargazers,
+    ahead_by,
+    behind_by
+  from
+    gha_fork_activity
+  where
+    pushed_at >= now() - '%d days'::interval
+  `
+	query = fmt.Sprintf(query, forkActivityDays)
+	args := []interface{}{}
+	if repository != "" {
+		query += "    and repo_name = $1\n"
+		args = append(args, repository)
+	}
+	query += `  order by
+    ahead_by desc,
+    pushed_at desc
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := forkActivityPayload{
+		Project:    project,
+		DB:         db,
+		Repository: repository,
+		Repos:      []string{},
+		ForkNames:  []string{},
+		Branches:   []string{},
+		PushedAt:   []time.Time{},
+		Stargazers: []int{},
+		AheadBy:    []int{},
+		BehindBy:   []int{},
+	}
+	var (
+		repo, fork, branch        string
+		pushedAt                  time.Time
+		stargazers, ahead, behind int
+	)
+	for rows.Next() {
+		err = rows.Scan(&repo, &fork, &branch, &pushedAt, &stargazers, &ahead, &behind)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Repos = append(pl.Repos, repo)
+		pl.ForkNames = append(pl.ForkNames, fork)
+		pl.Branches = append(pl.Branches, branch)
+		pl.PushedAt = append(pl.PushedAt, pushedAt)
+		pl.Stargazers = append(pl.Stargazers, stargazers)
+		pl.AheadBy = append(pl.AheadBy, ahead)
+		pl.BehindBy = append(pl.BehindBy, behind)
+		pl.Forks++
+		if ahead > 0 {
+			pl.ForksAhead++
+			pl.CommitsAhead += ahead
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiMentionGraph - who mentions whom: edges (actor -> mentioned login) weighted by number of issues, PRs, reviews and comments
+// Also returns totals of mentions and issue/PR cross-references made in a given range
+func apiMentionGraph(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.MentionGraph
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	limit := defaultMentionGraphLimit
+	sLimit, _ := getPayloadStringParam("limit", w, payload, true)
+	if sLimit != "" {
+		limit, err = strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			err = fmt.Errorf("invalid limit value: '%s'", sLimit)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_mentions")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("mentions are not collected for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	cond := ""
+	args := []interface{}{from, to}
+	if repository != "" {
+		args = append(args, repository)
+		cond = fmt.Sprintf("    and repo_name = $%d\n", len(args))
+	}
+	pl := mentionGraphPayload{
+		Project:    project,
+		DB:         db,
+		From:       params["from"],
+		To:         params["to"],
+		Repository: repository,
+		FromLogins: []string{},
+		ToLogins:   []string{},
+		Weights:    []int64{},
+	}
+	query := `
+  select
+    count(*),
+    count(distinct lower(actor_login)),
+    count(distinct lower(mentioned_login))
+  from
+    gha_mentions
+  where
+    created_at >= $1
+    and created_at < $2
+` + cond
+	err = lib.QueryRowSQL(c, ctx, query, args...).Scan(&pl.Mentions, &pl.Actors, &pl.Mentioned)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	query = `
+  select
+    count(*),
+    count(*) filter (where lower(ref_repo_name) <> lower(repo_name))
+  from
+    gha_issue_refs
+  where
+    created_at >= $1
+    and created_at < $2
+` + cond
+	err = lib.QueryRowSQL(c, ctx, query, args...).Scan(&pl.IssueRefs, &pl.CrossRepoRefs)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	query = `
+  select
+    min(actor_login),
+    min(mentioned_login),
+    count(*) as weight
+  from
+    gha_mentions
+  where
+    created_at >= $1
+    and created_at < $2
+` + cond + `  group by
+    lower(actor_login),
+    lower(mentioned_login)
+  order by
+    weight desc,
+    1,
+    2
+  limit ` + strconv.Itoa(limit) + `
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		fromLogin, toLogin string
+		weight             int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&fromLogin, &toLogin, &weight)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.FromLogins = append(pl.FromLogins, fromLogin)
+		pl.ToLogins = append(pl.ToLogins, toLogin)
+		pl.Weights = append(pl.Weights, weight)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiRenamedOrDeletedRepos - returns repositories marked as not found by ghapi2db, with other names known for their IDs (possible renames)
+
+// apiReleaseStats - release cadence: releases per quarter, days between releases, pre-release ratio and lead time
+// Lead time is the time from the first commit pushed after the previous release of the same repository to the release date
+func apiReleaseStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ReleaseStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repoGroup, _ := getPayloadStringParam("repository_group", w, payload, true)
+	if repoGroup == "" {
+		repoGroup = lib.ALL
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Release date is its publish date (creation date when not published), drafts are skipped
+	// Days between releases and lead time use the previous release of the same repository, even when it is before the range
+	cond := ""
+	args := []interface{}{from, to}
+	if repoGroup != lib.ALL {
+		cond = `
+      and (r.dup_repo_id, r.dup_repo_name) in (
+        select
+          id,
+          name
+        from
+          gha_repos
+        where
+          coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $3
+      )`
+		args = append(args, repoGroup)
+	}
+	query := `
+  with releases as (
+    select distinct on (r.id)
+      r.id,
+      r.dup_repo_name as repo_name,
+      r.prerelease,
+      coalesce(r.published_at, r.created_at) as dt
+    from
+      gha_releases r
+    where
+      not r.draft` + cond + `
+    order by
+      r.id,
+      r.dup_created_at desc
+  ), ordered as (
+    select
+      repo_name,
+      prerelease,
+      dt,
+      lag(dt) over (partition by repo_name order by dt) as prev_dt
+    from
+      releases
+  ), stats as (
+    select
+      o.dt,
+      o.prerelease,
+      extract(epoch from o.dt - o.prev_dt) / 86400.0 as days_between,
+      extract(epoch from o.dt - (
+        select
+          min(c.dup_created_at)
+        from
+          gha_commits c
+        where
+          c.dup_repo_name = o.repo_name
+          and c.dup_created_at > o.prev_dt
+          and c.dup_created_at <= o.dt
+      )) / 86400.0 as lead_days
+    from
+      ordered o
+    where
+      o.dt >= $1
+      and o.dt < $2
+  )
+  select
+    date_trunc('quarter', dt) as quarter,
+    count(*),
+    count(*) filter (where prerelease),
+    avg(days_between),
+    percentile_cont(0.5) within group (order by lead_days)
+  from
+    stats
+  group by
+    grouping sets ((date_trunc('quarter', dt)), ())
+  order by
+    quarter nulls first
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := releaseStatsPayload{
+		Project:                   project,
+		DB:                        db,
+		From:                      params["from"],
+		To:                        params["to"],
+		RepositoryGroup:           repoGroup,
+		Quarters:                  []string{},
+		QuarterReleases:           []int64{},
+		QuarterPrereleases:        []int64{},
+		QuarterAvgDaysBetween:     []*float64{},
+		QuarterMedianLeadTimeDays: []*float64{},
+	}
+	var (
+		quarter          *time.Time
+		releases, pre    int64
+		avgDays, medLead *float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&quarter, &releases, &pre, &avgDays, &medLead)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		// Grouping set without quarter is the whole range summary
+		if quarter == nil {
+			pl.Releases = releases
+			pl.Prereleases = pre
+			if releases > 0 {
+				pl.PrereleaseRatio = float64(pre) / float64(releases)
+			}
+			pl.AvgDaysBetween = avgDays
+			pl.MedianLeadTimeDays = medLead
+			continue
+		}
+		pl.Quarters = append(pl.Quarters, lib.ToYMDDate(*quarter))
+		pl.QuarterReleases = append(pl.QuarterReleases, releases)
+		pl.QuarterPrereleases = append(pl.QuarterPrereleases, pre)
+		pl.QuarterAvgDaysBetween = append(pl.QuarterAvgDaysBetween, avgDays)
+		pl.QuarterMedianLeadTimeDays = append(pl.QuarterMedianLeadTimeDays, medLead)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// defaultRetentionQuarters - ContributorRetention reports this many quarters after cohort quarter, unless 'quarters' is given
+const defaultRetentionQuarters = 4
+
+// apiContributorRetention - contributor retention cohorts: contributors whose first contribution was in a given quarter
+// and how many of them contributed again in each of the following quarters
+func apiContributorRetention(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.ContributorRetention
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repoGroup, _ := getPayloadStringParam("repository_group", w, payload, true)
+	if repoGroup == "" {
+		repoGroup = lib.ALL
+	}
+	quarters := defaultRetentionQuarters
+	sQuarters, _ := getPayloadStringParam("quarters", w, payload, true)
+	if sQuarters != "" {
+		quarters, err = strconv.Atoi(sQuarters)
+		if err != nil || quarters < 1 || quarters > 12 {
+			err = fmt.Errorf("invalid quarters value: '%s', must be 1-12", sQuarters)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Cohort is the quarter of contributor's first contribution (to a given repository group), even when it is before the range
+	// Only cohorts starting in the range are returned, following quarters can be after the range
+	cond := ""
+	args := []interface{}{from, to, quarters}
+	if repoGroup != lib.ALL {
+		cond = `
+      and (e.repo_id, e.dup_repo_name) in (
+        select
+          id,
+          name
+        from
+          gha_repos
+        where
+          coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $4
+      )`
+		args = append(args, repoGroup)
+	}
+	query := `
+  with active as (
+    select
+      e.actor_id,
+      date_trunc('quarter', e.created_at) as quarter
+    from
+      gha_events e
+    where
+      e.type in (
+        'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent',
+        'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'
+      )` + cond + `
+    group by
+      e.actor_id,
+      date_trunc('quarter', e.created_at)
+  ), cohorts as (
+    select
+      actor_id,
+      min(quarter) as cohort
+    from
+      active
+    group by
+      actor_id
+  ), offsets as (
+    select
+      c.cohort,
+      ((date_part('year', a.quarter) - date_part('year', c.cohort)) * 4 + date_part('quarter', a.quarter) - date_part('quarter', c.cohort))::int as n
+    from
+      cohorts c,
+      active a
+    where
+      a.actor_id = c.actor_id
+      and c.cohort >= $1
+      and c.cohort < $2
+  )
+  select
+    cohort,
+    n,
+    count(*)
+  from
+    offsets
+  where
+    n <= $3
+  group by
+    cohort,
+    n
+  order by
+    cohort,
+    n
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := contributorRetentionPayload{
+		Project:         project,
+		DB:              db,
+		From:            params["from"],
+		To:              params["to"],
+		RepositoryGroup: repoGroup,
+		Quarters:        quarters,
+		Cohorts:         []string{},
+		Contributors:    []int64{},
+		Retained:        [][]*int64{},
+		Retention:       [][]*float64{},
+	}
+	now := time.Now()
+	var (
+		cohort time.Time
+		n      int
+		number int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&cohort, &n, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		// Every cohort contributor is active in the cohort quarter, so each cohort starts with n = 0
+		if n == 0 {
+			retained := make([]*int64, quarters)
+			retention := make([]*float64, quarters)
+			// Quarters that have not started yet are null
+			for i := range retained {
+				if cohort.AddDate(0, 3*(i+1), 0).After(now) {
+					break
+				}
+				zero, zeroRatio := int64(0), 0.0
+				retained[i], retention[i] = &zero, &zeroRatio
+			}
+			pl.Cohorts = append(pl.Cohorts, lib.ToYMDDate(cohort))
+			pl.Contributors = append(pl.Contributors, number)
+			pl.Retained = append(pl.Retained, retained)
+			pl.Retention = append(pl.Retention, retention)
+			continue
+		}
+		last := len(pl.Cohorts) - 1
+		if last < 0 || pl.Retained[last][n-1] == nil {
+			continue
+		}
+		*pl.Retained[last][n-1] = number
+		*pl.Retention[last][n-1] = float64(number) / float64(pl.Contributors[last])
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiLabelLifecycle - time spent in labels: label applied periods (added -> removed, or still applied) started in a given range
+func apiLabelLifecycle(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.LabelLifecycle
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	labels, err := getPayloadStringArrayParam("labels", w, payload, true, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_issue_label_history")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("label history is not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	// Every 'added' is paired with the next change of the same issue label, when it is 'removed' the period ends then,
+	// when there is no next change label is still applied (its period ends now), repeated 'added' is skipped
+	cond := ""
+	args := []interface{}{from, to}
+	if repository != "" {
+		args = append(args, repository)
+		cond += fmt.Sprintf("      and repo_name = $%d\n", len(args))
+	}
+	if len(labels) > 0 {
+		cond += "      and label_name in " + lib.NArray(len(labels), len(args)) + "\n"
+		for _, label := range labels {
+			args = append(args, label)
+		}
+	}
+	query := `
+  with changes as (
+    select
+      issue_id,
+      label_name,
+      action,
+      dt,
+      lead(action) over w as next_action,
+      lead(dt) over w as next_dt
+    from
+      gha_issue_label_history
+    where
+      true
+` + cond + `    window w as (partition by issue_id, label_name order by dt, event_id)
+  ), spans as (
+    select
+      label_name,
+      issue_id,
+      next_dt is null as still_applied,
+      extract(epoch from coalesce(next_dt, now()) - dt) / 3600.0 as hours
+    from
+      changes
+    where
+      action = 'added'
+      and (next_action is null or next_action = 'removed')
+      and dt >= $1
+      and dt < $2
+  )
+  select
+    label_name,
+    count(*),
+    count(distinct issue_id),
+    count(*) filter (where still_applied),
+    avg(hours),
+    percentile_cont(0.5) within group (order by hours),
+    percentile_cont(0.85) within group (order by hours)
+  from
+    spans
+  group by
+    label_name
+  order by
+    count(*) desc,
+    label_name
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := labelLifecyclePayload{
+		Project:           project,
+		DB:                db,
+		From:              params["from"],
+		To:                params["to"],
+		Repository:        repository,
+		Labels:            []string{},
+		Periods:           []int64{},
+		Issues:            []int64{},
+		StillApplied:      []int64{},
+		AvgHours:          []float64{},
+		MedianHours:       []float64{},
+		Percentile85Hours: []float64{},
+	}
+	var (
+		label                  string
+		periods, issues, still int64
+		avg, median, p85       float64
+	)
+	for rows.Next() {
+		err = rows.Scan(&label, &periods, &issues, &still, &avg, &median, &p85)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Labels = append(pl.Labels, label)
+		pl.Periods = append(pl.Periods, periods)
+		pl.Issues = append(pl.Issues, issues)
+		pl.StillApplied = append(pl.StillApplied, still)
+		pl.AvgHours = append(pl.AvgHours, avg)
+		pl.MedianHours = append(pl.MedianHours, median)
+		pl.Percentile85Hours = append(pl.Percentile85Hours, p85)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiLinkedWork - PRs merged in a given range linked to issues they close (gha_pr_issues synced by ghapi2db)
+// Hours are counted from issue open to closing PR merge
+func apiLinkedWork(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.LinkedWork
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repository, _ := getPayloadStringParam("repository", w, payload, true)
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_pr_issues")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		err = fmt.Errorf("PR linked issues are not synced for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	cond := ""
+	args := []interface{}{from, to}
+	if repository != "" {
+		args = append(args, repository)
+		cond += fmt.Sprintf("      and pr.dup_repo_name = $%d\n", len(args))
+	}
+	// PR has one gha_pull_requests row per event, so merged PRs are grouped by their issue ID (gha_pr_issues key)
+	query := `
+  with merged as (
+    select
+      ipr.issue_id as pr_issue_id,
+      max(pr.merged_at) as merged_at
+    from
+      gha_pull_requests pr,
+      gha_issues_pull_requests ipr
+    where
+      ipr.pull_request_id = pr.id
+      and pr.merged_at >= $1
+      and pr.merged_at < $2
+` + cond + `    group by
+      ipr.issue_id
+  ), links as (
+    select
+      m.pr_issue_id,
+      m.merged_at,
+      l.issue_repo_name,
+      l.issue_number,
+      l.source,
+      (select min(i.created_at) from gha_issues i where i.id = l.issue_id) as opened_at
+    from
+      merged m,
+      gha_pr_issues l
+    where
+      l.pr_issue_id = m.pr_issue_id
+  ), hours as (
+    select
+      extract(epoch from merged_at - opened_at) / 3600.0 as hours
+    from
+      links
+    where
+      opened_at is not null
+      and opened_at < merged_at
+  )
+  select
+    (select count(*) from merged),
+    (select count(distinct pr_issue_id) from links),
+    (select count(distinct (issue_repo_name, issue_number)) from links),
+    (select count(*) from links where source = 'body'),
+    (select count(*) from links where source = 'timeline'),
+    (select avg(hours) from hours),
+    (select percentile_cont(0.5) within group (order by hours) from hours),
+    (select percentile_cont(0.85) within group (order by hours) from hours)
+  `
+	pl := linkedWorkPayload{
+		Project:    project,
+		DB:         db,
+		From:       params["from"],
+		To:         params["to"],
+		Repository: repository,
+	}
+	err = lib.QueryRowSQL(c, ctx, query, args...).Scan(
+		&pl.MergedPRs,
+		&pl.LinkedPRs,
+		&pl.LinkedIssues,
+		&pl.BodyLinks,
+		&pl.TimelineLinks,
+		&pl.AvgHours,
+		&pl.MedianHours,
+		&pl.Percentile85Hours,
+	)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if pl.MergedPRs > 0 {
+		pl.LinkedRatio = float64(pl.LinkedPRs) / float64(pl.MergedPRs)
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// apiTagCloud - most used issue/PR labels in a given range, with quarter-over-quarter trend (last quarter of the range vs the quarter before it)
+// Label is used by an issue when any of its events in a period has that label (gha_issues_labels)
+func apiTagCloud(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.TagCloud
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	repoGroup, _ := getPayloadStringParam("repository_group", w, payload, true)
+	if repoGroup == "" {
+		repoGroup = lib.ALL
+	}
+	kind, _ := getPayloadStringParam("kind", w, payload, true)
+	if kind == "" {
+		kind = "all"
+	}
+	isPR, ok := tagCloudKinds[kind]
+	if !ok {
+		err = fmt.Errorf("invalid kind value: '%s', allowed: all, issues, prs", kind)
+		returnError(apiName, w, err)
+		return
+	}
+	limit := defaultTagCloudLimit
+	sLimit, _ := getPayloadStringParam("limit", w, payload, true)
+	if sLimit != "" {
+		limit, err = strconv.Atoi(sLimit)
+		if err != nil || limit < 1 {
+			err = fmt.Errorf("invalid limit value: '%s'", sLimit)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Trend compares the last quarter of the range with the previous one, they can start before the range
+	dtTo := lib.TimeParseAny(to)
+	quarterFrom := dtTo.AddDate(0, -3, 0)
+	prevQuarterFrom := dtTo.AddDate(0, -6, 0)
+	dtFrom := lib.TimeParseAny(from)
+	if prevQuarterFrom.Before(dtFrom) {
+		dtFrom = prevQuarterFrom
+	}
+	cond := ""
+	args := []interface{}{from, to, quarterFrom, prevQuarterFrom, dtFrom}
+	if repoGroup != lib.ALL {
+		args = append(args, repoGroup)
+		cond += fmt.Sprintf(`
+      and (il.dup_repo_id, il.dup_repo_name) in (
+        select
+          id,
+          name
+        from
+          gha_repos
+        where
+          coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $%d
+      )`, len(args))
+	}
+	if isPR != "" {
+		cond += `
+      and il.issue_id in (
+        select
+          id
+        from
+          gha_issues
+        where
+          is_pull_request = ` + isPR + `
+      )`
+	}
+	args = append(args, limit)
+	query := `
+  with labels as (
+    select
+      il.dup_label_name as label_name,
+      il.issue_id,
+      il.dup_created_at as dt
+    from
+      gha_issues_labels il
+    where
+      il.dup_created_at >= $5
+      and il.dup_created_at < $2` + cond + `
+  )
+  select
+    label_name,
+    count(distinct issue_id) filter (where dt >= $1) as issues,
+    count(distinct issue_id) filter (where dt >= $3),
+    count(distinct issue_id) filter (where dt >= $4 and dt < $3)
+  from
+    labels
+  group by
+    label_name
+  having
+    count(distinct issue_id) filter (where dt >= $1) > 0
+  order by
+    issues desc,
+    label_name
+  limit
+    ` + fmt.Sprintf("$%d", len(args)) + `
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := tagCloudPayload{
+		Project:         project,
+		DB:              db,
+		From:            params["from"],
+		To:              params["to"],
+		RepositoryGroup: repoGroup,
+		Kind:            kind,
+		QuarterFrom:     lib.ToYMDHMSDate(quarterFrom),
+		PrevQuarterFrom: lib.ToYMDHMSDate(prevQuarterFrom),
+		Labels:          []string{},
+		Issues:          []int64{},
+		Quarter:         []int64{},
+		PrevQuarter:     []int64{},
+		Trend:           []*float64{},
+	}
+	var (
+		label                  string
+		issues, quarter, prevQ int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&label, &issues, &quarter, &prevQ)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		// Trend is relative change, it is null when label was not used in the previous quarter
+		var trend *float64
+		if prevQ > 0 {
+			t := float64(quarter-prevQ) / float64(prevQ)
+			trend = &t
+		}
+		pl.Labels = append(pl.Labels, label)
+		pl.Issues = append(pl.Issues, issues)
+		pl.Quarter = append(pl.Quarter, quarter)
+		pl.PrevQuarter = append(pl.PrevQuarter, prevQ)
+		pl.Trend = append(pl.Trend, trend)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiRenamedOrDeletedRepos(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.RenamedOrDeletedRepos
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	pl := renamedOrDeletedReposPayload{
+		Project:     project,
+		DB:          db,
+		Repos:       []string{},
+		IDs:         []int64{},
+		NotFound:    []int{},
+		LastChecked: []time.Time{},
+		OtherNames:  [][]string{},
+	}
+	// Repository status columns are added by ghapi2db, there is nothing to report before it runs
+	var hasStatus bool
+	err = lib.QueryRowSQL(
+		c,
+		ctx,
+		"select exists(select 1 from information_schema.columns where table_name = 'gha_repos' and column_name = 'status')",
+	).Scan(&hasStatus)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !hasStatus {
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(pl)
+		return
+	}
+	query := `
+  select
+    r.name,
+    r.id,
+    r.not_found,
+    coalesce(r.last_checked, r.updated_at),
+    coalesce(string_agg(distinct o.name, ',') filter (where o.name is not null), '')
+  from
+    gha_repos r
+  left join
+    gha_repos o
+  on
+    o.id = r.id
+    and o.name <> r.name
+    and o.status is distinct from $1
+  where
+    r.status = $1
+  group by
+    r.name,
+    r.id,
+    r.not_found,
+    r.last_checked,
+    r.updated_at
+  order by
+    4 desc,
+    1
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, lib.RepoNotFoundStatus)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		repo, others string
+		id           int64
+		notFound     int
+		lastChecked  time.Time
+	)
+	for rows.Next() {
+		err = rows.Scan(&repo, &id, &notFound, &lastChecked, &others)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		names := []string{}
+		if others != "" {
+			names = strings.Split(others, ",")
+		}
+		pl.Repos = append(pl.Repos, repo)
+		pl.IDs = append(pl.IDs, id)
+		pl.NotFound = append(pl.NotFound, notFound)
+		pl.LastChecked = append(pl.LastChecked, lastChecked)
+		pl.OtherNames = append(pl.OtherNames, names)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiIngestStats(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.IngestStats
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	eventType, err := getPayloadStringParam("event_type", w, payload, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if eventType == "" {
+		eventType = lib.ALL
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	pl := ingestStatsPayload{
+		Project:    project,
+		DB:         db,
+		From:       from,
+		To:         to,
+		EventType:  eventType,
+		Hours:      []time.Time{},
+		Events:     []int64{},
+		Matched:    []int64{},
+		Written:    []int64{},
+		Skewed:     []int64{},
+		Duplicates: []int64{},
+		Excluded:   []int64{},
+	}
+	// Counters are saved by gha2db, there is nothing to report before it runs
+	exists, err := tableExists(c, ctx, "gha_parsed_stats")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if !exists {
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(pl)
+		return
+	}
+	query := `
+  select
+    dt,
+    sum(events),
+    sum(matched),
+    sum(written),
+    sum(skewed),
+    sum(duplicates),
+    sum(excluded)
+  from
+    gha_parsed_stats
+  where
+    dt >= $1
+    and dt < $2
+  `
+	args := []interface{}{from, to}
+	if eventType != lib.ALL {
+		query += `
+    and type = $3
+  `
+		args = append(args, eventType)
+	}
+	query += `
+  group by
+    dt
+  order by
+    dt
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	var (
+		dt                                                     time.Time
+		events, matched, written, skewed, duplicates, excluded int64
+	)
+	for rows.Next() {
+		err = rows.Scan(&dt, &events, &matched, &written, &skewed, &duplicates, &excluded)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		pl.Hours = append(pl.Hours, dt)
+		pl.Events = append(pl.Events, events)
+		pl.Matched = append(pl.Matched, matched)
+		pl.Written = append(pl.Written, written)
+		pl.Skewed = append(pl.Skewed, skewed)
+		pl.Duplicates = append(pl.Duplicates, duplicates)
+		pl.Excluded = append(pl.Excluded, excluded)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// prSizeBuckets - PR size (additions + deletions) buckets, each bucket contains sizes below its limit, last bucket has no limit
+var prSizeBuckets = []struct {
+	name  string
+	limit int
+}{
+	{"XS: 0-9", 10},
+	{"S: 10-49", 50},
+	{"M: 50-249", 250},
+	{"L: 250-999", 1000},
+	{"XL: 1000+", 0},
+}
+
+// prSizePeriods - PRSizeDistribution API period abbreviations
+var prSizePeriods = map[string]string{"d": lib.Day, "w": lib.Week, "m": lib.Month, "q": lib.Quarter, "y": lib.Year}
+
+func apiPRSizeDistribution(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.PRSizeDistribution
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": "", "period": "", "repository_group": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	period, ok := prSizePeriods[params["period"]]
+	if !ok {
+		err = fmt.Errorf("invalid period value: '%s', allowed: d, w, m, q, y", params["period"])
+		returnError(apiName, w, err)
+		return
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// PR is stored once per event, size is taken from the latest known state, merge time from PR creation
+	query := `
+  with prs as (
+    select
+      id,
+      min(created_at) as created_at,
+      max(merged_at) as merged_at,
+      max(coalesce(additions, 0) + coalesce(deletions, 0)) filter (where additions is not null or deletions is not null) as size
+    from
+      gha_pull_requests
+    where
+      created_at >= $1
+      and created_at < $2
+  `
+	args := []interface{}{from, to}
+	if params["repository_group"] != lib.ALL {
+		query += `
+      and (dup_repo_id, dup_repo_name) in (
+        select
+          id,
+          name
+        from
+          gha_repos
+        where
+          coalesce(case repo_group when '' then 'Not specified' else repo_group end, 'Not specified') = $3
+      )
+  `
+		args = append(args, params["repository_group"])
+	}
+	query += `
+    group by
+      id
+  )
+  select
+    date_trunc('` + period + `', created_at) as period,
+    count(*),
+    count(merged_at),
+    percentile_cont(0.5) within group (order by extract(epoch from merged_at - created_at) / 3600.0) filter (where merged_at is not null)`
+	prev := 0
+	for _, bucket := range prSizeBuckets {
+		if bucket.limit > 0 {
+			query += fmt.Sprintf(",\n    count(*) filter (where size >= %d and size < %d)", prev, bucket.limit)
+			prev = bucket.limit
+		} else {
+			query += fmt.Sprintf(",\n    count(*) filter (where size >= %d)", prev)
+		}
+	}
+	query += `
+  from
+    prs
+  group by
+    period
+  order by
+    period
+  `
+	rows, err := lib.QuerySQLLogErr(c, ctx, query, args...)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	pl := prSizeDistributionPayload{
+		Project:          project,
+		DB:               db,
+		From:             params["from"],
+		To:               params["to"],
+		Period:           params["period"],
+		RepositoryGroup:  params["repository_group"],
+		Buckets:          []string{},
+		Periods:          []time.Time{},
+		PRs:              []int64{},
+		BucketsPRs:       [][]int64{},
+		Merged:           []int64{},
+		MergeRate:        []float64{},
+		MedianMergeHours: []float64{},
+	}
+	for _, bucket := range prSizeBuckets {
+		pl.Buckets = append(pl.Buckets, bucket.name)
+	}
+	var (
+		dt          time.Time
+		prs, merged int64
+		median      sql.NullFloat64
+	)
+	for rows.Next() {
+		counts := make([]int64, len(prSizeBuckets))
+		dest := []interface{}{&dt, &prs, &merged, &median}
+		for i := range counts {
+			dest = append(dest, &counts[i])
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		rate := 0.0
+		if prs > 0 {
+			rate = float64(merged) * 100.0 / float64(prs)
+		}
+		pl.Periods = append(pl.Periods, dt)
+		pl.PRs = append(pl.PRs, prs)
+		pl.BucketsPRs = append(pl.BucketsPRs, counts)
+		pl.Merged = append(pl.Merged, merged)
+		pl.MergeRate = append(pl.MergeRate, rate)
+		pl.MedianMergeHours = append(pl.MedianMergeHours, median.Float64)
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// certificateAlgorithm - Certificate API signature algorithm
+const certificateAlgorithm = "HMAC-SHA256"
+
+// signCertificate - returns hex encoded HMAC-SHA256 of certificate JSON
+func signCertificate(cert *certificate) (string, error) {
+	data, err := jsoniter.Marshal(cert)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, gCertSecret)
+	_, _ = mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func apiCertificate(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Certificate
+	var err error
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(gCertSecret) == 0 {
+		err = fmt.Errorf("certificates are disabled on this server")
+		returnError(apiName, w, err)
+		return
+	}
+	ghID, err := getPayloadStringParam("github_id", w, payload, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metricName, _ := getPayloadStringParam("metric", w, payload, true)
+	if metricName == "" {
+		metricName = "Contributions"
+	}
+	metricMap, err := metricNameToValueMap(db, lib.DevActCnt)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	metric, ok := metricMap[metricName]
+	if !ok {
+		err = fmt.Errorf("invalid metric value: '%s'", metricName)
+		returnError(apiName, w, err)
+		return
+	}
+	// Data is calculated up to yesterday
+	to := lib.DayStart(time.Now().AddDate(0, 0, -1))
+	sDate, _ := getPayloadStringParam("date", w, payload, true)
+	if sDate != "" {
+		to, err = timeParseAny(sDate)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// Totals are counted since the first project event
+	rows, err := lib.QuerySQLLogErr(c, ctx, "select min(created_at) from gha_events")
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var pFrom *time.Time
+	for rows.Next() {
+		err = rows.Scan(&pFrom)
+		if err != nil {
+			_ = rows.Close()
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	err = rows.Err()
+	_ = rows.Close()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if pFrom == nil {
+		err = fmt.Errorf("no data for project '%s'", project)
+		returnError(apiName, w, err)
+		return
+	}
+	from := lib.DayStart(*pFrom)
+	period, _, err := periodNameToValue(c, ctx, "range:"+lib.ToYMDDate(from)+","+lib.ToYMDHMSDate(to), true, time.UTC)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	_, err = ensureManualData(c, ctx, project, db, lib.DevActCnt, metric, period, nil, false, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	series := fmt.Sprintf("hdev_%sallall", metric)
+	query := `
+   select
+     row_number() over (order by sum(value) desc) as "Rank",
+     split_part(name, '$$$', 1) as name,
+     sum(value) as value
+   from
+     shdev
+   where
+     series = $1
+     and period = $2
+   group by
+     split_part(name, '$$$', 1)
+	`
+	rows, err = lib.QuerySQLLogErr(c, ctx, query, series, period)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	cert := certificate{
+		Project:  project,
+		GitHubID: ghID,
+		Metric:   metricName,
+		From:     lib.ToYMDHMSDate(from),
+		To:       lib.ToYMDHMSDate(to),
+		IssuedAt: lib.ToYMDHMSDate(time.Now().UTC()),
+	}
+	var (
+		rank   int
+		login  string
+		number int
+	)
+	for rows.Next() {
+		err = rows.Scan(&rank, &login, &number)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		cert.Of++
+		if strings.ToLower(login) == strings.ToLower(ghID) {
+			r := rank
+			cert.Rank = &r
+			cert.GitHubID = login
+			cert.Number = number
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Login known to the project but without activity in the range gets a certificate with zero contributions and no rank
+	if cert.Rank == nil {
+		var known bool
+		known, err = knownLogin(c, ctx, ghID)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		if !known {
+			err = fmt.Errorf("github_id '%s' not found in project", ghID)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	signature, err := signCertificate(&cert)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := certificatePayload{
+		Certificate: cert,
+		Algorithm:   certificateAlgorithm,
+		Signature:   signature,
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+func apiVerifyCertificate(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.VerifyCertificate
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): payload: %+v err:%v\n", apiName, payload, err)
+	}()
+	if len(gCertSecret) == 0 {
+		err = fmt.Errorf("certificates are disabled on this server")
+		returnError(apiName, w, err)
+		return
+	}
+	iCert, ok := payload["certificate"]
+	if !ok {
+		err = fmt.Errorf("API '%s' 'certificate' parameter missing", apiName)
+		returnError(apiName, w, err)
+		return
+	}
+	signature, err := getPayloadStringParam("signature", w, payload, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	// Certificate is decoded strictly, any unknown field makes it invalid
+	data, err := jsoniter.Marshal(iCert)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	var cert certificate
+	dec := jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&cert)
+	if err != nil {
+		err = fmt.Errorf("invalid certificate: %v", err)
+		returnError(apiName, w, err)
+		return
+	}
+	expected, err := signCertificate(&cert)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	pl := verifyCertificatePayload{
+		Valid: hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)),
+	}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// exportTableRe - tables that can be exported by Export API: series tables
+var exportTableRe = regexp.MustCompile(`^s[a-z0-9_]+$`)
+
+// exportSummary - pseudo table name of a project summary dataset exported by Export API
+const exportSummary = "summary"
+
+// exportSummaryQuery - project summary dataset: daily events, actors and repositories by event type
+const exportSummaryQuery = `
+  select
+    date_trunc('day', created_at) as time,
+    type,
+    count(*) as events,
+    count(distinct actor_id) as actors,
+    count(distinct repo_id) as repos
+  from
+    gha_events
+  where
+    created_at >= $1
+    and created_at < $2
+  group by
+    1,
+    2
+  order by
+    1,
+    2
+`
+
+// checkAdminToken - checks admin APIs bearer token from the Authorization header
+func checkAdminToken(req *http.Request) (int, error) {
+	if len(gAdminToken) == 0 {
+		return http.StatusForbidden, fmt.Errorf("admin APIs are disabled on this server")
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return http.StatusUnauthorized, fmt.Errorf("missing bearer token")
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(auth[7:])), gAdminToken) != 1 {
+		return http.StatusUnauthorized, fmt.Errorf("invalid bearer token")
+	}
+	return http.StatusOK, nil
+}
+
+// exportValue - converts value scanned from the database into a value that can be written as CSV or JSON
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return lib.ToYMDHMSDate(v)
+	}
+	return value
+}
+
+// exportRows - writes all rows as CSV (with header) or JSONL (one object per line), returns number of rows written
+func exportRows(rows *sql.Rows, format string, out io.Writer) (n int, err error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	var (
+		cw  *csv.Writer
+		enc *jsoniter.Encoder
+	)
+	if format == "csv" {
+		cw = csv.NewWriter(out)
+		err = cw.Write(columns)
+		if err != nil {
+			return
+		}
+	} else {
+		enc = jsoniter.NewEncoder(out)
+	}
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return
+		}
+		if cw != nil {
+			record := make([]string, len(values))
+			for i, value := range values {
+				if value != nil {
+					record[i] = fmt.Sprintf("%v", exportValue(value))
+				}
+			}
+			err = cw.Write(record)
+		} else {
+			obj := make(map[string]interface{}, len(values))
+			for i, value := range values {
+				obj[columns[i]] = exportValue(value)
+			}
+			err = enc.Encode(obj)
+		}
+		if err != nil {
+			return
+		}
+		n++
+	}
+	err = rows.Err()
+	if err == nil && cw != nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+	return
+}
+
+// apiExport - streams a zip archive with one CSV/JSONL file per requested table, rows are limited to a given date range
+// Requires "Authorization: Bearer <GHA2DB_API_ADMIN_TOKEN>" header
+func apiExport(info string, w http.ResponseWriter, req *http.Request, payload map[string]interface{}) {
+	apiName := lib.Export
+	var err error
+	status, err := checkAdminToken(req)
+	if err != nil {
+		lib.Printf("%s(exit): %s err:%v\n", apiName, info, err)
+		errStr := "API '" + apiName + "': " + err.Error()
+		w.WriteHeader(status)
+		jsoniter.NewEncoder(w).Encode(errorPayload{Error: errStr})
+		return
+	}
+	project, db, err := handleSharedPayload(w, payload)
+	defer func() {
+		lib.Printf("%s(exit): project:%s db:%s payload: %+v err:%v\n", apiName, project, db, payload, err)
+	}()
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	params := map[string]string{"from": "", "to": ""}
+	for paramName := range params {
+		paramValue, err := getPayloadStringParam(paramName, w, payload, false)
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+		params[paramName] = paramValue
+	}
+	from, to, err := getTimeRangeParams(w, payload, params)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	format, err := getPayloadStringParam("format", w, payload, true)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "csv" && format != "jsonl" {
+		err = fmt.Errorf("invalid format value: '%s', allowed: csv, jsonl", format)
+		returnError(apiName, w, err)
+		return
+	}
+	tables, err := getPayloadStringArrayParam("tables", w, payload, true, false)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	if len(tables) == 0 {
+		tables = []string{exportSummary}
+	}
+	if len(tables) > gMaxExportTables {
+		err = fmt.Errorf("too many tables: %d, maximum is %d", len(tables), gMaxExportTables)
+		returnError(apiName, w, err)
+		return
+	}
+	for _, table := range tables {
+		if table != exportSummary && !exportTableRe.MatchString(table) {
+			err = fmt.Errorf("invalid table '%s', only series tables (s...) and '%s' can be exported", table, exportSummary)
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		returnError(apiName, w, err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	// All tables are checked before streaming starts, later errors cannot be reported in the response
+	for _, table := range tables {
+		if table == exportSummary {
+			continue
+		}
+		var exists bool
+		exists, err = tableExists(c, ctx, table)
+		if err == nil && !exists {
+			err = fmt.Errorf("table '%s' not found", table)
+		}
+		if err != nil {
+			returnError(apiName, w, err)
+			return
+		}
+	}
+	name := fmt.Sprintf("%s_%s_%s", db, from[:10], to[:10])
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+	w.WriteHeader(http.StatusOK)
+	zw := zip.NewWriter(w)
+	defer func() {
+		cerr := zw.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+	for _, table := range tables {
+		query := exportSummaryQuery
+		if table != exportSummary {
+			query = fmt.Sprintf(`select * from "%s" where time >= $1 and time < $2 order by time`, table)
+		}
+		var rows *sql.Rows
+		rows, err = lib.QuerySQLLogErr(c, ctx, query, from, to)
+		if err != nil {
+			return
+		}
+		var out io.Writer
+		out, err = zw.Create(table + "." + format)
+		if err == nil {
+			var n int
+			n, err = exportRows(rows, format, out)
+			if ctx.Debug > 0 {
+				lib.Printf("%s: %s: exported %d rows\n", apiName, table, n)
+			}
+		}
+		_ = rows.Close()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// batchResponseWriter - collects response of a single API call executed as a part of Batch API
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	trace  context.Context
+	cached bool
+}
+
+func (bw *batchResponseWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *batchResponseWriter) Write(data []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(data)
+}
+
+func (bw *batchResponseWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func apiBatch(info string, w http.ResponseWriter, payload map[string]interface{}) {
+	apiName := lib.Batch
+	var err error
+	defer func() {
+		lib.Printf("%s(exit): payload: %+v err:%v\n", apiName, payload, err)
+	}()
+	if len(payload) == 0 {
+		err = fmt.Errorf("'%s' API required payload with 'requests' array", apiName)
+		returnError(apiName, w, err)
+		return
+	}
+	iRequests, ok := payload["requests"]
+	if !ok {
+		err = fmt.Errorf("missing 'requests' field in payload")
+		returnError(apiName, w, err)
+		return
+	}
+	aRequests, ok := iRequests.([]interface{})
+	if !ok {
+		err = fmt.Errorf("'requests' field '%+v' must be an array", iRequests)
+		returnError(apiName, w, err)
+		return
+	}
+	if len(aRequests) > gMaxBatchRequests {
+		err = fmt.Errorf("too many requests in batch: %d, maximum is %d", len(aRequests), gMaxBatchRequests)
+		returnError(apiName, w, err)
+		return
+	}
+	requests := []apiPayload{}
+	for i, iRequest := range aRequests {
+		request, ok := iRequest.(map[string]interface{})
+		if !ok {
+			err = fmt.Errorf("request #%d '%+v' must be an object with 'api' and 'payload' fields", i+1, iRequest)
+			returnError(apiName, w, err)
+			return
+		}
+		api, _ := request["api"].(string)
+		if api == "" || api == lib.Batch || api == lib.Export {
+			err = fmt.Errorf("request #%d has invalid api '%+v'", i+1, request["api"])
+			returnError(apiName, w, err)
+			return
+		}
+		pl := apiPayload{API: api}
+		if request["payload"] != nil {
+			pl.Payload, ok = request["payload"].(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("request #%d payload '%+v' must be an object", i+1, request["payload"])
+				returnError(apiName, w, err)
+				return
+			}
+		}
+		requests = append(requests, pl)
+	}
+	// Execute requests concurrently using bounded number of workers, results are returned in the requests order
+	results := make([]batchResult, len(requests))
+	cached := make([]bool, len(requests))
+	ch := make(chan struct{})
+	nThreads := 0
+	for i := range requests {
+		go func(i int) {
+			defer func() { ch <- struct{}{} }()
+			bw := &batchResponseWriter{header: http.Header{}, trace: requestTrace(w)}
+			_ = dispatchAPI(fmt.Sprintf("%s batch #%d", info, i+1), bw, &requests[i])
+			if bw.status == 0 {
+				bw.status = http.StatusOK
+			}
+			response := bytes.TrimSpace(bw.body.Bytes())
+			if len(response) == 0 {
+				response = []byte("null")
+			}
+			results[i] = batchResult{API: requests[i].API, Status: bw.status, Response: jsoniter.RawMessage(response)}
+			cached[i] = bw.cached
+		}(i)
+		nThreads++
+		if nThreads >= gBatchWorkers {
+			<-ch
+			nThreads--
+		}
+	}
+	for nThreads > 0 {
+		<-ch
+		nThreads--
+	}
+	// Batch is served from cache only when all its requests are
+	allCached := len(requests) > 0
+	for _, c := range cached {
+		allCached = allCached && c
+	}
+	if allCached {
+		setResponseCached(w)
+	}
+	pl := batchPayload{Results: results}
+	w.WriteHeader(http.StatusOK)
+	jsoniter.NewEncoder(w).Encode(pl)
+}
+
+// warmEntry - access statistics of a single warmable API payload, with its cached response when it is among the top requested ones
+type warmEntry struct {
+	api     string
+	db      string
+	payload map[string]interface{}
+	count   float64
+	status  int
+	body    []byte
+	dt      time.Time
+}
+
+var (
+	// gWarmTopK - number of the most requested warmable API payloads kept cached and refreshed after each project sync (GHA2DB_API_WARM_TOPK)
+	gWarmTopK int
+	// gWarmEntries - warmable API payloads access statistics, by API name and canonical payload
+	gWarmEntries = map[string]*warmEntry{}
+	gWarmMtx     = &sync.Mutex{}
+	// warmAPIs - APIs whose responses can be warmed, they only depend on project data (so they change only after sync)
+	warmAPIs = map[string]func(string, http.ResponseWriter, map[string]interface{}){
+		lib.DevActCnt:      apiDevActCnt,
+		lib.CompaniesTable: apiCompaniesTable,
+	}
+)
+
+const (
+	// warmMaxEntries - maximum number of distinct payloads with access statistics, new payloads are not tracked above it
+	warmMaxEntries = 10000
+	// warmDecay - access counts are halved this often, so recently popular payloads win
+	warmDecay = time.Hour
+)
+
+// warmKey - returns warmable API payload key and its project database, ok is false for payloads that cannot be warmed
+func warmKey(pl *apiPayload) (key, db string, ok bool) {
+	if gWarmTopK <= 0 || warmAPIs[pl.API] == nil || pl.Payload == nil {
+		return
+	}
+	project, _ := pl.Payload["project"].(string)
+	db, err := nameToDB(project)
+	if err != nil {
+		return
+	}
+	key, err = payloadKey(pl.API, pl.Payload)
+	if err != nil {
+		return
+	}
+	return key, db, true
+}
+
+// payloadKey - returns canonical key of API payload (without 'fields'), the same payload always gives the same key
+func payloadKey(api string, pl map[string]interface{}) (string, error) {
+	payload := make(map[string]interface{})
+	for k, v := range pl {
+		if k != "fields" {
+			payload[k] = v
+		}
+	}
+	// Standard library compatible config sorts map keys
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return api + ":" + string(data), nil
+}
+
+// warmedResponse - records warmable API payload access and writes its cached response if there is one, returns true then
+func warmedResponse(w http.ResponseWriter, pl *apiPayload) bool {
+	key, db, ok := warmKey(pl)
+	if !ok {
+		return false
+	}
+	gWarmMtx.Lock()
+	entry, ok := gWarmEntries[key]
+	if !ok {
+		if len(gWarmEntries) >= warmMaxEntries {
+			gWarmMtx.Unlock()
+			return false
+		}
+		entry = &warmEntry{api: pl.API, db: db, payload: pl.Payload}
+		gWarmEntries[key] = entry
+	}
+	entry.count++
+	status, body, dt := entry.status, entry.body, entry.dt
+	gWarmMtx.Unlock()
+	if body == nil {
+		return false
+	}
+	lib.Printf("%s: using warmed response computed at %v\n", pl.API, dt)
+	setResponseCached(w)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+	return true
+}
+
+// warmTopEntries - returns keys of gWarmTopK most requested payloads, drops cached responses of all other payloads
+func warmTopEntries() (keys []string) {
+	gWarmMtx.Lock()
+	defer gWarmMtx.Unlock()
+	for key := range gWarmEntries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := gWarmEntries[keys[i]].count, gWarmEntries[keys[j]].count
+		if ci == cj {
+			return keys[i] < keys[j]
+		}
+		return ci > cj
+	})
+	if len(keys) > gWarmTopK {
+		for _, key := range keys[gWarmTopK:] {
+			gWarmEntries[key].body = nil
+		}
+		keys = keys[:gWarmTopK]
+	}
+	return
+}
+
+// warmDecayCounts - halves access counts, forgets payloads that were not requested recently (and are not cached)
+func warmDecayCounts() {
+	gWarmMtx.Lock()
+	defer gWarmMtx.Unlock()
+	for key, entry := range gWarmEntries {
+		entry.count /= 2.0
+		if entry.count < 0.5 && entry.body == nil {
+			delete(gWarmEntries, key)
+		}
+	}
+}
+
+// warmSyncFinished - returns when the last project sync run finished, zero time when it is unknown
+func warmSyncFinished(db string) (dt time.Time, err error) {
+	ctx, c, err := getContextAndDB(nil, db)
+	if err != nil {
+		return
+	}
+	defer func() { _ = c.Close() }()
+	exists, err := tableExists(c, ctx, "gha_sync_runs")
+	if err != nil || !exists {
+		return
+	}
+	var pdt *time.Time
+	err = lib.QueryRowSQL(
+		c,
+		ctx,
+		"select max(dt_end) from gha_sync_runs where stage = $1 and status = 'finished'",
+		lib.SyncRunAll,
+	).Scan(&pdt)
+	if err == nil && pdt != nil {
+		dt = *pdt
+	}
+	return
+}
+
+// warmRefresh - recomputes top requested payloads that have no cached response or whose project finished sync after it was computed
+func warmRefresh() {
+	keys := warmTopEntries()
+	finished := make(map[string]time.Time)
+	for _, key := range keys {
+		gWarmMtx.Lock()
+		entry := gWarmEntries[key]
+		api, db, payload, cached, dt := entry.api, entry.db, entry.payload, entry.body != nil, entry.dt
+		gWarmMtx.Unlock()
+		syncDt, ok := finished[db]
+		if !ok {
+			var err error
+			syncDt, err = warmSyncFinished(db)
+			if err != nil {
+				lib.Printf("Warm: cannot get last sync of '%s': %v\n", db, err)
+			}
+			finished[db] = syncDt
+		}
+		if cached && !dt.Before(syncDt) {
+			continue
+		}
+		bw := &batchResponseWriter{header: http.Header{}}
+		dtStart := time.Now()
+		warmAPIs[api]("warm", bw, payload)
+		// Errors are not cached, users get them from the API directly
+		gWarmMtx.Lock()
+		if bw.status == http.StatusOK {
+			entry.status, entry.body, entry.dt = bw.status, bw.body.Bytes(), dtStart
+		} else {
+			entry.body = nil
+		}
+		gWarmMtx.Unlock()
+		lib.Printf("Warm: %s %+v refreshed in %v, status %d\n", api, payload, time.Since(dtStart), bw.status)
+	}
+}
+
+// warmScheduler - refreshes the most requested payloads shortly after each project sync finishes (GHA2DB_API_WARM_TOPK)
+func warmScheduler(interval time.Duration) {
+	lastDecay := time.Now()
+	for {
+		time.Sleep(interval)
+		if time.Since(lastDecay) >= warmDecay {
+			warmDecayCounts()
+			lastDecay = time.Now()
+		}
+		warmRefresh()
+	}
+}
+
+// seriesEntry - API response cached for a given series data version
+type seriesEntry struct {
+	version string
+	body    []byte
+	dt      time.Time
+}
+
+var (
+	// gSeriesCache - DevActCnt/DevActCntComp/CompaniesTable responses by canonical payload, valid as long as their series period version is the same
+	gSeriesCache = map[string]*seriesEntry{}
+	gSeriesMtx   = &sync.Mutex{}
+)
+
+// seriesCacheMaxEntries - maximum number of cached responses, the oldest one is dropped above it
+const seriesCacheMaxEntries = 1000
+
+// seriesETag - returns ETag of a response to a given payload key computed from a given series version
+func seriesETag(key, version string) string {
+	hash := sha256.Sum256([]byte(key + "\x00" + version))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// seriesVersion - returns series table period data version with a given payload key, ok is false when versions are not tracked
+// Sets ETag header and writes cached response when it was computed for the same version, cached is true then
+func seriesVersion(w http.ResponseWriter, c *sql.DB, ctx *lib.Ctx, apiName string, payload map[string]interface{}, table, period string) (key, version string, ok, cached bool) {
+	var err error
+	version, err = lib.SeriesVersion(c, ctx, table, period)
+	if err != nil {
+		lib.Printf("%s: cannot get %s %s version: %v\n", apiName, table, period, err)
+		return
+	}
+	if version == "" {
+		return
+	}
+	key, err = payloadKey(apiName, payload)
+	if err != nil {
+		return
+	}
+	ok = true
+	w.Header().Set("ETag", seriesETag(key, version))
+	gSeriesMtx.Lock()
+	entry, found := gSeriesCache[key]
+	var body []byte
+	if found && entry.version == version {
+		body = entry.body
+	}
+	gSeriesMtx.Unlock()
+	if body == nil {
+		return
+	}
+	if ctx.Debug > 0 {
+		lib.Printf("%s: using cached response for %s %s version %s\n", apiName, table, period, version)
+	}
+	cached = true
+	setResponseCached(w)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	return
+}
+
+// writeSeriesResponse - writes JSON response and caches it for a given series version (see seriesVersion)
+func writeSeriesResponse(w http.ResponseWriter, key, version string, ok bool, pl interface{}) {
+	if !ok {
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(pl)
+		return
+	}
+	var buf bytes.Buffer
+	jsoniter.NewEncoder(&buf).Encode(pl)
+	body := buf.Bytes()
+	gSeriesMtx.Lock()
+	if _, found := gSeriesCache[key]; !found && len(gSeriesCache) >= seriesCacheMaxEntries {
+		oldest := ""
+		for k, entry := range gSeriesCache {
+			if oldest == "" || entry.dt.Before(gSeriesCache[oldest].dt) {
+				oldest = k
+			}
+		}
+		delete(gSeriesCache, oldest)
+	}
+	gSeriesCache[key] = &seriesEntry{version: version, body: body, dt: time.Now()}
+	gSeriesMtx.Unlock()
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// etagResponseWriter - returns 304 with no body instead of 200 response with ETag matching request If-None-Match header (see lib.ETagMatch)
+type etagResponseWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	notModified bool
+}
+
+func (ew *etagResponseWriter) WriteHeader(status int) {
+	if status == http.StatusOK && lib.ETagMatch(ew.ifNoneMatch, ew.Header().Get("ETag")) {
+		ew.notModified = true
+		status = http.StatusNotModified
+	}
+	ew.ResponseWriter.WriteHeader(status)
+}
+
+func (ew *etagResponseWriter) Write(data []byte) (int, error) {
+	if ew.notModified {
+		return len(data), nil
+	}
+	return ew.ResponseWriter.Write(data)
+}
+
+// formattedResponseWriter - buffers JSON response to write it indented ("pretty": true) and/or as JSONP ("callback": "name"), see lib.FormatJSONResponse
+// Non-JSON responses (for example CSV) are written unchanged
+type formattedResponseWriter struct {
+	http.ResponseWriter
+	pretty   bool
+	callback string
+	status   int
+	body     bytes.Buffer
+}
+
+func (fw *formattedResponseWriter) WriteHeader(status int) {
+	if fw.status == 0 {
+		fw.status = status
+	}
+}
+
+func (fw *formattedResponseWriter) Write(data []byte) (int, error) {
+	if fw.status == 0 {
+		fw.status = http.StatusOK
+	}
+	return fw.body.Write(data)
+}
+
+// flush - writes formatted response
+func (fw *formattedResponseWriter) flush() {
+	if fw.status == 0 {
+		fw.status = http.StatusOK
+	}
+	body := fw.body.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(fw.Header().Get("Content-Type"))
+	if mediaType == "application/json" && len(bytes.TrimSpace(body)) > 0 {
+		body = lib.FormatJSONResponse(body, fw.pretty, fw.callback)
+		if fw.callback != "" {
+			fw.Header().Set("Content-Type", "application/javascript")
+			fw.Header().Set("X-Content-Type-Options", "nosniff")
+		}
+		// Formatted response is a different representation
+		if etag := fw.Header().Get("ETag"); etag != "" {
+			fw.Header().Set("ETag", seriesETag(etag, fmt.Sprintf("pretty=%v,callback=%s", fw.pretty, fw.callback)))
+		}
+	}
+	fw.ResponseWriter.WriteHeader(fw.status)
+	_, _ = fw.ResponseWriter.Write(body)
+}
+
+// getFormatParams - returns response formatting params ("pretty" and "callback") and removes them from payload, API handlers never see them
+func getFormatParams(w http.ResponseWriter, payload map[string]interface{}) (pretty bool, callback string, err error) {
+	pretty, err = getPayloadBoolParam("pretty", w, payload)
+	if err != nil {
+		return
+	}
+	callback, err = getPayloadStringParam("callback", w, payload, true)
+	if err != nil {
+		return
+	}
+	if callback != "" && !lib.JSONPCallbackRE.MatchString(callback) {
+		err = fmt.Errorf("invalid JSONP callback '%s', it must be a JavaScript identifier (optionally dotted)", callback)
+		return
+	}
+	delete(payload, "pretty")
+	delete(payload, "callback")
+	return
+}
+
+// responseMeta - standard "meta" object added to every JSON object response, so consumers can show data freshness and debug discrepancies
+type responseMeta struct {
+	DB            string     `json:"db"`
+	DataAsOf      *time.Time `json:"data_as_of"`
+	Cached        bool       `json:"cached"`
+	ComputationMs float64    `json:"computation_ms"`
+}
+
+// dataAsOfEntry - project's latest parsed GHA hour, read at dt
+type dataAsOfEntry struct {
+	dataAsOf *time.Time
+	dt       time.Time
+}
+
+var (
+	// gDataAsOf - latest gha_parsed hour by project database, reused for dataAsOfTTL
+	gDataAsOf    = map[string]dataAsOfEntry{}
+	gDataAsOfMtx = &sync.Mutex{}
+)
+
+// dataAsOfTTL - how long project's latest parsed GHA hour is reused before it is read again
+const dataAsOfTTL = time.Minute
+
+// dataAsOf - returns latest GHA hour fully parsed into a given project database (nil when unknown)
+func dataAsOf(w http.ResponseWriter, db string) *time.Time {
+	gDataAsOfMtx.Lock()
+	entry, ok := gDataAsOf[db]
+	gDataAsOfMtx.Unlock()
+	if ok && time.Since(entry.dt) < dataAsOfTTL {
+		return entry.dataAsOf
+	}
+	ctx, c, err := getContextAndDB(w, db)
+	if err != nil {
+		lib.Printf("Cannot get '%s' data freshness: %v\n", db, err)
+		return nil
+	}
+	defer func() { _ = c.Close() }()
+	var dt *time.Time
+	err = lib.QueryRowSQL(c, ctx, "select max(dt) from gha_parsed where status <> "+lib.NValue(1), lib.ParsedPartial).Scan(&dt)
+	if err != nil {
+		lib.Printf("Cannot get '%s' data freshness: %v\n", db, err)
+		return nil
+	}
+	gDataAsOfMtx.Lock()
+	gDataAsOf[db] = dataAsOfEntry{dataAsOf: dt, dt: time.Now()}
+	gDataAsOfMtx.Unlock()
+	return dt
+}
+
+// metaResponseWriter - buffers JSON object response to add the "meta" object to it (see responseMeta)
+// Non-JSON responses and JSON arrays are written unchanged, meta is not a part of ETag
+type metaResponseWriter struct {
+	http.ResponseWriter
+	db     string
+	start  time.Time
+	cached bool
+	status int
+	body   bytes.Buffer
+}
+
+func (mw *metaResponseWriter) WriteHeader(status int) {
+	if mw.status == 0 {
+		mw.status = status
+	}
+}
+
+func (mw *metaResponseWriter) Write(data []byte) (int, error) {
+	if mw.status == 0 {
+		mw.status = http.StatusOK
+	}
+	return mw.body.Write(data)
+}
+
+// flush - writes response with meta object added
+func (mw *metaResponseWriter) flush() {
+	if mw.status == 0 {
+		mw.status = http.StatusOK
+	}
+	body := mw.body.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(mw.Header().Get("Content-Type"))
+	if mediaType == "application/json" {
+		meta := responseMeta{
+			DB:            mw.db,
+			Cached:        mw.cached,
+			ComputationMs: float64(time.Since(mw.start).Microseconds()) / 1000.0,
+		}
+		if mw.db != "" {
+			meta.DataAsOf = dataAsOf(mw, mw.db)
+		}
+		data, err := jsoniter.Marshal(meta)
+		if err == nil {
+			body = lib.AddJSONField(body, "meta", data)
+		}
+	}
+	mw.ResponseWriter.WriteHeader(mw.status)
+	_, _ = mw.ResponseWriter.Write(body)
+}
+
+// setResponseCached - marks response of a given writer as served from cache (meta "cached" field)
+func setResponseCached(w http.ResponseWriter) {
+	for {
+		switch rw := w.(type) {
+		case *metaResponseWriter:
+			rw.cached = true
+			return
+		case *batchResponseWriter:
+			rw.cached = true
+			return
+		case *tracedResponseWriter:
+			w = rw.ResponseWriter
+		case *etagResponseWriter:
+			w = rw.ResponseWriter
+		case *formattedResponseWriter:
+			w = rw.ResponseWriter
+		default:
+			return
+		}
+	}
+}
+
+// tracedResponseWriter - response writer of a traced API request, its trace context is used by request's SQL and command spans
+type tracedResponseWriter struct {
+	http.ResponseWriter
+	trace  context.Context
+	status int
+}
+
+func (tw *tracedResponseWriter) WriteHeader(status int) {
+	if tw.status == 0 {
+		tw.status = status
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *tracedResponseWriter) Write(data []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.ResponseWriter.Write(data)
+}
+
+// requestTrace - returns trace context of the API request a given response writer belongs to, nil when request is not traced
+func requestTrace(w http.ResponseWriter) context.Context {
+	for {
+		switch rw := w.(type) {
+		case *tracedResponseWriter:
+			return rw.trace
+		case *etagResponseWriter:
+			w = rw.ResponseWriter
+		case *formattedResponseWriter:
+			w = rw.ResponseWriter
+		case *metaResponseWriter:
+			w = rw.ResponseWriter
+		case *batchResponseWriter:
+			return rw.trace
+		default:
+			return nil
+		}
+	}
+}
+
+// traceRequest - starts API request span (continuing client's trace when request has traceparent header)
+// Returns response writer that passes span's trace context to handlers and function that ends the span
+func traceRequest(w http.ResponseWriter, req *http.Request, pl *apiPayload) (http.ResponseWriter, func(error)) {
+	if !lib.TracingEnabled() {
+		return w, func(error) {}
+	}
+	parent := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	attrs := []attribute.KeyValue{attribute.String("devstats.api", pl.API)}
+	if project, ok := pl.Payload["project"].(string); ok {
+		attrs = append(attrs, attribute.String("devstats.project", project))
+	}
+	sctx, span := lib.StartSpan(parent, "api "+pl.API, attrs...)
+	tw := &tracedResponseWriter{ResponseWriter: w, trace: sctx}
+	return tw, func(err error) {
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.status_code", tw.status))
+		if err == nil && tw.status >= http.StatusBadRequest {
+			err = fmt.Errorf("HTTP status %d", tw.status)
+		}
+		lib.EndSpan(span, err)
+	}
+}
+
+func requestInfo(r *http.Request) string {
+	agent := ""
+	hdr := r.Header
+	method := r.Method
+	path := html.EscapeString(r.URL.Path)
+	if hdr != nil {
+		uAgentAry, ok := hdr["User-Agent"]
+		if ok {
+			agent = strings.Join(uAgentAry, ", ")
+		}
+	}
+	// Client IP differs from remote address only for requests forwarded by trusted proxies
+	ip := r.RemoteAddr
+	if clientIP := lib.ClientIP(r, gTrustedProxies); clientIP != lib.ClientIP(r, nil) {
+		ip = clientIP + " (via " + r.RemoteAddr + ")"
+	}
+	if agent != "" {
+		return fmt.Sprintf("IP: %s, agent: %s, method: %s, path: %s", ip, agent, method, path)
+	}
+	return fmt.Sprintf("IP: %s, method: %s, path: %s", ip, method, path)
+}
+
+// handleMethods - handles requests other than given endpoint's main method, returns true when request was handled
+// OPTIONS returns allowed methods (and all APIs for the main endpoint), HEAD returns headers only, other methods are not allowed
+func handleMethods(w http.ResponseWriter, req *http.Request, main, allowed string, apis []string) bool {
+	if req.Method == main {
+		return false
+	}
+	w.Header().Set("Allow", allowed)
+	w.Header().Set("X-Devstats-Git-SHA", lib.GitHash)
+	switch req.Method {
+	case http.MethodOptions:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		jsoniter.NewEncoder(w).Encode(optionsPayload{Methods: strings.Split(allowed, ", "), APIs: apis})
+	case http.MethodHead:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		jsoniter.NewEncoder(w).Encode(errorPayload{Error: fmt.Sprintf("method %s not allowed, allowed methods: %s", req.Method, allowed)})
+	}
+	lib.Printf("Request: %s: handled %s\n", requestInfo(req), req.Method)
+	return true
+}
+
+// handleVersion - returns build version, git SHA, number of projects and uptime of the API server (GET/HEAD /api/v1/version)
+func handleVersion(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodHead && handleMethods(w, req, http.MethodGet, versionMethods, nil) {
+		return
+	}
+	gMtx.RLock()
+	nProjects := len(gProjects)
+	gMtx.RUnlock()
+	uptime := time.Since(gStartTime)
+	vpl := versionPayload{
+		BuildTime:     lib.BuildStamp,
+		GitSHA:        lib.GitHash,
+		GoVersion:     lib.GoVersion,
+		BuildHost:     lib.HostName,
+		Projects:      nProjects,
+		StartedAt:     gStartTime,
+		Uptime:        uptime.Truncate(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Devstats-Git-SHA", lib.GitHash)
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return
+	}
+	jsoniter.NewEncoder(w).Encode(vpl)
+}
+
+// decodeAPIQuery - decodes GET API request: API name from "api" and JSON payload object from "payload" query params,
+// "callback" and "pretty" query params are added to payload. GET requests are for JSONP (<script> tags cannot POST),
+// query is limited like POST request body
+func decodeAPIQuery(req *http.Request, pl *apiPayload) (int, error) {
+	if len(req.URL.RawQuery) > gMaxBody {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("request query exceeds %d bytes", gMaxBody)
+	}
+	query := req.URL.Query()
+	pl.API = query.Get("api")
+	pl.Payload = make(map[string]interface{})
+	if payload := query.Get("payload"); payload != "" {
+		var raw interface{}
+		err := jsoniter.Unmarshal([]byte(payload), &raw)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		err = lib.CheckJSONLimits(map[string]interface{}{"payload": raw}, gMaxDepth, gMaxArray)
+		if err != nil {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request payload too large: %v", err)
+		}
+		err = jsoniter.Unmarshal([]byte(payload), &pl.Payload)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	if callback := query.Get("callback"); callback != "" {
+		pl.Payload["callback"] = callback
+	}
+	if pretty := query.Get("pretty"); pretty != "" {
+		b, err := strconv.ParseBool(pretty)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid 'pretty' query param '%s'", pretty)
+		}
+		pl.Payload["pretty"] = b
+	}
+	return http.StatusOK, nil
+}
+
+// decodeAPIPayload - decodes API request body, returns HTTP status to use when it fails
+// Body must be JSON (415 otherwise), not larger than gMaxBody bytes and not nested deeper than gMaxDepth or with arrays longer than gMaxArray (413 otherwise)
+// GET requests are decoded from query params (decodeAPIQuery)
+func decodeAPIPayload(w http.ResponseWriter, req *http.Request, pl *apiPayload) (int, error) {
+	if req.Method == http.MethodGet {
+		return decodeAPIQuery(req, pl)
+	}
+	contentType := req.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type '%s', only application/json is accepted", contentType)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, int64(gMaxBody)))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", gMaxBody)
+		}
+		return http.StatusBadRequest, err
+	}
+	var raw interface{}
+	err = jsoniter.Unmarshal(body, &raw)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	err = lib.CheckJSONLimits(raw, gMaxDepth, gMaxArray)
+	if err != nil {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("request payload too large: %v", err)
+	}
+	err = jsoniter.Unmarshal(body, pl)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+func handleAPI(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && handleMethods(w, req, http.MethodPost, apiMethods, allAPIs) {
+		return
+	}
+	info := requestInfo(req)
+	gBgMtx.RLock()
+	num := gNumBg
+	gBgMtx.RUnlock()
+	if num == 0 {
+		lib.Printf("Request: %s\n", info)
+	} else {
+		lib.Printf("Request (%d bg runners): %s\n", num, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var (
+		pl  apiPayload
+		err error
+	)
+	defer func() {
+		gBgMtx.RLock()
+		num := gNumBg
+		gBgMtx.RUnlock()
+		if num == 0 {
+			lib.Printf("Request(exit): %s err:%v\n", info, err)
+		} else {
+			lib.Printf("Request(exit, %d bg runners): %s err:%v\n", num, info, err)
+		}
+	}()
+	status, err := decodeAPIPayload(w, req, &pl)
+	if err != nil {
+		returnErrorStatus("unknown", w, status, err)
+		return
+	}
+	lib.Printf("Request: %s, Payload: %+v\n", info, pl)
+	w, endSpan := traceRequest(w, req, &pl)
+	defer func() { endSpan(err) }()
+	// Admin APIs need request headers and stream their own response, so they are not dispatched (and cannot be batched)
+	if pl.API == lib.Export {
+		if req.Method == http.MethodGet {
+			returnErrorStatus(pl.API, w, http.StatusMethodNotAllowed, fmt.Errorf("%s API is only available via POST", pl.API))
+			return
+		}
+		apiExport(info, w, req, pl.Payload)
+		return
+	}
+	// Formatting applies to the whole response (Batch results are formatted together)
+	pretty, callback, err := getFormatParams(w, pl.Payload)
+	if err != nil {
+		returnError(pl.API, w, err)
+		return
+	}
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		w = &etagResponseWriter{ResponseWriter: w, ifNoneMatch: ifNoneMatch}
+	}
+	if pretty || callback != "" {
+		fw := &formattedResponseWriter{ResponseWriter: w, pretty: pretty, callback: callback}
+		defer fw.flush()
+		w = fw
+	}
+	// Project database of the "meta" object, APIs without a single project have no database
+	project, _ := pl.Payload["project"].(string)
+	db, _ := nameToDB(project)
+	mw := &metaResponseWriter{ResponseWriter: w, db: db, start: time.Now()}
+	defer mw.flush()
+	w = mw
+	err = dispatchAPI(info, w, &pl)
+}
+
+// selectFields - returns JSON object response with only given top-level fields, fields not present in response are skipped
+// Error responses and responses that are not JSON objects (for example CSV) are returned unchanged
+func selectFields(body []byte, fields []string) ([]byte, error) {
+	var obj map[string]jsoniter.RawMessage
+	if jsoniter.Unmarshal(body, &obj) != nil {
+		return body, nil
+	}
+	if _, ok := obj["error"]; ok {
+		return body, nil
+	}
+	selected := make(map[string]jsoniter.RawMessage)
+	for _, field := range fields {
+		if value, ok := obj[field]; ok {
+			selected[field] = value
+		}
+	}
+	data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(selected)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// dispatchAPI - calls handler of the requested API, returns error only for unknown APIs or invalid 'fields' (handlers report their own errors)
+// When payload contains 'fields' array, response only includes these top-level fields (sparse fieldsets)
+func dispatchAPI(info string, w http.ResponseWriter, pl *apiPayload) (err error) {
+	fields, err := getPayloadStringArrayParam("fields", w, pl.Payload, true, false)
+	if err != nil {
+		returnError(pl.API, w, err)
+		return
+	}
+	if len(fields) == 0 {
+		return dispatchAPIFull(info, w, pl)
+	}
+	delete(pl.Payload, "fields")
+	bw := &batchResponseWriter{header: w.Header(), trace: requestTrace(w)}
+	err = dispatchAPIFull(info, bw, pl)
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if bw.cached {
+		setResponseCached(w)
+	}
+	body, ferr := selectFields(bw.body.Bytes(), fields)
+	if ferr != nil {
+		returnError(pl.API, w, ferr)
+		return
+	}
+	// The same response with other fields selected is a different representation
+	if etag := w.Header().Get("ETag"); etag != "" {
+		w.Header().Set("ETag", seriesETag(etag, strings.Join(fields, ",")))
+	}
+	w.WriteHeader(bw.status)
+	_, _ = w.Write(body)
+	return
+}
+
+// dispatchAPIFull - calls handler of the requested API, warmed APIs responses are returned from cache when available
+func dispatchAPIFull(info string, w http.ResponseWriter, pl *apiPayload) (err error) {
+	if warmedResponse(w, pl) {
+		return
+	}
+	switch pl.API {
+	case lib.Health:
+		apiHealth(info, w, pl.Payload)
+	case lib.ListAPIs:
+		apiListAPIs(info, w)
+	case lib.ListProjects:
+		apiListProjects(info, w)
+	case lib.RepoGroups:
+		apiRepoGroups(info, w, pl.Payload)
+	case lib.Ranges:
+		apiRanges(info, w, pl.Payload)
+	case lib.Countries:
+		apiCountries(info, w, pl.Payload)
+	case lib.Companies:
+		apiCompanies(info, w, pl.Payload)
+	case lib.Events:
+		apiEvents(info, w, pl.Payload)
+	case lib.Repos:
+		apiRepos(info, w, pl.Payload)
+	case lib.CompaniesTable:
+		apiCompaniesTable(info, w, pl.Payload)
+	case lib.ComContribRepoGrp:
+		apiComContribRepoGrp(info, w, pl.Payload)
+	case lib.ComStatsRepoGrp:
+		apiComStatsRepoGrp(info, w, pl.Payload)
+	case lib.DevActCnt:
+		apiDevActCnt(info, w, pl.Payload)
+	case lib.DevActCntComp:
+		apiDevActCntComp(info, w, pl.Payload)
+	case lib.SiteStats:
+		apiSiteStats(info, w, pl.Payload)
+	case lib.CountriesStats:
+		apiCountriesStats(info, w, pl.Payload)
+	case lib.DataQuality:
+		apiDataQuality(info, w, pl.Payload)
+	case lib.Batch:
+		apiBatch(info, w, pl.Payload)
+	case lib.CompanyProfile:
+		apiCompanyProfile(info, w, pl.Payload)
+	case lib.CIStats:
+		apiCIStats(info, w, pl.Payload)
+	case lib.Certificate:
+		apiCertificate(info, w, pl.Payload)
+	case lib.VerifyCertificate:
+		apiVerifyCertificate(info, w, pl.Payload)
+	case lib.PRSizeDistribution:
+		apiPRSizeDistribution(info, w, pl.Payload)
+	case lib.RenamedOrDeletedRepos:
+		apiRenamedOrDeletedRepos(info, w, pl.Payload)
+	case lib.IngestStats:
+		apiIngestStats(info, w, pl.Payload)
+	case lib.Traffic:
+		apiTraffic(info, w, pl.Payload)
+	case lib.DevActDistribution:
+		apiDevActDistribution(info, w, pl.Payload)
+	case lib.Velocity:
+		apiVelocity(info, w, pl.Payload)
+	case lib.LabelLifecycle:
+		apiLabelLifecycle(info, w, pl.Payload)
+	case lib.ReleaseStats:
+		apiReleaseStats(info, w, pl.Payload)
+	case lib.SyncStatus:
+		apiSyncStatus(info, w, pl.Payload)
+	case lib.ForkActivity:
+		apiForkActivity(info, w, pl.Payload)
+	case lib.ProjectFeatures:
+		apiProjectFeatures(info, w, pl.Payload)
+	case lib.MentionGraph:
+		apiMentionGraph(info, w, pl.Payload)
+	case lib.RepoStats:
+		apiRepoStats(info, w, pl.Payload)
+	case lib.BusFactor:
+		apiBusFactor(info, w, pl.Payload)
+	case lib.LinkedWork:
+		apiLinkedWork(info, w, pl.Payload)
+	case lib.TagCloud:
+		apiTagCloud(info, w, pl.Payload)
+	case lib.MilestoneProgress:
+		apiMilestoneProgress(info, w, pl.Payload)
+	case lib.SeriesQuery:
+		apiSeriesQuery(info, w, pl.Payload)
+	case lib.ContributorRetention:
+		apiContributorRetention(info, w, pl.Payload)
+	case lib.DevActDiff:
+		apiDevActDiff(info, w, pl.Payload)
+	case lib.BgStatus:
+		apiBgStatus(info, w)
+	default:
+		err = fmt.Errorf("unknown API '%s'", pl.API)
+		returnError("unknown:"+pl.API, w, err)
+	}
+	return
+}
+
+func checkEnv() {
+	requiredEnv := []string{"PG_PASS", "PG_PASS_RO", "PG_USER_RO", "PG_HOST_RO"}
+	for _, env := range requiredEnv {
+		if os.Getenv(env) == "" {
+			lib.Fatalf("%s env variable must be set", env)
+		}
+	}
+}
+
+func readProjects(ctx *lib.Ctx) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	data, err := ioutil.ReadFile(dataPrefix + ctx.ProjectsYaml)
+	lib.FatalOnError(err)
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+	gNameToDB = make(map[string]string)
+	gMainRepos = make(map[string]string)
+	gFeatures = make(map[string][]string)
+	gSharded = make(map[string]bool)
+	for projName, projData := range projects.Projects {
+		disabled := projData.Disabled
+		if disabled {
+			continue
+		}
+		db := projData.PDB
+		gNameToDB[projName] = db
+		gNameToDB[projData.FullName] = db
+		gNameToDB[projData.PDB] = db
+		gMainRepos[db] = projData.MainRepo
+		gFeatures[db] = lib.EnabledFeatures(projData.Features)
+		gSharded[db] = len(projData.Shards) > 0
+		gProjects = append(gProjects, projData.FullName)
+	}
+	gMtx = &sync.RWMutex{}
+}
+
+func serveAPI() {
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+	lib.Printf("Starting API server\n")
+	checkEnv()
+	readProjects(&ctx)
+	gTrustedProxies = ctx.TrustedProxies
+	gCertSecret = []byte(ctx.APICertSecret)
+	gAdminToken = []byte(ctx.APIAdminToken)
+	gMaxBody = ctx.APIMaxBody
+	gMaxDepth = ctx.APIMaxDepth
+	gMaxArray = ctx.APIMaxArray
+	gMaxBg = ctx.APIMaxBg
+	gMaxBgQueue = ctx.APIMaxBgQueue
+	gWarmTopK = ctx.APIWarmTopK
+	flushSpans := lib.InitTracing(&ctx, "api")
+	if gWarmTopK > 0 {
+		go warmScheduler(ctx.APIWarmInterval)
+	}
+	gBgMtx = &sync.RWMutex{}
+	gStartTime = time.Now()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGUSR1, syscall.SIGALRM)
+	go func() {
+		for {
+			sig := <-sigs
+			lib.Printf("Exiting due to signal %v\n", sig)
+			flushSpans()
+			os.Exit(lib.ExitError)
+		}
+	}()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1", handleAPI)
+	mux.HandleFunc("/api/v1/version", handleVersion)
+	handler := cors.AllowAll().Handler(mux)
+	lib.FatalOnError(http.ListenAndServe("0.0.0.0:8080", handler))
+}
+
+// Main - runs api tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	serveAPI()
+	lib.Fatalf("serveAPI exited without error, returning error state anyway")
+}
@@ -0,0 +1,417 @@
+package devstats
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/lib/pq"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// setSyncDuration stores project's last sync duration in `gha_last_computed` table
+// This is to allow tuning projects.yaml weight/cpu_budget/nice values
+func setSyncDuration(ctx *lib.Ctx, db string, command []string, dtStart, dtEnd time.Time, failed bool) {
+	metric := "devstats_sync"
+	if failed {
+		metric = "devstats_sync_failed"
+	}
+	took := dtEnd.Sub(dtStart)
+	tookMs := took.Milliseconds()
+	tookStr := fmt.Sprintf("%+v", took)
+	cmd := strings.Join(command, " ")
+	con := lib.PgConnDB(ctx, db)
+	defer func() { _ = con.Close() }()
+	_, err := lib.ExecSQL(
+		con,
+		ctx,
+		"insert into gha_last_computed(metric, dt, start_dt, took, took_as_str, command) "+
+			"values($1, $2, $3, $4, $5, $6) "+
+			"on conflict(metric) do update set "+
+			"dt = $7, start_dt = $8, took = $9, took_as_str = $10, command = $11 "+
+			"where gha_last_computed.metric = $12",
+		metric,
+		dtEnd,
+		dtStart,
+		tookMs,
+		tookStr,
+		cmd,
+		dtEnd,
+		dtStart,
+		tookMs,
+		tookStr,
+		cmd,
+		metric,
+	)
+	if err != nil {
+		lib.Printf("Cannot save '%s' sync duration: %+v\n", db, err)
+	}
+}
+
+// Sync all projects from "projects.yaml", calling `gha2db_sync` for all of them
+func syncAllProjects() bool {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Local or cron mode?
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	cmdPrefix := ""
+	if ctx.LocalCmd {
+		cmdPrefix = "./"
+	}
+
+	// Read defined projects
+	data, err := ioutil.ReadFile(dataPrefix + ctx.ProjectsYaml)
+	lib.FatalOnError(err)
+
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+
+	// Get ordered & filtered projects
+	names, projs := lib.GetProjectsList(&ctx, &projects)
+
+	// Lighter projects are synced first, see projects.yaml "weight"
+	names, projs = lib.OrderProjectsByWeight(names, projs)
+
+	// If check provision flag is set, we need to iterate all projects
+	// and check if all of them are provisioned
+	if ctx.CheckProvisionFlag {
+		missing := 0
+		for _, proj := range projs {
+			db := proj.PDB
+			con := lib.PgConnDB(&ctx, db)
+			provisionFlag := "provisioned"
+			var (
+				err  error
+				rows *sql.Rows
+			)
+			trials := 0
+			for true {
+				rows, err = lib.QuerySQL(con, &ctx, "select 1 from gha_computed where metric = "+lib.NValue(1)+" limit 1", provisionFlag)
+				trials++
+				if err != nil {
+					switch e := err.(type) {
+					case *pq.Error:
+						errName := e.Code.Name()
+						if errName == lib.InvalidCatalogName {
+							lib.Printf("No '%s' database, missing provisioning flag\n", db)
+							missing++
+							lib.FatalOnError(con.Close())
+							continue
+						} else {
+							lib.FatalOnError(err)
+						}
+					default:
+						lib.FatalOnError(err)
+					}
+				}
+				if err == nil || trials >= 3 {
+					break
+				}
+			}
+			provisioned := 0
+			for rows.Next() {
+				lib.FatalOnError(rows.Scan(&provisioned))
+			}
+			lib.FatalOnError(rows.Err())
+			lib.FatalOnError(rows.Close())
+			lib.FatalOnError(con.Close())
+			if provisioned != 1 {
+				lib.Printf("Missing provisioned flag on '%s' database and check provisioned flag is set\n", db)
+				missing++
+			}
+		}
+		if missing > 0 {
+			lib.Printf("Not all databases provisioned, pending: %d, exiting\n", missing)
+			return false
+		}
+	}
+
+	// If running provision flag is set on any project, we skip running this devstats instance
+	runningFlag := "devstats_running"
+	if ctx.CheckRunningFlag {
+		for _, proj := range projs {
+			db := proj.PDB
+			con := lib.PgConnDB(&ctx, db)
+			rows, err := lib.QuerySQL(
+				con,
+				&ctx,
+				"select dt from gha_computed where metric = "+lib.NValue(1)+" order by dt desc limit 1",
+				runningFlag,
+			)
+			if err != nil {
+				switch e := err.(type) {
+				case *pq.Error:
+					errName := e.Code.Name()
+					if errName == lib.InvalidCatalogName {
+						lib.Printf("No '%s' database, cannot check running flag\n", db)
+						lib.FatalOnError(con.Close())
+						return false
+					}
+					lib.FatalOnError(err)
+				default:
+					lib.FatalOnError(err)
+				}
+			}
+			running := time.Now()
+			runningSet := false
+			for rows.Next() {
+				lib.FatalOnError(rows.Scan(&running))
+				runningSet = true
+			}
+			lib.FatalOnError(rows.Err())
+			lib.FatalOnError(rows.Close())
+			if runningSet {
+				age := time.Now().Sub(running)
+				lib.Printf("Running flag on '%s' set, age %+v, maximum allowed age: %+v\n", db, age, ctx.MaxRunningFlagAge)
+				if age <= ctx.MaxRunningFlagAge {
+					lib.Printf("Running flag on '%s' set, exiting\n", db)
+					lib.FatalOnError(con.Close())
+					return false
+				}
+				lib.Printf("Running flag on '%s' expired, removing (this may be due to some error)\n", db)
+				lib.ExecSQLWithErr(con, &ctx, "delete from gha_computed where metric ="+lib.NValue(1), runningFlag)
+				lib.FatalOnError(con.Close())
+				lib.Printf("Running flag on '%s' force removed\n", db)
+			}
+		}
+	}
+
+	// Set 'devstats_running' flag on 'gha_computed' table and defer clearing that flag
+	if ctx.SetRunningFlag {
+		if ctx.Debug > 0 {
+			lib.Printf("Setting running flag\n")
+		}
+		missing := 0
+		for _, proj := range projs {
+			db := proj.PDB
+			con := lib.PgConnDB(&ctx, db)
+			_, err := lib.ExecSQL(
+				con,
+				&ctx,
+				"insert into gha_computed(metric, dt) select "+lib.NValue(1)+", now() where not exists(select 1 from gha_computed where metric = "+lib.NValue(2)+")",
+				runningFlag,
+				runningFlag,
+			)
+			if err != nil {
+				switch e := err.(type) {
+				case *pq.Error:
+					errName := e.Code.Name()
+					if errName == lib.InvalidCatalogName {
+						lib.Printf("No '%s' database, cannot set running flag\n", db)
+						missing++
+						lib.FatalOnError(con.Close())
+						continue
+					} else {
+						lib.FatalOnError(err)
+					}
+				default:
+					lib.FatalOnError(err)
+				}
+			}
+			lib.FatalOnError(con.Close())
+			if ctx.Debug > 0 {
+				lib.Printf("Set running flag on %s\n", db)
+			}
+		}
+		// Defer clearing that flag
+		defer func() {
+			if ctx.Debug > 0 {
+				lib.Printf("Deleting running flag\n")
+			}
+			for _, proj := range projs {
+				db := proj.PDB
+				sleepTime := 1
+				for {
+					con := lib.PgConnDB(&ctx, db)
+					_, err := lib.ExecSQL(con, &ctx, "delete from gha_computed where metric = "+lib.NValue(1), runningFlag)
+					con.Close()
+					if err == nil {
+						if ctx.Debug > 0 || sleepTime > 1 {
+							lib.Printf("Cleared running flag on %s\n", db)
+						}
+						break
+					} else {
+						if sleepTime >= 90 {
+							lib.Fatalf("something really bad happened, tried to clear running flag 90 times without success")
+						}
+						lib.Printf("Failed to clear running flag on %s: %+v, retrying after %d seconds\n", db, err, sleepTime)
+						time.Sleep(time.Duration(sleepTime) * time.Second)
+						sleepTime++
+					}
+				}
+			}
+		}()
+		if missing > 0 {
+			lib.Printf("Not all databases present, missing: %d, exiting\n", missing)
+			return false
+		}
+	}
+
+	// Set non-fatal exec mode, we want to run sync for next project(s) if current fails
+	ctx.ExecFatal = false
+
+	if !ctx.SkipPIDFile {
+		// Create PID file (if not exists)
+		// If PID file exists, exit
+		pid := os.Getpid()
+		pidFile := "/tmp/" + ctx.PidFileRoot + ".pid"
+		f, err := os.OpenFile(pidFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
+		if err != nil {
+			lib.Printf("Another `devstats` instance is running, PID file '%s' exists, exiting (not an error)\n", pidFile)
+			return false
+		}
+		fmt.Fprintf(f, "%d", pid)
+		lib.FatalOnError(f.Close())
+
+		// Schedule remove PID file when finished
+		defer func() { lib.FatalOnError(os.Remove(pidFile)) }()
+	}
+
+	// Only run clone/pull part here
+	// Remaining commit analysis in "gha2db_sync"
+	// after new commits are fetched from GHA
+	// So here we get repo files to the newest state
+	// And the gha2db_sync takes Postgres DB commits to the newest state
+	// after this it need to update commit files
+	if !ctx.SkipGetRepos {
+		lib.Printf("Updating git repos for all projects\n")
+		dtStart := time.Now()
+		_, res := lib.ExecCommand(
+			&ctx,
+			[]string{
+				cmdPrefix + "get_repos",
+			},
+			map[string]string{
+				"GHA2DB_PROCESS_REPOS": "1",
+			},
+		)
+		dtEnd := time.Now()
+		if res != nil {
+			lib.Printf("Error updating git repos (took %v): %+v\n", dtEnd.Sub(dtStart), res)
+			fmt.Fprintf(os.Stderr, "%v: Error updating git repos (took %v): %+v\n", dtEnd, dtEnd.Sub(dtStart), res)
+			return false
+		}
+		lib.Printf("Updated git repos, took: %v\n", dtEnd.Sub(dtStart))
+	}
+
+	// If there is an affs_lock/giant_lock mtx set on 'devstats' database and it is older than 30/60 hours - remove it (so other affs can start importing)
+	lib.ClearOrphanedLocks()
+
+	// Sync all projects
+	for i, name := range names {
+		proj := projs[i]
+		if proj.SyncProbability != nil {
+			rand.Seed(time.Now().UnixNano())
+			if rand.Float64() >= *proj.SyncProbability {
+				lib.Printf("Skipping #%d %s\n", proj.Order, name)
+				continue
+			}
+		}
+		projEnv := map[string]string{
+			"GHA2DB_PROJECT": name,
+			"PG_DB":          proj.PDB,
+			"ENV_SET":        "1",
+		}
+		// CPU budget is a fraction of available CPUs that project's sync can use
+		// GetThreadsNum in all called tools will respect GHA2DB_NCPUS set here
+		if proj.CPUBudget != nil {
+			projEnv["GHA2DB_NCPUS"] = strconv.Itoa(lib.BudgetThreadsNum(*proj.CPUBudget))
+		}
+		// Events of very large multi-org projects can be split between shard databases (by org hash)
+		if len(proj.Shards) > 0 {
+			projEnv["GHA2DB_SHARDS"] = strings.Join(proj.Shards, ",")
+		}
+		// Per project features, tools check them via ctx.FeatureEnabled
+		if len(proj.Features) > 0 {
+			projEnv["GHA2DB_FEATURES"] = lib.FeaturesToString(proj.Features)
+		}
+		// Apply eventual per project specific environment
+		for envName, envValue := range proj.Env {
+			projEnv[envName] = envValue
+		}
+		command := []string{cmdPrefix + "gha2db_sync"}
+		if proj.Nice != nil {
+			command = append([]string{"nice", "-n", strconv.Itoa(*proj.Nice)}, command...)
+		}
+		lib.Printf("Syncing #%d %s\n", proj.Order, name)
+		dtStart := time.Now()
+		_, res := lib.ExecCommand(
+			&ctx,
+			command,
+			projEnv,
+		)
+		dtEnd := time.Now()
+		setSyncDuration(&ctx, proj.PDB, command, dtStart, dtEnd, res != nil)
+		if res != nil {
+			code := lib.ExitCode(res)
+			lib.Printf("Error result for %s (took %v, exit code %d: %s): %+v\n", name, dtEnd.Sub(dtStart), code, lib.ExitCodeNames[code], res)
+			fmt.Fprintf(os.Stderr, "%v: Error result for %s (took %v, exit code %d: %s): %+v\n", dtEnd, name, dtEnd.Sub(dtStart), code, lib.ExitCodeNames[code], res)
+			continue
+		}
+		lib.Printf("Synced %s, took: %v\n", name, dtEnd.Sub(dtStart))
+	}
+	if ctx.WebsiteData {
+		lib.Printf("Generating website data for all projects\n")
+		dtStart := time.Now()
+		_, res := lib.ExecCommand(
+			&ctx,
+			[]string{
+				cmdPrefix + "website_data",
+			},
+			nil,
+		)
+		dtEnd := time.Now()
+		if res != nil {
+			lib.Printf("Error generating website data (took %v): %+v\n", dtEnd.Sub(dtStart), res)
+			fmt.Fprintf(os.Stderr, "%v: Error generating website (took %v): %+v\n", dtEnd, dtEnd.Sub(dtStart), res)
+			return false
+		}
+		lib.Printf("Generated website data, took: %v\n", dtEnd.Sub(dtStart))
+	}
+	return true
+}
+
+// listEnv - prints all settings with their environment variables, types, documented defaults and current values
+func listEnv() {
+	var ctx lib.Ctx
+	ctx.Init()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "ENV\tFIELD\tTYPE\tDEFAULT\tVALUE\n")
+	for _, s := range ctx.Describe() {
+		env := strings.Join(s.Env, ",")
+		if env == "" {
+			env = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", env, s.Field, s.Type, s.Default, s.Value)
+	}
+	_ = tw.Flush()
+}
+
+// Main - runs devstats tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	if len(os.Args) > 1 && os.Args[1] == "--list-env" {
+		listEnv()
+		return
+	}
+	dtStart := time.Now()
+	synced := syncAllProjects()
+	dtEnd := time.Now()
+	if synced {
+		lib.Printf("Synced all projects in: %v\n", dtEnd.Sub(dtStart))
+	} else {
+		lib.Printf("There were sync errors, took: %v\n", dtEnd.Sub(dtStart))
+	}
+}
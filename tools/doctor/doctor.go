@@ -0,0 +1,350 @@
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Check statuses, failed checks make doctor exit with non-zero code
+const (
+	pass = "PASS"
+	warn = "WARN"
+	fail = "FAIL"
+)
+
+// checkResult - result of a single check
+type checkResult struct {
+	status string
+	name   string
+	detail string
+}
+
+// doctor - collects check results
+type doctor struct {
+	ctx     *lib.Ctx
+	results []checkResult
+}
+
+// add - records check result, it is also printed immediately
+func (d *doctor) add(status, name, detail string, args ...interface{}) {
+	if len(args) > 0 {
+		detail = fmt.Sprintf(detail, args...)
+	}
+	d.results = append(d.results, checkResult{status: status, name: name, detail: detail})
+	lib.Printf("%s %s: %s\n", status, name, detail)
+}
+
+// counts - returns number of passed, warning and failed checks
+func (d *doctor) counts() (passed, warned, failed int) {
+	for _, result := range d.results {
+		switch result.status {
+		case pass:
+			passed++
+		case warn:
+			warned++
+		case fail:
+			failed++
+		}
+	}
+	return
+}
+
+// checkConfig - checks projects.yaml and returns databases of enabled projects (only of given projects when specified)
+func (d *doctor) checkConfig(projects []string) (dbs map[string]string) {
+	ctx := d.ctx
+	dbs = make(map[string]string)
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	if _, err := os.Stat(dataPrefix); err != nil {
+		d.add(fail, "config", "data directory %s: %v (GHA2DB_DATADIR, GHA2DB_LOCAL)", dataPrefix, err)
+	} else {
+		d.add(pass, "config", "data directory %s exists", dataPrefix)
+	}
+	data, err := lib.ReadFile(ctx, dataPrefix+ctx.ProjectsYaml)
+	if err != nil {
+		d.add(fail, "config", "cannot read projects file %s: %v", dataPrefix+ctx.ProjectsYaml, err)
+		return
+	}
+	var allProjects lib.AllProjects
+	err = yaml.Unmarshal(data, &allProjects)
+	if err != nil {
+		d.add(fail, "config", "cannot parse projects file %s: %v", dataPrefix+ctx.ProjectsYaml, err)
+		return
+	}
+	wanted := make(map[string]struct{})
+	for _, project := range projects {
+		wanted[project] = struct{}{}
+	}
+	disabled := 0
+	for name, project := range allProjects.Projects {
+		if len(wanted) > 0 {
+			if _, ok := wanted[name]; !ok {
+				continue
+			}
+			delete(wanted, name)
+		}
+		if project.Disabled {
+			disabled++
+			continue
+		}
+		if project.PDB == "" {
+			d.add(fail, "config", "project %s has no psql_db defined", name)
+			continue
+		}
+		dbs[name] = project.PDB
+	}
+	for name := range wanted {
+		d.add(fail, "config", "project %s not found in %s", name, ctx.ProjectsYaml)
+	}
+	if len(dbs) == 0 {
+		d.add(fail, "config", "no enabled projects in %s", ctx.ProjectsYaml)
+	} else {
+		d.add(pass, "config", "%d enabled projects in %s (%d disabled)", len(dbs), ctx.ProjectsYaml, disabled)
+	}
+	if ctx.PgPass == lib.Password {
+		d.add(warn, "config", "PG_PASS is not set, default password is used")
+	}
+	if ctx.PgSSL == "disable" && ctx.PgHost != "localhost" && ctx.PgHost != "127.0.0.1" {
+		d.add(warn, "config", "SSL is disabled for remote Postgres host %s (PG_SSL)", ctx.PgHost)
+	}
+	return
+}
+
+// checkDB - checks connectivity and tables of a given project database
+func (d *doctor) checkDB(project, db string) {
+	ctx := d.ctx
+	name := "postgres " + project
+	con, err := lib.PgConnErr(&lib.Ctx{PgHost: ctx.PgHost, PgPort: ctx.PgPort, PgDB: db, PgUser: ctx.PgUser, PgPass: ctx.PgPass, PgSSL: ctx.PgSSL})
+	if err != nil {
+		d.add(fail, name, "%s: %v", db, err)
+		return
+	}
+	defer func() { _ = con.Close() }()
+	pctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = con.PingContext(pctx)
+	if err != nil {
+		d.add(fail, name, "cannot connect to %s@%s:%s/%s: %v", ctx.PgUser, ctx.PgHost, ctx.PgPort, db, err)
+		return
+	}
+	rows, err := con.QueryContext(pctx, "select table_name from information_schema.tables where table_schema in ('public', 'shards')")
+	if err != nil {
+		d.add(fail, name, "%s: cannot list tables: %v", db, err)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	tables := make(map[string]struct{})
+	var table string
+	for rows.Next() {
+		err = rows.Scan(&table)
+		if err != nil {
+			d.add(fail, name, "%s: cannot list tables: %v", db, err)
+			return
+		}
+		tables[table] = struct{}{}
+	}
+	err = rows.Err()
+	if err != nil {
+		d.add(fail, name, "%s: cannot list tables: %v", db, err)
+		return
+	}
+	missing := []string{}
+	for table := range lib.SchemaManifest {
+		if _, optional := lib.SchemaOptionalTables[table]; optional {
+			continue
+		}
+		if _, ok := tables[table]; !ok {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		d.add(fail, name, "%s: missing tables: %s (run structure)", db, strings.Join(missing, ", "))
+		return
+	}
+	var lastParsed sql.NullTime
+	err = con.QueryRowContext(pctx, "select max(dt) from gha_parsed").Scan(&lastParsed)
+	if err != nil {
+		d.add(fail, name, "%s: cannot read gha_parsed: %v", db, err)
+		return
+	}
+	if !lastParsed.Valid {
+		d.add(warn, name, "%s: all %d tables present, no GHA data imported yet", db, len(lib.SchemaManifest))
+		return
+	}
+	var partial int
+	err = con.QueryRowContext(pctx, "select count(*) from gha_parsed where status = "+lib.NValue(1), lib.ParsedPartial).Scan(&partial)
+	if err != nil {
+		d.add(fail, name, "%s: cannot read gha_parsed status: %v (run gha2db to add it)", db, err)
+		return
+	}
+	if partial > 0 {
+		d.add(warn, name, "%s: all tables present, GHA data imported up to %s, %d partially parsed hours (next sync parses them again)", db, lib.ToYMDHDate(lastParsed.Time), partial)
+		return
+	}
+	d.add(pass, name, "%s: all tables present, GHA data imported up to %s", db, lib.ToYMDHDate(lastParsed.Time))
+}
+
+// checkGHA - checks that GHA archives can be fetched (or read from GHA2DB_LOCAL_JSONS_DIR)
+func (d *doctor) checkGHA() {
+	ctx := d.ctx
+	if ctx.LocalJSONsDir != "" {
+		files, err := ioutil.ReadDir(ctx.LocalJSONsDir)
+		if err != nil {
+			d.add(fail, "gharchive", "cannot read local GHA directory %s: %v", ctx.LocalJSONsDir, err)
+			return
+		}
+		d.add(pass, "gharchive", "local GHA directory %s has %d files", ctx.LocalJSONsDir, len(files))
+		return
+	}
+	// The previous day is always archived
+	url := fmt.Sprintf("https://data.gharchive.org/%s.json.gz", lib.ToGHADate(lib.DayStart(time.Now()).AddDate(0, 0, -1)))
+	hctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(hctx, http.MethodHead, url, nil)
+	if err != nil {
+		d.add(fail, "gharchive", "%s: %v", url, err)
+		return
+	}
+	resp, err := lib.HTTPClient(ctx).Do(req)
+	if err != nil {
+		d.add(fail, "gharchive", "%s: %v", url, err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		d.add(fail, "gharchive", "%s: HTTP status %d", url, resp.StatusCode)
+		return
+	}
+	d.add(pass, "gharchive", "%s is reachable (%d bytes)", url, resp.ContentLength)
+}
+
+// checkGitHub - checks that each GitHub token (or GitHub App installation) is valid and reports its remaining API points
+func (d *doctor) checkGitHub() {
+	ctx := d.ctx
+	if ctx.SkipGHAPI {
+		d.add(pass, "github", "GitHub API is skipped (GHA2DB_GHAPISKIP)")
+		return
+	}
+	// Token kinds (classic or fine-grained) are only reported, classic tokens still work
+	names := []string{}
+	if ctx.GitHubAppID > 0 {
+		_, err := lib.ReadFile(ctx, ctx.GitHubAppKey)
+		if err != nil {
+			d.add(fail, "github", "cannot read GitHub App private key file %s: %v", ctx.GitHubAppKey, err)
+			return
+		}
+		for _, installationID := range ctx.GitHubAppInstallations {
+			names = append(names, fmt.Sprintf("github app %d installation %d", ctx.GitHubAppID, installationID))
+		}
+	} else {
+		oAuth := ctx.GitHubOAuth
+		if oAuth == "-" {
+			d.add(warn, "github", "no GitHub token, public access is limited to 60 API points per hour (GHA2DB_GITHUB_OAUTH)")
+		}
+		if strings.Contains(oAuth, "/") {
+			data, err := lib.ReadFile(ctx, oAuth)
+			if err != nil {
+				d.add(fail, "github", "cannot read GitHub token file %s: %v", oAuth, err)
+				return
+			}
+			oAuth = string(data)
+		}
+		if oAuth != "-" {
+			for idx, token := range lib.GitHubTokens(oAuth) {
+				names = append(names, fmt.Sprintf("github token #%d (%s)", idx+1, lib.GitHubTokenKind(token)))
+			}
+		}
+	}
+	gctx, clients := lib.GHClient(ctx)
+	for idx, client := range clients {
+		name := fmt.Sprintf("github token #%d", idx+1)
+		if idx < len(names) {
+			name = names[idx]
+		}
+		rl, _, err := client.RateLimits(gctx)
+		if err != nil {
+			d.add(fail, name, "%v", err)
+			continue
+		}
+		if rl == nil || rl.Core == nil {
+			d.add(fail, name, "no rate limits returned")
+			continue
+		}
+		status := pass
+		if rl.Core.Remaining < ctx.MinGHAPIPoints {
+			status = warn
+		}
+		d.add(status, name, "%d/%d API points remaining, resets at %s", rl.Core.Remaining, rl.Core.Limit, lib.ToYMDHMSDate(rl.Core.Reset.Time))
+	}
+}
+
+// checkJSONsDir - checks that JSON files can be written into GHA2DB_JSONS_DIR
+func (d *doctor) checkJSONsDir() {
+	dir := d.ctx.JSONsDir
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		d.add(warn, "jsons dir", "%s does not exist (GHA2DB_JSONS_DIR)", dir)
+		return
+	}
+	if err != nil {
+		d.add(fail, "jsons dir", "%s: %v", dir, err)
+		return
+	}
+	if !info.IsDir() {
+		d.add(fail, "jsons dir", "%s is not a directory", dir)
+		return
+	}
+	f, err := ioutil.TempFile(dir, ".doctor-*")
+	if err != nil {
+		d.add(fail, "jsons dir", "%s is not writable: %v", dir, err)
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	d.add(pass, "jsons dir", "%s is writable", dir)
+}
+
+// Main - runs doctor tool, os.Args are its command line (os.Args[0] is the tool name)
+// Checks everything a deployment needs and prints a pass/fail report, exits with 1 when any check fails
+// Arguments are project names to check databases of (GHA2DB_PROJECT or all enabled projects by default)
+func Main() {
+	dtStart := time.Now()
+	var ctx lib.Ctx
+	ctx.Init()
+	projects := os.Args[1:]
+	if len(projects) == 0 && ctx.Project != "" {
+		projects = []string{ctx.Project}
+	}
+	d := &doctor{ctx: &ctx}
+	dbs := d.checkConfig(projects)
+	names := []string{}
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.checkDB(name, dbs[name])
+	}
+	d.checkGHA()
+	d.checkGitHub()
+	d.checkJSONsDir()
+	passed, warned, failed := d.counts()
+	dtEnd := time.Now()
+	lib.Printf("Checks: %d passed, %d warnings, %d failed, time: %v\n", passed, warned, failed, dtEnd.Sub(dtStart))
+	if failed > 0 {
+		os.Exit(lib.ExitConfig)
+	}
+}
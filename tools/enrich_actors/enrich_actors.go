@@ -0,0 +1,213 @@
+package enrichactors
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+)
+
+// actorGeo - previously stored enrichment data for an actor
+type actorGeo struct {
+	login     string
+	countryID *string
+	tz        *string
+}
+
+// getActors - returns actors active in the last 'days' days that were not checked in the last 'recheckDays' days
+// Only actors without a country or with a country set by previous enrichment are returned
+func getActors(con *sql.DB, ctx *lib.Ctx, days, recheckDays, limit int) (actors []actorGeo) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select a.login, g.country_id, g.tz from gha_actors a "+
+			"left join gha_actors_geo g on g.login = a.login "+
+			"where a.id > 0 "+
+			"and (a.country_id is null or a.country_id = g.country_id) "+
+			"and (g.checked_at is null or g.checked_at < now() - $1::interval) "+
+			"and a.id in (select distinct actor_id from gha_events where created_at >= now() - $2::interval) "+
+			"order by g.checked_at nulls first, a.login limit $3",
+		strconv.Itoa(recheckDays)+" days",
+		strconv.Itoa(days)+" days",
+		limit,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	seen := make(map[string]struct{})
+	for rows.Next() {
+		var a actorGeo
+		lib.FatalOnError(rows.Scan(&a.login, &a.countryID, &a.tz))
+		if _, ok := seen[a.login]; ok {
+			continue
+		}
+		seen[a.login] = struct{}{}
+		actors = append(actors, a)
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// getLocation - returns public profile location of a GitHub user, waits for API points when needed
+// Second returned value is false when location cannot be fetched now (should be retried in the next run)
+func getLocation(gctx context.Context, gc []*github.Client, ctx *lib.Ctx, login string) (string, bool) {
+	for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+		hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				lib.Printf("API limit reached while getting user data, waiting %v (%d)\n", waitPeriod[hint], tr)
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(waitPeriod[hint])
+				continue
+			}
+			lib.Printf("API limit reached while getting user data, don't want to wait %v, stopping\n", waitPeriod[hint])
+			return "", false
+		}
+		lib.GHRateGateWait()
+		user, _, err := gc[hint].Users.Get(gctx, login)
+		res := lib.HandlePossibleError(err, login, "Users.Get")
+		if res != "" {
+			if res == lib.Abuse {
+				wait := lib.GHAbuseWait(err, tr)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("GitHub API abuse detected (user), wait %v\n", wait)
+				}
+				lib.GHRateGateSleep(wait)
+			}
+			if res == lib.NotFound {
+				// User deleted or renamed, there is no location to get
+				return "", true
+			}
+			continue
+		}
+		if user == nil || user.Location == nil {
+			return "", true
+		}
+		return strings.TrimSpace(*user.Location), true
+	}
+	return "", false
+}
+
+// getCountryCodes - returns gha_countries codes by their lower case form
+// Resolvers return upper case codes, they are stored in the same form as in gha_countries (devstats uses lower case codes)
+func getCountryCodes(con *sql.DB, ctx *lib.Ctx) map[string]string {
+	rows := lib.QuerySQLWithErr(con, ctx, "select code from gha_countries")
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	codes := make(map[string]string)
+	code := ""
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&code))
+		codes[strings.ToLower(code)] = code
+	}
+	lib.FatalOnError(rows.Err())
+	return codes
+}
+
+// nilIfEmpty - returns nil for empty strings, so they are stored as nulls
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// enrichActor - stores location and resolved country/tz, updates gha_actors unless values there were curated manually
+func enrichActor(con *sql.DB, ctx *lib.Ctx, a *actorGeo, location, countryID, tz string) {
+	// Never overwrite values that were not set by the previous enrichment (for example affiliations imports)
+	lib.ExecSQLWithErr(
+		con,
+		ctx,
+		"update gha_actors set "+
+			"country_id = case when country_id is null or country_id = $2 then $3 else country_id end, "+
+			"country_name = case when country_id is null or country_id = $2 then "+
+			"(select name from gha_countries where lower(code) = lower($3)) else country_name end, "+
+			"tz = case when $5 <> '' and (tz is null or tz = $4) then $5 else tz end "+
+			"where login = $1",
+		a.login,
+		a.countryID,
+		nilIfEmpty(countryID),
+		a.tz,
+		tz,
+	)
+	q, args := lib.NewQB("gha_actors_geo").
+		Set("login", a.login).
+		Set("location", lib.CleanUTF8(location)).
+		Set("country_id", nilIfEmpty(countryID)).
+		Set("tz", nilIfEmpty(tz)).
+		Set("checked_at", time.Now()).
+		Upsert("login")
+	lib.ExecSQLWithErr(con, ctx, q, args...)
+}
+
+// enrichActors - fetches GitHub profile locations of active actors and resolves them to countries and time zones
+func enrichActors(days, recheckDays, limit int) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	lib.Migrate(con, &ctx)
+
+	resolver, err := lib.GetLocationResolver(con, &ctx)
+	lib.FatalOnError(err)
+	codes := getCountryCodes(con, &ctx)
+
+	// Connect to GitHub API
+	gctx, gc := lib.GHClient(&ctx)
+
+	actors := getActors(con, &ctx, days, recheckDays, limit)
+	lib.Printf("Enriching %d actors active in the last %d days\n", len(actors), days)
+	checked, resolved := 0, 0
+	for i := range actors {
+		a := &actors[i]
+		location, ok := getLocation(gctx, gc, &ctx, a.login)
+		if !ok {
+			lib.Printf("Cannot get location for %s, stopping\n", a.login)
+			break
+		}
+		countryID, tz := "", ""
+		if location != "" {
+			countryID, tz, _ = resolver.Resolve(location)
+		}
+		if code, ok := codes[strings.ToLower(countryID)]; ok {
+			countryID = code
+		}
+		if countryID != "" {
+			resolved++
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("%s: '%s' -> '%s' '%s'\n", a.login, location, countryID, tz)
+		}
+		enrichActor(con, &ctx, a, location, countryID, tz)
+		checked++
+		if checked%100 == 0 {
+			lib.Printf("Checked %d/%d actors, resolved %d\n", checked, len(actors), resolved)
+		}
+	}
+	lib.Printf("Checked %d actors, resolved %d locations\n", checked, resolved)
+}
+
+// Main - runs enrich_actors tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	// Defaults: actors active in the last 90 days, recheck after 30 days, at most 5000 actors per run (GitHub API points)
+	args := []int{90, 30, 5000}
+	if len(os.Args) > 4 {
+		lib.Printf("Usage: %s [active_days [recheck_days [limit]]]\n", os.Args[0])
+		os.Exit(lib.ExitConfig)
+	}
+	for i, arg := range os.Args[1:] {
+		v, err := strconv.Atoi(arg)
+		lib.FatalOnError(err)
+		args[i] = v
+	}
+	enrichActors(args[0], args[1], args[2])
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,2734 @@
+package gha2db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/cncf/devstatscode/lib/trailers"
+	jsoniter "github.com/json-iterator/go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// gDiffMtx - diff mode (GHA2DB_DIFF) stats access mutex
+	gDiffMtx = &sync.Mutex{}
+	// gDiffStats - diff mode per table stats: expected rows, rows in DB, missing in DB, extra in DB
+	gDiffStats = make(map[string][4]int)
+	// gDiffSamples - diff mode sampled mismatches per table
+	gDiffSamples = make(map[string][]string)
+	// gDiffMaxSamples - maximum number of sampled mismatches reported per table
+	gDiffMaxSamples = 10
+	// gActorsCon - main database connection in sharding mode (GHA2DB_SHARDS), actors are replicated to it
+	// and looked up in it, so import_affs and per-shard lookups see actors from all shards, nil without sharding
+	gActorsCon *sql.DB
+)
+
+// Inserts single GHA Actor
+func ghaActor(con *sql.Tx, ctx *lib.Ctx, actor *lib.Actor, maybeHide func(string) string) {
+	// gha_actors
+	// {"id:Fixnum"=>48592, "login:String"=>48592, "display_login:String"=>48592,
+	// "gravatar_id:String"=>48592, "url:String"=>48592, "avatar_url:String"=>48592}
+	// {"id"=>8, "login"=>34, "display_login"=>34, "gravatar_id"=>0, "url"=>63, "avatar_url"=>49}
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		lib.InsertIgnore("into gha_actors(id, login, name) "+lib.NValues(3)),
+		lib.AnyArray{actor.ID, maybeHide(actor.Login), ""}...,
+	)
+	if gActorsCon != nil {
+		lib.ExecSQLWithErr(
+			gActorsCon,
+			ctx,
+			lib.InsertIgnore("into gha_actors(id, login, name) "+lib.NValues(3)),
+			lib.AnyArray{actor.ID, maybeHide(actor.Login), ""}...,
+		)
+	}
+}
+
+// Inserts single GHA Repo
+func ghaRepo(db *sql.DB, ctx *lib.Ctx, repo *lib.Repo, orgID, orgLogin interface{}) {
+	// gha_repos
+	// {"id:Fixnum"=>48592, "name:String"=>48592, "url:String"=>48592}
+	// {"id"=>8, "name"=>111, "url"=>140}
+	lib.ExecSQLWithErr(
+		db,
+		ctx,
+		lib.InsertIgnore("into gha_repos(id, name, org_id, org_login) "+lib.NValues(4)),
+		lib.AnyArray{repo.ID, repo.Name, orgID, orgLogin}...,
+	)
+}
+
+// Inserts single GHA Org
+func ghaOrg(db *sql.DB, ctx *lib.Ctx, org *lib.Org) {
+	// gha_orgs
+	// {"id:Fixnum"=>18494, "login:String"=>18494, "gravatar_id:String"=>18494,
+	// "url:String"=>18494, "avatar_url:String"=>18494}
+	// {"id"=>8, "login"=>38, "gravatar_id"=>0, "url"=>66, "avatar_url"=>49}
+	if org != nil {
+		lib.ExecSQLWithErr(
+			db,
+			ctx,
+			lib.InsertIgnore("into gha_orgs(id, login) "+lib.NValues(2)),
+			lib.AnyArray{org.ID, org.Login}...,
+		)
+	}
+}
+
+// Inserts single GHA Milestone
+func ghaMilestone(con *sql.Tx, ctx *lib.Ctx, eid string, milestone *lib.Milestone, ev *lib.Event, maybeHide func(string) string) {
+	// creator
+	if milestone.Creator != nil {
+		ghaActor(con, ctx, milestone.Creator, maybeHide)
+	}
+
+	// gha_milestones
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_milestones("+
+			"id, event_id, closed_at, closed_issues, created_at, creator_id, "+
+			"description, due_on, number, open_issues, state, title, updated_at, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+			"dupn_creator_login) "+lib.NValues(20),
+		lib.AnyArray{
+			milestone.ID,
+			eid,
+			lib.TimeOrNil(milestone.ClosedAt),
+			milestone.ClosedIssues,
+			milestone.CreatedAt,
+			lib.ActorIDOrNil(milestone.Creator),
+			lib.TruncFieldOrNil(ctx, "gha_milestones.description", milestone.Description),
+			lib.TimeOrNil(milestone.DueOn),
+			milestone.Number,
+			milestone.OpenIssues,
+			milestone.State,
+			lib.TruncField(ctx, "gha_milestones.title", milestone.Title),
+			milestone.UpdatedAt,
+			ev.Actor.ID,
+			maybeHide(ev.Actor.Login),
+			ev.Repo.ID,
+			ev.Repo.Name,
+			ev.Type,
+			ev.CreatedAt,
+			lib.ActorLoginOrNil(milestone.Creator, maybeHide),
+		}...,
+	)
+}
+
+// Inserts single GHA Forkee (old format < 2015)
+func ghaForkeeOld(con *sql.Tx, ctx *lib.Ctx, eid string, forkee *lib.ForkeeOld, actor *lib.Actor, repo *lib.Repo, ev *lib.EventOld, maybeHide func(string) string) {
+
+	// Lookup author by GitHub login
+	aid := lookupActorTx(con, ctx, forkee.Owner, maybeHide)
+
+	// Owner
+	owner := lib.Actor{ID: aid, Login: forkee.Owner}
+	ghaActor(con, ctx, &owner, maybeHide)
+
+	// gha_forkees
+	// Table details and analysis in `analysis/analysis.txt` and `analysis/forkee_*.json`
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_forkees("+
+			"id, event_id, name, full_name, owner_id, description, fork, "+
+			"created_at, updated_at, pushed_at, homepage, size, language, organization, "+
+			"stargazers_count, has_issues, has_projects, has_downloads, "+
+			"has_wiki, has_pages, forks, default_branch, open_issues, watchers, public, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+			"dup_owner_login) "+lib.NValues(32),
+		lib.AnyArray{
+			forkee.ID,
+			eid,
+			lib.TruncField(ctx, "gha_forkees.name", forkee.Name),
+			lib.TruncField(ctx, "gha_forkees.full_name", forkee.Name), // ForkeeOld has no FullName
+			owner.ID,
+			lib.TruncFieldOrNil(ctx, "gha_forkees.description", forkee.Description),
+			forkee.Fork,
+			forkee.CreatedAt,
+			forkee.CreatedAt, // ForkeeOld has no UpdatedAt
+			lib.TimeOrNil(forkee.PushedAt),
+			lib.StringOrNil(forkee.Homepage),
+			forkee.Size,
+			lib.StringOrNil(forkee.Language),
+			lib.StringOrNil(forkee.Organization),
+			forkee.Stargazers,
+			forkee.HasIssues,
+			nil,
+			forkee.HasDownloads,
+			forkee.HasWiki,
+			nil,
+			forkee.Forks,
+			lib.TruncField(ctx, "gha_forkees.default_branch", forkee.DefaultBranch),
+			forkee.OpenIssues,
+			forkee.Watchers,
+			lib.NegatedBoolOrNil(forkee.Private),
+			actor.ID,
+			maybeHide(actor.Login),
+			repo.ID,
+			repo.Name,
+			ev.Type,
+			ev.CreatedAt,
+			maybeHide(owner.Login),
+		}...,
+	)
+}
+
+// Inserts single GHA Forkee
+func ghaForkee(con *sql.Tx, ctx *lib.Ctx, eid string, forkee *lib.Forkee, ev *lib.Event, maybeHide func(string) string) {
+	// owner
+	ghaActor(con, ctx, &forkee.Owner, maybeHide)
+
+	// gha_forkees
+	// Table details and analysis in `analysis/analysis.txt` and `analysis/forkee_*.json`
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_forkees("+
+			"id, event_id, name, full_name, owner_id, description, fork, "+
+			"created_at, updated_at, pushed_at, homepage, size, language, organization, "+
+			"stargazers_count, has_issues, has_projects, has_downloads, "+
+			"has_wiki, has_pages, forks, default_branch, open_issues, watchers, public, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+			"dup_owner_login) "+lib.NValues(32),
+		lib.AnyArray{
+			forkee.ID,
+			eid,
+			lib.TruncField(ctx, "gha_forkees.name", forkee.Name),
+			lib.TruncField(ctx, "gha_forkees.full_name", forkee.FullName),
+			forkee.Owner.ID,
+			lib.TruncFieldOrNil(ctx, "gha_forkees.description", forkee.Description),
+			forkee.Fork,
+			forkee.CreatedAt,
+			forkee.UpdatedAt,
+			lib.TimeOrNil(forkee.PushedAt),
+			lib.StringOrNil(forkee.Homepage),
+			forkee.Size,
+			nil,
+			nil,
+			forkee.StargazersCount,
+			forkee.HasIssues,
+			lib.BoolOrNil(forkee.HasProjects),
+			forkee.HasDownloads,
+			forkee.HasWiki,
+			lib.BoolOrNil(forkee.HasPages),
+			forkee.Forks,
+			lib.TruncField(ctx, "gha_forkees.default_branch", forkee.DefaultBranch),
+			forkee.OpenIssues,
+			forkee.Watchers,
+			lib.BoolOrNil(forkee.Public),
+			ev.Actor.ID,
+			maybeHide(ev.Actor.Login),
+			ev.Repo.ID,
+			ev.Repo.Name,
+			ev.Type,
+			ev.CreatedAt,
+			maybeHide(forkee.Owner.Login),
+		}...,
+	)
+}
+
+// Inserts single GHA Branch
+func ghaBranch(con *sql.Tx, ctx *lib.Ctx, eid string, branch *lib.Branch, ev *lib.Event, skipIDs []int, maybeHide func(string) string) {
+	// user
+	if branch.User != nil {
+		ghaActor(con, ctx, branch.User, maybeHide)
+	}
+
+	// repo
+	if branch.Repo != nil {
+		rid := branch.Repo.ID
+		insert := true
+		for _, skipID := range skipIDs {
+			if rid == skipID {
+				insert = false
+				break
+			}
+		}
+		if insert {
+			ghaForkee(con, ctx, eid, branch.Repo, ev, maybeHide)
+		}
+	}
+
+	// gha_branches
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_branches("+
+			"sha, event_id, user_id, repo_id, label, ref, "+
+			"dup_type, dup_created_at, dupn_user_login, dupn_forkee_name"+
+			") "+lib.NValues(10),
+		lib.AnyArray{
+			branch.SHA,
+			eid,
+			lib.ActorIDOrNil(branch.User),
+			lib.ForkeeIDOrNil(branch.Repo), // GitHub uses JSON "repo" but it conatins Forkee
+			lib.TruncField(ctx, "gha_branches.label", branch.Label),
+			lib.TruncField(ctx, "gha_branches.ref", branch.Ref),
+			ev.Type,
+			ev.CreatedAt,
+			lib.ActorLoginOrNil(branch.User, maybeHide),
+			lib.ForkeeNameOrNil(branch.Repo),
+		}...,
+	)
+}
+
+// Search for given label using name & color
+// If not found, return hash as its ID
+func lookupLabel(con *sql.Tx, ctx *lib.Ctx, name string, color string) int {
+	rows := lib.QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select id from gha_labels where name=%s and color=%s",
+			lib.NValue(1),
+			lib.NValue(2),
+		),
+		name,
+		color,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	lid := 0
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&lid))
+	}
+	lib.FatalOnError(rows.Err())
+	if lid == 0 {
+		lid = lib.HashStrings([]string{name, color})
+	}
+	return lid
+}
+
+// Search for given actor using his/her login
+// If not found, return hash as its ID
+// In sharding mode actors are looked up in the main database
+func lookupActor(db *sql.DB, ctx *lib.Ctx, login string, maybeHide func(string) string) int {
+	if gActorsCon != nil {
+		db = gActorsCon
+	}
+	hlogin := maybeHide(login)
+	rows := lib.QuerySQLWithErr(
+		db,
+		ctx,
+		fmt.Sprintf("select id from gha_actors where login=%s order by id desc limit 1", lib.NValue(1)),
+		hlogin,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	aid := 0
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&aid))
+	}
+	lib.FatalOnError(rows.Err())
+	if aid == 0 {
+		aid = lib.HashStrings([]string{login})
+	}
+	return aid
+}
+
+// Search for given actor using his/her login
+// If not found, return hash as its ID
+// In sharding mode actors are looked up in the main database
+func lookupActorTx(con *sql.Tx, ctx *lib.Ctx, login string, maybeHide func(string) string) int {
+	if gActorsCon != nil {
+		return lookupActor(gActorsCon, ctx, login, maybeHide)
+	}
+	hlogin := maybeHide(login)
+	rows := lib.QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf("select id from gha_actors where login=%s order by id desc limit 1", lib.NValue(1)),
+		hlogin,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	aid := 0
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&aid))
+	}
+	lib.FatalOnError(rows.Err())
+	if aid == 0 {
+		aid = lib.HashStrings([]string{login})
+	}
+	return aid
+}
+
+// Try to find Repo by name and Organization
+func findRepoFromNameAndOrg(db *sql.DB, ctx *lib.Ctx, repoName string, orgID *int) (int, bool) {
+	var rows *sql.Rows
+	if orgID != nil {
+		rows = lib.QuerySQLWithErr(
+			db,
+			ctx,
+			fmt.Sprintf(
+				"select id from gha_repos where name=%s and org_id=%s",
+				lib.NValue(1),
+				lib.NValue(2),
+			),
+			repoName,
+			orgID,
+		)
+	} else {
+		rows = lib.QuerySQLWithErr(
+			db,
+			ctx,
+			fmt.Sprintf(
+				"select id from gha_repos where name=%s and org_id is null",
+				lib.NValue(1),
+			),
+			repoName,
+		)
+	}
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	exists := false
+	rid := 0
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&rid))
+		exists = true
+	}
+	lib.FatalOnError(rows.Err())
+	return rid, exists
+}
+
+// Try to find OrgID for given OrgLogin (returns nil for nil)
+func findOrgIDOrNil(db *sql.DB, ctx *lib.Ctx, orgLogin *string) *int {
+	var orgID int
+	if orgLogin == nil {
+		return nil
+	}
+	rows := lib.QuerySQLWithErr(
+		db,
+		ctx,
+		fmt.Sprintf(
+			"select id from gha_orgs where login=%s",
+			lib.NValue(1),
+		),
+		*orgLogin,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&orgID))
+		return &orgID
+	}
+	lib.FatalOnError(rows.Err())
+	return nil
+}
+
+// Check if given event existis (given by ID)
+// GitHub event IDs are global, so the same event added by another project sharing the DB is also an existing one
+func eventExists(db *sql.DB, ctx *lib.Ctx, eventID string) bool {
+	project, ok := lib.EventProject(db, ctx, eventID)
+	if ok && ctx.Debug > 0 && project != ctx.Project {
+		lib.Printf("Event %s already added by project '%s'\n", eventID, project)
+	}
+	return ok
+}
+
+// oldEventID - returns ID of pre-2015 event, hashed from its type, actor, repository name (without org) and date
+// When an event with that ID already exists in the same repo (also added by another project sharing the DB) the ID is kept,
+// so the event is skipped as an existing one. Different events can get the same hash (for example same named repos
+// in different orgs), in that case the event is identified within the project: its ID is hashed with the project name
+func oldEventID(db *sql.DB, ctx *lib.Ctx, ev *lib.EventOld) string {
+	eid := fmt.Sprintf("%v", lib.HashStrings([]string{ev.Type, ev.Actor, ev.Repository.Name, lib.ToYMDHMSDate(ev.CreatedAt)}))
+	if ctx.Project == "" || !ctx.DBOut || ctx.Diff {
+		return eid
+	}
+	var repoID int
+	err := lib.QueryRowSQL(db, ctx, fmt.Sprintf("select repo_id from gha_events where id = %s", lib.NValue(1)), eid).Scan(&repoID)
+	if err == sql.ErrNoRows {
+		return eid
+	}
+	lib.FatalOnError(err)
+	if repoID == ev.Repository.ID {
+		return eid
+	}
+	eid = fmt.Sprintf("%v", lib.HashStrings([]string{ev.Type, ev.Actor, ev.Repository.Name, lib.ToYMDHMSDate(ev.CreatedAt), ctx.Project}))
+	if ctx.Debug > 0 {
+		lib.Printf("Event ID collision with repo ID %d, using project event ID %s\n", repoID, eid)
+	}
+	return eid
+}
+
+func ghaCommitsRoles(con *sql.Tx, ctx *lib.Ctx, msg, sha, eventID string, repoID int, repoName string, evCreatedAt time.Time, maybeHide func(string) string) {
+	// fmt.Printf("got here: sha=%s, created=%v\nmsg:\n%s\n", sha, evCreatedAt, msg)
+	for _, cr := range trailers.ParseTrailers(msg) {
+		name, email := cr.Name, cr.Email
+		id, login := lib.LookupActorNameEmailTx(con, ctx, name, email, maybeHide)
+		// fmt.Printf("got trailer(s): %+v -> ('%s', '%s', %d, '%s')\n", cr.Roles, name, email, id, login)
+		for _, role := range cr.Roles {
+			q, args := lib.NewQB("gha_commits_roles").
+				Set("sha", sha).
+				Set("event_id", eventID).
+				Set("role", role).
+				Set("actor_id", id).
+				Set("actor_login", maybeHide(lib.TruncField(ctx, "gha_commits_roles.actor_login", login))).
+				Set("actor_name", maybeHide(lib.TruncField(ctx, "gha_commits_roles.actor_name", name))).
+				Set("actor_email", maybeHide(lib.TruncField(ctx, "gha_commits_roles.actor_email", email))).
+				Set("dup_repo_id", repoID).
+				Set("dup_repo_name", repoName).
+				Set("dup_created_at", evCreatedAt).
+				InsertIgnore()
+			lib.ExecSQLTxWithErr(con, ctx, q, args...)
+		}
+	}
+	// fmt.Printf("out of here: sha=%s, created=%v\n", sha, evCreatedAt)
+}
+
+// ghaCommitsAuthors - writes commit author and co-authors from commit message trailers into gha_commits_authors
+func ghaCommitsAuthors(con *sql.Tx, ctx *lib.Ctx, name, email, msg, sha, eventID string, repoID int, repoName string, evCreatedAt time.Time, maybeHide func(string) string) {
+	for ord, author := range trailers.CommitAuthors(name, email, msg) {
+		id, login := lib.LookupActorNameEmailTx(con, ctx, author.Name, author.Email, maybeHide)
+		q, args := lib.NewQB("gha_commits_authors").
+			Set("sha", sha).
+			Set("event_id", eventID).
+			Set("ord", ord).
+			Set("source", author.Source).
+			Set("actor_id", id).
+			Set("actor_login", maybeHide(lib.TruncField(ctx, "gha_commits_authors.actor_login", login))).
+			Set("actor_name", maybeHide(lib.TruncField(ctx, "gha_commits_authors.actor_name", author.Name))).
+			Set("actor_email", maybeHide(lib.TruncField(ctx, "gha_commits_authors.actor_email", author.Email))).
+			Set("dup_repo_id", repoID).
+			Set("dup_repo_name", repoName).
+			Set("dup_created_at", evCreatedAt).
+			InsertIgnore()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+	}
+}
+
+// Process GHA pages
+// gha_pages
+// {"page_name:String"=>370, "title:String"=>370, "summary:NilClass"=>370,
+// "action:String"=>370, "sha:String"=>370, "html_url:String"=>370}
+// {"page_name"=>65, "title"=>65, "summary"=>0, "action"=>7, "sha"=>40, "html_url"=>130}
+// 370
+func ghaPages(con *sql.Tx, ctx *lib.Ctx, payloadPages *[]lib.Page, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, maybeHide func(string) string) {
+	pages := []lib.Page{}
+	if payloadPages != nil {
+		pages = *payloadPages
+	}
+	for _, page := range pages {
+		sha := page.SHA
+		q, args := lib.NewQB("gha_pages").
+			Set("sha", sha).
+			Set("event_id", eventID).
+			Set("action", page.Action).
+			Set("title", lib.TruncField(ctx, "gha_pages.title", page.Title)).
+			Set("dup_actor_id", actor.ID).
+			Set("dup_actor_login", maybeHide(actor.Login)).
+			Set("dup_repo_id", repo.ID).
+			Set("dup_repo_name", repo.Name).
+			Set("dup_type", eType).
+			Set("dup_created_at", eCreatedAt).
+			InsertIgnore()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+	}
+}
+
+// gha_comments
+// Table details and analysis in `analysis/analysis.txt` and `analysis/comment_*.json`
+func ghaComment(con *sql.Tx, ctx *lib.Ctx, payloadComment *lib.Comment, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, maybeHide func(string) string) {
+	if payloadComment == nil {
+		return
+	}
+	comment := *payloadComment
+
+	// user
+	ghaActor(con, ctx, &comment.User, maybeHide)
+
+	// comment
+	cid := comment.ID
+	q, args := lib.NewQB("gha_comments").
+		Set("id", cid).
+		Set("event_id", eventID).
+		Set("body", lib.TruncField(ctx, "gha_comments.body", comment.Body)).
+		Set("created_at", comment.CreatedAt).
+		Set("updated_at", comment.UpdatedAt).
+		Set("user_id", comment.User.ID).
+		Set("commit_id", lib.StringOrNil(comment.CommitID)).
+		Set("original_commit_id", lib.StringOrNil(comment.OriginalCommitID)).
+		Set("diff_hunk", lib.StringOrNil(comment.DiffHunk)).
+		Set("position", lib.IntOrNil(comment.Position)).
+		Set("original_position", lib.IntOrNil(comment.OriginalPosition)).
+		Set("path", lib.StringOrNil(comment.Path)).
+		Set("pull_request_review_id", lib.IntOrNil(comment.PullRequestReviewID)).
+		Set("line", lib.IntOrNil(comment.Line)).
+		Set("dup_actor_id", actor.ID).
+		Set("dup_actor_login", maybeHide(actor.Login)).
+		Set("dup_repo_id", repo.ID).
+		Set("dup_repo_name", repo.Name).
+		Set("dup_type", eType).
+		Set("dup_created_at", eCreatedAt).
+		Set("dup_user_login", maybeHide(comment.User.Login)).
+		InsertIgnore()
+	lib.ExecSQLTxWithErr(con, ctx, q, args...)
+}
+
+// gha_reviews
+// Table details and analysis in `analysis/analysis.txt` and `analysis/*review_*.json`
+func ghaReview(con *sql.Tx, ctx *lib.Ctx, payloadReview *lib.Review, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, maybeHide func(string) string) {
+	if payloadReview == nil {
+		return
+	}
+	review := *payloadReview
+
+	// user
+	ghaActor(con, ctx, &review.User, maybeHide)
+
+	// review
+	rid := review.ID
+	q, args := lib.NewQB("gha_reviews").
+		Set("id", rid).
+		Set("event_id", eventID).
+		Set("state", review.State).
+		Set("author_association", review.AuthorAssociation).
+		Set("submitted_at", review.SubmittedAt).
+		Set("user_id", review.User.ID).
+		Set("commit_id", review.CommitID).
+		Set("body", lib.TruncFieldOrNil(ctx, "gha_reviews.body", review.Body)).
+		Set("dup_actor_id", actor.ID).
+		Set("dup_actor_login", maybeHide(actor.Login)).
+		Set("dup_repo_id", repo.ID).
+		Set("dup_repo_name", repo.Name).
+		Set("dup_type", eType).
+		Set("dup_created_at", eCreatedAt).
+		Set("dup_user_login", maybeHide(review.User.Login)).
+		InsertIgnore()
+	lib.ExecSQLTxWithErr(con, ctx, q, args...)
+}
+
+// gha_releases
+// Table details and analysis in `analysis/analysis.txt` and `analysis/release_*.json`
+func ghaRelease(con *sql.Tx, ctx *lib.Ctx, payloadRelease *lib.Release, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, maybeHide func(string) string) {
+	if payloadRelease == nil {
+		return
+	}
+	release := *payloadRelease
+
+	// author
+	ghaActor(con, ctx, &release.Author, maybeHide)
+
+	// release
+	rid := release.ID
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_releases("+
+			"id, event_id, tag_name, target_commitish, name, draft, "+
+			"author_id, prerelease, created_at, published_at, body, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+			"dup_author_login) "+lib.NValues(18),
+		lib.AnyArray{
+			rid,
+			eventID,
+			lib.TruncField(ctx, "gha_releases.tag_name", release.TagName),
+			lib.TruncField(ctx, "gha_releases.target_commitish", release.TargetCommitish),
+			lib.TruncFieldOrNil(ctx, "gha_releases.name", release.Name),
+			release.Draft,
+			release.Author.ID,
+			release.Prerelease,
+			release.CreatedAt,
+			lib.TimeOrNil(release.PublishedAt),
+			lib.TruncFieldOrNil(ctx, "gha_releases.body", release.Body),
+			actor.ID,
+			maybeHide(actor.Login),
+			repo.ID,
+			repo.Name,
+			eType,
+			eCreatedAt,
+			maybeHide(release.Author.Login),
+		}...,
+	)
+
+	// Assets
+	for _, asset := range release.Assets {
+		// uploader
+		ghaActor(con, ctx, &asset.Uploader, maybeHide)
+
+		// asset
+		aid := asset.ID
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			"insert into gha_assets("+
+				"id, event_id, name, label, uploader_id, content_type, "+
+				"state, size, download_count, created_at, updated_at, "+
+				"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+				"dup_uploader_login) "+lib.NValues(18),
+			lib.AnyArray{
+				aid,
+				eventID,
+				lib.TruncField(ctx, "gha_assets.name", asset.Name),
+				lib.TruncFieldOrNil(ctx, "gha_assets.label", asset.Label),
+				asset.Uploader.ID,
+				asset.ContentType,
+				asset.State,
+				asset.Size,
+				asset.DownloadCount,
+				asset.CreatedAt,
+				asset.UpdatedAt,
+				actor.ID,
+				maybeHide(actor.Login),
+				repo.ID,
+				repo.Name,
+				eType,
+				eCreatedAt,
+				maybeHide(asset.Uploader.Login),
+			}...,
+		)
+
+		// release-asset connection
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			"insert into gha_releases_assets(release_id, event_id, asset_id) "+lib.NValues(3),
+			lib.AnyArray{rid, eventID, aid}...,
+		)
+	}
+}
+
+// gha_pull_requests
+// Table details and analysis in `analysis/analysis.txt` and `analysis/pull_request_*.json`
+func ghaPullRequest(con *sql.Tx, ctx *lib.Ctx, payloadPullRequest *lib.PullRequest, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, forkeeIDsToSkip []int, maybeHide func(string) string) {
+	if payloadPullRequest == nil {
+		return
+	}
+
+	// PR object
+	pr := *payloadPullRequest
+
+	// user
+	ghaActor(con, ctx, &pr.User, maybeHide)
+
+	baseSHA := pr.Base.SHA
+	headSHA := pr.Head.SHA
+	baseRepoID := lib.ForkeeIDOrNil(pr.Base.Repo)
+
+	// Create Event
+	ev := lib.Event{Actor: *actor, Repo: *repo, Type: eType, CreatedAt: eCreatedAt}
+
+	// base
+	ghaBranch(con, ctx, eventID, &pr.Base, &ev, forkeeIDsToSkip, maybeHide)
+
+	// head (if different, and skip its repo if defined and the same as base repo)
+	if baseSHA != headSHA {
+		if baseRepoID != nil {
+			forkeeIDsToSkip = append(forkeeIDsToSkip, baseRepoID.(int))
+		}
+		ghaBranch(con, ctx, eventID, &pr.Head, &ev, forkeeIDsToSkip, maybeHide)
+	}
+
+	// merged_by
+	if pr.MergedBy != nil {
+		ghaActor(con, ctx, pr.MergedBy, maybeHide)
+	}
+
+	// assignee
+	if pr.Assignee != nil {
+		ghaActor(con, ctx, pr.Assignee, maybeHide)
+	}
+
+	// milestone
+	if pr.Milestone != nil {
+		ghaMilestone(con, ctx, eventID, pr.Milestone, &ev, maybeHide)
+	}
+
+	// pull_request
+	prid := pr.ID
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_pull_requests("+
+			"id, event_id, user_id, base_sha, head_sha, merged_by_id, assignee_id, milestone_id, "+
+			"number, state, locked, title, body, created_at, updated_at, closed_at, merged_at, "+
+			"merge_commit_sha, merged, mergeable, rebaseable, mergeable_state, comments, "+
+			"review_comments, maintainer_can_modify, commits, additions, deletions, changed_files, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+			"dup_user_login, dupn_assignee_login, dupn_merged_by_login) "+lib.NValues(38),
+		lib.AnyArray{
+			prid,
+			eventID,
+			pr.User.ID,
+			baseSHA,
+			headSHA,
+			lib.ActorIDOrNil(pr.MergedBy),
+			lib.ActorIDOrNil(pr.Assignee),
+			lib.MilestoneIDOrNil(pr.Milestone),
+			pr.Number,
+			pr.State,
+			lib.BoolOrNil(pr.Locked),
+			lib.CleanUTF8(pr.Title),
+			lib.TruncFieldOrNil(ctx, "gha_pull_requests.body", pr.Body),
+			pr.CreatedAt,
+			pr.UpdatedAt,
+			lib.TimeOrNil(pr.ClosedAt),
+			lib.TimeOrNil(pr.MergedAt),
+			lib.StringOrNil(pr.MergeCommitSHA),
+			lib.BoolOrNil(pr.Merged),
+			lib.BoolOrNil(pr.Mergeable),
+			lib.BoolOrNil(pr.Rebaseable),
+			lib.StringOrNil(pr.MergeableState),
+			lib.IntOrNil(pr.Comments),
+			lib.IntOrNil(pr.ReviewComments),
+			lib.BoolOrNil(pr.MaintainerCanModify),
+			lib.IntOrNil(pr.Commits),
+			lib.IntOrNil(pr.Additions),
+			lib.IntOrNil(pr.Deletions),
+			lib.IntOrNil(pr.ChangedFiles),
+			actor.ID,
+			maybeHide(actor.Login),
+			repo.ID,
+			repo.Name,
+			eType,
+			eCreatedAt,
+			maybeHide(pr.User.Login),
+			lib.ActorLoginOrNil(pr.Assignee, maybeHide),
+			lib.ActorLoginOrNil(pr.MergedBy, maybeHide),
+		}...,
+	)
+
+	// Arrays: actors: assignees, requested_reviewers
+	// assignees
+	var assignees []lib.Actor
+
+	prAid := lib.ActorIDOrNil(pr.Assignee)
+	if pr.Assignee != nil {
+		assignees = append(assignees, *pr.Assignee)
+	}
+
+	if pr.Assignees != nil {
+		for _, assignee := range *pr.Assignees {
+			aid := assignee.ID
+			if aid == prAid {
+				continue
+			}
+			assignees = append(assignees, assignee)
+		}
+	}
+
+	for _, assignee := range assignees {
+		// assignee
+		ghaActor(con, ctx, &assignee, maybeHide)
+
+		// pull_request-assignee connection
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			"insert into gha_pull_requests_assignees(pull_request_id, event_id, assignee_id) "+lib.NValues(3),
+			lib.AnyArray{prid, eventID, assignee.ID}...,
+		)
+	}
+
+	// requested_reviewers
+	if pr.RequestedReviewers != nil {
+		for _, reviewer := range *pr.RequestedReviewers {
+			// reviewer
+			ghaActor(con, ctx, &reviewer, maybeHide)
+
+			// pull_request-requested_reviewer connection
+			lib.ExecSQLTxWithErr(
+				con,
+				ctx,
+				"insert into gha_pull_requests_requested_reviewers(pull_request_id, event_id, requested_reviewer_id) "+lib.NValues(3),
+				lib.AnyArray{prid, eventID, reviewer.ID}...,
+			)
+		}
+	}
+}
+
+// gha_mentions, gha_issue_refs
+// sourceType is 'body' (issue/PR description, sourceID is its number), 'comment' or 'review'
+func ghaMentions(con *sql.Tx, ctx *lib.Ctx, sourceType string, sourceID int, body *string, author *lib.Actor, eventID string, repo *lib.Repo, eCreatedAt time.Time, maybeHide func(string) string) {
+	if !ctx.Mentions || body == nil || *body == "" {
+		return
+	}
+	for _, login := range lib.ParseMentions(*body) {
+		if strings.EqualFold(login, author.Login) {
+			continue
+		}
+		q, args := lib.NewQB("gha_mentions").
+			Set("source_type", sourceType).
+			Set("source_id", sourceID).
+			Set("mentioned_login", maybeHide(login)).
+			Set("event_id", eventID).
+			Set("actor_id", author.ID).
+			Set("actor_login", maybeHide(author.Login)).
+			Set("repo_id", repo.ID).
+			Set("repo_name", repo.Name).
+			Set("created_at", eCreatedAt).
+			InsertIgnore()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+	}
+	for _, ref := range lib.ParseIssueRefs(*body, repo.Name) {
+		if sourceType == "body" && ref.Number == sourceID && strings.EqualFold(ref.Repo, repo.Name) {
+			continue
+		}
+		q, args := lib.NewQB("gha_issue_refs").
+			Set("source_type", sourceType).
+			Set("source_id", sourceID).
+			Set("ref_repo_name", lib.TruncField(ctx, "gha_issue_refs.ref_repo_name", ref.Repo)).
+			Set("ref_number", ref.Number).
+			Set("event_id", eventID).
+			Set("actor_id", author.ID).
+			Set("actor_login", maybeHide(author.Login)).
+			Set("repo_id", repo.ID).
+			Set("repo_name", repo.Name).
+			Set("created_at", eCreatedAt).
+			InsertIgnore()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+	}
+}
+
+// gha_teams
+func ghaTeam(con *sql.Tx, ctx *lib.Ctx, payloadTeam *lib.Team, payloadRepo *lib.Forkee, eventID string, actor *lib.Actor, repo *lib.Repo, eType string, eCreatedAt time.Time, maybeHide func(string) string) {
+	if payloadTeam == nil {
+		return
+	}
+	team := *payloadTeam
+
+	// team
+	tid := team.ID
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"insert into gha_teams("+
+			"id, event_id, name, slug, permission, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at"+
+			") "+lib.NValues(11),
+		lib.AnyArray{
+			tid,
+			eventID,
+			lib.TruncField(ctx, "gha_teams.name", team.Name),
+			lib.TruncField(ctx, "gha_teams.slug", team.Slug),
+			lib.TruncField(ctx, "gha_teams.permission", team.Permission),
+			actor.ID,
+			maybeHide(actor.Login),
+			repo.ID,
+			repo.Name,
+			eType,
+			eCreatedAt,
+		}...,
+	)
+
+	// team-repository connection
+	if payloadRepo != nil {
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			"insert into gha_teams_repositories(team_id, event_id, repository_id) "+lib.NValues(3),
+			lib.AnyArray{tid, eventID, payloadRepo.ID}...,
+		)
+	}
+}
+
+// Write GHA entire event (in old pre 2015 format) into Postgres DB
+func writeToDBOldFmt(db *sql.DB, ctx *lib.Ctx, eventID string, ev *lib.EventOld, shas map[string]string) int {
+	if eventExists(db, ctx, eventID) {
+		return 0
+	}
+
+	// To handle GDPR
+	maybeHide := lib.MaybeHideFunc(shas)
+
+	// Lookup author by GitHub login
+	aid := lookupActor(db, ctx, ev.Actor, maybeHide)
+	actor := lib.Actor{ID: aid, Login: ev.Actor}
+
+	// Repository
+	repository := ev.Repository
+
+	// Find Org ID from Repository.Organization
+	oid := findOrgIDOrNil(db, ctx, repository.Organization)
+
+	// Find Repo ID from Repository (this is a ForkeeOld before 2015).
+	rid, ok := findRepoFromNameAndOrg(db, ctx, repository.Name, oid)
+	if !ok {
+		rid = repository.ID
+	}
+
+	// Organization and repository are added before the event, gha_events.org_id references gha_orgs in integrity mode (GHA2DB_FK_CHECKS)
+	eventOID := oid
+	if repository.Organization != nil {
+		if oid == nil {
+			h := lib.HashStrings([]string{*repository.Organization})
+			oid = &h
+		}
+		ghaOrg(db, ctx, &lib.Org{ID: *oid, Login: *repository.Organization})
+	}
+
+	// Add Repository
+	repo := lib.Repo{ID: rid, Name: repository.Name}
+	ghaRepo(db, ctx, &repo, oid, repository.Organization)
+
+	// We defer transaction create until we're inserting data that can be shared between different events
+	lib.ExecSQLWithErr(
+		db,
+		ctx,
+		"insert into gha_events("+
+			"id, type, actor_id, repo_id, public, created_at, "+
+			"dup_actor_login, dup_repo_name, org_id, forkee_id, project) "+lib.NValues(11),
+		lib.AnyArray{
+			eventID,
+			ev.Type,
+			aid,
+			rid,
+			ev.Public,
+			ev.CreatedAt,
+			maybeHide(ev.Actor),
+			ev.Repository.Name,
+			eventOID,
+			ev.Repository.ID,
+			ctx.Project,
+		}...,
+	)
+
+	// Pre 2015 Payload
+	pl := ev.Payload
+	if pl == nil {
+		return 0
+	}
+
+	iid := lib.FirstIntOrNil([]*int{pl.Issue, pl.IssueID})
+	cid := lib.CommentIDOrNil(pl.Comment)
+	if cid == nil {
+		cid = lib.IntOrNil(pl.CommentID)
+	}
+
+	lib.ExecSQLWithErr(
+		db,
+		ctx,
+		"insert into gha_payloads("+
+			"event_id, push_id, size, ref, head, befor, action, "+
+			"issue_id, pull_request_id, comment_id, ref_type, master_branch, commit, "+
+			"description, number, forkee_id, release_id, member_id, "+
+			"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at"+
+			") "+lib.NValues(24),
+		lib.AnyArray{
+			eventID,
+			nil,
+			lib.IntOrNil(pl.Size),
+			lib.TruncFieldOrNil(ctx, "gha_payloads.ref", pl.Ref),
+			lib.StringOrNil(pl.Head),
+			nil,
+			lib.StringOrNil(pl.Action),
+			iid,
+			lib.PullRequestIDOrNil(pl.PullRequest),
+			cid,
+			lib.StringOrNil(pl.RefType),
+			lib.TruncFieldOrNil(ctx, "gha_payloads.master_branch", pl.MasterBranch),
+			lib.StringOrNil(pl.Commit),
+			lib.TruncFieldOrNil(ctx, "gha_payloads.description", pl.Description),
+			lib.IntOrNil(pl.Number),
+			lib.ForkeeIDOrNil(pl.Repository),
+			lib.ReleaseIDOrNil(pl.Release),
+			lib.ActorIDOrNil(pl.Member),
+			actor.ID,
+			maybeHide(actor.Login),
+			repo.ID,
+			repo.Name,
+			ev.Type,
+			ev.CreatedAt,
+		}...,
+	)
+
+	// Start transaction for data possibly shared between events
+	con, err := db.Begin()
+	lib.FatalOnError(err)
+
+	// gha_actors
+	ghaActor(con, ctx, &actor, maybeHide)
+
+	// Payload's Forkee (it uses new structure, so I'm giving it precedence over
+	// Event's Forkee (which uses older structure)
+	if pl.Repository != nil {
+		// Reposotory is actually a Forkee (non old in this case!)
+		// Artificial event is only used to allow duplicating EventOld's data
+		// (passed as Event to avoid code duplication)
+		artificialEv := lib.Event{Actor: actor, Repo: repo, Type: ev.Type, CreatedAt: ev.CreatedAt}
+		ghaForkee(con, ctx, eventID, pl.Repository, &artificialEv, maybeHide)
+	}
+
+	// Add Forkee in old mode if we didn't added it from payload or if it is a different Forkee
+	if pl.Repository == nil || pl.Repository.ID != ev.Repository.ID {
+		ghaForkeeOld(con, ctx, eventID, &ev.Repository, &actor, &repo, ev, maybeHide)
+	}
+
+	// SHAs - commits
+	if pl.SHAs != nil {
+		commits := *pl.SHAs
+		for _, comm := range commits {
+			commit, ok := comm.([]interface{})
+			if !ok {
+				lib.Fatalf("comm is not []interface{}: %+v", comm)
+			}
+			sha, ok := commit[0].(string)
+			if !ok {
+				lib.Fatalf("commit[0] is not string: %+v", commit[0])
+			}
+			lib.ExecSQLTxWithErr(
+				con,
+				ctx,
+				"insert into gha_commits("+
+					"sha, event_id, author_name, encrypted_email, message, is_distinct, "+
+					"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at"+
+					") "+lib.NValues(12),
+				lib.AnyArray{
+					sha,
+					eventID,
+					maybeHide(lib.TruncField(ctx, "gha_commits.author_name", commit[3].(string))),
+					lib.TruncField(ctx, "gha_commits.encrypted_email", commit[1].(string)),
+					lib.TruncField(ctx, "gha_commits.message", commit[2].(string)),
+					commit[4].(bool),
+					actor.ID,
+					maybeHide(actor.Login),
+					repo.ID,
+					repo.Name,
+					ev.Type,
+					ev.CreatedAt,
+				}...,
+			)
+			// Full message of a truncated commit message goes to gha_commits_messages
+			if ctx.CommitMsgStore && lib.CommitMessageTruncated(ctx, commit[2].(string)) {
+				lib.StoreCommitMessage(con, ctx, sha, commit[2].(string), repo.Name, ev.CreatedAt)
+			}
+			// Commit Roles
+			ghaCommitsRoles(con, ctx, commit[2].(string), sha, eventID, repo.ID, repo.Name, ev.CreatedAt, maybeHide)
+			// Commit authors
+			ghaCommitsAuthors(con, ctx, commit[3].(string), commit[1].(string), commit[2].(string), sha, eventID, repo.ID, repo.Name, ev.CreatedAt, maybeHide)
+		}
+	}
+
+	// Pages
+	ghaPages(con, ctx, pl.Pages, eventID, &actor, &repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Member
+	if pl.Member != nil {
+		ghaActor(con, ctx, pl.Member, maybeHide)
+	}
+
+	// Comment
+	ghaComment(con, ctx, pl.Comment, eventID, &actor, &repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Release & assets
+	ghaRelease(con, ctx, pl.Release, eventID, &actor, &repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Team & Repo connection
+	ghaTeam(con, ctx, pl.Team, pl.Repository, eventID, &actor, &repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Pull Request
+	forkeeIDsToSkip := []int{ev.Repository.ID}
+	if pl.Repository != nil {
+		forkeeIDsToSkip = append(forkeeIDsToSkip, pl.Repository.ID)
+	}
+	ghaPullRequest(con, ctx, pl.PullRequest, eventID, &actor, &repo, ev.Type, ev.CreatedAt, forkeeIDsToSkip, maybeHide)
+
+	// We need artificial issue
+	// gha_issues
+	// Table details and analysis in `analysis/analysis.txt` and `analysis/issue_*.json`
+	if pl.PullRequest != nil {
+		pr := *pl.PullRequest
+
+		// issue
+		iid = -pr.ID
+		isPR := true
+		comments := 0
+		locked := false
+		if pr.Comments != nil {
+			comments = *pr.Comments
+		}
+		if pr.Locked != nil {
+			locked = *pr.Locked
+		}
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			"insert into gha_issues("+
+				"id, event_id, assignee_id, body, closed_at, comments, created_at, "+
+				"locked, milestone_id, number, state, title, updated_at, user_id, "+
+				"dup_actor_id, dup_actor_login, dup_repo_id, dup_repo_name, dup_type, dup_created_at, "+
+				"dup_user_login, dupn_assignee_login, is_pull_request) "+lib.NValues(23),
+			lib.AnyArray{
+				iid,
+				eventID,
+				lib.ActorIDOrNil(pr.Assignee),
+				lib.TruncFieldOrNil(ctx, "gha_issues.body", pr.Body),
+				lib.TimeOrNil(pr.ClosedAt),
+				comments,
+				pr.CreatedAt,
+				locked,
+				lib.MilestoneIDOrNil(pr.Milestone),
+				pr.Number,
+				pr.State,
+				lib.CleanUTF8(pr.Title),
+				pr.UpdatedAt,
+				pr.User.ID,
+				actor.ID,
+				maybeHide(actor.Login),
+				repo.ID,
+				repo.Name,
+				ev.Type,
+				ev.CreatedAt,
+				maybeHide(pr.User.Login),
+				lib.ActorLoginOrNil(pr.Assignee, maybeHide),
+				isPR,
+			}...,
+		)
+
+		var assignees []lib.Actor
+
+		prAid := lib.ActorIDOrNil(pr.Assignee)
+		if pr.Assignee != nil {
+			assignees = append(assignees, *pr.Assignee)
+		}
+
+		if pr.Assignees != nil {
+			for _, assignee := range *pr.Assignees {
+				aid := assignee.ID
+				if aid == prAid {
+					continue
+				}
+				assignees = append(assignees, assignee)
+			}
+		}
+
+		for _, assignee := range assignees {
+			// pull_request-assignee connection
+			lib.ExecSQLTxWithErr(
+				con,
+				ctx,
+				"insert into gha_issues_assignees(issue_id, event_id, assignee_id) "+lib.NValues(3),
+				lib.AnyArray{iid, eventID, assignee.ID}...,
+			)
+		}
+	}
+
+	// Final commit
+	lib.FatalOnError(con.Commit())
+	return 1
+}
+
+// Write entire GHA event (in a new 2015+ format) into Postgres DB
+func writeToDB(db *sql.DB, ctx *lib.Ctx, ev *lib.Event, shas map[string]string) int {
+	eventID := ev.ID
+	if eventExists(db, ctx, eventID) {
+		return 0
+	}
+
+	// To handle GDPR
+	maybeHide := lib.MaybeHideFunc(shas)
+
+	// Repository
+	repo := ev.Repo
+	org := ev.Org
+	ghaRepo(db, ctx, &repo, lib.OrgIDOrNil(org), lib.OrgLoginOrNil(org))
+
+	// Organization, added before the event: gha_events.org_id references gha_orgs in integrity mode (GHA2DB_FK_CHECKS)
+	if org != nil {
+		ghaOrg(db, ctx, org)
+	}
+
+	// We defer transaction create until we're inserting data that can be shared between different events
+	// gha_events
+	// {"id:String"=>48592, "type:String"=>48592, "actor:Hash"=>48592, "repo:Hash"=>48592,
+	// "payload:Hash"=>48592, "public:TrueClass"=>48592, "created_at:String"=>48592,
+	// "org:Hash"=>19451}
+	// {"id"=>10, "type"=>29, "actor"=>278, "repo"=>290, "payload"=>216017, "public"=>4,
+	// "created_at"=>20, "org"=>230}
+	// Fields dup_actor_login, dup_repo_name are copied from (gha_actors and gha_repos) to save
+	// joins on complex queries (MySQL has no hash joins and is very slow on big tables joins)
+	q, args := lib.NewQB("gha_events").
+		Set("id", eventID).
+		Set("type", ev.Type).
+		Set("actor_id", ev.Actor.ID).
+		Set("repo_id", ev.Repo.ID).
+		Set("public", ev.Public).
+		Set("created_at", ev.CreatedAt).
+		Set("dup_actor_login", maybeHide(ev.Actor.Login)).
+		Set("dup_repo_name", ev.Repo.Name).
+		Set("org_id", lib.OrgIDOrNil(ev.Org)).
+		Set("forkee_id", nil).
+		Set("project", ctx.Project).
+		Insert()
+	lib.ExecSQLWithErr(db, ctx, q, args...)
+
+	// gha_payloads
+	// {"push_id:Fixnum"=>24636, "size:Fixnum"=>24636, "distinct_size:Fixnum"=>24636,
+	// "ref:String"=>30522, "head:String"=>24636, "before:String"=>24636, "commits:Array"=>24636,
+	// "action:String"=>14317, "issue:Hash"=>6446, "comment:Hash"=>6055, "ref_type:String"=>8010,
+	// "master_branch:String"=>6724, "description:String"=>3701, "pusher_type:String"=>8010,
+	// "pull_request:Hash"=>4475, "ref:NilClass"=>2124, "description:NilClass"=>3023,
+	// "number:Fixnum"=>2992, "forkee:Hash"=>1211, "pages:Array"=>370, "release:Hash"=>156,
+	// "member:Hash"=>219}
+	// {"push_id"=>10, "size"=>4, "distinct_size"=>4, "ref"=>110, "head"=>40, "before"=>40,
+	// "commits"=>33215, "action"=>9, "issue"=>87776, "comment"=>177917, "ref_type"=>10,
+	// "master_branch"=>34, "description"=>3222, "pusher_type"=>4, "pull_request"=>70565,
+	// "number"=>5, "forkee"=>6880, "pages"=>855, "release"=>31206, "member"=>1040}
+	// 48746
+	// using exec_stmt (without select), because payload are per event_id.
+	// Columns duplicated from gha_events starts with "dup_"
+	pl := ev.Payload
+	q, args = lib.NewQB("gha_payloads").
+		Set("event_id", eventID).
+		Set("push_id", lib.IntOrNil(pl.PushID)).
+		Set("size", lib.IntOrNil(pl.Size)).
+		Set("ref", lib.TruncFieldOrNil(ctx, "gha_payloads.ref", pl.Ref)).
+		Set("head", lib.StringOrNil(pl.Head)).
+		Set("befor", lib.StringOrNil(pl.Before)).
+		Set("action", lib.StringOrNil(pl.Action)).
+		Set("issue_id", lib.IssueIDOrNil(pl.Issue)).
+		Set("pull_request_id", lib.PullRequestIDOrNil(pl.PullRequest)).
+		Set("comment_id", lib.CommentIDOrNil(pl.Comment)).
+		Set("ref_type", lib.StringOrNil(pl.RefType)).
+		Set("master_branch", lib.TruncFieldOrNil(ctx, "gha_payloads.master_branch", pl.MasterBranch)).
+		Set("commit", nil).
+		Set("description", lib.TruncFieldOrNil(ctx, "gha_payloads.description", pl.Description)).
+		Set("number", lib.IntOrNil(pl.Number)).
+		Set("forkee_id", lib.ForkeeIDOrNil(pl.Forkee)).
+		Set("release_id", lib.ReleaseIDOrNil(pl.Release)).
+		Set("member_id", lib.ActorIDOrNil(pl.Member)).
+		Set("dup_actor_id", ev.Actor.ID).
+		Set("dup_actor_login", maybeHide(ev.Actor.Login)).
+		Set("dup_repo_id", ev.Repo.ID).
+		Set("dup_repo_name", ev.Repo.Name).
+		Set("dup_type", ev.Type).
+		Set("dup_created_at", ev.CreatedAt).
+		Insert()
+	lib.ExecSQLWithErr(db, ctx, q, args...)
+
+	// Start transaction for data possibly shared between events
+	con, err := db.Begin()
+	lib.FatalOnError(err)
+
+	// gha_actors
+	ghaActor(con, ctx, &ev.Actor, maybeHide)
+
+	// Make sure that entry is gha_actors is most up-to-date
+	/*
+		lib.ExecSQLWithErr(
+			db,
+			ctx,
+			fmt.Sprintf(
+				"update gha_actors set login=%s where id=%s"+
+					lib.NValue(1),
+				  lib.NValue(2),
+			),
+			lib.AnyArray{
+				maybeHide(ev.Actor.Login),
+				ev.Actor.ID,
+			}...,
+		)
+	*/
+
+	// gha_commits
+	// {"sha:String"=>23265, "author:Hash"=>23265, "message:String"=>23265,
+	// "distinct:TrueClass"=>21789, "url:String"=>23265, "distinct:FalseClass"=>1476}
+	// {"sha"=>40, "author"=>177, "message"=>19005, "distinct"=>5, "url"=>191}
+	// author: {"name:String"=>23265, "email:String"=>23265} (only git username/email)
+	// author: {"name"=>96, "email"=>95}
+	// 23265
+	commits := []lib.Commit{}
+	if pl.Commits != nil {
+		commits = *pl.Commits
+	}
+	for _, commit := range commits {
+		sha := commit.SHA
+		q, args := lib.NewQB("gha_commits").
+			Set("sha", sha).
+			Set("event_id", eventID).
+			Set("author_name", maybeHide(lib.TruncField(ctx, "gha_commits.author_name", commit.Author.Name))).
+			Set("encrypted_email", lib.TruncField(ctx, "gha_commits.encrypted_email", commit.Author.Email)).
+			Set("message", lib.TruncField(ctx, "gha_commits.message", commit.Message)).
+			Set("is_distinct", commit.Distinct).
+			Set("dup_actor_id", ev.Actor.ID).
+			Set("dup_actor_login", maybeHide(ev.Actor.Login)).
+			Set("dup_repo_id", ev.Repo.ID).
+			Set("dup_repo_name", ev.Repo.Name).
+			Set("dup_type", ev.Type).
+			Set("dup_created_at", ev.CreatedAt).
+			Insert()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+		// Full message of a truncated commit message goes to gha_commits_messages
+		if ctx.CommitMsgStore && lib.CommitMessageTruncated(ctx, commit.Message) {
+			lib.StoreCommitMessage(con, ctx, sha, commit.Message, ev.Repo.Name, ev.CreatedAt)
+		}
+		// Commit Roles
+		ghaCommitsRoles(con, ctx, commit.Message, sha, eventID, ev.Repo.ID, ev.Repo.Name, ev.CreatedAt, maybeHide)
+		// Commit authors
+		ghaCommitsAuthors(con, ctx, commit.Author.Name, commit.Author.Email, commit.Message, sha, eventID, ev.Repo.ID, ev.Repo.Name, ev.CreatedAt, maybeHide)
+	}
+
+	// Pages
+	ghaPages(con, ctx, pl.Pages, eventID, &ev.Actor, &ev.Repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Member
+	if pl.Member != nil {
+		ghaActor(con, ctx, pl.Member, maybeHide)
+	}
+
+	// Comment
+	ghaComment(con, ctx, pl.Comment, eventID, &ev.Actor, &ev.Repo, ev.Type, ev.CreatedAt, maybeHide)
+	if pl.Comment != nil {
+		ghaMentions(con, ctx, "comment", pl.Comment.ID, &pl.Comment.Body, &pl.Comment.User, eventID, &ev.Repo, ev.CreatedAt, maybeHide)
+	}
+
+	// gha_issues
+	// Table details and analysis in `analysis/analysis.txt` and `analysis/issue_*.json`
+	if pl.Issue != nil {
+		issue := *pl.Issue
+
+		// user, assignee
+		ghaActor(con, ctx, &issue.User, maybeHide)
+		if issue.Assignee != nil {
+			ghaActor(con, ctx, issue.Assignee, maybeHide)
+		}
+
+		// issue
+		iid := issue.ID
+		isPR := false
+		if issue.PullRequest != nil {
+			isPR = true
+		}
+		q, args := lib.NewQB("gha_issues").
+			Set("id", iid).
+			Set("event_id", eventID).
+			Set("assignee_id", lib.ActorIDOrNil(issue.Assignee)).
+			Set("body", lib.TruncFieldOrNil(ctx, "gha_issues.body", issue.Body)).
+			Set("closed_at", lib.TimeOrNil(issue.ClosedAt)).
+			Set("comments", issue.Comments).
+			Set("created_at", issue.CreatedAt).
+			Set("locked", issue.Locked).
+			Set("milestone_id", lib.MilestoneIDOrNil(issue.Milestone)).
+			Set("number", issue.Number).
+			Set("state", issue.State).
+			Set("title", lib.CleanUTF8(issue.Title)).
+			Set("updated_at", issue.UpdatedAt).
+			Set("user_id", issue.User.ID).
+			Set("dup_actor_id", ev.Actor.ID).
+			Set("dup_actor_login", maybeHide(ev.Actor.Login)).
+			Set("dup_repo_id", ev.Repo.ID).
+			Set("dup_repo_name", ev.Repo.Name).
+			Set("dup_type", ev.Type).
+			Set("dup_created_at", ev.CreatedAt).
+			Set("dup_user_login", maybeHide(issue.User.Login)).
+			Set("dupn_assignee_login", lib.ActorLoginOrNil(issue.Assignee, maybeHide)).
+			Set("is_pull_request", isPR).
+			Insert()
+		lib.ExecSQLTxWithErr(con, ctx, q, args...)
+
+		// mentions and references in issue/PR description
+		ghaMentions(con, ctx, "body", issue.Number, issue.Body, &issue.User, eventID, &ev.Repo, ev.CreatedAt, maybeHide)
+
+		// milestone
+		if issue.Milestone != nil {
+			ghaMilestone(con, ctx, eventID, issue.Milestone, ev, maybeHide)
+		}
+
+		pAid := lib.ActorIDOrNil(issue.Assignee)
+		for _, assignee := range issue.Assignees {
+			aid := assignee.ID
+			if aid == pAid {
+				continue
+			}
+
+			// assignee
+			ghaActor(con, ctx, &assignee, maybeHide)
+
+			// issue-assignee connection
+			q, args := lib.NewQB("gha_issues_assignees").Set("issue_id", iid).Set("event_id", eventID).Set("assignee_id", aid).Insert()
+			lib.ExecSQLTxWithErr(con, ctx, q, args...)
+		}
+
+		// labels
+		for _, label := range issue.Labels {
+			lid := lib.IntOrNil(label.ID)
+			if lid == nil {
+				lid = lookupLabel(con, ctx, lib.TruncField(ctx, "gha_labels.name", label.Name), label.Color)
+			}
+
+			// label
+			q, args := lib.NewQB("gha_labels").
+				Set("id", lid).
+				Set("name", lib.TruncField(ctx, "gha_labels.name", label.Name)).
+				Set("color", label.Color).
+				Set("is_default", lib.BoolOrNil(label.Default)).
+				InsertIgnore()
+			lib.ExecSQLTxWithErr(con, ctx, q, args...)
+
+			// issue-label connection
+			q, args = lib.NewQB("gha_issues_labels").
+				Set("issue_id", iid).
+				Set("event_id", eventID).
+				Set("label_id", lid).
+				Set("dup_actor_id", ev.Actor.ID).
+				Set("dup_actor_login", maybeHide(ev.Actor.Login)).
+				Set("dup_repo_id", ev.Repo.ID).
+				Set("dup_repo_name", ev.Repo.Name).
+				Set("dup_type", ev.Type).
+				Set("dup_created_at", ev.CreatedAt).
+				Set("dup_issue_number", issue.Number).
+				Set("dup_label_name", label.Name).
+				InsertIgnore()
+			lib.ExecSQLTxWithErr(con, ctx, q, args...)
+		}
+	}
+
+	// gha_forkees
+	if pl.Forkee != nil {
+		ghaForkee(con, ctx, eventID, pl.Forkee, ev, maybeHide)
+	}
+
+	// Release & assets
+	ghaRelease(con, ctx, pl.Release, eventID, &ev.Actor, &ev.Repo, ev.Type, ev.CreatedAt, maybeHide)
+
+	// Pull Request
+	ghaPullRequest(con, ctx, pl.PullRequest, eventID, &ev.Actor, &ev.Repo, ev.Type, ev.CreatedAt, []int{}, maybeHide)
+	if pl.PullRequest != nil {
+		ghaMentions(con, ctx, "body", pl.PullRequest.Number, pl.PullRequest.Body, &pl.PullRequest.User, eventID, &ev.Repo, ev.CreatedAt, maybeHide)
+	}
+
+	// Review
+	ghaReview(con, ctx, pl.Review, eventID, &ev.Actor, &ev.Repo, ev.Type, ev.CreatedAt, maybeHide)
+	if pl.Review != nil {
+		ghaMentions(con, ctx, "review", pl.Review.ID, pl.Review.Body, &pl.Review.User, eventID, &ev.Repo, ev.CreatedAt, maybeHide)
+	}
+
+	// Final commit
+	lib.FatalOnError(con.Commit())
+	return 1
+}
+
+// diffTable - table compared in diff mode (GHA2DB_DIFF)
+// key is an SQL expression that must return the same strings as computed by diffEvent
+type diffTable struct {
+	name     string
+	key      string
+	eventCol string
+}
+
+// diffTables - tables compared in diff mode
+var diffTables = []diffTable{
+	{name: "gha_events", key: "id::text", eventCol: "id"},
+	{name: "gha_payloads", key: "event_id::text", eventCol: "event_id"},
+	{name: "gha_commits", key: "sha", eventCol: "event_id"},
+	{name: "gha_commits_roles", key: "sha || ' ' || role || ' ' || coalesce(actor_name, '') || ' <' || coalesce(actor_email, '') || '>'", eventCol: "event_id"},
+	{name: "gha_pages", key: "sha", eventCol: "event_id"},
+	{name: "gha_comments", key: "id::text", eventCol: "event_id"},
+	{name: "gha_issues", key: "id::text", eventCol: "event_id"},
+	{name: "gha_issues_assignees", key: "assignee_id::text", eventCol: "event_id"},
+	{name: "gha_issues_labels", key: "dup_label_name", eventCol: "event_id"},
+	{name: "gha_pull_requests", key: "id::text", eventCol: "event_id"},
+	{name: "gha_pull_requests_assignees", key: "assignee_id::text", eventCol: "event_id"},
+	{name: "gha_pull_requests_requested_reviewers", key: "requested_reviewer_id::text", eventCol: "event_id"},
+	{name: "gha_milestones", key: "id::text", eventCol: "event_id"},
+	{name: "gha_reviews", key: "id::text", eventCol: "event_id"},
+	{name: "gha_releases", key: "id::text", eventCol: "event_id"},
+	{name: "gha_releases_assets", key: "asset_id::text", eventCol: "event_id"},
+}
+
+// diffNotCompared - tables written by writeToDB that diff mode doesn't compare (shared dimension rows
+// not keyed by event, forkees/branches/assets/teams details and optional mentions), listed in the report
+var diffNotCompared = []string{
+	"gha_actors", "gha_orgs", "gha_repos", "gha_labels", "gha_forkees", "gha_branches",
+	"gha_assets", "gha_teams", "gha_teams_repositories", "gha_mentions", "gha_issue_refs",
+}
+
+// diffEvent - compare rows that writeToDB would write for a given event with rows already in the DB
+// It doesn't write anything, it only updates diff mode stats
+func diffEvent(db *sql.DB, ctx *lib.Ctx, ev *lib.Event, shas map[string]string) {
+	eventID := ev.ID
+	maybeHide := lib.MaybeHideFunc(shas)
+	pl := ev.Payload
+	expected := make(map[string]map[string]struct{})
+	for _, table := range diffTables {
+		expected[table.name] = make(map[string]struct{})
+	}
+	add := func(table, key string) {
+		expected[table][key] = struct{}{}
+	}
+	add("gha_events", eventID)
+	add("gha_payloads", eventID)
+	if pl.Commits != nil {
+		for _, commit := range *pl.Commits {
+			add("gha_commits", commit.SHA)
+			for _, cr := range trailers.ParseTrailers(commit.Message) {
+				for _, role := range cr.Roles {
+					add(
+						"gha_commits_roles",
+						fmt.Sprintf(
+							"%s %s %s <%s>",
+							commit.SHA,
+							role,
+							maybeHide(lib.TruncField(ctx, "gha_commits_roles.actor_name", cr.Name)),
+							maybeHide(lib.TruncField(ctx, "gha_commits_roles.actor_email", cr.Email)),
+						),
+					)
+				}
+			}
+		}
+	}
+	if pl.Pages != nil {
+		for _, page := range *pl.Pages {
+			add("gha_pages", page.SHA)
+		}
+	}
+	if pl.Comment != nil {
+		add("gha_comments", strconv.Itoa(pl.Comment.ID))
+	}
+	if pl.Issue != nil {
+		issue := pl.Issue
+		add("gha_issues", strconv.Itoa(issue.ID))
+		pAid := lib.ActorIDOrNil(issue.Assignee)
+		for _, assignee := range issue.Assignees {
+			if assignee.ID == pAid {
+				continue
+			}
+			add("gha_issues_assignees", strconv.Itoa(assignee.ID))
+		}
+		for _, label := range issue.Labels {
+			add("gha_issues_labels", label.Name)
+		}
+		if issue.Milestone != nil {
+			add("gha_milestones", strconv.Itoa(issue.Milestone.ID))
+		}
+	}
+	if pl.PullRequest != nil {
+		pr := pl.PullRequest
+		add("gha_pull_requests", strconv.Itoa(pr.ID))
+		prAid := lib.ActorIDOrNil(pr.Assignee)
+		if pr.Assignee != nil {
+			add("gha_pull_requests_assignees", strconv.Itoa(pr.Assignee.ID))
+		}
+		if pr.Assignees != nil {
+			for _, assignee := range *pr.Assignees {
+				if assignee.ID == prAid {
+					continue
+				}
+				add("gha_pull_requests_assignees", strconv.Itoa(assignee.ID))
+			}
+		}
+		if pr.RequestedReviewers != nil {
+			for _, reviewer := range *pr.RequestedReviewers {
+				add("gha_pull_requests_requested_reviewers", strconv.Itoa(reviewer.ID))
+			}
+		}
+		if pr.Milestone != nil {
+			add("gha_milestones", strconv.Itoa(pr.Milestone.ID))
+		}
+	}
+	if pl.Review != nil {
+		add("gha_reviews", strconv.Itoa(pl.Review.ID))
+	}
+	if pl.Release != nil {
+		add("gha_releases", strconv.Itoa(pl.Release.ID))
+		for _, asset := range pl.Release.Assets {
+			add("gha_releases_assets", strconv.Itoa(asset.ID))
+		}
+	}
+
+	// Get current DB state
+	for _, table := range diffTables {
+		got := make(map[string]struct{})
+		rows := lib.QuerySQLWithErr(
+			db,
+			ctx,
+			"select "+table.key+" from "+table.name+" where "+table.eventCol+" = "+lib.NValue(1),
+			eventID,
+		)
+		var key *string
+		for rows.Next() {
+			lib.FatalOnError(rows.Scan(&key))
+			if key != nil {
+				got[*key] = struct{}{}
+			}
+		}
+		lib.FatalOnError(rows.Err())
+		lib.FatalOnError(rows.Close())
+		exp := expected[table.name]
+		missing, extra := []string{}, []string{}
+		for k := range exp {
+			if _, ok := got[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		for k := range got {
+			if _, ok := exp[k]; !ok {
+				extra = append(extra, k)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		gDiffMtx.Lock()
+		stats := gDiffStats[table.name]
+		stats[0] += len(exp)
+		stats[1] += len(got)
+		stats[2] += len(missing)
+		stats[3] += len(extra)
+		gDiffStats[table.name] = stats
+		for _, k := range missing {
+			if len(gDiffSamples[table.name]) >= gDiffMaxSamples {
+				break
+			}
+			gDiffSamples[table.name] = append(gDiffSamples[table.name], fmt.Sprintf("event %s: missing in DB: %s", eventID, k))
+		}
+		for _, k := range extra {
+			if len(gDiffSamples[table.name]) >= gDiffMaxSamples {
+				break
+			}
+			gDiffSamples[table.name] = append(gDiffSamples[table.name], fmt.Sprintf("event %s: extra in DB: %s", eventID, k))
+		}
+		gDiffMtx.Unlock()
+	}
+}
+
+// diffReport - output diff mode (GHA2DB_DIFF) report
+func diffReport() {
+	gDiffMtx.Lock()
+	defer gDiffMtx.Unlock()
+	report := "Diff report (expected rows, rows in DB, missing in DB, extra in DB):\n"
+	diffs := 0
+	for _, table := range diffTables {
+		stats := gDiffStats[table.name]
+		report += fmt.Sprintf("%-38s %8d %8d %8d %8d\n", table.name, stats[0], stats[1], stats[2], stats[3])
+		diffs += stats[2] + stats[3]
+	}
+	for _, table := range diffTables {
+		samples := gDiffSamples[table.name]
+		if len(samples) == 0 {
+			continue
+		}
+		report += fmt.Sprintf("%s sampled mismatches:\n", table.name)
+		for _, sample := range samples {
+			report += "  " + sample + "\n"
+		}
+	}
+	report += fmt.Sprintf("Not compared: %s\n", strings.Join(diffNotCompared, ", "))
+	if diffs == 0 {
+		report += fmt.Sprintf("No differences found in %d compared tables\n", len(diffTables))
+	} else {
+		report += fmt.Sprintf("Found %d differences in %d compared tables\n", diffs, len(diffTables))
+	}
+	lib.Printf("%s", report)
+}
+
+// parseJSON - parse signle GHA JSON event
+// When sharding is enabled, event is written to the shard database selected by its org (shardCons), otherwise to con
+// Returns event type, 1 when event matches filters, 1 when event was written,
+// skewed when event created_at is outside of a given GHA hour and dup when event ID was already seen in this or adjacent hours (it is skipped then)
+// excluded is set to the reason when event matches filters but its org or actor is excluded (GHA2DB_EXCLUSIONS_YAML)
+func parseJSON(con *sql.DB, shardCons map[string]*sql.DB, ctx *lib.Ctx, idx, njsons int, jsonStr []byte, dt time.Time, forg, frepo map[string]struct{}, orgRE, repoRE *regexp.Regexp, shas map[string]string, ids *lib.RollingIDs, exp *hourExport, recent map[*sql.DB]map[lib.RecentRepo]time.Time, provisional map[*sql.DB]bool) (typ string, f int, e int, skewed, dup bool, excluded string) {
+	var (
+		h         lib.Event
+		hOld      lib.EventOld
+		err       error
+		fullName  string
+		eid       string
+		actorName string
+	)
+	if ctx.OldFormat {
+		err = jsoniter.Unmarshal(jsonStr, &hOld)
+	} else {
+		err = jsoniter.Unmarshal(jsonStr, &h)
+	}
+	// jsonStr = bytes.Replace(jsonStr, []byte("\x00"), []byte(""), -1)
+	if err != nil {
+		lib.Printf("Error(%v): %v\n", lib.ToGHADate(dt), err)
+		ofn := fmt.Sprintf("jsons/error_%v-%d-%d.json", lib.ToGHADate(dt), idx+1, njsons)
+		lib.FatalOnError(ioutil.WriteFile(ofn, jsonStr, 0644))
+		lib.Printf("%v: Cannot unmarshal:\n%s\n%v\n", dt, string(jsonStr), err)
+		fmt.Fprintf(os.Stderr, "%v: Cannot unmarshal:\n%s\n%v\n", dt, string(jsonStr), err)
+		if ctx.AllowBrokenJSON {
+			return
+		}
+		pretty := lib.PrettyPrintJSON(jsonStr)
+		lib.Printf("%v: JSON Unmarshal failed for:\n'%v'\n", dt, string(pretty))
+		fmt.Fprintf(os.Stderr, "%v: JSON Unmarshal failed for:\n'%v'\n", dt, string(pretty))
+	}
+	lib.FatalOnError(err)
+	if ctx.OldFormat {
+		fullName = lib.MakeOldRepoName(&hOld.Repository)
+		actorName = hOld.Actor
+		typ = hOld.Type
+	} else {
+		fullName = h.Repo.Name
+		actorName = h.Actor.Login
+		typ = h.Type
+	}
+	// GHA hour files can contain events created outside of that hour
+	var createdAt *time.Time
+	if ctx.OldFormat {
+		createdAt = &hOld.CreatedAt
+	} else {
+		createdAt = &h.CreatedAt
+	}
+	hourEnd := dt.Add(time.Hour)
+	if createdAt.Before(dt) || !createdAt.Before(hourEnd) {
+		skewed = true
+		if ctx.Debug > 0 {
+			lib.Printf("%v: event created at %v is outside of its GHA hour\n", dt, *createdAt)
+		}
+		if ctx.SkewFix {
+			if createdAt.Before(dt) {
+				*createdAt = dt
+			} else {
+				*createdAt = hourEnd.Add(-time.Second)
+			}
+		}
+	}
+	// The same event can be repeated in adjacent GHA hours, pre-2015 events have no IDs
+	if ids != nil && !ctx.OldFormat && h.ID != "" {
+		dup = ids.Seen(dt, h.ID)
+		if dup && ctx.Debug > 0 {
+			lib.Printf("%v: event %s was already seen in this or adjacent GHA hour\n", dt, h.ID)
+		}
+	}
+	if lib.RepoHit(ctx, fullName, forg, frepo, orgRE, repoRE) && lib.ActorHit(ctx, actorName) {
+		if len(shardCons) > 0 {
+			con = shardCons[lib.ShardForRepo(ctx, fullName)]
+		}
+		if ctx.OldFormat {
+			eid = oldEventID(con, ctx, &hOld)
+		} else {
+			eid = h.ID
+		}
+		// Duplicated event was already exported, written and used to confirm provisional events
+		// Bloom filter can return false positives, so with database output event is only skipped when it is in the database,
+		// without database output (export only) false positives are skipped too
+		if dup && (!ctx.DBOut || eventExists(con, ctx, eid)) {
+			if ctx.Debug > 0 {
+				lib.Printf("%v: skipping duplicated event %s\n", dt, eid)
+			}
+			f = 1
+			return
+		}
+		if ctx.JSONOut {
+			// We want to Unmarshal/Marshall ALL JSON data, regardless of what is defined in lib.Event
+			pretty := lib.PrettyPrintJSON(jsonStr)
+			ofn := fmt.Sprintf("jsons/%v_%v.json", dt.Unix(), eid)
+			lib.FatalOnError(ioutil.WriteFile(ofn, pretty, 0644))
+		}
+		if exp != nil {
+			ev, err := lib.NewExportEvent(jsonStr, h.CreatedAt, exp.maybeHide)
+			lib.FatalOnError(err)
+			lib.FatalOnError(exp.exporter.Write(ev))
+		}
+		if ctx.Diff {
+			diffEvent(con, ctx, &h, shas)
+		} else if ctx.DBOut {
+			if ctx.OldFormat {
+				e = writeToDBOldFmt(con, ctx, eid, &hOld, shas)
+			} else {
+				// GHA event replaces provisional (webhook) events of the same activity
+				if provisional[con] {
+					lib.ConfirmProvisionalEvents(con, ctx, &h)
+				}
+				e = writeToDB(con, ctx, &h, shas)
+				// Last event date of this hour per repo, saved in the same database as the event
+				repos, ok := recent[con]
+				if !ok {
+					repos = make(map[lib.RecentRepo]time.Time)
+					recent[con] = repos
+				}
+				repo := lib.RecentRepo{ID: int64(h.Repo.ID), Name: h.Repo.Name}
+				if h.CreatedAt.After(repos[repo]) {
+					repos[repo] = h.CreatedAt
+				}
+			}
+		}
+		if ctx.Debug >= 1 {
+			lib.Printf("Processed: '%v' event: %v\n", dt, eid)
+		}
+		f = 1
+	} else {
+		excluded = lib.ExclusionReason(ctx, fullName, actorName, forg, frepo, orgRE, repoRE)
+		if excluded != "" && ctx.Debug > 0 {
+			lib.Printf("%v: event of %s by %s excluded (%s)\n", dt, fullName, actorName, excluded)
+		}
+	}
+	return
+}
+
+// hourExport - exporter of a single GHA hour events matching project filters (GHA2DB_EXPORT_FORMAT)
+type hourExport struct {
+	exporter  *lib.EventExporter
+	maybeHide func(string) string
+}
+
+// parsedStats - single GHA hour counters of a given event type: all events, events matching project filters and events written
+// skewed - events created outside of their GHA hour, duplicates - events already seen in this or adjacent GHA hours
+// excluded - events matching project filters but skipped because of their org or actor (GHA2DB_EXCLUSIONS_YAML)
+type parsedStats struct {
+	events     int
+	matched    int
+	written    int
+	skewed     int
+	duplicates int
+	excluded   int
+}
+
+// markAsProcessed - upserts a given hour status, saves per event type counters of this hour (if any) and their totals
+// Hour is marked as partial before parsing it, so hours that were not finished can be detected and re-run
+func markAsProcessed(con *sql.DB, ctx *lib.Ctx, dt time.Time, status string, stats map[string]*parsedStats) {
+	if !ctx.DBOut || ctx.Diff {
+		return
+	}
+	qb := lib.NewQB("gha_parsed").Set("dt", dt).Set("status", status).Set("last_run_at", time.Now())
+	if stats != nil {
+		found, written := 0, 0
+		for _, st := range stats {
+			found += st.events
+			written += st.written
+		}
+		qb.Set("events_found", found).Set("events_written", written)
+	}
+	q, args := qb.Upsert("dt")
+	lib.ExecSQLWithErr(con, ctx, q, args...)
+	for typ, st := range stats {
+		q, args := lib.NewQB("gha_parsed_stats").
+			Set("dt", dt).
+			Set("type", typ).
+			Set("events", st.events).
+			Set("matched", st.matched).
+			Set("written", st.written).
+			Set("skewed", st.skewed).
+			Set("duplicates", st.duplicates).
+			Set("excluded", st.excluded).
+			Upsert("dt", "type")
+		lib.ExecSQLWithErr(con, ctx, q, args...)
+	}
+}
+
+// forEachShard - calls f for every shard database or just for the main database when sharding is not used
+func forEachShard(ctx *lib.Ctx, f func(*lib.Ctx)) {
+	if len(ctx.Shards) == 0 {
+		f(ctx)
+		return
+	}
+	for _, shard := range ctx.Shards {
+		shardCtx := ctx.CopyContext()
+		shardCtx.PgDB = shard
+		lib.Printf("Shard %s\n", shard)
+		f(shardCtx)
+	}
+}
+
+// refreshCommitRoles - process/create gha_commits_roles for all commits in DB
+// Work is done by gha_backfill_commits_roles tool, which persists its progress and can be resumed after a crash
+func refreshCommitRoles(ctx *lib.Ctx) {
+	cmdPrefix := ""
+	if ctx.Local {
+		cmdPrefix = "./"
+	}
+	_, err := lib.ExecCommand(ctx, []string{cmdPrefix + "gha_backfill_commits_roles"}, map[string]string{"PG_DB": ctx.PgDB})
+	lib.FatalOnError(err)
+}
+
+// updateCommitRoles - try to find missing actor IDs/Logins in gha_commits_roles table
+func updateCommitRoles(ctx *lib.Ctx) {
+	// GDPR data hiding
+	shaMap := lib.GetHidden(ctx, lib.HideCfgFile)
+	maybeHide := lib.MaybeHideFuncTS(shaMap)
+	// Connect to Postgres DB
+	con := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select distinct actor_email, actor_name from gha_commits_roles where actor_id = 0 or actor_login = '' or actor_id is null or actor_login is null",
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	emails, names, email, name := []string{}, []string{}, "", ""
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&email, &name))
+		emails = append(emails, email)
+		names = append(names, name)
+	}
+	lib.FatalOnError(rows.Err())
+	// Get number of CPUs available
+	pool := lib.NewPool(ctx, 0).RefreshEvery(20)
+	nRoles := len(emails)
+	lib.Printf("Processing %d commit roles using %d CPUs\n", nRoles, pool.Size())
+	updated := 0
+	mtx := &sync.Mutex{}
+	for i, name := range names {
+		name, email := name, emails[i]
+		pool.Submit(func() {
+			// fmt.Printf("Processing (%s,%s)\n", email, name)
+			id, login := lib.LookupActorNameEmail(con, ctx, name, email, maybeHide)
+			if id == 0 {
+				return
+			}
+			// fmt.Printf("Got (%d,%s) for (%s,%s)\n", id, login, email, name)
+			lib.ExecSQLWithErr(
+				con,
+				ctx,
+				fmt.Sprintf(
+					"update gha_commits_roles set actor_id=%s, actor_login=%s where actor_name=%s and actor_email=%s",
+					lib.NValue(1),
+					lib.NValue(2),
+					lib.NValue(3),
+					lib.NValue(4),
+				),
+				lib.AnyArray{
+					id,
+					maybeHide(login),
+					maybeHide(name),
+					maybeHide(email),
+				}...,
+			)
+			mtx.Lock()
+			updated++
+			mtx.Unlock()
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	hits, missHits, misses := lib.ActorsCacheStats()
+	lib.Printf("Updated %d/%d roles using %d CPUs, actor lookups: %d cached, %d cached misses, %d queried\n", updated, nRoles, pool.Size(), hits, missHits, misses)
+}
+
+// readLocalGHAJSON - read given GHA hour from a local GHA2DB_LOCAL_JSONS_DIR directory
+// It tries YYYY-MM-DD-H.json.gz, YYYY-MM-DD-H.json.zst and YYYY-MM-DD-H.json files
+// zstd-compressed files are decompressed using `zstd` command (gha2db checks that it is available on start)
+func readLocalGHAJSON(ctx *lib.Ctx, dt time.Time) (fn string, jsonsBytes []byte, ok bool) {
+	root := filepath.Join(ctx.LocalJSONsDir, lib.ToGHADate(dt))
+	for _, ext := range []string{".json.gz", ".json.zst", ".json"} {
+		fn = root + ext
+		_, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+		switch ext {
+		case ".json.gz":
+			var file *os.File
+			file, err = os.Open(fn)
+			if err != nil {
+				break
+			}
+			var reader *gzip.Reader
+			reader, err = gzip.NewReader(file)
+			if err != nil {
+				_ = file.Close()
+				break
+			}
+			jsonsBytes, err = ioutil.ReadAll(reader)
+			_ = reader.Close()
+			_ = file.Close()
+		case ".json.zst":
+			jsonsBytes, err = exec.Command("zstd", "-dcq", "--", fn).Output()
+		default:
+			jsonsBytes, err = ioutil.ReadFile(fn)
+		}
+		if err != nil {
+			lib.Printf("%v: Error reading %s:\n%v\n", dt, fn, err)
+			fmt.Fprintf(os.Stderr, "%v: Error reading %s:\n%v\n", dt, fn, err)
+			return
+		}
+		lib.Printf("Read and decompressed %s\n", fn)
+		ok = true
+		return
+	}
+	lib.Printf("%v: No local data in %s.json[.gz|.zst]\n", dt, root)
+	fmt.Fprintf(os.Stderr, "%v: No local data in %s.json[.gz|.zst]\n", dt, root)
+	return
+}
+
+// getGHAJSON - This is a work for single go routine - 1 hour of GHA data
+// Usually such JSON conatin about 15000 - 60000 singe GHA events
+func getGHAJSON(ctx *lib.Ctx, dt time.Time, forg, frepo map[string]struct{}, orgRE, repoRE *regexp.Regexp, shas map[string]string, skipDates map[string]struct{}, ids *lib.RollingIDs, force bool, summary *lib.RunSummary) {
+	lib.Printf("Working on %v\n", dt)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+
+	// Connect to shard databases (if any), gha_parsed stays in the main database
+	var shardCons map[string]*sql.DB
+	if len(ctx.Shards) > 0 {
+		shardCons = lib.ShardsConns(ctx)
+		defer lib.CloseShardsConns(shardCons)
+	}
+
+	// Check skip GHA date config
+	_, ok := skipDates[lib.ToYMDHDate(dt)]
+	if ok {
+		lib.Printf("Skipped %v\n", dt)
+		summary.SkipHour()
+		markAsProcessed(con, ctx, dt, lib.ParsedSkipped, nil)
+		return
+	}
+
+	// Hours that were already fully parsed are only parsed again when forced, partial ones are always re-run
+	if ctx.DBOut && !ctx.Diff {
+		if !force && lib.ParsedStatus(con, ctx, dt) == lib.ParsedOK {
+			lib.Printf("Already parsed %v, use --force to parse it again\n", dt)
+			summary.SkipHour()
+			return
+		}
+		markAsProcessed(con, ctx, dt, lib.ParsedPartial, nil)
+	}
+
+	var (
+		fn         string
+		jsonsBytes []byte
+	)
+	if ctx.LocalJSONsDir != "" {
+		// Read JSONs from a local directory, no HTTP
+		fn, jsonsBytes, ok = readLocalGHAJSON(ctx, dt)
+		if !ok {
+			lib.Printf("Gave up on %+v\n", dt)
+			summary.AddError(fmt.Sprintf("%s: no local data", lib.ToYMDHDate(dt)))
+			return
+		}
+	} else {
+		fn = fmt.Sprintf("http://data.gharchive.org/%s.json.gz", lib.ToGHADate(dt))
+
+		// Get gzipped JSON array via HTTP
+		trials := 0
+		for {
+			trials++
+			if trials > 1 {
+				lib.Printf("Retry(%d) %+v\n", trials, dt)
+			}
+			httpClient := lib.HTTPClient(ctx)
+			httpClient.Timeout = time.Minute * time.Duration(trials*ctx.HTTPTimeout)
+			response, err := httpClient.Get(fn)
+			if err != nil {
+				lib.Printf("%v: Error http.Get:\n%v\n", dt, err)
+				if trials < ctx.HTTPRetry {
+					time.Sleep(time.Duration((1+rand.Intn(20))*trials) * time.Second)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%v: Error http.Get:\n%v\n", dt, err)
+			}
+			lib.FatalOnError(err)
+
+			// Decompress Gzipped response
+			reader, err := gzip.NewReader(response.Body)
+			//lib.FatalOnError(err)
+			if err != nil {
+				_ = response.Body.Close()
+				lib.Printf("%v: No data yet, gzip reader:\n%v\n", dt, err)
+				if trials < ctx.HTTPRetry {
+					time.Sleep(time.Duration((1+rand.Intn(3))*trials) * time.Second)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%v: No data yet, gzip reader:\n%v\n", dt, err)
+				lib.Printf("Gave up on %+v\n", dt)
+				summary.AddError(fmt.Sprintf("%s: no data yet, gzip reader: %v", lib.ToYMDHDate(dt), err))
+				return
+			}
+			lib.Printf("Opened %s\n", fn)
+
+			jsonsBytes, err = ioutil.ReadAll(reader)
+			_ = reader.Close()
+			_ = response.Body.Close()
+			//lib.FatalOnError(err)
+			if err != nil {
+				lib.Printf("%v: Error (no data yet, ioutil readall):\n%v\n", dt, err)
+				if trials < ctx.HTTPRetry {
+					time.Sleep(time.Duration((1+rand.Intn(20))*trials) * time.Second)
+					continue
+				}
+				fmt.Fprintf(os.Stderr, "%v: Error (no data yet, ioutil readall):\n%v\n", dt, err)
+				lib.Printf("Gave up on %+v\n", dt)
+				summary.AddError(fmt.Sprintf("%s: no data yet, read: %v", lib.ToYMDHDate(dt), err))
+				return
+			}
+			if trials > 1 {
+				lib.Printf("Recovered(%d) & decompressed %s\n", trials, fn)
+			} else {
+				lib.Printf("Decompressed %s\n", fn)
+			}
+			break
+		}
+	}
+
+	// Split JSON array into separate JSONs
+	jsonsArray := bytes.Split(jsonsBytes, []byte("\n"))
+	lib.Printf("Split %s, %d JSONs\n", fn, len(jsonsArray))
+
+	// Export file of this hour, it only gets its final name when the whole hour was parsed
+	var exp *hourExport
+	exporter, err := lib.NewEventExporter(ctx, dt)
+	lib.FatalOnError(err)
+	if exporter != nil {
+		exp = &hourExport{exporter: exporter, maybeHide: lib.MaybeHideFunc(shas)}
+	}
+
+	// Process JSONs one by one
+	n, f, e, sk, d := 0, 0, 0, 0, 0
+	njsons := len(jsonsArray)
+	stats := make(map[string]*parsedStats)
+	excludedBy := make(map[string]int)
+	recent := make(map[*sql.DB]map[lib.RecentRepo]time.Time)
+	// Databases with provisional events (received via webhooks by "gha2db receive") to be confirmed by this hour
+	provisional := make(map[*sql.DB]bool)
+	if ctx.DBOut && !ctx.Diff && !ctx.OldFormat {
+		provisional[con] = lib.HasProvisionalEvents(con, ctx)
+		for _, shardCon := range shardCons {
+			provisional[shardCon] = lib.HasProvisionalEvents(shardCon, ctx)
+		}
+	}
+	for i, json := range jsonsArray {
+		if len(json) < 1 {
+			continue
+		}
+		typ, fi, ei, skewed, dup, excluded := parseJSON(con, shardCons, ctx, i, njsons, json, dt, forg, frepo, orgRE, repoRE, shas, ids, exp, recent, provisional)
+		n++
+		f += fi
+		e += ei
+		// Broken JSONs (GHA2DB_ALLOW_BROKEN_JSON) have no type
+		if typ == "" {
+			typ = "unknown"
+		}
+		st, ok := stats[typ]
+		if !ok {
+			st = &parsedStats{}
+			stats[typ] = st
+		}
+		st.events++
+		st.matched += fi
+		st.written += ei
+		if skewed {
+			st.skewed++
+			sk++
+		}
+		if dup {
+			st.duplicates++
+			d++
+		}
+		if excluded != "" {
+			st.excluded++
+			excludedBy[excluded]++
+		}
+	}
+	lib.Printf(
+		"Parsed: %s: %d JSONs, found %d matching, events %d, outside of hour %d, duplicates %d\n",
+		fn, n, f, e, sk, d,
+	)
+	if len(excludedBy) > 0 {
+		lib.Printf("Excluded: %s: by org %d, by actor %d\n", fn, excludedBy[lib.ExcludedOrg], excludedBy[lib.ExcludedActor])
+	}
+	summary.AddHour(n, f, e, sk, d)
+	if exp != nil {
+		lib.FatalOnError(exp.exporter.Close())
+		lib.Printf("Exported %d events to %s\n", exp.exporter.Written, exp.exporter.Filename())
+	}
+	// Save originals of fields truncated while parsing this hour (if requested)
+	lib.FlushTruncations(con, ctx)
+	// Recently active repos are used by ghapi2db instead of scanning gha_events
+	for rcon, repos := range recent {
+		lib.UpdateRecentRepos(rcon, ctx, repos)
+	}
+	// Provisional events from before the previous hour that this or earlier hours did not confirm are removed, GHA is the source of truth
+	for pcon, ok := range provisional {
+		if !ok {
+			continue
+		}
+		if n := lib.ExpireProvisionalEvents(pcon, ctx, dt.Add(-time.Hour)); n > 0 {
+			lib.Printf("%v: removed %d provisional events not confirmed by GHA\n", dt, n)
+		}
+	}
+	// Mark date as computed, to skip fetching this JSON again when it contains no events for a current project
+	markAsProcessed(con, ctx, dt, lib.ParsedOK, stats)
+}
+
+// gha2db - main work horse
+// projectFilters - returns org and repo filters of GHA2DB_PROJECT from projects.yaml command_line (the same filters gha2db_sync uses)
+// Returns nil when project is not set or it is not defined in projects.yaml
+func projectFilters(ctx *lib.Ctx) []string {
+	if ctx.Project == "" {
+		return nil
+	}
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	data, err := lib.ReadFile(ctx, dataPrefix+ctx.ProjectsYaml)
+	if err != nil {
+		lib.Printf("Cannot read '%s' to get project '%s' filters: %v\n", ctx.ProjectsYaml, ctx.Project, err)
+		return nil
+	}
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+	proj, ok := projects.Projects[ctx.Project]
+	if !ok {
+		lib.Printf("Project '%s' is not defined in '%s', not using its filters\n", ctx.Project, ctx.ProjectsYaml)
+		return nil
+	}
+	if len(proj.CommandLine) > 2 {
+		lib.Fatalf("project '%s' command_line in '%s' should be ['org1,org2,...,orgN' ['repo1,repo2,...,repoN']], got %v", ctx.Project, ctx.ProjectsYaml, proj.CommandLine)
+	}
+	return proj.CommandLine
+}
+
+// parseFilters - parses optional org and repo filters: 'org1,org2,...,orgN' or 'regexp:...' and 'repo1,repo2,...,repoN' or 'regexp:...'
+func parseFilters(args []string) (org, repo map[string]struct{}, orgRE, repoRE *regexp.Regexp) {
+	var err error
+
+	// Strip function to be used by MapString
+	stripFunc := func(x string) string { return strings.TrimSpace(x) }
+
+	// Stripping whitespace from org and repo params
+	if len(args) >= 1 {
+		if strings.HasPrefix(args[0], "regexp:") {
+			orgRE, err = regexp.Compile(args[0][7:])
+			lib.FatalOnError(err)
+		} else {
+			org = lib.StringsMapToSet(
+				stripFunc,
+				strings.Split(args[0], ","),
+			)
+		}
+	}
+	if len(args) >= 2 {
+		if strings.HasPrefix(args[1], "regexp:") {
+			repoRE, err = regexp.Compile(args[1][7:])
+			lib.FatalOnError(err)
+		} else {
+			repo = lib.StringsMapToSet(
+				stripFunc,
+				strings.Split(args[1], ","),
+			)
+		}
+	}
+	return
+}
+
+func gha2db(args []string, force bool) {
+	// Environment context parse
+	var (
+		ctx      lib.Ctx
+		err      error
+		hourFrom int
+		hourTo   int
+		dFrom    time.Time
+		dTo      time.Time
+	)
+	// Current date
+	now := time.Now()
+	// Init stuff
+	debug.SetGCPercent(25)
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+	rand.Seed(time.Now().UnixNano())
+	ctx.Exclusions = lib.LoadExclusions(&ctx)
+
+	// Run summary (GHA2DB_SUMMARY_FILE) is written last, also when run fails
+	summary := lib.NewRunSummary("gha2db", args)
+	defer func() {
+		r := recover()
+		if r != nil {
+			summary.AddError(fmt.Sprintf("%v", r))
+		}
+		summary.Write(&ctx, r == nil)
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	// Diff mode only compares what would be written with the DB state, it doesn't write anything
+	if ctx.Diff {
+		if ctx.OldFormat {
+			lib.Fatalf("diff mode is not supported for pre-2015 GHA data")
+		}
+		defer diffReport()
+	} else if ctx.RefreshCommitRoles {
+		defer func() { forEachShard(&ctx, refreshCommitRoles) }()
+	} else {
+		defer func() { forEachShard(&ctx, updateCommitRoles) }()
+	}
+
+	// Export of parsed events in external schema, only new GHA format events have all exported fields
+	if ctx.ExportFormat != "" {
+		if ctx.OldFormat {
+			lib.Fatalf("events export is not supported for pre-2015 GHA data")
+		}
+		if _, ok := lib.ExportFormats[ctx.ExportFormat]; !ok {
+			lib.Fatalf("unknown GHA2DB_EXPORT_FORMAT '%s'", ctx.ExportFormat)
+		}
+	}
+
+	// Local zstd-compressed GHA hours need `zstd` command, check it once instead of failing on each hour
+	if ctx.LocalJSONsDir != "" {
+		zsts, err := filepath.Glob(filepath.Join(ctx.LocalJSONsDir, "*.json.zst"))
+		lib.FatalOnError(err)
+		if len(zsts) > 0 {
+			_, err = exec.LookPath("zstd")
+			if err != nil {
+				lib.FatalfWithCode(lib.ExitConfig, "%s contains .json.zst files, but zstd command is not available: %v", ctx.LocalJSONsDir, err)
+			}
+		}
+	}
+
+	startD, startH, endD, endH := args[0], args[1], args[2], args[3]
+
+	// No org/repo filters given, use current project's filters from projects.yaml
+	if len(args) == 4 {
+		if filters := projectFilters(&ctx); len(filters) > 0 {
+			lib.Printf("Using project '%s' filters from '%s': %v\n", ctx.Project, ctx.ProjectsYaml, filters)
+			args = append(args, filters...)
+		}
+	}
+
+	// Parse from day & hour
+	if strings.ToLower(startH) == lib.Now {
+		hourFrom = now.Hour()
+	} else {
+		hourFrom, err = strconv.Atoi(startH)
+		lib.FatalOnError(err)
+	}
+
+	if strings.ToLower(startD) == lib.Today {
+		dFrom = lib.DayStart(now).Add(time.Duration(hourFrom) * time.Hour)
+	} else {
+		dFrom, err = time.Parse(
+			time.RFC3339,
+			fmt.Sprintf("%sT%02d:00:00+00:00", startD, hourFrom),
+		)
+		lib.FatalOnError(err)
+	}
+
+	// Parse to day & hour
+	var currNow time.Time
+	dateToFunc := func() {
+		currNow = time.Now()
+		if strings.ToLower(endH) == lib.Now {
+			hourTo = currNow.Hour()
+		} else {
+			hourTo, err = strconv.Atoi(endH)
+			lib.FatalOnError(err)
+		}
+
+		if strings.ToLower(endD) == lib.Today {
+			dTo = lib.DayStart(currNow).Add(time.Duration(hourTo) * time.Hour)
+		} else {
+			dTo, err = time.Parse(
+				time.RFC3339,
+				fmt.Sprintf("%sT%02d:00:00+00:00", endD, hourTo),
+			)
+			lib.FatalOnError(err)
+		}
+	}
+	dateToFunc()
+
+	// Org and repo filters
+	org, repo, orgRE, repoRE := parseFilters(args[4:])
+
+	// Get number of CPUs available
+	thrN := lib.GetThreadsNum(&ctx)
+	lib.Printf(
+		"gha2db.go: Running (%v CPUs): %v - %v %v %v\n",
+		thrN, dFrom, dTo,
+		strings.Join(lib.StringsSetKeys(org), "+"),
+		strings.Join(lib.StringsSetKeys(repo), "+"),
+	)
+
+	// GDPR data hiding
+	shaMap := lib.GetHidden(&ctx, lib.HideCfgFile)
+
+	// Skipping JSON dates
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+
+	// Read GHA dates to skip
+	data, err := lib.ReadFile(&ctx, dataPrefix+ctx.SkipDatesYaml)
+	if err != nil {
+		lib.FatalOnError(err)
+		return
+	}
+
+	// Read lista nd convert it to set
+	var skipDatesList lib.SkipDatesList
+	lib.FatalOnError(yaml.Unmarshal(data, &skipDatesList))
+	skipDates := make(map[string]struct{})
+	for _, date := range skipDatesList.Dates {
+		skipDates[lib.ToYMDHDate(date)] = struct{}{}
+	}
+
+	// Tables and columns missing in databases created by older versions (per hour event type counters etc.)
+	if ctx.DBOut && !ctx.Diff {
+		con := lib.PgConn(&ctx)
+		lib.Migrate(con, &ctx)
+		// Monthly partitions of imported range (only when event tables are partitioned)
+		lib.EnsurePartitions(con, &ctx, dFrom, dTo)
+		// Fail before writing anything if database is older/newer than this binary
+		lib.CheckSchema(con, &ctx, ctx.PgDB)
+		lib.FatalOnError(con.Close())
+		if len(ctx.Shards) > 0 {
+			shardCons := lib.ShardsConns(&ctx)
+			for shard, shardCon := range shardCons {
+				lib.Migrate(shardCon, &ctx)
+				lib.EnsurePartitions(shardCon, &ctx, dFrom, dTo)
+				lib.CheckSchema(shardCon, &ctx, shard)
+			}
+			lib.CloseShardsConns(shardCons)
+		}
+	}
+
+	// Actors are replicated to the main database in sharding mode
+	if ctx.DBOut && !ctx.Diff && len(ctx.Shards) > 0 {
+		gActorsCon = lib.PgConn(&ctx)
+		defer func() { lib.FatalOnError(gActorsCon.Close()) }()
+	}
+
+	// Event IDs seen in recent GHA hours, used to detect events repeated in adjacent hours
+	var ids *lib.RollingIDs
+	if ctx.DedupWindow > 0 {
+		ids = lib.NewRollingIDs(ctx.DedupWindow)
+	}
+	// Hours before the earliest hour that can still be processed are no longer needed
+	pruneIDs := func(earliest time.Time) {
+		if ids != nil {
+			ids.Prune(earliest.Add(-time.Duration(ctx.DedupWindow) * time.Hour))
+		}
+	}
+
+	// Number of concurrent hours is adjusted to heap usage when GHA2DB_MEM_BUDGET_MB is set
+	mb := lib.NewMemBudget(&ctx, thrN)
+
+	// Hours processing progress, date to can move when processing up to now
+	prc := 0
+	nHours := func() int { return int(dTo.Sub(dFrom)/time.Hour) + 1 }
+	pr := lib.NewProgress(&ctx, "gha2db", nHours(), time.Duration(10)*time.Second)
+	reportHour := func(dt time.Time) {
+		pr.SetTotal(nHours())
+		pr.Report(prc, fmt.Sprintf("%s, threads: %d", lib.ToYMDHDate(dt), mb.Threads()))
+	}
+
+	dt := dFrom
+	if thrN > 1 {
+		// Hours in progress and progress/memory budget updates are shared by all workers
+		mtx := &sync.Mutex{}
+		mp := make(map[time.Time]struct{})
+		pool := lib.NewPool(&ctx, 0)
+		pool.Resize(mb.Threads())
+		for {
+			mtx.Lock()
+			dateToFunc()
+			next := !dt.After(dTo)
+			if next {
+				mp[dt] = struct{}{}
+			}
+			mtx.Unlock()
+			if !next {
+				break
+			}
+			hour := dt
+			submitted := pool.Submit(func() {
+				getGHAJSON(&ctx, hour, org, repo, orgRE, repoRE, shaMap, skipDates, ids, force, summary)
+				mtx.Lock()
+				defer mtx.Unlock()
+				delete(mp, hour)
+				// Hours not submitted yet are all after this one
+				earliest := hour.Add(time.Hour)
+				for h := range mp {
+					if h.Before(earliest) {
+						earliest = h
+					}
+				}
+				pruneIDs(earliest)
+				dateToFunc()
+				mb.Update()
+				summary.SampleMemory()
+				prc++
+				reportHour(hour)
+				if prc%10 == 0 {
+					mb.SetMaxThreads(lib.GetThreadsNum(&ctx))
+				}
+				pool.Resize(mb.Threads())
+			})
+			if !submitted {
+				// One of hours failed, later hours are not parsed, so the failed hour is the first one to process on the next run
+				mtx.Lock()
+				delete(mp, hour)
+				mtx.Unlock()
+				lib.Printf("Not processing %s and later hours, one of previous hours failed\n", lib.ToYMDHDate(hour))
+				break
+			}
+			dt = dt.Add(time.Hour)
+		}
+		mtx.Lock()
+		lib.Printf("Final threads join (processed %d)\n", prc)
+		if ctx.Debug >= 0 {
+			dta := []string{}
+			for k := range mp {
+				dta = append(dta, lib.ToYMDHDate(k))
+			}
+			lib.Printf("%d remain: %v\n", len(mp), strings.Join(dta, ", "))
+		}
+		mtx.Unlock()
+		lib.FatalOnError(pool.Wait())
+	} else {
+		lib.Printf("Using single threaded version\n")
+		for dt.Before(dTo) || dt.Equal(dTo) {
+			dateToFunc()
+			getGHAJSON(&ctx, dt, org, repo, orgRE, repoRE, shaMap, skipDates, ids, force, summary)
+			pruneIDs(dt.Add(time.Hour))
+			prc++
+			reportHour(dt)
+			dt = dt.Add(time.Hour)
+			mb.Update()
+			summary.SampleMemory()
+		}
+	}
+	pr.Final(prc, "hours processed")
+	// Finished
+	lib.ReportTruncations()
+	lib.ReportHTTPStats()
+	lib.Printf("All done: %v\n", currNow.Sub(now))
+}
+
+// receive - GitHub org webhooks receiver ("gha2db receive"), events matching org/repo filters are written immediately and tagged as provisional
+// Provisional events are replaced by GHA events of the same activity when their GHA hour is parsed, unconfirmed ones are removed later
+func receive(args []string) {
+	var ctx lib.Ctx
+	ctx.Init()
+	ctx.Exclusions = lib.LoadExclusions(&ctx)
+	if ctx.ReceiverSecret == "" {
+		lib.Fatalf("GHA2DB_RECEIVER_SECRET must be set in receive mode")
+	}
+	if ctx.OldFormat || ctx.Diff || !ctx.DBOut {
+		lib.Fatalf("receive mode writes new format events to the database, it cannot be used with GHA2DB_OLDFMT, GHA2DB_DIFF or GHA2DB_NODB")
+	}
+
+	// No org/repo filters given, use current project's filters from projects.yaml
+	if len(args) == 0 {
+		if filters := projectFilters(&ctx); len(filters) > 0 {
+			lib.Printf("Using project '%s' filters from '%s': %v\n", ctx.Project, ctx.ProjectsYaml, filters)
+			args = filters
+		}
+	}
+	org, repo, orgRE, repoRE := parseFilters(args)
+
+	// GDPR data hiding
+	shaMap := lib.GetHidden(&ctx, lib.HideCfgFile)
+
+	// Connections are kept open for the receiver lifetime
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	var shardCons map[string]*sql.DB
+	if len(ctx.Shards) > 0 {
+		shardCons = lib.ShardsConns(&ctx)
+		defer lib.CloseShardsConns(shardCons)
+		gActorsCon = con
+	}
+	now := time.Now()
+	ensure := func(c *sql.DB, db string) {
+		lib.Migrate(c, &ctx)
+		lib.EnsurePartitions(c, &ctx, now, now.AddDate(0, 1, 0))
+		lib.CheckSchema(c, &ctx, db)
+	}
+	ensure(con, ctx.PgDB)
+	for shard, shardCon := range shardCons {
+		ensure(shardCon, shard)
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			lib.WebhookRespond(w, http.StatusMethodNotAllowed, "only POST is allowed")
+			return
+		}
+		// Payload is read before its signature can be verified, so its size must be limited
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, lib.WebhookMaxPayload))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				lib.WebhookRespond(w, http.StatusRequestEntityTooLarge, "payload too large")
+				return
+			}
+			lib.WebhookRespond(w, http.StatusBadRequest, "cannot read payload")
+			return
+		}
+		err = lib.VerifyWebhookSignature(ctx.ReceiverSecret, body, r.Header.Get("X-Hub-Signature-256"))
+		if err != nil {
+			lib.Printf("Receiver: %v\n", err)
+			lib.WebhookRespond(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		name, delivery := r.Header.Get("X-GitHub-Event"), r.Header.Get("X-GitHub-Delivery")
+		ev, err := lib.NewWebhookEvent(name, delivery, body, time.Now())
+		if err != nil {
+			lib.Printf("Receiver: %s %s: %v\n", name, delivery, err)
+			lib.WebhookRespond(w, http.StatusBadRequest, "cannot parse payload")
+			return
+		}
+		// Includes "ping" sent when webhook is created
+		if ev == nil {
+			lib.WebhookRespond(w, http.StatusOK, "ignored "+name)
+			return
+		}
+		if !lib.RepoHit(&ctx, ev.Repo.Name, org, repo, orgRE, repoRE) || !lib.ActorHit(&ctx, ev.Actor.Login) {
+			lib.WebhookRespond(w, http.StatusOK, "skipped "+ev.Repo.Name)
+			return
+		}
+		c := con
+		if len(shardCons) > 0 {
+			c = shardCons[lib.ShardForRepo(&ctx, ev.Repo.Name)]
+		}
+		// Tagged first, so an event is never left untagged (tag of an event that was not written is removed like any other unconfirmed event)
+		lib.AddProvisionalEvent(c, &ctx, ev, delivery)
+		if writeToDB(c, &ctx, ev, shaMap) > 0 {
+			lib.UpdateRecentRepos(c, &ctx, map[lib.RecentRepo]time.Time{{ID: int64(ev.Repo.ID), Name: ev.Repo.Name}: ev.CreatedAt})
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("Receiver: %s %s: provisional %s event %s\n", name, delivery, ev.Type, ev.ID)
+		}
+		lib.WebhookRespond(w, http.StatusOK, "received "+ev.ID)
+	}
+	http.HandleFunc(ctx.ReceiverRoot, handler)
+	lib.Printf(
+		"gha2db.go: Receiving GitHub webhooks on %s%s%s: %v %v\n",
+		ctx.WebHookHost, ctx.ReceiverPort, ctx.ReceiverRoot,
+		strings.Join(lib.StringsSetKeys(org), "+"),
+		strings.Join(lib.StringsSetKeys(repo), "+"),
+	)
+	lib.FatalOnError(http.ListenAndServe(ctx.WebHookHost+ctx.ReceiverPort, nil))
+}
+
+// Main - runs gha2db tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	// GitHub webhooks receiver mode
+	if len(os.Args) > 1 && os.Args[1] == "receive" {
+		receive(os.Args[2:])
+		return
+	}
+	// Already parsed hours are skipped unless --force is given
+	args := os.Args[1:]
+	force := false
+	if len(args) > 0 && args[0] == "--force" {
+		force = true
+		args = args[1:]
+	}
+	// Required args
+	if len(args) < 4 {
+		lib.Printf(
+			"Arguments required: [--force] date_from_YYYY-MM-DD hour_from_HH date_to_YYYY-MM-DD hour_to_HH " +
+				"['org1,org2,...,orgN' ['repo1,repo2,...,repoN']]\n" +
+				"Hours already parsed are skipped, --force parses them again (partially parsed hours are always parsed again)\n" +
+				"Or: receive ['org1,org2,...,orgN' ['repo1,repo2,...,repoN']] to write GitHub webhooks as provisional events\n" +
+				"When no org/repo filters are given, they are read from GHA2DB_PROJECT's command_line in projects.yaml (if defined there)\n",
+		)
+		os.Exit(lib.ExitConfig)
+	}
+	gha2db(args, force)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,275 @@
+package ghabackfillcommitsroles
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/cncf/devstatscode/lib/trailers"
+)
+
+const (
+	// rolesProgressName - gha_backfill_progress key used by commit roles backfill
+	rolesProgressName = "commits_roles"
+	// authorsProgressName - gha_backfill_progress key used by commit authors backfill
+	authorsProgressName = "commits_authors"
+	// batchSize - number of commits fetched and processed at once, bounds memory usage
+	batchSize = 1000
+	// maxCachedActors - actor lookups cache is dropped when it grows above this size
+	maxCachedActors = 200000
+)
+
+// progressName - gha_backfill_progress key of the current backfill
+var progressName = rolesProgressName
+
+// commitData - single commit to process
+type commitData struct {
+	sha         string
+	eventID     int64
+	repoID      int
+	repoName    string
+	evCreatedAt time.Time
+	msg         string
+	authorName  string
+	authorEmail string
+}
+
+// progress - persisted backfill state, commits are processed in (sha, event_id) order
+// and watermark is the last (sha, event_id) of a fully processed batch
+type progress struct {
+	sha       string
+	eventID   int64
+	processed int64
+	roles     int64
+}
+
+// loadProgress - returns persisted progress or zero progress (start from the beginning)
+func loadProgress(con *sql.DB, ctx *lib.Ctx) (p progress) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select sha, event_id, processed, roles from gha_backfill_progress where name = $1",
+		progressName,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&p.sha, &p.eventID, &p.processed, &p.roles))
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// saveProgress - persists watermark after a fully processed batch
+func saveProgress(con *sql.DB, ctx *lib.Ctx, p progress) {
+	q, args := lib.NewQB("gha_backfill_progress").
+		Set("name", progressName).
+		Set("sha", p.sha).
+		Set("event_id", p.eventID).
+		Set("processed", p.processed).
+		Set("roles", p.roles).
+		Set("updated_at", time.Now()).
+		Upsert("name")
+	lib.ExecSQLWithErr(con, ctx, q, args...)
+}
+
+// clearProgress - removes persisted progress, so the next run starts from the beginning
+func clearProgress(con *sql.DB, ctx *lib.Ctx) {
+	lib.ExecSQLWithErr(con, ctx, "delete from gha_backfill_progress where name = $1", progressName)
+}
+
+// countRemaining - returns number of commits without roles (or authors) after a given watermark
+func countRemaining(con *sql.DB, ctx *lib.Ctx, p progress, table string) (n int) {
+	lib.FatalOnError(
+		lib.QueryRowSQL(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"select count(*) from (select distinct c.sha, c.event_id from gha_commits c where (c.sha, c.event_id) > ($1, $2) "+
+					"and not exists (select 1 from %s r where r.sha = c.sha and r.event_id = c.event_id)) sub",
+				table,
+			),
+			p.sha,
+			p.eventID,
+		).Scan(&n),
+	)
+	return
+}
+
+// getBatch - returns next batch of commits without roles (or authors) after a given watermark
+func getBatch(con *sql.DB, ctx *lib.Ctx, p progress, table string) (commits []commitData) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select distinct c.sha, c.event_id, c.dup_repo_id, c.dup_repo_name, c.dup_created_at, c.message, c.author_name, c.encrypted_email "+
+				"from gha_commits c where (c.sha, c.event_id) > ($1, $2) "+
+				"and not exists (select 1 from %s r where r.sha = c.sha and r.event_id = c.event_id) "+
+				"order by c.sha, c.event_id limit %d",
+			table,
+			batchSize,
+		),
+		p.sha,
+		p.eventID,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		var c commitData
+		lib.FatalOnError(rows.Scan(&c.sha, &c.eventID, &c.repoID, &c.repoName, &c.evCreatedAt, &c.msg, &c.authorName, &c.authorEmail))
+		commits = append(commits, c)
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// processCommit - parses commit message trailers and inserts found roles immediately, returns number of roles found
+func processCommit(con *sql.DB, ctx *lib.Ctx, c *commitData, maybeHide func(string) string) (n int) {
+	for _, cr := range trailers.ParseTrailers(c.msg) {
+		id, login := lib.LookupActorNameEmail(con, ctx, cr.Name, cr.Email, maybeHide)
+		for _, role := range cr.Roles {
+			q, args := lib.NewQB("gha_commits_roles").
+				Set("sha", c.sha).
+				Set("event_id", c.eventID).
+				Set("role", role).
+				Set("actor_id", id).
+				Set("actor_login", maybeHide(lib.TruncToBytes(login, 120))).
+				Set("actor_name", maybeHide(lib.TruncToBytes(cr.Name, 160))).
+				Set("actor_email", maybeHide(lib.TruncToBytes(cr.Email, 160))).
+				Set("dup_repo_id", c.repoID).
+				Set("dup_repo_name", c.repoName).
+				Set("dup_created_at", c.evCreatedAt).
+				InsertIgnore()
+			lib.ExecSQLWithErr(con, ctx, q, args...)
+			n++
+		}
+	}
+	return
+}
+
+// processCommitAuthors - inserts commit author and co-authors from commit message trailers, returns number of authors
+func processCommitAuthors(con *sql.DB, ctx *lib.Ctx, c *commitData, maybeHide func(string) string) (n int) {
+	for ord, author := range trailers.CommitAuthors(c.authorName, c.authorEmail, c.msg) {
+		id, login := lib.LookupActorNameEmail(con, ctx, author.Name, author.Email, maybeHide)
+		q, args := lib.NewQB("gha_commits_authors").
+			Set("sha", c.sha).
+			Set("event_id", c.eventID).
+			Set("ord", ord).
+			Set("source", author.Source).
+			Set("actor_id", id).
+			Set("actor_login", maybeHide(lib.TruncToBytes(login, 120))).
+			Set("actor_name", maybeHide(lib.TruncToBytes(author.Name, 160))).
+			Set("actor_email", maybeHide(lib.TruncToBytes(author.Email, 160))).
+			Set("dup_repo_id", c.repoID).
+			Set("dup_repo_name", c.repoName).
+			Set("dup_created_at", c.evCreatedAt).
+			InsertIgnore()
+		lib.ExecSQLWithErr(con, ctx, q, args...)
+		n++
+	}
+	return
+}
+
+// backfillCommitsRoles - creates gha_commits_roles (or gha_commits_authors) for all commits that don't have them yet
+// Progress is saved after each batch, so the backfill can be resumed after a restart
+func backfillCommitsRoles(restart, authors bool) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// GDPR data hiding
+	shaMap := lib.GetHidden(&ctx, lib.HideCfgFile)
+	maybeHide := lib.MaybeHideFuncTS(shaMap)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+
+	lib.Migrate(con, &ctx)
+	table, kind, process := "gha_commits_roles", "roles", processCommit
+	if authors {
+		progressName = authorsProgressName
+		table, kind, process = "gha_commits_authors", "authors", processCommitAuthors
+	}
+	if restart {
+		clearProgress(con, &ctx)
+	}
+	p := loadProgress(con, &ctx)
+	if p.processed > 0 {
+		lib.Printf("Resuming after (%s, %d), %d commits and %d %s processed so far\n", p.sha, p.eventID, p.processed, p.roles, kind)
+	}
+
+	// Commits processed in previous runs are included in progress, but not in rates
+	pr := lib.NewProgress(&ctx, "commit "+kind, int(p.processed)+countRemaining(con, &ctx, p, table), time.Duration(10)*time.Second)
+	pr.SetStart(int(p.processed))
+
+	// Get number of CPUs available, pool size is refreshed after each batch
+	pool := lib.NewPool(&ctx, 0)
+	mtx := &sync.Mutex{}
+	for {
+		commits := getBatch(con, &ctx, p, table)
+		nCommits := len(commits)
+		if nCommits == 0 {
+			break
+		}
+		roles := 0
+		for i := range commits {
+			c := &commits[i]
+			submitted := pool.Submit(func() {
+				n := process(con, &ctx, c, maybeHide)
+				mtx.Lock()
+				roles += n
+				mtx.Unlock()
+			})
+			if !submitted {
+				break
+			}
+		}
+		// Batch with a failed commit is not saved, so it is processed again after a restart
+		lib.FatalOnError(pool.Wait())
+		// All commits from the batch are processed, move watermark
+		last := commits[nCommits-1]
+		p.sha, p.eventID = last.sha, last.eventID
+		p.processed += int64(nCommits)
+		p.roles += int64(roles)
+		saveProgress(con, &ctx, p)
+		hits, missHits, misses := lib.ActorsCacheStats()
+		lib.Printf(
+			"Processed %d commits, %d %s (%d commits, %d %s so far, last: %s/%d), cached actors: %d, lookups: %d cached, %d cached misses, %d queried\n",
+			nCommits, roles, kind, p.processed, p.roles, kind, p.sha, p.eventID, lib.ActorsCacheSize(), hits, missHits, misses,
+		)
+		pr.Report(int(p.processed), "")
+		if lib.ActorsCacheSize() > maxCachedActors {
+			lib.ResetActorsCache()
+		}
+		pool.Refresh()
+	}
+	// Full pass done, the next run should start from the beginning
+	clearProgress(con, &ctx)
+	pr.Final(int(p.processed), "")
+	lib.Printf("Finished: %d commits processed, %d commit %s found\n", p.processed, p.roles, kind)
+}
+
+// Main - runs gha_backfill_commits_roles tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	restart, authors := false, false
+	for _, arg := range os.Args[1:] {
+		switch arg {
+		case "restart":
+			restart = true
+		case "authors":
+			authors = true
+		default:
+			lib.Printf("Usage: %s [authors] [restart]\n", os.Args[0])
+			lib.Printf("authors: backfill commit authors (gha_commits_authors) instead of commit roles\n")
+			lib.Printf("restart: ignore saved progress and start from the beginning\n")
+			os.Exit(lib.ExitConfig)
+		}
+	}
+	backfillCommitsRoles(restart, authors)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
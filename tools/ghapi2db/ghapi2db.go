@@ -0,0 +1,2782 @@
+package ghapi2db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rawSnapshots - fetched commits, issue events and PRs are also appended here (GHA2DB_GHAPI_RAW_DIR), nil when disabled
+var rawSnapshots *lib.GHAPIRaw
+
+// getAPIParams connects to GitHub and Postgres
+// Returns list of recent repositories and recent date to fetch commits from
+func getAPIParams(ctx *lib.Ctx) (repos []string, isSingleRepo bool, singleRepo string, gctx context.Context, gcs []*github.Client, c *sql.DB, recentDt time.Time) {
+	// Connect to GitHub API
+	gctx, gcs = lib.GHClient(ctx)
+
+	// Connect to Postgres DB
+	c = lib.PgConn(ctx)
+
+	// Get list of repositories to process
+	recentReposDt := lib.GetDateAgo(c, ctx, lib.HourStart(time.Now()), ctx.RecentReposRange)
+	reposA, rids := lib.GetRecentRepos(c, ctx, recentReposDt)
+	if ctx.Debug > 0 {
+		lib.Printf("Repos to process from %v: %v\n", recentReposDt, reposA)
+	}
+	// Repos can have the same ID with diffrent names
+	// But they also have the same name with different IDs
+	// We first need to put all repo names with unique IDs
+	// And then make this names list unique as well
+	ridsM := make(map[int64]struct{})
+	reposM := make(map[string]struct{})
+	for i := range rids {
+		rid := rids[i]
+		_, ok := ridsM[rid]
+		if !ok {
+			reposM[reposA[i]] = struct{}{}
+			ridsM[rid] = struct{}{}
+		}
+	}
+	for repo := range reposM {
+		repos = append(repos, repo)
+	}
+	repos = skipTombstoned(c, ctx, repos)
+	if ctx.Debug > 0 {
+		lib.Printf("Unique repos: %v\n", repos)
+	}
+	recentDt = lib.GetDateAgo(c, ctx, lib.HourStart(time.Now()), ctx.RecentRange)
+
+	// Single repo mode
+	singleRepo = os.Getenv("REPO")
+	if singleRepo != "" {
+		isSingleRepo = true
+	}
+
+	return
+}
+
+// skipTombstoned - removes repositories marked as not found from a given list
+func skipTombstoned(c *sql.DB, ctx *lib.Ctx, repos []string) []string {
+	tombstoned := lib.TombstonedRepos(c, ctx)
+	if len(tombstoned) == 0 {
+		return repos
+	}
+	ret := []string{}
+	for _, repo := range repos {
+		if _, skip := tombstoned[repo]; skip {
+			if ctx.Debug > 0 {
+				lib.Printf("Skipping %s, marked as not found\n", repo)
+			}
+			continue
+		}
+		ret = append(ret, repo)
+	}
+	if len(ret) < len(repos) {
+		lib.Printf("Skipped %d repos marked as not found\n", len(repos)-len(ret))
+	}
+	return ret
+}
+
+// getEnrichCommitsDateRange return last enriched commits date
+func getEnrichCommitsDateRange(c *sql.DB, ctx *lib.Ctx, repo string) (dtf time.Time, dtt time.Time, ok bool) {
+	var pdt *time.Time
+	rows := lib.QuerySQLWithErr(
+		c,
+		ctx,
+		fmt.Sprintf(
+			"select coalesce(max(dup_created_at), "+
+				"(select min(dup_created_at) from gha_commits where dup_repo_name = %s)) "+
+				"from gha_commits where author_email != '' and dup_repo_name = %s",
+			lib.NValue(1),
+			lib.NValue(2),
+		),
+		repo,
+		repo,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&pdt))
+		if pdt == nil {
+			if ctx.Debug > 0 {
+				lib.Printf("%s: no date from\n", repo)
+			}
+			return
+		}
+		dtf = pdt.Add(time.Minute * time.Duration(-2))
+	}
+	lib.FatalOnError(rows.Err())
+	rows = lib.QuerySQLWithErr(
+		c,
+		ctx,
+		fmt.Sprintf(
+			"select max(dup_created_at) from gha_commits where dup_repo_name = %s",
+			lib.NValue(1),
+		),
+		repo,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&pdt))
+		if pdt == nil {
+			if ctx.Debug > 0 {
+				lib.Printf("%s: no date to\n", repo)
+			}
+			return
+		}
+		dtt = pdt.Add(time.Minute * time.Duration(2))
+	}
+	lib.FatalOnError(rows.Err())
+	if ctx.Debug > 0 {
+		lib.Printf("%s: %s - %s\n", repo, lib.ToYMDHMSDate(dtf), lib.ToYMDHMSDate(dtt))
+	}
+	ok = true
+	return
+}
+
+// Search for given actor using his/her login
+// If not found, return hash as its ID
+func lookupActorTx(con *sql.Tx, ctx *lib.Ctx, login string, maybeHide func(string) string) int {
+	hlogin := maybeHide(login)
+	rows := lib.QuerySQLTxWithErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select id from gha_actors where login=%s union select id from "+
+				"gha_actors where lower(login)=%s order by id desc limit 1",
+			lib.NValue(1),
+			lib.NValue(2),
+		),
+		hlogin,
+		strings.ToLower(hlogin),
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	aid := 0
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&aid))
+	}
+	lib.FatalOnError(rows.Err())
+	if aid == 0 {
+		aid = lib.HashStrings([]string{login})
+	}
+	return aid
+}
+
+// Inserts single GHA Actor
+func insertActorTx(con *sql.Tx, ctx *lib.Ctx, aid int64, login, name string, maybeHide func(string) string) {
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		lib.InsertIgnore("into gha_actors(id, login, name) "+lib.NValues(3)),
+		lib.AnyArray{aid, maybeHide(login), maybeHide(lib.TruncToBytes(name, 120))}...,
+	)
+}
+
+// processCommit - logic to enrich commit
+func processCommit(c *sql.DB, ctx *lib.Ctx, commit *github.RepositoryCommit, maybeHide func(string) string) {
+	// Check required fields
+	if commit.Commit == nil {
+		lib.Fatalf("Nil Commit: %+v\n", commit)
+		return
+	}
+
+	// Start transaction for data possibly shared between events
+	tx, err := c.Begin()
+	lib.FatalOnError(err)
+
+	// Shortcuts
+	// SHA
+	cSHA := *commit.SHA
+
+	// Committer
+	committerID := int64(0)
+	committerLogin := ""
+	if commit.Committer != nil && commit.Committer.ID != nil {
+		committerID = *commit.Committer.ID
+	}
+	if commit.Committer != nil && commit.Committer.Login != nil {
+		committerLogin = *commit.Committer.Login
+	}
+	committerName := *commit.Commit.Committer.Name
+	committerEmail := *commit.Commit.Committer.Email
+	// committerDate := *commit.Commit.Committer.Date
+
+	// Author
+	authorID := int64(0)
+	authorLogin := ""
+	if commit.Author != nil && commit.Author.ID != nil {
+		authorID = *commit.Author.ID
+	}
+	if commit.Author != nil && commit.Author.Login != nil {
+		authorLogin = *commit.Author.Login
+	}
+	authorName := *commit.Commit.Author.Name
+	authorEmail := *commit.Commit.Author.Email
+	authorDate := *commit.Commit.Author.Date
+
+	//lib.Printf("%s %v %v\n", cSHA, authorDate, committerDate)
+	// Check if we already have this commit
+	strAuthorDate := lib.ToYMDHMSDate(authorDate)
+	rows := lib.QuerySQLTxWithErr(
+		tx,
+		ctx,
+		fmt.Sprintf(
+			"select sha, author_name, dup_created_at "+
+				"from gha_commits where sha = %s "+
+				"order by abs(extract(epoch from %s - dup_created_at)) "+
+				"limit 1",
+			lib.NValue(1),
+			lib.NValue(2),
+		),
+		cSHA,
+		strAuthorDate,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	sha := ""
+	currentAuthorName := ""
+	var createdAt time.Time
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&sha, &currentAuthorName, &createdAt))
+	}
+	lib.FatalOnError(rows.Err())
+	if sha != "" && ctx.Debug > 1 {
+		lib.Printf("GHA GHAPI time difference for sha %s: %v\n", cSHA, createdAt.Sub(authorDate))
+	}
+
+	// Get existing committer & author, it is possible that we don't have them yet
+	newCommitterID := int64(0)
+	if committerLogin != "" {
+		newCommitterID = int64(lookupActorTx(tx, ctx, committerLogin, maybeHide))
+	}
+	newAuthorID := int64(0)
+	if authorLogin != "" {
+		newAuthorID = committerID
+		if authorLogin != committerLogin {
+			newAuthorID = int64(lookupActorTx(tx, ctx, authorLogin, maybeHide))
+		}
+	}
+
+	// Compare to what we currently have, eventually warn and insert new
+	if committerLogin != "" && sha != "" && newCommitterID != committerID {
+		if ctx.Debug > 0 {
+			lib.Printf("DB Committer ID: %d != API Committer ID: %d, sha: %s, login: %s\n", newCommitterID, committerID, cSHA, committerLogin)
+		}
+		insertActorTx(tx, ctx, committerID, committerLogin, committerName, maybeHide)
+	}
+	if authorLogin != "" && sha != "" && authorLogin != committerLogin && newAuthorID != authorID {
+		if ctx.Debug > 0 {
+			lib.Printf("DB Author ID: %d != API Author ID: %d, SHA: %s, login: %s\n", newAuthorID, authorID, cSHA, authorLogin)
+		}
+		insertActorTx(tx, ctx, authorID, authorLogin, authorName, maybeHide)
+	}
+
+	// Same author?
+	if sha != "" && currentAuthorName != authorName {
+		lib.Printf("Author name mismatch API: %s, DB: %s, SHA: %s\n", authorName, currentAuthorName, cSHA)
+	}
+
+	// If we have that commit, update (enrich) it.
+	if sha == "" {
+		sha = *commit.SHA
+		if ctx.Debug > 1 {
+			lib.Printf("SHA %s not found\n", sha)
+		}
+	} else {
+		cols := []string{
+			"author_name=" + lib.NValue(1),
+			"author_email=" + lib.NValue(2),
+			"committer_name=" + lib.NValue(3),
+			"committer_email=" + lib.NValue(4),
+		}
+		vals := lib.AnyArray{
+			maybeHide(lib.TruncToBytes(authorName, 160)),
+			maybeHide(lib.TruncToBytes(authorEmail, 160)),
+			maybeHide(lib.TruncToBytes(committerName, 160)),
+			maybeHide(lib.TruncToBytes(committerEmail, 160)),
+		}
+		nVal := 5
+		if committerLogin != "" {
+			cols = append(cols, "committer_id="+lib.NValue(nVal))
+			vals = append(vals, committerID)
+			nVal++
+			cols = append(cols, "dup_committer_login="+lib.NValue(nVal))
+			vals = append(vals, maybeHide(lib.TruncToBytes(committerLogin, 160)))
+			nVal++
+		}
+		if authorLogin != "" {
+			cols = append(cols, "author_id="+lib.NValue(nVal))
+			vals = append(vals, authorID)
+			nVal++
+			cols = append(cols, "dup_author_login="+lib.NValue(nVal))
+			vals = append(vals, maybeHide(lib.TruncToBytes(authorLogin, 160)))
+			nVal++
+		}
+		vals = append(vals, sha)
+		vals = append(vals, createdAt)
+		query := "update gha_commits set " + strings.Join(cols, ", ")
+		query += " where sha=" + lib.NValue(nVal) + " and dup_created_at=" + lib.NValue(nVal+1)
+		lib.ExecSQLTxWithErr(tx, ctx, query, vals...)
+	}
+
+	// Author email
+	mEmail := maybeHide(lib.TruncToBytes(authorEmail, 120))
+	lib.ExecSQLTxWithErr(
+		tx,
+		ctx,
+		//lib.InsertIgnore("into gha_actors_emails(actor_id, email) "+lib.NValues(2)),
+		fmt.Sprintf(
+			"insert into gha_actors_emails(actor_id, email) %s on conflict(actor_id, email) "+
+				"do update set origin = 1 where gha_actors_emails.actor_id = %s "+
+				"and gha_actors_emails.email = %s",
+			lib.NValues(2),
+			lib.NValue(3),
+			lib.NValue(4),
+		),
+		lib.AnyArray{authorID, mEmail, authorID, mEmail}...,
+	)
+	// Committer email
+	if committerEmail != authorEmail {
+		mEmail = maybeHide(lib.TruncToBytes(committerEmail, 120))
+		lib.ExecSQLTxWithErr(
+			tx,
+			ctx,
+			//lib.InsertIgnore("into gha_actors_emails(actor_id, email) "+lib.NValues(2)),
+			fmt.Sprintf(
+				"insert into gha_actors_emails(actor_id, email) %s on conflict(actor_id, email) "+
+					"do update set origin = 1 where gha_actors_emails.actor_id = %s "+
+					"and gha_actors_emails.email = %s",
+				lib.NValues(2),
+				lib.NValue(3),
+				lib.NValue(4),
+			),
+			lib.AnyArray{committerID, mEmail, committerID, mEmail}...,
+		)
+	}
+	// Author name
+	mName := maybeHide(lib.TruncToBytes(authorName, 120))
+	lib.ExecSQLTxWithErr(
+		tx,
+		ctx,
+		//lib.InsertIgnore("into gha_actors_names(actor_id, name) "+lib.NValues(2)),
+		fmt.Sprintf(
+			"insert into gha_actors_names(actor_id, name) %s on conflict(actor_id, name) "+
+				"do update set origin = 1 where gha_actors_names.actor_id = %s "+
+				"and gha_actors_names.name = %s",
+			lib.NValues(2),
+			lib.NValue(3),
+			lib.NValue(4),
+		),
+		lib.AnyArray{authorID, mName, authorID, mName}...,
+	)
+	// Committer name
+	if committerName != authorName {
+		mName = maybeHide(lib.TruncToBytes(committerName, 120))
+		lib.ExecSQLTxWithErr(
+			tx,
+			ctx,
+			//lib.InsertIgnore("into gha_actors_names(actor_id, name) "+lib.NValues(2)),
+			fmt.Sprintf(
+				"insert into gha_actors_names(actor_id, name) %s on conflict(actor_id, name) "+
+					"do update set origin = 1 where gha_actors_names.actor_id = %s "+
+					"and gha_actors_names.name = %s",
+				lib.NValues(2),
+				lib.NValue(3),
+				lib.NValue(4),
+			),
+			lib.AnyArray{committerID, mName, committerID, mName}...,
+		)
+	}
+
+	// Final commit
+	// lib.FatalOnError(tx.Rollback())
+	lib.FatalOnError(tx.Commit())
+}
+
+// workflowRun - GitHub Actions workflow run, go-github's WorkflowRun has no actor and run start time
+type workflowRun struct {
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	WorkflowID   int64             `json:"workflow_id"`
+	Event        string            `json:"event"`
+	HeadBranch   *string           `json:"head_branch"`
+	HeadSHA      *string           `json:"head_sha"`
+	Status       string            `json:"status"`
+	Conclusion   *string           `json:"conclusion"`
+	Actor        *github.User      `json:"actor"`
+	CreatedAt    *github.Timestamp `json:"created_at"`
+	UpdatedAt    *github.Timestamp `json:"updated_at"`
+	RunStartedAt *github.Timestamp `json:"run_started_at"`
+}
+
+// workflowRuns - single page of repository workflow runs
+type workflowRuns struct {
+	TotalCount   int            `json:"total_count"`
+	WorkflowRuns []*workflowRun `json:"workflow_runs"`
+}
+
+// processWorkflowRun - inserts or updates workflow run, runs are updated until they are completed
+func processWorkflowRun(c *sql.DB, ctx *lib.Ctx, orgRepo string, run *workflowRun, maybeHide func(string) string) {
+	if run.ID == 0 || run.CreatedAt == nil {
+		return
+	}
+	updatedAt := run.CreatedAt.Time
+	if run.UpdatedAt != nil {
+		updatedAt = run.UpdatedAt.Time
+	}
+	// Duration is only known for completed runs, queue time is not included when run start time is known
+	var duration interface{}
+	if run.Status == "completed" {
+		startedAt := run.CreatedAt.Time
+		if run.RunStartedAt != nil {
+			startedAt = run.RunStartedAt.Time
+		}
+		duration = int(updatedAt.Sub(startedAt).Seconds())
+	}
+	var actorID, actorLogin interface{}
+	if run.Actor != nil && run.Actor.ID != nil && run.Actor.Login != nil {
+		actorID = *run.Actor.ID
+		actorLogin = maybeHide(*run.Actor.Login)
+	}
+	q, args := lib.NewQB("gha_workflow_runs").
+		Set("id", run.ID).
+		Set("repo_name", orgRepo).
+		Set("workflow_id", run.WorkflowID).
+		Set("name", lib.TruncToBytes(run.Name, 200)).
+		Set("event", lib.TruncToBytes(run.Event, 40)).
+		Set("head_branch", lib.TruncStringOrNil(run.HeadBranch, 200)).
+		Set("head_sha", lib.StringOrNil(run.HeadSHA)).
+		Set("status", lib.TruncToBytes(run.Status, 20)).
+		Set("conclusion", lib.StringOrNil(run.Conclusion)).
+		Set("actor_id", actorID).
+		Set("actor_login", actorLogin).
+		Set("created_at", run.CreatedAt.Time).
+		Set("updated_at", updatedAt).
+		Set("duration_seconds", duration).
+		Upsert("id")
+	lib.ExecSQLWithErr(c, ctx, q, args...)
+}
+
+// syncWorkflowRuns - syncs GitHub Actions workflow runs created in the recent range for all recent repos
+func syncWorkflowRuns(ctx *lib.Ctx) {
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, recentDt := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// To handle GDPR
+	maybeHide := lib.MaybeHideFunc(lib.GetHidden(ctx, lib.HideCfgFile))
+
+	// Process repos in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	runs := 0
+	var mtx = &sync.Mutex{}
+	nRepos := len(repos)
+	pr := lib.NewProgress(ctx, "ghapi2db workflow runs", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI workflow runs part\n", nRepos)
+	pool.OnDone(func(checked int) {
+		pr.Report(checked, "")
+	})
+	for _, orgRepo := range repos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			if isSingleRepo && orgRepo != singleRepo {
+				return
+			}
+			ary := strings.Split(orgRepo, "/")
+			if len(ary) < 2 || ary[0] == "" || ary[1] == "" {
+				return
+			}
+			// Runs are returned from the most recent, stop paging on the first run older than the recent range
+			for page := 1; page > 0; {
+				var (
+					data workflowRuns
+					resp *github.Response
+					got  bool
+				)
+				for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+					hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+					if rem[hint] <= ctx.MinGHAPIPoints {
+						if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("API limit reached while getting workflow runs data, waiting %v (%d)\n", waitPeriod[hint], tr)
+							}
+							time.Sleep(time.Duration(1) * time.Second)
+							time.Sleep(waitPeriod[hint])
+							continue
+						}
+						if ctx.GHAPIErrorIsFatal {
+							lib.Fatalf("API limit reached while getting workflow runs data, aborting, don't want to wait %v", waitPeriod[hint])
+						}
+						lib.Printf("Error: API limit reached while getting workflow runs data, aborting, don't want to wait %v\n", waitPeriod[hint])
+						return
+					}
+					req, err := gc[hint].NewRequest(
+						"GET",
+						fmt.Sprintf("repos/%s/actions/runs?per_page=100&page=%d", orgRepo, page),
+						nil,
+					)
+					lib.FatalOnError(err)
+					mtx.Lock()
+					apiCalls++
+					mtx.Unlock()
+					lib.GHRateGateWait()
+					data = workflowRuns{}
+					resp, err = gc[hint].Do(gctx, req, &data)
+					res := lib.HandlePossibleError(err, orgRepo, "Actions.ListRepositoryWorkflowRuns")
+					if res != "" {
+						if res == lib.Abuse {
+							wait := lib.GHAbuseWait(err, tr)
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("GitHub API abuse detected (workflow runs), wait %v\n", wait)
+							}
+							lib.GHRateGateSleep(wait)
+						}
+						if res == lib.NotFound {
+							// Actions disabled or repo removed
+							return
+						}
+						continue
+					}
+					got = true
+					break
+				}
+				if !got {
+					if ctx.GHAPIErrorIsFatal {
+						lib.Fatalf("GitHub API call failed %d times while getting workflow runs, aborting", ctx.MaxGHAPIRetry)
+					}
+					lib.Printf("Error: GitHub API call failed %d times while getting workflow runs for %s, skipping\n", ctx.MaxGHAPIRetry, orgRepo)
+					return
+				}
+				n := 0
+				for _, run := range data.WorkflowRuns {
+					if run.CreatedAt != nil && run.CreatedAt.Time.Before(recentDt) {
+						page = 0
+						break
+					}
+					processWorkflowRun(c, ctx, orgRepo, run, maybeHide)
+					n++
+				}
+				mtx.Lock()
+				runs += n
+				mtx.Unlock()
+				if ctx.Debug > 0 {
+					lib.Printf("%s: processed %d workflow runs, page %d\n", orgRepo, n, page)
+				}
+				if page > 0 {
+					page = resp.NextPage
+				}
+			}
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+	lib.Printf("GH workflow runs API calls: %d, workflow runs processed: %d\n", apiCalls, runs)
+}
+
+// getRepoTraffic - returns daily clones or views of a given repo (kind is "clones" or "views")
+// Returns nil data when the token has no push access to the repo (GitHub returns 403 then)
+func getRepoTraffic(gctx context.Context, ctx *lib.Ctx, gc []*github.Client, orgRepo, kind string, apiCalls *int, mtx *sync.Mutex) (data []*github.TrafficData, ok bool) {
+	ary := strings.Split(orgRepo, "/")
+	opts := &github.TrafficBreakdownOptions{Per: "day"}
+	for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+		hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API limit reached while getting traffic data, waiting %v (%d)\n", waitPeriod[hint], tr)
+				}
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(waitPeriod[hint])
+				continue
+			}
+			if ctx.GHAPIErrorIsFatal {
+				lib.Fatalf("API limit reached while getting traffic data, aborting, don't want to wait %v", waitPeriod[hint])
+			}
+			lib.Printf("Error: API limit reached while getting traffic data, aborting, don't want to wait %v\n", waitPeriod[hint])
+			return
+		}
+		mtx.Lock()
+		*apiCalls++
+		mtx.Unlock()
+		lib.GHRateGateWait()
+		var (
+			resp *github.Response
+			err  error
+		)
+		if kind == "clones" {
+			var clones *github.TrafficClones
+			clones, resp, err = gc[hint].Repositories.ListTrafficClones(gctx, ary[0], ary[1], opts)
+			if clones != nil {
+				data = clones.Clones
+			}
+		} else {
+			var views *github.TrafficViews
+			views, resp, err = gc[hint].Repositories.ListTrafficViews(gctx, ary[0], ary[1], opts)
+			if views != nil {
+				data = views.Views
+			}
+		}
+		// No push access, this is not an error (rate limits are reported as different error types)
+		if _, isResp := err.(*github.ErrorResponse); isResp && resp != nil && resp.StatusCode == 403 {
+			if ctx.Debug > 0 {
+				lib.Printf("%s: no push access, skipping traffic %s\n", orgRepo, kind)
+			}
+			return nil, true
+		}
+		res := lib.HandlePossibleError(err, orgRepo, "Repositories.ListTraffic")
+		if res != "" {
+			if res == lib.Abuse {
+				wait := lib.GHAbuseWait(err, tr)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("GitHub API abuse detected (traffic), wait %v\n", wait)
+				}
+				lib.GHRateGateSleep(wait)
+			}
+			if res == lib.NotFound {
+				return nil, true
+			}
+			continue
+		}
+		return data, true
+	}
+	if ctx.GHAPIErrorIsFatal {
+		lib.Fatalf("GitHub API call failed %d times while getting traffic, aborting", ctx.MaxGHAPIRetry)
+	}
+	lib.Printf("Error: GitHub API call failed %d times while getting traffic %s for %s, skipping\n", ctx.MaxGHAPIRetry, kind, orgRepo)
+	return
+}
+
+// syncTraffic - syncs daily clones and views of recent repos from orgs given in GHA2DB_GHAPITRAFFIC
+// GitHub only keeps the last 14 days of traffic data, so this must run at least once per 14 days
+func syncTraffic(ctx *lib.Ctx) {
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, _ := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// Process repos in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	days := 0
+	var mtx = &sync.Mutex{}
+	orgRepos := []string{}
+	for _, orgRepo := range repos {
+		ary := strings.Split(orgRepo, "/")
+		if len(ary) < 2 || ary[0] == "" || ary[1] == "" || !ctx.APITrafficOrgs[ary[0]] {
+			continue
+		}
+		if isSingleRepo && orgRepo != singleRepo {
+			continue
+		}
+		orgRepos = append(orgRepos, orgRepo)
+	}
+	nRepos := len(orgRepos)
+	pr := lib.NewProgress(ctx, "ghapi2db traffic", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI traffic part\n", nRepos)
+	pool.OnDone(func(checked int) {
+		pr.Report(checked, "")
+	})
+	for _, orgRepo := range orgRepos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			// day -> [clones, clones uniques, views, views uniques]
+			traffic := make(map[time.Time][4]int)
+			for i, kind := range []string{"clones", "views"} {
+				data, ok := getRepoTraffic(gctx, ctx, gc, orgRepo, kind, &apiCalls, mtx)
+				if !ok {
+					return
+				}
+				for _, item := range data {
+					if item.Timestamp == nil {
+						continue
+					}
+					day := lib.DayStart(item.Timestamp.Time)
+					counts := traffic[day]
+					counts[2*i] = item.GetCount()
+					counts[2*i+1] = item.GetUniques()
+					traffic[day] = counts
+				}
+			}
+			for day, counts := range traffic {
+				q, args := lib.NewQB("gha_repo_traffic").
+					Set("repo_name", orgRepo).
+					Set("dt", day).
+					Set("clones", counts[0]).
+					Set("clones_uniques", counts[1]).
+					Set("views", counts[2]).
+					Set("views_uniques", counts[3]).
+					Upsert("repo_name", "dt")
+				lib.ExecSQLWithErr(c, ctx, q, args...)
+			}
+			mtx.Lock()
+			days += len(traffic)
+			mtx.Unlock()
+			if ctx.Debug > 0 {
+				lib.Printf("%s: processed %d traffic days\n", orgRepo, len(traffic))
+			}
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+	lib.Printf("GH traffic API calls: %d, repo traffic days processed: %d\n", apiCalls, days)
+}
+
+// forkActiveDays - forks pushed within this number of days are active
+const forkActiveDays = 90
+
+// ghAPICall - calls GitHub API function f waiting for API points and retrying on errors, returns false when all tries failed
+// f returns error and response, notFound is set when GitHub returns 404
+func ghAPICall(gctx context.Context, ctx *lib.Ctx, gc []*github.Client, what, where string, apiCalls *int, mtx *sync.Mutex, f func(*github.Client) (*github.Response, error)) (ok, notFound bool) {
+	for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+		hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API limit reached while getting %s, waiting %v (%d)\n", what, waitPeriod[hint], tr)
+				}
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(waitPeriod[hint])
+				continue
+			}
+			if ctx.GHAPIErrorIsFatal {
+				lib.Fatalf("API limit reached while getting %s, aborting, don't want to wait %v", what, waitPeriod[hint])
+			}
+			lib.Printf("Error: API limit reached while getting %s, aborting, don't want to wait %v\n", what, waitPeriod[hint])
+			return
+		}
+		mtx.Lock()
+		*apiCalls++
+		mtx.Unlock()
+		lib.GHRateGateWait()
+		_, err := f(gc[hint])
+		res := lib.HandlePossibleError(err, where, what)
+		if res != "" {
+			if res == lib.Abuse {
+				wait := lib.GHAbuseWait(err, tr)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("GitHub API abuse detected (%s), wait %v\n", what, wait)
+				}
+				lib.GHRateGateSleep(wait)
+			}
+			if res == lib.NotFound {
+				return true, true
+			}
+			continue
+		}
+		return true, false
+	}
+	if ctx.GHAPIErrorIsFatal {
+		lib.Fatalf("GitHub API call failed %d times while getting %s, aborting", ctx.MaxGHAPIRetry, what)
+	}
+	lib.Printf("Error: GitHub API call failed %d times while getting %s for %s, skipping\n", ctx.MaxGHAPIRetry, what, where)
+	return
+}
+
+// syncForks - compares most recently pushed active forks of recent repos with upstream (GHA2DB_GHAPIFORKS)
+// Fork default branch is compared with the same upstream branch, ahead_by is the number of fork commits not in upstream
+func syncForks(ctx *lib.Ctx) {
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, _ := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// Process repos in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	forks := 0
+	var mtx = &sync.Mutex{}
+	orgRepos := []string{}
+	for _, orgRepo := range repos {
+		ary := strings.Split(orgRepo, "/")
+		if len(ary) < 2 || ary[0] == "" || ary[1] == "" {
+			continue
+		}
+		if isSingleRepo && orgRepo != singleRepo {
+			continue
+		}
+		orgRepos = append(orgRepos, orgRepo)
+	}
+	activeFrom := time.Now().AddDate(0, 0, -forkActiveDays)
+	nRepos := len(orgRepos)
+	pr := lib.NewProgress(ctx, "ghapi2db forks", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI forks part\n", nRepos)
+	pool.OnDone(func(checked int) {
+		pr.Report(checked, "")
+	})
+	for _, orgRepo := range orgRepos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			ary := strings.Split(orgRepo, "/")
+			// The newest forks are listed first, they can still be inactive
+			var list []*github.Repository
+			ok, _ := ghAPICall(gctx, ctx, gc, "Repositories.ListForks", orgRepo, &apiCalls, mtx, func(client *github.Client) (resp *github.Response, err error) {
+				opt := &github.RepositoryListForksOptions{Sort: "newest"}
+				opt.PerPage = 100
+				list, resp, err = client.Repositories.ListForks(gctx, ary[0], ary[1], opt)
+				return
+			})
+			if !ok {
+				return
+			}
+			active := []*github.Repository{}
+			for _, fork := range list {
+				if fork.PushedAt != nil && fork.PushedAt.After(activeFrom) && fork.GetDefaultBranch() != "" && fork.GetOwner().GetLogin() != "" {
+					active = append(active, fork)
+				}
+			}
+			sort.Slice(active, func(i, j int) bool { return active[i].PushedAt.After(active[j].PushedAt.Time) })
+			if len(active) > ctx.APIForks {
+				active = active[:ctx.APIForks]
+			}
+			n := 0
+			for _, fork := range active {
+				branch := fork.GetDefaultBranch()
+				var cmp *github.CommitsComparison
+				ok, notFound := ghAPICall(gctx, ctx, gc, "Repositories.CompareCommits", fork.GetFullName(), &apiCalls, mtx, func(client *github.Client) (resp *github.Response, err error) {
+					cmp, resp, err = client.Repositories.CompareCommits(gctx, ary[0], ary[1], branch, fork.GetOwner().GetLogin()+":"+branch, &github.ListOptions{PerPage: 1})
+					return
+				})
+				// Fork branch does not exist upstream
+				if !ok || notFound || cmp == nil {
+					continue
+				}
+				q, args := lib.NewQB("gha_fork_activity").
+					Set("repo_name", orgRepo).
+					Set("fork_name", lib.TruncToBytes(fork.GetFullName(), 160)).
+					Set("branch", lib.TruncToBytes(branch, 200)).
+					Set("pushed_at", fork.PushedAt.Time).
+					Set("stargazers", fork.GetStargazersCount()).
+					Set("ahead_by", cmp.GetAheadBy()).
+					Set("behind_by", cmp.GetBehindBy()).
+					Set("dt", time.Now()).
+					Upsert("repo_name", "fork_name")
+				lib.ExecSQLWithErr(c, ctx, q, args...)
+				n++
+			}
+			mtx.Lock()
+			forks += n
+			mtx.Unlock()
+			if ctx.Debug > 0 {
+				lib.Printf("%s: processed %d/%d active forks\n", orgRepo, n, len(active))
+			}
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+	lib.Printf("GH forks API calls: %d, active forks processed: %d\n", apiCalls, forks)
+}
+
+// syncMilestoneProgress - snapshots open and closed issues counts of active milestones of recent repos (GHA2DB_GHAPIMILESTONES)
+// Active milestones are open ones and ones closed in the recent range (their final state), snapshots of the same hour are upserted
+func syncMilestoneProgress(ctx *lib.Ctx) {
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, recentDt := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// Process repos in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	milestones := 0
+	var mtx = &sync.Mutex{}
+	orgRepos := []string{}
+	for _, orgRepo := range repos {
+		ary := strings.Split(orgRepo, "/")
+		if len(ary) < 2 || ary[0] == "" || ary[1] == "" {
+			continue
+		}
+		if isSingleRepo && orgRepo != singleRepo {
+			continue
+		}
+		orgRepos = append(orgRepos, orgRepo)
+	}
+	dt := lib.HourStart(time.Now())
+	nRepos := len(orgRepos)
+	pr := lib.NewProgress(ctx, "ghapi2db milestones", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI milestones part\n", nRepos)
+	pool.OnDone(func(checked int) {
+		pr.Report(checked, "")
+	})
+	for _, orgRepo := range orgRepos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			ary := strings.Split(orgRepo, "/")
+			n := 0
+			for page := 1; page > 0; {
+				var (
+					list []*github.Milestone
+					resp *github.Response
+				)
+				ok, notFound := ghAPICall(gctx, ctx, gc, "Issues.ListMilestones", orgRepo, &apiCalls, mtx, func(client *github.Client) (r *github.Response, err error) {
+					opt := &github.MilestoneListOptions{State: "all"}
+					opt.PerPage = 100
+					opt.Page = page
+					list, r, err = client.Issues.ListMilestones(gctx, ary[0], ary[1], opt)
+					resp = r
+					return
+				})
+				if !ok || notFound {
+					break
+				}
+				for _, milestone := range list {
+					if milestone.GetState() != "open" && (milestone.ClosedAt == nil || milestone.ClosedAt.Before(recentDt)) {
+						continue
+					}
+					var dueOn interface{}
+					if milestone.DueOn != nil {
+						dueOn = *milestone.DueOn
+					}
+					q, args := lib.NewQB("gha_milestone_progress").
+						Set("milestone_id", milestone.GetID()).
+						Set("dt", dt).
+						Set("repo_name", orgRepo).
+						Set("number", milestone.GetNumber()).
+						Set("title", lib.TruncToBytes(milestone.GetTitle(), 200)).
+						Set("state", milestone.GetState()).
+						Set("due_on", dueOn).
+						Set("open_issues", milestone.GetOpenIssues()).
+						Set("closed_issues", milestone.GetClosedIssues()).
+						Upsert("milestone_id", "dt")
+					lib.ExecSQLWithErr(c, ctx, q, args...)
+					n++
+				}
+				page = resp.NextPage
+			}
+			mtx.Lock()
+			milestones += n
+			mtx.Unlock()
+			if ctx.Debug > 0 {
+				lib.Printf("%s: snapshotted %d active milestones\n", orgRepo, n)
+			}
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+	lib.Printf("GH milestones API calls: %d, active milestones snapshotted: %d\n", apiCalls, milestones)
+}
+
+// actorIDColumns - columns referencing actor IDs, rewritten when synthetic actor ID is reconciled
+// login is the column holding the same actor login (needed to rewrite zero IDs that are shared by many logins)
+// keys are other primary key columns of tables having actor ID in their primary key
+var actorIDColumns = []struct {
+	table, column, login string
+	keys                 []string
+}{
+	{"gha_events", "actor_id", "dup_actor_login", nil},
+	{"gha_actors_emails", "actor_id", "", []string{"email"}},
+	{"gha_actors_names", "actor_id", "", []string{"name"}},
+	{"gha_actors_affiliations", "actor_id", "", []string{"company_name", "dt_from", "dt_to"}},
+	{"gha_payloads", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_payloads", "member_id", "", nil},
+	{"gha_commits", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_commits", "author_id", "dup_author_login", nil},
+	{"gha_commits", "committer_id", "dup_committer_login", nil},
+	{"gha_commits_roles", "actor_id", "actor_login", nil},
+	{"gha_commits_authors", "actor_id", "actor_login", nil},
+	{"gha_pages", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_comments", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_comments", "user_id", "dup_user_login", nil},
+	{"gha_issues", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_issues", "user_id", "dup_user_login", nil},
+	{"gha_issues", "assignee_id", "dupn_assignee_login", nil},
+	{"gha_issues_assignees", "assignee_id", "", []string{"issue_id", "event_id"}},
+	{"gha_milestones", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_milestones", "creator_id", "dupn_creator_login", nil},
+	{"gha_issues_labels", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_forkees", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_forkees", "owner_id", "dup_owner_login", nil},
+	{"gha_releases", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_releases", "author_id", "dup_author_login", nil},
+	{"gha_assets", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_pull_requests", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_pull_requests", "user_id", "dup_user_login", nil},
+	{"gha_pull_requests", "merged_by_id", "dupn_merged_by_login", nil},
+	{"gha_pull_requests", "assignee_id", "dupn_assignee_login", nil},
+	{"gha_pull_requests_assignees", "assignee_id", "", []string{"pull_request_id", "event_id"}},
+	{"gha_pull_requests_requested_reviewers", "requested_reviewer_id", "", []string{"pull_request_id", "event_id"}},
+	{"gha_branches", "user_id", "dupn_user_login", nil},
+	{"gha_teams", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_reviews", "dup_actor_id", "dup_actor_login", nil},
+	{"gha_reviews", "user_id", "dup_user_login", nil},
+	{"gha_texts", "actor_id", "actor_login", nil},
+	{"gha_issues_events_labels", "actor_id", "actor_login", nil},
+	{"gha_issue_label_history", "actor_id", "actor_login", nil},
+	{"gha_workflow_runs", "actor_id", "actor_login", nil},
+}
+
+// actorReconcileBatch - number of reconciled logins rewritten in a single transaction (every table is scanned once per batch)
+const actorReconcileBatch = 100
+
+// actorIDMapping - synthetic actor ID of a given login resolved to its real GitHub ID
+type actorIDMapping struct {
+	login string
+	oldID int64
+	newID int64
+}
+
+// getUserID - returns GitHub ID of a given login, waits for API points when needed
+// reason is set when login cannot be resolved, ok is false when user cannot be fetched now (should be retried in the next run)
+func getUserID(gctx context.Context, ctx *lib.Ctx, gc []*github.Client, login string, apiCalls *int, mtx *sync.Mutex) (id int64, reason string, ok bool) {
+	for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+		hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API limit reached while getting user data, waiting %v (%d)\n", waitPeriod[hint], tr)
+				}
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(waitPeriod[hint])
+				continue
+			}
+			lib.Printf("API limit reached while getting user data, don't want to wait %v, stopping\n", waitPeriod[hint])
+			return
+		}
+		mtx.Lock()
+		*apiCalls++
+		mtx.Unlock()
+		lib.GHRateGateWait()
+		user, _, err := gc[hint].Users.Get(gctx, login)
+		res := lib.HandlePossibleError(err, login, "Users.Get")
+		if res != "" {
+			if res == lib.Abuse {
+				wait := lib.GHAbuseWait(err, tr)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("GitHub API abuse detected (user), wait %v\n", wait)
+				}
+				lib.GHRateGateSleep(wait)
+			}
+			if res == lib.NotFound {
+				return 0, "not_found", true
+			}
+			continue
+		}
+		// Login was renamed and is now used by a different user
+		if user == nil || user.ID == nil || !strings.EqualFold(user.GetLogin(), login) {
+			return 0, "renamed", true
+		}
+		return *user.ID, "", true
+	}
+	lib.Printf("Error: GitHub API call failed %d times while getting user %s, skipping\n", ctx.MaxGHAPIRetry, login)
+	return
+}
+
+// rewriteActorIDs - replaces synthetic actor IDs with real ones in gha_actors and all tables referencing actors
+// Rows that would duplicate an existing primary key are removed
+func rewriteActorIDs(c *sql.DB, ctx *lib.Ctx, mappings []actorIDMapping) {
+	values := func(zero bool) (string, []interface{}) {
+		rows := []string{}
+		args := []interface{}{}
+		for _, m := range mappings {
+			if m.oldID == 0 && !zero {
+				continue
+			}
+			n := len(args)
+			rows = append(rows, fmt.Sprintf("(%s::bigint, %s::bigint, %s::text)", lib.NValue(n+1), lib.NValue(n+2), lib.NValue(n+3)))
+			args = append(args, m.oldID, m.newID, m.login)
+		}
+		if len(rows) == 0 {
+			return "", nil
+		}
+		return "(values " + strings.Join(rows, ", ") + ") m(old_id, new_id, login)", args
+	}
+	con, err := c.Begin()
+	lib.FatalOnError(err)
+	for _, col := range actorIDColumns {
+		// Zero IDs can only be rewritten in rows that also have actor login
+		mv, args := values(col.login != "")
+		if mv == "" {
+			continue
+		}
+		cond := fmt.Sprintf("t.%s = m.old_id", col.column)
+		if col.login != "" {
+			cond += fmt.Sprintf(" and (m.old_id <> 0 or t.%s = m.login)", col.login)
+		}
+		if len(col.keys) == 0 {
+			lib.ExecSQLTxWithErr(con, ctx, fmt.Sprintf("update %s t set %s = m.new_id from %s where %s", col.table, col.column, mv, cond), args...)
+			continue
+		}
+		keys := []string{}
+		for _, key := range col.keys {
+			keys = append(keys, fmt.Sprintf("x.%s = t.%s", key, key))
+		}
+		lib.ExecSQLTxWithErr(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"update %s t set %s = m.new_id from %s where %s and not exists(select 1 from %s x where x.%s = m.new_id and %s)",
+				col.table,
+				col.column,
+				mv,
+				cond,
+				col.table,
+				col.column,
+				strings.Join(keys, " and "),
+			),
+			args...,
+		)
+		lib.ExecSQLTxWithErr(con, ctx, fmt.Sprintf("delete from %s t using %s where %s", col.table, mv, cond), args...)
+	}
+	mv, args := values(true)
+	lib.ExecSQLTxWithErr(
+		con,
+		ctx,
+		"update gha_actors t set id = m.new_id from "+mv+" where t.id = m.old_id and t.login = m.login "+
+			"and not exists(select 1 from gha_actors x where x.id = m.new_id and x.login = t.login)",
+		args...,
+	)
+	lib.ExecSQLTxWithErr(con, ctx, "delete from gha_actors t using "+mv+" where t.id = m.old_id and t.login = m.login", args...)
+	lib.ExecSQLTxWithErr(con, ctx, "delete from gha_actors_unreconciled t using "+mv+" where t.login = m.login", args...)
+	lib.FatalOnError(con.Commit())
+}
+
+// reconcileActors - resolves logins having synthetic (hashed or zero) actor IDs to their real GitHub IDs (GHA2DB_GHAPI_RECONCILE_ACTORS)
+// Real ID already known for the same login is used without calling GitHub API, logins that cannot be resolved are saved in gha_actors_unreconciled
+// and skipped for a month
+func reconcileActors(ctx *lib.Ctx) {
+	gctx, gc := lib.GHClient(ctx)
+	c := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+	rows := lib.QuerySQLWithErr(
+		c,
+		ctx,
+		"select a.login, min(a.id), (select max(x.id) from gha_actors x where lower(x.login) = lower(a.login) and x.id > 0) "+
+			"from gha_actors a where a.id <= 0 and a.login not like 'anon-%' and not exists("+
+			"select 1 from gha_actors_unreconciled u where u.login = a.login and u.dt > now() - '1 month'::interval) "+
+			"group by a.login order by a.login limit $1",
+		ctx.APIReconcileActors,
+	)
+	var (
+		login   string
+		oldID   int64
+		knownID sql.NullInt64
+	)
+	mappings := []actorIDMapping{}
+	logins := make(map[string]int64)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&login, &oldID, &knownID))
+		if knownID.Valid {
+			mappings = append(mappings, actorIDMapping{login: login, oldID: oldID, newID: knownID.Int64})
+			continue
+		}
+		logins[login] = oldID
+	}
+	lib.FatalOnError(rows.Err())
+	lib.FatalOnError(rows.Close())
+	lib.Printf("ghapi2db.go: Reconciling %d actors with synthetic IDs, %d already known, %d via GitHub API\n", len(mappings)+len(logins), len(mappings), len(logins))
+
+	// Resolve logins via GitHub users API in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	unreconciled := 0
+	var mtx = &sync.Mutex{}
+	dtStart := time.Now()
+	lastTime := dtStart
+	pool.OnDone(func(checked int) {
+		lib.ProgressInfo(checked, len(logins), dtStart, &lastTime, time.Duration(10)*time.Second, "")
+	})
+	for login, oldID := range logins {
+		login, oldID := login, oldID
+		pool.Submit(func() {
+			id, reason, ok := getUserID(gctx, ctx, gc, login, &apiCalls, mtx)
+			if !ok {
+				return
+			}
+			if reason != "" {
+				q, args := lib.NewQB("gha_actors_unreconciled").
+					Set("login", login).
+					Set("reason", reason).
+					Set("dt", time.Now()).
+					Upsert("login")
+				lib.ExecSQLWithErr(c, ctx, q, args...)
+				mtx.Lock()
+				unreconciled++
+				mtx.Unlock()
+				return
+			}
+			mtx.Lock()
+			mappings = append(mappings, actorIDMapping{login: login, oldID: oldID, newID: id})
+			mtx.Unlock()
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+
+	// Rewrite references in batches
+	for from := 0; from < len(mappings); from += actorReconcileBatch {
+		to := from + actorReconcileBatch
+		if to > len(mappings) {
+			to = len(mappings)
+		}
+		rewriteActorIDs(c, ctx, mappings[from:to])
+		if ctx.Debug > 0 {
+			lib.Printf("Reconciled actors %d-%d/%d\n", from+1, to, len(mappings))
+		}
+	}
+	lib.Printf("GH users API calls: %d, actors reconciled: %d, unreconcilable: %d\n", apiCalls, len(mappings), unreconciled)
+}
+
+// Some debugging options (environment variables)
+// You can set:
+// REPO=full_repo_name
+// DTFROM=datetime 'YYYY-MM-DD hh:mm:ss.uuuuuu"
+// To use DTFROM make sure you set GHA2DB_RECENT_RANGE to cover that range too.
+// commitGap - commit with missing author/committer enrichment
+type commitGap struct {
+	sha       string
+	createdAt time.Time
+	repo      string
+}
+
+// findCommitsGaps - returns commits of given repos created in from-to range that have empty author_email or no author_id
+// Commits already re-synced GHA2DB_COMMITS_GAP_ATTEMPTS times are skipped (for example authors without GitHub accounts never get author_id)
+func findCommitsGaps(c *sql.DB, ctx *lib.Ctx, repos map[string]struct{}, from, to time.Time) (gaps []commitGap) {
+	rows := lib.QuerySQLWithErr(
+		c,
+		ctx,
+		fmt.Sprintf(
+			"select c.sha, c.dup_created_at, c.dup_repo_name from gha_commits c "+
+				"left join gha_commits_gaps g on g.sha = c.sha and g.dup_created_at = c.dup_created_at "+
+				"where c.dup_created_at >= %s and c.dup_created_at < %s "+
+				"and (c.author_email = '' or coalesce(c.author_id, 0) = 0) "+
+				"and coalesce(g.attempts, 0) < %s "+
+				"order by c.dup_created_at desc",
+			lib.NValue(1),
+			lib.NValue(2),
+			lib.NValue(3),
+		),
+		from,
+		to,
+		ctx.CommitsGapAttempts,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		var gap commitGap
+		lib.FatalOnError(rows.Scan(&gap.sha, &gap.createdAt, &gap.repo))
+		if _, ok := repos[gap.repo]; !ok || len(gaps) >= ctx.CommitsGapMax {
+			continue
+		}
+		gaps = append(gaps, gap)
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// resyncCommitsGaps - fetches commits with missing enrichment one by one and enriches them again, every attempt is counted in gha_commits_gaps
+func resyncCommitsGaps(gctx context.Context, ctx *lib.Ctx, gc []*github.Client, c *sql.DB, repos map[string]struct{}, from, to time.Time, apiCalls *int, apiCallsMutex *sync.Mutex) (resynced int64) {
+	gaps := findCommitsGaps(c, ctx, repos, from, to)
+	if len(gaps) == 0 {
+		if ctx.Debug > 0 {
+			lib.Printf("No commits enrichment gaps found in %s - %s\n", lib.ToYMDHMSDate(from), lib.ToYMDHMSDate(to))
+		}
+		return
+	}
+	lib.Printf("Found %d commits with missing enrichment in %s - %s, re-syncing\n", len(gaps), lib.ToYMDHMSDate(from), lib.ToYMDHMSDate(to))
+	maybeHide := lib.MaybeHideFuncTS(lib.GetHidden(ctx, lib.HideCfgFile))
+	var notFound, failed int64
+	pool := lib.NewPool(ctx, 16)
+	for _, gap := range gaps {
+		gap := gap
+		pool.Submit(func() {
+			ary := strings.Split(gap.repo, "/")
+			if len(ary) < 2 || ary[0] == "" || ary[1] == "" {
+				return
+			}
+			var commit *github.RepositoryCommit
+			ok, nf := ghAPICall(
+				gctx, ctx, gc, "commit "+gap.sha, gap.repo, apiCalls, apiCallsMutex,
+				func(client *github.Client) (response *github.Response, err error) {
+					commit, response, err = client.Repositories.GetCommit(gctx, ary[0], ary[1], gap.sha, nil)
+					return
+				},
+			)
+			switch {
+			case nf:
+				atomic.AddInt64(&notFound, 1)
+			case ok && commit != nil && commit.Commit != nil:
+				rawSnapshots.Write(lib.GHAPIRawCommits, gap.repo, commit)
+				processCommit(c, ctx, commit, maybeHide)
+				atomic.AddInt64(&resynced, 1)
+			default:
+				atomic.AddInt64(&failed, 1)
+			}
+			lib.ExecSQLWithErr(
+				c,
+				ctx,
+				fmt.Sprintf(
+					"insert into gha_commits_gaps(sha, dup_created_at, dup_repo_name, attempts, last_attempt_at) %s "+
+						"on conflict(sha, dup_created_at) do update set "+
+						"attempts = gha_commits_gaps.attempts + 1, last_attempt_at = excluded.last_attempt_at",
+					lib.NValues(5),
+				),
+				gap.sha,
+				gap.createdAt,
+				gap.repo,
+				1,
+				time.Now(),
+			)
+		})
+	}
+	lib.FatalOnError(pool.Wait())
+	lib.Printf("Commits enrichment gaps: %d found, %d re-synced, %d not found, %d failed\n", len(gaps), resynced, notFound, failed)
+	return
+}
+
+func syncCommits(ctx *lib.Ctx) {
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, recentDt := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// Date range mode
+	var (
+		dateRangeFrom *time.Time
+		dateRangeTo   *time.Time
+	)
+	isDateRange := false
+	dateRangeFromS := os.Getenv("DTFROM")
+	dateRangeToS := os.Getenv("DTTO")
+	if dateRangeFromS != "" {
+		tmp := lib.TimeParseAny(dateRangeFromS)
+		dateRangeFrom = &tmp
+		isDateRange = true
+	}
+	if dateRangeToS != "" {
+		tmp := lib.TimeParseAny(dateRangeToS)
+		dateRangeTo = &tmp
+		isDateRange = true
+	}
+
+	// Process commits in parallel
+	pool := lib.NewPool(ctx, 16)
+	apiCalls := 0
+	var apiCallsMutex = &sync.Mutex{}
+	var processedCommits int64
+	dtStart := time.Now()
+	nRepos := len(repos)
+	pr := lib.NewProgress(ctx, "ghapi2db commits", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI commits part\n", nRepos)
+
+	opt := &github.CommitsListOptions{
+		Since: recentDt,
+		// SHA:    "s",
+		// Path:   "p",
+		// Author: "a",
+	}
+	opt.PerPage = 100
+	if isDateRange {
+		if dateRangeFrom != nil {
+			opt.Since = *dateRangeFrom
+		}
+		if dateRangeTo != nil {
+			opt.Until = *dateRangeTo
+		}
+	}
+	pool.OnDone(func(checked int) {
+		// Get RateLimits info
+		hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gc, true)
+		pr.Report(checked, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+	})
+	for _, orgRepo := range repos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			if isSingleRepo && orgRepo != singleRepo {
+				return
+			}
+			ary := strings.Split(orgRepo, "/")
+			if len(ary) < 2 {
+				return
+			}
+			org := ary[0]
+			repo := ary[1]
+			if org == "" || repo == "" {
+				return
+			}
+			thDtStart := time.Now()
+			thLastTime := dtStart
+			// To handle GDPR
+			maybeHide := lib.MaybeHideFunc(lib.GetHidden(ctx, lib.HideCfgFile))
+			// Need deep copy - threads
+			copt := opt
+			// No DTFROM/DTTO set and no GHA2DB_NO_AUTOFETCHCOMMITS
+			if !isDateRange && ctx.AutoFetchCommits {
+				dtf, dtt, ok := getEnrichCommitsDateRange(c, ctx, orgRepo)
+				if !ok {
+					return
+				}
+				copt = &github.CommitsListOptions{
+					Since:  dtf,
+					Until:  dtt,
+					SHA:    opt.SHA,
+					Path:   opt.Path,
+					Author: opt.Author,
+				}
+				copt.PerPage = opt.PerPage
+			}
+			var (
+				err      error
+				commits  []*github.RepositoryCommit
+				response *github.Response
+			)
+			nPages := 0
+			// Synchronize go routine
+			// start infinite for (paging)
+			for {
+				got := false
+				/// start trials
+				for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+					hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+					if ctx.GitHubDebug > 0 {
+						lib.Printf("Repo commits Try: %d, rem: %+v, waitPeriod: %+v, hint: %d\n", tr, rem, waitPeriod, hint)
+					}
+					if rem[hint] <= ctx.MinGHAPIPoints {
+						if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("API limit reached while getting commits data, waiting %v (%d)\n", waitPeriod[hint], tr)
+							}
+							time.Sleep(time.Duration(1) * time.Second)
+							time.Sleep(waitPeriod[hint])
+							continue
+						} else {
+							if ctx.GHAPIErrorIsFatal {
+								lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting commits data, aborting, don't want to wait %v", waitPeriod[hint])
+							} else {
+								lib.Printf("Error: API limit reached while getting commits data, aborting, don't want to wait %v\n", waitPeriod[hint])
+								return
+							}
+						}
+					}
+					nPages++
+					if ctx.GitHubDebug > 0 {
+						lib.Printf("API call for commits %s (%d), remaining GHAPI points %+v, hint: %d\n", orgRepo, nPages, rem, hint)
+					}
+					apiCallsMutex.Lock()
+					apiCalls++
+					apiCallsMutex.Unlock()
+					lib.GHRateGateWait()
+					commits, response, err = gc[hint].Repositories.ListCommits(gctx, org, repo, copt)
+					res := lib.HandlePossibleError(err, orgRepo, "Repositories.ListCommits")
+					if res != "" {
+						if res == lib.Abuse {
+							wait := lib.GHAbuseWait(err, tr)
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("GitHub API abuse detected (issues events), wait %v\n", wait)
+							}
+							if pool.Shrink() && ctx.GitHubDebug > 0 {
+								lib.Printf("Lower threads limit (issues events): %d/%d\n", pool.Running(), pool.Size())
+							}
+							lib.GHRateGateSleep(wait)
+						}
+						if res == lib.NotFound {
+							lib.Printf("Warning: not found: %s/%s\n", org, repo)
+							return
+						}
+						continue
+					} else {
+						if pool.Grow() && ctx.GitHubDebug > 0 {
+							lib.Printf("Rise threads limit (issues events): %d/%d\n", pool.Running(), pool.Size())
+						}
+					}
+					got = true
+					break
+				}
+				/// end trials
+				if !got {
+					if ctx.GHAPIErrorIsFatal {
+						lib.FatalfWithCode(lib.ExitGitHubAPI, "GetRateLimit call failed %d times while getting events, aborting", ctx.MaxGHAPIRetry)
+					} else {
+						lib.Printf("Error: GetRateLimit call failed %d times while getting events, aborting\n", ctx.MaxGHAPIRetry)
+						return
+					}
+				}
+				// Process commits
+				if ctx.Debug > 0 {
+					lib.Printf("%s: processing %d commits, page %d\n", orgRepo, len(commits), nPages)
+				}
+				for _, commit := range commits {
+					rawSnapshots.Write(lib.GHAPIRawCommits, orgRepo, commit)
+					processCommit(c, ctx, commit, maybeHide)
+				}
+				atomic.AddInt64(&processedCommits, int64(len(commits)))
+				hint, _, thRem, thWait := lib.GetRateLimits(gctx, ctx, gc, true)
+				lib.ProgressInfo(0, 0, thDtStart, &thLastTime, time.Duration(10)*time.Second, fmt.Sprintf("%s page %d, API points: %+v, resets in: %+v, hint: %d", orgRepo, nPages, thRem, thWait, hint))
+				// Handle paging
+				if response.NextPage == 0 {
+					break
+				}
+				copt.Page = response.NextPage
+			}
+			// end infinite for (paging)
+		})
+	}
+	// Usually all work happens on 'pool.Submit'
+	if ctx.Debug > 0 {
+		lib.Printf("Final GHAPI threads join\n")
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+	// Commits enrichment could be missed (token errors, aborts on abuse detection), re-sync incomplete commits of the synced window
+	if ctx.CommitsGapAttempts > 0 {
+		gapRepos := make(map[string]struct{})
+		for _, repo := range repos {
+			if !isSingleRepo || repo == singleRepo {
+				gapRepos[repo] = struct{}{}
+			}
+		}
+		gapTo := opt.Until
+		if gapTo.IsZero() {
+			gapTo = time.Now()
+		}
+		processedCommits += resyncCommitsGaps(gctx, ctx, gc, c, gapRepos, opt.Since, gapTo, &apiCalls, apiCallsMutex)
+	}
+	// Processed commits add actors emails and names, other tools can now find actors they cached as not found
+	if processedCommits > 0 {
+		lib.SetActorsChanged(c, ctx)
+	}
+	lib.Printf("GH Commits API calls: %d, commits processed: %d\n", apiCalls, processedCommits)
+}
+
+// Some debugging options (environment variables)
+// You can set:
+// REPO=full_repo_name
+// DTFROM=datetime 'YYYY-MM-DD hh:mm:ss.uuuuuu"
+// DTTO=datetime 'YYYY-MM-DD hh:mm:ss.uuuuuu"
+// MILESTONE=milestone name
+// ISSUE="issue_number"
+// To use DTFROM and DTTO make sure you set GHA2DB_RECENT_RANGE to cover that range too.
+// labelChange - single label added/removed transition from labeled/unlabeled issue event
+type labelChange struct {
+	eventID    int64
+	issueID    int64
+	label      string
+	action     string
+	dt         time.Time
+	actorID    *int64
+	actorLogin string
+	repo       string
+	number     int
+	pr         bool
+}
+
+// newLabelChange - returns label transition of a labeled/unlabeled issue event, ok is false for other events
+func newLabelChange(cfg *lib.IssueConfig, maybeHide func(string) string) (change labelChange, ok bool) {
+	event := cfg.GhEvent
+	if (cfg.EventType != "labeled" && cfg.EventType != "unlabeled") || event.Label == nil || event.Label.Name == nil {
+		return
+	}
+	change = labelChange{
+		eventID: cfg.EventID,
+		issueID: cfg.IssueID,
+		label:   lib.TruncToBytes(*event.Label.Name, lib.SchemaLimits["gha_issue_label_history.label_name"]),
+		action:  "added",
+		dt:      cfg.CreatedAt,
+		repo:    cfg.Repo,
+		number:  cfg.Number,
+		pr:      cfg.Pr,
+	}
+	if cfg.EventType == "unlabeled" {
+		change.action = "removed"
+	}
+	if event.Actor != nil {
+		change.actorID = event.Actor.ID
+		if event.Actor.Login != nil {
+			change.actorLogin = lib.TruncToBytes(maybeHide(*event.Actor.Login), lib.SchemaLimits["gha_issue_label_history.actor_login"])
+		}
+	}
+	return change, true
+}
+
+// saveLabelHistory - saves label transitions into gha_issue_label_history
+func saveLabelHistory(c *sql.DB, ctx *lib.Ctx, changes []labelChange) {
+	if len(changes) == 0 {
+		return
+	}
+	for _, change := range changes {
+		q, args := lib.NewQB("gha_issue_label_history").
+			Set("event_id", change.eventID).
+			Set("issue_id", change.issueID).
+			Set("label_name", change.label).
+			Set("action", change.action).
+			Set("dt", change.dt).
+			Set("actor_id", change.actorID).
+			Set("actor_login", change.actorLogin).
+			Set("repo_name", change.repo).
+			Set("issue_number", change.number).
+			Set("is_pull_request", change.pr).
+			Upsert("event_id")
+		lib.ExecSQLWithErr(c, ctx, q, args...)
+	}
+	lib.Printf("Saved %d label history changes\n", len(changes))
+}
+
+// issueTransfer - issue/PR transferred into a repository, from 'transferred' issue event
+type issueTransfer struct {
+	eventID    int64
+	issueID    int64
+	dt         time.Time
+	actorID    *int64
+	actorLogin string
+	repo       string
+	number     int
+	pr         bool
+}
+
+// repoFromAPIURL - returns org/repo from GitHub API repository URL (https://api.github.com/repos/org/repo), empty string for other URLs
+func repoFromAPIURL(url string) string {
+	if !strings.Contains(url, "/repos/") {
+		return ""
+	}
+	return url[strings.LastIndex(url, "/repos/")+7:]
+}
+
+// newIssueTransfer - returns transfer of a transferred issue event, ok is false for other events
+// Target repository is the current issue repository, GitHub API doesn't return the source repository
+func newIssueTransfer(cfg *lib.IssueConfig, maybeHide func(string) string) (transfer issueTransfer, ok bool) {
+	if cfg.EventType != "transferred" {
+		return
+	}
+	transfer = issueTransfer{
+		eventID: cfg.EventID,
+		issueID: cfg.IssueID,
+		dt:      cfg.CreatedAt,
+		repo:    cfg.Repo,
+		number:  cfg.Number,
+		pr:      cfg.Pr,
+	}
+	if repo := repoFromAPIURL(cfg.GhIssue.GetRepositoryURL()); repo != "" {
+		transfer.repo = repo
+	}
+	event := cfg.GhEvent
+	if event.Actor != nil {
+		transfer.actorID = event.Actor.ID
+		if event.Actor.Login != nil {
+			transfer.actorLogin = lib.TruncToBytes(maybeHide(*event.Actor.Login), lib.SchemaLimits["gha_issue_transfers.actor_login"])
+		}
+	}
+	return transfer, true
+}
+
+// saveIssueTransfers - saves issue transfers into gha_issue_transfers and points gha_issues_canonical to the target repository
+// Source repository and number come from the latest issue state stored for other repository (GHA keeps the same issue ID)
+func saveIssueTransfers(c *sql.DB, ctx *lib.Ctx, transfers []issueTransfer) {
+	if len(transfers) == 0 {
+		return
+	}
+	for _, transfer := range transfers {
+		var (
+			fromRepoID   *int64
+			fromRepoName *string
+			fromNumber   *int
+			toRepoID     int64
+		)
+		err := lib.QueryRowSQL(
+			c,
+			ctx,
+			"select dup_repo_id, dup_repo_name, number from gha_issues where id = $1 and dup_repo_name <> $2 "+
+				"order by updated_at desc, event_id desc limit 1",
+			transfer.issueID,
+			transfer.repo,
+		).Scan(&fromRepoID, &fromRepoName, &fromNumber)
+		if err != nil && err != sql.ErrNoRows {
+			lib.FatalOnError(err)
+		}
+		lib.FatalOnError(
+			lib.QueryRowSQL(
+				c,
+				ctx,
+				"select coalesce(max(repo_id), -1) from gha_events where dup_repo_name = $1",
+				transfer.repo,
+			).Scan(&toRepoID),
+		)
+		q, args := lib.NewQB("gha_issue_transfers").
+			Set("event_id", transfer.eventID).
+			Set("issue_id", transfer.issueID).
+			Set("from_repo_id", fromRepoID).
+			Set("from_repo_name", fromRepoName).
+			Set("from_number", fromNumber).
+			Set("to_repo_id", toRepoID).
+			Set("to_repo_name", transfer.repo).
+			Set("to_number", transfer.number).
+			Set("is_pull_request", transfer.pr).
+			Set("dt", transfer.dt).
+			Set("actor_id", transfer.actorID).
+			Set("actor_login", transfer.actorLogin).
+			Upsert("event_id")
+		lib.ExecSQLWithErr(c, ctx, q, args...)
+		// Issue can be transferred multiple times, the most recent transfer wins
+		q, args = lib.NewQB("gha_issues_canonical").
+			Set("issue_id", transfer.issueID).
+			Set("repo_id", toRepoID).
+			Set("repo_name", transfer.repo).
+			Set("number", transfer.number).
+			Set("transferred_at", transfer.dt).
+			Upsert("issue_id")
+		lib.ExecSQLWithErr(c, ctx, q+" where gha_issues_canonical.transferred_at <= excluded.transferred_at", args...)
+		if ctx.Debug > 0 {
+			from := "unknown"
+			if fromRepoName != nil && fromNumber != nil {
+				from = fmt.Sprintf("%s#%d", *fromRepoName, *fromNumber)
+			}
+			lib.Printf("Issue %d transferred from %s to %s#%d\n", transfer.issueID, from, transfer.repo, transfer.number)
+		}
+	}
+	lib.Printf("Saved %d issue transfers\n", len(transfers))
+}
+
+// prIssueLink - issue closed by a PR ("closes #N" linkage) from PR body or closed issue timeline
+type prIssueLink struct {
+	prIssueID int64
+	prRepo    string
+	prNumber  int
+	repo      string
+	number    int
+	source    string
+	dt        time.Time
+}
+
+// prBodyLinks - issues closed according to the most recent PR body seen in this sync
+type prBodyLinks struct {
+	dt    time.Time
+	links []prIssueLink
+}
+
+// addPRBodyLinks - remembers issues closed by a PR event body, only the most recent body of each PR is used
+func addPRBodyLinks(bodies map[int64]prBodyLinks, cfg *lib.IssueConfig) {
+	if !cfg.Pr {
+		return
+	}
+	if prev, ok := bodies[cfg.IssueID]; ok && prev.dt.After(cfg.CreatedAt) {
+		return
+	}
+	body := prBodyLinks{dt: cfg.CreatedAt, links: []prIssueLink{}}
+	for _, ref := range lib.ParseClosingRefs(cfg.GhIssue.GetBody(), cfg.Repo) {
+		if strings.EqualFold(ref.Repo, cfg.Repo) && ref.Number == cfg.Number {
+			continue
+		}
+		body.links = append(
+			body.links,
+			prIssueLink{
+				prIssueID: cfg.IssueID,
+				prRepo:    cfg.Repo,
+				prNumber:  cfg.Number,
+				repo:      ref.Repo,
+				number:    ref.Number,
+				source:    "body",
+				dt:        cfg.CreatedAt,
+			},
+		)
+	}
+	bodies[cfg.IssueID] = body
+}
+
+// getClosingPRs - returns PRs cross-referencing a given issue whose body closes it, from the issue timeline (GHA2DB_GHAPILINKEDTIMELINE)
+// It finds closing PRs from repositories that are not synced (for example other orgs)
+func getClosingPRs(gctx context.Context, ctx *lib.Ctx, gc []*github.Client, orgRepo string, number int, apiCalls *int, mtx *sync.Mutex) (links []prIssueLink) {
+	ary := strings.Split(orgRepo, "/")
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		var (
+			items []*github.Timeline
+			resp  *github.Response
+		)
+		ok, notFound := ghAPICall(
+			gctx, ctx, gc, "issue timeline", fmt.Sprintf("%s#%d", orgRepo, number), apiCalls, mtx,
+			func(c *github.Client) (*github.Response, error) {
+				var err error
+				items, resp, err = c.Issues.ListIssueTimeline(gctx, ary[0], ary[1], number, opt)
+				return resp, err
+			},
+		)
+		if !ok || notFound {
+			return
+		}
+		for _, item := range items {
+			if item.GetEvent() != "cross-referenced" || item.Source == nil || item.Source.Issue == nil || !item.Source.Issue.IsPullRequest() {
+				continue
+			}
+			pr := item.Source.Issue
+			prRepo := repoFromAPIURL(pr.GetRepositoryURL())
+			if prRepo == "" {
+				continue
+			}
+			for _, ref := range lib.ParseClosingRefs(pr.GetBody(), prRepo) {
+				if !strings.EqualFold(ref.Repo, orgRepo) || ref.Number != number {
+					continue
+				}
+				links = append(
+					links,
+					prIssueLink{
+						prIssueID: pr.GetID(),
+						prRepo:    prRepo,
+						prNumber:  pr.GetNumber(),
+						repo:      orgRepo,
+						number:    number,
+						source:    "timeline",
+						dt:        item.GetCreatedAt(),
+					},
+				)
+				break
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// savePRIssues - saves issues closed by PRs into gha_pr_issues, issue IDs are resolved from gha_issues
+// Body links of PRs seen in this sync replace their previous body links (closing keywords can be removed by editing PR body)
+// Timeline links never replace body links
+func savePRIssues(c *sql.DB, ctx *lib.Ctx, bodies map[int64]prBodyLinks, timeline []prIssueLink) {
+	if len(bodies) == 0 && len(timeline) == 0 {
+		return
+	}
+	save := func(link prIssueLink, replace bool) {
+		var issueID *int64
+		err := lib.QueryRowSQL(
+			c,
+			ctx,
+			"select id from gha_issues where dup_repo_name = $1 and number = $2 and is_pull_request = false "+
+				"order by updated_at desc, event_id desc limit 1",
+			link.repo,
+			link.number,
+		).Scan(&issueID)
+		if err != nil && err != sql.ErrNoRows {
+			lib.FatalOnError(err)
+		}
+		qb := lib.NewQB("gha_pr_issues").
+			Set("pr_issue_id", link.prIssueID).
+			Set("pr_repo_name", link.prRepo).
+			Set("pr_number", link.prNumber).
+			Set("issue_repo_name", link.repo).
+			Set("issue_number", link.number).
+			Set("issue_id", issueID).
+			Set("source", link.source).
+			Set("dt", link.dt)
+		var (
+			q    string
+			args []interface{}
+		)
+		if replace {
+			q, args = qb.Upsert("pr_issue_id", "issue_repo_name", "issue_number")
+		} else {
+			q, args = qb.InsertIgnore()
+		}
+		lib.ExecSQLWithErr(c, ctx, q, args...)
+	}
+	n := 0
+	for prIssueID, body := range bodies {
+		lib.ExecSQLWithErr(c, ctx, "delete from gha_pr_issues where pr_issue_id = $1 and source = 'body'", prIssueID)
+		for _, link := range body.links {
+			save(link, true)
+			n++
+		}
+	}
+	for _, link := range timeline {
+		save(link, false)
+		n++
+	}
+	lib.Printf("Saved %d PR linked issues (%d PRs checked, %d from closed issues timelines)\n", n, len(bodies), len(timeline))
+}
+
+// eventTypesFilter - returns issue event types filter: allow/deny lists are read from GHA2DB_EVENT_TYPES_YAML (when set)
+// GHA2DB_GHAPIEVENTTYPES and GHA2DB_GHAPISKIPEVENTTYPES override YAML lists, built-in list is used when there is no allow list
+func eventTypesFilter(ctx *lib.Ctx) *lib.EventTypesFilter {
+	var list lib.EventTypesList
+	if ctx.EventTypesYaml != "" {
+		dataPrefix := ctx.DataDir
+		if ctx.Local {
+			dataPrefix = "./"
+		}
+		data, err := lib.ReadFile(ctx, dataPrefix+ctx.EventTypesYaml)
+		lib.FatalOnError(err)
+		lib.FatalOnError(yaml.Unmarshal(data, &list))
+	}
+	if len(ctx.APIEventTypes) > 0 {
+		list.Allow = ctx.APIEventTypes
+	}
+	if len(ctx.APISkipEventTypes) > 0 {
+		list.Deny = ctx.APISkipEventTypes
+	}
+	return lib.NewEventTypesFilter(list.Allow, list.Deny)
+}
+
+// saveSkippedEvents - logs and saves numbers of skipped issue events per type of this sync run
+func saveSkippedEvents(c *sql.DB, ctx *lib.Ctx, eventTypes *lib.EventTypesFilter, dt time.Time) {
+	skipped := eventTypes.Skipped()
+	if len(skipped) == 0 {
+		return
+	}
+	counts := []string{}
+	for _, st := range skipped {
+		counts = append(counts, fmt.Sprintf("%s: %d", st.Type, st.Events))
+		q, args := lib.NewQB("gha_skipped_events").
+			Set("dt", dt).
+			Set("type", st.Type).
+			Set("events", st.Events).
+			Set("unknown", st.Unknown).
+			Upsert("dt", "type")
+		lib.ExecSQLWithErr(c, ctx, q, args...)
+	}
+	lib.Printf("Skipped issue events by type: %s\n", strings.Join(counts, ", "))
+}
+
+func syncEvents(ctx *lib.Ctx) {
+	dtStart := time.Now()
+	// Get common params
+	repos, isSingleRepo, singleRepo, gctx, gc, c, recentDt := getAPIParams(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+
+	// Date range mode
+	var (
+		dateRangeFrom *time.Time
+		dateRangeTo   *time.Time
+	)
+	isDateRange := false
+	dateRangeFromS := os.Getenv("DTFROM")
+	dateRangeToS := os.Getenv("DTTO")
+	if dateRangeFromS != "" {
+		tmp := lib.TimeParseAny(dateRangeFromS)
+		dateRangeFrom = &tmp
+		isDateRange = true
+	}
+	if dateRangeToS != "" {
+		tmp := lib.TimeParseAny(dateRangeToS)
+		dateRangeTo = &tmp
+		isDateRange = true
+	}
+
+	// Single milestone mode
+	isSingleMilestone := false
+	singleMilestone := os.Getenv("MILESTONE")
+	if singleMilestone != "" {
+		isSingleMilestone = true
+	}
+
+	// Single issue mode
+	isSingleIssue := false
+	singleIssue := 0
+	sSingleIssue := os.Getenv("ISSUE")
+	if sSingleIssue != "" {
+		var err error
+		singleIssue, err = strconv.Atoi(sSingleIssue)
+		if err == nil {
+			isSingleIssue = true
+		}
+	}
+
+	// Issue event types to process, skipped events are counted per type
+	eventTypes := eventTypesFilter(ctx)
+
+	// Get number of CPUs available
+	// GitHub don't like MT quering - they say that:
+	// 403 You have triggered an abuse detection mechanism. Please wait a few minutes before you try again
+	// So let's get all GitHub stuff one-after-another (ugly and slow) and then spawn threads to speedup
+	// Damn GitHub! - this could be working Number of CPU times faster! We're trying some hardcoded value: max pool size
+	// Seems like GitHub is not detecting abuse when using 16 threads, but it detects when using 32.
+	pool := lib.NewPool(ctx, 16)
+	nRepos := len(repos)
+	pr := lib.NewProgress(ctx, "ghapi2db events", nRepos, time.Duration(10)*time.Second)
+	lib.Printf("ghapi2db.go: Processing %d repos - GHAPI Events part\n", nRepos)
+
+	//opt := &github.ListOptions{}
+	opt := &github.ListOptions{PerPage: 100}
+	issues := make(map[int64]lib.IssueConfigAry)
+	var issuesMutex = &sync.Mutex{}
+	eids := make(map[int64][2]int64)
+	eidRepos := make(map[int64][]string)
+	var eidsMutex = &sync.Mutex{}
+	prs := make(map[int64]github.PullRequest)
+	var prsMutex = &sync.Mutex{}
+	labelChanges := []labelChange{}
+	var labelChangesMutex = &sync.Mutex{}
+	transfers := []issueTransfer{}
+	var transfersMutex = &sync.Mutex{}
+	prBodies := make(map[int64]prBodyLinks)
+	timelineLinks := []prIssueLink{}
+	timelineChecked := make(map[int64]struct{})
+	var prIssuesMutex = &sync.Mutex{}
+	maybeHide := lib.MaybeHideFuncTS(lib.GetHidden(ctx, lib.HideCfgFile))
+	apiCalls := 0
+	var apiCallsMutex = &sync.Mutex{}
+	pool.OnDone(func(checked int) {
+		// Get RateLimits info
+		hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gc, true)
+		pr.Report(checked, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+	})
+	for _, orgRepo := range repos {
+		orgRepo := orgRepo
+		pool.Submit(func() {
+			if isSingleRepo && orgRepo != singleRepo {
+				return
+			}
+			ary := strings.Split(orgRepo, "/")
+			if len(ary) < 2 {
+				return
+			}
+			org := ary[0]
+			repo := ary[1]
+			if org == "" || repo == "" {
+				return
+			}
+			gcfg := lib.IssueConfig{
+				Repo: orgRepo,
+			}
+			var (
+				err      error
+				events   []*github.IssueEvent
+				response *github.Response
+				pr       *github.PullRequest
+			)
+			nPages := 0
+			found := false
+			for {
+				got := false
+				for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+					hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+					if ctx.GitHubDebug > 0 {
+						lib.Printf("Issues Repo Events Try: %d, rem: %+v, waitPeriod: %+v, hint: %d\n", tr, rem, waitPeriod, hint)
+					}
+					if rem[hint] <= ctx.MinGHAPIPoints {
+						if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("API limit reached while getting events data, waiting %v (%d)\n", waitPeriod[hint], tr)
+							}
+							time.Sleep(time.Duration(1) * time.Second)
+							time.Sleep(waitPeriod[hint])
+							continue
+						} else {
+							if ctx.GHAPIErrorIsFatal {
+								lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting issues events data, aborting, don't want to wait %v", waitPeriod[hint])
+							} else {
+								lib.Printf("Error: API limit reached while getting issues events data, aborting, don't want to wait %v\n", waitPeriod[hint])
+								return
+							}
+						}
+					}
+					nPages++
+					if ctx.GitHubDebug > 0 {
+						lib.Printf("API call for issues events %s (%d), remaining GHAPI points %+v, hint: %d\n", orgRepo, nPages, rem, hint)
+					}
+					apiCallsMutex.Lock()
+					apiCalls++
+					apiCallsMutex.Unlock()
+					// Returns events in GHA format
+					//events, response, err = gc.Activity.ListRepositoryEvents(gctx, org, repo, opt)
+					// Returns events in Issue Event format (UI events)
+					lib.GHRateGateWait()
+					events, response, err = gc[hint].Issues.ListRepositoryEvents(gctx, org, repo, opt)
+					res := lib.HandlePossibleError(err, gcfg.String(), "Issues.ListRepositoryEvents")
+					if res != "" {
+						if res == lib.Abuse {
+							wait := lib.GHAbuseWait(err, tr)
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("GitHub API abuse detected (issues events), wait %v\n", wait)
+							}
+							if pool.Shrink() && ctx.GitHubDebug > 0 {
+								lib.Printf("Lower threads limit (issues events): %d/%d\n", pool.Running(), pool.Size())
+							}
+							lib.GHRateGateSleep(wait)
+						}
+						if res == lib.NotFound {
+							lib.Printf("Warning: not found: %s/%s\n", org, repo)
+							lib.RepoNotFound(c, ctx, orgRepo)
+							return
+						}
+						continue
+					} else {
+						if !found {
+							lib.RepoFound(c, ctx, orgRepo)
+							found = true
+						}
+						if pool.Grow() && ctx.GitHubDebug > 0 {
+							lib.Printf("Rise threads limit (issues events): %d/%d\n", pool.Running(), pool.Size())
+						}
+					}
+					got = true
+					break
+				}
+				if !got {
+					if ctx.GHAPIErrorIsFatal {
+						lib.FatalfWithCode(lib.ExitGitHubAPI, "GetRateLimit call failed %d times while getting events, aborting", ctx.MaxGHAPIRetry)
+					} else {
+						lib.Printf("Error: GetRateLimit call failed %d times while getting events, aborting\n", ctx.MaxGHAPIRetry)
+						return
+					}
+				}
+				minCreatedAt := time.Now()
+				maxCreatedAt := recentDt
+				for _, event := range events {
+					rawSnapshots.Write(lib.GHAPIRawIssueEvents, orgRepo, event)
+					createdAt := *event.CreatedAt
+					if createdAt.Before(minCreatedAt) {
+						minCreatedAt = createdAt
+					}
+					if createdAt.After(maxCreatedAt) {
+						maxCreatedAt = createdAt
+					}
+					if isDateRange {
+						if dateRangeFrom != nil && createdAt.Before(*dateRangeFrom) {
+							continue
+						}
+						if dateRangeTo != nil && createdAt.After(*dateRangeTo) {
+							continue
+						}
+					}
+					if event.Event == nil {
+						lib.Printf("Warning: Skipping event without type\n")
+						continue
+					}
+					eventType := *event.Event
+					allowed, first := eventTypes.Allowed(eventType)
+					if !allowed {
+						if first {
+							lib.Printf("Warning: skipping unknown event type %s (first seen for issue %s %d), add it to allow or deny list\n", eventType, orgRepo, *event.Issue.Number)
+						}
+						continue
+					}
+					issue := event.Issue
+					if isSingleIssue && (issue.Number == nil || *issue.Number != singleIssue) {
+						continue
+					}
+					if isSingleMilestone && (issue.Milestone == nil || issue.Milestone.Title == nil || *issue.Milestone.Title != singleMilestone) {
+						continue
+					}
+					if createdAt.Before(recentDt) {
+						continue
+					}
+					cfg := lib.IssueConfig{Repo: orgRepo}
+					eid := *event.ID
+					iid := *issue.ID
+					// Check for duplicate events
+					eidsMutex.Lock()
+					duplicate := false
+					_, o := eids[eid]
+					if o {
+						eids[eid] = [2]int64{iid, eids[eid][1] + 1}
+						eidRepos[eid] = append(eidRepos[eid], orgRepo)
+						duplicate = true
+					} else {
+						eids[eid] = [2]int64{iid, 1}
+						eidRepos[eid] = []string{orgRepo}
+					}
+					eidsMutex.Unlock()
+					if duplicate {
+						if ctx.Debug > 0 {
+							lib.Printf("Note: duplicate GH event %d, %v, %v\n", eid, eids[eid], eidRepos[eid])
+						}
+						return
+					}
+					if issue.Milestone != nil {
+						cfg.MilestoneID = issue.Milestone.ID
+					}
+					if issue.Assignee != nil {
+						cfg.AssigneeID = issue.Assignee.ID
+					}
+					if eventType == "renamed" {
+						issue.Title = event.Rename.To
+					}
+					cfg.EventID = *event.ID
+					cfg.IssueID = *issue.ID
+					cfg.EventType = eventType
+					cfg.CreatedAt = createdAt
+					cfg.GhIssue = issue
+					cfg.GhEvent = event
+					cfg.Number = *issue.Number
+					cfg.Pr = issue.IsPullRequest()
+					// Labels
+					cfg.LabelsMap = make(map[int64]string)
+					for _, label := range issue.Labels {
+						cfg.LabelsMap[*label.ID] = *label.Name
+					}
+					labelsAry := lib.Int64Ary{}
+					for label := range cfg.LabelsMap {
+						labelsAry = append(labelsAry, label)
+					}
+					sort.Sort(labelsAry)
+					l := len(labelsAry)
+					for i, label := range labelsAry {
+						if i == l-1 {
+							cfg.Labels += fmt.Sprintf("%d", label)
+						} else {
+							cfg.Labels += fmt.Sprintf("%d,", label)
+						}
+					}
+					// Assignees
+					cfg.AssigneesMap = make(map[int64]string)
+					for _, assignee := range issue.Assignees {
+						cfg.AssigneesMap[*assignee.ID] = *assignee.Login
+					}
+					assigneesAry := lib.Int64Ary{}
+					for assignee := range cfg.AssigneesMap {
+						assigneesAry = append(assigneesAry, assignee)
+					}
+					sort.Sort(assigneesAry)
+					l = len(assigneesAry)
+					for i, assignee := range assigneesAry {
+						if i == l-1 {
+							cfg.Assignees += fmt.Sprintf("%d", assignee)
+						} else {
+							cfg.Assignees += fmt.Sprintf("%d,", assignee)
+						}
+					}
+					// Label history
+					if change, ok := newLabelChange(&cfg, maybeHide); ok {
+						labelChangesMutex.Lock()
+						labelChanges = append(labelChanges, change)
+						labelChangesMutex.Unlock()
+					}
+					// Issue transferred into this repository
+					if transfer, ok := newIssueTransfer(&cfg, maybeHide); ok {
+						transfersMutex.Lock()
+						transfers = append(transfers, transfer)
+						transfersMutex.Unlock()
+					}
+					// Issues closed by PR ("closes #N" in PR body, closing PRs from closed issue timeline)
+					if cfg.Pr {
+						prIssuesMutex.Lock()
+						addPRBodyLinks(prBodies, &cfg)
+						prIssuesMutex.Unlock()
+					} else if ctx.APILinkedTimeline && eventType == "closed" {
+						prIssuesMutex.Lock()
+						_, checked := timelineChecked[cfg.IssueID]
+						timelineChecked[cfg.IssueID] = struct{}{}
+						prIssuesMutex.Unlock()
+						if !checked {
+							links := getClosingPRs(gctx, ctx, gc, orgRepo, cfg.Number, &apiCalls, apiCallsMutex)
+							prIssuesMutex.Lock()
+							timelineLinks = append(timelineLinks, links...)
+							prIssuesMutex.Unlock()
+						}
+					}
+					issuesMutex.Lock()
+					_, ok := issues[cfg.IssueID]
+					if ok {
+						issues[cfg.IssueID] = append(issues[cfg.IssueID], cfg)
+					} else {
+						issues[cfg.IssueID] = []lib.IssueConfig{cfg}
+					}
+					issuesMutex.Unlock()
+					if ctx.Debug > 1 {
+						lib.Printf("Processing %v\n", cfg)
+					} else if ctx.Debug == 1 {
+						lib.Printf("Processing %s issue number %d, event: %s, date: %s\n", cfg.Repo, cfg.Number, cfg.EventType, lib.ToYMDHMSDate(cfg.CreatedAt))
+					}
+					// Handle PR
+					if issue.IsPullRequest() {
+						prsMutex.Lock()
+						_, foundPR := prs[cfg.IssueID]
+						prsMutex.Unlock()
+						if !foundPR {
+							prNum := *issue.Number
+							got = false
+							for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+								hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+								if ctx.GitHubDebug > 0 {
+									lib.Printf("Get PR Try: %d, rem: %+v, waitPeriod: %+v, hint: %d\n", tr, rem, waitPeriod, hint)
+								}
+								if rem[hint] <= ctx.MinGHAPIPoints {
+									if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+										if ctx.GitHubDebug > 0 {
+											lib.Printf("API limit reached while getting PR data, waiting %v (%d)\n", waitPeriod[hint], tr)
+										}
+										time.Sleep(time.Duration(1) * time.Second)
+										time.Sleep(waitPeriod[hint])
+										continue
+									} else {
+										if ctx.GHAPIErrorIsFatal {
+											lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting PR data, aborting, don't want to wait %v", waitPeriod[hint])
+										} else {
+											lib.Printf("Error: API limit reached while getting PR data, aborting, don't want to wait %v\n", waitPeriod[hint])
+											return
+										}
+									}
+								}
+								if ctx.GitHubDebug > 0 {
+									lib.Printf("API call for %s PR: %d, remaining GHAPI points %+v, hint: %d\n", orgRepo, prNum, rem, hint)
+								}
+								apiCallsMutex.Lock()
+								apiCalls++
+								apiCallsMutex.Unlock()
+								lib.GHRateGateWait()
+								pr, _, err = gc[hint].PullRequests.Get(gctx, org, repo, prNum)
+								res := lib.HandlePossibleError(err, gcfg.String(), "PullRequests.Get")
+								if res != "" {
+									if res == lib.Abuse {
+										wait := lib.GHAbuseWait(err, tr)
+										if ctx.GitHubDebug > 0 {
+											lib.Printf("GitHub API abuse detected (get PR), wait %v\n", wait)
+										}
+										if pool.Shrink() && ctx.GitHubDebug > 0 {
+											lib.Printf("Lower threads limit (get PR): %d/%d\n", pool.Running(), pool.Size())
+										}
+										lib.GHRateGateSleep(wait)
+									}
+									continue
+								} else {
+									if pool.Grow() && ctx.GitHubDebug > 0 {
+										lib.Printf("Rise threads limit (get PR): %d/%d\n", pool.Running(), pool.Size())
+									}
+								}
+								got = true
+								break
+							}
+							if !got {
+								if ctx.GHAPIErrorIsFatal {
+									lib.FatalfWithCode(lib.ExitGitHubAPI, "GetRateLimit call failed %d times while getting PR, aborting", ctx.MaxGHAPIRetry)
+								} else {
+									lib.Printf("Error: GetRateLimit call failed %d times while getting PR, aborting\n", ctx.MaxGHAPIRetry)
+									return
+								}
+							}
+							if pr != nil {
+								rawSnapshots.Write(lib.GHAPIRawPullRequests, orgRepo, pr)
+								prsMutex.Lock()
+								prs[cfg.IssueID] = *pr
+								prsMutex.Unlock()
+							}
+						}
+					}
+				}
+				if ctx.Debug > 0 {
+					lib.Printf("%s: [%v - %v] < %v: %v\n", orgRepo, minCreatedAt, maxCreatedAt, recentDt, minCreatedAt.Before(recentDt))
+				}
+				if minCreatedAt.Before(recentDt) {
+					break
+				}
+				// Handle paging
+				if response.NextPage == 0 {
+					break
+				}
+				opt.Page = response.NextPage
+			}
+		})
+	}
+	// Usually all work happens on 'pool.Submit'
+	if ctx.Debug > 0 {
+		lib.Printf("Final GHAPI threads join\n")
+	}
+	lib.FatalOnError(pool.Wait())
+	pr.Final(pool.Finished(), "")
+
+	// API calls
+	lib.Printf("GH Repo Events/PRs API calls: %d\n", apiCalls)
+
+	// Label added/removed history
+	saveLabelHistory(c, ctx, labelChanges)
+
+	// Issues transferred between repositories
+	saveIssueTransfers(c, ctx, transfers)
+
+	// Issues closed by PRs
+	savePRIssues(c, ctx, prBodies, timelineLinks)
+
+	// Skipped event types counters
+	saveSkippedEvents(c, ctx, eventTypes, dtStart)
+
+	// Do final corrections
+	// manual sync: false
+	lib.SyncIssuesState(gctx, gc, ctx, c, issues, prs, false)
+}
+
+func syncLicenses(ctx *lib.Ctx) {
+	gctx, gcs := lib.GHClient(ctx)
+	c := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+	query := lib.RepoNamesQuery
+	if !ctx.ForceAPILicenses {
+		query += " and (license_key is null or license_key = '')"
+	}
+	repos := []string{}
+	repo := ""
+	rows := lib.QuerySQLWithErr(c, ctx, query)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&repo))
+		repos = append(repos, repo)
+	}
+	lib.FatalOnError(rows.Err())
+	repos = skipTombstoned(c, ctx, repos)
+	nRepos := len(repos)
+	lib.Printf("Checking license on %d repos\n", nRepos)
+	hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gcs, true)
+	allowed := 0
+	handleRate := func() (ok bool) {
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if wait[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API limit reached while getting licenses data, waiting %v\n", wait[hint])
+				}
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(wait[hint])
+			} else {
+				if ctx.GHAPIErrorIsFatal {
+					lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting licenses data, aborting, don't want to wait %v", wait[hint])
+				} else {
+					lib.Printf("Error: API limit reached while getting licenses data, aborting, don't want to wait %v\n", wait[hint])
+					return
+				}
+			}
+			hint, _, rem, wait = lib.GetRateLimits(gctx, ctx, gcs, true)
+		}
+		allowed = rem[hint] / 10
+		ok = true
+		return
+	}
+	if !handleRate() {
+		return
+	}
+	processed := 0
+	pr := lib.NewProgress(ctx, "ghapi2db licenses", nRepos, time.Duration(30)*time.Second)
+	mtx := &sync.Mutex{}
+	found := 0
+	notFound := 0
+	abuses := 0
+	iter := func(abused bool) (ok bool) {
+		if !abused {
+			processed++
+			allowed--
+		} else {
+			allowed = 0
+			abuses++
+		}
+		if allowed <= 0 {
+			hint, _, rem, wait = lib.GetRateLimits(gctx, ctx, gcs, true)
+			if !handleRate() {
+				return
+			}
+		}
+		pr.Report(processed, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+		ok = true
+		return
+	}
+	getLicense := func(orgRepo string) {
+		noLicense := func() {
+			query := fmt.Sprintf(
+				"update gha_repos set license_key = %s, license_name = %s, license_prob = %s, updated_at = %s where name = %s",
+				lib.NValue(1),
+				lib.NValue(2),
+				lib.NValue(3),
+				lib.NValue(4),
+				lib.NValue(5),
+			)
+			lib.ExecSQLWithErr(c, ctx, query, "not_found", "Not found", 0.0, time.Now(), orgRepo)
+			mtx.Lock()
+			notFound++
+			mtx.Unlock()
+		}
+		cl := gcs[hint]
+		ary := strings.Split(orgRepo, "/")
+		if len(ary) < 2 {
+			lib.Printf("WARNING: malformed repo name: '%s'\n", orgRepo)
+			return
+		}
+		org := ary[0]
+		repo := ary[1]
+		var license *github.RepositoryLicense
+		for {
+			lic, resp, err := cl.Repositories.License(gctx, org, repo)
+			if resp == nil {
+				lib.Printf("License API response is null for %s/%s, skipping\n", org, repo)
+				return
+			}
+			if resp.StatusCode == 404 {
+				lib.Printf("No license found for: %s/%s (404)\n", org, repo)
+				noLicense()
+				return
+			}
+			if resp.StatusCode >= 400 {
+				if resp.StatusCode == 403 {
+					lib.Printf("Licenses abuse detected on %s/%s, retrying\n", org, repo)
+					mtx.Lock()
+					if !iter(true) {
+						mtx.Unlock()
+						return
+					}
+					mtx.Unlock()
+					continue
+				} else {
+					lib.Printf("No license found for: %s/%s, skipping (%d)\n", org, repo, resp.StatusCode)
+				}
+				return
+			}
+			lib.FatalOnError(err)
+			if lic == nil {
+				lib.Printf("License is null for %s/%s, skipping\n", org, repo)
+				return
+			}
+			if lic.License == nil {
+				lib.Printf("No license found for: %s/%s (nil)\n", org, repo)
+				return
+			}
+			license = lic
+			break
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("%s license:%+v\n", orgRepo, license.License)
+		}
+		query := fmt.Sprintf(
+			"update gha_repos set license_key = %s, license_name = %s, license_prob = %s, updated_at = %s where name = %s",
+			lib.NValue(1),
+			lib.NValue(2),
+			lib.NValue(3),
+			lib.NValue(4),
+			lib.NValue(5),
+		)
+		lib.ExecSQLWithErr(c, ctx, query, license.License.Key, license.License.Name, 100.0, time.Now(), orgRepo)
+		mtx.Lock()
+		found++
+		mtx.Unlock()
+	}
+	// Processing stops when API points are exhausted and we don't want to wait for them
+	stop := false
+	pool := lib.NewPool(ctx, 0).RefreshEvery(20).OnDone(func(int) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !stop && !iter(false) {
+			stop = true
+		}
+	})
+	for _, repo := range repos {
+		mtx.Lock()
+		stopped := stop
+		mtx.Unlock()
+		if stopped {
+			break
+		}
+		repo := repo
+		pool.Submit(func() { getLicense(repo) })
+	}
+	lib.FatalOnError(pool.Wait())
+	if stop {
+		return
+	}
+	pr.Final(processed, "")
+	lib.Printf("Processed %d, found %d licenses, %d not found, abuses %d\n", processed, found, notFound, abuses)
+}
+
+func syncLangs(ctx *lib.Ctx) {
+	gctx, gcs := lib.GHClient(ctx)
+	c := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+	query := lib.RepoNamesQuery
+	if !ctx.ForceAPILangs {
+		query += " and name not in (select distinct repo_name from gha_repos_langs)"
+	}
+	repos := []string{}
+	repo := ""
+	rows := lib.QuerySQLWithErr(c, ctx, query)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&repo))
+		repos = append(repos, repo)
+	}
+	lib.FatalOnError(rows.Err())
+	repos = skipTombstoned(c, ctx, repos)
+	nRepos := len(repos)
+	lib.Printf("Checking programming languages on %d repos\n", nRepos)
+	hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gcs, true)
+	allowed := 0
+	handleRate := func() (ok bool) {
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if wait[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API limit reached while getting programming languages data, waiting %v\n", wait[hint])
+				}
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(wait[hint])
+			} else {
+				if ctx.GHAPIErrorIsFatal {
+					lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting programming languages data, aborting, don't want to wait %v", wait[hint])
+				} else {
+					lib.Printf("Error: API limit reached while getting programming languages data, aborting, don't want to wait %v\n", wait[hint])
+					return
+				}
+			}
+			hint, _, rem, wait = lib.GetRateLimits(gctx, ctx, gcs, true)
+		}
+		allowed = rem[hint] / 10
+		ok = true
+		return
+	}
+	if !handleRate() {
+		return
+	}
+	processed := 0
+	pr := lib.NewProgress(ctx, "ghapi2db languages", nRepos, time.Duration(30)*time.Second)
+	mtx := &sync.Mutex{}
+	found := 0
+	notFound := 0
+	abuses := 0
+	iter := func(abused bool) (ok bool) {
+		if !abused {
+			processed++
+			allowed--
+		} else {
+			allowed = 0
+			abuses++
+		}
+		if allowed <= 0 {
+			hint, _, rem, wait = lib.GetRateLimits(gctx, ctx, gcs, true)
+			if !handleRate() {
+				return
+			}
+		}
+		pr.Report(processed, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+		ok = true
+		return
+	}
+	getLangs := func(orgRepo string) {
+		noLangs := func() {
+			lib.ExecSQLWithErr(c, ctx, lib.InsertIgnore("into gha_repos_langs(repo_name, lang_name, lang_loc, lang_perc) "+lib.NValues(4)), orgRepo, "unknown", 0, 0.0)
+			mtx.Lock()
+			notFound++
+			mtx.Unlock()
+		}
+		cl := gcs[hint]
+		ary := strings.Split(orgRepo, "/")
+		if len(ary) < 2 {
+			lib.Printf("WARNING: malformed repo name: '%s'\n", orgRepo)
+			return
+		}
+		org := ary[0]
+		repo := ary[1]
+		var langs map[string]int
+		when := time.Now()
+		for {
+			ls, resp, err := cl.Repositories.ListLanguages(gctx, org, repo)
+			if resp == nil {
+				lib.Printf("Languages API response is null for %s/%s, skipping\n", org, repo)
+				return
+			}
+			if resp.StatusCode == 404 {
+				lib.Printf("No programming languages found for: %s/%s (404)\n", org, repo)
+				noLangs()
+				return
+			}
+			if resp.StatusCode >= 400 {
+				if resp.StatusCode == 403 {
+					lib.Printf("Languages abuse detected on %s/%s, retrying\n", org, repo)
+					mtx.Lock()
+					if !iter(true) {
+						mtx.Unlock()
+						return
+					}
+					mtx.Unlock()
+					continue
+				} else {
+					lib.Printf("No languages found for: %s/%s, skipping (%d)\n", org, repo, resp.StatusCode)
+				}
+				return
+			}
+			lib.FatalOnError(err)
+			if len(ls) == 0 {
+				lib.Printf("No programming languages found for: %s/%s (0)\n", org, repo)
+				noLangs()
+				return
+			}
+			langs = ls
+			break
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("%s languages: %+v\n", orgRepo, langs)
+		}
+		allLOC := 0
+		for _, loc := range langs {
+			allLOC += loc
+		}
+		if allLOC == 0 {
+			lib.Printf("All BOC sum to 0 for: %s/%s\n", org, repo)
+			noLangs()
+			return
+		}
+		lib.ExecSQLWithErr(c, ctx, "delete from gha_repos_langs where repo_name = "+lib.NValue(1), orgRepo)
+		for lang, loc := range langs {
+			perc := (float64(loc) * 100.0) / float64(allLOC)
+			lib.ExecSQLWithErr(c, ctx, "insert into gha_repos_langs(repo_name, lang_name, lang_loc, lang_perc, dt) "+lib.NValues(5), orgRepo, lang, loc, perc, when)
+		}
+		mtx.Lock()
+		found++
+		mtx.Unlock()
+	}
+	// Processing stops when API points are exhausted and we don't want to wait for them
+	stop := false
+	pool := lib.NewPool(ctx, 0).RefreshEvery(20).OnDone(func(int) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !stop && !iter(false) {
+			stop = true
+		}
+	})
+	for _, repo := range repos {
+		mtx.Lock()
+		stopped := stop
+		mtx.Unlock()
+		if stopped {
+			break
+		}
+		repo := repo
+		pool.Submit(func() { getLangs(repo) })
+	}
+	lib.FatalOnError(pool.Wait())
+	if stop {
+		return
+	}
+	pr.Final(processed, "")
+	lib.Printf("Processed %d, found languages on %d repos, on %d not found, abuses: %d\n", processed, found, notFound, abuses)
+}
+
+// Main - runs ghapi2db tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	dtStart := time.Now()
+	// Raw snapshots are closed also when sync fails, so objects fetched so far are kept
+	rawSnapshots = lib.NewGHAPIRaw(&ctx)
+	defer rawSnapshots.Close()
+	// Create artificial events
+	if !ctx.SkipGHAPI {
+		// Tables missing in databases created by older versions
+		c := lib.PgConn(&ctx)
+		lib.Migrate(c, &ctx)
+		lib.FatalOnError(c.Close())
+		if !ctx.SkipAPILicenses {
+			syncLicenses(&ctx)
+		}
+		if !ctx.SkipAPILangs {
+			syncLangs(&ctx)
+		}
+		if !ctx.SkipAPIEvents {
+			syncEvents(&ctx)
+		}
+		if !ctx.SkipAPICommits {
+			syncCommits(&ctx)
+		}
+		if ctx.APIWorkflowRuns {
+			syncWorkflowRuns(&ctx)
+		}
+		if len(ctx.APITrafficOrgs) > 0 {
+			syncTraffic(&ctx)
+		}
+		if ctx.APIForks > 0 {
+			syncForks(&ctx)
+		}
+		if ctx.APIMilestones {
+			syncMilestoneProgress(&ctx)
+		}
+		if ctx.APIReconcileActors > 0 {
+			reconcileActors(&ctx)
+		}
+	}
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,153 @@
+package hidedata
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func processHidden(ctx *lib.Ctx) {
+	configFile := lib.HideCfgFile
+	shaMap := lib.GetHidden(ctx, configFile)
+
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+
+	data, err := ioutil.ReadFile(dataPrefix + ctx.ProjectsYaml)
+	lib.FatalOnError(err)
+
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+
+	orders := []int{}
+	projectsMap := make(map[int]string)
+	for name, proj := range projects.Projects {
+		if lib.IsProjectDisabled(ctx, name, proj.Disabled) {
+			continue
+		}
+		orders = append(orders, proj.Order)
+		projectsMap[proj.Order] = name
+	}
+	sort.Ints(orders)
+
+	only := make(map[string]struct{})
+	onlyS := os.Getenv("ONLY")
+	bOnly := false
+	if onlyS != "" {
+		onlyA := strings.Split(onlyS, " ")
+		for _, item := range onlyA {
+			if item == "" {
+				continue
+			}
+			only[item] = struct{}{}
+		}
+		bOnly = true
+	}
+
+	tasks := [][3]string{}
+	dbs := []string{}
+	for _, order := range orders {
+		name := projectsMap[order]
+		if bOnly {
+			_, ok := only[name]
+			if !ok {
+				continue
+			}
+		}
+		proj := projects.Projects[name]
+		for sha, anon := range shaMap {
+			tasks = append(tasks, [3]string{proj.PDB, sha, anon})
+		}
+		dbs = append(dbs, proj.PDB)
+	}
+	lib.Printf("Processing databases: %+v\n", dbs)
+	thrN := lib.GetThreadsNum(ctx)
+	ch := make(chan bool)
+	nThreads := 0
+	for _, task := range tasks {
+		go func(ch chan bool, task [3]string) {
+			con := lib.PgConnDB(ctx, task[0])
+			defer func() { lib.FatalOnError(con.Close()) }()
+			for _, replace := range lib.HiddenColumns {
+				res := lib.ExecSQLWithErr(
+					con,
+					ctx,
+					fmt.Sprintf(
+						"update %s set %s = %s where encode(digest(%s, 'sha1'), 'hex') = %s",
+						replace.Table,
+						replace.Column,
+						lib.NValue(1),
+						replace.Column,
+						lib.NValue(2),
+					),
+					lib.AnyArray{
+						task[2],
+						task[1],
+					}...,
+				)
+				rows, err := res.RowsAffected()
+				lib.FatalOnError(err)
+				if rows > 0 {
+					lib.Printf("DB: %s, table: %s, column: %s, sha: %s, updated %d rows\n", task[0], replace.Table, replace.Column, task[1], rows)
+				}
+			}
+			ch <- true
+		}(ch, task)
+		nThreads++
+		if nThreads >= thrN {
+			<-ch
+			nThreads--
+		}
+	}
+	for nThreads > 0 {
+		<-ch
+		nThreads--
+	}
+}
+
+func hideData(ctx *lib.Ctx, args []string) {
+	shaMap := lib.GetHidden(ctx, lib.HideCfgFile)
+	added := false
+	for _, argo := range args {
+		arg := strings.TrimSpace(argo)
+		hash := sha1.New()
+		hash.Write([]byte(arg))
+		sha := hex.EncodeToString(hash.Sum(nil))
+		_, ok := shaMap[sha]
+		if ok {
+			lib.Printf("Skipping '%s', SHA1 '%s' - already added\n", arg, sha)
+			continue
+		}
+		shaMap[sha] = ""
+		added = true
+	}
+	if !added {
+		return
+	}
+	lib.FatalOnError(lib.SaveHidden(lib.HideCfgFile, shaMap))
+}
+
+// Main - runs hide_data tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	var ctx lib.Ctx
+	dtStart := time.Now()
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+	if len(os.Args) < 2 {
+		processHidden(&ctx)
+	} else {
+		hideData(&ctx, os.Args[1:])
+	}
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
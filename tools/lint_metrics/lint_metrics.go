@@ -0,0 +1,41 @@
+package lintmetrics
+
+import (
+	"os"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// lintProject - validates metrics, tags and columns YAMLs of a given project (GHA2DB_PROJECT when empty), returns number of problems found
+func lintProject(project string) int {
+	if project != "" {
+		lib.FatalOnError(os.Setenv("GHA2DB_PROJECT", project))
+	}
+	var ctx lib.Ctx
+	ctx.Init()
+	errs := lib.LintConfig(&ctx)
+	for _, err := range errs {
+		lib.Printf("%v\n", err)
+	}
+	lib.Printf("%s: %d problem(s) found in %s, %s, %s\n", ctx.Project, len(errs), ctx.MetricsYaml, ctx.TagsYaml, ctx.ColumnsYaml)
+	return len(errs)
+}
+
+// Main - runs lint_metrics tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	projects := os.Args[1:]
+	if len(projects) == 0 {
+		projects = []string{""}
+	}
+	problems := 0
+	for _, project := range projects {
+		problems += lintProject(project)
+	}
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+	if problems > 0 {
+		os.Exit(lib.ExitConfig)
+	}
+}
@@ -0,0 +1,116 @@
+package metricscoverage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// readDashboards - returns series tables referenced by each dashboard JSON from given files or directories (all *.json files in them)
+func readDashboards(ctx *lib.Ctx, paths []string) map[string][]string {
+	dashboards := make(map[string][]string)
+	for _, path := range paths {
+		files := []string{path}
+		info, err := os.Stat(path)
+		lib.FatalOnError(err)
+		if info.IsDir() {
+			entries, err := ioutil.ReadDir(path)
+			lib.FatalOnError(err)
+			files = []string{}
+			for _, entry := range entries {
+				if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		for _, file := range files {
+			data, err := lib.ReadFile(ctx, file)
+			lib.FatalOnError(err)
+			title, refs, err := lib.DashboardSeriesRefs(data)
+			if err != nil {
+				lib.Printf("%s: cannot parse dashboard JSON: %v\n", file, err)
+				continue
+			}
+			if ctx.Debug > 0 {
+				lib.Printf("%s: '%s': %d series tables: %v\n", file, title, len(refs), refs)
+			}
+			dashboards[file] = refs
+		}
+	}
+	return dashboards
+}
+
+// seriesTables - returns series tables present in the current project database (with GHA2DB_TSDB_SUFFIX stripped when set)
+func seriesTables(ctx *lib.Ctx) (tables []string) {
+	con := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select tablename from pg_catalog.pg_tables where schemaname = 'public' and tablename like 's%' order by tablename",
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	table := ""
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&table))
+		if ctx.TSDBSuffix != "" {
+			if !strings.HasSuffix(table, ctx.TSDBSuffix) {
+				continue
+			}
+			table = strings.TrimSuffix(table, ctx.TSDBSuffix)
+		}
+		tables = append(tables, table)
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// Main - runs metrics_coverage tool, os.Args are its command line (os.Args[0] is the tool name)
+// Arguments are Grafana dashboard JSON files or directories with them (default grafana/dashboards/<GHA2DB_PROJECT>/ in data directory)
+// Reports dashboards querying series tables that do not exist and series tables no dashboard queries, exits with 1 when any series is missing
+func Main() {
+	dtStart := time.Now()
+	var ctx lib.Ctx
+	ctx.Init()
+	paths := os.Args[1:]
+	if len(paths) == 0 {
+		if ctx.Project == "" {
+			lib.Printf("Arguments required: dashboard JSON files or directories, or GHA2DB_PROJECT to use grafana/dashboards/<project>/ from data directory\n")
+			os.Exit(lib.ExitConfig)
+		}
+		dataPrefix := ctx.DataDir
+		if ctx.Local {
+			dataPrefix = "./"
+		}
+		paths = []string{dataPrefix + "grafana/dashboards/" + ctx.Project + "/"}
+	}
+	dashboards := readDashboards(&ctx, paths)
+	tables := seriesTables(&ctx)
+	missing, unused := lib.MetricsCoverage(dashboards, tables)
+	names := []string{}
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nMissing := 0
+	for _, name := range names {
+		lib.Printf("%s: queries missing series: %s\n", name, strings.Join(missing[name], ", "))
+		nMissing += len(missing[name])
+	}
+	for _, table := range unused {
+		lib.Printf("Series not used by any dashboard: %s\n", table)
+	}
+	dtEnd := time.Now()
+	lib.Printf(
+		"%s: %d dashboards, %d series tables, %d missing series in %d dashboards, %d unused series, time: %v\n",
+		ctx.PgDB, len(dashboards), len(tables), nMissing, len(missing), len(unused), dtEnd.Sub(dtStart),
+	)
+	if nMissing > 0 {
+		os.Exit(lib.ExitConfig)
+	}
+}
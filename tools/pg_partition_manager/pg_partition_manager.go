@@ -0,0 +1,270 @@
+package pgpartitionmanager
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// partition - single monthly partition of a partitioned table
+type partition struct {
+	name  string
+	month time.Time
+	rows  int64
+}
+
+// partitionRe - monthly partition name suffix: table_pYYYYMM
+var partitionRe = regexp.MustCompile(`_p(\d{4})(\d{2})$`)
+
+// unpartitionedSuffix - suffix of the original table name while it is converted
+const unpartitionedSuffix = "_unpartitioned"
+
+// getPartitions - returns monthly partitions of a given table (sorted by month) and estimated number of rows in its default partition
+func getPartitions(con *sql.DB, ctx *lib.Ctx, pt *lib.PartitionedTable) (partitions []partition, defaultRows int64) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select c.relname, greatest(c.reltuples, 0)::bigint from pg_inherits i, pg_class c "+
+			"where i.inhrelid = c.oid and i.inhparent = $1::regclass order by c.relname",
+		pt.Table,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	var (
+		name string
+		n    int64
+	)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&name, &n))
+		if name == lib.DefaultPartitionName(pt.Table) {
+			defaultRows = n
+			continue
+		}
+		m := partitionRe.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		year, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		partitions = append(partitions, partition{name: name, month: time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), rows: n})
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// convertTable - converts a given table into partitioned table with monthly partitions, all in a single transaction
+// Sync must be stopped while converting (devel/sync_lock.sh), table is locked and its data is copied
+func convertTable(con *sql.DB, ctx *lib.Ctx, pt *lib.PartitionedTable) {
+	if !lib.TableExists(con, ctx, pt.Table) {
+		lib.Printf("%s: table does not exist, skipping\n", pt.Table)
+		return
+	}
+	if lib.IsPartitioned(con, ctx, pt.Table) {
+		lib.Printf("%s: already partitioned, skipping\n", pt.Table)
+		return
+	}
+	var refs int
+	lib.FatalOnError(
+		lib.QueryRowSQL(con, ctx, "select count(*) from pg_constraint where contype = 'f' and confrelid = $1::regclass", pt.Table).Scan(&refs),
+	)
+	if refs > 0 {
+		lib.Fatalf("%s: referenced by %d foreign keys (integrity mode), it cannot be partitioned", pt.Table, refs)
+	}
+
+	// Indexes to recreate, primary key is replaced by the one including partition column
+	var pkey string
+	err := lib.QueryRowSQL(con, ctx, "select conname from pg_constraint where contype = 'p' and conrelid = $1::regclass", pt.Table).Scan(&pkey)
+	if err != nil && err != sql.ErrNoRows {
+		lib.FatalOnError(err)
+	}
+	indexes := []string{}
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select indexdef from pg_indexes where schemaname = 'public' and tablename = $1 and indexname <> $2 order by indexname",
+		pt.Table,
+		pkey,
+	)
+	for rows.Next() {
+		var def string
+		lib.FatalOnError(rows.Scan(&def))
+		indexes = append(indexes, def)
+	}
+	lib.FatalOnError(rows.Err())
+	lib.FatalOnError(rows.Close())
+
+	var minDt, maxDt *time.Time
+	lib.FatalOnError(lib.QueryRowSQL(con, ctx, fmt.Sprintf("select min(%s), max(%s) from %s", pt.Column, pt.Column, pt.Table)).Scan(&minDt, &maxDt))
+	now := time.Now()
+	from, to := lib.MonthStart(now), lib.AddNIntervals(now, ctx.PartitionsAhead, lib.NextMonthStart, lib.PrevMonthStart)
+	if minDt != nil {
+		from = lib.MonthStart(*minDt)
+	}
+	if maxDt != nil && maxDt.After(to) {
+		to = *maxDt
+	}
+	lib.Printf("%s: converting to monthly partitions %s - %s, %d indexes\n", pt.Table, lib.ToYMDDate(from), lib.ToYMDDate(to), len(indexes))
+
+	dtStart := time.Now()
+	old := pt.Table + unpartitionedSuffix
+	tx, err := con.Begin()
+	lib.FatalOnError(err)
+	lib.ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("alter table %s rename to %s", pt.Table, old))
+	if pkey != "" {
+		lib.ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("alter index %s rename to %s", pkey, old+"_pkey"))
+	}
+	lib.ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("create table %s (like %s including defaults) partition by range(%s)", pt.Table, old, pt.Column))
+	lib.ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("alter table %s add primary key(%s)", pt.Table, pt.PartitionedKey()))
+	lib.ExecSQLTxWithErr(tx, ctx, fmt.Sprintf("create table %s partition of %s default", lib.DefaultPartitionName(pt.Table), pt.Table))
+	copied := int64(0)
+	for month := from; !month.After(to); month = lib.NextMonthStart(month) {
+		next := lib.NextMonthStart(month)
+		name := lib.PartitionName(pt.Table, month)
+		lib.ExecSQLTxWithErr(
+			tx,
+			ctx,
+			fmt.Sprintf("create table %s partition of %s for values from ('%s') to ('%s')", name, pt.Table, lib.ToYMDDate(month), lib.ToYMDDate(next)),
+		)
+		res := lib.ExecSQLTxWithErr(
+			tx,
+			ctx,
+			fmt.Sprintf("insert into %s select * from %s where %s >= $1 and %s < $2", pt.Table, old, pt.Column, pt.Column),
+			month,
+			next,
+		)
+		n, _ := res.RowsAffected()
+		copied += n
+		if ctx.Debug > 0 || n > 0 {
+			lib.Printf("%s: %s: %d rows\n", pt.Table, name, n)
+		}
+	}
+	lib.ExecSQLTxWithErr(tx, ctx, "drop table "+old)
+	for _, index := range indexes {
+		lib.ExecSQLTxWithErr(tx, ctx, index)
+	}
+	lib.FatalOnError(tx.Commit())
+	lib.ExecSQLWithErr(con, ctx, "analyze "+pt.Table)
+	lib.Printf("%s: converted, %d rows copied in %v\n", pt.Table, copied, time.Since(dtStart))
+}
+
+// maintainTable - creates future partitions, detaches partitions older than GHA2DB_PARTITIONS_DETACH_MONTHS into archive schema
+func maintainTable(con *sql.DB, ctx *lib.Ctx, pt *lib.PartitionedTable) {
+	if !lib.TableExists(con, ctx, pt.Table) || !lib.IsPartitioned(con, ctx, pt.Table) {
+		lib.Printf("%s: not partitioned, skipping (use 'convert')\n", pt.Table)
+		return
+	}
+	lib.CreateDefaultPartition(con, ctx, pt)
+	now := time.Now()
+	to := lib.AddNIntervals(now, ctx.PartitionsAhead, lib.NextMonthStart, lib.PrevMonthStart)
+	created := 0
+	for month := lib.MonthStart(now); !month.After(to); month = lib.NextMonthStart(month) {
+		if lib.CreatePartition(con, ctx, pt, month) {
+			created++
+		}
+	}
+	detached := 0
+	if ctx.PartitionsDetachMonths > 0 {
+		cutoff := lib.AddNIntervals(lib.MonthStart(now), -ctx.PartitionsDetachMonths, lib.NextMonthStart, lib.PrevMonthStart)
+		partitions, _ := getPartitions(con, ctx, pt)
+		for _, p := range partitions {
+			if lib.NextMonthStart(p.month).After(cutoff) {
+				break
+			}
+			if detached == 0 {
+				lib.ExecSQLWithErr(con, ctx, "create schema if not exists "+lib.PartitionsArchiveSchema)
+			}
+			lib.ExecSQLWithErr(con, ctx, fmt.Sprintf("alter table %s detach partition %s", pt.Table, p.name))
+			lib.ExecSQLWithErr(con, ctx, fmt.Sprintf("alter table %s set schema %s", p.name, lib.PartitionsArchiveSchema))
+			lib.Printf("%s: detached %s (~%d rows) into %s schema\n", pt.Table, p.name, p.rows, lib.PartitionsArchiveSchema)
+			detached++
+		}
+	}
+	lib.Printf("%s: created %d partitions, detached %d\n", pt.Table, created, detached)
+}
+
+// tableStatus - prints partitioning status of a given table
+func tableStatus(con *sql.DB, ctx *lib.Ctx, pt *lib.PartitionedTable) {
+	if !lib.TableExists(con, ctx, pt.Table) {
+		lib.Printf("%s: does not exist\n", pt.Table)
+		return
+	}
+	if !lib.IsPartitioned(con, ctx, pt.Table) {
+		lib.Printf("%s: not partitioned\n", pt.Table)
+		return
+	}
+	partitions, defaultRows := getPartitions(con, ctx, pt)
+	if len(partitions) == 0 {
+		lib.Printf("%s: partitioned by %s, no monthly partitions, default partition ~%d rows\n", pt.Table, pt.Column, defaultRows)
+		return
+	}
+	lib.Printf(
+		"%s: partitioned by %s, %d monthly partitions %s - %s, default partition ~%d rows\n",
+		pt.Table, pt.Column, len(partitions), partitions[0].name, partitions[len(partitions)-1].name, defaultRows,
+	)
+	if ctx.Debug > 0 {
+		for _, p := range partitions {
+			lib.Printf("%s: ~%d rows\n", p.name, p.rows)
+		}
+	}
+}
+
+// partitionManager - runs a given command on all (or given) partitionable tables
+func partitionManager(cmd string, tables []string) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+
+	selected := make(map[string]struct{})
+	for _, table := range tables {
+		selected[table] = struct{}{}
+	}
+	for i := range lib.PartitionedTables {
+		pt := &lib.PartitionedTables[i]
+		if len(selected) > 0 {
+			if _, ok := selected[pt.Table]; !ok {
+				continue
+			}
+			delete(selected, pt.Table)
+		}
+		switch cmd {
+		case "convert":
+			convertTable(con, &ctx, pt)
+		case "maintain":
+			maintainTable(con, &ctx, pt)
+		case "status":
+			tableStatus(con, &ctx, pt)
+		}
+	}
+	for table := range selected {
+		lib.Printf("%s: table cannot be partitioned, skipped\n", table)
+	}
+}
+
+// Main - runs pg_partition_manager tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	cmd := "maintain"
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+	if cmd != "convert" && cmd != "maintain" && cmd != "status" {
+		lib.Printf("Usage: %s [maintain|convert|status] [table1 table2 ...]\n", os.Args[0])
+		os.Exit(lib.ExitConfig)
+	}
+	tables := []string{}
+	if len(os.Args) > 2 {
+		tables = os.Args[2:]
+	}
+	partitionManager(cmd, tables)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,153 @@
+package reconcilestars
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+)
+
+// repoStars - number of stars counted from WatchEvents for a repo
+type repoStars struct {
+	id     int64
+	name   string
+	stored int
+}
+
+// getTopRepos - returns repos with the most stars counted from WatchEvents
+// Each actor is counted once per repo, so starring the same repo again after removing a star is not counted twice
+// Repo name is the most recent name used in events (repos can be renamed)
+func getTopRepos(con *sql.DB, ctx *lib.Ctx, limit int) (repos []repoStars) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select repo_id, (array_agg(dup_repo_name order by created_at desc))[1], count(distinct actor_id) "+
+			"from gha_events where type = 'WatchEvent' and repo_id > 0 "+
+			"group by repo_id order by 3 desc, 1 limit $1",
+		limit,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		var r repoStars
+		lib.FatalOnError(rows.Scan(&r.id, &r.name, &r.stored))
+		repos = append(repos, r)
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// getStargazers - returns stargazers_count from GitHub API, waits for API points when needed
+// Second returned value is false when the count cannot be fetched (repo deleted or API points exhausted)
+func getStargazers(gctx context.Context, gc []*github.Client, ctx *lib.Ctx, repoName string) (int, bool) {
+	ary := strings.Split(repoName, "/")
+	if len(ary) != 2 {
+		return 0, false
+	}
+	for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+		hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+		if rem[hint] <= ctx.MinGHAPIPoints {
+			if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+				lib.Printf("API limit reached while getting repo data, waiting %v (%d)\n", waitPeriod[hint], tr)
+				time.Sleep(time.Duration(1) * time.Second)
+				time.Sleep(waitPeriod[hint])
+				continue
+			}
+			lib.Printf("API limit reached while getting repo data, don't want to wait %v, stopping\n", waitPeriod[hint])
+			return 0, false
+		}
+		lib.GHRateGateWait()
+		repo, _, err := gc[hint].Repositories.Get(gctx, ary[0], ary[1])
+		res := lib.HandlePossibleError(err, repoName, "Repositories.Get")
+		if res != "" {
+			if res == lib.Abuse {
+				wait := lib.GHAbuseWait(err, tr)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("GitHub API abuse detected (repo), wait %v\n", wait)
+				}
+				lib.GHRateGateSleep(wait)
+			}
+			if res == lib.NotFound {
+				return 0, false
+			}
+			continue
+		}
+		if repo == nil || repo.StargazersCount == nil {
+			return 0, false
+		}
+		return *repo.StargazersCount, true
+	}
+	return 0, false
+}
+
+// reconcileStars - compares stars counted from WatchEvents with GitHub API stargazers_count for top repos
+// and records differences in gha_star_corrections
+func reconcileStars(limit int) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	lib.Migrate(con, &ctx)
+
+	// Connect to GitHub API
+	gctx, gc := lib.GHClient(&ctx)
+
+	repos := getTopRepos(con, &ctx, limit)
+	lib.Printf("Reconciling stars for %d repos\n", len(repos))
+	checked, corrected, total := 0, 0, 0
+	for _, r := range repos {
+		count, ok := getStargazers(gctx, gc, &ctx, r.name)
+		if !ok {
+			if ctx.Debug > 0 {
+				lib.Printf("Cannot get stargazers count for %s, skipping\n", r.name)
+			}
+			continue
+		}
+		correction := count - r.stored
+		if ctx.Debug > 0 {
+			lib.Printf("%s: stored %d, API %d, correction %d\n", r.name, r.stored, count, correction)
+		}
+		q, args := lib.NewQB("gha_star_corrections").
+			Set("repo_id", r.id).
+			Set("repo_name", r.name).
+			Set("stored_count", r.stored).
+			Set("api_count", count).
+			Set("correction", correction).
+			Set("checked_at", time.Now()).
+			Upsert("repo_id")
+		lib.ExecSQLWithErr(con, &ctx, q, args...)
+		checked++
+		if correction != 0 {
+			corrected++
+			total += correction
+		}
+	}
+	lib.Printf("Checked %d repos, %d needed correction, total correction: %d\n", checked, corrected, total)
+}
+
+// Main - runs reconcile_stars tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	// Default: 100 repos with the most stars
+	limit := 100
+	if len(os.Args) > 2 {
+		lib.Printf("Usage: %s [top_repos]\n", os.Args[0])
+		os.Exit(lib.ExitConfig)
+	}
+	if len(os.Args) > 1 {
+		v, err := strconv.Atoi(os.Args[1])
+		lib.FatalOnError(err)
+		limit = v
+	}
+	reconcileStars(limit)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
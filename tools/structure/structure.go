@@ -0,0 +1,53 @@
+package structure
+
+import (
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// Main - runs structure tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Sharding mode: create all shard databases with full structure first
+	if len(ctx.Shards) > 0 {
+		for _, shard := range ctx.Shards {
+			shardCtx := ctx.CopyContext()
+			shardCtx.PgDB = shard
+			lib.Printf("Shard %s\n", shard)
+			if lib.CreateDatabaseIfNeeded(shardCtx) {
+				lib.Structure(shardCtx)
+			} else {
+				lib.Printf("Shard database %s already exists, skipping its structure\n", shard)
+			}
+		}
+	}
+
+	// Create database if needed
+	createdDatabase := lib.CreateDatabaseIfNeeded(&ctx)
+
+	// If we are using existing database, then display warnings
+	// And ask for continue
+	if !createdDatabase {
+		if ctx.Table {
+			lib.Printf("This program will recreate DB structure (dropping all existing data)\n")
+		}
+		lib.Printf("Continue? (y/n) ")
+		c := lib.Mgetc(&ctx)
+		lib.Printf("\n")
+		if c == "y" {
+			lib.Structure(&ctx)
+		}
+	} else {
+		lib.Structure(&ctx)
+	}
+	// Union views over all shards in the main database (no-op without shards)
+	lib.ShardsUnion(&ctx)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
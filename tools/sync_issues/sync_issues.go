@@ -0,0 +1,381 @@
+package syncissues
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"github.com/google/go-github/v38/github"
+)
+
+// Sync issues state given by query from GHA2DB_ISSUES_SYNC_SQL env
+// Possible dynamic replacements inside the query via
+// FROM1=var1 TO1=val1, FROM2=..., TO2=..., ...
+func syncIssues(ctx *lib.Ctx) {
+	// Connect to GitHub API
+	gctx, gc := lib.GHClient(ctx)
+
+	// Connect to Postgres DB
+	c := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(c.Close()) }()
+	// Tables and columns missing in databases created by older versions (gha_events.project)
+	lib.Migrate(c, ctx)
+
+	// Get SQL that will return list of issue numbers to sync
+	// each issue must be full_repo_name, number
+	// For example kubernetes/kubernetes, 60172
+	sql := os.Getenv("GHA2DB_ISSUES_SYNC_SQL")
+	if sql == "" {
+		lib.Printf("You have to provide a SQL query to get a list of issue numbers to sync. Use GHA2DB_ISSUES_SYNC_SQL environment variable for this")
+		lib.Fatalf("no sync issues sql query provided")
+	}
+
+	i := 1
+	for {
+		from := os.Getenv(fmt.Sprintf("FROM%d", i))
+		to := os.Getenv(fmt.Sprintf("TO%d", i))
+		if from != "" {
+			sql = strings.Replace(sql, from, to, -1)
+			i++
+		} else {
+			break
+		}
+	}
+
+	// Execute SQL
+	rows := lib.QuerySQLWithErr(c, ctx, sql)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	numbers := []int{}
+	repos := []string{}
+	number := 0
+	repo := ""
+	seen := make(map[string]struct{})
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&repo, &number))
+		key := fmt.Sprintf("%s:%d", repo, number)
+		_, ok := seen[key]
+		if !ok {
+			numbers = append(numbers, number)
+			repos = append(repos, repo)
+			seen[key] = struct{}{}
+		} else {
+			if ctx.Debug > 0 {
+				lib.Printf("Duplicated issue: %s\n", key)
+			}
+		}
+	}
+	lib.FatalOnError(rows.Err())
+	nNumbers := len(numbers)
+	lib.Printf("sync_issues.go: Processing %d issues - GHAPI part\n", nNumbers)
+
+	// Get number of CPUs available
+	thrN := lib.GetThreadsNum(ctx)
+	// GitHub don't like MT quering - they say that:
+	// 403 You have triggered an abuse detection mechanism. Please wait a few minutes before you try again
+	// So let's get all GitHub stuff one-after-another (ugly and slow) and then spawn threads to speedup
+	// Damn GitHub! - this could be working Number of CPU times faster! We're trying some hardcoded value: maxThreads
+	// Seems like GitHub is not detecting abuse when using 16 threads, but it detects when using 32.
+	maxThreads := 16
+	if maxThreads > thrN {
+		maxThreads = thrN
+	}
+	allowedThrN := maxThreads
+	var thrMutex = &sync.Mutex{}
+	ch := make(chan bool)
+	nThreads := 0
+	dtStart := time.Now()
+	lastTime := dtStart
+	checked := 0
+	issues := make(map[int64]lib.IssueConfigAry)
+	var issuesMutex = &sync.Mutex{}
+	prs := make(map[int64]github.PullRequest)
+	var prsMutex = &sync.Mutex{}
+
+	// Process issues
+	for idx := range numbers {
+		go func(ch chan bool, orgRepo string, number int) {
+			artificialUID := int64(-1)
+			artificialLogin := "devstats-sync"
+			artificialEvent := &github.IssueEvent{Actor: &github.User{ID: &artificialUID, Login: &artificialLogin}}
+			ary := strings.Split(orgRepo, "/")
+			if len(ary) < 2 {
+				ch <- false
+				return
+			}
+			org := ary[0]
+			repo := ary[1]
+			if org == "" || repo == "" {
+				ch <- false
+				return
+			}
+			gcfg := lib.IssueConfig{
+				Repo: orgRepo,
+			}
+			var (
+				err   error
+				issue *github.Issue
+				pr    *github.PullRequest
+			)
+			got := false
+			for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+				hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("Get Issue Try: %d, rem: %+v, waitPeriod: %+v, hint: %d\n", tr, rem, waitPeriod, hint)
+				}
+				if rem[hint] <= ctx.MinGHAPIPoints {
+					if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+						lib.Printf("API limit reached while getting issue data, waiting %v (%d)\n", waitPeriod[hint], tr)
+						time.Sleep(time.Duration(1) * time.Second)
+						time.Sleep(waitPeriod[hint])
+						continue
+					} else {
+						lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting issue data, aborting, don't want to wait %v", waitPeriod[hint])
+					}
+				}
+				if ctx.GitHubDebug > 0 {
+					lib.Printf("API call for Issue %s %d, remaining GHAPI points %+v, hint: %d\n", orgRepo, number, rem, hint)
+				}
+				lib.GHRateGateWait()
+				issue, _, err = gc[hint].Issues.Get(gctx, org, repo, number)
+				res := lib.HandlePossibleError(err, gcfg.String(), "Issues.Get")
+				if res != "" {
+					if res == lib.Abuse {
+						wait := lib.GHAbuseWait(err, tr)
+						thrMutex.Lock()
+						if ctx.GitHubDebug > 0 {
+							lib.Printf("GitHub API abuse detected (issue), wait %v\n", wait)
+						}
+						if allowedThrN > 1 {
+							allowedThrN--
+							if ctx.GitHubDebug > 0 {
+								lib.Printf("Lower threads limit (issue): %d/%d\n", nThreads, allowedThrN)
+							}
+						}
+						thrMutex.Unlock()
+						lib.GHRateGateSleep(wait)
+					}
+					if res == lib.NotFound {
+						lib.Printf("Warning: not found: %s/%s %d\n", org, repo, number)
+						ch <- false
+						return
+					}
+					if res == lib.IssueIsDeleted {
+						lib.Printf("Warning: issue is deleted: %s/%s %d\n", org, repo, number)
+						ch <- false
+						return
+					}
+					if res == lib.MovedPermanently {
+						lib.Printf("Warning: This issue has been transferred: %s/%s %d\n", org, repo, number)
+						ch <- false
+						return
+					}
+					continue
+				} else {
+					thrMutex.Lock()
+					if allowedThrN < maxThreads {
+						allowedThrN++
+						if ctx.GitHubDebug > 0 {
+							lib.Printf("Rise threads limit (issue): %d/%d\n", nThreads, allowedThrN)
+						}
+					}
+					thrMutex.Unlock()
+				}
+				got = true
+				break
+			}
+			if !got {
+				lib.FatalfWithCode(lib.ExitGitHubAPI, "GetRateLimit call failed %d times while getting issue, aborting", ctx.MaxGHAPIRetry)
+			}
+
+			// Notice: If the issue number changes, it means that the issue has been transferred to a new repository.
+			currNumber := issue.GetNumber()
+			currRepo := issue.GetRepository()
+			if currNumber != 0 && currNumber != number {
+				lib.Printf("Warning: This issue has been transferred from %s/%s#%d to %s/%s#%d\n",
+					org, repo, number, currRepo.GetOwner().GetName(), currRepo.GetName(), currNumber)
+				ch <- false
+				return
+			}
+
+			cfg := lib.IssueConfig{Repo: orgRepo}
+			if issue.Milestone != nil {
+				cfg.MilestoneID = issue.Milestone.ID
+			}
+			if issue.Assignee != nil {
+				cfg.AssigneeID = issue.Assignee.ID
+			}
+			cfg.EventType = "sync"
+			cfg.CreatedAt = time.Now()
+			cfg.GhIssue = issue
+			cfg.GhEvent = artificialEvent
+			cfg.Number = *issue.Number
+			cfg.IssueID = *issue.ID
+			cfg.EventID = time.Now().UnixNano() / 31622
+			cfg.GhEvent.ID = &cfg.EventID
+			cfg.Pr = issue.IsPullRequest()
+			// Labels
+			cfg.LabelsMap = make(map[int64]string)
+			for _, label := range issue.Labels {
+				cfg.LabelsMap[*label.ID] = *label.Name
+			}
+			labelsAry := lib.Int64Ary{}
+			for label := range cfg.LabelsMap {
+				labelsAry = append(labelsAry, label)
+			}
+			sort.Sort(labelsAry)
+			l := len(labelsAry)
+			for i, label := range labelsAry {
+				if i == l-1 {
+					cfg.Labels += fmt.Sprintf("%d", label)
+				} else {
+					cfg.Labels += fmt.Sprintf("%d,", label)
+				}
+			}
+			// Assignees
+			cfg.AssigneesMap = make(map[int64]string)
+			for _, assignee := range issue.Assignees {
+				cfg.AssigneesMap[*assignee.ID] = *assignee.Login
+			}
+			assigneesAry := lib.Int64Ary{}
+			for assignee := range cfg.AssigneesMap {
+				assigneesAry = append(assigneesAry, assignee)
+			}
+			sort.Sort(assigneesAry)
+			l = len(assigneesAry)
+			for i, assignee := range assigneesAry {
+				if i == l-1 {
+					cfg.Assignees += fmt.Sprintf("%d", assignee)
+				} else {
+					cfg.Assignees += fmt.Sprintf("%d,", assignee)
+				}
+			}
+			issuesMutex.Lock()
+			_, ok := issues[cfg.IssueID]
+			if ok {
+				issues[cfg.IssueID] = append(issues[cfg.IssueID], cfg)
+			} else {
+				issues[cfg.IssueID] = []lib.IssueConfig{cfg}
+			}
+			issuesMutex.Unlock()
+			if ctx.Debug > 0 {
+				lib.Printf("Processing %v\n", cfg)
+			} else if ctx.Debug == 1 {
+				lib.Printf("Processing issue number %d\n", cfg.Number)
+			}
+
+			// Handle PR
+			if issue.IsPullRequest() {
+				prsMutex.Lock()
+				_, foundPR := prs[cfg.IssueID]
+				prsMutex.Unlock()
+				if !foundPR {
+					prNum := *issue.Number
+					got = false
+					for tr := 0; tr < ctx.MaxGHAPIRetry; tr++ {
+						hint, _, rem, waitPeriod := lib.GetRateLimits(gctx, ctx, gc, true)
+						if ctx.GitHubDebug > 0 {
+							lib.Printf("Get PR Try: %d, rem: %+v, waitPeriod: %+v, hint: %d\n", tr, rem, waitPeriod, hint)
+						}
+						if rem[hint] <= ctx.MinGHAPIPoints {
+							if waitPeriod[hint].Seconds() <= float64(ctx.MaxGHAPIWaitSeconds) {
+								lib.Printf("API limit reached while getting PR data, waiting %v (%d)\n", waitPeriod[hint], tr)
+								time.Sleep(time.Duration(1) * time.Second)
+								time.Sleep(waitPeriod[hint])
+								continue
+							} else {
+								lib.FatalfWithCode(lib.ExitGitHubAPI, "API limit reached while getting PR data, aborting, don't want to wait %v", waitPeriod[hint])
+							}
+						}
+						if ctx.GitHubDebug > 0 {
+							lib.Printf("API call for PR %s %d, remaining GHAPI points %+v, hint: %d\n", orgRepo, prNum, rem, hint)
+						}
+						lib.GHRateGateWait()
+						pr, _, err = gc[hint].PullRequests.Get(gctx, org, repo, prNum)
+						res := lib.HandlePossibleError(err, gcfg.String(), "PullRequests.Get")
+						if res != "" {
+							if res == lib.Abuse {
+								wait := lib.GHAbuseWait(err, tr)
+								thrMutex.Lock()
+								if ctx.GitHubDebug > 0 {
+									lib.Printf("GitHub API abuse detected (get PR), wait %v\n", wait)
+								}
+								if allowedThrN > 1 {
+									allowedThrN--
+									if ctx.GitHubDebug > 0 {
+										lib.Printf("Lower threads limit (get PR): %d/%d\n", nThreads, allowedThrN)
+									}
+								}
+								thrMutex.Unlock()
+								lib.GHRateGateSleep(wait)
+							}
+							continue
+						} else {
+							thrMutex.Lock()
+							if allowedThrN < maxThreads {
+								allowedThrN++
+								if ctx.GitHubDebug > 0 {
+									lib.Printf("Rise threads limit (get PR): %d/%d\n", nThreads, allowedThrN)
+								}
+							}
+							thrMutex.Unlock()
+						}
+						got = true
+						break
+					}
+					if !got {
+						lib.FatalfWithCode(lib.ExitGitHubAPI, "GetRateLimit call failed %d times while getting PR, aborting", ctx.MaxGHAPIRetry)
+					}
+					if pr != nil {
+						prsMutex.Lock()
+						prs[cfg.IssueID] = *pr
+						prsMutex.Unlock()
+					}
+				}
+			}
+			/* end handle pr */
+			// Synchronize go routine
+			ch <- true
+		}(ch, repos[idx], numbers[idx])
+		nThreads++
+		for nThreads >= allowedThrN {
+			<-ch
+			nThreads--
+			checked++
+			// Get RateLimits info
+			hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gc, true)
+			lib.ProgressInfo(checked, nNumbers, dtStart, &lastTime, time.Duration(10)*time.Second, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+		}
+	}
+	// Usually all work happens on '<-ch'
+	if ctx.Debug > 0 {
+		lib.Printf("Final GHAPI threads join\n")
+	}
+	for nThreads > 0 {
+		<-ch
+		nThreads--
+		checked++
+		// Get RateLimits info
+		hint, _, rem, wait := lib.GetRateLimits(gctx, ctx, gc, true)
+		lib.ProgressInfo(checked, nNumbers, dtStart, &lastTime, time.Duration(10)*time.Second, fmt.Sprintf("API points: %+v, resets in: %+v, hint: %d", rem, wait, hint))
+	}
+
+	// Do final corrections
+	// manual sync: true
+	lib.SyncIssuesState(gctx, gc, ctx, c, issues, prs, true)
+}
+
+// Main - runs sync_issues tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+	dtStart := time.Now()
+	syncIssues(&ctx)
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,290 @@
+package testmetrics
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	"gopkg.in/yaml.v2"
+)
+
+// metricTests - single metric tests file, for example metrics/kubernetes/tests/events.yaml
+// fixtures are loaded into a temporary database created with the full DevStats structure
+// then every case runs metric SQL for all its periods and compares returned rows with expected ones
+type metricTests struct {
+	Project   string                              `yaml:"project"`   // project name, metric SQLs are read from metrics/{{project}}/, default GHA2DB_PROJECT or project from tests path
+	Structure *bool                               `yaml:"structure"` // create DevStats tables structure in the temporary database, default true
+	SQLs      []string                            `yaml:"sqls"`      // fixture SQL files to execute (relative to tests file directory)
+	Tables    map[string][]map[string]interface{} `yaml:"tables"`    // fixture rows: table name -> list of rows (column -> value)
+	Cases     []metricTestCase                    `yaml:"cases"`     // metric test cases
+}
+
+// metricTestCase - metric SQL and expected results for given periods
+type metricTestCase struct {
+	Name      string            `yaml:"name"`      // case name, defaults to SQL name
+	SQL       string            `yaml:"sql"`       // metric SQL name without .sql extension, for example "events"
+	Replaces  map[string]string `yaml:"replaces"`  // additional replacements, for example {{period}}: "1 week"
+	Tolerance float64           `yaml:"tolerance"` // maximum allowed numeric difference, default 1e-6
+	Periods   []metricPeriod    `yaml:"periods"`   // periods to compute
+}
+
+// metricPeriod - single period to compute and its expected rows
+type metricPeriod struct {
+	From     string          `yaml:"from"`     // YYYY-MM-DD[ HH:MI:SS]
+	To       string          `yaml:"to"`       // YYYY-MM-DD[ HH:MI:SS]
+	N        int             `yaml:"n"`        // {{n}} replacement - number of periods in a moving average, default 1
+	Expected [][]interface{} `yaml:"expected"` // expected rows, null values are expected as empty strings
+}
+
+// loadFixtures - creates structure and loads fixture SQLs and rows into a temporary database
+func loadFixtures(ctx *lib.Ctx, tests *metricTests, dir string) {
+	if tests.Structure == nil || *tests.Structure {
+		lib.Structure(ctx)
+	}
+	con := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	for _, sqlFile := range tests.SQLs {
+		bytes, err := lib.ReadFile(ctx, filepath.Join(dir, sqlFile))
+		lib.FatalOnError(err)
+		lib.ExecSQLWithErr(con, ctx, string(bytes))
+	}
+	tables := []string{}
+	for table := range tests.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		for _, row := range tests.Tables[table] {
+			cols := []string{}
+			for col := range row {
+				cols = append(cols, col)
+			}
+			sort.Strings(cols)
+			qb := lib.NewQB(table)
+			for _, col := range cols {
+				qb.Set(col, row[col])
+			}
+			q, args := qb.Insert()
+			lib.ExecSQLWithErr(con, ctx, q, args...)
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("Loaded %d %s fixture rows\n", len(tests.Tables[table]), table)
+		}
+	}
+}
+
+// queryRows - executes query and returns all rows as strings, nulls are returned as empty strings
+func queryRows(con *sql.DB, ctx *lib.Ctx, query string) (ret [][]string, err error) {
+	rows, err := lib.QuerySQLLogErr(con, ctx, query)
+	if err != nil {
+		return
+	}
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	vals := make([]interface{}, len(columns))
+	for i := range columns {
+		vals[i] = new(sql.RawBytes)
+	}
+	for rows.Next() {
+		err = rows.Scan(vals...)
+		if err != nil {
+			return
+		}
+		row := []string{}
+		for _, val := range vals {
+			row = append(row, string(*val.(*sql.RawBytes)))
+		}
+		ret = append(ret, row)
+	}
+	err = rows.Err()
+	return
+}
+
+// valuesEqual - compares got and expected values, numeric values are compared with tolerance
+func valuesEqual(got, expected string, tolerance float64) bool {
+	if got == expected {
+		return true
+	}
+	g, errG := strconv.ParseFloat(got, 64)
+	e, errE := strconv.ParseFloat(expected, 64)
+	if errG != nil || errE != nil {
+		return false
+	}
+	return math.Abs(g-e) <= tolerance
+}
+
+// compareRows - returns description of all differences between got and expected rows
+func compareRows(got [][]string, expected [][]interface{}, tolerance float64) (diffs []string) {
+	if len(got) != len(expected) {
+		diffs = append(diffs, fmt.Sprintf("expected %d rows, got %d", len(expected), len(got)))
+	}
+	for i := 0; i < len(got) && i < len(expected); i++ {
+		if len(got[i]) != len(expected[i]) {
+			diffs = append(diffs, fmt.Sprintf("row #%d: expected %d columns, got %d: %v", i+1, len(expected[i]), len(got[i]), got[i]))
+			continue
+		}
+		for j, exp := range expected[i] {
+			sExp := ""
+			if exp != nil {
+				sExp = fmt.Sprintf("%v", exp)
+			}
+			if !valuesEqual(got[i][j], sExp, tolerance) {
+				diffs = append(diffs, fmt.Sprintf("row #%d column #%d: expected '%s', got '%s'", i+1, j+1, sExp, got[i][j]))
+			}
+		}
+	}
+	return
+}
+
+// runCases - runs all metric test cases on already loaded fixtures, returns number of passed and failed periods
+func runCases(ctx *lib.Ctx, tests *metricTests, dataPrefix, excludeBots string) (passed, failed int) {
+	con := lib.PgConn(ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	for _, cs := range tests.Cases {
+		name := cs.Name
+		if name == "" {
+			name = cs.SQL
+		}
+		bytes, err := lib.ReadFile(ctx, dataPrefix+"metrics/"+tests.Project+"/"+cs.SQL+".sql")
+		lib.FatalOnError(err)
+		sqlQueryOrig := strings.Replace(string(bytes), "{{exclude_bots}}", excludeBots, -1)
+		for from, to := range cs.Replaces {
+			sqlQueryOrig = strings.Replace(sqlQueryOrig, from, to, -1)
+		}
+		tolerance := cs.Tolerance
+		if tolerance <= 0 {
+			tolerance = 1e-6
+		}
+		for _, period := range cs.Periods {
+			from := lib.TimeParseAny(period.From)
+			to := lib.TimeParseAny(period.To)
+			n := period.N
+			if n < 1 {
+				n = 1
+			}
+			sqlQuery := strings.Replace(sqlQueryOrig, "{{from}}", lib.ToYMDHMSDate(from), -1)
+			sqlQuery = strings.Replace(sqlQuery, "{{to}}", lib.ToYMDHMSDate(to), -1)
+			sqlQuery = strings.Replace(sqlQuery, "{{n}}", strconv.Itoa(n)+".0", -1)
+			sqlQuery = strings.Replace(sqlQuery, "{{range}}", lib.RangeHours(from, to), -1)
+			sqlQuery = strings.Replace(sqlQuery, "{{project_scale}}", "1.0", -1)
+			sqlQuery = strings.Replace(sqlQuery, "{{rnd}}", lib.RandString(), -1)
+			got, err := queryRows(con, ctx, sqlQuery)
+			var diffs []string
+			if err != nil {
+				diffs = []string{err.Error()}
+			} else {
+				diffs = compareRows(got, period.Expected, tolerance)
+			}
+			if len(diffs) == 0 {
+				passed++
+				lib.Printf("ok   %s/%s %s - %s\n", tests.Project, name, period.From, period.To)
+				continue
+			}
+			failed++
+			lib.Printf("FAIL %s/%s %s - %s:\n", tests.Project, name, period.From, period.To)
+			for _, diff := range diffs {
+				lib.Printf("  %s\n", diff)
+			}
+			if ctx.Debug > 0 {
+				lib.Printf("Got: %+v\n", got)
+			}
+		}
+	}
+	return
+}
+
+// testMetricsFile - runs all test cases from a single tests file on a fresh temporary database
+func testMetricsFile(ctx *lib.Ctx, fn, project, dataPrefix, excludeBots string) (passed, failed int) {
+	bytes, err := lib.ReadFile(ctx, fn)
+	lib.FatalOnError(err)
+	var tests metricTests
+	lib.FatalOnError(yaml.Unmarshal(bytes, &tests))
+	if tests.Project == "" {
+		tests.Project = project
+	}
+	if tests.Project == "" {
+		lib.Fatalf("%s: unknown project, set 'project' in the tests file or GHA2DB_PROJECT", fn)
+	}
+
+	// Each tests file gets its own database, so fixtures cannot interfere
+	db := ctx.PgDB
+	ctx.PgDB = "test_metrics_" + lib.RandString()
+	defer func() { ctx.PgDB = db }()
+	if !lib.CreateDatabaseIfNeeded(ctx) {
+		lib.Fatalf("temporary database %s already exists", ctx.PgDB)
+	}
+	defer func() { lib.DropDatabaseIfExists(ctx) }()
+	if ctx.Debug > 0 {
+		lib.Printf("%s: using temporary database %s\n", fn, ctx.PgDB)
+	}
+	loadFixtures(ctx, &tests, filepath.Dir(fn))
+	return runCases(ctx, &tests, dataPrefix, excludeBots)
+}
+
+func testMetrics(args []string) bool {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// Local or cron mode?
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+
+	// Read bots exclusion partial SQL
+	bytes, err := lib.ReadFile(&ctx, dataPrefix+"util_sql/exclude_bots.sql")
+	lib.FatalOnError(err)
+	excludeBots := string(bytes)
+
+	passed, failed := 0, 0
+	for _, arg := range args {
+		// Argument is either a tests file or a project name (then all metrics/project/tests/*.yaml are used)
+		files := []string{arg}
+		project := ctx.Project
+		if !strings.HasSuffix(arg, ".yaml") && !strings.HasSuffix(arg, ".yml") {
+			project = arg
+			files, err = filepath.Glob(dataPrefix + "metrics/" + arg + "/tests/*.yaml")
+			lib.FatalOnError(err)
+			if len(files) == 0 {
+				lib.Printf("%s: no metric tests found\n", arg)
+			}
+		} else if project == "" {
+			// metrics/project/tests/file.yaml
+			project = filepath.Base(filepath.Dir(filepath.Dir(arg)))
+		}
+		for _, fn := range files {
+			p, f := testMetricsFile(&ctx, fn, project, dataPrefix, excludeBots)
+			passed += p
+			failed += f
+		}
+	}
+	lib.Printf("Metric tests: %d passed, %d failed\n", passed, failed)
+	return failed == 0
+}
+
+// Main - runs test_metrics tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	if len(os.Args) < 2 {
+		lib.Printf("Required project name(s) or tests file(s): project1 [metrics/project2/tests/test.yaml [...]]\n")
+		os.Exit(lib.ExitConfig)
+	}
+	ok := testMetrics(os.Args[1:])
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+	if !ok {
+		os.Exit(lib.ExitData)
+	}
+}
@@ -0,0 +1,449 @@
+package tracker2db
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// trackerIssue - single issue fetched from an external tracker, backend independent
+type trackerIssue struct {
+	id          int64
+	key         string
+	number      int
+	title       string
+	body        string
+	status      string
+	closed      bool
+	reporter    string
+	assignee    string
+	comments    int
+	created     time.Time
+	updated     time.Time
+	transitions []trackerTransition
+}
+
+// trackerTransition - single issue state transition
+// fromStatus is the status before the first transition, it is used as the initial issue status
+type trackerTransition struct {
+	id         int64
+	actor      string
+	at         time.Time
+	fromStatus string
+	status     string
+	closed     bool
+}
+
+// tracker - external issue tracker backend
+type tracker interface {
+	// issues - calls fn for every issue of a given project updated since from
+	issues(project string, from time.Time, fn func(*trackerIssue)) error
+}
+
+// backends - supported trackers, name -> backend constructor
+var backends = map[string]func(*lib.Ctx, string) (tracker, error){
+	"jira": newJira,
+}
+
+// jira - Jira REST API v2 backend
+type jira struct {
+	url    string
+	user   string
+	token  string
+	client *http.Client
+	// statuses - status name -> true if status is in the "done" category
+	statuses map[string]bool
+}
+
+// jiraUser - Jira user, Jira Server uses name, Jira Cloud only has accountId
+type jiraUser struct {
+	Name        string `json:"name"`
+	AccountID   string `json:"accountId"`
+	DisplayName string `json:"displayName"`
+}
+
+// login - returns user identifier used as actor login
+func (u *jiraUser) login() string {
+	if u == nil {
+		return ""
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.AccountID
+}
+
+// jiraStatus - Jira issue status with its category
+type jiraStatus struct {
+	Name     string `json:"name"`
+	Category struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+// jiraSearch - Jira search API response
+type jiraSearch struct {
+	StartAt    int `json:"startAt"`
+	MaxResults int `json:"maxResults"`
+	Total      int `json:"total"`
+	Issues     []struct {
+		ID     string `json:"id"`
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string     `json:"summary"`
+			Description *string    `json:"description"`
+			Status      jiraStatus `json:"status"`
+			Created     string     `json:"created"`
+			Updated     string     `json:"updated"`
+			Reporter    *jiraUser  `json:"reporter"`
+			Assignee    *jiraUser  `json:"assignee"`
+			Comment     struct {
+				Total int `json:"total"`
+			} `json:"comment"`
+		} `json:"fields"`
+		Changelog struct {
+			Histories []struct {
+				ID      string    `json:"id"`
+				Author  *jiraUser `json:"author"`
+				Created string    `json:"created"`
+				Items   []struct {
+					Field      string `json:"field"`
+					FromString string `json:"fromString"`
+					ToString   string `json:"toString"`
+				} `json:"items"`
+			} `json:"histories"`
+		} `json:"changelog"`
+	} `json:"issues"`
+}
+
+// jiraTime - Jira timestamps format
+const jiraTime = "2006-01-02T15:04:05.000-0700"
+
+// newJira - creates Jira backend, TRACKER_USER + TRACKER_TOKEN use basic auth (Jira Cloud), TRACKER_TOKEN alone is a personal access token (Jira Server)
+func newJira(ctx *lib.Ctx, baseURL string) (tracker, error) {
+	j := &jira{
+		url:    strings.TrimRight(baseURL, "/"),
+		user:   os.Getenv("TRACKER_USER"),
+		token:  os.Getenv("TRACKER_TOKEN"),
+		client: lib.HTTPClient(ctx),
+	}
+	var statuses []jiraStatus
+	err := j.get("/rest/api/2/status", nil, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	j.statuses = make(map[string]bool)
+	for _, status := range statuses {
+		j.statuses[status.Name] = status.Category.Key == "done"
+	}
+	return j, nil
+}
+
+// get - calls Jira REST API and parses its JSON response
+func (j *jira) get(path string, params url.Values, data interface{}) error {
+	u := j.url + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if j.token != "" {
+		if j.user != "" {
+			req.SetBasicAuth(j.user, j.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+j.token)
+		}
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d: %s", u, resp.StatusCode, lib.TruncToBytes(string(body), 200))
+	}
+	return jsoniter.Unmarshal(body, data)
+}
+
+// isClosed - checks if status is in the "done" category, unknown (deleted) statuses are checked by name
+func (j *jira) isClosed(status string) bool {
+	closed, ok := j.statuses[status]
+	if ok {
+		return closed
+	}
+	switch strings.ToLower(status) {
+	case "closed", "resolved", "done":
+		return true
+	}
+	return false
+}
+
+// issues - fetches project issues updated since from, including their status changes
+func (j *jira) issues(project string, from time.Time, fn func(*trackerIssue)) error {
+	jql := fmt.Sprintf(`project = "%s"`, project)
+	if !from.IsZero() {
+		jql += fmt.Sprintf(` and updated >= "%s"`, from.Format("2006-01-02 15:04"))
+	}
+	if extra := os.Getenv("TRACKER_JQL"); extra != "" {
+		jql += " and (" + extra + ")"
+	}
+	jql += " order by updated asc"
+	for startAt := 0; ; {
+		params := url.Values{}
+		params.Set("jql", jql)
+		params.Set("startAt", strconv.Itoa(startAt))
+		params.Set("maxResults", "100")
+		params.Set("fields", "summary,description,status,created,updated,reporter,assignee,comment")
+		params.Set("expand", "changelog")
+		var res jiraSearch
+		err := j.get("/rest/api/2/search", params, &res)
+		if err != nil {
+			return err
+		}
+		for _, i := range res.Issues {
+			issue := &trackerIssue{
+				key:      i.Key,
+				title:    i.Fields.Summary,
+				status:   i.Fields.Status.Name,
+				closed:   i.Fields.Status.Category.Key == "done",
+				reporter: i.Fields.Reporter.login(),
+				assignee: i.Fields.Assignee.login(),
+				comments: i.Fields.Comment.Total,
+			}
+			if i.Fields.Description != nil {
+				issue.body = *i.Fields.Description
+			}
+			issue.id, err = strconv.ParseInt(i.ID, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid id '%s': %v", i.Key, i.ID, err)
+			}
+			ary := strings.Split(i.Key, "-")
+			issue.number, err = strconv.Atoi(ary[len(ary)-1])
+			if err != nil {
+				return fmt.Errorf("%s: invalid key: %v", i.Key, err)
+			}
+			issue.created, err = time.Parse(jiraTime, i.Fields.Created)
+			if err != nil {
+				return fmt.Errorf("%s: invalid created date: %v", i.Key, err)
+			}
+			issue.updated, err = time.Parse(jiraTime, i.Fields.Updated)
+			if err != nil {
+				return fmt.Errorf("%s: invalid updated date: %v", i.Key, err)
+			}
+			for _, h := range i.Changelog.Histories {
+				for _, item := range h.Items {
+					if item.Field != "status" {
+						continue
+					}
+					t := trackerTransition{
+						actor:      h.Author.login(),
+						fromStatus: item.FromString,
+						status:     item.ToString,
+						closed:     j.isClosed(item.ToString),
+					}
+					t.id, err = strconv.ParseInt(h.ID, 10, 64)
+					if err != nil {
+						return fmt.Errorf("%s: invalid history id '%s': %v", i.Key, h.ID, err)
+					}
+					t.at, err = time.Parse(jiraTime, h.Created)
+					if err != nil {
+						return fmt.Errorf("%s: invalid history date: %v", i.Key, err)
+					}
+					issue.transitions = append(issue.transitions, t)
+				}
+			}
+			fn(issue)
+		}
+		startAt += len(res.Issues)
+		if len(res.Issues) == 0 || startAt >= res.Total {
+			break
+		}
+	}
+	return nil
+}
+
+// lastUpdated - returns the most recent update date of already imported issues of a given source and project
+func lastUpdated(con *sql.DB, ctx *lib.Ctx, source, project string) (dt time.Time) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select coalesce(max(updated_at), '1970-01-01') from gha_tracker_issues where source = $1 and external_key like $2",
+		source,
+		project+"-%",
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&dt))
+	}
+	lib.FatalOnError(rows.Err())
+	if dt.Year() == 1970 {
+		dt = time.Time{}
+	}
+	return
+}
+
+// repoID - returns id of a given GitHub repo, artificial (negative) id if repo is not known
+func repoID(con *sql.DB, ctx *lib.Ctx, repo string) int64 {
+	rows := lib.QuerySQLWithErr(con, ctx, "select id from gha_repos where name = $1 order by id desc limit 1", repo)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	rid := int64(0)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&rid))
+	}
+	lib.FatalOnError(rows.Err())
+	if rid == 0 {
+		rid = int64(lib.HashStrings([]string{repo}))
+	}
+	return rid
+}
+
+// actorID - returns artificial (negative) id of an external tracker user
+// Tracker users are not mapped to GitHub actors, the same login can belong to different people
+func actorID(source, login string) int64 {
+	return int64(lib.HashStrings([]string{source, ":", login}))
+}
+
+// saveIssue - upserts issue creation and all its state transitions into gha_tracker_issues
+func saveIssue(con *sql.DB, ctx *lib.Ctx, source string, rid int64, repo string, issue *trackerIssue, maybeHide func(string) string) {
+	sort.Slice(issue.transitions, func(i, j int) bool { return issue.transitions[i].at.Before(issue.transitions[j].at) })
+	reporter := maybeHide(lib.TruncToBytes(issue.reporter, 120))
+	var (
+		assigneeID    interface{}
+		assigneeLogin interface{}
+	)
+	if issue.assignee != "" {
+		assigneeID = actorID(source, issue.assignee)
+		assigneeLogin = maybeHide(lib.TruncToBytes(issue.assignee, 120))
+	}
+	// Issue creation row, initial status is the status before the first transition
+	status, closed := issue.status, issue.closed
+	if len(issue.transitions) > 0 {
+		status, closed = issue.transitions[0].fromStatus, false
+	}
+	events := []trackerTransition{{id: 0, actor: issue.reporter, at: issue.created, status: status, closed: closed}}
+	events = append(events, issue.transitions...)
+	for _, ev := range events {
+		state := "open"
+		var closedAt interface{}
+		if ev.closed {
+			state = "closed"
+			closedAt = ev.at
+		}
+		actor := maybeHide(lib.TruncToBytes(ev.actor, 120))
+		q, args := lib.NewQB("gha_tracker_issues").
+			Set("source", source).
+			Set("external_key", issue.key).
+			Set("external_state", lib.TruncToBytes(ev.status, 100)).
+			Set("id", issue.id).
+			Set("event_id", ev.id).
+			Set("assignee_id", assigneeID).
+			Set("body", lib.TruncField(ctx, "gha_issues.body", issue.body)).
+			Set("closed_at", closedAt).
+			Set("comments", issue.comments).
+			Set("created_at", issue.created).
+			Set("locked", false).
+			Set("milestone_id", nil).
+			Set("number", issue.number).
+			Set("state", state).
+			Set("title", lib.TruncField(ctx, "gha_issues.title", issue.title)).
+			Set("updated_at", ev.at).
+			Set("user_id", actorID(source, issue.reporter)).
+			Set("is_pull_request", false).
+			Set("dup_actor_id", actorID(source, ev.actor)).
+			Set("dup_actor_login", actor).
+			Set("dup_repo_id", rid).
+			Set("dup_repo_name", repo).
+			Set("dup_type", "IssuesEvent").
+			Set("dup_created_at", ev.at).
+			Set("dupn_assignee_login", assigneeLogin).
+			Set("dup_user_login", reporter).
+			Upsert("source", "id", "event_id")
+		lib.ExecSQLWithErr(con, ctx, q, args...)
+	}
+}
+
+// importTracker - imports issues of a given external tracker project and attributes them to a GitHub repo
+func importTracker(source, project, repo string) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	newBackend, ok := backends[source]
+	if !ok {
+		lib.Fatalf("unknown tracker '%s'", source)
+	}
+	baseURL := os.Getenv("TRACKER_URL")
+	if baseURL == "" {
+		lib.Fatalf("you need to set TRACKER_URL")
+	}
+	backend, err := newBackend(&ctx, baseURL)
+	lib.FatalOnError(err)
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	lib.Migrate(con, &ctx)
+
+	// Incremental import: issues updated since the last imported update, DTFROM overrides it
+	var from time.Time
+	if dtFrom := os.Getenv("DTFROM"); dtFrom != "" {
+		from = lib.TimeParseAny(dtFrom)
+	} else {
+		from = lastUpdated(con, &ctx, source, project)
+		if !from.IsZero() {
+			// Tracker can use a different timezone in date queries, issues are upserted so overlapping is safe
+			from = from.Add(-24 * time.Hour)
+		}
+	}
+	rid := repoID(con, &ctx, repo)
+	maybeHide := lib.MaybeHideFunc(lib.GetHidden(&ctx, lib.HideCfgFile))
+	lib.Printf("Importing %s project %s issues updated since %v into %s\n", source, project, from, repo)
+	issues, transitions := 0, 0
+	err = backend.issues(project, from, func(issue *trackerIssue) {
+		saveIssue(con, &ctx, source, rid, repo, issue, maybeHide)
+		issues++
+		transitions += len(issue.transitions)
+		if ctx.Debug > 0 {
+			lib.Printf("%s: %s (%d transitions)\n", issue.key, issue.status, len(issue.transitions))
+		}
+	})
+	lib.FatalOnError(err)
+	lib.Printf("Imported %d issues with %d state transitions\n", issues, transitions)
+	lib.ReportHTTPStats()
+}
+
+// Main - runs tracker2db tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	if len(os.Args) < 4 {
+		names := []string{}
+		for name := range backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lib.Printf("Usage: %s tracker project github_org/repo\n", os.Args[0])
+		lib.Printf("Supported trackers: %s, set TRACKER_URL to the tracker URL\n", strings.Join(names, ", "))
+		os.Exit(lib.ExitConfig)
+	}
+	importTracker(os.Args[1], os.Args[2], os.Args[3])
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,417 @@
+package tsexport
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+// column - single series table column, type is information_schema data type
+type column struct {
+	name string
+	typ  string
+}
+
+// seriesTable - series table with its key (time, [series,] period) and data columns
+// hll columns cannot be replicated and are not included
+type seriesTable struct {
+	name    string
+	series  bool
+	period  bool
+	columns []column
+}
+
+// keys - returns table key columns, series column is only present in tables written by merged series
+func (t *seriesTable) keys() []string {
+	if t.series {
+		return []string{"time", "series", "period"}
+	}
+	return []string{"time", "period"}
+}
+
+// backend - time series database series tables are replicated to
+type backend interface {
+	// prepare - creates or updates target structure of a given series table
+	prepare(t *seriesTable) error
+	// write - writes given series table rows (columns in keys + columns order), returns number of rows written
+	write(t *seriesTable, rows *sql.Rows) (int, error)
+	// close - releases backend resources
+	close()
+}
+
+// backends - supported time series databases, name -> backend constructor
+var backends = map[string]func(*lib.Ctx, string) (backend, error){
+	"timescaledb":     newTimescale,
+	"victoriametrics": newVictoria,
+}
+
+// quote - returns quoted psql identifier
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// scanRow - scans current row into values (one per column)
+func scanRow(rows *sql.Rows, n int) ([]interface{}, error) {
+	values := make([]interface{}, n)
+	pointers := make([]interface{}, n)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err := rows.Scan(pointers...)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if b, ok := value.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+	return values, nil
+}
+
+// timescale - TimescaleDB backend, series tables are replicated as hypertables with the same names and columns
+type timescale struct {
+	ctx *lib.Ctx
+	con *sql.DB
+}
+
+// newTimescale - connects to TimescaleDB, url is a Postgres connection string
+func newTimescale(ctx *lib.Ctx, url string) (backend, error) {
+	con, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	err = con.Ping()
+	if err != nil {
+		_ = con.Close()
+		return nil, err
+	}
+	// Target is not the main database, reconnecting would connect to the wrong one
+	tctx := ctx.CopyContext()
+	tctx.CanReconnect = false
+	return &timescale{ctx: tctx, con: con}, nil
+}
+
+func (ts *timescale) prepare(t *seriesTable) error {
+	sq := "create table if not exists " + quote(t.name) + "(time timestamp not null, "
+	if t.series {
+		sq += "series text not null, "
+	}
+	sq += "period text not null default '', primary key(" + strings.Join(t.keys(), ", ") + "))"
+	_, err := lib.ExecSQL(ts.con, ts.ctx, sq)
+	if err != nil {
+		return err
+	}
+	_, err = lib.ExecSQL(ts.con, ts.ctx, "select create_hypertable($1, 'time', if_not_exists => true, migrate_data => true)", quote(t.name))
+	if err != nil {
+		return err
+	}
+	for _, col := range t.columns {
+		_, err = lib.ExecSQL(ts.con, ts.ctx, "alter table "+quote(t.name)+" add column if not exists "+quote(col.name)+" "+col.typ)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *timescale) write(t *seriesTable, rows *sql.Rows) (n int, err error) {
+	tx, err := ts.con.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+	names := t.keys()
+	for _, col := range t.columns {
+		names = append(names, col.name)
+	}
+	keys := []string{}
+	for _, key := range t.keys() {
+		keys = append(keys, quote(key))
+	}
+	var values []interface{}
+	for rows.Next() {
+		values, err = scanRow(rows, len(names))
+		if err != nil {
+			return
+		}
+		qb := lib.NewQB(quote(t.name))
+		for i, name := range names {
+			qb.Set(quote(name), values[i])
+		}
+		q, args := qb.Upsert(keys...)
+		_, err = lib.ExecSQLTx(tx, ts.ctx, q, args...)
+		if err != nil {
+			return
+		}
+		n++
+	}
+	err = rows.Err()
+	return
+}
+
+func (ts *timescale) close() {
+	_ = ts.con.Close()
+}
+
+// victoria - VictoriaMetrics backend, every numeric column becomes a metric imported in Prometheus text format
+// Metric name is devstats_<table>_<column>, labels are db, series, period and text columns
+type victoria struct {
+	ctx    *lib.Ctx
+	url    string
+	client *http.Client
+}
+
+// victoriaBatch - number of samples sent in a single import request
+const victoriaBatch = 10000
+
+var nonMetricRe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// newVictoria - creates VictoriaMetrics backend, url is VictoriaMetrics base URL, for example http://127.0.0.1:8428
+func newVictoria(ctx *lib.Ctx, url string) (backend, error) {
+	return &victoria{ctx: ctx, url: strings.TrimRight(url, "/"), client: lib.HTTPClient(ctx)}, nil
+}
+
+// prepare - VictoriaMetrics has no schema, metrics are created on import
+func (vm *victoria) prepare(t *seriesTable) error {
+	return nil
+}
+
+// label - returns Prometheus text format label
+func label(name, value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return nonMetricRe.ReplaceAllString(name, "_") + `="` + value + `"`
+}
+
+// post - sends samples to VictoriaMetrics import API
+func (vm *victoria) post(buf *bytes.Buffer) error {
+	resp, err := vm.client.Post(vm.url+"/api/v1/import/prometheus", "text/plain", buf)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: status %d: %s", vm.url, resp.StatusCode, lib.TruncToBytes(string(body), 200))
+	}
+	buf.Reset()
+	return nil
+}
+
+func (vm *victoria) write(t *seriesTable, rows *sql.Rows) (n int, err error) {
+	keys := t.keys()
+	ncols := len(keys) + len(t.columns)
+	prefix := "devstats_" + nonMetricRe.ReplaceAllString(t.name, "_") + "_"
+	var (
+		buf     bytes.Buffer
+		values  []interface{}
+		samples int
+	)
+	for rows.Next() {
+		values, err = scanRow(rows, ncols)
+		if err != nil {
+			return
+		}
+		dt, _ := values[0].(time.Time)
+		labels := []string{label("db", vm.ctx.PgDB)}
+		for i, key := range keys[1:] {
+			labels = append(labels, label(key, fmt.Sprintf("%v", values[i+1])))
+		}
+		for i, col := range t.columns {
+			if s, ok := values[len(keys)+i].(string); ok && col.typ == "text" {
+				labels = append(labels, label(col.name, s))
+			}
+		}
+		sort.Strings(labels[1:])
+		lbls := "{" + strings.Join(labels, ",") + "}"
+		for i, col := range t.columns {
+			f, ok := values[len(keys)+i].(float64)
+			if !ok || col.typ != "double precision" || math.IsNaN(f) {
+				continue
+			}
+			buf.WriteString(fmt.Sprintf("%s%s%s %v %d\n", prefix, nonMetricRe.ReplaceAllString(col.name, "_"), lbls, f, dt.UnixNano()/int64(time.Millisecond)))
+			samples++
+		}
+		n++
+		if samples >= victoriaBatch {
+			err = vm.post(&buf)
+			if err != nil {
+				return
+			}
+			samples = 0
+		}
+	}
+	err = rows.Err()
+	if err == nil && buf.Len() > 0 {
+		err = vm.post(&buf)
+	}
+	return
+}
+
+func (vm *victoria) close() {
+}
+
+// seriesTables - returns series tables matching a given regexp with their replicated columns, shadow (blue/green) tables are skipped
+func seriesTables(con *sql.DB, ctx *lib.Ctx, tablesRe string) (tables []*seriesTable) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select c.relname, a.attname, format_type(a.atttypid, a.atttypmod) from pg_class c, pg_namespace n, pg_attribute a "+
+			"where c.relnamespace = n.oid and a.attrelid = c.oid and n.nspname = 'public' and c.relkind = 'r' "+
+			"and c.relname ~ $1 and a.attnum > 0 and not a.attisdropped "+
+			"and obj_description(c.oid, 'pg_class') is distinct from $2 "+
+			"order by c.relname, a.attnum",
+		tablesRe,
+		lib.TSDBShadowComment,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	var (
+		name, col, typ string
+		t              *seriesTable
+	)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&name, &col, &typ))
+		if t == nil || t.name != name {
+			t = &seriesTable{name: name}
+			tables = append(tables, t)
+		}
+		switch {
+		case col == "time":
+		case col == "series":
+			t.series = true
+		case col == "period":
+			t.period = true
+		case typ == "double precision" || typ == "text" || strings.HasPrefix(typ, "timestamp"):
+			t.columns = append(t.columns, column{name: col, typ: typ})
+		}
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+// lastTime - returns last replicated time of a given table, zero time if it was never replicated
+func lastTime(con *sql.DB, ctx *lib.Ctx, name, table string) (dt time.Time) {
+	err := lib.QueryRowSQL(con, ctx, "select last_time from gha_ts_export where backend = $1 and series_table = $2", name, table).Scan(&dt)
+	if err != nil && err != sql.ErrNoRows {
+		lib.FatalOnError(err)
+	}
+	return
+}
+
+// tsExport - replicates series tables to a given backend, only rows newer than the last replicated time minus overlap are sent
+func tsExport(name string) {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	newBackend, ok := backends[name]
+	if !ok {
+		lib.Fatalf("unknown backend '%s'", name)
+	}
+	url := os.Getenv("TS_URL")
+	if url == "" {
+		lib.Fatalf("you need to set TS_URL")
+	}
+	tablesRe := os.Getenv("TS_TABLES")
+	if tablesRe == "" {
+		tablesRe = "^s"
+	}
+	// Series values of recent periods are recalculated, so they are replicated again
+	overlap := os.Getenv("TS_OVERLAP")
+	if overlap == "" {
+		overlap = "1 year"
+	}
+	be, err := newBackend(&ctx, url)
+	lib.FatalOnError(err)
+	defer be.close()
+
+	// Connect to Postgres DB
+	con := lib.PgConn(&ctx)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	lib.Migrate(con, &ctx)
+
+	tables := seriesTables(con, &ctx, tablesRe)
+	lib.Printf("Replicating %d series tables to %s\n", len(tables), name)
+	dtStart := time.Now()
+	lastInfo := dtStart
+	total := 0
+	for i, t := range tables {
+		if !t.period || len(t.columns) == 0 {
+			lib.Printf("%s: not a series table, skipping\n", t.name)
+			continue
+		}
+		lib.FatalOnError(be.prepare(t))
+		from := lastTime(con, &ctx, name, t.name)
+		cols := t.keys()
+		for _, col := range t.columns {
+			cols = append(cols, col.name)
+		}
+		for j, col := range cols {
+			cols[j] = quote(col)
+		}
+		query := "select " + strings.Join(cols, ", ") + " from " + quote(t.name)
+		args := []interface{}{}
+		if !from.IsZero() {
+			query += " where time >= $1::timestamp - $2::interval"
+			args = append(args, from, overlap)
+		}
+		rows := lib.QuerySQLWithErr(con, &ctx, query+" order by time", args...)
+		n, err := be.write(t, rows)
+		lib.FatalOnError(rows.Close())
+		lib.FatalOnError(err)
+		total += n
+		var last *time.Time
+		lib.FatalOnError(lib.QueryRowSQL(con, &ctx, "select max(time) from "+quote(t.name)).Scan(&last))
+		if last != nil {
+			q, args := lib.NewQB("gha_ts_export").
+				Set("backend", name).
+				Set("series_table", t.name).
+				Set("last_time", *last).
+				Set("dt", time.Now()).
+				Upsert("backend", "series_table")
+			lib.ExecSQLWithErr(con, &ctx, q, args...)
+		}
+		if ctx.Debug > 0 {
+			lib.Printf("%s: replicated %d rows since %v - %s\n", t.name, n, from, overlap)
+		}
+		lib.ProgressInfo(i+1, len(tables), dtStart, &lastInfo, time.Duration(10)*time.Second, t.name)
+	}
+	lib.Printf("Replicated %d rows from %d series tables to %s\n", total, len(tables), name)
+}
+
+// Main - runs ts_export tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	if len(os.Args) < 2 {
+		names := []string{}
+		for name := range backends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lib.Printf("Usage: %s backend\n", os.Args[0])
+		lib.Printf("Supported backends: %s, set TS_URL to the backend URL\n", strings.Join(names, ", "))
+		os.Exit(lib.ExitConfig)
+	}
+	tsExport(os.Args[1])
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
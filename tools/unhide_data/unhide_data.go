@@ -0,0 +1,300 @@
+package unhidedata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// anonValue - returns value used to hide a given string
+func anonValue(value string) (sha, anon string) {
+	hash := sha1.New()
+	_, err := hash.Write([]byte(value))
+	lib.FatalOnError(err)
+	sha = hex.EncodeToString(hash.Sum(nil))
+	anon = "anon-" + sha
+	return
+}
+
+// projectsDBs - returns databases of all enabled projects (or only those given in ONLY="project1 project2 ...")
+func projectsDBs(ctx *lib.Ctx) (dbs []string) {
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	data, err := ioutil.ReadFile(dataPrefix + ctx.ProjectsYaml)
+	lib.FatalOnError(err)
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+	only := make(map[string]struct{})
+	for _, item := range strings.Split(os.Getenv("ONLY"), " ") {
+		if item != "" {
+			only[item] = struct{}{}
+		}
+	}
+	orders := []int{}
+	projectsMap := make(map[int]string)
+	for name, proj := range projects.Projects {
+		if lib.IsProjectDisabled(ctx, name, proj.Disabled) {
+			continue
+		}
+		if len(only) > 0 {
+			if _, ok := only[name]; !ok {
+				continue
+			}
+		}
+		orders = append(orders, proj.Order)
+		projectsMap[proj.Order] = name
+	}
+	sort.Ints(orders)
+	for _, order := range orders {
+		dbs = append(dbs, projects.Projects[projectsMap[order]].PDB)
+	}
+	return
+}
+
+// removeFromHidden - removes given values from hide config, returns values that were hidden (value -> anon value)
+func removeFromHidden(ctx *lib.Ctx, values []string) map[string]string {
+	shaMap := lib.GetHidden(ctx, lib.HideCfgFile)
+	removed := make(map[string]string)
+	for _, value := range values {
+		sha, anon := anonValue(value)
+		if _, ok := shaMap[sha]; !ok {
+			lib.Printf("Skipping '%s', SHA1 '%s' - not hidden\n", value, sha)
+			continue
+		}
+		delete(shaMap, sha)
+		removed[value] = anon
+	}
+	if len(removed) > 0 {
+		lib.FatalOnError(lib.SaveHidden(lib.HideCfgFile, shaMap))
+		lib.Printf("Removed %d values from %s\n", len(removed), lib.HideCfgFile)
+	}
+	return removed
+}
+
+// restoreValues - replaces anon values with original ones in all hidden columns of all databases
+func restoreValues(ctx *lib.Ctx, dbs []string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	dtStart := time.Now()
+	lastTime := dtStart
+	n := len(dbs) * len(lib.HiddenColumns)
+	i := 0
+	for _, db := range dbs {
+		con := lib.PgConnDB(ctx, db)
+		for _, column := range lib.HiddenColumns {
+			for value, anon := range values {
+				res := lib.ExecSQLWithErr(
+					con,
+					ctx,
+					fmt.Sprintf(
+						"update %s set %s = %s where %s = %s",
+						column.Table,
+						column.Column,
+						lib.NValue(1),
+						column.Column,
+						lib.NValue(2),
+					),
+					value,
+					anon,
+				)
+				rows, err := res.RowsAffected()
+				lib.FatalOnError(err)
+				if rows > 0 {
+					lib.Printf("DB: %s, table: %s, column: %s, restored '%s' in %d rows\n", db, column.Table, column.Column, value, rows)
+				}
+			}
+			i++
+			lib.ProgressInfo(i, n, dtStart, &lastTime, time.Duration(10)*time.Second, db+": "+column.Table+"."+column.Column)
+		}
+		lib.FatalOnError(con.Close())
+	}
+}
+
+// hiddenAuthors - returns hidden author names of commits authored by given logins, grouped by GHA hour: hour -> commit SHA -> anon name
+func hiddenAuthors(con *sql.DB, ctx *lib.Ctx, logins []string) map[time.Time]map[string]string {
+	args := lib.AnyArray{}
+	for _, login := range logins {
+		args = append(args, login)
+	}
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		fmt.Sprintf(
+			"select distinct c.sha, c.author_name, e.created_at from gha_commits c, gha_events e "+
+				"where c.event_id = e.id and c.author_name like 'anon-%%' and c.dup_author_login in %s",
+			lib.NArray(len(logins), 0),
+		),
+		args...,
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	hours := make(map[time.Time]map[string]string)
+	var (
+		sha, anon string
+		dt        time.Time
+	)
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&sha, &anon, &dt))
+		hour := lib.HourStart(dt)
+		if _, ok := hours[hour]; !ok {
+			hours[hour] = make(map[string]string)
+		}
+		hours[hour][sha] = anon
+	}
+	lib.FatalOnError(rows.Err())
+	return hours
+}
+
+// readGHAHour - returns given GHA hour JSONs, from GHA2DB_LOCAL_JSONS_DIR when set or from data.gharchive.org
+func readGHAHour(ctx *lib.Ctx, dt time.Time) ([]byte, error) {
+	if ctx.LocalJSONsDir != "" {
+		root := filepath.Join(ctx.LocalJSONsDir, lib.ToGHADate(dt))
+		if data, err := ioutil.ReadFile(root + ".json"); err == nil {
+			return data, nil
+		}
+		file, err := os.Open(root + ".json.gz")
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = reader.Close() }()
+		return ioutil.ReadAll(reader)
+	}
+	response, err := lib.HTTPClient(ctx).Get(fmt.Sprintf("https://data.gharchive.org/%s.json.gz", lib.ToGHADate(dt)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: HTTP status %d", dt, response.StatusCode)
+	}
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return ioutil.ReadAll(reader)
+}
+
+// recoverAuthors - re-parses GHA hours of commits authored by given logins with hidden author names
+// Returns recovered author names (name -> anon value), only names whose SHA1 matches hidden value are returned
+func recoverAuthors(ctx *lib.Ctx, dbs []string, logins []string) map[string]string {
+	hours := make(map[time.Time]map[string]string)
+	for _, db := range dbs {
+		con := lib.PgConnDB(ctx, db)
+		for hour, commits := range hiddenAuthors(con, ctx, logins) {
+			if _, ok := hours[hour]; !ok {
+				hours[hour] = make(map[string]string)
+			}
+			for sha, anon := range commits {
+				hours[hour][sha] = anon
+			}
+		}
+		lib.FatalOnError(con.Close())
+	}
+	recovered := make(map[string]string)
+	if len(hours) == 0 {
+		return recovered
+	}
+	dts := []time.Time{}
+	for dt := range hours {
+		dts = append(dts, dt)
+	}
+	sort.Slice(dts, func(i, j int) bool { return dts[i].Before(dts[j]) })
+	lib.Printf("Re-parsing %d GHA hours (%v - %v) to recover hidden commit authors\n", len(dts), lib.ToYMDHDate(dts[0]), lib.ToYMDHDate(dts[len(dts)-1]))
+	dtStart := time.Now()
+	lastTime := dtStart
+	for i, dt := range dts {
+		commits := hours[dt]
+		data, err := readGHAHour(ctx, dt)
+		if err != nil {
+			lib.Printf("Cannot read GHA hour %v, skipping: %v\n", dt, err)
+			continue
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if !bytes.Contains(line, []byte(`"PushEvent"`)) {
+				continue
+			}
+			var ev lib.Event
+			if jsoniter.Unmarshal(line, &ev) != nil || ev.Type != "PushEvent" || ev.Payload.Commits == nil {
+				continue
+			}
+			for _, commit := range *ev.Payload.Commits {
+				anon, ok := commits[commit.SHA]
+				if !ok {
+					continue
+				}
+				name := lib.TruncToBytes(commit.Author.Name, lib.SchemaLimits["gha_commits.author_name"])
+				if _, nameAnon := anonValue(name); nameAnon == anon {
+					recovered[name] = anon
+				}
+			}
+		}
+		lib.ProgressInfo(i+1, len(dts), dtStart, &lastTime, time.Duration(10)*time.Second, lib.ToYMDHDate(dt))
+	}
+	return recovered
+}
+
+// unhideData - removes given logins from hide config and restores their identity in all projects databases
+func unhideData(ctx *lib.Ctx, args []string) {
+	logins := []string{}
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if arg != "" {
+			logins = append(logins, arg)
+		}
+	}
+	dbs := projectsDBs(ctx)
+	lib.Printf("Processing databases: %+v\n", dbs)
+
+	// Stage 1: logins are hidden as "anon-" + SHA1(login), so they can be restored directly
+	lib.Printf("Stage 1/2: restoring logins\n")
+	restoreValues(ctx, dbs, removeFromHidden(ctx, logins))
+
+	// Stage 2: author names of commits authored by these logins can be recovered from GHA payloads
+	lib.Printf("Stage 2/2: recovering commit authors names\n")
+	recovered := recoverAuthors(ctx, dbs, logins)
+	names := []string{}
+	for name := range recovered {
+		names = append(names, name)
+	}
+	lib.Printf("Recovered %d names: %+v\n", len(names), names)
+	restoreValues(ctx, dbs, removeFromHidden(ctx, names))
+}
+
+// Main - runs unhide_data tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	var ctx lib.Ctx
+	dtStart := time.Now()
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+	if len(os.Args) < 2 {
+		lib.Printf("Required at least one login to un-hide\n")
+		fmt.Printf("%s: login1 [login2 [...]]\n", os.Args[0])
+		os.Exit(lib.ExitConfig)
+	}
+	unhideData(&ctx, os.Args[1:])
+	lib.ReportHTTPStats()
+	dtEnd := time.Now()
+	lib.Printf("Time: %v\n", dtEnd.Sub(dtStart))
+}
@@ -0,0 +1,508 @@
+package websitedata
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	lib "github.com/cncf/devstatscode"
+	jsoniter "github.com/json-iterator/go"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type allProjects struct {
+	Projects  []project `json:"projects"`
+	Summary   string    `json:"summary"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type project struct {
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Status       string `json:"status"`
+	Repo         string `json:"repo"`
+	DashboardURL string `json:"dashboardUrl"`
+	DBDumpURL    string `json:"dbDumpUrl"`
+}
+
+type projectStats struct {
+	Totals           activityTotals `json:"activityTotals"`
+	LatestVersion    string         `json:"latestVersion"`
+	OpenIssues       int            `json:"openIssues"`
+	RecentDiscussion int            `json:"recentDiscussion"`
+	Stars            int            `json:"stars"`
+	CommitGraph      commitGraph    `json:"commitGraph"`
+	Timestamp        time.Time      `json:"timestamp"`
+}
+
+type commitGraph struct {
+	Day   [24][2]int `json:"day"`
+	Week  [7][2]int  `json:"week"`
+	Month [4][2]int  `json:"month"`
+}
+
+type activityTotals struct {
+	Day   activityTotal `json:"day"`
+	Week  activityTotal `json:"week"`
+	Month activityTotal `json:"month"`
+}
+
+type activityTotal struct {
+	Commits    int `json:"commits"`
+	Discussion int `json:"discussion"`
+	Stars      int `json:"stars"`
+}
+
+type topContributors struct {
+	Contributors []contributor `json:"contributors"`
+	Timestamp    time.Time     `json:"timestamp"`
+}
+
+type contributor struct {
+	Login         string `json:"login"`
+	Contributions int    `json:"contributions"`
+}
+
+type topCompanies struct {
+	Companies []company `json:"companies"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type company struct {
+	Name          string `json:"name"`
+	Contributors  int    `json:"contributors"`
+	Contributions int    `json:"contributions"`
+}
+
+type projectAnnotations struct {
+	Annotations []annotation `json:"annotations"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
+
+type annotation struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+}
+
+// feeds - per-project feeds that can be generated (GHA2DB_WEBSITEDATA_FEEDS), stats feed is saved as <project>.json, others as <project>_<feed>.json
+var feeds = []string{"stats", "contributors", "companies", "annotations"}
+
+// contributionsTypes - events counted as contributions in contributors and companies feeds
+const contributionsTypes = "'PushEvent', 'PullRequestEvent', 'IssuesEvent', 'PullRequestReviewEvent', " +
+	"'CommitCommentEvent', 'IssueCommentEvent', 'PullRequestReviewCommentEvent'"
+
+func getIntValue(con *sql.DB, ctx *lib.Ctx, sql string) (ival int) {
+	rows := lib.QuerySQLWithErr(con, ctx, sql)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		lib.FatalOnError(rows.Scan(&ival))
+	}
+	lib.FatalOnError(rows.Err())
+	return
+}
+
+func generateJSONData(con *sql.DB, ctx *lib.Ctx, excludeBots, lastTagCmd, repo string, stats *projectStats) {
+	for i := 0; i < 24; i++ {
+		to := 23 - i
+		from := to + 1
+		commits := getIntValue(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"select count(distinct sha) from gha_commits "+
+					"where dup_created_at >= now() - '%d hours'::interval "+
+					"and dup_created_at < now() - '%d hours'::interval ",
+				from,
+				to,
+			)+"and (lower(dup_actor_login) "+excludeBots+")",
+		)
+		stats.CommitGraph.Day[i][0] = i
+		stats.CommitGraph.Day[i][1] = commits
+	}
+	for i := 0; i < 7; i++ {
+		to := 6 - i
+		from := to + 1
+		commits := getIntValue(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"select count(distinct sha) from gha_commits "+
+					"where dup_created_at >= now() - '%d days'::interval "+
+					"and dup_created_at < now() - '%d days'::interval ",
+				from,
+				to,
+			)+"and (lower(dup_actor_login) "+excludeBots+")",
+		)
+		stats.CommitGraph.Week[i][0] = i
+		stats.CommitGraph.Week[i][1] = commits
+	}
+	for i := 0; i < 4; i++ {
+		to := 3 - i
+		from := to + 1
+		commits := getIntValue(
+			con,
+			ctx,
+			fmt.Sprintf(
+				"select count(distinct sha) from gha_commits "+
+					"where dup_created_at >= now() - '%d weeks'::interval "+
+					"and dup_created_at < now() - '%d weeks'::interval ",
+				from,
+				to,
+			)+"and (lower(dup_actor_login) "+excludeBots+")",
+		)
+		stats.CommitGraph.Month[i][0] = i
+		stats.CommitGraph.Month[i][1] = commits
+	}
+	stats.Totals.Day.Commits = stats.CommitGraph.Week[6][1]
+	stats.Totals.Week.Commits = stats.CommitGraph.Month[3][1]
+	stats.Totals.Month.Commits = getIntValue(
+		con,
+		ctx,
+		"select count(distinct sha) from gha_commits "+
+			"where dup_created_at >= now() - '1 month'::interval "+
+			"and (lower(dup_actor_login) "+excludeBots+")",
+	)
+	stats.Totals.Day.Discussion = getIntValue(
+		con,
+		ctx,
+		"select count(distinct event_id) from gha_texts "+
+			"where created_at >= now() - '1 day'::interval "+
+			"and (lower(actor_login) "+excludeBots+")",
+	)
+	stats.Totals.Week.Discussion = getIntValue(
+		con,
+		ctx,
+		"select count(distinct event_id) from gha_texts "+
+			"where created_at >= now() - '1 week'::interval "+
+			"and (lower(actor_login) "+excludeBots+")",
+	)
+	stats.Totals.Month.Discussion = getIntValue(
+		con,
+		ctx,
+		"select count(distinct event_id) from gha_texts "+
+			"where created_at >= now() - '1 month'::interval "+
+			"and (lower(actor_login) "+excludeBots+")",
+	)
+	stats.RecentDiscussion = stats.Totals.Month.Discussion
+	stats.Totals.Day.Stars = getIntValue(
+		con,
+		ctx,
+		"select coalesce(sum(sub.diff), 0) "+
+			"from (select min(stargazers_count) as fmin, "+
+			"max(stargazers_count) - min(stargazers_count) as diff "+
+			"from gha_forkees where dup_repo_name = full_name and "+
+			"dup_created_at >= now() - '1 day'::interval "+
+			"group by dup_repo_name) sub where fmin > 0 and diff > 0",
+	)
+	stats.Totals.Week.Stars = getIntValue(
+		con,
+		ctx,
+		"select coalesce(sum(sub.diff), 0) "+
+			"from (select min(stargazers_count) as fmin, "+
+			"max(stargazers_count) - min(stargazers_count) as diff "+
+			"from gha_forkees where dup_repo_name = full_name and "+
+			"dup_created_at >= now() - '1 week'::interval "+
+			"group by dup_repo_name) sub where fmin > 0 and diff > 0",
+	)
+	stats.Totals.Month.Stars = getIntValue(
+		con,
+		ctx,
+		"select coalesce(sum(sub.diff), 0) "+
+			"from (select min(stargazers_count) as fmin, "+
+			"max(stargazers_count) - min(stargazers_count) as diff "+
+			"from gha_forkees where dup_repo_name = full_name and "+
+			"dup_created_at >= now() - '1 month'::interval "+
+			"group by dup_repo_name) sub where fmin > 0 and diff > 0",
+	)
+	stats.Totals.Month.Stars = getIntValue(
+		con,
+		ctx,
+		"select coalesce(sum(sub.diff), 0) "+
+			"from (select min(stargazers_count) as fmin, "+
+			"max(stargazers_count) - min(stargazers_count) as diff "+
+			"from gha_forkees where dup_repo_name = full_name and "+
+			"dup_created_at >= now() - '1 month'::interval "+
+			"group by dup_repo_name) sub where fmin > 0 and diff > 0",
+	)
+	stats.Stars = getIntValue(
+		con,
+		ctx,
+		"select sum(fmax) from (select max(stargazers_count) as fmax "+
+			"from gha_forkees where dup_repo_name = full_name "+
+			"and dup_created_at >= now() - '3 months'::interval "+
+			"group by dup_repo_name) sub",
+	)
+	stats.OpenIssues = getIntValue(
+		con,
+		ctx,
+		"select count(sub.id) from (select distinct id, "+
+			"last_value(closed_at) over update_date as closed_at "+
+			"from gha_issues where is_pull_request = false "+
+			"window update_date as (partition by id order by "+
+			"updated_at asc, event_id asc range between current row "+
+			"and unbounded following)) sub where sub.closed_at is null",
+	)
+	tag := "-"
+	if repo != "" {
+		var err error
+		rwd := ctx.ReposDir + repo
+		tag, err = lib.ExecCommand(
+			ctx,
+			[]string{lastTagCmd, rwd},
+			map[string]string{"GIT_TERMINAL_PROMPT": "0"},
+		)
+		if err == nil {
+			tag = strings.TrimSpace(tag)
+		}
+	}
+	stats.LatestVersion = tag
+}
+
+// generateContributors - top contributors (by number of contributions) in the last month
+func generateContributors(con *sql.DB, ctx *lib.Ctx, excludeBots string, contributors *topContributors) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select dup_actor_login, count(id) from gha_events "+
+			"where created_at >= now() - '1 month'::interval "+
+			"and type in ("+contributionsTypes+") "+
+			"and (lower(dup_actor_login) "+excludeBots+") "+
+			"group by dup_actor_login order by 2 desc, 1 asc "+
+			fmt.Sprintf("limit %d", ctx.WebsiteDataTop),
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	contributors.Contributors = []contributor{}
+	for rows.Next() {
+		var c contributor
+		lib.FatalOnError(rows.Scan(&c.Login, &c.Contributions))
+		contributors.Contributors = append(contributors.Contributors, c)
+	}
+	lib.FatalOnError(rows.Err())
+}
+
+// generateCompanies - top companies (by number of contributions of their employees) in the last month
+func generateCompanies(con *sql.DB, ctx *lib.Ctx, excludeBots string, companies *topCompanies) {
+	rows := lib.QuerySQLWithErr(
+		con,
+		ctx,
+		"select af.company_name, count(distinct e.actor_id), count(e.id) "+
+			"from gha_events e, gha_actors_affiliations af "+
+			"where e.actor_id = af.actor_id "+
+			"and af.dt_from <= e.created_at and af.dt_to > e.created_at "+
+			"and af.company_name not in ('Independent', 'Unknown', 'NotFound', '') "+
+			"and e.created_at >= now() - '1 month'::interval "+
+			"and e.type in ("+contributionsTypes+") "+
+			"and (lower(e.dup_actor_login) "+excludeBots+") "+
+			"group by af.company_name order by 3 desc, 1 asc "+
+			fmt.Sprintf("limit %d", ctx.WebsiteDataTop),
+	)
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	companies.Companies = []company{}
+	for rows.Next() {
+		var c company
+		lib.FatalOnError(rows.Scan(&c.Name, &c.Contributors, &c.Contributions))
+		companies.Companies = append(companies.Companies, c)
+	}
+	lib.FatalOnError(rows.Err())
+}
+
+// generateAnnotations - project annotations (releases etc.) saved by annotations tool, newest first
+func generateAnnotations(con *sql.DB, ctx *lib.Ctx, annotations *projectAnnotations) {
+	annotations.Annotations = []annotation{}
+	if !lib.TableExists(con, ctx, "sannotations") {
+		return
+	}
+	rows := lib.QuerySQLWithErr(con, ctx, "select title, description, time from sannotations order by time desc")
+	defer func() { lib.FatalOnError(rows.Close()) }()
+	for rows.Next() {
+		var a annotation
+		lib.FatalOnError(rows.Scan(&a.Title, &a.Description, &a.Time))
+		annotations.Annotations = append(annotations.Annotations, a)
+	}
+	lib.FatalOnError(rows.Err())
+}
+
+// writeJSON - saves given object as a pretty printed JSON file
+func writeJSON(fn string, obj interface{}) {
+	jsonBytes, err := jsoniter.Marshal(obj)
+	lib.FatalOnError(err)
+	pretty := lib.PrettyPrintJSON(jsonBytes)
+	lib.FatalOnError(ioutil.WriteFile(fn, pretty, 0644))
+}
+
+// generateProjectFeeds - generates all feeds enabled via GHA2DB_WEBSITEDATA_FEEDS for a given project
+func generateProjectFeeds(ctx *lib.Ctx, name, excludeBots, lastTagCmd, repo string) {
+	db := name
+	if name == lib.Kubernetes {
+		db = lib.GHA
+	} else if name == lib.All {
+		db = "allprj"
+	}
+	// Connect to Postgres DB
+	con := lib.PgConnDB(ctx, db)
+	defer func() { lib.FatalOnError(con.Close()) }()
+	if ctx.WebsiteDataFeeds["stats"] {
+		var stats projectStats
+		generateJSONData(con, ctx, excludeBots, lastTagCmd, repo, &stats)
+		stats.Timestamp = time.Now()
+		writeJSON(ctx.JSONsDir+name+".json", stats)
+	}
+	if ctx.WebsiteDataFeeds["contributors"] {
+		var contributors topContributors
+		generateContributors(con, ctx, excludeBots, &contributors)
+		contributors.Timestamp = time.Now()
+		writeJSON(ctx.JSONsDir+name+"_contributors.json", contributors)
+	}
+	if ctx.WebsiteDataFeeds["companies"] {
+		var companies topCompanies
+		generateCompanies(con, ctx, excludeBots, &companies)
+		companies.Timestamp = time.Now()
+		writeJSON(ctx.JSONsDir+name+"_companies.json", companies)
+	}
+	if ctx.WebsiteDataFeeds["annotations"] {
+		var annotations projectAnnotations
+		generateAnnotations(con, ctx, &annotations)
+		annotations.Timestamp = time.Now()
+		writeJSON(ctx.JSONsDir+name+"_annotations.json", annotations)
+	}
+}
+
+// uploadFeeds - runs GHA2DB_WEBSITEDATA_UPLOAD command (for example S3 sync) on generated feeds
+func uploadFeeds(ctx *lib.Ctx) {
+	args := []string{}
+	for _, arg := range strings.Fields(ctx.WebsiteDataUpload) {
+		args = append(args, strings.Replace(arg, "{{dir}}", ctx.JSONsDir, -1))
+	}
+	if len(args) == 0 {
+		return
+	}
+	lib.Printf("Uploading website data: %v\n", args)
+	res, err := lib.ExecCommand(ctx, args, nil)
+	if err != nil {
+		lib.Printf("Upload output:\n%s\n", res)
+	}
+	lib.FatalOnError(err)
+}
+
+func generateWebsiteData() {
+	// Environment context parse
+	var ctx lib.Ctx
+	ctx.Init()
+	lib.SetupTimeoutSignal(&ctx)
+
+	// We need this to capture 'last_tag.sh' output.
+	ctx.ExecOutput = true
+	ctx.ExecFatal = false
+
+	// Local or cron mode?
+	dataPrefix := ctx.DataDir
+	if ctx.Local {
+		dataPrefix = "./"
+	}
+	cmdPrefix := ""
+	if ctx.LocalCmd {
+		cmdPrefix = lib.LocalGitScripts
+	}
+	lastTagCmd := cmdPrefix + "last_tag.sh"
+
+	// Check requested feeds
+	known := make(map[string]struct{})
+	for _, feed := range feeds {
+		known[feed] = struct{}{}
+	}
+	for feed := range ctx.WebsiteDataFeeds {
+		if _, ok := known[feed]; !ok {
+			lib.Fatalf("unknown website data feed '%s', allowed: %s", feed, strings.Join(feeds, ", "))
+		}
+	}
+
+	// Get hostname
+	hostname, err := os.Hostname()
+	lib.FatalOnError(err)
+	proto := "https://"
+	prefix := proto + hostname + "/"
+
+	// Read defined projects
+	data, err := ioutil.ReadFile(dataPrefix + ctx.ProjectsYaml)
+	lib.FatalOnError(err)
+
+	var projects lib.AllProjects
+	lib.FatalOnError(yaml.Unmarshal(data, &projects))
+
+	// Get ordered & filtered projects
+	var jprojs allProjects
+	pnames := []string{}
+	names, projs := lib.GetProjectsList(&ctx, &projects)
+	for i, name := range names {
+		proj := projs[i]
+		dashURL := proto + name + "." + hostname
+		if name == lib.Kubernetes {
+			dashURL = proto + "k8s." + hostname
+		}
+		jproj := project{
+			Name:         name,
+			Title:        proj.FullName,
+			Status:       proj.Status,
+			Repo:         proj.MainRepo,
+			DashboardURL: dashURL,
+			DBDumpURL:    prefix + proj.PDB + ".dump",
+		}
+		jprojs.Projects = append(jprojs.Projects, jproj)
+		pnames = append(pnames, name)
+	}
+	jprojs.Summary = lib.All
+
+	// Marshal JSON
+	jprojs.Timestamp = time.Now()
+	writeJSON(ctx.JSONsDir+"projects.json", jprojs)
+
+	// Read bots exclusion partial SQL
+	bytes, err := lib.ReadFile(&ctx, dataPrefix+"util_sql/exclude_bots.sql")
+	lib.FatalOnError(err)
+	excludeBots := string(bytes)
+
+	// Get number of CPUs available
+	thrN := lib.GetThreadsNum(&ctx)
+
+	if thrN > 1 {
+		ch := make(chan struct{})
+		nThreads := 0
+		for _, name := range pnames {
+			go func(ch chan struct{}, name string) {
+				generateProjectFeeds(&ctx, name, excludeBots, lastTagCmd, projects.Projects[name].MainRepo)
+				ch <- struct{}{}
+			}(ch, name)
+			nThreads++
+			if nThreads >= thrN {
+				<-ch
+				nThreads--
+			}
+		}
+		for nThreads > 0 {
+			<-ch
+			nThreads--
+		}
+	} else {
+		lib.Printf("Using single threaded version\n")
+		for _, name := range pnames {
+			generateProjectFeeds(&ctx, name, excludeBots, lastTagCmd, projects.Projects[name].MainRepo)
+		}
+	}
+
+	// Upload generated feeds
+	if ctx.WebsiteDataUpload != "" {
+		uploadFeeds(&ctx)
+	}
+}
+
+// Main - runs website_data tool, os.Args are its command line (os.Args[0] is the tool name)
+func Main() {
+	dtStart := time.Now()
+	generateWebsiteData()
+	dtEnd := time.Now()
+	lib.Printf("Generated website data in: %v\n", dtEnd.Sub(dtStart))
+}
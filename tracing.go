@@ -0,0 +1,163 @@
+package devstatscode
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName - instrumentation name of all devstats spans
+const TracerName = "github.com/cncf/devstatscode"
+
+// Longer SQL statements are truncated in span attributes
+const maxSpanStatement = 0x1000
+
+// gTracing - set when spans are exported, there is no instrumentation overhead otherwise
+var gTracing bool
+
+// InitTracing - exports OpenTelemetry spans to GHA2DB_OTEL_ENDPOINT OTLP/HTTP collector (tracing is disabled when it is not set)
+// Trace context passed by the parent process in TRACEPARENT environment variable becomes parent of tool's spans (ctx.Trace)
+// Returned function flushes pending spans, call it before tool exits
+func InitTracing(ctx *Ctx, service string) func() {
+	if ctx.OTelEndpoint == "" {
+		return func() {}
+	}
+	u, err := url.Parse(ctx.OTelEndpoint)
+	FatalOnError(err)
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	FatalOnError(err)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ctx.OTelSample))),
+		sdktrace.WithResource(
+			resource.NewSchemaless(
+				attribute.String("service.name", service),
+				attribute.String("service.version", GitHash),
+				attribute.String("devstats.project", ctx.Project),
+			),
+		),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	gTracing = true
+	if traceParent := os.Getenv("TRACEPARENT"); traceParent != "" {
+		ctx.Trace = propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceParent})
+	}
+	Printf("Tracing enabled, exporting spans to %s, sample ratio %v\n", ctx.OTelEndpoint, ctx.OTelSample)
+	return func() {
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(sctx); err != nil {
+			Printf("Error flushing spans: %v\n", err)
+		}
+	}
+}
+
+// TraceTool - initializes tracing (see InitTracing) and starts tool's root span with its command line arguments
+// All SQL and command spans of the tool are children of this span, returned function ends it and flushes pending spans
+func TraceTool(ctx *Ctx, tool string) func() {
+	flush := InitTracing(ctx, tool)
+	if !gTracing {
+		return flush
+	}
+	sctx, span := StartSpan(ctx.Trace, tool, attribute.StringSlice("devstats.args", os.Args[1:]))
+	ctx.Trace = sctx
+	return func() {
+		span.End()
+		flush()
+	}
+}
+
+// TracingEnabled - returns true when spans are exported
+func TracingEnabled() bool {
+	return gTracing
+}
+
+// StartSpan - starts span as a child of a given context (nil starts a new trace), caller must end it (see EndSpan)
+func StartSpan(parent context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return otel.Tracer(TracerName).Start(parent, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan - ends span, marks it as failed when error is given
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sqlSpan - starts span of a single SQL statement on ctx.PgDB as a child of ctx.Trace, returns nil when tracing is disabled
+// Statement arguments are not recorded, statement text is redacted like logged queries (GHA2DB_SQL_REDACT)
+func sqlSpan(ctx *Ctx, query string) trace.Span {
+	if !gTracing {
+		return nil
+	}
+	statement := strings.TrimSpace(RedactSQLQuery(ctx, query))
+	operation := ""
+	if words := strings.Fields(statement); len(words) > 0 {
+		operation = strings.ToLower(words[0])
+	}
+	if len(statement) > maxSpanStatement {
+		statement = statement[:maxSpanStatement] + "..."
+	}
+	_, span := StartSpan(
+		ctx.Trace,
+		"sql "+operation,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.name", ctx.PgDB),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", statement),
+	)
+	return span
+}
+
+// endSQLSpan - ends span started by sqlSpan (if any)
+func endSQLSpan(span trace.Span, err error) {
+	if span != nil {
+		EndSpan(span, err)
+	}
+}
+
+// execSpan - starts span of a command executed by ExecCommand, returns environment with span's TRACEPARENT added
+// Tools started with TRACEPARENT continue the same trace (see InitTracing)
+func execSpan(ctx *Ctx, cmdAndArgs []string, env map[string]string) (map[string]string, trace.Span) {
+	sctx, span := StartSpan(
+		ctx.Trace,
+		"exec "+filepath.Base(cmdAndArgs[0]),
+		attribute.StringSlice("exec.args", cmdAndArgs[1:]),
+		attribute.String("devstats.project", env["GHA2DB_PROJECT"]),
+	)
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(sctx, carrier)
+	newEnv := make(map[string]string)
+	for key, value := range env {
+		newEnv[key] = value
+	}
+	if traceParent, ok := carrier["traceparent"]; ok {
+		newEnv["TRACEPARENT"] = traceParent
+	}
+	return newEnv, span
+}
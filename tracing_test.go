@@ -0,0 +1,79 @@
+package devstatscode
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	// Tracing is disabled without endpoint
+	var ctx lib.Ctx
+	lib.InitTracing(&ctx, "test")()
+	if lib.TracingEnabled() || ctx.Trace != nil {
+		t.Fatalf("expected tracing to be disabled without endpoint")
+	}
+	_, span := lib.StartSpan(nil, "test")
+	if span.SpanContext().IsValid() {
+		t.Errorf("expected no-op span when tracing is disabled")
+	}
+	lib.EndSpan(span, nil)
+
+	// Trace context from parent process, not sampled, so nothing is exported
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	os.Setenv("TRACEPARENT", "00-"+traceID+"-00f067aa0ba902b7-00")
+	defer os.Unsetenv("TRACEPARENT")
+	ctx = lib.Ctx{OTelEndpoint: "http://127.0.0.1:4318", OTelSample: 0.0}
+	flush := lib.TraceTool(&ctx, "test")
+	defer flush()
+	if !lib.TracingEnabled() {
+		t.Fatalf("expected tracing to be enabled")
+	}
+	_, span = lib.StartSpan(ctx.Trace, "child")
+	defer span.End()
+	sc := span.SpanContext()
+	if !sc.IsValid() || sc.TraceID().String() != traceID {
+		t.Errorf("expected child span of trace %s, got %+v", traceID, sc)
+	}
+	parent := trace.SpanContextFromContext(ctx.Trace)
+	if parent.SpanID() == sc.SpanID() || parent.TraceID() != sc.TraceID() {
+		t.Errorf("expected tool span %+v to be parent of %+v", parent, sc)
+	}
+}
+
+func TestSQLSpanRedaction(t *testing.T) {
+	ctx := lib.Ctx{OTelEndpoint: "http://127.0.0.1:4318", OTelSample: 1.0}
+	flush := lib.InitTracing(&ctx, "test")
+	defer flush()
+	// Record spans in memory instead of exporting them
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	ctx.SQLRedact = lib.SQLRedactDefault
+	ctx.PgHost, ctx.PgPort, ctx.PgDB, ctx.PgUser, ctx.PgPass, ctx.PgSSL = "127.0.0.1", "1", "gha", "gha_admin", "pwd", "disable"
+	con, err := lib.PgConnErr(&ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = con.Close() }()
+	// Nothing listens on port 1, the query fails but its span is still recorded
+	_ = lib.QueryRowSQL(con, &ctx, "select id from gha_actors where login = 'john' or email = 'john@example.com'").Scan()
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected a single SQL span, got %d", len(spans))
+	}
+	statement := ""
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "db.statement" {
+			statement = attr.Value.AsString()
+		}
+	}
+	if statement == "" || strings.Contains(statement, "john") {
+		t.Errorf("expected redacted statement, got '%s'", statement)
+	}
+}
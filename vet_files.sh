@@ -1,6 +1,6 @@
 #!/bin/bash
 $1 *.go || exit 1
-for dir in `find ./cmd/ -mindepth 1 -type d`
+for dir in `find ./cmd/ ./tools/ -mindepth 1 -type d` ./lib/trailers
 do
   $1 $dir/*.go || exit 1
 done
@@ -0,0 +1,39 @@
+package devstatscode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WebhookMaxPayload - maximum webhook payload size accepted by receivers, GitHub caps payloads at 25 MB
+const WebhookMaxPayload = 25 << 20
+
+// WebhookRespond - writes {"message": "..."} JSON response with a given HTTP status, used by webhook receivers
+func WebhookRespond(w http.ResponseWriter, status int, m string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	message := fmt.Sprintf("{\"message\": \"%s\"}", m)
+	_, _ = w.Write([]byte(message))
+}
+
+// VerifyWebhookSignature - verifies GitHub webhook X-Hub-Signature-256 header ("sha256=<hex HMAC-SHA256 of body>")
+func VerifyWebhookSignature(secret string, body []byte, signature string) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return errors.New("missing or invalid signature")
+	}
+	got, err := hex.DecodeString(signature[7:])
+	if err != nil {
+		return errors.New("cannot decode signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("unauthorized payload")
+	}
+	return nil
+}
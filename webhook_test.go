@@ -0,0 +1,34 @@
+package devstatscode
+
+import (
+	"testing"
+
+	lib "github.com/cncf/devstatscode"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	// HMAC-SHA256 of "Hello, World!" with "It's a Secret to Everybody" key (GitHub docs example)
+	body := []byte("Hello, World!")
+	signature := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	// Test cases
+	var testCases = []struct {
+		secret    string
+		body      []byte
+		signature string
+		expected  bool
+	}{
+		{secret: "It's a Secret to Everybody", body: body, signature: signature, expected: true},
+		{secret: "It's a Secret to Everybody", body: []byte("Hello, World?"), signature: signature},
+		{secret: "other secret", body: body, signature: signature},
+		{secret: "It's a Secret to Everybody", body: body, signature: signature[7:]},
+		{secret: "It's a Secret to Everybody", body: body, signature: "sha256=xyz"},
+		{secret: "It's a Secret to Everybody", body: body, signature: ""},
+	}
+	// Execute test cases
+	for index, test := range testCases {
+		err := lib.VerifyWebhookSignature(test.secret, test.body, test.signature)
+		if (err == nil) != test.expected {
+			t.Errorf("test number %d, expected valid %v, got error %v", index+1, test.expected, err)
+		}
+	}
+}